@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,6 +31,16 @@ type BackupSpec struct {
 	// included in the backup.
 	ExcludedNamespaces []string `json:"excludedNamespaces"`
 
+	// NamespaceMatching specifies how the entries in IncludedNamespaces
+	// and ExcludedNamespaces are matched against namespaces' names. If
+	// empty, they're matched exactly.
+	NamespaceMatching NamespaceMatching `json:"namespaceMatching,omitempty"`
+
+	// TerminatingNamespacePolicy specifies what to do with included
+	// namespaces that are being deleted when the backup runs. If empty,
+	// they're skipped.
+	TerminatingNamespacePolicy TerminatingNamespacePolicy `json:"terminatingNamespacePolicy,omitempty"`
+
 	// IncludedResources is a slice of resource names to include
 	// in the backup. If empty, all resources are included.
 	IncludedResources []string `json:"includedResources"`
@@ -38,35 +49,288 @@ type BackupSpec struct {
 	// included in the backup.
 	ExcludedResources []string `json:"excludedResources"`
 
+	// OrderedResources is a list of resources, formatted as
+	// resource.group, that are written to the backup's tarball before all
+	// other resources, in the order they're listed. Items of other
+	// resources that are backed up along with them, such as a pod's
+	// PersistentVolumeClaims, are written with them. Optional.
+	OrderedResources []string `json:"orderedResources,omitempty"`
+
 	// LabelSelector is a metav1.LabelSelector to filter with
 	// when adding individual objects to the backup. If empty
 	// or nil, all objects are included. Optional.
 	LabelSelector *metav1.LabelSelector `json:"labelSelector"`
 
+	// ExcludedLabelSelector is a metav1.LabelSelector for objects to
+	// leave out of the backup, even if they match LabelSelector. If nil,
+	// no objects are excluded by label. Optional.
+	ExcludedLabelSelector *metav1.LabelSelector `json:"excludedLabelSelector,omitempty"`
+
 	// SnapshotVolumes specifies whether to take cloud snapshots
 	// of any PV's referenced in the set of objects included
 	// in the Backup.
 	SnapshotVolumes *bool `json:"snapshotVolumes,omitempty"`
 
+	// VolumeSnapshotExcludeSelector is a metav1.LabelSelector for
+	// PersistentVolumes that aren't snapshotted, even if volumes are
+	// being snapshotted. A volume is excluded if its labels, or those of
+	// the PersistentVolumeClaim bound to it, match. It can't be set if
+	// SnapshotVolumes is false. Optional.
+	VolumeSnapshotExcludeSelector *metav1.LabelSelector `json:"volumeSnapshotExcludeSelector,omitempty"`
+
+	// FileCopyVolumes specifies whether the data in PersistentVolumeClaims
+	// mounted by pods should be copied at the file level, using restic,
+	// when their PersistentVolumes can't be snapshotted (e.g. hostPath,
+	// NFS, or local volumes).
+	FileCopyVolumes bool `json:"fileCopyVolumes,omitempty"`
+
 	// TTL is a time.Duration-parseable string describing how long
 	// the Backup should be retained for.
 	TTL metav1.Duration `json:"ttl"`
 
+	// BackupTimeout is how long collecting the backup's items may take
+	// before the backup is canceled and marked as failed. If unset, the
+	// server's default is used.
+	BackupTimeout metav1.Duration `json:"backupTimeout,omitempty"`
+
 	// IncludeClusterResources specifies whether cluster-scoped resources
 	// should be included for consideration in the backup.
 	IncludeClusterResources *bool `json:"includeClusterResources"`
 
+	// IncludeServiceAccountTokens specifies whether Secrets of type
+	// kubernetes.io/service-account-token should be included in the
+	// backup. These are regenerated by the target cluster, so they're
+	// excluded by default.
+	IncludeServiceAccountTokens bool `json:"includeServiceAccountTokens,omitempty"`
+
+	// MaxItemSizeBytes is the largest size, in bytes, of an item's JSON
+	// that is included in the backup. Larger items are skipped and
+	// recorded in the backup's status. Zero, the default, means there's
+	// no limit.
+	MaxItemSizeBytes int64 `json:"maxItemSizeBytes,omitempty"`
+
+	// ItemTransforms are applied, in order, to each item in the backup
+	// before it's written to the backup's tarball, e.g. to strip fields
+	// that bloat the backup or that hold secrets. Optional.
+	ItemTransforms []ItemTransform `json:"itemTransforms,omitempty"`
+
+	// EnabledPlugins is the names of the BackupItemAction plugins to run
+	// during the backup. If empty, all of the server's BackupItemAction
+	// plugins are run.
+	EnabledPlugins []string `json:"enabledPlugins,omitempty"`
+
+	// DisabledPlugins is the names of BackupItemAction plugins not to
+	// run during the backup, even if they're in EnabledPlugins.
+	DisabledPlugins []string `json:"disabledPlugins,omitempty"`
+
 	// Hooks represent custom behaviors that should be executed at different phases of the backup.
 	Hooks BackupHooks `json:"hooks"`
 
 	// StorageLocation is a string containing the name of a BackupStorageLocation where the backup should be stored.
 	StorageLocation string `json:"storageLocation"`
+
+	// MirrorStorageLocations is a list of names of additional
+	// BackupStorageLocations the backup should be uploaded to. Uploads to
+	// all of the backup's locations run concurrently.
+	MirrorStorageLocations []string `json:"mirrorStorageLocations,omitempty"`
+
+	// CredentialSecretRef references the key of a secret, in the backup's
+	// namespace, that holds the cloud credentials to upload the backup to
+	// its storage locations with, in the provider's credentials file
+	// format. If it's not set, the server's credentials are used.
+	CredentialSecretRef *corev1api.SecretKeySelector `json:"credentialSecretRef,omitempty"`
+
+	// NotifyWebhook, if set, is called with a summary of the backup once
+	// it's finished. Optional.
+	NotifyWebhook *BackupNotifyWebhook `json:"notifyWebhook,omitempty"`
+
+	// DeleteStorageOnRemoval specifies whether the backup's data should be
+	// deleted from its storage locations when the backup is deleted. If
+	// it's not set, the server's default is used.
+	DeleteStorageOnRemoval *bool `json:"deleteStorageOnRemoval,omitempty"`
+
+	// BaseBackup is the name of a completed full backup, in the same
+	// storage location, to take this backup incrementally from. Only the
+	// items whose resourceVersion has changed since the base backup are
+	// written to this backup's tarball, and restoring it layers them over
+	// the base backup's items.
+	BaseBackup string `json:"baseBackup,omitempty"`
+
+	// UploadPolicy specifies which of the backup's uploads must succeed
+	// for it to be completed. If empty, the upload to the primary
+	// storage location must succeed.
+	UploadPolicy UploadPolicy `json:"uploadPolicy,omitempty"`
+
+	// PartialFailurePolicy specifies how the backup treats errors backing
+	// up the items in individual namespaces. If empty, any such error
+	// fails the backup.
+	PartialFailurePolicy PartialFailurePolicy `json:"partialFailurePolicy,omitempty"`
+
+	// OnListError specifies what to do with a resource whose items can't
+	// be listed, e.g. because the aggregated API server that serves it is
+	// unavailable. If empty, the error is treated like any other error
+	// backing up the items in the namespace it was listed in.
+	OnListError ListErrorPolicy `json:"onListError,omitempty"`
+
+	// Description is free-form, human-readable text describing the backup
+	// (e.g. why it was taken). It does not affect the backup's behavior.
+	Description string `json:"description,omitempty"`
+
+	// Metadata is free-form key/value data, such as the git SHA of the
+	// application being backed up or the ID of a change ticket, that's
+	// recorded in the backup's status, and so is uploaded with it. It does
+	// not affect the backup's behavior.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// DryRun specifies that the backup should only collect its items,
+	// to show what would be backed up. Volumes aren't snapshotted or
+	// copied, nothing is uploaded to the backup's storage locations, and
+	// the items that would have been backed up are listed in the status.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Cancel requests that the backup be stopped. A backup that hasn't
+	// started yet is cancelled without running, and an in-progress backup
+	// is interrupted, moving through the Cancelling phase to Cancelled.
+	Cancel bool `json:"cancel,omitempty"`
+
+	// IntegrityManifest specifies that a manifest listing each file in the
+	// backup's tarball, with its item's resource, namespace and name and
+	// where its contents are in the uncompressed tarball, is uploaded
+	// alongside it. The manifest is written as the tarball is, so a backup
+	// that fails partway through lists what it captured.
+	IntegrityManifest bool `json:"integrityManifest,omitempty"`
+
+	// ConsistencyMode specifies how the backup tracks the consistency of
+	// the items it captures. Items are listed from the API server one
+	// resource at a time, so they aren't captured at a single point in
+	// time. If empty, consistency isn't tracked.
+	ConsistencyMode BackupConsistencyMode `json:"consistencyMode,omitempty"`
 }
 
+// ItemTransform is a change made to each item in a backup before it's
+// written to the backup's tarball.
+type ItemTransform struct {
+	// Remove is a JSONPath expression for a field to remove from each
+	// item, such as {.metadata.managedFields}, {.status} or
+	// {.metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']}.
+	// Only field names are supported, not array indexes or filters.
+	// Items that don't have the field are left as they are.
+	Remove string `json:"remove"`
+}
+
+// TerminatingNamespacePolicy defines how a backup treats namespaces
+// that are being deleted.
+type TerminatingNamespacePolicy string
+
+const (
+	// TerminatingNamespacePolicySkip means that terminating namespaces,
+	// and everything in them, are left out of the backup, so that
+	// restoring it doesn't recreate namespaces that were being deleted.
+	TerminatingNamespacePolicySkip TerminatingNamespacePolicy = "Skip"
+
+	// TerminatingNamespacePolicyInclude means that terminating namespaces
+	// are backed up like any other, even though their contents may be
+	// partially deleted.
+	TerminatingNamespacePolicyInclude TerminatingNamespacePolicy = "Include"
+)
+
+// NamespaceMatching defines how a backup's included and excluded
+// namespaces are matched against namespaces' names.
+type NamespaceMatching string
+
+const (
+	// NamespaceMatchingExact means that each included or excluded
+	// namespace matches only the namespace with that name, except for
+	// '*' in the included namespaces, which matches every namespace.
+	NamespaceMatchingExact NamespaceMatching = "Exact"
+
+	// NamespaceMatchingGlob means that each included or excluded
+	// namespace is a shell-style glob pattern, e.g. "team-*" or
+	// "prod-??", with the syntax of Go's path.Match.
+	NamespaceMatchingGlob NamespaceMatching = "Glob"
+)
+
+// UploadPolicy defines which of a backup's uploads to its storage
+// locations must succeed for it to be completed.
+type UploadPolicy string
+
+const (
+	// UploadPolicyRequireAny means that the backup is completed if it
+	// was uploaded to at least one of its locations. Failed uploads are
+	// logged as warnings. If the upload to the primary storage location
+	// failed, the first mirror location it was uploaded to becomes the
+	// primary location.
+	UploadPolicyRequireAny UploadPolicy = "RequireAny"
+
+	// UploadPolicyRequireAll means that the backup fails unless it was
+	// uploaded to all of its locations.
+	UploadPolicyRequireAll UploadPolicy = "RequireAll"
+
+	// UploadPolicyRequirePrimary means that the backup fails unless it
+	// was uploaded to its primary storage location. Failed uploads to its
+	// mirror locations are logged as warnings. It's the default.
+	UploadPolicyRequirePrimary UploadPolicy = "RequirePrimary"
+)
+
+// PartialFailurePolicy defines how a backup treats errors backing up
+// the items in individual namespaces.
+type PartialFailurePolicy string
+
+const (
+	// PartialFailurePolicyFail means that an error backing up a
+	// namespace's items fails the backup.
+	PartialFailurePolicyFail PartialFailurePolicy = "Fail"
+
+	// PartialFailurePolicyContinue means that errors backing up a
+	// namespace's items are recorded in the backup's status, and the
+	// rest of the backup continues. If there were any, the backup is
+	// partially failed rather than completed.
+	PartialFailurePolicyContinue PartialFailurePolicy = "Continue"
+)
+
+// ListErrorPolicy defines how a backup treats errors listing a
+// resource's items.
+type ListErrorPolicy string
+
+const (
+	// ListErrorPolicyFail means that an error listing a resource's items
+	// is handled under the backup's PartialFailurePolicy.
+	ListErrorPolicyFail ListErrorPolicy = "Fail"
+
+	// ListErrorPolicySkip means that a resource whose items can't be
+	// listed is left out of the backup, recorded in its status and as a
+	// warning, and the rest of the backup continues.
+	ListErrorPolicySkip ListErrorPolicy = "Skip"
+)
+
+// BackupConsistencyMode defines how a backup tracks the consistency of
+// the items it captures.
+type BackupConsistencyMode string
+
+const (
+	// BackupConsistencyModeNone means that consistency isn't tracked.
+	BackupConsistencyModeNone BackupConsistencyMode = "None"
+
+	// BackupConsistencyModeResourceVersion means that the range of
+	// resourceVersions of each resource's items is recorded in the
+	// backup's status, along with the items that were modified after the
+	// backup began listing items, which may not be consistent with the
+	// items listed before them.
+	BackupConsistencyModeResourceVersion BackupConsistencyMode = "ResourceVersion"
+)
+
 // BackupHooks contains custom behaviors that should be executed at different phases of the backup.
 type BackupHooks struct {
 	// Resources are hooks that should be executed when backing up individual instances of a resource.
 	Resources []BackupResourceHookSpec `json:"resources"`
+	// Validate specifies whether to check that hooks can be run before running them, i.e. that the
+	// pods they target are running with their hook containers started, and that the hooks' timeouts
+	// fit within the backup's pod volume timeout. Problems are logged as warnings.
+	Validate bool `json:"validate,omitempty"`
+	// FailOnPreHookError specifies whether the backup should be marked as failed if any pre hook
+	// fails, even if the hook's OnError is Continue. Pre hooks are typically used to quiesce
+	// applications, so backing up an item whose pre hook failed may capture inconsistent data.
+	FailOnPreHookError bool `json:"failOnPreHookError,omitempty"`
 }
 
 // BackupResourceHookSpec defines one or more BackupResourceHooks that should be executed based on
@@ -94,6 +358,14 @@ type BackupResourceHookSpec struct {
 	// PostHooks is a list of BackupResourceHooks to execute after storing the item in the backup.
 	// These are executed after all "additional items" from item actions are processed.
 	PostHooks []BackupResourceHook `json:"post,omitempty"`
+	// PreSnapshotHooks is a list of BackupResourceHooks to execute in a pod before each of the
+	// PersistentVolumes its PersistentVolumeClaims are bound to is snapshotted. Volume snapshots
+	// may be taken in the background, after the pod's post hooks have run, so these are the hooks
+	// to use to quiesce an application for its volumes' snapshots.
+	PreSnapshotHooks []BackupResourceHook `json:"preSnapshot,omitempty"`
+	// PostSnapshotHooks is a list of BackupResourceHooks to execute in a pod after each of its
+	// volumes is snapshotted, whether or not the snapshot succeeded.
+	PostSnapshotHooks []BackupResourceHook `json:"postSnapshot,omitempty"`
 }
 
 // BackupResourceHook defines a hook for a resource.
@@ -128,6 +400,20 @@ const (
 	HookErrorModeFail HookErrorMode = "Fail"
 )
 
+// BackupNotifyWebhook is a webhook that's sent a JSON summary of a backup,
+// in a POST request, once it's finished.
+type BackupNotifyWebhook struct {
+	// URL is the http or https URL the summary is posted to.
+	URL string `json:"url"`
+
+	// SecretRef references the key of a secret, in the backup's
+	// namespace, that the summary is signed with. If it's set, the
+	// request's X-Ark-Signature header is "sha256=" followed by the
+	// hex-encoded HMAC-SHA256 of the request body, keyed with the
+	// secret's value. Optional.
+	SecretRef *corev1api.SecretKeySelector `json:"secretRef,omitempty"`
+}
+
 // BackupPhase is a string representation of the lifecycle phase
 // of an Ark backup.
 type BackupPhase string
@@ -148,10 +434,29 @@ const (
 	// errors.
 	BackupPhaseCompleted BackupPhase = "Completed"
 
+	// BackupPhaseCompletedEmpty means the backup ran without errors, but
+	// captured no items, or its tarball was smaller than the server's
+	// --min-backup-size-bytes, which usually means its includes and
+	// excludes are misconfigured. It's uploaded like a completed backup.
+	BackupPhaseCompletedEmpty BackupPhase = "CompletedEmpty"
+
 	// BackupPhaseFailed means the backup ran but encountered an error that
 	// prevented it from completing successfully.
 	BackupPhaseFailed BackupPhase = "Failed"
 
+	// BackupPhasePartiallyFailed means the backup ran to completion, but
+	// errors backing up the items in some namespaces were recorded under
+	// its PartialFailurePolicy of Continue.
+	BackupPhasePartiallyFailed BackupPhase = "PartiallyFailed"
+
+	// BackupPhaseCancelling means that cancelling the in-progress backup
+	// was requested, and it's being stopped.
+	BackupPhaseCancelling BackupPhase = "Cancelling"
+
+	// BackupPhaseCancelled means the backup was stopped because its
+	// spec's Cancel was set. Only its log is uploaded.
+	BackupPhaseCancelled BackupPhase = "Cancelled"
+
 	// BackupPhaseDeleting means the backup and all its associated data are being deleted.
 	BackupPhaseDeleting BackupPhase = "Deleting"
 )
@@ -164,6 +469,11 @@ type BackupStatus struct {
 	// Expiration is when this Backup is eligible for garbage-collection.
 	Expiration metav1.Time `json:"expiration"`
 
+	// ObjectLockExpiration is when the object locks on the backup's
+	// objects in backup storage expire, if its storage locations lock
+	// them. The backup can't be deleted before then.
+	ObjectLockExpiration metav1.Time `json:"objectLockExpiration,omitempty"`
+
 	// Phase is the current state of the Backup.
 	Phase BackupPhase `json:"phase"`
 
@@ -172,10 +482,23 @@ type BackupStatus struct {
 	// provider API.
 	VolumeBackups map[string]*VolumeBackupInfo `json:"volumeBackups"`
 
+	// VolumeBackupMethods is a map of PersistentVolume names to
+	// the method used to back up the volume's data.
+	VolumeBackupMethods map[string]VolumeBackupMethod `json:"volumeBackupMethods,omitempty"`
+
 	// ValidationErrors is a slice of all validation errors (if
 	// applicable).
 	ValidationErrors []string `json:"validationErrors"`
 
+	// ValidationWarnings is a slice of problems found while validating
+	// the backup that don't fail it, such as included resources that
+	// the API server doesn't serve.
+	ValidationWarnings []string `json:"validationWarnings,omitempty"`
+
+	// FailureReason is an error that caused the entire backup to be
+	// aborted, such as it timing out.
+	FailureReason string `json:"failureReason,omitempty"`
+
 	// StartTimestamp records the time a backup was started.
 	// Separate from CreationTimestamp, since that value changes
 	// on restores.
@@ -187,8 +510,296 @@ type BackupStatus struct {
 	// Completion time is recorded before uploading the backup object.
 	// The server's time is used for CompletionTimestamps
 	CompletionTimestamp metav1.Time `json:"completionTimestamp"`
+
+	// Description is the description from the backup's spec, recorded
+	// when the backup was processed.
+	Description string `json:"description,omitempty"`
+
+	// Metadata is the metadata from the backup's spec, recorded when the
+	// backup was processed.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// SkippedItems is the number of items that were intentionally left
+	// out of the backup, such as service account token Secrets.
+	SkippedItems int `json:"skippedItems,omitempty"`
+
+	// SkippedLargeItems lists the items that were left out of the
+	// backup because they were larger than the spec's MaxItemSizeBytes.
+	// They're also counted in SkippedItems.
+	SkippedLargeItems []SkippedLargeItem `json:"skippedLargeItems,omitempty"`
+
+	// SkippedTerminatingNamespaces lists the namespaces that were left
+	// out of the backup because they were being deleted.
+	SkippedTerminatingNamespaces []string `json:"skippedTerminatingNamespaces,omitempty"`
+
+	// SkippedUnlistableResources lists the resources that were left out
+	// of the backup, under an OnListError policy of Skip, because their
+	// items couldn't be listed.
+	SkippedUnlistableResources []SkippedUnlistableResource `json:"skippedUnlistableResources,omitempty"`
+
+	// KeptTempFiles lists the paths, on the Ark server that ran the
+	// backup, of the temp files its tarball and log were staged in, if
+	// the server was configured to keep them for debugging.
+	KeptTempFiles []string `json:"keptTempFiles,omitempty"`
+
+	// ResolvedIncludesExcludes is the namespaces and resources that the
+	// backup was configured to include and exclude, as they were applied,
+	// so that the backup's metadata describes what it captured.
+	ResolvedIncludesExcludes *BackupResolvedIncludesExcludes `json:"resolvedIncludesExcludes,omitempty"`
+
+	// PartialFailures lists the errors backing up the items in individual
+	// namespaces that were recorded under a PartialFailurePolicy of
+	// Continue.
+	PartialFailures []string `json:"partialFailures,omitempty"`
+
+	// UnchangedItems is the number of items that were left out of an
+	// incremental backup's tarball because they hadn't changed since its
+	// base backup.
+	UnchangedItems int `json:"unchangedItems,omitempty"`
+
+	// Warnings is the number of warnings that were logged during the
+	// backup, such as for namespaces or items that were skipped. Warnings
+	// don't fail the backup.
+	Warnings int `json:"warnings,omitempty"`
+
+	// HookResults records the outcome of each hook that was run
+	// during the backup.
+	HookResults []BackupHookResult `json:"hookResults,omitempty"`
+
+	// LocationStatuses is a map of the names of the backup's storage
+	// locations to the outcome of uploading it to each of them.
+	LocationStatuses map[string]UploadStatus `json:"locationStatuses,omitempty"`
+
+	// Progress summarizes the items that were written to the backup's
+	// tarball. It's recorded even if the backup fails partway through,
+	// so it shows what was captured before the failure.
+	Progress *BackupProgress `json:"progress,omitempty"`
+
+	// BackupItemActionVersions is the version of each BackupItemAction
+	// plugin that ran during the backup, keyed by plugin name. Plugins that
+	// don't report a version are recorded as "unknown".
+	BackupItemActionVersions map[string]string `json:"backupItemActionVersions,omitempty"`
+
+	// TarballChecksum is the hex-encoded SHA-256 checksum of the backup's
+	// tarball, as it was written before being uploaded. It's empty if the
+	// backup was aborted.
+	TarballChecksum string `json:"tarballChecksum,omitempty"`
+
+	// StorageLocationReason is why the backup's storage location was
+	// chosen.
+	StorageLocationReason StorageLocationReason `json:"storageLocationReason,omitempty"`
+
+	// StorageLocationMatchedLabel is the label, formatted as key=value,
+	// that the backup and its storage location both have, if the location
+	// was chosen by matching it.
+	StorageLocationMatchedLabel string `json:"storageLocationMatchedLabel,omitempty"`
+
+	// LogFormat is the format the backup's log was written in, text or
+	// json. Backups without one were written as text.
+	LogFormat string `json:"logFormat,omitempty"`
+
+	// LogTruncated is true if the backup's log couldn't be written in
+	// full, e.g. because the disk filled up, so the uploaded log may be
+	// missing its end or fail to decompress.
+	LogTruncated bool `json:"logTruncated,omitempty"`
+
+	// Consistency records the resourceVersions of the items captured by
+	// the backup, if its ConsistencyMode is ResourceVersion.
+	Consistency *BackupConsistency `json:"consistency,omitempty"`
+
+	// GroupVersions records the group version each resource's items were
+	// backed up at, e.g. "apps/v1" for "deployments.apps", keyed by
+	// group-resource, so that a restore can tell when a version it needs
+	// is no longer served by the cluster it's restored into.
+	GroupVersions map[string]string `json:"groupVersions,omitempty"`
+}
+
+// BackupResolvedIncludesExcludes is a backup's included and excluded
+// namespaces and resources after they were resolved. Resources are
+// fully-qualified group-resources rather than the shortcuts they may be
+// specified as, resources that couldn't be resolved are left out, and
+// empty includes are listed as "*". Each list is sorted.
+type BackupResolvedIncludesExcludes struct {
+	IncludedNamespaces []string `json:"includedNamespaces,omitempty"`
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	IncludedResources  []string `json:"includedResources,omitempty"`
+	ExcludedResources  []string `json:"excludedResources,omitempty"`
+}
+
+// StorageLocationReason is why a backup's storage location was chosen.
+type StorageLocationReason string
+
+const (
+	// StorageLocationReasonSpec means that the location was set in the
+	// backup's spec.
+	StorageLocationReasonSpec StorageLocationReason = "Spec"
+
+	// StorageLocationReasonLabelMatch means that the location was the only
+	// one with the same value as the backup for the server's
+	// --backup-location-label.
+	StorageLocationReasonLabelMatch StorageLocationReason = "LabelMatch"
+
+	// StorageLocationReasonDefault means that the location is the server's
+	// default, because no other location was chosen.
+	StorageLocationReasonDefault StorageLocationReason = "Default"
+)
+
+// BackupProgress summarizes the items captured by a backup.
+type BackupProgress struct {
+	// ItemsBackedUp is the number of items written to the backup's
+	// tarball.
+	ItemsBackedUp int `json:"itemsBackedUp"`
+
+	// ResourceCounts is a map of resources, formatted as resource.group,
+	// to the number of items of each that were written to the tarball.
+	ResourceCounts map[string]int `json:"resourceCounts,omitempty"`
+
+	// ResourceSizes is a map of resources, formatted as resource.group,
+	// to the total size, in bytes, of the items of each that were written
+	// to the tarball, before it was compressed.
+	ResourceSizes map[string]int64 `json:"resourceSizes,omitempty"`
+
+	// Namespaces is the number of namespaces that had at least one item
+	// written to the tarball.
+	Namespaces int `json:"namespaces"`
+
+	// VolumeSnapshots is the number of PersistentVolume snapshots taken
+	// in the cloud provider API.
+	VolumeSnapshots int `json:"volumeSnapshots"`
+
+	// Items lists the path in the tarball of each item that was written
+	// to it. It's only recorded for dry runs.
+	Items []string `json:"items,omitempty"`
+}
+
+// BackupConsistency records the resourceVersions of the items captured by
+// a backup, to show which of them may be inconsistent with each other.
+type BackupConsistency struct {
+	// StartResourceVersion is the resourceVersion of the first list of
+	// items the backup made. Items with a later resourceVersion were
+	// modified while the backup was running.
+	StartResourceVersion string `json:"startResourceVersion,omitempty"`
+
+	// Resources is the range of resourceVersions of each resource's
+	// items, keyed by resource.group.
+	Resources map[string]ResourceVersionRange `json:"resources,omitempty"`
+
+	// PotentiallyInconsistentItems lists the items, formatted as
+	// resource.group/namespace/name or resource.group/name, that were
+	// modified after StartResourceVersion, so may not be consistent with
+	// the items captured before them.
+	PotentiallyInconsistentItems []string `json:"potentiallyInconsistentItems,omitempty"`
 }
 
+// ResourceVersionRange is the range of resourceVersions of a resource's
+// items in a backup.
+type ResourceVersionRange struct {
+	// Min is the smallest resourceVersion of the resource's items.
+	Min string `json:"min"`
+
+	// Max is the largest resourceVersion of the resource's items.
+	Max string `json:"max"`
+
+	// PotentiallyInconsistent is whether any of the resource's items were
+	// modified after the backup's StartResourceVersion.
+	PotentiallyInconsistent bool `json:"potentiallyInconsistent,omitempty"`
+}
+
+// UploadPhase is the outcome of uploading a backup to a storage location.
+type UploadPhase string
+
+const (
+	// UploadPhaseSucceeded means all of the backup's files were uploaded.
+	UploadPhaseSucceeded UploadPhase = "Succeeded"
+
+	// UploadPhaseFailed means the upload failed, so the location may
+	// have none or only some of the backup's files.
+	UploadPhaseFailed UploadPhase = "Failed"
+)
+
+// UploadStatus records the outcome of uploading a backup to one of its
+// storage locations.
+type UploadStatus struct {
+	// Phase is the outcome of the upload.
+	Phase UploadPhase `json:"phase"`
+
+	// Error is the error the upload failed with, if any.
+	Error string `json:"error,omitempty"`
+
+	// Duration is how long the upload took.
+	Duration metav1.Duration `json:"duration"`
+}
+
+// SkippedLargeItem identifies an item that was left out of a backup
+// because of its size.
+type SkippedLargeItem struct {
+	// GroupResource is the item's resource, formatted as resource.group.
+	GroupResource string `json:"groupResource"`
+
+	// Namespace is the item's namespace, if it's namespaced.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name is the item's name.
+	Name string `json:"name"`
+
+	// SizeBytes is the size, in bytes, of the item's JSON.
+	SizeBytes int64 `json:"sizeBytes"`
+}
+
+// SkippedUnlistableResource identifies a resource whose items were left
+// out of a backup because they couldn't be listed.
+type SkippedUnlistableResource struct {
+	// GroupResource is the resource, formatted as resource.group.
+	GroupResource string `json:"groupResource"`
+
+	// Namespace is the namespace the items were listed in, or empty if
+	// they were listed across all namespaces or the resource is
+	// cluster-scoped.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Error is the error listing the items.
+	Error string `json:"error"`
+}
+
+// BackupHookResult records the outcome of running a hook on a pod
+// during a backup.
+type BackupHookResult struct {
+	// Name is the name of the hook spec the hook was defined in, or
+	// "<from-annotation>" for hooks defined by pod annotations.
+	Name string `json:"name"`
+
+	// Phase is "pre", "post", "pre-snapshot" or "post-snapshot".
+	Phase string `json:"phase"`
+
+	// Namespace is the namespace of the pod the hook was run in.
+	Namespace string `json:"namespace"`
+
+	// Pod is the name of the pod the hook was run in.
+	Pod string `json:"pod"`
+
+	// Container is the container the hook was run in.
+	Container string `json:"container,omitempty"`
+
+	// Succeeded is whether the hook ran successfully.
+	Succeeded bool `json:"succeeded"`
+
+	// Error is the error from the hook if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// VolumeBackupMethod is how a PersistentVolume's data was backed up.
+type VolumeBackupMethod string
+
+const (
+	// VolumeBackupMethodSnapshot means the volume was snapshotted
+	// in the cloud provider API.
+	VolumeBackupMethodSnapshot VolumeBackupMethod = "Snapshot"
+
+	// VolumeBackupMethodFileCopy means the volume's files were
+	// copied using restic.
+	VolumeBackupMethodFileCopy VolumeBackupMethod = "FileCopy"
+)
+
 // VolumeBackupInfo captures the required information about
 // a PersistentVolume at backup time to be able to restore
 // it later.
@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -55,6 +56,13 @@ type ObjectStorageLocation struct {
 
 	// Prefix is the path inside a bucket to use for Ark storage. Optional.
 	Prefix string `json:"prefix"`
+
+	// KeyLayout is the name of the layout of the object keys of the
+	// location's backups and restores: "flat", the default, or "date",
+	// which partitions them by the dates in their names. Backups and
+	// restores stored in either layout are found whichever is set, so it
+	// can be changed without moving them. Optional.
+	KeyLayout string `json:"keyLayout,omitempty"`
 }
 
 // BackupStorageLocationSpec defines the specification for an Ark BackupStorageLocation.
@@ -66,6 +74,42 @@ type BackupStorageLocationSpec struct {
 	Config map[string]string `json:"config"`
 
 	StorageType `json:",inline"`
+
+	// EncryptionKeySecret references the key of a secret, in the
+	// location's namespace, that holds the 32-byte AES-256 key that
+	// backups stored in this location are encrypted with. If it's not
+	// set, backups are stored unencrypted. Optional.
+	EncryptionKeySecret *corev1api.SecretKeySelector `json:"encryptionKeySecret,omitempty"`
+
+	// ProbeBeforeBackup specifies whether to check that the location can
+	// be written to and read from before starting each backup to it, so
+	// that backups to an unusable location fail validation instead of
+	// failing after all of their items have been collected. Optional.
+	ProbeBeforeBackup bool `json:"probeBeforeBackup,omitempty"`
+
+	// ServerSideEncryption specifies how the object storage provider
+	// should encrypt the objects Ark puts in this location. Providers
+	// that don't support server-side encryption ignore it. Optional.
+	ServerSideEncryption *ServerSideEncryption `json:"serverSideEncryption,omitempty"`
+
+	// ObjectLockRetention is how long each object Ark puts in this
+	// location is locked for, from when it's put, so that it can't be
+	// deleted or overwritten until the lock expires. The bucket must have
+	// object lock enabled. Providers that don't support object locks
+	// ignore it. Optional.
+	ObjectLockRetention metav1.Duration `json:"objectLockRetention,omitempty"`
+}
+
+// ServerSideEncryption holds the server-side encryption options that are
+// passed to a location's object store when putting objects.
+type ServerSideEncryption struct {
+	// Algorithm is the server-side encryption algorithm, e.g. "AES256"
+	// or "aws:kms" for AWS.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// KMSKeyID is the ID of the key management service key that objects
+	// are encrypted with.
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
 }
 
 // BackupStorageLocationPhase is the lifecyle phase of an Ark BackupStorageLocation.
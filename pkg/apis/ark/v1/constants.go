@@ -40,4 +40,9 @@ const (
 	// NamespaceScopedDir is the name of the directory containing namespace-scoped
 	// resource within an Ark backup.
 	NamespaceScopedDir = "namespaces"
+
+	// DeleteBackupStorageFinalizer is the finalizer added to backups whose
+	// data should be deleted from their storage locations when they're
+	// deleted. The backup isn't removed until its data has been deleted.
+	DeleteBackupStorageFinalizer = "ark.heptio.com/delete-backup-storage"
 )
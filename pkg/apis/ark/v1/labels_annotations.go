@@ -37,7 +37,77 @@ const (
 	// restic backups/restores).
 	PodVolumeOperationTimeoutAnnotation = "ark.heptio.com/pod-volume-timeout"
 
+	// PluginVersionsAnnotation is the annotation key used to record, as a
+	// JSON object keyed by "<kind>/<name>", the versions of the plugins that
+	// were available when a backup was taken.
+	PluginVersionsAnnotation = "ark.heptio.com/plugin-versions"
+
+	// TransferAnnotation is the annotation key used to request, with the
+	// value "true", that a backup be streamed to, or a restore be streamed
+	// from, the server's transfer endpoint instead of object storage.
+	TransferAnnotation = "ark.heptio.com/transfer"
+
+	// CompletionMarkerAnnotation is the annotation key used to record that a
+	// backup's upload ends with a completion marker, so the backup should be
+	// treated as incomplete if the marker is missing. Backups uploaded before
+	// completion markers existed don't have it.
+	CompletionMarkerAnnotation = "ark.heptio.com/completion-marker"
+
+	// TransformsAnnotation is the annotation key used to record, as a
+	// comma-separated list in the order they were applied, the transform
+	// stages a backup's tarball was passed through before it was uploaded.
+	TransformsAnnotation = "ark.heptio.com/transforms"
+
+	// CompressionAnnotation is the annotation key used to record the
+	// algorithm a backup's tarball was compressed with. Backups without it
+	// are gzip-compressed.
+	CompressionAnnotation = "ark.heptio.com/compression"
+
+	// ContentIndexVersionAnnotation is the annotation key used to record
+	// the format version of the content index uploaded alongside a
+	// backup's tarball. Backups without a content index don't have it.
+	ContentIndexVersionAnnotation = "ark.heptio.com/content-index-version"
+
+	// BaseBackupAnnotation is the annotation key used to record the name
+	// of the base backup an incremental backup's tarball only holds the
+	// changes since. Restores layer its tarball over the base backup's.
+	BaseBackupAnnotation = "ark.heptio.com/base-backup"
+
+	// LogLevelAnnotation is the annotation key used to request that a
+	// backup be logged at the given level (e.g. "debug") instead of the
+	// server's backup log level.
+	LogLevelAnnotation = "ark.heptio.com/log-level"
+
+	// RepairRequestedAnnotation is the annotation key used to request that
+	// the server re-upload whichever of a backup's artifacts are missing
+	// from its storage location and can be reconstructed. It's removed once
+	// the backup's been repaired.
+	RepairRequestedAnnotation = "ark.heptio.com/repair-requested"
+
+	// RepairResultAnnotation is the annotation key used to record which of
+	// a backup's artifacts were repaired, and which couldn't be, the last
+	// time a repair was requested.
+	RepairResultAnnotation = "ark.heptio.com/repair-result"
+
+	// BackupSetLabel is the label key used to group related backups, such
+	// as those taken together for a coordinated snapshot, into a backup set.
+	BackupSetLabel = "ark.heptio.com/backup-set"
+
+	// BackupSetOrderLabel is the label key used to order the backups in a
+	// backup set. Backups with a lower order are restored first.
+	BackupSetOrderLabel = "ark.heptio.com/backup-set-order"
+
+	// ClusterNameLabel is the label key used to identify the cluster a
+	// backup was taken in, when the server that took it was given a
+	// cluster name.
+	ClusterNameLabel = "ark.heptio.com/cluster-name"
+
 	// StorageLocationLabel is the label key used to identify the storage
 	// location of a backup.
 	StorageLocationLabel = "ark.heptio.com/storage-location"
+
+	// BaseBackupNameLabel is the label key used to identify the base backup
+	// of an incremental backup, so that the base isn't deleted while
+	// incremental backups depend on it.
+	BaseBackupNameLabel = "ark.heptio.com/base-backup-name"
 )
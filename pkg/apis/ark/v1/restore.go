@@ -29,6 +29,13 @@ type RestoreSpec struct {
 	// from the most recent successful backup created from this schedule.
 	ScheduleName string `json:"scheduleName,omitempty"`
 
+	// StorageLocationOverride is the name of the backup storage location
+	// to read the backup from, instead of the location it was written to,
+	// e.g. because its bucket has been migrated and the original location
+	// no longer exists. The restore's log and results are stored there
+	// too. Optional.
+	StorageLocationOverride string `json:"storageLocationOverride,omitempty"`
+
 	// IncludedNamespaces is a slice of namespace names to include objects
 	// from. If empty, all namespaces are included.
 	IncludedNamespaces []string `json:"includedNamespaces"`
@@ -64,8 +71,66 @@ type RestoreSpec struct {
 	// should be included for consideration in the restore. If null, defaults
 	// to true.
 	IncludeClusterResources *bool `json:"includeClusterResources,omitempty"`
+
+	// VerifyItemCounts specifies whether the number of items restored for
+	// each group-resource should be checked against the backup's contents
+	// once the restore completes. Items that weren't restored or skipped
+	// (e.g. due to the restore's filters) are reported as warnings.
+	VerifyItemCounts bool `json:"verifyItemCounts,omitempty"`
+
+	// VerifyManifest specifies that the backup's tarball is checked
+	// against its integrity manifest before anything is restored, failing
+	// the restore if they differ. The backup must have been taken with
+	// Spec.IntegrityManifest.
+	VerifyManifest bool `json:"verifyManifest,omitempty"`
+
+	// ExistingResourcePolicy specifies what to do with items that already
+	// exist in the cluster and differ from the backed-up version. If empty,
+	// defaults to ExistingResourcePolicyNone.
+	ExistingResourcePolicy ExistingResourcePolicy `json:"existingResourcePolicy,omitempty"`
+
+	// ImmutableFieldPolicy specifies what to do when updating an existing
+	// item fails because fields that differ from the backed-up version are
+	// immutable. It only applies if ExistingResourcePolicy is
+	// ExistingResourcePolicyUpdate. If empty, defaults to
+	// ImmutableFieldPolicySkip.
+	ImmutableFieldPolicy ImmutableFieldPolicy `json:"immutableFieldPolicy,omitempty"`
 }
 
+// ExistingResourcePolicy is a policy for restoring items that already exist
+// in the cluster.
+type ExistingResourcePolicy string
+
+const (
+	// ExistingResourcePolicyNone means existing items are left as they
+	// are, with a warning if they differ from the backed-up version.
+	ExistingResourcePolicyNone ExistingResourcePolicy = "none"
+
+	// ExistingResourcePolicyUpdate means existing items are patched to match
+	// the backed-up version.
+	ExistingResourcePolicyUpdate ExistingResourcePolicy = "update"
+)
+
+// ImmutableFieldPolicy is a policy for updating existing items whose
+// immutable fields differ from the backed-up version.
+type ImmutableFieldPolicy string
+
+const (
+	// ImmutableFieldPolicySkip means the item isn't updated, and a warning
+	// is recorded.
+	ImmutableFieldPolicySkip ImmutableFieldPolicy = "skip"
+
+	// ImmutableFieldPolicyRecreate means the existing item is deleted,
+	// orphaning any dependents rather than deleting them, and is then
+	// recreated from the backed-up version. PersistentVolumes and
+	// PersistentVolumeClaims are never recreated, since deleting them can
+	// delete the underlying volume, so they're skipped instead.
+	ImmutableFieldPolicyRecreate ImmutableFieldPolicy = "recreate"
+
+	// ImmutableFieldPolicyFail means an error is recorded for the item.
+	ImmutableFieldPolicyFail ImmutableFieldPolicy = "fail"
+)
+
 // RestorePhase is a string representation of the lifecycle phase
 // of an Ark restore
 type RestorePhase string
@@ -110,6 +175,30 @@ type RestoreStatus struct {
 
 	// FailureReason is an error that caused the entire restore to fail.
 	FailureReason string `json:"failureReason"`
+
+	// ItemCounts reconciles, for each group-resource in the backup, the
+	// number of items in the backup with the number restored. It's only
+	// populated if Spec.VerifyItemCounts is true.
+	ItemCounts []RestoreItemCount `json:"itemCounts,omitempty"`
+}
+
+// RestoreItemCount is the number of items of a group-resource, such as
+// "deployments.apps", that were in a restore's backup, and how many of them
+// were restored or intentionally skipped.
+type RestoreItemCount struct {
+	// GroupResource is the group-resource the counts are for.
+	GroupResource string `json:"groupResource"`
+
+	// InBackup is the number of items contained in the backup.
+	InBackup int `json:"inBackup"`
+
+	// Restored is the number of items that were created, or that already
+	// existed in the cluster.
+	Restored int `json:"restored"`
+
+	// Skipped is the number of items that weren't restored intentionally,
+	// e.g. because they were excluded by the restore's filters.
+	Skipped int `json:"skipped"`
 }
 
 // RestoreResult is a collection of messages that were generated
@@ -27,6 +27,28 @@ type ScheduleSpec struct {
 	// Schedule is a Cron expression defining when to run
 	// the Backup.
 	Schedule string `json:"schedule"`
+
+	// BackupNameTemplate is a Go template that the names of the
+	// schedule's backups are rendered from. It can use .Schedule (the
+	// schedule's name), .ClusterName (the server's cluster name),
+	// .Timestamp (when the backup was created) and .Sequence (0, or
+	// the number of times the name has been rendered again because it
+	// was taken). If it's empty, backups are named
+	// <schedule>-<YYYYMMDDhhmmss>.
+	BackupNameTemplate string `json:"backupNameTemplate,omitempty"`
+
+	// JitterMaxSeconds is the longest that the schedule's backups are
+	// delayed by after they're due. Each backup is delayed by a random
+	// number of seconds, up to this many, so that schedules with the
+	// same Cron expression don't all create their backups at once. It
+	// should be less than the time between the schedule's runs.
+	JitterMaxSeconds int64 `json:"jitterMaxSeconds,omitempty"`
+
+	// BackupOwnerReferences are set as the owner references of the
+	// schedule's backups, so that they're garbage-collected when
+	// their owners are deleted. The owners must be in the schedule's
+	// namespace, or cluster-scoped.
+	BackupOwnerReferences []metav1.OwnerReference `json:"backupOwnerReferences,omitempty"`
 }
 
 // SchedulePhase is a string representation of the lifecycle phase
@@ -21,6 +21,7 @@ limitations under the License.
 package v1
 
 import (
+	core_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -53,6 +54,50 @@ func (in *Backup) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupConsistency) DeepCopyInto(out *BackupConsistency) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make(map[string]ResourceVersionRange, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PotentiallyInconsistentItems != nil {
+		in, out := &in.PotentiallyInconsistentItems, &out.PotentiallyInconsistentItems
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupConsistency.
+func (in *BackupConsistency) DeepCopy() *BackupConsistency {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupConsistency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupHookResult) DeepCopyInto(out *BackupHookResult) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupHookResult.
+func (in *BackupHookResult) DeepCopy() *BackupHookResult {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupHookResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupHooks) DeepCopyInto(out *BackupHooks) {
 	*out = *in
@@ -109,6 +154,102 @@ func (in *BackupList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupNotifyWebhook) DeepCopyInto(out *BackupNotifyWebhook) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(core_v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupNotifyWebhook.
+func (in *BackupNotifyWebhook) DeepCopy() *BackupNotifyWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupNotifyWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupProgress) DeepCopyInto(out *BackupProgress) {
+	*out = *in
+	if in.ResourceCounts != nil {
+		in, out := &in.ResourceCounts, &out.ResourceCounts
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ResourceSizes != nil {
+		in, out := &in.ResourceSizes, &out.ResourceSizes
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupProgress.
+func (in *BackupProgress) DeepCopy() *BackupProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupResolvedIncludesExcludes) DeepCopyInto(out *BackupResolvedIncludesExcludes) {
+	*out = *in
+	if in.IncludedNamespaces != nil {
+		in, out := &in.IncludedNamespaces, &out.IncludedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedNamespaces != nil {
+		in, out := &in.ExcludedNamespaces, &out.ExcludedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IncludedResources != nil {
+		in, out := &in.IncludedResources, &out.IncludedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedResources != nil {
+		in, out := &in.ExcludedResources, &out.ExcludedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupResolvedIncludesExcludes.
+func (in *BackupResolvedIncludesExcludes) DeepCopy() *BackupResolvedIncludesExcludes {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupResolvedIncludesExcludes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupResourceHook) DeepCopyInto(out *BackupResourceHook) {
 	*out = *in
@@ -187,6 +328,20 @@ func (in *BackupResourceHookSpec) DeepCopyInto(out *BackupResourceHookSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PreSnapshotHooks != nil {
+		in, out := &in.PreSnapshotHooks, &out.PreSnapshotHooks
+		*out = make([]BackupResourceHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostSnapshotHooks != nil {
+		in, out := &in.PostSnapshotHooks, &out.PostSnapshotHooks
+		*out = make([]BackupResourceHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -223,6 +378,11 @@ func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.OrderedResources != nil {
+		in, out := &in.OrderedResources, &out.OrderedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.LabelSelector != nil {
 		in, out := &in.LabelSelector, &out.LabelSelector
 		if *in == nil {
@@ -232,6 +392,15 @@ func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.ExcludedLabelSelector != nil {
+		in, out := &in.ExcludedLabelSelector, &out.ExcludedLabelSelector
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.LabelSelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
 	if in.SnapshotVolumes != nil {
 		in, out := &in.SnapshotVolumes, &out.SnapshotVolumes
 		if *in == nil {
@@ -241,7 +410,17 @@ func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
 			**out = **in
 		}
 	}
+	if in.VolumeSnapshotExcludeSelector != nil {
+		in, out := &in.VolumeSnapshotExcludeSelector, &out.VolumeSnapshotExcludeSelector
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(meta_v1.LabelSelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
 	out.TTL = in.TTL
+	out.BackupTimeout = in.BackupTimeout
 	if in.IncludeClusterResources != nil {
 		in, out := &in.IncludeClusterResources, &out.IncludeClusterResources
 		if *in == nil {
@@ -251,7 +430,61 @@ func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
 			**out = **in
 		}
 	}
+	if in.ItemTransforms != nil {
+		in, out := &in.ItemTransforms, &out.ItemTransforms
+		*out = make([]ItemTransform, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnabledPlugins != nil {
+		in, out := &in.EnabledPlugins, &out.EnabledPlugins
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DisabledPlugins != nil {
+		in, out := &in.DisabledPlugins, &out.DisabledPlugins
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Hooks.DeepCopyInto(&out.Hooks)
+	if in.MirrorStorageLocations != nil {
+		in, out := &in.MirrorStorageLocations, &out.MirrorStorageLocations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CredentialSecretRef != nil {
+		in, out := &in.CredentialSecretRef, &out.CredentialSecretRef
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(core_v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.NotifyWebhook != nil {
+		in, out := &in.NotifyWebhook, &out.NotifyWebhook
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(BackupNotifyWebhook)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.DeleteStorageOnRemoval != nil {
+		in, out := &in.DeleteStorageOnRemoval, &out.DeleteStorageOnRemoval
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(bool)
+			**out = **in
+		}
+	}
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -269,6 +502,7 @@ func (in *BackupSpec) DeepCopy() *BackupSpec {
 func (in *BackupStatus) DeepCopyInto(out *BackupStatus) {
 	*out = *in
 	in.Expiration.DeepCopyInto(&out.Expiration)
+	in.ObjectLockExpiration.DeepCopyInto(&out.ObjectLockExpiration)
 	if in.VolumeBackups != nil {
 		in, out := &in.VolumeBackups, &out.VolumeBackups
 		*out = make(map[string]*VolumeBackupInfo, len(*in))
@@ -281,13 +515,110 @@ func (in *BackupStatus) DeepCopyInto(out *BackupStatus) {
 			}
 		}
 	}
+	if in.VolumeBackupMethods != nil {
+		in, out := &in.VolumeBackupMethods, &out.VolumeBackupMethods
+		*out = make(map[string]VolumeBackupMethod, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.ValidationErrors != nil {
 		in, out := &in.ValidationErrors, &out.ValidationErrors
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ValidationWarnings != nil {
+		in, out := &in.ValidationWarnings, &out.ValidationWarnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.StartTimestamp.DeepCopyInto(&out.StartTimestamp)
 	in.CompletionTimestamp.DeepCopyInto(&out.CompletionTimestamp)
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SkippedLargeItems != nil {
+		in, out := &in.SkippedLargeItems, &out.SkippedLargeItems
+		*out = make([]SkippedLargeItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkippedTerminatingNamespaces != nil {
+		in, out := &in.SkippedTerminatingNamespaces, &out.SkippedTerminatingNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkippedUnlistableResources != nil {
+		in, out := &in.SkippedUnlistableResources, &out.SkippedUnlistableResources
+		*out = make([]SkippedUnlistableResource, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeptTempFiles != nil {
+		in, out := &in.KeptTempFiles, &out.KeptTempFiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResolvedIncludesExcludes != nil {
+		in, out := &in.ResolvedIncludesExcludes, &out.ResolvedIncludesExcludes
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(BackupResolvedIncludesExcludes)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.PartialFailures != nil {
+		in, out := &in.PartialFailures, &out.PartialFailures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HookResults != nil {
+		in, out := &in.HookResults, &out.HookResults
+		*out = make([]BackupHookResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.LocationStatuses != nil {
+		in, out := &in.LocationStatuses, &out.LocationStatuses
+		*out = make(map[string]UploadStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(BackupProgress)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.BackupItemActionVersions != nil {
+		in, out := &in.BackupItemActionVersions, &out.BackupItemActionVersions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Consistency != nil {
+		in, out := &in.Consistency, &out.Consistency
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(BackupConsistency)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.GroupVersions != nil {
+		in, out := &in.GroupVersions, &out.GroupVersions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -373,6 +704,25 @@ func (in *BackupStorageLocationSpec) DeepCopyInto(out *BackupStorageLocationSpec
 		}
 	}
 	in.StorageType.DeepCopyInto(&out.StorageType)
+	if in.EncryptionKeySecret != nil {
+		in, out := &in.EncryptionKeySecret, &out.EncryptionKeySecret
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(core_v1.SecretKeySelector)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.ServerSideEncryption != nil {
+		in, out := &in.ServerSideEncryption, &out.ServerSideEncryption
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ServerSideEncryption)
+			**out = **in
+		}
+	}
+	out.ObjectLockRetention = in.ObjectLockRetention
 	return
 }
 
@@ -725,6 +1075,22 @@ func (in *ExecHook) DeepCopy() *ExecHook {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ItemTransform) DeepCopyInto(out *ItemTransform) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ItemTransform.
+func (in *ItemTransform) DeepCopy() *ItemTransform {
+	if in == nil {
+		return nil
+	}
+	out := new(ItemTransform)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ObjectStorageLocation) DeepCopyInto(out *ObjectStorageLocation) {
 	*out = *in
@@ -936,6 +1302,22 @@ func (in *PodVolumeRestoreStatus) DeepCopy() *PodVolumeRestoreStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceVersionRange) DeepCopyInto(out *ResourceVersionRange) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceVersionRange.
+func (in *ResourceVersionRange) DeepCopy() *ResourceVersionRange {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceVersionRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResticRepository) DeepCopyInto(out *ResticRepository) {
 	*out = *in
@@ -1059,6 +1441,22 @@ func (in *Restore) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreItemCount) DeepCopyInto(out *RestoreItemCount) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreItemCount.
+func (in *RestoreItemCount) DeepCopy() *RestoreItemCount {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreItemCount)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RestoreList) DeepCopyInto(out *RestoreList) {
 	*out = *in
@@ -1208,6 +1606,11 @@ func (in *RestoreStatus) DeepCopyInto(out *RestoreStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ItemCounts != nil {
+		in, out := &in.ItemCounts, &out.ItemCounts
+		*out = make([]RestoreItemCount, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -1286,6 +1689,13 @@ func (in *ScheduleList) DeepCopyObject() runtime.Object {
 func (in *ScheduleSpec) DeepCopyInto(out *ScheduleSpec) {
 	*out = *in
 	in.Template.DeepCopyInto(&out.Template)
+	if in.BackupOwnerReferences != nil {
+		in, out := &in.BackupOwnerReferences, &out.BackupOwnerReferences
+		*out = make([]meta_v1.OwnerReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -1321,6 +1731,54 @@ func (in *ScheduleStatus) DeepCopy() *ScheduleStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerSideEncryption) DeepCopyInto(out *ServerSideEncryption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerSideEncryption.
+func (in *ServerSideEncryption) DeepCopy() *ServerSideEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerSideEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkippedLargeItem) DeepCopyInto(out *SkippedLargeItem) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkippedLargeItem.
+func (in *SkippedLargeItem) DeepCopy() *SkippedLargeItem {
+	if in == nil {
+		return nil
+	}
+	out := new(SkippedLargeItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkippedUnlistableResource) DeepCopyInto(out *SkippedUnlistableResource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkippedUnlistableResource.
+func (in *SkippedUnlistableResource) DeepCopy() *SkippedUnlistableResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SkippedUnlistableResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageType) DeepCopyInto(out *StorageType) {
 	*out = *in
@@ -1346,6 +1804,23 @@ func (in *StorageType) DeepCopy() *StorageType {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UploadStatus) DeepCopyInto(out *UploadStatus) {
+	*out = *in
+	out.Duration = in.Duration
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UploadStatus.
+func (in *UploadStatus) DeepCopy() *UploadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UploadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeBackupInfo) DeepCopyInto(out *VolumeBackupInfo) {
 	*out = *in
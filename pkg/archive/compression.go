@@ -0,0 +1,128 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// CompressionAlgorithm is an algorithm that backup tarballs can be
+// compressed with.
+type CompressionAlgorithm string
+
+const (
+	// CompressionGzip compresses tarballs with gzip. It's the default, and
+	// the algorithm that backups without a CompressionAnnotation were
+	// compressed with.
+	CompressionGzip CompressionAlgorithm = "gzip"
+
+	// CompressionZstd compresses tarballs with Zstandard, which is
+	// typically faster than gzip at a similar ratio.
+	CompressionZstd CompressionAlgorithm = "zstd"
+)
+
+// Compression is the algorithm and level that backup tarballs are
+// compressed with.
+type Compression struct {
+	Algorithm CompressionAlgorithm
+
+	// Level is the algorithm-specific compression level: 1-9 for gzip, and
+	// 1-22 for zstd. Zero uses the algorithm's default level.
+	Level int
+}
+
+// NewCompression returns a Compression for the named algorithm and level,
+// or an error if either is invalid.
+func NewCompression(algorithm string, level int) (Compression, error) {
+	c := Compression{Algorithm: CompressionAlgorithm(algorithm), Level: level}
+
+	var maxLevel int
+	switch c.Algorithm {
+	case CompressionGzip:
+		maxLevel = gzip.BestCompression
+	case CompressionZstd:
+		maxLevel = 22
+	default:
+		return Compression{}, errors.Errorf("unsupported compression algorithm %q (valid algorithms are %s, %s)", algorithm, CompressionGzip, CompressionZstd)
+	}
+
+	if level < 0 || level > maxLevel {
+		return Compression{}, errors.Errorf("invalid %s compression level %d (valid levels are 1-%d, or 0 for the default)", algorithm, level, maxLevel)
+	}
+
+	return c, nil
+}
+
+// NewWriter returns a writer that compresses the data written to it and
+// writes the result to w. Closing the returned writer flushes any buffered
+// data to w, but doesn't close w.
+func (c Compression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c.Algorithm {
+	case CompressionGzip, "":
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gzw, err := gzip.NewWriterLevel(w, level)
+		return gzw, errors.WithStack(err)
+	case CompressionZstd:
+		level := zstd.SpeedDefault
+		if c.Level != 0 {
+			level = zstd.EncoderLevelFromZstd(c.Level)
+		}
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+		return zw, errors.WithStack(err)
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm %q", c.Algorithm)
+	}
+}
+
+// NewReader returns a reader that decompresses the data read from r, which
+// was compressed with algorithm.
+func NewReader(r io.Reader, algorithm CompressionAlgorithm) (io.ReadCloser, error) {
+	switch algorithm {
+	case CompressionGzip, "":
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating gzip reader")
+		}
+		return gzr, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating zstd reader")
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm %q", algorithm)
+	}
+}
+
+// CompressionAlgorithmForBackup returns the algorithm the backup's tarball
+// was compressed with, as recorded in its CompressionAnnotation.
+func CompressionAlgorithmForBackup(backup *api.Backup) CompressionAlgorithm {
+	if algorithm := backup.Annotations[api.CompressionAnnotation]; algorithm != "" {
+		return CompressionAlgorithm(algorithm)
+	}
+	return CompressionGzip
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestNewCompression(t *testing.T) {
+	tests := []struct {
+		algorithm   string
+		level       int
+		expectedErr bool
+	}{
+		{algorithm: "gzip", level: 0},
+		{algorithm: "gzip", level: 1},
+		{algorithm: "gzip", level: 9},
+		{algorithm: "gzip", level: 10, expectedErr: true},
+		{algorithm: "gzip", level: -1, expectedErr: true},
+		{algorithm: "zstd", level: 0},
+		{algorithm: "zstd", level: 22},
+		{algorithm: "zstd", level: 23, expectedErr: true},
+		{algorithm: "lz4", level: 0, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-%d", test.algorithm, test.level), func(t *testing.T) {
+			c, err := NewCompression(test.algorithm, test.level)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, Compression{Algorithm: CompressionAlgorithm(test.algorithm), Level: test.level}, c)
+		})
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	for _, c := range []Compression{
+		{Algorithm: CompressionGzip},
+		{Algorithm: CompressionGzip, Level: 1},
+		{Algorithm: CompressionZstd},
+		{Algorithm: CompressionZstd, Level: 19},
+	} {
+		t.Run(fmt.Sprintf("%s-%d", c.Algorithm, c.Level), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w, err := c.NewWriter(buf)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("contents"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			r, err := NewReader(buf, c.Algorithm)
+			require.NoError(t, err)
+			defer r.Close()
+			contents, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "contents", string(contents))
+		})
+	}
+}
+
+func TestCompressionAlgorithmForBackup(t *testing.T) {
+	assert.Equal(t, CompressionGzip, CompressionAlgorithmForBackup(arktest.NewTestBackup().Backup))
+	assert.Equal(t, CompressionZstd, CompressionAlgorithmForBackup(arktest.NewTestBackup().WithAnnotation(api.CompressionAnnotation, "zstd").Backup))
+}
+
+// newRepresentativeTarball returns an uncompressed tarball laid out like a
+// backup's, holding a few thousand pods, configmaps and secrets.
+func newRepresentativeTarball(b *testing.B) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	for i := 0; i < 3000; i++ {
+		ns := fmt.Sprintf("ns-%d", i%20)
+		for _, resource := range []string{"pods", "configmaps", "secrets"} {
+			name := fmt.Sprintf("%s-%d", resource, i)
+			item := fmt.Sprintf(`{"apiVersion":"v1","kind":"%s","metadata":{"name":"%s","namespace":"%s","uid":"%08x-0000-0000-0000-000000000000","labels":{"app":"app-%d","tier":"backend"},"annotations":{"deployment.kubernetes.io/revision":"%d"}},"spec":{"containers":[{"name":"main","image":"example.com/app:v%d","resources":{"requests":{"cpu":"100m","memory":"128Mi"}}}]}}`,
+				resource, name, ns, i, i%50, i%7, i%3)
+
+			if err := tw.WriteHeader(&tar.Header{
+				Name:     fmt.Sprintf("resources/%s/namespaces/%s/%s.json", resource, ns, name),
+				Typeflag: tar.TypeReg,
+				Mode:     0755,
+				Size:     int64(len(item)),
+			}); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(item)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func benchmarkCompression(b *testing.B, c Compression) {
+	tarball := newRepresentativeTarball(b)
+	b.SetBytes(int64(len(tarball)))
+	b.ResetTimer()
+
+	var compressedSize int
+	for i := 0; i < b.N; i++ {
+		buf := new(bytes.Buffer)
+		w, err := c.NewWriter(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(w, bytes.NewReader(tarball)); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+		compressedSize = buf.Len()
+	}
+
+	b.ReportMetric(float64(compressedSize)/float64(len(tarball)), "ratio")
+}
+
+func BenchmarkCompressionGzip1(b *testing.B) {
+	benchmarkCompression(b, Compression{Algorithm: CompressionGzip, Level: 1})
+}
+
+func BenchmarkCompressionGzip9(b *testing.B) {
+	benchmarkCompression(b, Compression{Algorithm: CompressionGzip, Level: 9})
+}
+
+func BenchmarkCompressionZstd(b *testing.B) {
+	benchmarkCompression(b, Compression{Algorithm: CompressionZstd})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ResourceDiff lists the differences between two backups for the items of
+// a single group-resource in a single namespace.
+type ResourceDiff struct {
+	// GroupResource is the group-resource of the items, e.g. "deployments.apps".
+	GroupResource string `json:"groupResource"`
+
+	// Namespace is the namespace of the items. It's empty for cluster-scoped
+	// items.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Added is a sorted list of the names of items that are only in the
+	// newer backup.
+	Added []string `json:"added,omitempty"`
+
+	// Removed is a sorted list of the names of items that are only in the
+	// older backup.
+	Removed []string `json:"removed,omitempty"`
+
+	// Changed is a sorted list of the names of items that are in both
+	// backups but whose contents differ. It's only populated when digests
+	// are compared.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// DiffInventories compares the inventories of an older and a newer backup
+// and returns the differences, sorted by group-resource and namespace.
+// Groups with no differences are omitted.
+//
+// If both oldDigests and newDigests are non-nil, items present in both
+// backups are also compared by content and reported as changed if their
+// digests differ.
+func DiffInventories(oldInventory, newInventory Inventory, oldDigests, newDigests ItemDigests) []ResourceDiff {
+	compareContents := oldDigests != nil && newDigests != nil
+
+	groupResources := sets.NewString(oldInventory.GroupResources()...)
+	groupResources.Insert(newInventory.GroupResources()...)
+
+	var res []ResourceDiff
+	for _, gr := range groupResources.List() {
+		oldItems, newItems := oldInventory[gr], newInventory[gr]
+
+		namespaces := sets.NewString()
+		if oldItems != nil {
+			for ns := range oldItems.Namespaced {
+				namespaces.Insert(ns)
+			}
+		}
+		if newItems != nil {
+			for ns := range newItems.Namespaced {
+				namespaces.Insert(ns)
+			}
+		}
+
+		// cluster-scoped items are keyed by the empty namespace, which
+		// sorts first.
+		for _, ns := range append([]string{""}, namespaces.List()...) {
+			diff := ResourceDiff{GroupResource: gr, Namespace: ns}
+
+			oldNames, newNames := oldItems.names(ns), newItems.names(ns)
+
+			if added := newNames.Difference(oldNames); added.Len() > 0 {
+				diff.Added = added.List()
+			}
+			if removed := oldNames.Difference(newNames); removed.Len() > 0 {
+				diff.Removed = removed.List()
+			}
+
+			if compareContents {
+				for _, name := range oldNames.Intersection(newNames).List() {
+					path := ItemPath(gr, ns, name)
+					if oldDigests[path] != newDigests[path] {
+						diff.Changed = append(diff.Changed, name)
+					}
+				}
+			}
+
+			if len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.Changed) > 0 {
+				res = append(res, diff)
+			}
+		}
+	}
+
+	return res
+}
+
+// names returns the set of names of the items in the given namespace, or of
+// the cluster-scoped items if namespace is empty. It's safe to call on a nil
+// ResourceItems.
+func (r *ResourceItems) names(namespace string) sets.String {
+	if r == nil {
+		return sets.NewString()
+	}
+	if namespace == "" {
+		return sets.NewString(r.ClusterScoped...)
+	}
+	return sets.NewString(r.Namespaced[namespace]...)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTarballWithContents returns a gzipped tarball containing a regular
+// file for each entry in files, keyed by path.
+func newTarballWithContents(t *testing.T, files map[string]string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	for path, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     path,
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+			Size:     int64(len(contents)),
+		}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return buf
+}
+
+func TestReadInventoryWithDigests(t *testing.T) {
+	tarball := newTarballWithContents(t, map[string]string{
+		"metadata/version":                      "1",
+		"resources/pods/namespaces/ns-1/a.json": "{}",
+		"resources/pods/namespaces/ns-1/b.json": "{}",
+		"resources/pods/namespaces/ns-1/c.json": `{"foo":"bar"}`,
+	})
+
+	inventory, digests, err := ReadInventoryWithDigests(tarball, CompressionGzip)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, inventory["pods"].Count())
+	assert.Len(t, digests, 3)
+	assert.Equal(t, digests["resources/pods/namespaces/ns-1/a.json"], digests["resources/pods/namespaces/ns-1/b.json"])
+	assert.NotEqual(t, digests["resources/pods/namespaces/ns-1/a.json"], digests["resources/pods/namespaces/ns-1/c.json"])
+}
+
+func TestItemPath(t *testing.T) {
+	assert.Equal(t, "resources/persistentvolumes/cluster/pv-1.json", ItemPath("persistentvolumes", "", "pv-1"))
+	assert.Equal(t, "resources/deployments.apps/namespaces/ns-1/deploy-1.json", ItemPath("deployments.apps", "ns-1", "deploy-1"))
+}
+
+func TestDiffInventories(t *testing.T) {
+	oldFiles := map[string]string{
+		"resources/persistentvolumes/cluster/pv-1.json":            "{}",
+		"resources/pods/namespaces/ns-1/pod-1.json":                "{}",
+		"resources/pods/namespaces/ns-1/pod-2.json":                "{}",
+		"resources/pods/namespaces/ns-2/pod-1.json":                "{}",
+		"resources/deployments.apps/namespaces/ns-1/deploy-1.json": "{}",
+	}
+	newFiles := map[string]string{
+		"resources/persistentvolumes/cluster/pv-1.json":            "{}",
+		"resources/persistentvolumes/cluster/pv-2.json":            "{}",
+		"resources/pods/namespaces/ns-1/pod-1.json":                `{"changed":true}`,
+		"resources/pods/namespaces/ns-1/pod-3.json":                "{}",
+		"resources/deployments.apps/namespaces/ns-1/deploy-1.json": "{}",
+	}
+
+	oldInventory, oldDigests, err := ReadInventoryWithDigests(newTarballWithContents(t, oldFiles), CompressionGzip)
+	require.NoError(t, err)
+	newInventory, newDigests, err := ReadInventoryWithDigests(newTarballWithContents(t, newFiles), CompressionGzip)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		oldDigests ItemDigests
+		newDigests ItemDigests
+		expected   []ResourceDiff
+	}{
+		{
+			name: "inventories only",
+			expected: []ResourceDiff{
+				{GroupResource: "persistentvolumes", Added: []string{"pv-2"}},
+				{GroupResource: "pods", Namespace: "ns-1", Added: []string{"pod-3"}, Removed: []string{"pod-2"}},
+				{GroupResource: "pods", Namespace: "ns-2", Removed: []string{"pod-1"}},
+			},
+		},
+		{
+			name:       "with contents",
+			oldDigests: oldDigests,
+			newDigests: newDigests,
+			expected: []ResourceDiff{
+				{GroupResource: "persistentvolumes", Added: []string{"pv-2"}},
+				{GroupResource: "pods", Namespace: "ns-1", Added: []string{"pod-3"}, Removed: []string{"pod-2"}, Changed: []string{"pod-1"}},
+				{GroupResource: "pods", Namespace: "ns-2", Removed: []string{"pod-1"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, DiffInventories(oldInventory, newInventory, test.oldDigests, test.newDigests))
+		})
+	}
+}
+
+func TestDiffInventoriesIdentical(t *testing.T) {
+	inventory := Inventory{
+		"pods": &ResourceItems{Namespaced: map[string][]string{"ns-1": {"pod-1"}}},
+	}
+
+	assert.Empty(t, DiffInventories(inventory, inventory, nil, nil))
+}
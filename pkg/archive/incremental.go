@@ -0,0 +1,198 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// UnchangedItemsPath is the path within an incremental backup's tarball of
+// the newline-delimited list of the items that were left out of it because
+// they hadn't changed since its base backup. They're restored from the
+// base backup's tarball.
+const UnchangedItemsPath = "metadata/unchanged-items"
+
+// ResourceVersions maps the tarball paths of the resource items in a backup
+// to their resourceVersions.
+type ResourceVersions map[string]string
+
+// ReadResourceVersions reads a backup tarball compressed with algorithm from
+// r and returns the resourceVersion of each item it contains.
+func ReadResourceVersions(r io.Reader, algorithm CompressionAlgorithm) (ResourceVersions, error) {
+	zr, err := NewReader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	versions := make(ResourceVersions)
+	tr := tar.NewReader(zr)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading tar header")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, _, _, ok := parseItemPath(header.Name); !ok {
+			continue
+		}
+
+		resourceVersion, err := ItemResourceVersion(tr)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error decoding "+header.Name)
+		}
+		versions[header.Name] = resourceVersion
+	}
+
+	return versions, nil
+}
+
+// ItemResourceVersion decodes a resource item's JSON from r and returns its
+// resourceVersion.
+func ItemResourceVersion(r io.Reader) (string, error) {
+	var obj struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return obj.Metadata.ResourceVersion, nil
+}
+
+// Unchanged returns whether the item at the given tarball path had the
+// given resourceVersion in the base backup that v was read from. Items
+// without a resourceVersion are always treated as changed.
+func (v ResourceVersions) Unchanged(path, resourceVersion string) bool {
+	if resourceVersion == "" {
+		return false
+	}
+
+	baseVersion, ok := v[path]
+	return ok && baseVersion == resourceVersion
+}
+
+// LayerIncremental reconstructs the full tarball of an incremental backup
+// by layering its tarball, read from incremental, over its base backup's,
+// read from base, and writes the result to w, compressed with algorithm.
+// Everything in the incremental tarball is written, along with the items
+// from the base tarball that it lists as unchanged. Items that were in the
+// base backup but not in the incremental one were deleted in between, so
+// they're left out.
+func LayerIncremental(w io.Writer, algorithm CompressionAlgorithm, incremental io.Reader, incrementalAlgorithm CompressionAlgorithm, base io.Reader, baseAlgorithm CompressionAlgorithm) error {
+	cw, err := Compression{Algorithm: algorithm}.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(cw)
+
+	var unchanged sets.String
+	err = copyTarball(tw, incremental, incrementalAlgorithm, func(header *tar.Header, r io.Reader) (bool, error) {
+		if header.Name != UnchangedItemsPath {
+			return true, nil
+		}
+
+		var err error
+		unchanged, err = readUnchangedItems(r)
+		return false, err
+	})
+	if err != nil {
+		return errors.WithMessage(err, "error reading incremental backup")
+	}
+
+	if unchanged.Len() > 0 {
+		err = copyTarball(tw, base, baseAlgorithm, func(header *tar.Header, r io.Reader) (bool, error) {
+			return unchanged.Has(header.Name), nil
+		})
+		if err != nil {
+			return errors.WithMessage(err, "error reading base backup")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "error closing tar writer")
+	}
+	return errors.Wrap(cw.Close(), "error closing compressed tarball")
+}
+
+// copyTarball copies the entries of the tarball compressed with algorithm
+// read from r to tw, if include returns true for them. include is passed
+// each entry's header and contents, and may read the contents of the
+// entries it doesn't include.
+func copyTarball(tw *tar.Writer, r io.Reader, algorithm CompressionAlgorithm, include func(*tar.Header, io.Reader) (bool, error)) error {
+	zr, err := NewReader(r, algorithm)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading tar header")
+		}
+
+		ok, err := include(header, tr)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "error writing tar header for %s", header.Name)
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return errors.Wrapf(err, "error copying %s", header.Name)
+		}
+	}
+}
+
+func readUnchangedItems(r io.Reader) (sets.String, error) {
+	unchanged := sets.NewString()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			unchanged.Insert(line)
+		}
+	}
+
+	return unchanged, errors.Wrap(scanner.Err(), "error reading unchanged items")
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadResourceVersions(t *testing.T) {
+	tarball := newTarballWithContents(t, map[string]string{
+		"metadata/version":                               "1",
+		"resources/pods/namespaces/ns-1/pod-1.json":      `{"metadata":{"name":"pod-1","resourceVersion":"10"}}`,
+		"resources/persistentvolumes/cluster/pv-1.json":  `{"metadata":{"name":"pv-1","resourceVersion":"20"}}`,
+		"resources/configmaps/namespaces/ns-1/cm-1.json": `{"metadata":{"name":"cm-1"}}`,
+	})
+
+	versions, err := ReadResourceVersions(tarball, CompressionGzip)
+	require.NoError(t, err)
+
+	assert.Equal(t, ResourceVersions{
+		"resources/pods/namespaces/ns-1/pod-1.json":      "10",
+		"resources/persistentvolumes/cluster/pv-1.json":  "20",
+		"resources/configmaps/namespaces/ns-1/cm-1.json": "",
+	}, versions)
+}
+
+func TestReadResourceVersionsInvalidItem(t *testing.T) {
+	tarball := newTarballWithContents(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-1.json": `not json`,
+	})
+
+	_, err := ReadResourceVersions(tarball, CompressionGzip)
+	assert.Error(t, err)
+}
+
+func TestResourceVersionsUnchanged(t *testing.T) {
+	base := ResourceVersions{
+		"resources/pods/namespaces/ns-1/pod-1.json":      "10",
+		"resources/configmaps/namespaces/ns-1/cm-1.json": "",
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		resourceVersion string
+		expected        bool
+	}{
+		{
+			name:            "same resourceVersion is unchanged",
+			path:            "resources/pods/namespaces/ns-1/pod-1.json",
+			resourceVersion: "10",
+			expected:        true,
+		},
+		{
+			name:            "different resourceVersion is changed",
+			path:            "resources/pods/namespaces/ns-1/pod-1.json",
+			resourceVersion: "11",
+			expected:        false,
+		},
+		{
+			name:            "item that wasn't in the base backup is changed",
+			path:            "resources/pods/namespaces/ns-1/pod-2.json",
+			resourceVersion: "10",
+			expected:        false,
+		},
+		{
+			name:     "item without a resourceVersion is always changed",
+			path:     "resources/configmaps/namespaces/ns-1/cm-1.json",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, base.Unchanged(test.path, test.resourceVersion))
+		})
+	}
+}
+
+func TestLayerIncremental(t *testing.T) {
+	base := newTarballWithContents(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-1.json": "base pod-1",
+		"resources/pods/namespaces/ns-1/pod-2.json": "base pod-2",
+		"resources/pods/namespaces/ns-1/pod-3.json": "base pod-3",
+	})
+
+	// pod-1 is unchanged, pod-2 changed, pod-3 was deleted, and pod-4 was
+	// created since the base backup.
+	incremental := newTarballWithContents(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-2.json": "incremental pod-2",
+		"resources/pods/namespaces/ns-1/pod-4.json": "incremental pod-4",
+		UnchangedItemsPath:                          "resources/pods/namespaces/ns-1/pod-1.json\n",
+	})
+
+	var layered bytes.Buffer
+	require.NoError(t, LayerIncremental(&layered, CompressionZstd, incremental, CompressionGzip, base, CompressionGzip))
+
+	zr, err := NewReader(&layered, CompressionZstd)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	files := make(map[string]string)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(contents)
+	}
+
+	assert.Equal(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-1.json": "base pod-1",
+		"resources/pods/namespaces/ns-1/pod-2.json": "incremental pod-2",
+		"resources/pods/namespaces/ns-1/pod-4.json": "incremental pod-4",
+	}, files)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ContentIndexVersion is the version of the content index format written
+// by WriteContentIndex.
+const ContentIndexVersion = 1
+
+// WriteContentIndex reads a backup tarball compressed with algorithm from r
+// and writes an index of the items it contains to w. The first line
+// identifies the format version; each following line is one item,
+// formatted as
+//
+//	<group>/<version>/<resource>/<namespace>/<name>
+//
+// where the group is empty for the core API group and the namespace is
+// empty for cluster-scoped items.
+func WriteContentIndex(r io.Reader, algorithm CompressionAlgorithm, w io.Writer) error {
+	zr, err := NewReader(r, algorithm)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# ark-content-index v%d\n", ContentIndexVersion)
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading tar header")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		groupResource, namespace, name, ok := parseItemPath(header.Name)
+		if !ok {
+			continue
+		}
+
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+		}
+		if err := json.NewDecoder(tr).Decode(&obj); err != nil {
+			return errors.Wrapf(err, "error decoding %s", header.Name)
+		}
+		gv, err := schema.ParseGroupVersion(obj.APIVersion)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing apiVersion of %s", header.Name)
+		}
+		gr := schema.ParseGroupResource(groupResource)
+
+		fmt.Fprintf(bw, "%s/%s/%s/%s/%s\n", gr.Group, gv.Version, gr.Resource, namespace, name)
+	}
+
+	return errors.WithStack(bw.Flush())
+}
+
+// ReadContentIndex reads a content index written by WriteContentIndex from
+// r, and returns an Inventory of the items it lists, so that a backup's
+// contents can be listed without reading its tarball.
+func ReadContentIndex(r io.Reader) (Inventory, error) {
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "error reading content index")
+		}
+		return nil, errors.New("content index is empty")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(scanner.Text(), "# ark-content-index v%d", &version); err != nil {
+		return nil, errors.Wrap(err, "error reading content index header")
+	}
+	if version < 1 || version > ContentIndexVersion {
+		return nil, errors.Errorf("content index has version %d, but only versions up to %d are understood", version, ContentIndexVersion)
+	}
+
+	inventory := make(Inventory)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "/")
+		if len(parts) != 5 {
+			return nil, errors.Errorf("content index has a malformed line: %q", scanner.Text())
+		}
+
+		gr := schema.GroupResource{Group: parts[0], Resource: parts[2]}
+		inventory.addItem(gr.String(), parts[3], parts[4])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading content index")
+	}
+	inventory.sort()
+
+	return inventory, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteContentIndex(t *testing.T) {
+	files := []struct {
+		path, contents string
+	}{
+		{"metadata/version", "1"},
+		{"resources/pods/namespaces/ns-1/pod-1.json", `{"apiVersion":"v1","kind":"Pod"}`},
+		{"resources/persistentvolumes/cluster/pv-1.json", `{"apiVersion":"v1","kind":"PersistentVolume"}`},
+		{"resources/deployments.apps/namespaces/ns-1/deploy-1.json", `{"apiVersion":"apps/v1beta1","kind":"Deployment"}`},
+	}
+
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     file.path,
+			Size:     int64(len(file.contents)),
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+		}))
+		_, err := tw.Write([]byte(file.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	index := new(bytes.Buffer)
+	require.NoError(t, WriteContentIndex(buf, CompressionGzip, index))
+
+	expected := "# ark-content-index v1\n" +
+		"/v1/pods/ns-1/pod-1\n" +
+		"/v1/persistentvolumes//pv-1\n" +
+		"apps/v1beta1/deployments/ns-1/deploy-1\n"
+	assert.Equal(t, expected, index.String())
+}
+
+func TestReadContentIndex(t *testing.T) {
+	index := "# ark-content-index v1\n" +
+		"/v1/pods/ns-1/pod-2\n" +
+		"/v1/pods/ns-1/pod-1\n" +
+		"/v1/persistentvolumes//pv-1\n" +
+		"apps/v1beta1/deployments/ns-1/deploy-1\n"
+
+	inventory, err := ReadContentIndex(strings.NewReader(index))
+	require.NoError(t, err)
+
+	expected := Inventory{
+		"pods": {
+			Namespaced: map[string][]string{"ns-1": {"pod-1", "pod-2"}},
+		},
+		"persistentvolumes": {
+			ClusterScoped: []string{"pv-1"},
+			Namespaced:    map[string][]string{},
+		},
+		"deployments.apps": {
+			Namespaced: map[string][]string{"ns-1": {"deploy-1"}},
+		},
+	}
+	assert.Equal(t, expected, inventory)
+}
+
+func TestReadContentIndexErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		index       string
+		expectedErr string
+	}{
+		{
+			name:        "empty",
+			expectedErr: "content index is empty",
+		},
+		{
+			name:        "missing header",
+			index:       "/v1/pods/ns-1/pod-1\n",
+			expectedErr: "error reading content index header",
+		},
+		{
+			name:        "newer version",
+			index:       "# ark-content-index v2\n",
+			expectedErr: "content index has version 2, but only versions up to 1 are understood",
+		},
+		{
+			name:        "malformed line",
+			index:       "# ark-content-index v1\n/v1/pods/pod-1\n",
+			expectedErr: `content index has a malformed line: "/v1/pods/pod-1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadContentIndex(strings.NewReader(test.index))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// Inventory is a listing of the items contained in a backup tarball, keyed
+// by group-resource (e.g. "deployments.apps").
+type Inventory map[string]*ResourceItems
+
+// ResourceItems lists the items of a single group-resource contained in a
+// backup tarball.
+type ResourceItems struct {
+	// ClusterScoped is a sorted list of the names of cluster-scoped items.
+	ClusterScoped []string
+
+	// Namespaced is a map of namespace name to a sorted list of the names of
+	// the items in that namespace.
+	Namespaced map[string][]string
+}
+
+// ItemDigests maps the tarball paths of the resource items in a backup to
+// the hex-encoded SHA-256 digests of their contents.
+type ItemDigests map[string]string
+
+// ReadInventory reads a backup tarball compressed with algorithm from r and
+// returns an Inventory of the items it contains. Only tar headers are
+// inspected; item contents are skipped over without being decoded.
+func ReadInventory(r io.Reader, algorithm CompressionAlgorithm) (Inventory, error) {
+	inventory, _, err := readInventory(r, algorithm, false)
+	return inventory, err
+}
+
+// ReadInventoryWithDigests reads a backup tarball compressed with algorithm
+// from r and returns an Inventory of the items it contains, along with the
+// digests of their contents. Items are hashed as they're streamed, so only
+// the digests are held in memory.
+func ReadInventoryWithDigests(r io.Reader, algorithm CompressionAlgorithm) (Inventory, ItemDigests, error) {
+	return readInventory(r, algorithm, true)
+}
+
+func readInventory(r io.Reader, algorithm CompressionAlgorithm, withDigests bool) (Inventory, ItemDigests, error) {
+	zr, err := NewReader(r, algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+
+	inventory := make(Inventory)
+	var digests ItemDigests
+	if withDigests {
+		digests = make(ItemDigests)
+	}
+	tr := tar.NewReader(zr)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error reading tar header")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if !inventory.add(header.Name) || !withDigests {
+			continue
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, tr); err != nil {
+			return nil, nil, errors.Wrapf(err, "error reading %s", header.Name)
+		}
+		digests[header.Name] = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	inventory.sort()
+
+	return inventory, digests, nil
+}
+
+// add records the item stored at the given tarball path, if it's
+// a resource item, and returns whether it was.
+func (i Inventory) add(path string) bool {
+	groupResource, namespace, name, ok := parseItemPath(path)
+	if !ok {
+		return false
+	}
+
+	i.addItem(groupResource, namespace, name)
+	return true
+}
+
+// addItem records an item of the given group-resource, in the given
+// namespace ("" for cluster-scoped items).
+func (i Inventory) addItem(groupResource, namespace, name string) {
+	items := i[groupResource]
+	if items == nil {
+		items = &ResourceItems{Namespaced: make(map[string][]string)}
+		i[groupResource] = items
+	}
+
+	if namespace == "" {
+		items.ClusterScoped = append(items.ClusterScoped, name)
+	} else {
+		items.Namespaced[namespace] = append(items.Namespaced[namespace], name)
+	}
+}
+
+// sort sorts the names of the items of each group-resource.
+func (i Inventory) sort() {
+	for _, items := range i {
+		sort.Strings(items.ClusterScoped)
+		for _, names := range items.Namespaced {
+			sort.Strings(names)
+		}
+	}
+}
+
+// parseItemPath returns the group-resource, namespace and name of the
+// resource item stored at the given tarball path, and whether the path
+// is a resource item's. Paths have one of the following forms:
+//
+//	resources/<group-resource>/cluster/<name>.json
+//	resources/<group-resource>/namespaces/<namespace>/<name>.json
+func parseItemPath(path string) (groupResource, namespace, name string, ok bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 4 || parts[0] != api.ResourcesDir || !strings.HasSuffix(path, ".json") {
+		return "", "", "", false
+	}
+
+	switch {
+	case len(parts) == 4 && parts[2] == api.ClusterScopedDir:
+		name = parts[3]
+	case len(parts) == 5 && parts[2] == api.NamespaceScopedDir:
+		namespace, name = parts[3], parts[4]
+	default:
+		return "", "", "", false
+	}
+
+	return parts[1], namespace, strings.TrimSuffix(name, ".json"), true
+}
+
+// GroupResources returns a sorted list of the group-resources contained in
+// the inventory.
+func (i Inventory) GroupResources() []string {
+	res := make([]string, 0, len(i))
+	for gr := range i {
+		res = append(res, gr)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// Count returns the total number of items of this group-resource.
+func (r *ResourceItems) Count() int {
+	count := len(r.ClusterScoped)
+	for _, names := range r.Namespaced {
+		count += len(names)
+	}
+
+	return count
+}
+
+// ItemPath returns the path within a backup tarball of the item with the
+// given group-resource, namespace and name. Cluster-scoped items have an
+// empty namespace.
+func ItemPath(groupResource, namespace, name string) string {
+	if namespace == "" {
+		return path.Join(api.ResourcesDir, groupResource, api.ClusterScopedDir, name+".json")
+	}
+	return path.Join(api.ResourcesDir, groupResource, api.NamespaceScopedDir, namespace, name+".json")
+}
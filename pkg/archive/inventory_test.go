@@ -0,0 +1,92 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTarball returns a gzipped tarball containing an empty regular
+// file at each of the provided paths.
+func newTarball(t *testing.T, paths ...string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	for _, path := range paths {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     path,
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+		}))
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return buf
+}
+
+func TestReadInventory(t *testing.T) {
+	tarball := newTarball(t,
+		"metadata/version",
+		"resources/pods/namespaces/ns-2/pod-1.json",
+		"resources/pods/namespaces/ns-1/pod-2.json",
+		"resources/pods/namespaces/ns-1/pod-1.json",
+		"resources/persistentvolumes/cluster/pv-1.json",
+		"resources/deployments.apps/namespaces/ns-1/deploy-1.json",
+		"resources/deployments.apps/namespaces/ns-1/not-an-item.txt",
+		"resources/deployments.apps/unknown/ns-1/deploy-2.json",
+	)
+
+	inventory, err := ReadInventory(tarball, CompressionGzip)
+	require.NoError(t, err)
+
+	expected := Inventory{
+		"pods": &ResourceItems{
+			Namespaced: map[string][]string{
+				"ns-1": {"pod-1", "pod-2"},
+				"ns-2": {"pod-1"},
+			},
+		},
+		"persistentvolumes": &ResourceItems{
+			ClusterScoped: []string{"pv-1"},
+			Namespaced:    map[string][]string{},
+		},
+		"deployments.apps": &ResourceItems{
+			Namespaced: map[string][]string{
+				"ns-1": {"deploy-1"},
+			},
+		},
+	}
+
+	assert.Equal(t, expected, inventory)
+	assert.Equal(t, []string{"deployments.apps", "persistentvolumes", "pods"}, inventory.GroupResources())
+	assert.Equal(t, 3, inventory["pods"].Count())
+	assert.Equal(t, 1, inventory["persistentvolumes"].Count())
+}
+
+func TestReadInventoryInvalidGzip(t *testing.T) {
+	_, err := ReadInventory(bytes.NewBufferString("not a gzip stream"), CompressionGzip)
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ManifestVersion is the version of the manifest format written by
+// ManifestWriter.
+const ManifestVersion = 1
+
+// ManifestEntry describes one file in a backup tarball.
+type ManifestEntry struct {
+	// Path is the file's path in the tarball.
+	Path string `json:"path"`
+
+	// Resource, Namespace and Name identify the item the file holds. They're
+	// empty for files that aren't items, and Namespace is empty for
+	// cluster-scoped items.
+	Resource  string `json:"resource,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+
+	// Offset is where the file's contents start in the uncompressed
+	// tarball, and Size is their length, both in bytes.
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+// NewManifestEntry returns the ManifestEntry for the file at path in a
+// backup tarball, whose contents are size bytes long and start at offset.
+func NewManifestEntry(path string, offset, size int64) ManifestEntry {
+	entry := ManifestEntry{
+		Path:   path,
+		Offset: offset,
+		Size:   size,
+	}
+	if resource, namespace, name, ok := parseItemPath(path); ok {
+		entry.Resource, entry.Namespace, entry.Name = resource, namespace, name
+	}
+	return entry
+}
+
+type manifestHeader struct {
+	ManifestVersion int `json:"manifestVersion"`
+}
+
+// ManifestWriter writes the manifest of a backup tarball as the tarball is
+// written, one entry at a time, so that a backup that fails partway through
+// still has a manifest of what it captured. The first line of a manifest
+// identifies the format version; each following line is one
+// JSON-encoded ManifestEntry, in the order of the files in the tarball.
+type ManifestWriter struct {
+	encoder *json.Encoder
+}
+
+// NewManifestWriter writes the header of a manifest to w, and returns a
+// ManifestWriter that writes its entries to w.
+func NewManifestWriter(w io.Writer) (*ManifestWriter, error) {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(manifestHeader{ManifestVersion: ManifestVersion}); err != nil {
+		return nil, errors.Wrap(err, "error writing manifest header")
+	}
+
+	return &ManifestWriter{encoder: encoder}, nil
+}
+
+// Add writes entry to the manifest.
+func (w *ManifestWriter) Add(entry ManifestEntry) error {
+	return errors.Wrapf(w.encoder.Encode(entry), "error writing manifest entry for %s", entry.Path)
+}
+
+// ReadManifest reads a manifest written by ManifestWriter from r, and
+// returns its entries.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "error reading manifest")
+		}
+		return nil, errors.New("manifest is empty")
+	}
+
+	var header manifestHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, errors.Wrap(err, "error decoding manifest header")
+	}
+	if header.ManifestVersion < 1 || header.ManifestVersion > ManifestVersion {
+		return nil, errors.Errorf("manifest has version %d, but only versions up to %d are understood", header.ManifestVersion, ManifestVersion)
+	}
+
+	var entries []ManifestEntry
+	for scanner.Scan() {
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "error decoding manifest entry %d", len(entries)+1)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading manifest")
+	}
+
+	return entries, nil
+}
+
+// InventoryFromManifest returns an Inventory of the items listed in a
+// backup tarball's manifest, so that its contents can be listed without
+// reading the tarball.
+func InventoryFromManifest(entries []ManifestEntry) Inventory {
+	inventory := make(Inventory)
+	for _, entry := range entries {
+		if entry.Resource == "" {
+			continue
+		}
+		inventory.addItem(entry.Resource, entry.Namespace, entry.Name)
+	}
+	inventory.sort()
+
+	return inventory
+}
+
+// VerifyManifest reads a backup tarball compressed with algorithm from r,
+// and returns an error describing the first difference between the files it
+// contains and the manifest's entries: a file that's missing, isn't listed,
+// or isn't at the offset or of the size that's listed.
+func VerifyManifest(r io.Reader, algorithm CompressionAlgorithm, entries []ManifestEntry) error {
+	zr, err := NewReader(r, algorithm)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	// the tar reader doesn't read ahead of the current file's contents, so
+	// the bytes read from under it are where they start.
+	counter := &countingReader{r: zr}
+	tr := tar.NewReader(counter)
+
+	var i int
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading tar header")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if i == len(entries) {
+			return errors.Errorf("tarball contains %s, which isn't in the manifest", header.Name)
+		}
+
+		entry := entries[i]
+		if header.Name != entry.Path || counter.n != entry.Offset || header.Size != entry.Size {
+			return errors.Errorf("tarball contains %s at offset %d with size %d where the manifest lists %s at offset %d with size %d",
+				header.Name, counter.n, header.Size, entry.Path, entry.Offset, entry.Size)
+		}
+		i++
+	}
+
+	if i < len(entries) {
+		return errors.Errorf("manifest lists %s, which isn't in the tarball", entries[i].Path)
+	}
+
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
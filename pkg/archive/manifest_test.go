@@ -0,0 +1,223 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTarballWithManifest returns a gzipped tarball of the files, in order,
+// and the entries of its manifest.
+func newTarballWithManifest(t *testing.T, files [][2]string) (*bytes.Buffer, []ManifestEntry) {
+	var (
+		buf     = new(bytes.Buffer)
+		gzw     = gzip.NewWriter(buf)
+		counter = &countingWriter{w: gzw}
+		tw      = tar.NewWriter(counter)
+		entries []ManifestEntry
+	)
+
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     file[0],
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+			Size:     int64(len(file[1])),
+		}))
+		entries = append(entries, NewManifestEntry(file[0], counter.n, int64(len(file[1]))))
+
+		_, err := tw.Write([]byte(file[1]))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return buf, entries
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func TestNewManifestEntry(t *testing.T) {
+	assert.Equal(t, ManifestEntry{
+		Path:      "resources/pods/namespaces/ns-1/pod-1.json",
+		Resource:  "pods",
+		Namespace: "ns-1",
+		Name:      "pod-1",
+		Offset:    512,
+		Size:      10,
+	}, NewManifestEntry("resources/pods/namespaces/ns-1/pod-1.json", 512, 10))
+
+	assert.Equal(t, ManifestEntry{
+		Path:     "resources/persistentvolumes/cluster/pv-1.json",
+		Resource: "persistentvolumes",
+		Name:     "pv-1",
+		Offset:   1024,
+		Size:     20,
+	}, NewManifestEntry("resources/persistentvolumes/cluster/pv-1.json", 1024, 20))
+
+	assert.Equal(t, ManifestEntry{
+		Path:   "metadata/version",
+		Offset: 512,
+		Size:   1,
+	}, NewManifestEntry("metadata/version", 512, 1))
+}
+
+func TestReadManifest(t *testing.T) {
+	entries := []ManifestEntry{
+		NewManifestEntry("metadata/version", 512, 1),
+		NewManifestEntry("resources/pods/namespaces/ns-1/pod-1.json", 1536, 10),
+	}
+
+	buf := new(bytes.Buffer)
+	w, err := NewManifestWriter(buf)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.NoError(t, w.Add(entry))
+	}
+
+	read, err := ReadManifest(buf)
+	require.NoError(t, err)
+	assert.Equal(t, entries, read)
+}
+
+func TestInventoryFromManifest(t *testing.T) {
+	entries := []ManifestEntry{
+		NewManifestEntry("metadata/version", 512, 1),
+		NewManifestEntry("resources/pods/namespaces/ns-1/pod-2.json", 1536, 10),
+		NewManifestEntry("resources/pods/namespaces/ns-1/pod-1.json", 2560, 10),
+		NewManifestEntry("resources/persistentvolumes/cluster/pv-1.json", 3584, 10),
+	}
+
+	expected := Inventory{
+		"pods": {
+			Namespaced: map[string][]string{"ns-1": {"pod-1", "pod-2"}},
+		},
+		"persistentvolumes": {
+			ClusterScoped: []string{"pv-1"},
+			Namespaced:    map[string][]string{},
+		},
+	}
+	assert.Equal(t, expected, InventoryFromManifest(entries))
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{
+			name: "empty manifest",
+		},
+		{
+			name:     "newer version",
+			manifest: `{"manifestVersion":2}` + "\n",
+		},
+		{
+			name:     "invalid entry",
+			manifest: `{"manifestVersion":1}` + "\nnot json\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadManifest(strings.NewReader(test.manifest))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	files := [][2]string{
+		{"metadata/version", "1"},
+		// long enough to need a PAX header, which moves the contents.
+		{"resources/pods/namespaces/ns-1/" + strings.Repeat("a", 100) + ".json", `{"metadata":{"name":"long"}}`},
+		{"resources/persistentvolumes/cluster/pv-1.json", `{"metadata":{"name":"pv-1"}}`},
+	}
+
+	tests := []struct {
+		name        string
+		mutate      func([]ManifestEntry) []ManifestEntry
+		expectedErr string
+	}{
+		{
+			name:   "matching manifest",
+			mutate: func(entries []ManifestEntry) []ManifestEntry { return entries },
+		},
+		{
+			name: "wrong size",
+			mutate: func(entries []ManifestEntry) []ManifestEntry {
+				entries[2].Size++
+				return entries
+			},
+			expectedErr: "tarball contains resources/persistentvolumes/cluster/pv-1.json at offset",
+		},
+		{
+			name: "wrong offset",
+			mutate: func(entries []ManifestEntry) []ManifestEntry {
+				entries[1].Offset += 512
+				return entries
+			},
+			expectedErr: "tarball contains resources/pods/namespaces/ns-1/",
+		},
+		{
+			name: "file missing from the manifest",
+			mutate: func(entries []ManifestEntry) []ManifestEntry {
+				return entries[:2]
+			},
+			expectedErr: "tarball contains resources/persistentvolumes/cluster/pv-1.json, which isn't in the manifest",
+		},
+		{
+			name: "file missing from the tarball",
+			mutate: func(entries []ManifestEntry) []ManifestEntry {
+				return append(entries, NewManifestEntry("resources/pods/namespaces/ns-1/pod-2.json", 4096, 10))
+			},
+			expectedErr: "manifest lists resources/pods/namespaces/ns-1/pod-2.json, which isn't in the tarball",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tarball, entries := newTarballWithManifest(t, files)
+
+			err := VerifyManifest(tarball, CompressionGzip, test.mutate(entries))
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
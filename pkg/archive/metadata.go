@@ -0,0 +1,60 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// BackupMetadataPath is the path within a backup's tarball of a copy of
+// the backup's API object, as it was once all of its items had been
+// written. It's what the backup's metadata is rebuilt from if it's lost.
+const BackupMetadataPath = "metadata/backup.json"
+
+// ReadBackupMetadata reads a backup tarball compressed with algorithm from
+// r and returns the copy of the backup's API object at BackupMetadataPath,
+// or nil if the tarball doesn't have one, since tarballs written before it
+// was added don't.
+func ReadBackupMetadata(r io.Reader, algorithm CompressionAlgorithm) ([]byte, error) {
+	zr, err := NewReader(r, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading tar header")
+		}
+
+		if header.Name != BackupMetadataPath {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		return data, errors.Wrapf(err, "error reading %s", BackupMetadataPath)
+	}
+}
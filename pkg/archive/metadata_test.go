@@ -0,0 +1,74 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBackupMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []byte
+	}{
+		{
+			name: "tarball with metadata",
+			files: map[string]string{
+				"resources/pods/namespaces/ns-1/pod-1.json": `{"apiVersion":"v1","kind":"Pod"}`,
+				BackupMetadataPath:                          `{"kind":"Backup"}`,
+			},
+			expected: []byte(`{"kind":"Backup"}`),
+		},
+		{
+			name: "tarball without metadata",
+			files: map[string]string{
+				"resources/pods/namespaces/ns-1/pod-1.json": `{"apiVersion":"v1","kind":"Pod"}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			gzw := gzip.NewWriter(buf)
+			tw := tar.NewWriter(gzw)
+			for path, contents := range test.files {
+				require.NoError(t, tw.WriteHeader(&tar.Header{
+					Name:     path,
+					Size:     int64(len(contents)),
+					Typeflag: tar.TypeReg,
+					Mode:     0755,
+				}))
+				_, err := tw.Write([]byte(contents))
+				require.NoError(t, err)
+			}
+			require.NoError(t, tw.Close())
+			require.NoError(t, gzw.Close())
+
+			metadata, err := ReadBackupMetadata(buf, CompressionGzip)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, metadata)
+		})
+	}
+}
@@ -18,8 +18,8 @@ package backup
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"time"
@@ -27,15 +27,20 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	corev1api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	kuberrs "k8s.io/apimachinery/pkg/util/errors"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/discovery"
+	"github.com/heptio/ark/pkg/kuberesource"
 	"github.com/heptio/ark/pkg/podexec"
 	"github.com/heptio/ark/pkg/restic"
 	"github.com/heptio/ark/pkg/util/collections"
@@ -45,8 +50,13 @@ import (
 // Backupper performs backups.
 type Backupper interface {
 	// Backup takes a backup using the specification in the api.Backup and writes backup and log data
-	// to the given writers.
-	Backup(logger logrus.FieldLogger, backup *api.Backup, backupFile io.Writer, actions []ItemAction) error
+	// to the given writers. It returns the warnings that came up during the backup, such as for
+	// namespaces or items that were skipped, separately from its errors, since warnings don't fail
+	// the backup. For an incremental backup, baseVersions are the resourceVersions of the items in
+	// its base backup, and the items that haven't changed since are left out of the tarball. It's
+	// nil for full backups. If manifest isn't nil, an archive.ManifestWriter manifest of the
+	// tarball's files is written to it as they're written.
+	Backup(logger logrus.FieldLogger, backup *api.Backup, backupFile io.Writer, actions []ItemAction, baseVersions archive.ResourceVersions, manifest io.Writer) (warnings []string, err error)
 }
 
 // kubernetesBackupper implements Backupper.
@@ -58,6 +68,7 @@ type kubernetesBackupper struct {
 	blockStore             cloudprovider.BlockStore
 	resticBackupperFactory restic.BackupperFactory
 	resticTimeout          time.Duration
+	snapshotConcurrency    int
 }
 
 type itemKey struct {
@@ -96,7 +107,12 @@ func NewKubernetesBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupperFactory restic.BackupperFactory,
 	resticTimeout time.Duration,
+	snapshotConcurrency int,
 ) (Backupper, error) {
+	if snapshotConcurrency < 1 {
+		return nil, errors.Errorf("snapshot concurrency must be at least 1, not %d", snapshotConcurrency)
+	}
+
 	return &kubernetesBackupper{
 		discoveryHelper:        discoveryHelper,
 		dynamicFactory:         dynamicFactory,
@@ -105,6 +121,7 @@ func NewKubernetesBackupper(
 		blockStore:             blockStore,
 		resticBackupperFactory: resticBackupperFactory,
 		resticTimeout:          resticTimeout,
+		snapshotConcurrency:    snapshotConcurrency,
 	}, nil
 }
 
@@ -147,24 +164,121 @@ func getResourceIncludesExcludes(helper discovery.Helper, includes, excludes []s
 	resources := collections.GenerateIncludesExcludes(
 		includes,
 		excludes,
-		func(item string) string {
-			gvr, _, err := helper.ResourceFor(schema.ParseGroupResource(item).WithVersion(""))
-			if err != nil {
-				return ""
-			}
-
-			gr := gvr.GroupResource()
-			return gr.String()
-		},
+		resourceResolver(helper),
 	)
 
 	return resources
 }
 
+// resourceResolver returns a function that resolves a resource, which may be
+// a shortcut, to its fully-qualified group-resource name, or to "" if it
+// can't be resolved.
+func resourceResolver(helper discovery.Helper) func(string) string {
+	return func(item string) string {
+		gvr, _, err := helper.ResourceFor(schema.ParseGroupResource(item).WithVersion(""))
+		if err != nil {
+			return ""
+		}
+
+		gr := gvr.GroupResource()
+		return gr.String()
+	}
+}
+
+// UnservedResourceWarnings returns a warning for each explicitly-included
+// resource that the API server doesn't serve, e.g. a custom resource whose
+// CustomResourceDefinition isn't installed, since nothing would be backed up
+// for it. The wildcard is never warned about.
+func UnservedResourceWarnings(helper discovery.Helper, includes []string) []string {
+	resolve := resourceResolver(helper)
+
+	var warnings []string
+	for _, item := range includes {
+		if item == "*" {
+			continue
+		}
+		if resolve(item) == "" {
+			warnings = append(warnings, fmt.Sprintf("Included resource %s isn't served by the API server, so none of it will be backed up; check that its CustomResourceDefinition is installed", item))
+		}
+	}
+
+	return warnings
+}
+
+// clusterScopedResourceWarnings returns a warning for each cluster-scoped
+// resource that's explicitly included in the backup, but that its
+// IncludeClusterResources setting skips: all of them when it's false, and,
+// when it's unset, all of them unless every namespace is included.
+// Namespaces are never skipped because of their scope, so they're never
+// warned about.
+func clusterScopedResourceWarnings(helper discovery.Helper, backup *api.Backup, namespaces *collections.IncludesExcludes) []string {
+	var reason string
+	switch include := backup.Spec.IncludeClusterResources; {
+	case include == nil && !namespaces.IncludeEverything():
+		reason = "includeClusterResources is unset and not every namespace is included"
+	case include != nil && !*include:
+		reason = "includeClusterResources is false"
+	default:
+		return nil
+	}
+
+	var warnings []string
+	for _, item := range backup.Spec.IncludedResources {
+		if item == "*" {
+			continue
+		}
+
+		gvr, resource, err := helper.ResourceFor(schema.ParseGroupResource(item).WithVersion(""))
+		if err != nil || resource.Namespaced {
+			continue
+		}
+
+		gr := gvr.GroupResource()
+		if gr == kuberesource.Namespaces {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf("Cluster-scoped resource %s is included, but %s, so it's skipped", gr.String(), reason))
+	}
+
+	return warnings
+}
+
 // getNamespaceIncludesExcludes returns an IncludesExcludes list containing which namespaces to
 // include and exclude from the backup.
 func getNamespaceIncludesExcludes(backup *api.Backup) *collections.IncludesExcludes {
-	return collections.NewIncludesExcludes().Includes(backup.Spec.IncludedNamespaces...).Excludes(backup.Spec.ExcludedNamespaces...)
+	ie := collections.NewIncludesExcludes().Includes(backup.Spec.IncludedNamespaces...).Excludes(backup.Spec.ExcludedNamespaces...)
+	if backup.Spec.NamespaceMatching == api.NamespaceMatchingGlob {
+		ie.MatchGlobs()
+	}
+	return ie
+}
+
+// resolvedIncludesExcludes returns the namespaces and resources that a backup
+// includes and excludes, as they're recorded in its status.
+func resolvedIncludesExcludes(namespaces, resources *collections.IncludesExcludes) *api.BackupResolvedIncludesExcludes {
+	return &api.BackupResolvedIncludesExcludes{
+		IncludedNamespaces: includesOrWildcard(namespaces.GetIncludes()),
+		ExcludedNamespaces: nilIfEmpty(namespaces.GetExcludes()),
+		IncludedResources:  includesOrWildcard(resources.GetIncludes()),
+		ExcludedResources:  nilIfEmpty(resources.GetExcludes()),
+	}
+}
+
+// includesOrWildcard returns includes, or "*" if it's empty, since including
+// nothing means including everything.
+func includesOrWildcard(includes []string) []string {
+	if len(includes) == 0 {
+		return []string{"*"}
+	}
+	return includes
+}
+
+func nilIfEmpty(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	return list
 }
 
 func getResourceHooks(hookSpecs []api.BackupResourceHookSpec, discoveryHelper discovery.Helper) ([]resourceHook, error) {
@@ -191,11 +305,13 @@ func getResourceHook(hookSpec api.BackupResourceHookSpec, discoveryHelper discov
 	}
 
 	h := resourceHook{
-		name:       hookSpec.Name,
-		namespaces: collections.NewIncludesExcludes().Includes(hookSpec.IncludedNamespaces...).Excludes(hookSpec.ExcludedNamespaces...),
-		resources:  getResourceIncludesExcludes(discoveryHelper, hookSpec.IncludedResources, hookSpec.ExcludedResources),
-		pre:        preHooks,
-		post:       hookSpec.PostHooks,
+		name:         hookSpec.Name,
+		namespaces:   collections.NewIncludesExcludes().Includes(hookSpec.IncludedNamespaces...).Excludes(hookSpec.ExcludedNamespaces...),
+		resources:    getResourceIncludesExcludes(discoveryHelper, hookSpec.IncludedResources, hookSpec.ExcludedResources),
+		pre:          preHooks,
+		post:         hookSpec.PostHooks,
+		preSnapshot:  hookSpec.PreSnapshotHooks,
+		postSnapshot: hookSpec.PostSnapshotHooks,
 	}
 
 	if hookSpec.LabelSelector != nil {
@@ -209,29 +325,76 @@ func getResourceHook(hookSpec api.BackupResourceHookSpec, discoveryHelper discov
 	return h, nil
 }
 
-// Backup backs up the items specified in the Backup, placing them in a gzip-compressed tar file
-// written to backupFile. The finalized api.Backup is written to metadata.
-func (kb *kubernetesBackupper) Backup(logger logrus.FieldLogger, backup *api.Backup, backupFile io.Writer, actions []ItemAction) error {
-	gzippedData := gzip.NewWriter(backupFile)
-	defer gzippedData.Close()
-
-	tw := tar.NewWriter(gzippedData)
+// Backup backs up the items specified in the Backup, placing them in a tar file written to
+// backupFile. The caller is responsible for compressing it. A summary of the items that
+// were backed up is recorded in the backup's Status.Progress.
+func (kb *kubernetesBackupper) Backup(logger logrus.FieldLogger, backup *api.Backup, backupFile io.Writer, actions []ItemAction, baseVersions archive.ResourceVersions, manifest io.Writer) ([]string, error) {
+	written := &countingWriter{w: backupFile}
+	tw := tar.NewWriter(written)
 	defer tw.Close()
 
+	var fileWriter tarWriter = tw
+	if manifest != nil {
+		mtw, err := newManifestTarWriter(tw, written, manifest)
+		if err != nil {
+			return nil, err
+		}
+		fileWriter = mtw
+	}
+
+	// record what was backed up in the backup's status as items are
+	// written, so that a partially failed backup shows what it captured.
+	ptw := newProgressTarWriter(fileWriter, backup)
+
+	var (
+		itemWriter tarWriter = ptw
+		itw        *incrementalTarWriter
+	)
+	if baseVersions != nil {
+		itw = newIncrementalTarWriter(ptw, backup, baseVersions)
+		itemWriter = itw
+	}
+	defer func() {
+		backup.Status.Progress.VolumeSnapshots = len(backup.Status.VolumeBackups)
+	}()
+
 	log := logger.WithField("backup", kubeutil.NamespaceAndName(backup))
 	log.Info("Starting backup")
 
+	var warnings []string
+
 	namespaceIncludesExcludes := getNamespaceIncludesExcludes(backup)
+
+	if backup.Spec.TerminatingNamespacePolicy != api.TerminatingNamespacePolicyInclude {
+		terminating, err := kb.terminatingNamespaces(namespaceIncludesExcludes)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Unable to check for terminating namespaces: %v", err))
+		}
+		for _, ns := range terminating {
+			warnings = append(warnings, fmt.Sprintf("Skipping namespace %s because it's terminating", ns))
+		}
+		backup.Status.SkippedTerminatingNamespaces = terminating
+	}
+
 	log.Infof("Including namespaces: %s", namespaceIncludesExcludes.IncludesString())
 	log.Infof("Excluding namespaces: %s", namespaceIncludesExcludes.ExcludesString())
 
 	resourceIncludesExcludes := getResourceIncludesExcludes(kb.discoveryHelper, backup.Spec.IncludedResources, backup.Spec.ExcludedResources)
 	log.Infof("Including resources: %s", resourceIncludesExcludes.IncludesString())
 	log.Infof("Excluding resources: %s", resourceIncludesExcludes.ExcludesString())
+	warnings = append(warnings, clusterScopedResourceWarnings(kb.discoveryHelper, backup, namespaceIncludesExcludes)...)
+
+	backup.Status.ResolvedIncludesExcludes = resolvedIncludesExcludes(namespaceIncludesExcludes, resourceIncludesExcludes)
 
 	resourceHooks, err := getResourceHooks(backup.Spec.Hooks.Resources, kb.discoveryHelper)
 	if err != nil {
-		return err
+		return warnings, err
+	}
+
+	// item transforms are applied as each item is written, so check that
+	// they're all valid before any are.
+	if _, err := parseItemTransforms(backup.Spec.ItemTransforms); err != nil {
+		return warnings, err
 	}
 
 	backedUpItems := make(map[itemKey]struct{})
@@ -239,7 +402,7 @@ func (kb *kubernetesBackupper) Backup(logger logrus.FieldLogger, backup *api.Bac
 
 	resolvedActions, err := resolveActions(actions, kb.discoveryHelper)
 	if err != nil {
-		return err
+		return warnings, err
 	}
 
 	podVolumeTimeout := kb.resticTimeout
@@ -252,17 +415,25 @@ func (kb *kubernetesBackupper) Backup(logger logrus.FieldLogger, backup *api.Bac
 		}
 	}
 
+	if backup.Spec.Hooks.Validate {
+		warnings = append(warnings, validateHookTimeouts(resourceHooks, podVolumeTimeout)...)
+	}
+
 	ctx, cancelFunc := context.WithTimeout(context.Background(), podVolumeTimeout)
 	defer cancelFunc()
 
 	var resticBackupper restic.Backupper
-	if kb.resticBackupperFactory != nil {
+	if kb.resticBackupperFactory != nil && !backup.Spec.DryRun {
 		resticBackupper, err = kb.resticBackupperFactory.NewBackupper(ctx, backup)
 		if err != nil {
-			return errors.WithStack(err)
+			return warnings, errors.WithStack(err)
 		}
 	}
 
+	// volume snapshots are taken in the background while the backup's
+	// items are collected, so that several can be in progress at once.
+	snapshots := newSnapshotPool(kb.snapshotConcurrency)
+
 	gb := kb.groupBackupperFactory.newGroupBackupper(
 		log,
 		backup,
@@ -274,27 +445,209 @@ func (kb *kubernetesBackupper) Backup(logger logrus.FieldLogger, backup *api.Bac
 		cohabitatingResources(),
 		resolvedActions,
 		kb.podCommandExecutor,
-		tw,
+		itemWriter,
 		resourceHooks,
 		kb.blockStore,
 		resticBackupper,
 		newPVCSnapshotTracker(),
+		snapshots,
 	)
 
-	for _, group := range kb.discoveryHelper.Resources() {
+	groups, orderWarnings := orderResourceGroups(kb.discoveryHelper, backup.Spec.OrderedResources)
+	warnings = append(warnings, orderWarnings...)
+
+	for _, group := range groups {
 		if err := gb.backupGroup(group); err != nil {
 			errs = append(errs, err)
 		}
 	}
 
+	log.Debug("Waiting for volume snapshots to complete")
+	errs = append(errs, snapshots.wait()...)
+
+	if itw != nil {
+		if err := itw.writeUnchangedItems(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// a copy of the backup goes in its tarball, so that its metadata can
+	// be rebuilt from the tarball if it's lost.
+	if err := writeBackupMetadata(fileWriter, backup); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, item := range backup.Status.SkippedLargeItems {
+		name := item.Name
+		if item.Namespace != "" {
+			name = item.Namespace + "/" + name
+		}
+		warnings = append(warnings, fmt.Sprintf("Skipped %s %s because its size of %d bytes is larger than the backup's maximum item size", item.GroupResource, name, item.SizeBytes))
+	}
+
+	for _, resource := range backup.Status.SkippedUnlistableResources {
+		if resource.Namespace != "" {
+			warnings = append(warnings, fmt.Sprintf("Skipped %s in namespace %s because its items couldn't be listed: %s", resource.GroupResource, resource.Namespace, resource.Error))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("Skipped %s because its items couldn't be listed: %s", resource.GroupResource, resource.Error))
+		}
+	}
+
 	err = kuberrs.Flatten(kuberrs.NewAggregate(errs))
-	if err == nil {
-		log.Infof("Backup completed successfully")
-	} else {
+	switch {
+	case err != nil:
 		log.Infof("Backup completed with errors: %v", err)
+	case len(warnings) > 0:
+		log.Infof("Backup completed with %d warnings", len(warnings))
+	default:
+		log.Infof("Backup completed successfully")
+	}
+
+	return warnings, err
+}
+
+// writeBackupMetadata writes a copy of backup, as it is now, to tw at
+// archive.BackupMetadataPath, encoded like the backup's metadata file.
+func writeBackupMetadata(tw tarWriter, backup *api.Backup) error {
+	backup = backup.DeepCopy()
+	backup.APIVersion = api.SchemeGroupVersion.String()
+	backup.Kind = "Backup"
+
+	contents, err := json.Marshal(backup)
+	if err != nil {
+		return errors.Wrap(err, "error encoding backup metadata")
+	}
+
+	hdr := &tar.Header{
+		Name:     archive.BackupMetadataPath,
+		Size:     int64(len(contents)),
+		Typeflag: tar.TypeReg,
+		Mode:     0755,
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = tw.Write(contents)
+	return errors.WithStack(err)
+}
+
+// orderResourceGroups returns the API groups to back up, with the resources
+// in ordered, formatted as resource.group, first and in the order they're
+// listed. Each ordered resource is moved out of its group into a group of
+// its own. Resources that can't be resolved are left in their default
+// order, and a warning is returned for each of them.
+func orderResourceGroups(helper discovery.Helper, ordered []string) ([]*metav1.APIResourceList, []string) {
+	groups := helper.Resources()
+	if len(ordered) == 0 {
+		return groups, nil
+	}
+
+	var warnings []string
+
+	var res []*metav1.APIResourceList
+	moved := make(map[schema.GroupVersionResource]bool)
+
+	for _, item := range ordered {
+		gvr, _, err := helper.ResourceFor(schema.ParseGroupResource(item).WithVersion(""))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Unable to resolve ordered resource %s, so it's backed up in the default order: %v", item, err))
+			continue
+		}
+		if moved[gvr] {
+			continue
+		}
+
+		for _, group := range groups {
+			if group.GroupVersion != gvr.GroupVersion().String() {
+				continue
+			}
+			for _, resource := range group.APIResources {
+				if resource.Name == gvr.Resource {
+					res = append(res, &metav1.APIResourceList{
+						GroupVersion: group.GroupVersion,
+						APIResources: []metav1.APIResource{resource},
+					})
+					moved[gvr] = true
+				}
+			}
+		}
 	}
 
-	return err
+	for _, group := range groups {
+		gv, err := schema.ParseGroupVersion(group.GroupVersion)
+		if err != nil {
+			// leave it for the group backupper to report
+			res = append(res, group)
+			continue
+		}
+
+		remaining := &metav1.APIResourceList{GroupVersion: group.GroupVersion}
+		for _, resource := range group.APIResources {
+			if !moved[gv.WithResource(resource.Name)] {
+				remaining.APIResources = append(remaining.APIResources, resource)
+			}
+		}
+		if len(remaining.APIResources) > 0 {
+			res = append(res, remaining)
+		}
+	}
+
+	return res, warnings
+}
+
+// terminatingNamespaces returns the names of the namespaces included by
+// namespaces that are being deleted.
+func (kb *kubernetesBackupper) terminatingNamespaces(namespaces *collections.IncludesExcludes) ([]string, error) {
+	gvr, resource, err := kb.discoveryHelper.ResourceFor(kuberesource.Namespaces.WithVersion(""))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kb.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), resource, "")
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var terminating []string
+	for _, item := range items {
+		ns, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			return nil, errors.Errorf("unexpected type %T", item)
+		}
+
+		if !namespaces.ShouldInclude(ns.GetName()) {
+			continue
+		}
+
+		if phase, _, _ := unstructured.NestedString(ns.Object, "status", "phase"); phase == string(corev1api.NamespaceTerminating) {
+			terminating = append(terminating, ns.GetName())
+		}
+	}
+
+	return terminating, nil
+}
+
+// isSkippedTerminatingNamespace returns whether namespace was left out of
+// the backup because it was being deleted.
+func isSkippedTerminatingNamespace(backup *api.Backup, namespace string) bool {
+	for _, ns := range backup.Status.SkippedTerminatingNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
 }
 
 type tarWriter interface {
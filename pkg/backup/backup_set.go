@@ -0,0 +1,73 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// ValidateBackupSet returns an error for each problem with the given backup
+// set name and order. A backup set name must be a valid DNS-1123 label, and
+// an order, if given, must be an integer.
+func ValidateBackupSet(name, order string) []string {
+	var errs []string
+
+	if name == "" {
+		if order != "" {
+			errs = append(errs, "A backup set order can only be specified for a backup in a backup set")
+		}
+		return errs
+	}
+
+	if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+		errs = append(errs, fmt.Sprintf("Invalid backup set name %q: %s", name, strings.Join(msgs, "; ")))
+	}
+
+	if order != "" {
+		if _, err := strconv.Atoi(order); err != nil {
+			errs = append(errs, fmt.Sprintf("Invalid backup set order %q: must be an integer", order))
+		}
+	}
+
+	return errs
+}
+
+// BackupSetOrder returns the backup's order within its backup set, or zero
+// if it has none.
+func BackupSetOrder(backup *api.Backup) int {
+	order, _ := strconv.Atoi(backup.Labels[api.BackupSetOrderLabel])
+	return order
+}
+
+// SortBackupSet sorts the backups in a backup set into the order they should
+// be restored in: by their backup set order, then by name.
+func SortBackupSet(backups []api.Backup) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		iOrder, jOrder := BackupSetOrder(&backups[i]), BackupSetOrder(&backups[j])
+		if iOrder != jOrder {
+			return iOrder < jOrder
+		}
+		return backups[i].Name < backups[j].Name
+	})
+}
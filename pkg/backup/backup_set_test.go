@@ -0,0 +1,65 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestValidateBackupSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      string
+		order    string
+		expected int
+	}{
+		{name: "no backup set", expected: 0},
+		{name: "valid name", set: "set-1", expected: 0},
+		{name: "valid name and order", set: "set-1", order: "-2", expected: 0},
+		{name: "invalid name", set: "Set_1", expected: 1},
+		{name: "invalid order", set: "set-1", order: "first", expected: 1},
+		{name: "order without a backup set", order: "1", expected: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Len(t, ValidateBackupSet(test.set, test.order), test.expected)
+		})
+	}
+}
+
+func TestSortBackupSet(t *testing.T) {
+	backups := []api.Backup{
+		*arktest.NewTestBackup().WithName("c").Backup,
+		*arktest.NewTestBackup().WithName("b").WithLabel(api.BackupSetOrderLabel, "2").Backup,
+		*arktest.NewTestBackup().WithName("a").WithLabel(api.BackupSetOrderLabel, "2").Backup,
+		*arktest.NewTestBackup().WithName("d").WithLabel(api.BackupSetOrderLabel, "-1").Backup,
+	}
+
+	SortBackupSet(backups)
+
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	assert.Equal(t, []string{"d", "c", "a", "b"}, names)
+}
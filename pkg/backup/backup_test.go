@@ -17,7 +17,10 @@ limitations under the License.
 package backup
 
 import (
+	"archive/tar"
 	"bytes"
+	"encoding/json"
+	"io"
 	"reflect"
 	"sort"
 	"testing"
@@ -37,11 +40,13 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/discovery"
 	"github.com/heptio/ark/pkg/podexec"
 	"github.com/heptio/ark/pkg/restic"
+	"github.com/heptio/ark/pkg/util/boolptr"
 	"github.com/heptio/ark/pkg/util/collections"
 	kubeutil "github.com/heptio/ark/pkg/util/kube"
 	"github.com/heptio/ark/pkg/util/logging"
@@ -226,6 +231,107 @@ func TestGetResourceIncludesExcludes(t *testing.T) {
 	}
 }
 
+func TestUnservedResourceWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		includes []string
+		expected []string
+	}{
+		{
+			name:     "served resources",
+			includes: []string{"foo", "foodies.somegroup"},
+		},
+		{
+			name:     "unserved resource",
+			includes: []string{"foo", "widgets.example.com"},
+			expected: []string{"Included resource widgets.example.com isn't served by the API server, so none of it will be backed up; check that its CustomResourceDefinition is installed"},
+		},
+		{
+			name:     "wildcard is exempt",
+			includes: []string{"*"},
+		},
+		{
+			name: "no includes",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resources := map[schema.GroupVersionResource]schema.GroupVersionResource{
+				{Resource: "foo"}:                         {Group: "somegroup", Resource: "foodies"},
+				{Resource: "foodies", Group: "somegroup"}: {Group: "somegroup", Resource: "foodies"},
+			}
+			discoveryHelper := arktest.NewFakeDiscoveryHelper(false, resources)
+
+			assert.Equal(t, test.expected, UnservedResourceWarnings(discoveryHelper, test.includes))
+		})
+	}
+}
+
+func TestClusterScopedResourceWarnings(t *testing.T) {
+	tests := []struct {
+		name                    string
+		includeClusterResources *bool
+		includedNamespaces      []string
+		includedResources       []string
+		expected                []string
+	}{
+		{
+			name:              "unset, with every namespace included",
+			includedResources: []string{"persistentvolumes", "pods"},
+		},
+		{
+			name:               "unset, with specific namespaces included",
+			includedNamespaces: []string{"ns-1"},
+			includedResources:  []string{"pv", "pods"},
+			expected:           []string{"Cluster-scoped resource persistentvolumes is included, but includeClusterResources is unset and not every namespace is included, so it's skipped"},
+		},
+		{
+			name:                    "false",
+			includeClusterResources: boolptr.False(),
+			includedResources:       []string{"persistentvolumes", "pods", "storageclasses.storage.k8s.io"},
+			expected: []string{
+				"Cluster-scoped resource persistentvolumes is included, but includeClusterResources is false, so it's skipped",
+				"Cluster-scoped resource storageclasses.storage.k8s.io is included, but includeClusterResources is false, so it's skipped",
+			},
+		},
+		{
+			name:                    "true, with specific namespaces included",
+			includeClusterResources: boolptr.True(),
+			includedNamespaces:      []string{"ns-1"},
+			includedResources:       []string{"persistentvolumes", "pods"},
+		},
+		{
+			name:                    "false, with namespaces and wildcards included",
+			includeClusterResources: boolptr.False(),
+			includedResources:       []string{"*", "namespaces", "unresolvable"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resources := map[schema.GroupVersionResource]schema.GroupVersionResource{
+				{Resource: "pv"}:                                      {Resource: "persistentvolumes"},
+				{Resource: "persistentvolumes"}:                       {Resource: "persistentvolumes"},
+				{Resource: "storageclasses", Group: "storage.k8s.io"}: {Group: "storage.k8s.io", Resource: "storageclasses"},
+				{Resource: "namespaces"}:                              {Resource: "namespaces"},
+				{Resource: "pods"}:                                    {Resource: "pods"},
+			}
+			discoveryHelper := arktest.NewFakeDiscoveryHelper(false, resources)
+			for _, list := range discoveryHelper.ResourceList {
+				for i := range list.APIResources {
+					list.APIResources[i].Namespaced = list.APIResources[i].Name == "pods"
+				}
+			}
+
+			backup := arktest.NewTestBackup().WithIncludedNamespaces(test.includedNamespaces...).WithIncludedResources(test.includedResources...).Backup
+			backup.Spec.IncludeClusterResources = test.includeClusterResources
+
+			assert.Equal(t, test.expected, clusterScopedResourceWarnings(discoveryHelper, backup, getNamespaceIncludesExcludes(backup)))
+		})
+	}
+}
+
 func TestGetNamespaceIncludesExcludes(t *testing.T) {
 	backup := &v1.Backup{
 		Spec: v1.BackupSpec{
@@ -252,6 +358,24 @@ func TestGetNamespaceIncludesExcludes(t *testing.T) {
 	}
 }
 
+func TestGetNamespaceIncludesExcludesGlob(t *testing.T) {
+	backup := &v1.Backup{
+		Spec: v1.BackupSpec{
+			IncludedNamespaces: []string{"team-*"},
+			ExcludedNamespaces: []string{"team-?-old"},
+		},
+	}
+
+	ns := getNamespaceIncludesExcludes(backup)
+	assert.False(t, ns.ShouldInclude("team-a"))
+
+	backup.Spec.NamespaceMatching = v1.NamespaceMatchingGlob
+	ns = getNamespaceIncludesExcludes(backup)
+	assert.True(t, ns.ShouldInclude("team-a"))
+	assert.False(t, ns.ShouldInclude("team-a-old"))
+	assert.False(t, ns.ShouldInclude("default"))
+}
+
 var (
 	v1Group = &metav1.APIResourceList{
 		GroupVersion: "v1",
@@ -378,6 +502,7 @@ func TestBackup(t *testing.T) {
 		expectedResources     *collections.IncludesExcludes
 		expectedLabelSelector string
 		expectedHooks         []resourceHook
+		expectedResolved      *v1.BackupResolvedIncludesExcludes
 		backupGroupErrors     map[*metav1.APIResourceList]error
 		expectedError         error
 	}{
@@ -396,6 +521,35 @@ func TestBackup(t *testing.T) {
 			expectedNamespaces: collections.NewIncludesExcludes().Includes("a", "b").Excludes("c", "d"),
 			expectedResources:  collections.NewIncludesExcludes().Includes("configmaps", "certificatesigningrequests.certificates.k8s.io", "roles.rbac.authorization.k8s.io"),
 			expectedHooks:      []resourceHook{},
+			expectedResolved: &v1.BackupResolvedIncludesExcludes{
+				IncludedNamespaces: []string{"a", "b"},
+				ExcludedNamespaces: []string{"c", "d"},
+				IncludedResources:  []string{"certificatesigningrequests.certificates.k8s.io", "configmaps", "roles.rbac.authorization.k8s.io"},
+			},
+			backupGroupErrors: map[*metav1.APIResourceList]error{
+				v1Group:           nil,
+				certificatesGroup: nil,
+				rbacGroup:         nil,
+			},
+		},
+		{
+			name: "wildcard includes and resolved excludes",
+			backup: &v1.Backup{
+				Spec: v1.BackupSpec{
+					IncludedResources:  []string{"*"},
+					ExcludedResources:  []string{"cm", "roles", "unresolvable"},
+					ExcludedNamespaces: []string{"kube-system"},
+				},
+			},
+			expectedNamespaces: collections.NewIncludesExcludes().Excludes("kube-system"),
+			expectedResources:  collections.NewIncludesExcludes().Includes("*").Excludes("configmaps", "roles.rbac.authorization.k8s.io"),
+			expectedHooks:      []resourceHook{},
+			expectedResolved: &v1.BackupResolvedIncludesExcludes{
+				IncludedNamespaces: []string{"*"},
+				ExcludedNamespaces: []string{"kube-system"},
+				IncludedResources:  []string{"*"},
+				ExcludedResources:  []string{"configmaps", "roles.rbac.authorization.k8s.io"},
+			},
 			backupGroupErrors: map[*metav1.APIResourceList]error{
 				v1Group:           nil,
 				certificatesGroup: nil,
@@ -415,6 +569,10 @@ func TestBackup(t *testing.T) {
 			expectedResources:     collections.NewIncludesExcludes(),
 			expectedHooks:         []resourceHook{},
 			expectedLabelSelector: "a=b",
+			expectedResolved: &v1.BackupResolvedIncludesExcludes{
+				IncludedNamespaces: []string{"*"},
+				IncludedResources:  []string{"*"},
+			},
 			backupGroupErrors: map[*metav1.APIResourceList]error{
 				v1Group:           nil,
 				certificatesGroup: nil,
@@ -463,6 +621,10 @@ func TestBackup(t *testing.T) {
 			},
 			expectedNamespaces: collections.NewIncludesExcludes(),
 			expectedResources:  collections.NewIncludesExcludes(),
+			expectedResolved: &v1.BackupResolvedIncludesExcludes{
+				IncludedNamespaces: []string{"*"},
+				IncludedResources:  []string{"*"},
+			},
 			expectedHooks: []resourceHook{
 				{
 					name:          "hook1",
@@ -515,6 +677,7 @@ func TestBackup(t *testing.T) {
 				nil,
 				nil, // restic backupper factory
 				0,   // restic timeout
+				1,   // snapshot concurrency
 			)
 			require.NoError(t, err)
 			kb := b.(*kubernetesBackupper)
@@ -542,6 +705,7 @@ func TestBackup(t *testing.T) {
 				mock.Anything,
 				mock.Anything, // restic backupper
 				mock.Anything, // pvc snapshot tracker
+				mock.Anything, // snapshot pool
 			).Return(groupBackupper)
 
 			for group, err := range test.backupGroupErrors {
@@ -550,13 +714,14 @@ func TestBackup(t *testing.T) {
 
 			var backupFile bytes.Buffer
 
-			err = b.Backup(logging.DefaultLogger(logrus.DebugLevel), test.backup, &backupFile, nil)
+			_, err = b.Backup(logging.DefaultLogger(logrus.DebugLevel), test.backup, &backupFile, nil, nil, nil)
 
 			if test.expectedError != nil {
 				assert.EqualError(t, err, test.expectedError.Error())
 				return
 			}
 			assert.NoError(t, err)
+			assert.Equal(t, test.expectedResolved, test.backup.Status.ResolvedIncludesExcludes)
 		})
 	}
 }
@@ -568,7 +733,7 @@ func TestBackupUsesNewCohabitatingResourcesForEachBackup(t *testing.T) {
 		},
 	}
 
-	b, err := NewKubernetesBackupper(discoveryHelper, nil, nil, nil, nil, 0)
+	b, err := NewKubernetesBackupper(discoveryHelper, nil, nil, nil, nil, 0, 1)
 	require.NoError(t, err)
 
 	kb := b.(*kubernetesBackupper)
@@ -594,9 +759,11 @@ func TestBackupUsesNewCohabitatingResourcesForEachBackup(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(&mockGroupBackupper{})
 
-	assert.NoError(t, b.Backup(arktest.NewLogger(), &v1.Backup{}, &bytes.Buffer{}, nil))
+	_, err = b.Backup(arktest.NewLogger(), &v1.Backup{}, &bytes.Buffer{}, nil, nil, nil)
+	assert.NoError(t, err)
 	groupBackupperFactory.AssertExpectations(t)
 
 	// mutate the cohabitatingResources map that was used in the first backup to simulate
@@ -626,9 +793,11 @@ func TestBackupUsesNewCohabitatingResourcesForEachBackup(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(&mockGroupBackupper{})
 
-	assert.NoError(t, b.Backup(arktest.NewLogger(), &v1.Backup{}, &bytes.Buffer{}, nil))
+	_, err = b.Backup(arktest.NewLogger(), &v1.Backup{}, &bytes.Buffer{}, nil, nil, nil)
+	assert.NoError(t, err)
 	assert.NotEqual(t, firstCohabitatingResources, secondCohabitatingResources)
 	for _, resource := range secondCohabitatingResources {
 		assert.False(t, resource.seen)
@@ -636,6 +805,280 @@ func TestBackupUsesNewCohabitatingResourcesForEachBackup(t *testing.T) {
 	groupBackupperFactory.AssertExpectations(t)
 }
 
+func TestBackupOrderedResources(t *testing.T) {
+	tests := []struct {
+		name             string
+		orderedResources []string
+		expected         []string
+	}{
+		{
+			name:     "no ordered resources uses the discovery order",
+			expected: []string{"configmaps", "pods", "namespaces", "certificatesigningrequests.certificates.k8s.io", "roles.rbac.authorization.k8s.io"},
+		},
+		{
+			name:             "ordered resources are backed up first, in order",
+			orderedResources: []string{"roles.rbac.authorization.k8s.io", "pods"},
+			expected:         []string{"roles.rbac.authorization.k8s.io", "pods", "configmaps", "namespaces", "certificatesigningrequests.certificates.k8s.io"},
+		},
+		{
+			name:             "unresolvable and duplicate ordered resources are ignored",
+			orderedResources: []string{"pods", "foo", "pods"},
+			expected:         []string{"pods", "configmaps", "namespaces", "certificatesigningrequests.certificates.k8s.io", "roles.rbac.authorization.k8s.io"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			discoveryHelper := &arktest.FakeDiscoveryHelper{
+				Mapper: &arktest.FakeMapper{
+					Resources: map[schema.GroupVersionResource]schema.GroupVersionResource{
+						{Resource: "pods"}: {Group: "", Version: "v1", Resource: "pods"},
+						{Group: "rbac.authorization.k8s.io", Resource: "roles"}: {Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "roles"},
+					},
+				},
+				ResourceList: []*metav1.APIResourceList{
+					v1Group,
+					certificatesGroup,
+					rbacGroup,
+				},
+			}
+
+			b, err := NewKubernetesBackupper(discoveryHelper, nil, nil, nil, nil, 0, 1)
+			require.NoError(t, err)
+			kb := b.(*kubernetesBackupper)
+			kb.groupBackupperFactory = &tarGroupBackupperFactory{}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+			backup.Spec.OrderedResources = test.orderedResources
+
+			var backupFile bytes.Buffer
+			_, err = b.Backup(arktest.NewLogger(), backup, &backupFile, nil, nil, nil)
+			require.NoError(t, err)
+
+			var res []string
+			tr := tar.NewReader(&backupFile)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				// the backup's own metadata is written after its items
+				if header.Name == archive.BackupMetadataPath {
+					continue
+				}
+				res = append(res, header.Name)
+			}
+
+			assert.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestBackupWritesBackupMetadata(t *testing.T) {
+	discoveryHelper := &arktest.FakeDiscoveryHelper{
+		Mapper: &arktest.FakeMapper{
+			Resources: map[schema.GroupVersionResource]schema.GroupVersionResource{},
+		},
+	}
+
+	b, err := NewKubernetesBackupper(discoveryHelper, nil, nil, nil, nil, 0, 1)
+	require.NoError(t, err)
+	b.(*kubernetesBackupper).groupBackupperFactory = &tarGroupBackupperFactory{}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithIncludedNamespaces("ns-1").Backup
+
+	var backupFile bytes.Buffer
+	_, err = b.Backup(arktest.NewLogger(), backup, &backupFile, nil, nil, nil)
+	require.NoError(t, err)
+
+	tr := tar.NewReader(&backupFile)
+	header, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, archive.BackupMetadataPath, header.Name)
+
+	var metadata v1.Backup
+	require.NoError(t, json.NewDecoder(tr).Decode(&metadata))
+	assert.Equal(t, "ark.heptio.com/v1", metadata.APIVersion)
+	assert.Equal(t, "Backup", metadata.Kind)
+	assert.Equal(t, "backup-1", metadata.Name)
+	assert.Equal(t, []string{"ns-1"}, metadata.Spec.IncludedNamespaces)
+
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBackupReturnsWarningsSeparatelyFromErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		groupErr         error
+		expectedWarnings []string
+		expectedErr      string
+	}{
+		{
+			name: "warnings only",
+			expectedWarnings: []string{
+				`Unable to resolve ordered resource foo, so it's backed up in the default order: invalid resource "/, Resource=foo"`,
+				"Skipped configmaps ns-1/big because its size of 2048 bytes is larger than the backup's maximum item size",
+			},
+		},
+		{
+			name:     "warnings and errors",
+			groupErr: errors.New("error listing items"),
+			expectedWarnings: []string{
+				`Unable to resolve ordered resource foo, so it's backed up in the default order: invalid resource "/, Resource=foo"`,
+				"Skipped configmaps ns-1/big because its size of 2048 bytes is larger than the backup's maximum item size",
+			},
+			expectedErr: "error listing items",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			discoveryHelper := &arktest.FakeDiscoveryHelper{
+				Mapper: &arktest.FakeMapper{
+					Resources: map[schema.GroupVersionResource]schema.GroupVersionResource{},
+				},
+				ResourceList: []*metav1.APIResourceList{
+					{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps", Namespaced: true}}},
+				},
+			}
+
+			b, err := NewKubernetesBackupper(discoveryHelper, nil, nil, nil, nil, 0, 1)
+			require.NoError(t, err)
+			b.(*kubernetesBackupper).groupBackupperFactory = &skippingGroupBackupperFactory{err: test.groupErr}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").WithTerminatingNamespacePolicy(v1.TerminatingNamespacePolicyInclude).Backup
+			backup.Spec.OrderedResources = []string{"foo"}
+
+			warnings, err := b.Backup(arktest.NewLogger(), backup, new(bytes.Buffer), nil, nil, nil)
+			assert.Equal(t, test.expectedWarnings, warnings)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+// skippingGroupBackupperFactory returns group backuppers that record a
+// skipped large item for each group, and return err.
+type skippingGroupBackupperFactory struct {
+	err error
+}
+
+func (f *skippingGroupBackupperFactory) newGroupBackupper(
+	log logrus.FieldLogger,
+	backup *v1.Backup,
+	namespaces, resources *collections.IncludesExcludes,
+	dynamicFactory client.DynamicFactory,
+	discoveryHelper discovery.Helper,
+	backedUpItems map[itemKey]struct{},
+	cohabitatingResources map[string]*cohabitatingResource,
+	actions []resolvedAction,
+	podCommandExecutor podexec.PodCommandExecutor,
+	tarWriter tarWriter,
+	resourceHooks []resourceHook,
+	blockStore cloudprovider.BlockStore,
+	resticBackupper restic.Backupper,
+	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
+) groupBackupper {
+	return &skippingGroupBackupper{backup: backup, err: f.err}
+}
+
+type skippingGroupBackupper struct {
+	backup *v1.Backup
+	err    error
+}
+
+func (gb *skippingGroupBackupper) backupGroup(group *metav1.APIResourceList) error {
+	gb.backup.Status.SkippedLargeItems = append(gb.backup.Status.SkippedLargeItems, v1.SkippedLargeItem{
+		GroupResource: "configmaps",
+		Namespace:     "ns-1",
+		Name:          "big",
+		SizeBytes:     2048,
+	})
+
+	return gb.err
+}
+
+// tarGroupBackupperFactory returns group backuppers that write an empty
+// tar entry, named for the resource, for each resource in a group.
+type tarGroupBackupperFactory struct{}
+
+func (f *tarGroupBackupperFactory) newGroupBackupper(
+	log logrus.FieldLogger,
+	backup *v1.Backup,
+	namespaces, resources *collections.IncludesExcludes,
+	dynamicFactory client.DynamicFactory,
+	discoveryHelper discovery.Helper,
+	backedUpItems map[itemKey]struct{},
+	cohabitatingResources map[string]*cohabitatingResource,
+	actions []resolvedAction,
+	podCommandExecutor podexec.PodCommandExecutor,
+	tarWriter tarWriter,
+	resourceHooks []resourceHook,
+	blockStore cloudprovider.BlockStore,
+	resticBackupper restic.Backupper,
+	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
+) groupBackupper {
+	return &tarGroupBackupper{tarWriter: tarWriter}
+}
+
+type tarGroupBackupper struct {
+	tarWriter tarWriter
+}
+
+func (gb *tarGroupBackupper) backupGroup(group *metav1.APIResourceList) error {
+	gv, err := schema.ParseGroupVersion(group.GroupVersion)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range group.APIResources {
+		gr := gv.WithResource(resource.Name).GroupResource()
+		header := &tar.Header{
+			Name:     gr.String(),
+			Typeflag: tar.TypeReg,
+		}
+		if err := gb.tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestTerminatingNamespaces(t *testing.T) {
+	discoveryHelper := &arktest.FakeDiscoveryHelper{AutoReturnResource: true}
+	dynamicFactory := &arktest.FakeDynamicFactory{}
+	defer dynamicFactory.AssertExpectations(t)
+
+	client := &arktest.FakeDynamicClient{}
+	defer client.AssertExpectations(t)
+
+	dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{}, metav1.APIResource{Name: "namespaces"}, "").Return(client, nil)
+	client.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{
+		Items: []unstructured.Unstructured{
+			*arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"active"},"status":{"phase":"Active"}}`),
+			*arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"terminating"},"status":{"phase":"Terminating"}}`),
+			*arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"excluded"},"status":{"phase":"Terminating"}}`),
+		},
+	}, nil)
+
+	kb := &kubernetesBackupper{
+		discoveryHelper: discoveryHelper,
+		dynamicFactory:  dynamicFactory,
+	}
+
+	terminating, err := kb.terminatingNamespaces(collections.NewIncludesExcludes().Excludes("excluded"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"terminating"}, terminating)
+}
+
 type mockGroupBackupperFactory struct {
 	mock.Mock
 }
@@ -655,6 +1098,7 @@ func (f *mockGroupBackupperFactory) newGroupBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupper restic.Backupper,
 	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
 ) groupBackupper {
 	args := f.Called(
 		log,
@@ -672,6 +1116,7 @@ func (f *mockGroupBackupperFactory) newGroupBackupper(
 		blockStore,
 		resticBackupper,
 		resticSnapshotTracker,
+		snapshotPool,
 	)
 	return args.Get(0).(groupBackupper)
 }
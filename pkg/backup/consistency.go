@@ -0,0 +1,99 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"path"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// tracksConsistency returns whether the backup records the resourceVersions
+// of the items it captures.
+func tracksConsistency(backup *api.Backup) bool {
+	return backup.Spec.ConsistencyMode == api.BackupConsistencyModeResourceVersion
+}
+
+// backupConsistency returns the backup's consistency status, adding it if
+// it doesn't have one yet.
+func backupConsistency(backup *api.Backup) *api.BackupConsistency {
+	if backup.Status.Consistency == nil {
+		backup.Status.Consistency = &api.BackupConsistency{
+			Resources: make(map[string]api.ResourceVersionRange),
+		}
+	}
+	return backup.Status.Consistency
+}
+
+// parseResourceVersion parses a resourceVersion so that it can be compared
+// to others. The API server's resourceVersions are opaque, but they're
+// numbers for every storage backend that Kubernetes supports; those that
+// aren't can't be compared, so aren't tracked.
+func parseResourceVersion(resourceVersion string) (uint64, bool) {
+	version, err := strconv.ParseUint(resourceVersion, 10, 64)
+	return version, err == nil
+}
+
+// recordListResourceVersion records resourceVersion, that of a list of
+// items made by the backup, as its StartResourceVersion if it's the first.
+func recordListResourceVersion(backup *api.Backup, resourceVersion string) {
+	if !tracksConsistency(backup) {
+		return
+	}
+	if _, ok := parseResourceVersion(resourceVersion); !ok {
+		return
+	}
+
+	consistency := backupConsistency(backup)
+	if consistency.StartResourceVersion == "" {
+		consistency.StartResourceVersion = resourceVersion
+	}
+}
+
+// recordItemResourceVersion adds resourceVersion, that of an item written
+// to the backup, to the range of its resource's resourceVersions, and flags
+// the item as potentially inconsistent if it was modified after the backup
+// began listing items.
+func recordItemResourceVersion(backup *api.Backup, groupResource schema.GroupResource, namespace, name, resourceVersion string) {
+	if !tracksConsistency(backup) {
+		return
+	}
+	version, ok := parseResourceVersion(resourceVersion)
+	if !ok {
+		return
+	}
+
+	consistency := backupConsistency(backup)
+
+	versions, found := consistency.Resources[groupResource.String()]
+	if min, _ := parseResourceVersion(versions.Min); !found || version < min {
+		versions.Min = resourceVersion
+	}
+	if max, _ := parseResourceVersion(versions.Max); !found || version > max {
+		versions.Max = resourceVersion
+	}
+
+	if start, ok := parseResourceVersion(consistency.StartResourceVersion); ok && version > start {
+		versions.PotentiallyInconsistent = true
+		consistency.PotentiallyInconsistentItems = append(consistency.PotentiallyInconsistentItems, path.Join(groupResource.String(), namespace, name))
+	}
+
+	consistency.Resources[groupResource.String()] = versions
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/kuberesource"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestRecordResourceVersionsFlagsItemsChangedMidScrape(t *testing.T) {
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.ConsistencyMode = api.BackupConsistencyModeResourceVersion
+
+	// pods are listed first, when the cluster is at resourceVersion 100.
+	recordListResourceVersion(backup, "100")
+	recordItemResourceVersion(backup, kuberesource.Pods, "ns", "pod-1", "95")
+	recordItemResourceVersion(backup, kuberesource.Pods, "ns", "pod-2", "40")
+
+	// while the pods are being backed up, a PVC is modified, so by the time
+	// PVCs are listed it's at a later resourceVersion than the pods' list.
+	recordListResourceVersion(backup, "120")
+	recordItemResourceVersion(backup, kuberesource.PersistentVolumeClaims, "ns", "pvc-1", "60")
+	recordItemResourceVersion(backup, kuberesource.PersistentVolumeClaims, "ns", "pvc-2", "115")
+
+	// cluster-scoped items, and items whose resourceVersions aren't numbers,
+	// are tracked too.
+	recordListResourceVersion(backup, "130")
+	recordItemResourceVersion(backup, kuberesource.PersistentVolumes, "", "pv-1", "50")
+	recordItemResourceVersion(backup, kuberesource.PersistentVolumes, "", "pv-2", "not-a-number")
+
+	assert.Equal(t, &api.BackupConsistency{
+		StartResourceVersion: "100",
+		Resources: map[string]api.ResourceVersionRange{
+			"pods":                   {Min: "40", Max: "95"},
+			"persistentvolumeclaims": {Min: "60", Max: "115", PotentiallyInconsistent: true},
+			"persistentvolumes":      {Min: "50", Max: "50"},
+		},
+		PotentiallyInconsistentItems: []string{"persistentvolumeclaims/ns/pvc-2"},
+	}, backup.Status.Consistency)
+}
+
+func TestRecordResourceVersionsNotTracked(t *testing.T) {
+	for _, mode := range []api.BackupConsistencyMode{"", api.BackupConsistencyModeNone} {
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		backup.Spec.ConsistencyMode = mode
+
+		recordListResourceVersion(backup, "100")
+		recordItemResourceVersion(backup, kuberesource.Pods, "ns", "pod-1", "150")
+
+		assert.Nil(t, backup.Status.Consistency)
+	}
+}
@@ -52,6 +52,7 @@ type groupBackupperFactory interface {
 		blockStore cloudprovider.BlockStore,
 		resticBackupper restic.Backupper,
 		resticSnapshotTracker *pvcSnapshotTracker,
+		snapshotPool *snapshotPool,
 	) groupBackupper
 }
 
@@ -72,6 +73,7 @@ func (f *defaultGroupBackupperFactory) newGroupBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupper restic.Backupper,
 	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
 ) groupBackupper {
 	return &defaultGroupBackupper{
 		log:                      log,
@@ -89,6 +91,7 @@ func (f *defaultGroupBackupperFactory) newGroupBackupper(
 		blockStore:               blockStore,
 		resticBackupper:          resticBackupper,
 		resticSnapshotTracker:    resticSnapshotTracker,
+		snapshotPool:             snapshotPool,
 		resourceBackupperFactory: &defaultResourceBackupperFactory{},
 	}
 }
@@ -112,6 +115,7 @@ type defaultGroupBackupper struct {
 	blockStore               cloudprovider.BlockStore
 	resticBackupper          restic.Backupper
 	resticSnapshotTracker    *pvcSnapshotTracker
+	snapshotPool             *snapshotPool
 	resourceBackupperFactory resourceBackupperFactory
 }
 
@@ -136,6 +140,7 @@ func (gb *defaultGroupBackupper) backupGroup(group *metav1.APIResourceList) erro
 			gb.blockStore,
 			gb.resticBackupper,
 			gb.resticSnapshotTracker,
+			gb.snapshotPool,
 		)
 	)
 
@@ -90,6 +90,7 @@ func TestBackupGroup(t *testing.T) {
 		nil, // snapshot service
 		nil, // restic backupper
 		newPVCSnapshotTracker(),
+		nil, // snapshot pool
 	).(*defaultGroupBackupper)
 
 	resourceBackupperFactory := &mockResourceBackupperFactory{}
@@ -115,6 +116,7 @@ func TestBackupGroup(t *testing.T) {
 		nil,
 		mock.Anything, // restic backupper
 		mock.Anything, // pvc snapshot tracker
+		mock.Anything, // snapshot pool
 	).Return(resourceBackupper)
 
 	group := &metav1.APIResourceList{
@@ -164,6 +166,7 @@ func (rbf *mockResourceBackupperFactory) newResourceBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupper restic.Backupper,
 	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
 ) resourceBackupper {
 	args := rbf.Called(
 		log,
@@ -181,6 +184,7 @@ func (rbf *mockResourceBackupperFactory) newResourceBackupper(
 		blockStore,
 		resticBackupper,
 		resticSnapshotTracker,
+		snapshotPool,
 	)
 	return args.Get(0).(resourceBackupper)
 }
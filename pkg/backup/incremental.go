@@ -0,0 +1,108 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
+)
+
+// incrementalTarWriter is a tarWriter for incremental backups. It leaves
+// out the items whose resourceVersion hasn't changed since the base
+// backup, counting them in the backup's Status.UnchangedItems, and lists
+// them at archive.UnchangedItemsPath so that they can be restored from the
+// base backup.
+//
+// Each item's header is held until its contents are written, since its
+// resourceVersion is read from them, so each item's contents must be
+// written in a single call to Write.
+type incrementalTarWriter struct {
+	tarWriter
+
+	baseVersions archive.ResourceVersions
+	backup       *api.Backup
+	pending      *tar.Header
+	unchanged    []string
+}
+
+func newIncrementalTarWriter(tw tarWriter, backup *api.Backup, baseVersions archive.ResourceVersions) *incrementalTarWriter {
+	return &incrementalTarWriter{
+		tarWriter:    tw,
+		baseVersions: baseVersions,
+		backup:       backup,
+	}
+}
+
+func (w *incrementalTarWriter) WriteHeader(hdr *tar.Header) error {
+	if !strings.HasPrefix(hdr.Name, api.ResourcesDir+"/") {
+		return w.tarWriter.WriteHeader(hdr)
+	}
+
+	w.pending = hdr
+	return nil
+}
+
+func (w *incrementalTarWriter) Write(p []byte) (int, error) {
+	hdr := w.pending
+	if hdr == nil {
+		return w.tarWriter.Write(p)
+	}
+	w.pending = nil
+
+	resourceVersion, err := archive.ItemResourceVersion(bytes.NewReader(p))
+	if err != nil {
+		return 0, errors.WithMessage(err, "error reading resourceVersion of "+hdr.Name)
+	}
+
+	if w.baseVersions.Unchanged(hdr.Name, resourceVersion) {
+		w.unchanged = append(w.unchanged, hdr.Name)
+		w.backup.Status.UnchangedItems++
+		return len(p), nil
+	}
+
+	if err := w.tarWriter.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	return w.tarWriter.Write(p)
+}
+
+// writeUnchangedItems writes the list of the items that were left out of
+// the backup because they were unchanged.
+func (w *incrementalTarWriter) writeUnchangedItems() error {
+	contents := []byte(strings.Join(w.unchanged, "\n"))
+
+	hdr := &tar.Header{
+		Name:     archive.UnchangedItemsPath,
+		Size:     int64(len(contents)),
+		Typeflag: tar.TypeReg,
+		Mode:     0755,
+		ModTime:  time.Now(),
+	}
+	if err := w.tarWriter.WriteHeader(hdr); err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err := w.tarWriter.Write(contents)
+	return errors.WithStack(err)
+}
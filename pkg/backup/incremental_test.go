@@ -0,0 +1,84 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/heptio/ark/pkg/archive"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestIncrementalTarWriter(t *testing.T) {
+	var (
+		backup       = arktest.NewTestBackup().WithName("backup-1").Backup
+		tw           = &fakeTarWriter{}
+		baseVersions = archive.ResourceVersions{
+			"resources/pods/namespaces/ns-1/pod-1.json": "10",
+			"resources/pods/namespaces/ns-1/pod-2.json": "20",
+		}
+		w = newIncrementalTarWriter(tw, backup, baseVersions)
+	)
+
+	items := []struct {
+		path     string
+		contents string
+	}{
+		// unchanged since the base backup
+		{"resources/pods/namespaces/ns-1/pod-1.json", `{"metadata":{"name":"pod-1","resourceVersion":"10"}}`},
+		// changed since the base backup
+		{"resources/pods/namespaces/ns-1/pod-2.json", `{"metadata":{"name":"pod-2","resourceVersion":"21"}}`},
+		// created since the base backup
+		{"resources/pods/namespaces/ns-1/pod-3.json", `{"metadata":{"name":"pod-3","resourceVersion":"30"}}`},
+	}
+	for _, item := range items {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: item.path}))
+		_, err := w.Write([]byte(item.contents))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.writeUnchangedItems())
+
+	var written []string
+	for _, header := range tw.headers {
+		written = append(written, header.Name)
+	}
+	assert.Equal(t, []string{
+		"resources/pods/namespaces/ns-1/pod-2.json",
+		"resources/pods/namespaces/ns-1/pod-3.json",
+		archive.UnchangedItemsPath,
+	}, written)
+
+	require.Len(t, tw.data, 3)
+	assert.Equal(t, items[1].contents, string(tw.data[0]))
+	assert.Equal(t, items[2].contents, string(tw.data[1]))
+	assert.Equal(t, "resources/pods/namespaces/ns-1/pod-1.json", string(tw.data[2]))
+
+	assert.Equal(t, 1, backup.Status.UnchangedItems)
+}
+
+func TestIncrementalTarWriterInvalidItem(t *testing.T) {
+	w := newIncrementalTarWriter(&fakeTarWriter{}, arktest.NewTestBackup().Backup, archive.ResourceVersions{})
+
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "resources/pods/namespaces/ns-1/pod-1.json"}))
+	_, err := w.Write([]byte("not json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// ItemActionTimeoutError is returned by an action wrapped with
+// NewTimedItemAction when it doesn't finish executing within its timeout.
+// The item is backed up without the action's changes.
+type ItemActionTimeoutError struct {
+	Action  string
+	Timeout time.Duration
+}
+
+func (e *ItemActionTimeoutError) Error() string {
+	return fmt.Sprintf("backup item action %s didn't finish executing within %v", e.Action, e.Timeout)
+}
+
+// ItemActionObserver is called with the duration and result of each
+// execution of an action wrapped with NewTimedItemAction. It may be called
+// concurrently.
+type ItemActionObserver func(action string, duration time.Duration, err error)
+
+type timedItemAction struct {
+	ItemAction
+
+	name    string
+	timeout time.Duration
+	observe ItemActionObserver
+}
+
+// NewTimedItemAction wraps action so that each execution of it is passed
+// to observe, and, if timeout is positive, returns an
+// *ItemActionTimeoutError if it doesn't finish within timeout. An
+// execution that times out is abandoned rather than cancelled, since
+// actions can't be interrupted.
+func NewTimedItemAction(action ItemAction, name string, timeout time.Duration, observe ItemActionObserver) ItemAction {
+	return &timedItemAction{
+		ItemAction: action,
+		name:       name,
+		timeout:    timeout,
+		observe:    observe,
+	}
+}
+
+type itemActionResult struct {
+	item            runtime.Unstructured
+	additionalItems []ResourceIdentifier
+	err             error
+}
+
+func (a *timedItemAction) Execute(item runtime.Unstructured, backup *api.Backup) (runtime.Unstructured, []ResourceIdentifier, error) {
+	start := time.Now()
+
+	if a.timeout <= 0 {
+		updatedItem, additionalItems, err := a.ItemAction.Execute(item, backup)
+		a.observe(a.name, time.Since(start), err)
+		return updatedItem, additionalItems, err
+	}
+
+	// the action is given copies, so an abandoned execution can't modify
+	// the item or backup while they're used elsewhere, and the channel is
+	// buffered so that its goroutine can exit.
+	var (
+		itemCopy   = item.DeepCopyObject().(runtime.Unstructured)
+		backupCopy = backup.DeepCopy()
+		results    = make(chan itemActionResult, 1)
+	)
+	go func() {
+		var res itemActionResult
+		res.item, res.additionalItems, res.err = a.ItemAction.Execute(itemCopy, backupCopy)
+		results <- res
+	}()
+
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-results:
+		a.observe(a.name, time.Since(start), res.err)
+		return res.item, res.additionalItems, res.err
+	case <-timer.C:
+		err := &ItemActionTimeoutError{Action: a.name, Timeout: a.timeout}
+		a.observe(a.name, time.Since(start), err)
+		return nil, nil, err
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+// slowAction is an ItemAction whose executions don't finish until release
+// is closed.
+type slowAction struct {
+	release chan struct{}
+}
+
+func (a *slowAction) AppliesTo() (ResourceSelector, error) {
+	return ResourceSelector{}, nil
+}
+
+func (a *slowAction) Execute(item runtime.Unstructured, backup *v1.Backup) (runtime.Unstructured, []ResourceIdentifier, error) {
+	<-a.release
+	return item, nil, nil
+}
+
+type observation struct {
+	action string
+	err    error
+}
+
+func TestTimedItemAction(t *testing.T) {
+	var (
+		item   = arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"cm"}}`)
+		backup = arktest.NewTestBackup().WithName("backup-1").Backup
+	)
+
+	tests := []struct {
+		name          string
+		action        ItemAction
+		timeout       time.Duration
+		expectedErr   error
+		expectedItems int
+	}{
+		{
+			name:          "action that finishes within the timeout returns its results",
+			action:        newFakeAction("configmaps"),
+			timeout:       time.Minute,
+			expectedItems: 1,
+		},
+		{
+			name:          "action without a timeout returns its results",
+			action:        newFakeAction("configmaps"),
+			expectedItems: 1,
+		},
+		{
+			name:        "action that doesn't finish within the timeout returns a timeout error",
+			action:      &slowAction{release: make(chan struct{})},
+			timeout:     10 * time.Millisecond,
+			expectedErr: &ItemActionTimeoutError{Action: "my-action", Timeout: 10 * time.Millisecond},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if slow, ok := test.action.(*slowAction); ok {
+				defer close(slow.release)
+			}
+
+			var observations []observation
+			observe := func(action string, duration time.Duration, err error) {
+				observations = append(observations, observation{action: action, err: err})
+			}
+
+			updatedItem, _, err := NewTimedItemAction(test.action, "my-action", test.timeout, observe).Execute(item, backup)
+
+			assert.Equal(t, test.expectedErr, err)
+			if test.expectedErr == nil {
+				assert.Equal(t, item, updatedItem)
+			}
+
+			require.Len(t, observations, 1)
+			assert.Equal(t, "my-action", observations[0].action)
+			assert.Equal(t, test.expectedErr, observations[0].err)
+
+			if fake, ok := test.action.(*fakeAction); ok {
+				assert.Len(t, fake.ids, test.expectedItems)
+			}
+		})
+	}
+}
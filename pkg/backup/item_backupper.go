@@ -26,8 +26,10 @@ import (
 	"github.com/sirupsen/logrus"
 
 	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -41,6 +43,7 @@ import (
 	"github.com/heptio/ark/pkg/podexec"
 	"github.com/heptio/ark/pkg/restic"
 	"github.com/heptio/ark/pkg/util/collections"
+	"github.com/heptio/ark/pkg/util/stringslice"
 )
 
 type itemBackupperFactory interface {
@@ -57,6 +60,7 @@ type itemBackupperFactory interface {
 		blockStore cloudprovider.BlockStore,
 		resticBackupper restic.Backupper,
 		resticSnapshotTracker *pvcSnapshotTracker,
+		snapshotPool *snapshotPool,
 	) ItemBackupper
 }
 
@@ -75,6 +79,7 @@ func (f *defaultItemBackupperFactory) newItemBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupper restic.Backupper,
 	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
 ) ItemBackupper {
 	ib := &defaultItemBackupper{
 		backup:          backup,
@@ -89,9 +94,13 @@ func (f *defaultItemBackupperFactory) newItemBackupper(
 		blockStore:      blockStore,
 		itemHookHandler: &defaultItemHookHandler{
 			podCommandExecutor: podCommandExecutor,
+			backup:             backup,
+			snapshotPool:       snapshotPool,
 		},
 		resticBackupper:       resticBackupper,
 		resticSnapshotTracker: resticSnapshotTracker,
+		snapshotPool:          snapshotPool,
+		snapshotHookPods:      make(map[string][]runtime.Unstructured),
 	}
 
 	// this is for testing purposes
@@ -117,6 +126,14 @@ type defaultItemBackupper struct {
 	blockStore            cloudprovider.BlockStore
 	resticBackupper       restic.Backupper
 	resticSnapshotTracker *pvcSnapshotTracker
+	snapshotPool          *snapshotPool
+
+	// snapshotHookPods are the pods that have been backed up, by the
+	// namespace/name of each PVC they mount, so that their snapshot hooks
+	// can be run when the PVCs' PVs are snapshotted. A pod's PVCs and PVs
+	// are backed up as additional items of the pod, so by the same
+	// ItemBackupper.
+	snapshotHookPods map[string][]runtime.Unstructured
 
 	itemHookHandler         itemHookHandler
 	additionalItemBackupper ItemBackupper
@@ -145,6 +162,11 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 		return nil
 	}
 
+	if namespace != "" && isSkippedTerminatingNamespace(ib.backup, namespace) {
+		log.Info("Excluding item because namespace is terminating")
+		return nil
+	}
+
 	// NOTE: we specifically allow namespaces to be backed up even if IncludeClusterResources is
 	// false.
 	if namespace == "" && groupResource != kuberesource.Namespaces && ib.backup.Spec.IncludeClusterResources != nil && !*ib.backup.Spec.IncludeClusterResources {
@@ -157,6 +179,21 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 		return nil
 	}
 
+	// NOTE: the backup's label selector is applied when listing items, so an item that matches
+	// both it and the excluded label selector is excluded.
+	if selector := ib.backup.Spec.ExcludedLabelSelector; selector != nil {
+		excludedLabels, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			// This should never happen, since the selector is validated before the backup runs.
+			return errors.Wrap(err, "invalid excluded label selector")
+		}
+
+		if excludedLabels.Matches(labels.Set(metadata.GetLabels())) {
+			log.Info("Excluding item because it matches the backup's excluded label selector")
+			return nil
+		}
+	}
+
 	if metadata.GetDeletionTimestamp() != nil {
 		log.Info("Skipping item because it's being deleted.")
 		return nil
@@ -173,6 +210,12 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 	}
 	ib.backedUpItems[key] = struct{}{}
 
+	if groupResource == kuberesource.Secrets && !ib.backup.Spec.IncludeServiceAccountTokens && isServiceAccountToken(obj) {
+		log.Info("Skipping item because it's a service account token secret.")
+		ib.backup.Status.SkippedItems++
+		return nil
+	}
+
 	log.Info("Backing up resource")
 
 	log.Debug("Executing pre hooks")
@@ -184,6 +227,7 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 		backupErrs            []error
 		pod                   *corev1api.Pod
 		resticVolumesToBackup []string
+		podPVs                map[string]*unstructured.Unstructured
 	)
 
 	if groupResource == kuberesource.Pods {
@@ -199,7 +243,34 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 			// PVs that will have their data backed up with restic.
 			resticVolumesToBackup = restic.GetVolumesToBackup(pod)
 
+			// if the backup copies the files of volumes that can't be snapshotted, add any of the pod's
+			// PVC volumes whose PVs can't be snapshotted to the volumes to backup using restic.
+			if ib.backup.Spec.FileCopyVolumes {
+				var err error
+				if podPVs, err = ib.getPodPVs(pod); err != nil {
+					backupErrs = append(backupErrs, err)
+				}
+
+				for _, volume := range pod.Spec.Volumes {
+					pv, found := podPVs[volume.Name]
+					if !found || stringslice.Has(resticVolumesToBackup, volume.Name) {
+						continue
+					}
+
+					snapshottable, err := ib.isSnapshottable(pv)
+					if err != nil {
+						backupErrs = append(backupErrs, err)
+						continue
+					}
+					if !snapshottable {
+						log.Infof("Copying files of volume %s because its PersistentVolume %s can't be snapshotted", volume.Name, pv.GetName())
+						resticVolumesToBackup = append(resticVolumesToBackup, volume.Name)
+					}
+				}
+			}
+
 			ib.resticSnapshotTracker.Track(pod, resticVolumesToBackup)
+			ib.trackSnapshotHookPod(pod, obj)
 		}
 	}
 
@@ -237,6 +308,12 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 		// annotate the pod with the successful volume snapshots
 		for volume, snapshot := range volumeSnapshots {
 			restic.SetPodSnapshotAnnotation(metadata, volume, snapshot)
+
+			if pv, found := podPVs[volume]; found {
+				ib.snapshotPool.withStatusLock(func() {
+					setVolumeBackupMethod(ib.backup, pv.GetName(), api.VolumeBackupMethodFileCopy)
+				})
+			}
 		}
 
 		backupErrs = append(backupErrs, errs...)
@@ -258,11 +335,37 @@ func (ib *defaultItemBackupper) backupItem(logger logrus.FieldLogger, obj runtim
 		filePath = filepath.Join(api.ResourcesDir, groupResource.String(), api.ClusterScopedDir, name+".json")
 	}
 
+	// the item's resourceVersion is read before it's transformed, since
+	// its transforms may remove it.
+	resourceVersion := metadata.GetResourceVersion()
+
+	if len(ib.backup.Spec.ItemTransforms) > 0 {
+		transforms, err := parseItemTransforms(ib.backup.Spec.ItemTransforms)
+		if err != nil {
+			return err
+		}
+		applyItemTransforms(obj.UnstructuredContent(), transforms)
+	}
+
 	itemBytes, err := json.Marshal(obj.UnstructuredContent())
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	if max := ib.backup.Spec.MaxItemSizeBytes; max > 0 && int64(len(itemBytes)) > max {
+		log.Warnf("Skipping item because its size of %d bytes is larger than the backup's maximum item size of %d bytes", len(itemBytes), max)
+		ib.backup.Status.SkippedItems++
+		ib.backup.Status.SkippedLargeItems = append(ib.backup.Status.SkippedLargeItems, api.SkippedLargeItem{
+			GroupResource: groupResource.String(),
+			Namespace:     namespace,
+			Name:          name,
+			SizeBytes:     int64(len(itemBytes)),
+		})
+		return nil
+	}
+
+	recordItemResourceVersion(ib.backup, groupResource, namespace, name, resourceVersion)
+
 	hdr := &tar.Header{
 		Name:     filePath,
 		Size:     int64(len(itemBytes)),
@@ -289,12 +392,110 @@ func (ib *defaultItemBackupper) backupPodVolumes(log logrus.FieldLogger, pod *co
 		return nil, nil
 	}
 
+	if ib.backup.Spec.DryRun {
+		log.Info("Backup is a dry run, not backing up pod's volumes")
+		return nil, nil
+	}
+
 	if ib.resticBackupper == nil {
 		log.Warn("No restic backupper, not backing up pod's volumes")
 		return nil, nil
 	}
 
-	return ib.resticBackupper.BackupPodVolumes(ib.backup, pod, log)
+	return ib.resticBackupper.BackupPodVolumes(ib.backup, pod, volumes, log)
+}
+
+// getPodPVs returns a map of the names of the pod's PersistentVolumeClaim volumes to the
+// PersistentVolumes bound to their claims. Volumes whose claims aren't bound are left out.
+func (ib *defaultItemBackupper) getPodPVs(pod *corev1api.Pod) (map[string]*unstructured.Unstructured, error) {
+	pvs := make(map[string]*unstructured.Unstructured)
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		pvc, err := ib.getItem(kuberesource.PersistentVolumeClaims, pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		if err != nil {
+			return pvs, errors.Wrapf(err, "error getting PersistentVolumeClaim %s/%s", pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		}
+
+		pvName, _, err := unstructured.NestedString(pvc.UnstructuredContent(), "spec", "volumeName")
+		if err != nil {
+			return pvs, errors.WithStack(err)
+		}
+		if pvName == "" {
+			continue
+		}
+
+		pv, err := ib.getItem(kuberesource.PersistentVolumes, "", pvName)
+		if err != nil {
+			return pvs, errors.Wrapf(err, "error getting PersistentVolume %s", pvName)
+		}
+
+		pvs[volume.Name] = pv
+	}
+
+	return pvs, nil
+}
+
+// getItem gets an item from the API server.
+func (ib *defaultItemBackupper) getItem(groupResource schema.GroupResource, namespace, name string) (*unstructured.Unstructured, error) {
+	gvr, resource, err := ib.discoveryHelper.ResourceFor(groupResource.WithVersion(""))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ib.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), resource, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Get(name, metav1.GetOptions{})
+}
+
+// matchesVolumeSnapshotExcludeSelector returns whether the labels of the
+// PersistentVolume, or of the PersistentVolumeClaim bound to it, match
+// selector.
+func (ib *defaultItemBackupper) matchesVolumeSnapshotExcludeSelector(selector *metav1.LabelSelector, pv *corev1api.PersistentVolume) (bool, error) {
+	excludedLabels, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		// This should never happen, since the selector is validated before the backup runs.
+		return false, errors.Wrap(err, "invalid volume snapshot exclude selector")
+	}
+
+	if excludedLabels.Matches(labels.Set(pv.Labels)) {
+		return true, nil
+	}
+
+	if pv.Spec.ClaimRef == nil {
+		return false, nil
+	}
+
+	pvc, err := ib.getItem(kuberesource.PersistentVolumeClaims, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+	if apierrors.IsNotFound(err) {
+		// the PV's claim has been deleted, so only the PV's labels apply.
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting PersistentVolumeClaim %s/%s", pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+	}
+
+	return excludedLabels.Matches(labels.Set(pvc.GetLabels())), nil
+}
+
+// isSnapshottable returns true if the backup's block store can snapshot the PersistentVolume.
+func (ib *defaultItemBackupper) isSnapshottable(pv runtime.Unstructured) (bool, error) {
+	if ib.blockStore == nil {
+		return false, nil
+	}
+
+	volumeID, err := ib.blockStore.GetVolumeID(pv)
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting volume ID for PersistentVolume")
+	}
+
+	return volumeID != "", nil
 }
 
 func (ib *defaultItemBackupper) executeActions(
@@ -323,6 +524,12 @@ func (ib *defaultItemBackupper) executeActions(
 		log.Info("Executing custom action")
 
 		updatedItem, additionalItemIdentifiers, err := action.Execute(obj, ib.backup)
+		if timeoutErr, ok := errors.Cause(err).(*ItemActionTimeoutError); ok {
+			// A hung action shouldn't fail the item, so it's backed up
+			// without the action's changes.
+			log.WithError(timeoutErr).Warn("Skipping custom action because it timed out")
+			continue
+		}
 		if err != nil {
 			// We want this to show up in the log file at the place where the error occurs. When we return
 			// the error, it get aggregated with all the other ones at the end of the backup, making it
@@ -373,6 +580,11 @@ func (ib *defaultItemBackupper) takePVSnapshot(obj runtime.Unstructured, backup
 		return nil
 	}
 
+	if backup.Spec.DryRun {
+		log.Info("Backup is a dry run; skipping volume snapshot action.")
+		return nil
+	}
+
 	pv := new(corev1api.PersistentVolume)
 	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), pv); err != nil {
 		return errors.WithStack(err)
@@ -387,6 +599,17 @@ func (ib *defaultItemBackupper) takePVSnapshot(obj runtime.Unstructured, backup
 		}
 	}
 
+	if selector := backup.Spec.VolumeSnapshotExcludeSelector; selector != nil {
+		excluded, err := ib.matchesVolumeSnapshotExcludeSelector(selector, pv)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			log.Info("Skipping Persistent Volume snapshot because it matches the backup's volume snapshot exclude selector.")
+			return nil
+		}
+	}
+
 	metadata, err := meta.Accessor(obj)
 	if err != nil {
 		return errors.WithStack(err)
@@ -418,6 +641,69 @@ func (ib *defaultItemBackupper) takePVSnapshot(obj runtime.Unstructured, backup
 		"ark.heptio.com/pv":     metadata.GetName(),
 	}
 
+	// the pods that mount the PV's claim have their snapshot hooks run
+	// around the snapshot.
+	var pods []runtime.Unstructured
+	if pv.Spec.ClaimRef != nil {
+		pods = ib.snapshotHookPods[key(pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)]
+	}
+
+	// the snapshot is taken by the backup's snapshot pool, which may run it
+	// in the background. Its errors are then returned when the backup's
+	// snapshots are waited for, rather than from here.
+	return ib.snapshotPool.run(func() error {
+		var errs []error
+
+		// a failed pre-snapshot hook whose OnError is Fail means the volume
+		// isn't in a state to be snapshotted, so it isn't. The post-snapshot
+		// hooks are run regardless, to undo whatever the pre-snapshot hooks did.
+		if err := ib.runSnapshotHooks(log, pods, hookPhasePreSnapshot); err != nil {
+			log.WithError(err).Error("Skipping Persistent Volume snapshot because a pre-snapshot hook failed")
+			errs = append(errs, errors.WithMessage(err, "error running pre-snapshot hook"))
+		} else if err := ib.createSnapshot(log, backup, name, volumeID, pvFailureDomainZone, tags); err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := ib.runSnapshotHooks(log, pods, hookPhasePostSnapshot); err != nil {
+			errs = append(errs, errors.WithMessage(err, "error running post-snapshot hook"))
+		}
+
+		return kubeerrs.NewAggregate(errs)
+	})
+}
+
+// trackSnapshotHookPod records obj, the item for pod, as a pod whose
+// snapshot hooks are run when its PVCs' PVs are snapshotted.
+func (ib *defaultItemBackupper) trackSnapshotHookPod(pod *corev1api.Pod, obj runtime.Unstructured) {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		claimKey := key(pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		ib.snapshotHookPods[claimKey] = append(ib.snapshotHookPods[claimKey], obj)
+	}
+}
+
+// runSnapshotHooks runs the hooks for phase in each of pods. Pre-snapshot
+// hooks stop at the first pod whose hooks fail; post-snapshot hooks are run
+// in every pod.
+func (ib *defaultItemBackupper) runSnapshotHooks(log logrus.FieldLogger, pods []runtime.Unstructured, phase hookPhase) error {
+	var errs []error
+	for _, pod := range pods {
+		if err := ib.itemHookHandler.handleHooks(log, kuberesource.Pods, pod, ib.resourceHooks, phase); err != nil {
+			if phase == hookPhasePreSnapshot {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return kubeerrs.NewAggregate(errs)
+}
+
+// createSnapshot snapshots the volume underlying the PersistentVolume pvName,
+// and records the snapshot in the backup's status.
+func (ib *defaultItemBackupper) createSnapshot(log logrus.FieldLogger, backup *api.Backup, pvName, volumeID, pvFailureDomainZone string, tags map[string]string) error {
 	log.Info("Snapshotting PersistentVolume")
 	snapshotID, err := ib.blockStore.CreateSnapshot(volumeID, pvFailureDomainZone, tags)
 	if err != nil {
@@ -432,16 +718,36 @@ func (ib *defaultItemBackupper) takePVSnapshot(obj runtime.Unstructured, backup
 		return errors.WithMessage(err, "error getting volume info")
 	}
 
-	if backup.Status.VolumeBackups == nil {
-		backup.Status.VolumeBackups = make(map[string]*api.VolumeBackupInfo)
-	}
+	ib.snapshotPool.withStatusLock(func() {
+		if backup.Status.VolumeBackups == nil {
+			backup.Status.VolumeBackups = make(map[string]*api.VolumeBackupInfo)
+		}
 
-	backup.Status.VolumeBackups[name] = &api.VolumeBackupInfo{
-		SnapshotID:       snapshotID,
-		Type:             volumeType,
-		Iops:             iops,
-		AvailabilityZone: pvFailureDomainZone,
-	}
+		backup.Status.VolumeBackups[pvName] = &api.VolumeBackupInfo{
+			SnapshotID:       snapshotID,
+			Type:             volumeType,
+			Iops:             iops,
+			AvailabilityZone: pvFailureDomainZone,
+		}
+		setVolumeBackupMethod(backup, pvName, api.VolumeBackupMethodSnapshot)
+	})
 
 	return nil
 }
+
+// setVolumeBackupMethod records the method used to back up a PersistentVolume's data
+// in the backup's status.
+func setVolumeBackupMethod(backup *api.Backup, pvName string, method api.VolumeBackupMethod) {
+	if backup.Status.VolumeBackupMethods == nil {
+		backup.Status.VolumeBackupMethods = make(map[string]api.VolumeBackupMethod)
+	}
+	backup.Status.VolumeBackupMethods[pvName] = method
+}
+
+// isServiceAccountToken returns true if the given secret is of type
+// kubernetes.io/service-account-token. Token secrets are regenerated
+// by the target cluster, so there's no need to back them up.
+func isServiceAccountToken(obj runtime.Unstructured) bool {
+	secretType, _, _ := unstructured.NestedString(obj.UnstructuredContent(), "type")
+	return secretType == string(corev1api.SecretTypeServiceAccountToken)
+}
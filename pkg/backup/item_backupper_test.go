@@ -21,11 +21,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/heptio/ark/pkg/apis/ark/v1"
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/kuberesource"
 	resticmocks "github.com/heptio/ark/pkg/restic/mocks"
 	"github.com/heptio/ark/pkg/util/collections"
 	arktest "github.com/heptio/ark/pkg/util/test"
@@ -35,6 +37,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -46,14 +49,15 @@ import (
 
 func TestBackupItemSkips(t *testing.T) {
 	tests := []struct {
-		testName      string
-		namespace     string
-		name          string
-		namespaces    *collections.IncludesExcludes
-		groupResource schema.GroupResource
-		resources     *collections.IncludesExcludes
-		terminating   bool
-		backedUpItems map[itemKey]struct{}
+		testName             string
+		namespace            string
+		name                 string
+		namespaces           *collections.IncludesExcludes
+		groupResource        schema.GroupResource
+		resources            *collections.IncludesExcludes
+		terminating          bool
+		terminatingNamespace bool
+		backedUpItems        map[itemKey]struct{}
 	}{
 		{
 			testName:   "namespace not in includes list",
@@ -103,12 +107,26 @@ func TestBackupItemSkips(t *testing.T) {
 			resources:     collections.NewIncludesExcludes(),
 			terminating:   true,
 		},
+		{
+			testName:             "namespace is terminating",
+			namespace:            "ns",
+			name:                 "foo",
+			groupResource:        schema.GroupResource{Group: "foo", Resource: "bar"},
+			namespaces:           collections.NewIncludesExcludes(),
+			resources:            collections.NewIncludesExcludes(),
+			terminatingNamespace: true,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.testName, func(t *testing.T) {
+			backup := new(v1.Backup)
+			if test.terminatingNamespace {
+				backup.Status.SkippedTerminatingNamespaces = []string{test.namespace}
+			}
 
 			ib := &defaultItemBackupper{
+				backup:        backup,
 				namespaces:    test.namespaces,
 				resources:     test.resources,
 				backedUpItems: test.backedUpItems,
@@ -148,6 +166,54 @@ func TestBackupItemSkipsClusterScopedResourceWhenIncludeClusterResourcesFalse(t
 	assert.NoError(t, err)
 }
 
+func TestBackupItemSkipsServiceAccountTokens(t *testing.T) {
+	backup := &v1.Backup{}
+	ib := &defaultItemBackupper{
+		backup:        backup,
+		namespaces:    collections.NewIncludesExcludes(),
+		resources:     collections.NewIncludesExcludes(),
+		backedUpItems: make(map[itemKey]struct{}),
+	}
+
+	u := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Secret","metadata":{"namespace":"ns","name":"default-token-abcde"},"type":"kubernetes.io/service-account-token"}`)
+	require.NoError(t, ib.backupItem(arktest.NewLogger(), u, kuberesource.Secrets))
+	assert.Equal(t, 1, backup.Status.SkippedItems)
+
+	// backing up the same item again shouldn't count it twice
+	require.NoError(t, ib.backupItem(arktest.NewLogger(), u, kuberesource.Secrets))
+	assert.Equal(t, 1, backup.Status.SkippedItems)
+}
+
+func TestIsServiceAccountToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      string
+		expected bool
+	}{
+		{
+			name:     "service account token secret",
+			obj:      `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"foo"},"type":"kubernetes.io/service-account-token"}`,
+			expected: true,
+		},
+		{
+			name:     "opaque secret",
+			obj:      `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"foo"},"type":"Opaque"}`,
+			expected: false,
+		},
+		{
+			name:     "secret without a type",
+			obj:      `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"foo"}}`,
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isServiceAccountToken(arktest.UnstructuredOrDie(test.obj)))
+		})
+	}
+}
+
 func TestBackupItemNoSkips(t *testing.T) {
 	tests := []struct {
 		name                                  string
@@ -418,6 +484,7 @@ func TestBackupItemNoSkips(t *testing.T) {
 				nil, // snapshot service
 				nil, // restic backupper
 				newPVCSnapshotTracker(),
+				nil,
 			).(*defaultItemBackupper)
 
 			var blockStore *arktest.FakeBlockStore
@@ -600,6 +667,7 @@ func TestItemActionModificationsToItemPersist(t *testing.T) {
 			nil,
 			nil,
 			newPVCSnapshotTracker(),
+			nil,
 		).(*defaultItemBackupper)
 	)
 
@@ -619,6 +687,233 @@ func TestItemActionModificationsToItemPersist(t *testing.T) {
 	assert.EqualValues(t, expected.Object, actual)
 }
 
+func TestBackupItemSkipsTimedOutActions(t *testing.T) {
+	var (
+		w    = &fakeTarWriter{}
+		slow = &slowAction{release: make(chan struct{})}
+		obj  = arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"cm"}}`)
+	)
+	defer close(slow.release)
+
+	actions := []resolvedAction{
+		{
+			ItemAction:                NewTimedItemAction(slow, "slow", 10*time.Millisecond, func(string, time.Duration, error) {}),
+			namespaceIncludesExcludes: collections.NewIncludesExcludes(),
+			resourceIncludesExcludes:  collections.NewIncludesExcludes(),
+			selector:                  labels.Everything(),
+		},
+		{
+			ItemAction:                &addAnnotationAction{},
+			namespaceIncludesExcludes: collections.NewIncludesExcludes(),
+			resourceIncludesExcludes:  collections.NewIncludesExcludes(),
+			selector:                  labels.Everything(),
+		},
+	}
+
+	b := (&defaultItemBackupperFactory{}).newItemBackupper(
+		&v1.Backup{},
+		collections.NewIncludesExcludes(),
+		collections.NewIncludesExcludes(),
+		make(map[itemKey]struct{}),
+		actions,
+		nil,
+		w,
+		nil,
+		&arktest.FakeDynamicFactory{},
+		arktest.NewFakeDiscoveryHelper(true, nil),
+		nil,
+		nil,
+		newPVCSnapshotTracker(),
+		nil,
+	).(*defaultItemBackupper)
+
+	require.NoError(t, b.backupItem(arktest.NewLogger(), obj, schema.ParseGroupResource("configmaps")))
+
+	// the item is still backed up, with the changes of the actions that
+	// didn't time out.
+	require.Len(t, w.data, 1)
+	actual, err := arktest.GetAsMap(string(w.data[0]))
+	require.NoError(t, err)
+
+	expected := obj.DeepCopy()
+	expected.SetAnnotations(map[string]string{"foo": "bar"})
+	assert.EqualValues(t, expected.Object, actual)
+}
+
+func TestBackupItemSkipsLargeItems(t *testing.T) {
+	var (
+		w      = &fakeTarWriter{}
+		backup = &v1.Backup{Spec: v1.BackupSpec{MaxItemSizeBytes: 100}}
+		b      = (&defaultItemBackupperFactory{}).newItemBackupper(
+			backup,
+			collections.NewIncludesExcludes(),
+			collections.NewIncludesExcludes(),
+			make(map[itemKey]struct{}),
+			nil,
+			nil,
+			w,
+			nil,
+			&arktest.FakeDynamicFactory{},
+			arktest.NewFakeDiscoveryHelper(true, nil),
+			nil,
+			nil,
+			newPVCSnapshotTracker(),
+			nil,
+		).(*defaultItemBackupper)
+	)
+
+	small := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"small"}}`)
+	large := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"large"},"data":{"key":"` + strings.Repeat("x", 100) + `"}}`)
+
+	require.NoError(t, b.backupItem(arktest.NewLogger(), small, schema.ParseGroupResource("configmaps")))
+	require.NoError(t, b.backupItem(arktest.NewLogger(), large, schema.ParseGroupResource("configmaps")))
+
+	require.Len(t, w.headers, 1)
+	assert.Equal(t, "resources/configmaps/namespaces/ns/small.json", w.headers[0].Name)
+
+	assert.Equal(t, 1, backup.Status.SkippedItems)
+	require.Len(t, backup.Status.SkippedLargeItems, 1)
+	skipped := backup.Status.SkippedLargeItems[0]
+	assert.Equal(t, "configmaps", skipped.GroupResource)
+	assert.Equal(t, "ns", skipped.Namespace)
+	assert.Equal(t, "large", skipped.Name)
+	assert.True(t, skipped.SizeBytes > 100)
+}
+
+func TestBackupItemAppliesItemTransforms(t *testing.T) {
+	var (
+		w      = &fakeTarWriter{}
+		backup = &v1.Backup{Spec: v1.BackupSpec{ItemTransforms: []v1.ItemTransform{
+			{Remove: "{.status}"},
+			{Remove: "{.metadata.annotations['secret.example.com/token']}"},
+		}}}
+		b = (&defaultItemBackupperFactory{}).newItemBackupper(
+			backup,
+			collections.NewIncludesExcludes(),
+			collections.NewIncludesExcludes(),
+			make(map[itemKey]struct{}),
+			nil,
+			nil,
+			w,
+			nil,
+			&arktest.FakeDynamicFactory{},
+			arktest.NewFakeDiscoveryHelper(true, nil),
+			nil,
+			nil,
+			newPVCSnapshotTracker(),
+			nil,
+		).(*defaultItemBackupper)
+	)
+
+	obj := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Service","metadata":{"namespace":"ns","name":"svc-1","annotations":{"secret.example.com/token":"hunter2","app":"foo"}},"spec":{"type":"ClusterIP"},"status":{"loadBalancer":{}}}`)
+	require.NoError(t, b.backupItem(arktest.NewLogger(), obj, schema.ParseGroupResource("services")))
+
+	require.Len(t, w.headers, 1)
+	require.Len(t, w.data, 1)
+	assert.Equal(t, int64(len(w.data[0])), w.headers[0].Size)
+
+	written := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(w.data[0], &written))
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"namespace":   "ns",
+			"name":        "svc-1",
+			"annotations": map[string]interface{}{"app": "foo"},
+		},
+		"spec": map[string]interface{}{"type": "ClusterIP"},
+	}, written)
+}
+
+func TestBackupItemExcludedLabelSelector(t *testing.T) {
+	tests := []struct {
+		name            string
+		labelSelector   *metav1.LabelSelector
+		excludeSelector *metav1.LabelSelector
+		expected        []string
+	}{
+		{
+			name:     "no excluded label selector backs up all items",
+			expected: []string{"plain", "app", "excluded", "app-excluded"},
+		},
+		{
+			name:            "items matching the excluded label selector are skipped",
+			excludeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"skip": "true"}},
+			expected:        []string{"plain", "app"},
+		},
+		{
+			name:            "items matching both label selectors are skipped",
+			labelSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			excludeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"skip": "true"}},
+			expected:        []string{"app"},
+		},
+		{
+			name: "items matching an excluded set-based selector are skipped",
+			excludeSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: metav1.LabelSelectorOpExists},
+				},
+			},
+			expected: []string{"plain", "excluded"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				w      = &fakeTarWriter{}
+				backup = &v1.Backup{Spec: v1.BackupSpec{LabelSelector: test.labelSelector, ExcludedLabelSelector: test.excludeSelector}}
+				b      = (&defaultItemBackupperFactory{}).newItemBackupper(
+					backup,
+					collections.NewIncludesExcludes(),
+					collections.NewIncludesExcludes(),
+					make(map[itemKey]struct{}),
+					nil,
+					nil,
+					w,
+					nil,
+					&arktest.FakeDynamicFactory{},
+					arktest.NewFakeDiscoveryHelper(true, nil),
+					nil,
+					nil,
+					newPVCSnapshotTracker(),
+					nil,
+				).(*defaultItemBackupper)
+			)
+
+			labelSelector := labels.Everything()
+			if test.labelSelector != nil {
+				var err error
+				labelSelector, err = metav1.LabelSelectorAsSelector(test.labelSelector)
+				require.NoError(t, err)
+			}
+
+			for _, data := range []string{
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"plain"}}`,
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"app","labels":{"app":"foo"}}}`,
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"excluded","labels":{"skip":"true"}}}`,
+				`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"app-excluded","labels":{"app":"foo","skip":"true"}}}`,
+			} {
+				// the backup's label selector is applied when listing
+				// items, so backupItem is only passed items that match it.
+				item := arktest.UnstructuredOrDie(data)
+				if !labelSelector.Matches(labels.Set(item.GetLabels())) {
+					continue
+				}
+
+				require.NoError(t, b.backupItem(arktest.NewLogger(), item, schema.ParseGroupResource("configmaps")))
+			}
+
+			var res []string
+			for _, header := range w.headers {
+				res = append(res, strings.TrimSuffix(strings.TrimPrefix(header.Name, "resources/configmaps/namespaces/ns/"), ".json"))
+			}
+			assert.Equal(t, test.expected, res)
+		})
+	}
+}
+
 func TestResticAnnotationsPersist(t *testing.T) {
 	var (
 		w   = &fakeTarWriter{}
@@ -656,11 +951,12 @@ func TestResticAnnotationsPersist(t *testing.T) {
 			nil,
 			resticBackupper,
 			newPVCSnapshotTracker(),
+			nil,
 		).(*defaultItemBackupper)
 	)
 
 	resticBackupper.
-		On("BackupPodVolumes", mock.Anything, mock.Anything, mock.Anything).
+		On("BackupPodVolumes", mock.Anything, mock.Anything, []string{"volume-1", "volume-2"}, mock.Anything).
 		Return(map[string]string{"volume-1": "snapshot-1", "volume-2": "snapshot-2"}, nil)
 
 	// our expected backed-up object is the passed-in object, plus the annotation
@@ -687,12 +983,67 @@ func TestResticAnnotationsPersist(t *testing.T) {
 	assert.EqualValues(t, expected.Object, actual)
 }
 
+func TestBackupItemFileCopiesUnsnapshottableVolumes(t *testing.T) {
+	var (
+		w               = &fakeTarWriter{}
+		backup          = &v1.Backup{Spec: v1.BackupSpec{FileCopyVolumes: true}}
+		dynamicFactory  = &arktest.FakeDynamicFactory{}
+		resticBackupper = &resticmocks.Backupper{}
+		b               = (&defaultItemBackupperFactory{}).newItemBackupper(
+			backup,
+			collections.NewIncludesExcludes(),
+			collections.NewIncludesExcludes(),
+			make(map[itemKey]struct{}),
+			nil,
+			nil,
+			w,
+			nil,
+			dynamicFactory,
+			arktest.NewFakeDiscoveryHelper(true, nil),
+			nil,
+			resticBackupper,
+			newPVCSnapshotTracker(),
+			nil,
+		).(*defaultItemBackupper)
+	)
+
+	pod := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns","name":"pod-1"},"spec":{"volumes":[` +
+		`{"name":"data","persistentVolumeClaim":{"claimName":"pvc-1"}},` +
+		`{"name":"unbound","persistentVolumeClaim":{"claimName":"pvc-2"}},` +
+		`{"name":"scratch","emptyDir":{}}]}}`)
+
+	pvcClient := &arktest.FakeDynamicClient{}
+	pvcClient.On("Get", "pvc-1", metav1.GetOptions{}).Return(arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"namespace":"ns","name":"pvc-1"},"spec":{"volumeName":"pv-1"}}`), nil)
+	pvcClient.On("Get", "pvc-2", metav1.GetOptions{}).Return(arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"namespace":"ns","name":"pvc-2"}}`), nil)
+	dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{}, metav1.APIResource{Name: "persistentvolumeclaims"}, "ns").Return(pvcClient, nil)
+
+	pvClient := &arktest.FakeDynamicClient{}
+	pvClient.On("Get", "pv-1", metav1.GetOptions{}).Return(arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-1"},"spec":{"nfs":{"server":"nfs","path":"/data"}}}`), nil)
+	dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{}, metav1.APIResource{Name: "persistentvolumes"}, "").Return(pvClient, nil)
+
+	resticBackupper.
+		On("BackupPodVolumes", backup, mock.Anything, []string{"data"}, mock.Anything).
+		Return(map[string]string{"data": "snapshot-1"}, nil)
+
+	require.NoError(t, b.backupItem(arktest.NewLogger(), pod, kuberesource.Pods))
+
+	resticBackupper.AssertExpectations(t)
+	assert.True(t, b.resticSnapshotTracker.Has("ns", "pvc-1"))
+	assert.Equal(t, map[string]v1.VolumeBackupMethod{"pv-1": v1.VolumeBackupMethodFileCopy}, backup.Status.VolumeBackupMethods)
+
+	require.Len(t, w.data, 1)
+	actual, err := arktest.GetAsMap(string(w.data[0]))
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot-1", actual["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})["snapshot.ark.heptio.com/data"])
+}
+
 func TestTakePVSnapshot(t *testing.T) {
 	iops := int64(1000)
 
 	tests := []struct {
 		name                   string
 		snapshotEnabled        bool
+		dryRun                 bool
 		pv                     string
 		ttl                    time.Duration
 		expectError            bool
@@ -751,6 +1102,16 @@ func TestTakePVSnapshot(t *testing.T) {
 				"pd-abc123": {Type: "gp", SnapshotID: "snap-1"},
 			},
 		},
+		{
+			name:             "dry run",
+			snapshotEnabled:  true,
+			dryRun:           true,
+			pv:               `{"apiVersion": "v1", "kind": "PersistentVolume", "metadata": {"name": "mypv"}, "spec": {"gcePersistentDisk": {"pdName": "pd-abc123"}}}`,
+			expectedVolumeID: "pd-abc123",
+			volumeInfo: map[string]v1.VolumeBackupInfo{
+				"pd-abc123": {Type: "gp", SnapshotID: "snap-1"},
+			},
+		},
 		{
 			name:             "create snapshot error",
 			snapshotEnabled:  true,
@@ -782,6 +1143,7 @@ func TestTakePVSnapshot(t *testing.T) {
 				Spec: v1.BackupSpec{
 					SnapshotVolumes: &test.snapshotEnabled,
 					TTL:             metav1.Duration{Duration: test.ttl},
+					DryRun:          test.dryRun,
 				},
 				Status: v1.BackupStatus{
 					VolumeBackups: test.existingVolumeBackups,
@@ -845,6 +1207,63 @@ func TestTakePVSnapshot(t *testing.T) {
 	}
 }
 
+func TestTakePVSnapshotVolumeSnapshotExcludeSelector(t *testing.T) {
+	var (
+		snapshotVolumes = true
+		backup          = &v1.Backup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "mybackup"},
+			Spec: v1.BackupSpec{
+				SnapshotVolumes:               &snapshotVolumes,
+				VolumeSnapshotExcludeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"snapshot": "skip"}},
+			},
+		}
+		blockStore = &arktest.FakeBlockStore{
+			SnapshottableVolumes: map[string]v1.VolumeBackupInfo{
+				"vol-plain":       {SnapshotID: "snap-plain"},
+				"vol-labeled":     {SnapshotID: "snap-labeled"},
+				"vol-claimed":     {SnapshotID: "snap-claimed"},
+				"vol-pvc-labeled": {SnapshotID: "snap-pvc-labeled"},
+				"vol-unclaimed":   {SnapshotID: "snap-unclaimed"},
+			},
+		}
+		dynamicFactory = &arktest.FakeDynamicFactory{}
+		ib             = &defaultItemBackupper{
+			blockStore:            blockStore,
+			dynamicFactory:        dynamicFactory,
+			discoveryHelper:       arktest.NewFakeDiscoveryHelper(true, nil),
+			resticSnapshotTracker: newPVCSnapshotTracker(),
+		}
+	)
+
+	pvcClient := &arktest.FakeDynamicClient{}
+	pvcClient.On("Get", "claimed", metav1.GetOptions{}).Return(arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"namespace":"ns","name":"claimed","labels":{"app":"db"}}}`), nil)
+	pvcClient.On("Get", "pvc-labeled", metav1.GetOptions{}).Return(arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"namespace":"ns","name":"pvc-labeled","labels":{"snapshot":"skip"}}}`), nil)
+	pvcClient.On("Get", "deleted", metav1.GetOptions{}).Return((*unstructured.Unstructured)(nil), apierrors.NewNotFound(schema.GroupResource{Resource: "persistentvolumeclaims"}, "deleted"))
+	dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{}, metav1.APIResource{Name: "persistentvolumeclaims"}, "ns").Return(pvcClient, nil)
+
+	pvs := []struct {
+		volumeID string
+		pv       string
+	}{
+		{"vol-plain", `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-plain"}}`},
+		{"vol-labeled", `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-labeled","labels":{"snapshot":"skip"}}}`},
+		{"vol-claimed", `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-claimed"},"spec":{"claimRef":{"namespace":"ns","name":"claimed"}}}`},
+		{"vol-pvc-labeled", `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-pvc-labeled"},"spec":{"claimRef":{"namespace":"ns","name":"pvc-labeled"}}}`},
+		{"vol-unclaimed", `{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-unclaimed"},"spec":{"claimRef":{"namespace":"ns","name":"deleted"}}}`},
+	}
+
+	for _, pv := range pvs {
+		// the fake block store returns the same volume ID for every PV.
+		blockStore.VolumeID = pv.volumeID
+		require.NoError(t, ib.takePVSnapshot(arktest.UnstructuredOrDie(pv.pv), backup, arktest.NewLogger()))
+	}
+
+	assert.Equal(t, sets.NewString("snap-plain", "snap-claimed", "snap-unclaimed"), blockStore.SnapshotsTaken)
+	assert.Len(t, backup.Status.VolumeBackups, 3)
+	assert.NotContains(t, backup.Status.VolumeBackups, "pv-labeled")
+	assert.NotContains(t, backup.Status.VolumeBackups, "pv-pvc-labeled")
+}
+
 type fakeTarWriter struct {
 	closeCalled      bool
 	headers          []*tar.Header
@@ -873,3 +1292,91 @@ func (ib *mockItemBackupper) backupItem(logger logrus.FieldLogger, obj runtime.U
 	args := ib.Called(logger, obj, groupResource)
 	return args.Error(0)
 }
+
+// orderRecordingBlockStore records when snapshots are created, relative
+// to the hooks run around them.
+type orderRecordingBlockStore struct {
+	*arktest.FakeBlockStore
+	order *[]string
+}
+
+func (bs *orderRecordingBlockStore) CreateSnapshot(volumeID, volumeAZ string, tags map[string]string) (string, error) {
+	*bs.order = append(*bs.order, "snapshot")
+	return bs.FakeBlockStore.CreateSnapshot(volumeID, volumeAZ, tags)
+}
+
+func TestTakePVSnapshotRunsSnapshotHooks(t *testing.T) {
+	tests := []struct {
+		name                   string
+		preSnapshotErr         error
+		expectedOrder          []string
+		expectedSnapshotsTaken int
+		expectedErr            string
+	}{
+		{
+			name:                   "hooks run around the snapshot",
+			expectedOrder:          []string{"pre-snapshot", "snapshot", "post-snapshot"},
+			expectedSnapshotsTaken: 1,
+		},
+		{
+			name:           "failed pre-snapshot hook prevents the snapshot",
+			preSnapshotErr: errors.New("freeze failed"),
+			expectedOrder:  []string{"pre-snapshot", "post-snapshot"},
+			expectedErr:    "error running pre-snapshot hook: freeze failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				order      []string
+				backup     = arktest.NewTestBackup().WithName("backup-1").Backup
+				blockStore = &orderRecordingBlockStore{
+					FakeBlockStore: &arktest.FakeBlockStore{
+						SnapshottableVolumes: map[string]v1.VolumeBackupInfo{"vol-1": {SnapshotID: "snap-1"}},
+						VolumeID:             "vol-1",
+					},
+					order: &order,
+				}
+				itemHookHandler = &mockItemHookHandler{}
+				ib              = &defaultItemBackupper{
+					blockStore:            blockStore,
+					resticSnapshotTracker: newPVCSnapshotTracker(),
+					snapshotHookPods:      make(map[string][]runtime.Unstructured),
+					itemHookHandler:       itemHookHandler,
+				}
+			)
+			defer itemHookHandler.AssertExpectations(t)
+
+			pod := &corev1api.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pod-1"},
+				Spec: corev1api.PodSpec{
+					Volumes: []corev1api.Volume{
+						{Name: "data", VolumeSource: corev1api.VolumeSource{PersistentVolumeClaim: &corev1api.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-1"}}},
+						{Name: "config", VolumeSource: corev1api.VolumeSource{EmptyDir: &corev1api.EmptyDirVolumeSource{}}},
+					},
+				},
+			}
+			podObj := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns","name":"pod-1"}}`)
+			ib.trackSnapshotHookPod(pod, podObj)
+
+			itemHookHandler.On("handleHooks", mock.Anything, kuberesource.Pods, podObj, ib.resourceHooks, hookPhasePreSnapshot).
+				Run(func(mock.Arguments) { order = append(order, "pre-snapshot") }).
+				Return(test.preSnapshotErr)
+			itemHookHandler.On("handleHooks", mock.Anything, kuberesource.Pods, podObj, ib.resourceHooks, hookPhasePostSnapshot).
+				Run(func(mock.Arguments) { order = append(order, "post-snapshot") }).
+				Return(nil)
+
+			pv := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-1"},"spec":{"claimRef":{"namespace":"ns","name":"pvc-1"}}}`)
+			err := ib.takePVSnapshot(pv, backup, arktest.NewLogger())
+
+			assert.Equal(t, test.expectedOrder, order)
+			assert.Equal(t, test.expectedSnapshotsTaken, blockStore.SnapshotsTaken.Len())
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	corev1api "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -39,8 +40,10 @@ import (
 type hookPhase string
 
 const (
-	hookPhasePre  hookPhase = "pre"
-	hookPhasePost hookPhase = "post"
+	hookPhasePre          hookPhase = "pre"
+	hookPhasePost         hookPhase = "post"
+	hookPhasePreSnapshot  hookPhase = "pre-snapshot"
+	hookPhasePostSnapshot hookPhase = "post-snapshot"
 )
 
 // itemHookHandler invokes hooks for an item.
@@ -61,6 +64,13 @@ type itemHookHandler interface {
 // defaultItemHookHandler is the default itemHookHandler.
 type defaultItemHookHandler struct {
 	podCommandExecutor podexec.PodCommandExecutor
+	// backup, if non-nil, determines whether hooks are validated
+	// before being run, and has the outcome of each hook recorded
+	// in its status.
+	backup *api.Backup
+	// snapshotPool guards the backup's status against the snapshot
+	// jobs, which run snapshot hooks concurrently with the item hooks.
+	snapshotPool *snapshotPool
 }
 
 func (h *defaultItemHookHandler) handleHooks(
@@ -97,7 +107,7 @@ func (h *defaultItemHookHandler) handleHooks(
 				"hookPhase":  phase,
 			},
 		)
-		if err := h.podCommandExecutor.ExecutePodCommand(hookLog, obj.UnstructuredContent(), namespace, name, "<from-annotation>", hookFromAnnotations); err != nil {
+		if err := h.runHook(hookLog, obj, namespace, name, "<from-annotation>", hookFromAnnotations, phase); err != nil {
 			hookLog.WithError(err).Error("Error executing hook")
 			if hookFromAnnotations.OnError == api.HookErrorModeFail {
 				return err
@@ -114,13 +124,7 @@ func (h *defaultItemHookHandler) handleHooks(
 			continue
 		}
 
-		var hooks []api.BackupResourceHook
-		if phase == hookPhasePre {
-			hooks = resourceHook.pre
-		} else {
-			hooks = resourceHook.post
-		}
-		for _, hook := range hooks {
+		for _, hook := range resourceHook.hooksFor(phase) {
 			if groupResource == kuberesource.Pods {
 				if hook.Exec != nil {
 					hookLog := log.WithFields(
@@ -130,7 +134,7 @@ func (h *defaultItemHookHandler) handleHooks(
 							"hookPhase":  phase,
 						},
 					)
-					err := h.podCommandExecutor.ExecutePodCommand(hookLog, obj.UnstructuredContent(), namespace, name, resourceHook.name, hook.Exec)
+					err := h.runHook(hookLog, obj, namespace, name, resourceHook.name, hook.Exec, phase)
 					if err != nil {
 						hookLog.WithError(err).Error("Error executing hook")
 						if hook.Exec.OnError == api.HookErrorModeFail {
@@ -145,6 +149,112 @@ func (h *defaultItemHookHandler) handleHooks(
 	return nil
 }
 
+// runHook executes an exec hook in a pod, validating it first and recording
+// its outcome if the handler has a backup.
+func (h *defaultItemHookHandler) runHook(
+	log logrus.FieldLogger,
+	obj runtime.Unstructured,
+	namespace, name, hookName string,
+	hook *api.ExecHook,
+	phase hookPhase,
+) error {
+	if h.backup != nil && h.backup.Spec.Hooks.Validate {
+		if err := validateHookTarget(obj.UnstructuredContent(), hook); err != nil {
+			log.WithError(err).Warn("Hook may not run successfully")
+		}
+	}
+
+	err := h.podCommandExecutor.ExecutePodCommand(log, obj.UnstructuredContent(), namespace, name, hookName, hook)
+
+	if h.backup != nil {
+		result := api.BackupHookResult{
+			Name:      hookName,
+			Phase:     string(phase),
+			Namespace: namespace,
+			Pod:       name,
+			Container: hook.Container,
+			Succeeded: err == nil,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		h.snapshotPool.withStatusLock(func() {
+			h.backup.Status.HookResults = append(h.backup.Status.HookResults, result)
+		})
+	}
+
+	return err
+}
+
+// validateHookTarget returns an error if the pod isn't in a state that the
+// hook can be executed in, i.e. the pod isn't running or the hook's container
+// hasn't started.
+func validateHookTarget(item map[string]interface{}, hook *api.ExecHook) error {
+	pod := new(corev1api.Pod)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item, pod); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(hook.Command) == 0 {
+		return errors.New("hook has no command")
+	}
+
+	if pod.Status.Phase != corev1api.PodRunning {
+		return errors.Errorf("pod is %s, not %s", pod.Status.Phase, corev1api.PodRunning)
+	}
+
+	container := hook.Container
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != container {
+			continue
+		}
+		if status.State.Running == nil {
+			return errors.Errorf("container %q is not running", container)
+		}
+		return nil
+	}
+
+	return errors.Errorf("container %q has no status", container)
+}
+
+// validateHookTimeouts returns a warning for each hook whose timeout is
+// longer than limit, the backup's pod volume timeout. The pod volume backups
+// a pre hook quiesces for can't complete within the backup's timeout if the
+// hook itself may take longer.
+func validateHookTimeouts(resourceHooks []resourceHook, limit time.Duration) []string {
+	var warnings []string
+
+	check := func(resourceHook resourceHook, phase hookPhase, hooks []api.BackupResourceHook) {
+		for _, hook := range hooks {
+			if hook.Exec == nil {
+				continue
+			}
+
+			timeout := hook.Exec.Timeout.Duration
+			if timeout == 0 {
+				timeout = podexec.DefaultTimeout
+			}
+
+			if timeout > limit {
+				warnings = append(warnings, fmt.Sprintf("%s hook in %q has a timeout of %s, which is longer than the backup's pod volume timeout of %s", phase, resourceHook.name, timeout, limit))
+			}
+		}
+	}
+
+	for _, resourceHook := range resourceHooks {
+		check(resourceHook, hookPhasePre, resourceHook.pre)
+		check(resourceHook, hookPhasePost, resourceHook.post)
+		check(resourceHook, hookPhasePreSnapshot, resourceHook.preSnapshot)
+		check(resourceHook, hookPhasePostSnapshot, resourceHook.postSnapshot)
+	}
+
+	return warnings
+}
+
 const (
 	podBackupHookContainerAnnotationKey = "hook.backup.ark.heptio.com/container"
 	podBackupHookCommandAnnotationKey   = "hook.backup.ark.heptio.com/command"
@@ -212,6 +322,23 @@ type resourceHook struct {
 	labelSelector labels.Selector
 	pre           []api.BackupResourceHook
 	post          []api.BackupResourceHook
+	preSnapshot   []api.BackupResourceHook
+	postSnapshot  []api.BackupResourceHook
+}
+
+// hooksFor returns the resource hook's hooks for phase.
+func (r resourceHook) hooksFor(phase hookPhase) []api.BackupResourceHook {
+	switch phase {
+	case hookPhasePre:
+		return r.pre
+	case hookPhasePost:
+		return r.post
+	case hookPhasePreSnapshot:
+		return r.preSnapshot
+	case hookPhasePostSnapshot:
+		return r.postSnapshot
+	}
+	return nil
 }
 
 func (r resourceHook) applicableTo(groupResource schema.GroupResource, namespace string, labels labels.Set) bool {
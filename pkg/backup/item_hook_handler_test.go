@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/kuberesource"
 	"github.com/heptio/ark/pkg/util/collections"
 	arktest "github.com/heptio/ark/pkg/util/test"
 	"github.com/pkg/errors"
@@ -693,3 +694,165 @@ func TestResourceHookApplicableTo(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleHooksRecordsResults(t *testing.T) {
+	podCommandExecutor := &arktest.MockPodCommandExecutor{}
+	defer podCommandExecutor.AssertExpectations(t)
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	h := &defaultItemHookHandler{
+		podCommandExecutor: podCommandExecutor,
+		backup:             backup,
+	}
+
+	item := arktest.UnstructuredOrDie(`
+		{
+			"apiVersion": "v1",
+			"kind": "Pod",
+			"metadata": {
+				"namespace": "ns",
+				"name": "name"
+			}
+		}
+	`)
+
+	hooks := []resourceHook{
+		{
+			name: "hook1",
+			pre: []v1.BackupResourceHook{
+				{Exec: &v1.ExecHook{Container: "1a", Command: []string{"quiesce"}, OnError: v1.HookErrorModeContinue}},
+				{Exec: &v1.ExecHook{Container: "1b", Command: []string{"quiesce"}, OnError: v1.HookErrorModeContinue}},
+			},
+		},
+	}
+
+	podCommandExecutor.On("ExecutePodCommand", mock.Anything, item.UnstructuredContent(), "ns", "name", "hook1", hooks[0].pre[0].Exec).Return(nil)
+	podCommandExecutor.On("ExecutePodCommand", mock.Anything, item.UnstructuredContent(), "ns", "name", "hook1", hooks[0].pre[1].Exec).Return(errors.New("quiesce failed"))
+
+	require.NoError(t, h.handleHooks(arktest.NewLogger(), kuberesource.Pods, item, hooks, hookPhasePre))
+
+	assert.Equal(t, []v1.BackupHookResult{
+		{Name: "hook1", Phase: "pre", Namespace: "ns", Pod: "name", Container: "1a", Succeeded: true},
+		{Name: "hook1", Phase: "pre", Namespace: "ns", Pod: "name", Container: "1b", Succeeded: false, Error: "quiesce failed"},
+	}, backup.Status.HookResults)
+}
+
+func TestValidateHookTarget(t *testing.T) {
+	pod := func(phase string, containerState string) map[string]interface{} {
+		return arktest.UnstructuredOrDie(fmt.Sprintf(`
+			{
+				"apiVersion": "v1",
+				"kind": "Pod",
+				"metadata": {"namespace": "ns", "name": "name"},
+				"spec": {"containers": [{"name": "db"}, {"name": "sidecar"}]},
+				"status": {
+					"phase": %q,
+					"containerStatuses": [{"name": "db", "state": {%q: {}}}]
+				}
+			}
+		`, phase, containerState)).UnstructuredContent()
+	}
+
+	tests := []struct {
+		name          string
+		pod           map[string]interface{}
+		hook          *v1.ExecHook
+		expectedError string
+	}{
+		{
+			name: "running pod with default container",
+			pod:  pod("Running", "running"),
+			hook: &v1.ExecHook{Command: []string{"quiesce"}},
+		},
+		{
+			name:          "hook without a command",
+			pod:           pod("Running", "running"),
+			hook:          &v1.ExecHook{Container: "db"},
+			expectedError: "hook has no command",
+		},
+		{
+			name:          "pending pod",
+			pod:           pod("Pending", "waiting"),
+			hook:          &v1.ExecHook{Container: "db", Command: []string{"quiesce"}},
+			expectedError: "pod is Pending, not Running",
+		},
+		{
+			name:          "container not running",
+			pod:           pod("Running", "terminated"),
+			hook:          &v1.ExecHook{Container: "db", Command: []string{"quiesce"}},
+			expectedError: `container "db" is not running`,
+		},
+		{
+			name:          "container without status",
+			pod:           pod("Running", "running"),
+			hook:          &v1.ExecHook{Container: "sidecar", Command: []string{"quiesce"}},
+			expectedError: `container "sidecar" has no status`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateHookTarget(test.pod, test.hook)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateHookTimeouts(t *testing.T) {
+	hooks := []resourceHook{
+		{
+			name: "hook1",
+			pre: []v1.BackupResourceHook{
+				{Exec: &v1.ExecHook{Timeout: metav1.Duration{Duration: 5 * time.Minute}}},
+				{Exec: &v1.ExecHook{}},
+				{},
+			},
+			post: []v1.BackupResourceHook{
+				{Exec: &v1.ExecHook{Timeout: metav1.Duration{Duration: 2 * time.Hour}}},
+			},
+			preSnapshot: []v1.BackupResourceHook{
+				{Exec: &v1.ExecHook{Timeout: metav1.Duration{Duration: 90 * time.Minute}}},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		`post hook in "hook1" has a timeout of 2h0m0s, which is longer than the backup's pod volume timeout of 1h0m0s`,
+		`pre-snapshot hook in "hook1" has a timeout of 1h30m0s, which is longer than the backup's pod volume timeout of 1h0m0s`,
+	}, validateHookTimeouts(hooks, time.Hour))
+
+	assert.Equal(t, []string{
+		`pre hook in "hook1" has a timeout of 5m0s, which is longer than the backup's pod volume timeout of 10s`,
+		`pre hook in "hook1" has a timeout of 30s, which is longer than the backup's pod volume timeout of 10s`,
+		`post hook in "hook1" has a timeout of 2h0m0s, which is longer than the backup's pod volume timeout of 10s`,
+		`pre-snapshot hook in "hook1" has a timeout of 1h30m0s, which is longer than the backup's pod volume timeout of 10s`,
+	}, validateHookTimeouts(hooks, 10*time.Second))
+}
+
+func TestHandleHooksSnapshotPhases(t *testing.T) {
+	podCommandExecutor := &arktest.MockPodCommandExecutor{}
+	defer podCommandExecutor.AssertExpectations(t)
+
+	h := &defaultItemHookHandler{podCommandExecutor: podCommandExecutor}
+
+	item := arktest.UnstructuredOrDie(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"namespace": "ns", "name": "name"}}`)
+
+	hooks := []resourceHook{
+		{
+			name:         "hook1",
+			pre:          []v1.BackupResourceHook{{Exec: &v1.ExecHook{Command: []string{"pre"}}}},
+			preSnapshot:  []v1.BackupResourceHook{{Exec: &v1.ExecHook{Command: []string{"freeze"}, OnError: v1.HookErrorModeFail}}},
+			postSnapshot: []v1.BackupResourceHook{{Exec: &v1.ExecHook{Command: []string{"thaw"}}}},
+		},
+	}
+
+	podCommandExecutor.On("ExecutePodCommand", mock.Anything, item.UnstructuredContent(), "ns", "name", "hook1", hooks[0].preSnapshot[0].Exec).Return(errors.New("freeze failed"))
+	podCommandExecutor.On("ExecutePodCommand", mock.Anything, item.UnstructuredContent(), "ns", "name", "hook1", hooks[0].postSnapshot[0].Exec).Return(nil)
+
+	assert.EqualError(t, h.handleHooks(arktest.NewLogger(), kuberesource.Pods, item, hooks, hookPhasePreSnapshot), "freeze failed")
+	assert.NoError(t, h.handleHooks(arktest.NewLogger(), kuberesource.Pods, item, hooks, hookPhasePostSnapshot))
+}
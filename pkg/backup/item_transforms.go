@@ -0,0 +1,136 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// itemTransform is a parsed api.ItemTransform.
+type itemTransform struct {
+	// remove is the path of field names to the field to remove.
+	remove []string
+}
+
+// ValidateItemTransforms returns an error for each of the transforms whose
+// JSONPath expression can't be parsed.
+func ValidateItemTransforms(transforms []api.ItemTransform) []string {
+	var errs []string
+
+	for _, transform := range transforms {
+		if _, err := parseFieldPath(transform.Remove); err != nil {
+			errs = append(errs, fmt.Sprintf("Invalid item transform %q: %v", transform.Remove, err))
+		}
+	}
+
+	return errs
+}
+
+func parseItemTransforms(transforms []api.ItemTransform) ([]itemTransform, error) {
+	parsed := make([]itemTransform, 0, len(transforms))
+
+	for _, transform := range transforms {
+		path, err := parseFieldPath(transform.Remove)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid item transform %q", transform.Remove)
+		}
+		parsed = append(parsed, itemTransform{remove: path})
+	}
+
+	return parsed, nil
+}
+
+// parseFieldPath parses a JSONPath expression made up only of field names,
+// e.g. {.metadata.annotations['example.com/key']}, into the names.
+func parseFieldPath(expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") {
+		if !strings.HasSuffix(expr, "}") {
+			return nil, errors.New("unterminated {")
+		}
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	expr = strings.TrimPrefix(expr, "$")
+
+	var path []string
+	for len(expr) > 0 {
+		switch expr[0] {
+		case '.':
+			end := strings.IndexAny(expr[1:], ".[")
+			if end < 0 {
+				end = len(expr) - 1
+			}
+			name := expr[1 : end+1]
+			if name == "" {
+				return nil, errors.New("empty field name")
+			}
+			if strings.ContainsAny(name, "]'\"*?@()") {
+				return nil, errors.Errorf("unsupported field name %q", name)
+			}
+			path = append(path, name)
+			expr = expr[end+1:]
+		case '[':
+			if len(expr) < 2 || (expr[1] != '\'' && expr[1] != '"') {
+				return nil, errors.New("only quoted field names are supported in brackets")
+			}
+			quote := expr[1]
+			end := strings.IndexByte(expr[2:], quote)
+			if end < 0 || !strings.HasPrefix(expr[2+end+1:], "]") {
+				return nil, errors.New("unterminated [")
+			}
+			name := expr[2 : 2+end]
+			if name == "" {
+				return nil, errors.New("empty field name")
+			}
+			path = append(path, name)
+			expr = expr[2+end+2:]
+		default:
+			return nil, errors.Errorf("unexpected %q, expected . or [", expr[0])
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, errors.New("no field is named")
+	}
+
+	return path, nil
+}
+
+// applyItemTransforms applies the transforms, in order, to an item's
+// content.
+func applyItemTransforms(content map[string]interface{}, transforms []itemTransform) {
+	for _, transform := range transforms {
+		removeField(content, transform.remove)
+	}
+}
+
+func removeField(content map[string]interface{}, path []string) {
+	for _, name := range path[:len(path)-1] {
+		next, ok := content[name].(map[string]interface{})
+		if !ok {
+			return
+		}
+		content = next
+	}
+
+	delete(content, path[len(path)-1])
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		expr        string
+		expected    []string
+		expectedErr bool
+	}{
+		{expr: "{.status}", expected: []string{"status"}},
+		{expr: ".metadata.managedFields", expected: []string{"metadata", "managedFields"}},
+		{expr: "{$.spec.template}", expected: []string{"spec", "template"}},
+		{
+			expr:     "{.metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']}",
+			expected: []string{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+		},
+		{expr: `.data["key.json"].nested`, expected: []string{"data", "key.json", "nested"}},
+		{expr: "", expectedErr: true},
+		{expr: "{}", expectedErr: true},
+		{expr: "{.status", expectedErr: true},
+		{expr: "status", expectedErr: true},
+		{expr: ".metadata..name", expectedErr: true},
+		{expr: ".spec.containers[0]", expectedErr: true},
+		{expr: ".spec.containers[*].env", expectedErr: true},
+		{expr: ".metadata.annotations['key", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			path, err := parseFieldPath(test.expr)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, path)
+		})
+	}
+}
+
+func TestValidateItemTransforms(t *testing.T) {
+	assert.Empty(t, ValidateItemTransforms(nil))
+	assert.Empty(t, ValidateItemTransforms([]api.ItemTransform{{Remove: "{.status}"}}))
+
+	errs := ValidateItemTransforms([]api.ItemTransform{
+		{Remove: "{.status}"},
+		{Remove: ".spec.containers[0]"},
+	})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], `Invalid item transform ".spec.containers[0]"`)
+}
+
+func TestApplyItemTransforms(t *testing.T) {
+	content := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "pod-1",
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				"keep": "me",
+			},
+		},
+		"spec":   "unchanged",
+		"status": map[string]interface{}{"phase": "Running"},
+	}
+
+	transforms, err := parseItemTransforms([]api.ItemTransform{
+		{Remove: "{.status}"},
+		{Remove: "{.metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']}"},
+		// fields the item doesn't have, or that aren't objects, are skipped
+		{Remove: "{.metadata.managedFields}"},
+		{Remove: "{.spec.template}"},
+	})
+	require.NoError(t, err)
+
+	applyItemTransforms(content, transforms)
+
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "pod-1",
+			"annotations": map[string]interface{}{"keep": "me"},
+		},
+		"spec": "unchanged",
+	}, content)
+}
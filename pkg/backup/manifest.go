@@ -0,0 +1,69 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"io"
+
+	"github.com/heptio/ark/pkg/archive"
+)
+
+// manifestTarWriter is a tarWriter that adds each file written to it to
+// the backup's manifest as soon as its header is written.
+type manifestTarWriter struct {
+	tarWriter
+
+	// written counts the bytes the tar writer has written to the tarball,
+	// which, right after a header's written, is where the file's contents
+	// start.
+	written  *countingWriter
+	manifest *archive.ManifestWriter
+}
+
+func newManifestTarWriter(tw tarWriter, written *countingWriter, manifest io.Writer) (*manifestTarWriter, error) {
+	mw, err := archive.NewManifestWriter(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifestTarWriter{
+		tarWriter: tw,
+		written:   written,
+		manifest:  mw,
+	}, nil
+}
+
+func (w *manifestTarWriter) WriteHeader(hdr *tar.Header) error {
+	if err := w.tarWriter.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	return w.manifest.Add(archive.NewManifestEntry(hdr.Name, w.written.n, hdr.Size))
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
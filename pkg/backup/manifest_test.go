@@ -0,0 +1,107 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/heptio/ark/pkg/archive"
+)
+
+func TestManifestTarWriter(t *testing.T) {
+	var (
+		tarball  = new(bytes.Buffer)
+		manifest = new(bytes.Buffer)
+		written  = &countingWriter{w: tarball}
+		tw       = tar.NewWriter(written)
+	)
+
+	w, err := newManifestTarWriter(tw, written, manifest)
+	require.NoError(t, err)
+
+	files := []struct {
+		path     string
+		contents string
+	}{
+		{"metadata/version", "1"},
+		{"resources/pods/namespaces/ns-1/pod-1.json", `{"metadata":{"name":"pod-1"}}`},
+		// long enough to need a PAX header, which moves the contents.
+		{"resources/configmaps/namespaces/ns-1/" + strings.Repeat("a", 100) + ".json", `{"metadata":{"name":"long"}}`},
+		{"resources/persistentvolumes/cluster/pv-1.json", `{"metadata":{"name":"pv-1"}}`},
+	}
+	for _, file := range files {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: file.path, Typeflag: tar.TypeReg, Size: int64(len(file.contents))}))
+		_, err := w.Write([]byte(file.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	entries, err := archive.ReadManifest(manifest)
+	require.NoError(t, err)
+	require.Len(t, entries, len(files))
+
+	// each entry locates its file's contents in the tarball.
+	raw := tarball.Bytes()
+	for i, file := range files {
+		entry := entries[i]
+		assert.Equal(t, file.path, entry.Path)
+		assert.Equal(t, file.contents, string(raw[entry.Offset:entry.Offset+entry.Size]))
+	}
+	assert.Equal(t, "pods", entries[1].Resource)
+	assert.Equal(t, "ns-1", entries[1].Namespace)
+	assert.Equal(t, "pod-1", entries[1].Name)
+	assert.Equal(t, "", entries[3].Namespace)
+	assert.Equal(t, "pv-1", entries[3].Name)
+
+	// and the tarball verifies against it once compressed.
+	compressed := new(bytes.Buffer)
+	gzw := gzip.NewWriter(compressed)
+	_, err = gzw.Write(raw)
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+	assert.NoError(t, archive.VerifyManifest(compressed, archive.CompressionGzip, entries))
+}
+
+func TestManifestTarWriterPartialFailure(t *testing.T) {
+	var (
+		manifest = new(bytes.Buffer)
+		tw       = &fakeTarWriter{}
+	)
+
+	w, err := newManifestTarWriter(tw, &countingWriter{w: new(bytes.Buffer)}, manifest)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "resources/pods/namespaces/ns-1/pod-1.json", Size: 10}))
+
+	// a file whose header can't be written isn't in the tarball, so it's
+	// left out of the manifest.
+	tw.writeHeaderError = errors.New("write failed")
+	require.Error(t, w.WriteHeader(&tar.Header{Name: "resources/pods/namespaces/ns-1/pod-2.json", Size: 10}))
+
+	entries, err := archive.ReadManifest(manifest)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "resources/pods/namespaces/ns-1/pod-1.json", entries[0].Path)
+}
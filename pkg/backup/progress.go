@@ -0,0 +1,80 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"strings"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// progressTarWriter is a tarWriter that records each item written to it
+// in the backup's Status.Progress, so the summary is up to date even if
+// the backup fails partway through.
+type progressTarWriter struct {
+	tarWriter
+
+	progress   *api.BackupProgress
+	namespaces map[string]struct{}
+	// listItems is whether each item's path is recorded in the progress,
+	// as well as being counted.
+	listItems bool
+}
+
+func newProgressTarWriter(tw tarWriter, backup *api.Backup) *progressTarWriter {
+	backup.Status.Progress = &api.BackupProgress{
+		ResourceCounts: make(map[string]int),
+		ResourceSizes:  make(map[string]int64),
+	}
+
+	return &progressTarWriter{
+		tarWriter:  tw,
+		progress:   backup.Status.Progress,
+		namespaces: make(map[string]struct{}),
+		listItems:  backup.Spec.DryRun,
+	}
+}
+
+func (w *progressTarWriter) WriteHeader(hdr *tar.Header) error {
+	if err := w.tarWriter.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	// items are written to resources/<resource>/namespaces/<namespace>/<name>.json
+	// or resources/<resource>/cluster/<name>.json
+	parts := strings.Split(hdr.Name, "/")
+	if len(parts) < 4 || parts[0] != api.ResourcesDir {
+		return nil
+	}
+
+	w.progress.ItemsBackedUp++
+	if w.listItems {
+		w.progress.Items = append(w.progress.Items, hdr.Name)
+	}
+	w.progress.ResourceCounts[parts[1]]++
+	w.progress.ResourceSizes[parts[1]] += hdr.Size
+
+	if parts[2] == api.NamespaceScopedDir && len(parts) == 5 {
+		if _, ok := w.namespaces[parts[3]]; !ok {
+			w.namespaces[parts[3]] = struct{}{}
+			w.progress.Namespaces++
+		}
+	}
+
+	return nil
+}
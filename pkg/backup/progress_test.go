@@ -0,0 +1,82 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestProgressTarWriter(t *testing.T) {
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	tw := &fakeTarWriter{}
+	w := newProgressTarWriter(tw, backup)
+
+	for _, hdr := range []*tar.Header{
+		{Name: "resources/pods/namespaces/ns-1/pod-1.json", Size: 100},
+		{Name: "resources/pods/namespaces/ns-1/pod-2.json", Size: 200},
+		{Name: "resources/pods/namespaces/ns-2/pod-1.json", Size: 300},
+		{Name: "resources/deployments.apps/namespaces/ns-2/deploy-1.json", Size: 1000},
+		{Name: "resources/persistentvolumes/cluster/pv-1.json", Size: 50},
+		// files that aren't items aren't counted
+		{Name: "metadata/version", Size: 1},
+	} {
+		require.NoError(t, w.WriteHeader(hdr))
+	}
+
+	// failed writes aren't counted
+	tw.writeHeaderError = errors.New("disk full")
+	assert.Error(t, w.WriteHeader(&tar.Header{Name: "resources/pods/namespaces/ns-3/pod-1.json"}))
+
+	assert.Equal(t, &api.BackupProgress{
+		ItemsBackedUp: 5,
+		ResourceCounts: map[string]int{
+			"pods":              3,
+			"deployments.apps":  1,
+			"persistentvolumes": 1,
+		},
+		ResourceSizes: map[string]int64{
+			"pods":              600,
+			"deployments.apps":  1000,
+			"persistentvolumes": 50,
+		},
+		Namespaces: 2,
+	}, backup.Status.Progress)
+}
+
+func TestProgressTarWriterListsItemsForDryRuns(t *testing.T) {
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.DryRun = true
+	w := newProgressTarWriter(&fakeTarWriter{}, backup)
+
+	items := []string{
+		"resources/pods/namespaces/ns-1/pod-1.json",
+		"resources/persistentvolumes/cluster/pv-1.json",
+	}
+	for _, name := range items {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name}))
+	}
+
+	assert.Equal(t, items, backup.Status.Progress.Items)
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package backup
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
@@ -54,6 +56,7 @@ type resourceBackupperFactory interface {
 		blockStore cloudprovider.BlockStore,
 		resticBackupper restic.Backupper,
 		resticSnapshotTracker *pvcSnapshotTracker,
+		snapshotPool *snapshotPool,
 	) resourceBackupper
 }
 
@@ -75,6 +78,7 @@ func (f *defaultResourceBackupperFactory) newResourceBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupper restic.Backupper,
 	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
 ) resourceBackupper {
 	return &defaultResourceBackupper{
 		log:                   log,
@@ -92,6 +96,7 @@ func (f *defaultResourceBackupperFactory) newResourceBackupper(
 		blockStore:            blockStore,
 		resticBackupper:       resticBackupper,
 		resticSnapshotTracker: resticSnapshotTracker,
+		snapshotPool:          snapshotPool,
 		itemBackupperFactory:  &defaultItemBackupperFactory{},
 	}
 }
@@ -116,6 +121,7 @@ type defaultResourceBackupper struct {
 	blockStore            cloudprovider.BlockStore
 	resticBackupper       restic.Backupper
 	resticSnapshotTracker *pvcSnapshotTracker
+	snapshotPool          *snapshotPool
 	itemBackupperFactory  itemBackupperFactory
 }
 
@@ -192,6 +198,7 @@ func (rb *defaultResourceBackupper) backupResource(
 		rb.blockStore,
 		rb.resticBackupper,
 		rb.resticSnapshotTracker,
+		rb.snapshotPool,
 	)
 
 	namespacesToList := getNamespacesToList(rb.namespaces)
@@ -213,12 +220,17 @@ func (rb *defaultResourceBackupper) backupResource(
 		}
 
 		for _, ns := range namespacesToList {
+			if isSkippedTerminatingNamespace(rb.backup, ns) {
+				continue
+			}
+
 			log.WithField("namespace", ns).Info("Getting namespace")
 			unstructured, err := resourceClient.Get(ns, metav1.GetOptions{})
 			if err != nil {
 				errs = append(errs, errors.Wrap(err, "error getting namespace"))
 				continue
 			}
+			recordGroupVersion(rb.backup, gr, gv)
 
 			labels := labels.Set(unstructured.GetLabels())
 			if labelSelector != nil && !labelSelector.Matches(labels) {
@@ -240,6 +252,10 @@ func (rb *defaultResourceBackupper) backupResource(
 	}
 
 	for _, namespace := range namespacesToList {
+		if isSkippedTerminatingNamespace(rb.backup, namespace) {
+			continue
+		}
+
 		resourceClient, err := rb.dynamicFactory.ClientForGroupVersionResource(gv, resource, namespace)
 		if err != nil {
 			return err
@@ -253,8 +269,26 @@ func (rb *defaultResourceBackupper) backupResource(
 		log.WithField("namespace", namespace).Info("Listing items")
 		unstructuredList, err := resourceClient.List(metav1.ListOptions{LabelSelector: labelSelector})
 		if err != nil {
-			return errors.WithStack(err)
+			if rb.backup.Spec.OnListError == api.ListErrorPolicySkip {
+				rb.backup.Status.SkippedUnlistableResources = append(rb.backup.Status.SkippedUnlistableResources, api.SkippedUnlistableResource{
+					GroupResource: gr.String(),
+					Namespace:     namespace,
+					Error:         err.Error(),
+				})
+				log.WithField("namespace", namespace).WithError(err).Warn("Error listing items, skipping them under the backup's list error policy")
+				continue
+			}
+			if err := recordPartialFailure(rb.backup, namespace, errors.WithStack(err)); err != nil {
+				return err
+			}
+			log.WithField("namespace", namespace).WithError(err).Warn("Error listing items, continuing under the backup's partial failure policy")
+			continue
+		}
+
+		if listMetadata, err := meta.ListAccessor(unstructuredList); err == nil {
+			recordListResourceVersion(rb.backup, listMetadata.GetResourceVersion())
 		}
+		recordGroupVersion(rb.backup, gr, gv)
 
 		// do the backup
 		items, err := meta.ExtractList(unstructuredList)
@@ -281,8 +315,14 @@ func (rb *defaultResourceBackupper) backupResource(
 				continue
 			}
 
+			if gr == kuberesource.Namespaces && isSkippedTerminatingNamespace(rb.backup, metadata.GetName()) {
+				continue
+			}
+
 			if err := itemBackupper.backupItem(log, unstructured, gr); err != nil {
-				errs = append(errs, err)
+				if err := recordPartialFailure(rb.backup, metadata.GetNamespace(), err); err != nil {
+					errs = append(errs, err)
+				}
 			}
 		}
 	}
@@ -290,15 +330,39 @@ func (rb *defaultResourceBackupper) backupResource(
 	return kuberrs.NewAggregate(errs)
 }
 
+// recordPartialFailure records err, an error backing up the items in
+// namespace, in the backup's status if its PartialFailurePolicy is
+// Continue, and returns nil. Otherwise, or if the error isn't specific to a
+// namespace, err is returned.
+func recordPartialFailure(backup *api.Backup, namespace string, err error) error {
+	if backup.Spec.PartialFailurePolicy != api.PartialFailurePolicyContinue || namespace == "" {
+		return err
+	}
+
+	backup.Status.PartialFailures = append(backup.Status.PartialFailures, fmt.Sprintf("namespace %s: %v", namespace, err))
+	return nil
+}
+
+// recordGroupVersion records in the backup's status that gr's items were
+// got at gv.
+func recordGroupVersion(backup *api.Backup, gr schema.GroupResource, gv schema.GroupVersion) {
+	if backup.Status.GroupVersions == nil {
+		backup.Status.GroupVersions = make(map[string]string)
+	}
+	backup.Status.GroupVersions[gr.String()] = gv.String()
+}
+
 // getNamespacesToList examines ie and resolves the includes and excludes to a full list of
 // namespaces to list. If ie is nil or it includes *, the result is just "" (list across all
-// namespaces). Otherwise, the result is a list of every included namespace minus all excluded ones.
+// namespaces). The same is true if ie includes glob patterns, since the namespaces they match
+// can't be enumerated; items are then filtered by namespace as they're backed up. Otherwise, the
+// result is a list of every included namespace minus all excluded ones.
 func getNamespacesToList(ie *collections.IncludesExcludes) []string {
 	if ie == nil {
 		return []string{""}
 	}
 
-	if ie.ShouldInclude("*") {
+	if ie.ShouldInclude("*") || ie.IncludesPatterns() {
 		// "" means all namespaces
 		return []string{""}
 	}
@@ -28,6 +28,7 @@ import (
 	"github.com/heptio/ark/pkg/restic"
 	"github.com/heptio/ark/pkg/util/collections"
 	arktest "github.com/heptio/ark/pkg/util/test"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -273,6 +274,7 @@ func TestBackupResource(t *testing.T) {
 				nil, // snapshot service
 				nil, // restic backupper
 				newPVCSnapshotTracker(),
+				nil, // snapshot pool
 			).(*defaultResourceBackupper)
 
 			itemBackupperFactory := &mockItemBackupperFactory{}
@@ -297,6 +299,7 @@ func TestBackupResource(t *testing.T) {
 					mock.Anything,
 					mock.Anything,
 					mock.Anything,
+					mock.Anything,
 				).Return(itemBackupper)
 
 				if len(test.listResponses) > 0 {
@@ -441,6 +444,7 @@ func TestBackupResourceCohabitation(t *testing.T) {
 				nil, // snapshot service
 				nil, // restic backupper
 				newPVCSnapshotTracker(),
+				nil, // snapshot pool
 			).(*defaultResourceBackupper)
 
 			itemBackupperFactory := &mockItemBackupperFactory{}
@@ -464,6 +468,7 @@ func TestBackupResourceCohabitation(t *testing.T) {
 				mock.Anything, // snapshot service
 				mock.Anything, // restic backupper
 				mock.Anything, // pvc snapshot tracker
+				mock.Anything, // snapshot pool
 			).Return(itemBackupper)
 
 			client := &arktest.FakeDynamicClient{}
@@ -524,6 +529,7 @@ func TestBackupResourceOnlyIncludesSpecifiedNamespaces(t *testing.T) {
 		nil, // snapshot service
 		nil, // restic backupper
 		newPVCSnapshotTracker(),
+		nil, // snapshot pool
 	).(*defaultResourceBackupper)
 
 	itemBackupperFactory := &mockItemBackupperFactory{}
@@ -560,6 +566,7 @@ func TestBackupResourceOnlyIncludesSpecifiedNamespaces(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(itemBackupper)
 
 	client := &arktest.FakeDynamicClient{}
@@ -628,6 +635,7 @@ func TestBackupResourceListAllNamespacesExcludesCorrectly(t *testing.T) {
 		nil, // snapshot service
 		nil, // restic backupper
 		newPVCSnapshotTracker(),
+		nil, // snapshot pool
 	).(*defaultResourceBackupper)
 
 	itemBackupperFactory := &mockItemBackupperFactory{}
@@ -654,6 +662,7 @@ func TestBackupResourceListAllNamespacesExcludesCorrectly(t *testing.T) {
 		mock.Anything,
 		mock.Anything,
 		mock.Anything,
+		mock.Anything,
 	).Return(itemBackupper)
 
 	client := &arktest.FakeDynamicClient{}
@@ -675,6 +684,351 @@ func TestBackupResourceListAllNamespacesExcludesCorrectly(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestBackupResourcePartialFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name                    string
+		policy                  v1.PartialFailurePolicy
+		expectErr               bool
+		expectedPartialFailures []string
+	}{
+		{
+			name:      "errors fail the backup by default",
+			expectErr: true,
+		},
+		{
+			name:      "errors fail the backup with a policy of Fail",
+			policy:    v1.PartialFailurePolicyFail,
+			expectErr: true,
+		},
+		{
+			name:   "errors are recorded per namespace with a policy of Continue",
+			policy: v1.PartialFailurePolicyContinue,
+			expectedPartialFailures: []string{
+				"namespace ns-1: item error",
+				"namespace ns-3: list error",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := &v1.Backup{Spec: v1.BackupSpec{PartialFailurePolicy: test.policy}}
+
+			namespaces := collections.NewIncludesExcludes().Includes("ns-1", "ns-2", "ns-3")
+			resources := collections.NewIncludesExcludes().Includes("*")
+
+			backedUpItems := map[itemKey]struct{}{}
+
+			dynamicFactory := &arktest.FakeDynamicFactory{}
+			defer dynamicFactory.AssertExpectations(t)
+
+			discoveryHelper := arktest.NewFakeDiscoveryHelper(true, nil)
+
+			podCommandExecutor := &arktest.MockPodCommandExecutor{}
+			defer podCommandExecutor.AssertExpectations(t)
+
+			tarWriter := &fakeTarWriter{}
+
+			rb := (&defaultResourceBackupperFactory{}).newResourceBackupper(
+				arktest.NewLogger(),
+				backup,
+				namespaces,
+				resources,
+				dynamicFactory,
+				discoveryHelper,
+				backedUpItems,
+				map[string]*cohabitatingResource{},
+				nil,
+				podCommandExecutor,
+				tarWriter,
+				nil,
+				nil, // snapshot service
+				nil, // restic backupper
+				newPVCSnapshotTracker(),
+				nil, // snapshot pool
+			).(*defaultResourceBackupper)
+
+			itemBackupperFactory := &mockItemBackupperFactory{}
+			defer itemBackupperFactory.AssertExpectations(t)
+			rb.itemBackupperFactory = itemBackupperFactory
+
+			itemBackupper := &mockItemBackupper{}
+			defer itemBackupper.AssertExpectations(t)
+
+			itemBackupperFactory.On("newItemBackupper",
+				backup,
+				namespaces,
+				resources,
+				backedUpItems,
+				mock.Anything,
+				podCommandExecutor,
+				tarWriter,
+				mock.Anything,
+				dynamicFactory,
+				discoveryHelper,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(itemBackupper)
+
+			podsGroup := schema.GroupVersion{Group: "", Version: "v1"}
+
+			// ns-1's item fails to back up, ns-2's is backed up, and ns-3's
+			// items can't be listed.
+			pod1 := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns-1","name":"pod-1"}}`)
+			pod2 := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns-2","name":"pod-2"}}`)
+
+			client1 := &arktest.FakeDynamicClient{}
+			defer client1.AssertExpectations(t)
+			dynamicFactory.On("ClientForGroupVersionResource", podsGroup, podsResource, "ns-1").Return(client1, nil)
+			client1.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{Items: []unstructured.Unstructured{*pod1}}, nil)
+			itemBackupper.On("backupItem", mock.AnythingOfType("*logrus.Entry"), pod1, kuberesource.Pods).Return(errors.New("item error"))
+
+			client2 := &arktest.FakeDynamicClient{}
+			defer client2.AssertExpectations(t)
+			dynamicFactory.On("ClientForGroupVersionResource", podsGroup, podsResource, "ns-2").Return(client2, nil)
+			client2.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{Items: []unstructured.Unstructured{*pod2}}, nil)
+			itemBackupper.On("backupItem", mock.AnythingOfType("*logrus.Entry"), pod2, kuberesource.Pods).Return(nil)
+
+			client3 := &arktest.FakeDynamicClient{}
+			defer client3.AssertExpectations(t)
+			dynamicFactory.On("ClientForGroupVersionResource", podsGroup, podsResource, "ns-3").Return(client3, nil)
+			client3.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{}, errors.New("list error"))
+
+			err := rb.backupResource(v1Group, podsResource)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedPartialFailures, backup.Status.PartialFailures)
+		})
+	}
+}
+
+func TestBackupResourceOnListError(t *testing.T) {
+	tests := []struct {
+		name                 string
+		policy               v1.ListErrorPolicy
+		partialFailurePolicy v1.PartialFailurePolicy
+		expectErr            bool
+		expectedSkipped      []v1.SkippedUnlistableResource
+		expectedFailures     []string
+	}{
+		{
+			name:      "no policy fails the backup",
+			expectErr: true,
+		},
+		{
+			name:      "list errors fail the backup with a policy of Fail",
+			policy:    v1.ListErrorPolicyFail,
+			expectErr: true,
+		},
+		{
+			name:                 "list errors are partial failures with a policy of Fail and a partial failure policy of Continue",
+			policy:               v1.ListErrorPolicyFail,
+			partialFailurePolicy: v1.PartialFailurePolicyContinue,
+			expectedFailures:     []string{"namespace ns-3: list error"},
+		},
+		{
+			name:   "unlistable resources are skipped with a policy of Skip",
+			policy: v1.ListErrorPolicySkip,
+			expectedSkipped: []v1.SkippedUnlistableResource{
+				{GroupResource: "pods", Namespace: "ns-3", Error: "list error"},
+			},
+		},
+		{
+			name:                 "skipping takes precedence over the partial failure policy",
+			policy:               v1.ListErrorPolicySkip,
+			partialFailurePolicy: v1.PartialFailurePolicyContinue,
+			expectedSkipped: []v1.SkippedUnlistableResource{
+				{GroupResource: "pods", Namespace: "ns-3", Error: "list error"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := &v1.Backup{Spec: v1.BackupSpec{OnListError: test.policy, PartialFailurePolicy: test.partialFailurePolicy}}
+
+			namespaces := collections.NewIncludesExcludes().Includes("ns-2", "ns-3")
+			resources := collections.NewIncludesExcludes().Includes("*")
+
+			backedUpItems := map[itemKey]struct{}{}
+
+			dynamicFactory := &arktest.FakeDynamicFactory{}
+			defer dynamicFactory.AssertExpectations(t)
+
+			discoveryHelper := arktest.NewFakeDiscoveryHelper(true, nil)
+
+			podCommandExecutor := &arktest.MockPodCommandExecutor{}
+			defer podCommandExecutor.AssertExpectations(t)
+
+			tarWriter := &fakeTarWriter{}
+
+			rb := (&defaultResourceBackupperFactory{}).newResourceBackupper(
+				arktest.NewLogger(),
+				backup,
+				namespaces,
+				resources,
+				dynamicFactory,
+				discoveryHelper,
+				backedUpItems,
+				map[string]*cohabitatingResource{},
+				nil,
+				podCommandExecutor,
+				tarWriter,
+				nil,
+				nil, // snapshot service
+				nil, // restic backupper
+				newPVCSnapshotTracker(),
+				nil, // snapshot pool
+			).(*defaultResourceBackupper)
+
+			itemBackupperFactory := &mockItemBackupperFactory{}
+			defer itemBackupperFactory.AssertExpectations(t)
+			rb.itemBackupperFactory = itemBackupperFactory
+
+			itemBackupper := &mockItemBackupper{}
+			defer itemBackupper.AssertExpectations(t)
+
+			itemBackupperFactory.On("newItemBackupper",
+				backup,
+				namespaces,
+				resources,
+				backedUpItems,
+				mock.Anything,
+				podCommandExecutor,
+				tarWriter,
+				mock.Anything,
+				dynamicFactory,
+				discoveryHelper,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+				mock.Anything,
+			).Return(itemBackupper)
+
+			podsGroup := schema.GroupVersion{Group: "", Version: "v1"}
+
+			// ns-2's item is backed up, and ns-3's items can't be listed,
+			// e.g. because the API server serving them is unavailable.
+			pod2 := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns-2","name":"pod-2"}}`)
+
+			client2 := &arktest.FakeDynamicClient{}
+			defer client2.AssertExpectations(t)
+			dynamicFactory.On("ClientForGroupVersionResource", podsGroup, podsResource, "ns-2").Return(client2, nil)
+			client2.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{Items: []unstructured.Unstructured{*pod2}}, nil)
+			itemBackupper.On("backupItem", mock.AnythingOfType("*logrus.Entry"), pod2, kuberesource.Pods).Return(nil)
+
+			client3 := &arktest.FakeDynamicClient{}
+			defer client3.AssertExpectations(t)
+			dynamicFactory.On("ClientForGroupVersionResource", podsGroup, podsResource, "ns-3").Return(client3, nil)
+			client3.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{}, errors.New("list error"))
+
+			err := rb.backupResource(v1Group, podsResource)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedSkipped, backup.Status.SkippedUnlistableResources)
+			assert.Equal(t, test.expectedFailures, backup.Status.PartialFailures)
+		})
+	}
+}
+
+func TestBackupResourceRecordsGroupVersions(t *testing.T) {
+	backup := &v1.Backup{Spec: v1.BackupSpec{OnListError: v1.ListErrorPolicySkip}}
+
+	namespaces := collections.NewIncludesExcludes().Includes("*")
+	resources := collections.NewIncludesExcludes().Includes("*")
+
+	backedUpItems := map[itemKey]struct{}{}
+
+	dynamicFactory := &arktest.FakeDynamicFactory{}
+	defer dynamicFactory.AssertExpectations(t)
+
+	discoveryHelper := arktest.NewFakeDiscoveryHelper(true, nil)
+
+	podCommandExecutor := &arktest.MockPodCommandExecutor{}
+	defer podCommandExecutor.AssertExpectations(t)
+
+	tarWriter := &fakeTarWriter{}
+
+	rb := (&defaultResourceBackupperFactory{}).newResourceBackupper(
+		arktest.NewLogger(),
+		backup,
+		namespaces,
+		resources,
+		dynamicFactory,
+		discoveryHelper,
+		backedUpItems,
+		map[string]*cohabitatingResource{},
+		nil,
+		podCommandExecutor,
+		tarWriter,
+		nil,
+		nil, // snapshot service
+		nil, // restic backupper
+		newPVCSnapshotTracker(),
+		nil, // snapshot pool
+	).(*defaultResourceBackupper)
+
+	itemBackupperFactory := &mockItemBackupperFactory{}
+	defer itemBackupperFactory.AssertExpectations(t)
+	rb.itemBackupperFactory = itemBackupperFactory
+
+	itemBackupper := &mockItemBackupper{}
+	defer itemBackupper.AssertExpectations(t)
+
+	itemBackupperFactory.On("newItemBackupper",
+		backup,
+		namespaces,
+		resources,
+		backedUpItems,
+		mock.Anything,
+		podCommandExecutor,
+		tarWriter,
+		mock.Anything,
+		dynamicFactory,
+		discoveryHelper,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+	).Return(itemBackupper)
+
+	pod := arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"Pod","metadata":{"namespace":"ns-1","name":"pod-1"}}`)
+	podsClient := &arktest.FakeDynamicClient{}
+	defer podsClient.AssertExpectations(t)
+	dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{Version: "v1"}, podsResource, "").Return(podsClient, nil)
+	podsClient.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{Items: []unstructured.Unstructured{*pod}}, nil)
+	itemBackupper.On("backupItem", mock.AnythingOfType("*logrus.Entry"), pod, kuberesource.Pods).Return(nil)
+
+	// resources without items still record the version they were listed at
+	deploymentsClient := &arktest.FakeDynamicClient{}
+	defer deploymentsClient.AssertExpectations(t)
+	dynamicFactory.On("ClientForGroupVersionResource", appsGroupVersion, deploymentsResource, "").Return(deploymentsClient, nil)
+	deploymentsClient.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{}, nil)
+
+	// resources that couldn't be listed weren't captured at any version
+	networkPoliciesClient := &arktest.FakeDynamicClient{}
+	defer networkPoliciesClient.AssertExpectations(t)
+	dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}, networkPoliciesResource, "").Return(networkPoliciesClient, nil)
+	networkPoliciesClient.On("List", metav1.ListOptions{}).Return(&unstructured.UnstructuredList{}, errors.New("list error"))
+
+	require.NoError(t, rb.backupResource(v1Group, podsResource))
+	require.NoError(t, rb.backupResource(appsGroup, deploymentsResource))
+	require.NoError(t, rb.backupResource(networkingGroup, networkPoliciesResource))
+
+	assert.Equal(t, map[string]string{
+		"pods":             "v1",
+		"deployments.apps": "apps/v1beta1",
+	}, backup.Status.GroupVersions)
+}
+
 type mockItemBackupperFactory struct {
 	mock.Mock
 }
@@ -692,6 +1046,7 @@ func (ibf *mockItemBackupperFactory) newItemBackupper(
 	blockStore cloudprovider.BlockStore,
 	resticBackupper restic.Backupper,
 	resticSnapshotTracker *pvcSnapshotTracker,
+	snapshotPool *snapshotPool,
 ) ItemBackupper {
 	args := ibf.Called(
 		backup,
@@ -707,6 +1062,7 @@ func (ibf *mockItemBackupperFactory) newItemBackupper(
 		blockStore,
 		resticBackupper,
 		resticSnapshotTracker,
+		snapshotPool,
 	)
 	return args.Get(0).(ItemBackupper)
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
+)
+
+// synchronousPollInterval is how often RunSynchronous gets the backup to
+// check whether it's finished.
+var synchronousPollInterval = time.Second
+
+// RunSynchronous creates backup, which the Ark server then runs, and waits
+// for it to finish. It returns the backup's final status, whose phase is
+// the one it finished in, and an error if it didn't complete: if it
+// failed, partially failed, failed validation, was cancelled, or was
+// deleted while it ran. If ctx is done first, RunSynchronous stops waiting
+// and returns the backup's status so far and ctx's error; the backup
+// itself keeps running.
+func RunSynchronous(ctx context.Context, client arkv1client.BackupsGetter, backup *api.Backup) (api.BackupStatus, error) {
+	created, err := client.Backups(backup.Namespace).Create(backup)
+	if err != nil {
+		return api.BackupStatus{}, errors.Wrap(err, "error creating backup")
+	}
+	status := created.Status
+
+	err = wait.PollImmediateUntil(synchronousPollInterval, func() (bool, error) {
+		updated, err := client.Backups(created.Namespace).Get(created.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, errors.Errorf("backup %s/%s was deleted before it finished", created.Namespace, created.Name)
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "error getting backup %s/%s", created.Namespace, created.Name)
+		}
+		status = updated.Status
+
+		return isFinished(status.Phase), nil
+	}, ctx.Done())
+
+	if err == wait.ErrWaitTimeout {
+		return status, ctx.Err()
+	}
+	if err != nil {
+		return status, err
+	}
+
+	return status, finishedBackupError(created, status)
+}
+
+// isFinished returns whether a backup in phase has finished running.
+func isFinished(phase api.BackupPhase) bool {
+	switch phase {
+	case api.BackupPhaseCompleted, api.BackupPhaseCompletedEmpty, api.BackupPhasePartiallyFailed, api.BackupPhaseFailed,
+		api.BackupPhaseFailedValidation, api.BackupPhaseCancelled, api.BackupPhaseDeleting:
+		return true
+	default:
+		return false
+	}
+}
+
+// finishedBackupError returns an error describing why a finished backup
+// didn't complete, or nil if it did.
+func finishedBackupError(backup *api.Backup, status api.BackupStatus) error {
+	switch status.Phase {
+	case api.BackupPhaseCompleted:
+		return nil
+	case api.BackupPhaseFailedValidation:
+		return errors.Errorf("backup %s/%s failed validation: %s", backup.Namespace, backup.Name, strings.Join(status.ValidationErrors, "; "))
+	case api.BackupPhaseDeleting:
+		return errors.Errorf("backup %s/%s is being deleted", backup.Namespace, backup.Name)
+	}
+
+	if status.FailureReason != "" {
+		return errors.Errorf("backup %s/%s finished with phase %s: %s", backup.Namespace, backup.Name, status.Phase, status.FailureReason)
+	}
+	return errors.Errorf("backup %s/%s finished with phase %s", backup.Namespace, backup.Name, status.Phase)
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+// runFakeBackupController stands in for the Ark server: it waits for the
+// backup to be created, then moves it through phases, one per update.
+func runFakeBackupController(t *testing.T, client *fake.Clientset, namespace, name string, phases ...api.BackupPhase) {
+	backups := client.ArkV1().Backups(namespace)
+
+	for _, phase := range phases {
+		var backup *api.Backup
+		err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+			var err error
+			backup, err = backups.Get(name, metav1.GetOptions{})
+			return err == nil, nil
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		backup.Status.Phase = phase
+		if phase == api.BackupPhaseFailed {
+			backup.Status.FailureReason = "something broke"
+		}
+		if _, err := backups.Update(backup); !assert.NoError(t, err) {
+			return
+		}
+	}
+}
+
+func TestRunSynchronous(t *testing.T) {
+	defer func(interval time.Duration) { synchronousPollInterval = interval }(synchronousPollInterval)
+	synchronousPollInterval = time.Millisecond
+
+	tests := []struct {
+		name          string
+		phases        []api.BackupPhase
+		expectedPhase api.BackupPhase
+		expectedErr   string
+	}{
+		{
+			name:          "completed backup",
+			phases:        []api.BackupPhase{api.BackupPhaseInProgress, api.BackupPhaseCompleted},
+			expectedPhase: api.BackupPhaseCompleted,
+		},
+		{
+			name:          "failed backup",
+			phases:        []api.BackupPhase{api.BackupPhaseInProgress, api.BackupPhaseFailed},
+			expectedPhase: api.BackupPhaseFailed,
+			expectedErr:   "backup ark/backup-1 finished with phase Failed: something broke",
+		},
+		{
+			name:          "empty backup",
+			phases:        []api.BackupPhase{api.BackupPhaseInProgress, api.BackupPhaseCompletedEmpty},
+			expectedPhase: api.BackupPhaseCompletedEmpty,
+			expectedErr:   "backup ark/backup-1 finished with phase CompletedEmpty",
+		},
+		{
+			name:          "partially failed backup",
+			phases:        []api.BackupPhase{api.BackupPhaseInProgress, api.BackupPhasePartiallyFailed},
+			expectedPhase: api.BackupPhasePartiallyFailed,
+			expectedErr:   "backup ark/backup-1 finished with phase PartiallyFailed",
+		},
+		{
+			name:          "backup that failed validation",
+			phases:        []api.BackupPhase{api.BackupPhaseFailedValidation},
+			expectedPhase: api.BackupPhaseFailedValidation,
+			expectedErr:   "backup ark/backup-1 failed validation",
+		},
+		{
+			name:          "cancelled backup",
+			phases:        []api.BackupPhase{api.BackupPhaseInProgress, api.BackupPhaseCancelling, api.BackupPhaseCancelled},
+			expectedPhase: api.BackupPhaseCancelled,
+			expectedErr:   "backup ark/backup-1 finished with phase Cancelled",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").Backup
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				runFakeBackupController(t, client, "ark", "backup-1", test.phases...)
+			}()
+
+			status, err := RunSynchronous(context.Background(), client.ArkV1(), backup)
+			<-done
+
+			assert.Equal(t, test.expectedPhase, status.Phase)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestRunSynchronousContextCancelled(t *testing.T) {
+	defer func(interval time.Duration) { synchronousPollInterval = interval }(synchronousPollInterval)
+	synchronousPollInterval = time.Millisecond
+
+	client := fake.NewSimpleClientset()
+	backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").WithPhase(api.BackupPhaseNew).Backup
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	status, err := RunSynchronous(ctx, client.ArkV1(), backup)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, api.BackupPhaseNew, status.Phase)
+
+	// the backup is left to run.
+	_, err = client.ArkV1().Backups("ark").Get("backup-1", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestRunSynchronousBackupDeleted(t *testing.T) {
+	defer func(interval time.Duration) { synchronousPollInterval = interval }(synchronousPollInterval)
+	synchronousPollInterval = time.Millisecond
+
+	client := fake.NewSimpleClientset()
+	backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").Backup
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runFakeBackupController(t, client, "ark", "backup-1", api.BackupPhaseInProgress)
+		assert.NoError(t, client.ArkV1().Backups("ark").Delete("backup-1", nil))
+	}()
+
+	_, err := RunSynchronous(context.Background(), client.ArkV1(), backup)
+	<-done
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "was deleted before it finished")
+}
+
+func TestRunSynchronousCreateError(t *testing.T) {
+	backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").Backup
+	client := fake.NewSimpleClientset(backup.DeepCopy())
+
+	_, err := RunSynchronous(context.Background(), client.ArkV1(), backup)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error creating backup")
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import "sync"
+
+// snapshotPool runs a backup's volume snapshot jobs concurrently, with at
+// most a fixed number running at once so that the cloud provider's rate
+// limits aren't overwhelmed, and collects the errors they return.
+//
+// A nil *snapshotPool runs each job synchronously, when it's submitted.
+type snapshotPool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	errsLock sync.Mutex
+	errs     []error
+
+	// statusLock guards the fields of the backup's status that snapshot
+	// jobs record their results in.
+	statusLock sync.Mutex
+}
+
+// newSnapshotPool returns a snapshotPool that runs at most concurrency
+// jobs at once. A concurrency of less than 1 is treated as 1.
+func newSnapshotPool(concurrency int) *snapshotPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &snapshotPool{sem: make(chan struct{}, concurrency)}
+}
+
+// run starts job once fewer than the pool's concurrency jobs are running,
+// blocking until then. Its error is returned by wait. If p is nil, job is
+// run synchronously and its error is returned.
+func (p *snapshotPool) run(job func() error) error {
+	if p == nil {
+		return job()
+	}
+
+	p.sem <- struct{}{}
+	p.wg.Add(1)
+
+	go func() {
+		defer func() {
+			<-p.sem
+			p.wg.Done()
+		}()
+
+		if err := job(); err != nil {
+			p.errsLock.Lock()
+			p.errs = append(p.errs, err)
+			p.errsLock.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// wait waits for all of the jobs that have been run to finish, and returns
+// their errors.
+func (p *snapshotPool) wait() []error {
+	if p == nil {
+		return nil
+	}
+
+	p.wg.Wait()
+
+	p.errsLock.Lock()
+	defer p.errsLock.Unlock()
+
+	return p.errs
+}
+
+// withStatusLock calls fn while holding the lock that guards the backup's
+// status against concurrent updates by snapshot jobs.
+func (p *snapshotPool) withStatusLock(fn func()) {
+	if p == nil {
+		fn()
+		return
+	}
+
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	fn()
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/cloudprovider"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+// concurrencyTrackingBlockStore is a block store whose snapshots take a
+// little while, and that records the most snapshots that were in progress
+// at once.
+type concurrencyTrackingBlockStore struct {
+	cloudprovider.BlockStore
+
+	lock          sync.Mutex
+	active        int
+	maxActive     int
+	snapshotCalls int
+	failVolumes   map[string]bool
+}
+
+func (bs *concurrencyTrackingBlockStore) GetVolumeID(pv runtime.Unstructured) (string, error) {
+	return pv.UnstructuredContent()["metadata"].(map[string]interface{})["name"].(string), nil
+}
+
+func (bs *concurrencyTrackingBlockStore) CreateSnapshot(volumeID, volumeAZ string, tags map[string]string) (string, error) {
+	bs.lock.Lock()
+	bs.active++
+	bs.snapshotCalls++
+	if bs.active > bs.maxActive {
+		bs.maxActive = bs.active
+	}
+	bs.lock.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	bs.lock.Lock()
+	bs.active--
+	bs.lock.Unlock()
+
+	if bs.failVolumes[volumeID] {
+		return "", errors.New("snapshot failed")
+	}
+	return "snap-" + volumeID, nil
+}
+
+func (bs *concurrencyTrackingBlockStore) GetVolumeInfo(volumeID, volumeAZ string) (string, *int64, error) {
+	return "gp", nil, nil
+}
+
+func TestSnapshotPoolBoundsConcurrentSnapshots(t *testing.T) {
+	tests := []struct {
+		name        string
+		snapshots   int
+		concurrency int
+	}{
+		{name: "serial", snapshots: 5, concurrency: 1},
+		{name: "fewer snapshots than the concurrency", snapshots: 3, concurrency: 8},
+		{name: "more snapshots than the concurrency", snapshots: 20, concurrency: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				blockStore = &concurrencyTrackingBlockStore{}
+				pool       = newSnapshotPool(test.concurrency)
+				backup     = arktest.NewTestBackup().WithName("backup-1").Backup
+				ib         = &defaultItemBackupper{blockStore: blockStore, snapshotPool: pool}
+			)
+
+			for i := 0; i < test.snapshots; i++ {
+				pv := arktest.UnstructuredOrDie(fmt.Sprintf(`{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-%d"}}`, i))
+				require.NoError(t, ib.takePVSnapshot(pv, backup, arktest.NewLogger()))
+			}
+
+			assert.Empty(t, pool.wait())
+
+			expectedMax := test.concurrency
+			if test.snapshots < expectedMax {
+				expectedMax = test.snapshots
+			}
+			assert.Equal(t, test.snapshots, blockStore.snapshotCalls)
+			assert.Equal(t, expectedMax, blockStore.maxActive)
+
+			require.Len(t, backup.Status.VolumeBackups, test.snapshots)
+			for i := 0; i < test.snapshots; i++ {
+				name := fmt.Sprintf("pv-%d", i)
+				assert.Equal(t, "snap-"+name, backup.Status.VolumeBackups[name].SnapshotID)
+				assert.Equal(t, v1.VolumeBackupMethodSnapshot, backup.Status.VolumeBackupMethods[name])
+			}
+		})
+	}
+}
+
+func TestSnapshotPoolAggregatesErrors(t *testing.T) {
+	var (
+		blockStore = &concurrencyTrackingBlockStore{failVolumes: map[string]bool{"pv-1": true, "pv-3": true}}
+		pool       = newSnapshotPool(2)
+		backup     = arktest.NewTestBackup().WithName("backup-1").Backup
+		ib         = &defaultItemBackupper{blockStore: blockStore, snapshotPool: pool}
+	)
+
+	for i := 0; i < 4; i++ {
+		pv := arktest.UnstructuredOrDie(fmt.Sprintf(`{"apiVersion":"v1","kind":"PersistentVolume","metadata":{"name":"pv-%d"}}`, i))
+		require.NoError(t, ib.takePVSnapshot(pv, backup, arktest.NewLogger()))
+	}
+
+	assert.Len(t, pool.wait(), 2)
+	assert.Len(t, backup.Status.VolumeBackups, 2)
+	assert.Contains(t, backup.Status.VolumeBackups, "pv-0")
+	assert.Contains(t, backup.Status.VolumeBackups, "pv-2")
+}
+
+func TestNilSnapshotPoolRunsJobsSynchronously(t *testing.T) {
+	var pool *snapshotPool
+
+	ran := false
+	assert.EqualError(t, pool.run(func() error {
+		ran = true
+		return errors.New("job failed")
+	}), "job failed")
+	assert.True(t, ran)
+	assert.Empty(t, pool.wait())
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultThrottleBaseDelay = time.Second
+	defaultThrottleMaxDelay  = time.Minute
+)
+
+// ThrottleConfig configures how API clients back off when the API server
+// throttles them with 429 (Too Many Requests) responses.
+type ThrottleConfig struct {
+	// BaseDelay is the initial backoff after a throttled request. It doubles
+	// with each consecutive throttled response. Zero uses the default.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff. A Retry-After value sent by the API server
+	// is honored even if it's longer. Zero uses the default.
+	MaxDelay time.Duration
+
+	// MaxRetries is the number of times a throttled request is retried by
+	// the transport before its response is returned to the caller.
+	MaxRetries int
+}
+
+// NewThrottlingTransportWrapper returns a function suitable for use as a
+// rest.Config's WrapTransport that handles 429 responses from the API
+// server. When a request is throttled, all requests sent through the
+// transport are held back for the larger of the server's Retry-After value
+// and an exponential backoff, which decays again as requests succeed.
+// onThrottled, if non-nil, is called for every throttled response.
+func NewThrottlingTransportWrapper(config ThrottleConfig, onThrottled func()) func(http.RoundTripper) http.RoundTripper {
+	if config.BaseDelay == 0 {
+		config.BaseDelay = defaultThrottleBaseDelay
+	}
+	if config.MaxDelay == 0 {
+		config.MaxDelay = defaultThrottleMaxDelay
+	}
+	if onThrottled == nil {
+		onThrottled = func() {}
+	}
+
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &throttlingRoundTripper{
+			delegate:    rt,
+			config:      config,
+			onThrottled: onThrottled,
+		}
+	}
+}
+
+type throttlingRoundTripper struct {
+	delegate    http.RoundTripper
+	config      ThrottleConfig
+	onThrottled func()
+
+	lock sync.Mutex
+	// backoff is the current adaptive backoff. It's zero when requests
+	// aren't being throttled.
+	backoff time.Duration
+	// notBefore is the earliest time at which the next request may be sent.
+	notBefore time.Time
+}
+
+func (rt *throttlingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for retries := 0; ; retries++ {
+		if err := rt.wait(req); err != nil {
+			return nil, err
+		}
+
+		resp, err := rt.delegate.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			rt.succeeded()
+			return resp, nil
+		}
+
+		rt.onThrottled()
+		rt.throttled(resp)
+
+		if retries >= rt.config.MaxRetries || !canRetry(req) {
+			return resp, nil
+		}
+
+		// the response is discarded, so drain the body to allow the
+		// connection to be reused.
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			retry := *req
+			retry.Body = body
+			req = &retry
+		}
+	}
+}
+
+// wait blocks until requests may be sent again or the request's context
+// is done.
+func (rt *throttlingRoundTripper) wait(req *http.Request) error {
+	rt.lock.Lock()
+	delay := time.Until(rt.notBefore)
+	rt.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// throttled increases the backoff and holds back subsequent requests.
+func (rt *throttlingRoundTripper) throttled(resp *http.Response) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	rt.backoff *= 2
+	if rt.backoff < rt.config.BaseDelay {
+		rt.backoff = rt.config.BaseDelay
+	}
+	if rt.backoff > rt.config.MaxDelay {
+		rt.backoff = rt.config.MaxDelay
+	}
+
+	delay := rt.backoff
+	if retryAfter := retryAfter(resp); retryAfter > delay {
+		delay = retryAfter
+	}
+
+	if notBefore := time.Now().Add(delay); notBefore.After(rt.notBefore) {
+		rt.notBefore = notBefore
+	}
+}
+
+// succeeded decays the backoff after a request that wasn't throttled.
+func (rt *throttlingRoundTripper) succeeded() {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+
+	rt.backoff /= 2
+	if rt.backoff < rt.config.BaseDelay {
+		rt.backoff = 0
+	}
+}
+
+// retryAfter returns the duration given by the response's Retry-After
+// header, or zero if it's missing or not a number of seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// canRetry returns true if the request can safely be resent, i.e. it has
+// no body or its body can be recreated.
+func canRetry(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
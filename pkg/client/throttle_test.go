@@ -0,0 +1,141 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRoundTripper returns the given status codes in order, then 200s,
+// recording the body of each request it receives.
+type fakeRoundTripper struct {
+	statusCodes []int
+	bodies      []string
+}
+
+func (rt *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body string
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+	rt.bodies = append(rt.bodies, body)
+
+	code := http.StatusOK
+	if len(rt.statusCodes) > 0 {
+		code, rt.statusCodes = rt.statusCodes[0], rt.statusCodes[1:]
+	}
+
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func TestThrottlingRoundTripperRetries(t *testing.T) {
+	delegate := &fakeRoundTripper{statusCodes: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}}
+
+	throttledCount := 0
+	rt := NewThrottlingTransportWrapper(
+		ThrottleConfig{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, MaxRetries: 3},
+		func() { throttledCount++ },
+	)(delegate)
+
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader("body"))
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, throttledCount)
+	// the request body should be resent with each retry
+	assert.Equal(t, []string{"body", "body", "body"}, delegate.bodies)
+}
+
+func TestThrottlingRoundTripperRetriesExhausted(t *testing.T) {
+	delegate := &fakeRoundTripper{statusCodes: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}}
+
+	rt := NewThrottlingTransportWrapper(
+		ThrottleConfig{BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond, MaxRetries: 1},
+		nil,
+	)(delegate)
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Len(t, delegate.bodies, 2)
+}
+
+func TestThrottlingRoundTripperBackoff(t *testing.T) {
+	rt := &throttlingRoundTripper{
+		config: ThrottleConfig{BaseDelay: time.Second, MaxDelay: 4 * time.Second},
+	}
+	resp := &http.Response{Header: http.Header{}}
+
+	// consecutive throttled responses double the backoff, up to the max
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		rt.throttled(resp)
+		assert.Equal(t, expected, rt.backoff)
+	}
+
+	// successful responses decay the backoff back to zero
+	for _, expected := range []time.Duration{2 * time.Second, time.Second, 0} {
+		rt.succeeded()
+		assert.Equal(t, expected, rt.backoff)
+	}
+
+	// a Retry-After longer than the backoff holds back requests for that long
+	resp.Header.Set("Retry-After", "30")
+	rt.throttled(resp)
+	assert.True(t, time.Until(rt.notBefore) > 20*time.Second)
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "5", expected: 5 * time.Second},
+		{value: "-1", expected: 0},
+		{value: "Wed, 21 Oct 2015 07:28:00 GMT", expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			resp.Header.Set("Retry-After", test.value)
+			assert.Equal(t, test.expected, retryAfter(resp))
+		})
+	}
+}
@@ -17,12 +17,16 @@ limitations under the License.
 package aws
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/pkg/errors"
@@ -33,9 +37,14 @@ import (
 
 const (
 	s3URLKey            = "s3Url"
-	kmsKeyIDKey         = "kmsKeyId"
+	kmsKeyIDKey         = cloudprovider.KMSKeyIDConfigKey
+	sseKey              = cloudprovider.ServerSideEncryptionConfigKey
+	credentialsFileKey  = cloudprovider.CredentialsFileConfigKey
 	s3ForcePathStyleKey = "s3ForcePathStyle"
 	bucketKey           = "bucket"
+	objectLockKey       = cloudprovider.ObjectLockRetentionConfigKey
+	partSizeKey         = "multipartPartSize"
+	thresholdKey        = "multipartThreshold"
 )
 
 type objectStore struct {
@@ -43,6 +52,15 @@ type objectStore struct {
 	s3         *s3.S3
 	s3Uploader *s3manager.Uploader
 	kmsKeyID   string
+	sse        string
+	// objectLockRetention is how long objects are locked for, in
+	// compliance mode, from when they're put.
+	objectLockRetention time.Duration
+	// partSize is the size, in bytes, of the parts of objects larger
+	// than multipartThreshold, which are uploaded in parts rather than
+	// in a single request.
+	partSize           int64
+	multipartThreshold int64
 }
 
 func NewObjectStore(logger logrus.FieldLogger) cloudprovider.ObjectStore {
@@ -54,15 +72,23 @@ func (o *objectStore) Init(config map[string]string) error {
 		region              = config[regionKey]
 		s3URL               = config[s3URLKey]
 		kmsKeyID            = config[kmsKeyIDKey]
+		sse                 = config[sseKey]
+		credentialsFile     = config[credentialsFileKey]
 		s3ForcePathStyleVal = config[s3ForcePathStyleKey]
+		objectLockVal       = config[objectLockKey]
+		partSizeVal         = config[partSizeKey]
+		thresholdVal        = config[thresholdKey]
 
 		// note that bucket is automatically added to the config map
 		// by the server from the ObjectStorageProviderConfig so
 		// doesn't need to be explicitly set by the user within
 		// config.
-		bucket           = config[bucketKey]
-		s3ForcePathStyle bool
-		err              error
+		bucket              = config[bucketKey]
+		s3ForcePathStyle    bool
+		objectLockRetention time.Duration
+		partSize            = int64(s3manager.DefaultUploadPartSize)
+		threshold           int64
+		err                 error
 	)
 
 	if s3ForcePathStyleVal != "" {
@@ -71,6 +97,33 @@ func (o *objectStore) Init(config map[string]string) error {
 		}
 	}
 
+	if objectLockVal != "" {
+		if objectLockRetention, err = time.ParseDuration(objectLockVal); err != nil {
+			return errors.Wrapf(err, "could not parse %s (expected duration)", objectLockKey)
+		}
+	}
+
+	if partSizeVal != "" {
+		if partSize, err = strconv.ParseInt(partSizeVal, 10, 64); err != nil {
+			return errors.Wrapf(err, "could not parse %s (expected int)", partSizeKey)
+		}
+		if partSize < s3manager.MinUploadPartSize {
+			return errors.Errorf("%s must be at least %d bytes", partSizeKey, s3manager.MinUploadPartSize)
+		}
+	}
+
+	threshold = partSize
+	if thresholdVal != "" {
+		if threshold, err = strconv.ParseInt(thresholdVal, 10, 64); err != nil {
+			return errors.Wrapf(err, "could not parse %s (expected int)", thresholdKey)
+		}
+		// objects no larger than a part are always uploaded in a single
+		// request.
+		if threshold < partSize {
+			return errors.Errorf("%s must be at least %s (%d bytes)", thresholdKey, partSizeKey, partSize)
+		}
+	}
+
 	// AWS (not an alternate S3-compatible API) and region not
 	// explicitly specified: determine the bucket's region
 	if s3URL == "" && region == "" {
@@ -86,6 +139,12 @@ func (o *objectStore) Init(config map[string]string) error {
 		WithRegion(region).
 		WithS3ForcePathStyle(s3ForcePathStyle)
 
+	// use the default profile of the credentials file, if one was
+	// provided, instead of the server's credentials
+	if credentialsFile != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewSharedCredentials(credentialsFile, ""))
+	}
+
 	if s3URL != "" {
 		if !IsValidS3URLScheme(s3URL) {
 			return errors.Errorf("Invalid s3Url: %s", s3URL)
@@ -112,28 +171,142 @@ func (o *objectStore) Init(config map[string]string) error {
 	o.s3 = s3.New(sess)
 	o.s3Uploader = s3manager.NewUploader(sess)
 	o.kmsKeyID = kmsKeyID
+	o.sse = sse
+	o.objectLockRetention = objectLockRetention
+	o.partSize = partSize
+	o.multipartThreshold = threshold
 
 	return nil
 }
 
 func (o *objectStore) PutObject(bucket, key string, body io.Reader) error {
+	return o.PutObjectWithMetadata(bucket, key, body, cloudprovider.ObjectMetadata{})
+}
+
+func (o *objectStore) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
 	req := &s3manager.UploadInput{
 		Bucket: &bucket,
 		Key:    &key,
 		Body:   body,
 	}
 
+	if metadata.ContentType != "" {
+		req.ContentType = &metadata.ContentType
+	}
+	if metadata.ContentEncoding != "" {
+		req.ContentEncoding = &metadata.ContentEncoding
+	}
+
+	switch {
+	// if sse is not empty, use it as the encryption algorithm, along with
+	// kmsKeyID if it's set
+	case o.sse != "":
+		req.ServerSideEncryption = &o.sse
+		if o.kmsKeyID != "" {
+			req.SSEKMSKeyId = &o.kmsKeyID
+		}
 	// if kmsKeyID is not empty, enable "aws:kms" encryption
-	if o.kmsKeyID != "" {
+	case o.kmsKeyID != "":
 		req.ServerSideEncryption = aws.String("aws:kms")
 		req.SSEKMSKeyId = &o.kmsKeyID
 	}
 
-	_, err := o.s3Uploader.Upload(req)
+	body, multipart, err := o.multipartOptions(body)
+	if err != nil {
+		return errors.Wrapf(err, "error reading object %s", key)
+	}
+	req.Body = body
+
+	opts := []func(*s3manager.Uploader){multipart}
+	if o.objectLockRetention > 0 {
+		opts = append(opts, withObjectLock(time.Now().Add(o.objectLockRetention)))
+	}
+
+	_, err = o.s3Uploader.Upload(req, opts...)
 
 	return errors.Wrapf(err, "error putting object %s", key)
 }
 
+// multipartOptions returns body, and an option that makes the uploader put
+// it in a single request if it's no larger than o.multipartThreshold, or
+// in parts of o.partSize if it is. If a multipart upload fails, it's
+// aborted, so that the parts that were uploaded aren't left behind, and
+// billed for. If body's size can't be found by seeking, up to
+// o.multipartThreshold bytes of it are read into memory to find out
+// whether it's larger, and the returned body replays them.
+func (o *objectStore) multipartOptions(body io.Reader) (io.Reader, func(*s3manager.Uploader), error) {
+	partSize := o.partSize
+	if partSize == 0 {
+		partSize = s3manager.DefaultUploadPartSize
+	}
+
+	size, known, err := remainingSize(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the uploader itself only knows to use a single request for bodies
+	// no larger than a part, so larger thresholds are checked here.
+	if !known && o.multipartThreshold > partSize {
+		head, err := ioutil.ReadAll(io.LimitReader(body, o.multipartThreshold+1))
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		if int64(len(head)) <= o.multipartThreshold {
+			body, size, known = bytes.NewReader(head), int64(len(head)), true
+		} else {
+			body = io.MultiReader(bytes.NewReader(head), body)
+		}
+	}
+
+	if known && size > partSize && size <= o.multipartThreshold {
+		partSize = size
+	}
+
+	return body, func(u *s3manager.Uploader) {
+		u.PartSize = partSize
+		u.LeavePartsOnError = false
+	}, nil
+}
+
+// remainingSize returns the number of bytes left to read from body, and
+// whether that could be found, which it can if body is an io.Seeker.
+func remainingSize(body io.Reader) (int64, bool, error) {
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return 0, false, nil
+	}
+
+	pos, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	if _, err := seeker.Seek(pos, io.SeekStart); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	return end - pos, true, nil
+}
+
+// withObjectLock locks the uploaded object, in compliance mode, until
+// retainUntil. The SDK's UploadInput predates object locks, so their
+// headers are set on the requests that create the object directly.
+func withObjectLock(retainUntil time.Time) func(*s3manager.Uploader) {
+	return func(u *s3manager.Uploader) {
+		u.RequestOptions = append(u.RequestOptions, func(r *request.Request) {
+			switch r.Operation.Name {
+			case "PutObject", "CreateMultipartUpload":
+				r.HTTPRequest.Header.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+				r.HTTPRequest.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", retainUntil.UTC().Format(time.RFC3339))
+			}
+		})
+	}
+}
+
 func (o *objectStore) GetObject(bucket, key string) (io.ReadCloser, error) {
 	req := &s3.GetObjectInput{
 		Bucket: &bucket,
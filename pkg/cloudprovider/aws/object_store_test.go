@@ -0,0 +1,321 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+const mib = 1024 * 1024
+
+// fakeS3 records the requests an s3manager.Uploader makes to upload
+// objects: the size of each single-request upload, and the number and
+// size of each part of multipart uploads.
+type fakeS3 struct {
+	s3iface.S3API
+
+	mu         sync.Mutex
+	puts       []int
+	parts      map[int64]int
+	completed  []string
+	aborted    []string
+	failOnPart int64
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{parts: make(map[int64]int)}
+}
+
+func (s *fakeS3) PutObjectRequest(input *s3.PutObjectInput) (*request.Request, *s3.PutObjectOutput) {
+	data, _ := ioutil.ReadAll(input.Body)
+
+	s.mu.Lock()
+	s.puts = append(s.puts, len(data))
+	s.mu.Unlock()
+
+	output := &s3.PutObjectOutput{}
+	return request.New(aws.Config{}, metadata.ClientInfo{}, request.Handlers{}, nil, &request.Operation{Name: "PutObject"}, input, output), output
+}
+
+func (s *fakeS3) CreateMultipartUploadWithContext(aws.Context, *s3.CreateMultipartUploadInput, ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (s *fakeS3) UploadPartWithContext(_ aws.Context, input *s3.UploadPartInput, _ ...request.Option) (*s3.UploadPartOutput, error) {
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if *input.PartNumber == s.failOnPart {
+		return nil, errors.New("connection reset")
+	}
+	s.parts[*input.PartNumber] = len(data)
+
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func (s *fakeS3) CompleteMultipartUploadWithContext(_ aws.Context, input *s3.CompleteMultipartUploadInput, _ ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.completed = append(s.completed, *input.UploadId)
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (s *fakeS3) AbortMultipartUploadWithContext(_ aws.Context, input *s3.AbortMultipartUploadInput, _ ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.aborted = append(s.aborted, *input.UploadId)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// partSizes returns the sizes of the uploaded parts, in part number order.
+func (s *fakeS3) partSizes() []int {
+	var numbers []int
+	for number := range s.parts {
+		numbers = append(numbers, int(number))
+	}
+	sort.Ints(numbers)
+
+	var sizes []int
+	for _, number := range numbers {
+		sizes = append(sizes, s.parts[int64(number)])
+	}
+	return sizes
+}
+
+// unseekable hides its reader's other methods, like a plugin's streamed
+// body, so its size can't be found up front.
+type unseekable struct {
+	io.Reader
+}
+
+func TestPutObjectMultipart(t *testing.T) {
+	tests := []struct {
+		name          string
+		partSize      int64
+		threshold     int64
+		size          int
+		seekable      bool
+		expectedPuts  []int
+		expectedParts []int
+	}{
+		{
+			name:         "object no larger than a part is put in one request",
+			partSize:     5 * mib,
+			threshold:    5 * mib,
+			size:         5 * mib,
+			seekable:     true,
+			expectedPuts: []int{5 * mib},
+		},
+		{
+			name:          "object larger than a part is put in parts",
+			partSize:      5 * mib,
+			threshold:     5 * mib,
+			size:          12 * mib,
+			seekable:      true,
+			expectedParts: []int{5 * mib, 5 * mib, 2 * mib},
+		},
+		{
+			name:          "unseekable object larger than a part is put in parts",
+			partSize:      5 * mib,
+			threshold:     5 * mib,
+			size:          12 * mib,
+			expectedParts: []int{5 * mib, 5 * mib, 2 * mib},
+		},
+		{
+			name:         "object below a larger threshold is put in one request",
+			partSize:     5 * mib,
+			threshold:    8 * mib,
+			size:         7 * mib,
+			seekable:     true,
+			expectedPuts: []int{7 * mib},
+		},
+		{
+			name:         "unseekable object below a larger threshold is put in one request",
+			partSize:     5 * mib,
+			threshold:    8 * mib,
+			size:         8 * mib,
+			expectedPuts: []int{8 * mib},
+		},
+		{
+			name:          "object above a larger threshold is put in parts",
+			partSize:      5 * mib,
+			threshold:     8 * mib,
+			size:          9 * mib,
+			seekable:      true,
+			expectedParts: []int{5 * mib, 4 * mib},
+		},
+		{
+			name:          "unseekable object above a larger threshold is put in parts",
+			partSize:      5 * mib,
+			threshold:     8 * mib,
+			size:          8*mib + 1,
+			expectedParts: []int{5 * mib, 3*mib + 1},
+		},
+		{
+			name:          "part size is configurable",
+			partSize:      6 * mib,
+			threshold:     6 * mib,
+			size:          13 * mib,
+			seekable:      true,
+			expectedParts: []int{6 * mib, 6 * mib, 1 * mib},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeS3()
+			o := &objectStore{
+				log:                arktest.NewLogger(),
+				s3Uploader:         s3manager.NewUploaderWithClient(fake),
+				partSize:           test.partSize,
+				multipartThreshold: test.threshold,
+			}
+
+			var body io.Reader = bytes.NewReader(make([]byte, test.size))
+			if !test.seekable {
+				body = unseekable{body}
+			}
+
+			require.NoError(t, o.PutObject("bucket", "key", body))
+
+			assert.Equal(t, test.expectedPuts, fake.puts)
+			assert.Equal(t, test.expectedParts, fake.partSizes())
+			if len(test.expectedParts) > 0 {
+				assert.Equal(t, []string{"upload-1"}, fake.completed)
+			}
+			assert.Empty(t, fake.aborted)
+		})
+	}
+}
+
+func TestPutObjectMultipartAbortsOnError(t *testing.T) {
+	fake := newFakeS3()
+	fake.failOnPart = 2
+
+	o := &objectStore{
+		log:                arktest.NewLogger(),
+		s3Uploader:         s3manager.NewUploaderWithClient(fake),
+		partSize:           5 * mib,
+		multipartThreshold: 5 * mib,
+	}
+
+	err := o.PutObject("bucket", "key", bytes.NewReader(make([]byte, 12*mib)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error putting object key")
+
+	// the upload's aborted, so that its parts aren't left behind, rather
+	// than completed.
+	assert.Equal(t, []string{"upload-1"}, fake.aborted)
+	assert.Empty(t, fake.completed)
+}
+
+func TestInitMultipartConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            map[string]string
+		expectedPartSize  int64
+		expectedThreshold int64
+		expectedErr       string
+	}{
+		{
+			name:              "defaults",
+			config:            map[string]string{},
+			expectedPartSize:  s3manager.DefaultUploadPartSize,
+			expectedThreshold: s3manager.DefaultUploadPartSize,
+		},
+		{
+			name:              "part size defaults the threshold",
+			config:            map[string]string{partSizeKey: "67108864"},
+			expectedPartSize:  64 * mib,
+			expectedThreshold: 64 * mib,
+		},
+		{
+			name:              "part size and threshold",
+			config:            map[string]string{partSizeKey: "67108864", thresholdKey: "1073741824"},
+			expectedPartSize:  64 * mib,
+			expectedThreshold: 1024 * mib,
+		},
+		{
+			name:        "unparseable part size",
+			config:      map[string]string{partSizeKey: "64Mi"},
+			expectedErr: "could not parse multipartPartSize (expected int)",
+		},
+		{
+			name:        "part size below S3's minimum",
+			config:      map[string]string{partSizeKey: "1048576"},
+			expectedErr: "multipartPartSize must be at least 5242880 bytes",
+		},
+		{
+			name:        "threshold below the part size",
+			config:      map[string]string{partSizeKey: "67108864", thresholdKey: "10485760"},
+			expectedErr: "multipartThreshold must be at least multipartPartSize (67108864 bytes)",
+		},
+	}
+
+	credentialsFile, err := ioutil.TempFile("", "credentials")
+	require.NoError(t, err)
+	defer os.Remove(credentialsFile.Name())
+	_, err = credentialsFile.WriteString("[default]\naws_access_key_id = id\naws_secret_access_key = secret\n")
+	require.NoError(t, err)
+	require.NoError(t, credentialsFile.Close())
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// an s3Url and region keep Init from looking up the bucket's
+			// region.
+			test.config[s3URLKey] = "http://minio:9000"
+			test.config[regionKey] = "minio"
+			test.config[credentialsFileKey] = credentialsFile.Name()
+
+			o := &objectStore{log: arktest.NewLogger()}
+			err := o.Init(test.config)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPartSize, o.partSize)
+			assert.Equal(t, test.expectedThreshold, o.multipartThreshold)
+		})
+	}
+}
@@ -119,6 +119,10 @@ func (o *objectStore) Init(config map[string]string) error {
 }
 
 func (o *objectStore) PutObject(bucket, key string, body io.Reader) error {
+	return o.PutObjectWithMetadata(bucket, key, body, cloudprovider.ObjectMetadata{})
+}
+
+func (o *objectStore) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
 	container, err := getContainerReference(o.blobClient, bucket)
 	if err != nil {
 		return err
@@ -129,6 +133,9 @@ func (o *objectStore) PutObject(bucket, key string, body io.Reader) error {
 		return err
 	}
 
+	blob.Properties.ContentType = metadata.ContentType
+	blob.Properties.ContentEncoding = metadata.ContentEncoding
+
 	return errors.WithStack(blob.CreateBlockBlobFromReader(body, nil))
 }
 
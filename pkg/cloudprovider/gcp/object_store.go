@@ -37,16 +37,21 @@ const credentialsEnvVar = "GOOGLE_APPLICATION_CREDENTIALS"
 
 // bucketWriter wraps the GCP SDK functions for accessing object store so they can be faked for testing.
 type bucketWriter interface {
-	// getWriteCloser returns an io.WriteCloser that can be used to upload data to the specified bucket for the specified key.
-	getWriteCloser(bucket, key string) io.WriteCloser
+	// getWriteCloser returns an io.WriteCloser that can be used to upload data to the specified bucket for the specified key,
+	// storing the object with metadata.
+	getWriteCloser(bucket, key string, metadata cloudprovider.ObjectMetadata) io.WriteCloser
 }
 
 type writer struct {
 	client *storage.Client
 }
 
-func (w *writer) getWriteCloser(bucket, key string) io.WriteCloser {
-	return w.client.Bucket(bucket).Object(key).NewWriter(context.Background())
+func (w *writer) getWriteCloser(bucket, key string, metadata cloudprovider.ObjectMetadata) io.WriteCloser {
+	writer := w.client.Bucket(bucket).Object(key).NewWriter(context.Background())
+	writer.ContentType = metadata.ContentType
+	writer.ContentEncoding = metadata.ContentEncoding
+
+	return writer
 }
 
 type objectStore struct {
@@ -62,7 +67,12 @@ func NewObjectStore(logger logrus.FieldLogger) cloudprovider.ObjectStore {
 }
 
 func (o *objectStore) Init(config map[string]string) error {
-	credentialsFile := os.Getenv(credentialsEnvVar)
+	// a credentials file provided in the config is used instead of the
+	// server's credentials
+	credentialsFile := config[cloudprovider.CredentialsFileConfigKey]
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv(credentialsEnvVar)
+	}
 	if credentialsFile == "" {
 		return errors.Errorf("%s is undefined", credentialsEnvVar)
 	}
@@ -77,16 +87,16 @@ func (o *objectStore) Init(config map[string]string) error {
 		return errors.WithStack(err)
 	}
 	if jwtConfig.Email == "" {
-		return errors.Errorf("credentials file pointed to by %s does not contain an email", credentialsEnvVar)
+		return errors.Errorf("credentials file %s does not contain an email", credentialsFile)
 	}
 	if len(jwtConfig.PrivateKey) == 0 {
-		return errors.Errorf("credentials file pointed to by %s does not contain a private key", credentialsEnvVar)
+		return errors.Errorf("credentials file %s does not contain a private key", credentialsFile)
 	}
 
 	o.googleAccessID = jwtConfig.Email
 	o.privateKey = jwtConfig.PrivateKey
 
-	client, err := storage.NewClient(context.Background(), option.WithScopes(storage.ScopeReadWrite))
+	client, err := storage.NewClient(context.Background(), option.WithScopes(storage.ScopeReadWrite), option.WithCredentialsFile(credentialsFile))
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -98,7 +108,11 @@ func (o *objectStore) Init(config map[string]string) error {
 }
 
 func (o *objectStore) PutObject(bucket, key string, body io.Reader) error {
-	w := o.bucketWriter.getWriteCloser(bucket, key)
+	return o.PutObjectWithMetadata(bucket, key, body, cloudprovider.ObjectMetadata{})
+}
+
+func (o *objectStore) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
+	w := o.bucketWriter.getWriteCloser(bucket, key, metadata)
 
 	// The writer returned by NewWriter is asynchronous, so errors aren't guaranteed
 	// until Close() is called
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/heptio/ark/pkg/cloudprovider"
 	arktest "github.com/heptio/ark/pkg/util/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -43,14 +44,16 @@ func newMockWriteCloser(writeErr, closeErr error) *mockWriteCloser {
 }
 
 type fakeWriter struct {
-	wc *mockWriteCloser
+	wc       *mockWriteCloser
+	metadata cloudprovider.ObjectMetadata
 }
 
 func newFakeWriter(wc *mockWriteCloser) *fakeWriter {
 	return &fakeWriter{wc: wc}
 }
 
-func (fw *fakeWriter) getWriteCloser(bucket, name string) io.WriteCloser {
+func (fw *fakeWriter) getWriteCloser(bucket, name string, metadata cloudprovider.ObjectMetadata) io.WriteCloser {
+	fw.metadata = metadata
 	return fw.wc
 }
 
@@ -97,3 +100,16 @@ func TestPutObject(t *testing.T) {
 		})
 	}
 }
+
+func TestPutObjectWithMetadata(t *testing.T) {
+	fw := newFakeWriter(newMockWriteCloser(nil, nil))
+	o := NewObjectStore(arktest.NewLogger()).(*objectStore)
+	o.bucketWriter = fw
+
+	metadata := cloudprovider.ObjectMetadata{ContentType: "application/json", ContentEncoding: "gzip"}
+	assert.NoError(t, o.PutObjectWithMetadata("bucket", "key", strings.NewReader("contents"), metadata))
+	assert.Equal(t, metadata, fw.metadata)
+
+	assert.NoError(t, o.PutObject("bucket", "key", strings.NewReader("contents")))
+	assert.Equal(t, cloudprovider.ObjectMetadata{}, fw.metadata)
+}
@@ -93,7 +93,10 @@ func (o *InMemoryObjectStore) ListCommonPrefixes(bucket, prefix, delimiter strin
 	// For each key, check if it has an instance of the delimiter *after* the prefix.
 	// If not, skip it; if so, return the prefix of the key up to/including the delimiter.
 
+	// like real object stores, return each prefix once, however many keys
+	// share it.
 	var prefixes []string
+	seen := make(map[string]bool)
 	for _, key := range keys {
 		// everything after 'prefix'
 		afterPrefix := key[len(prefix):]
@@ -107,6 +110,10 @@ func (o *InMemoryObjectStore) ListCommonPrefixes(bucket, prefix, delimiter strin
 		// return the prefix, plus everything after the prefix and before
 		// the delimiter, plus the delimiter
 		fullPrefix := prefix + afterPrefix[0:delimiterStart] + delimiter
+		if seen[fullPrefix] {
+			continue
+		}
+		seen[fullPrefix] = true
 
 		prefixes = append(prefixes, fullPrefix)
 	}
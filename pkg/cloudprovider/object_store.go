@@ -21,6 +21,30 @@ import (
 	"time"
 )
 
+const (
+	// ServerSideEncryptionConfigKey is the key in the config map passed to
+	// ObjectStore.Init that holds the server-side encryption algorithm
+	// from the location's ServerSideEncryption.
+	ServerSideEncryptionConfigKey = "serverSideEncryption"
+
+	// KMSKeyIDConfigKey is the key in the config map passed to
+	// ObjectStore.Init that holds the key management service key ID from
+	// the location's ServerSideEncryption.
+	KMSKeyIDConfigKey = "kmsKeyId"
+
+	// CredentialsFileConfigKey is the key in the config map passed to
+	// ObjectStore.Init that holds the path to a file of credentials, in
+	// the provider's credentials file format, to use instead of the
+	// object store's default credentials.
+	CredentialsFileConfigKey = "credentialsFile"
+
+	// ObjectLockRetentionConfigKey is the key in the config map passed to
+	// ObjectStore.Init that holds, as a duration string, how long objects
+	// are locked for from when they're put, from the location's
+	// ObjectLockRetention.
+	ObjectLockRetentionConfigKey = "objectLockRetention"
+)
+
 // ObjectStore exposes basic object-storage operations required
 // by Ark.
 type ObjectStore interface {
@@ -60,3 +84,34 @@ type ObjectStore interface {
 	// CreateSignedURL creates a pre-signed URL for the given bucket and key that expires after ttl.
 	CreateSignedURL(bucket, key string, ttl time.Duration) (string, error)
 }
+
+// ObjectMetadata is metadata that's stored with an object and returned as
+// headers when it's downloaded.
+type ObjectMetadata struct {
+	// ContentType is the object's media type, e.g. application/json.
+	ContentType string
+
+	// ContentEncoding is the encoding, e.g. gzip, that the object's
+	// contents must be decoded with to get a ContentType document.
+	ContentEncoding string
+}
+
+// ObjectMetadataPutter is implemented by ObjectStores that can store
+// metadata with the objects they put. ObjectStores needn't implement it;
+// objects put in those that don't are stored with the default metadata.
+type ObjectMetadataPutter interface {
+	// PutObjectWithMetadata is like PutObject, except that the object is
+	// stored with metadata. Empty metadata fields are left unset.
+	PutObjectWithMetadata(bucket, key string, body io.Reader, metadata ObjectMetadata) error
+}
+
+// PutObjectWithMetadata puts an object in objectStore with metadata if
+// objectStore is an ObjectMetadataPutter, or with PutObject, ignoring
+// metadata, if it isn't.
+func PutObjectWithMetadata(objectStore ObjectStore, bucket, key string, body io.Reader, metadata ObjectMetadata) error {
+	if putter, ok := objectStore.(ObjectMetadataPutter); ok {
+		return putter.PutObjectWithMetadata(bucket, key, body, metadata)
+	}
+
+	return objectStore.PutObject(bucket, key, body)
+}
@@ -35,7 +35,10 @@ func NewCommand(f client.Factory) *cobra.Command {
 		NewLogsCommand(f),
 		NewDescribeCommand(f, "describe"),
 		NewDownloadCommand(f),
+		NewDiffCommand(f),
 		NewDeleteCommand(f, "delete"),
+		NewCancelCommand(f),
+		NewRepairCommand(f),
 	)
 
 	return c
@@ -0,0 +1,53 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/heptio/ark/pkg/client"
+	"github.com/heptio/ark/pkg/cmd"
+)
+
+// NewCancelCommand creates a new command that cancels a backup.
+func NewCancelCommand(f client.Factory) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "cancel BACKUP",
+		Short: "Cancel a backup that's new or in progress",
+		Long: `Cancel a backup that's new or in progress.
+
+A new backup is cancelled without running. An in-progress backup is stopped,
+and only its log is uploaded to its storage location.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(c *cobra.Command, args []string) {
+			arkClient, err := f.Client()
+			cmd.CheckError(err)
+
+			_, err = arkClient.ArkV1().Backups(f.Namespace()).Patch(args[0], types.MergePatchType, []byte(`{"spec":{"cancel":true}}`))
+			cmd.CheckError(errors.Wrapf(err, "error cancelling backup %s", args[0]))
+
+			fmt.Printf("Request to cancel backup %q submitted successfully.\n", args[0])
+		},
+	}
+
+	return c
+}
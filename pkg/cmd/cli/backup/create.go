@@ -18,21 +18,28 @@ package backup
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/heptio/ark/pkg/generated/informers/externalversions/ark/v1"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	corev1api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	pkgbackup "github.com/heptio/ark/pkg/backup"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cmd"
 	"github.com/heptio/ark/pkg/cmd/util/flag"
 	"github.com/heptio/ark/pkg/cmd/util/output"
 	arkclient "github.com/heptio/ark/pkg/generated/clientset/versioned"
+	"github.com/heptio/ark/pkg/notify"
+	"github.com/heptio/ark/pkg/util/collections"
 )
 
 func NewCreateCommand(f client.Factory, use string) *cobra.Command {
@@ -51,6 +58,8 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
 
 	o.BindFlags(c.Flags())
 	o.BindWait(c.Flags())
+	o.BindTransfer(c.Flags())
+	o.BindDryRun(c.Flags())
 	output.BindFlags(c.Flags())
 	output.ClearOutputFlagDefault(c)
 
@@ -58,18 +67,43 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
 }
 
 type CreateOptions struct {
-	Name                    string
-	TTL                     time.Duration
-	SnapshotVolumes         flag.OptionalBool
-	IncludeNamespaces       flag.StringArray
-	ExcludeNamespaces       flag.StringArray
-	IncludeResources        flag.StringArray
-	ExcludeResources        flag.StringArray
-	Labels                  flag.Map
-	Selector                flag.LabelSelector
-	IncludeClusterResources flag.OptionalBool
-	Wait                    bool
-	StorageLocation         string
+	Name                          string
+	TTL                           time.Duration
+	Timeout                       time.Duration
+	SnapshotVolumes               flag.OptionalBool
+	FileCopyVolumes               bool
+	IncludeNamespaces             flag.StringArray
+	ExcludeNamespaces             flag.StringArray
+	TerminatingNamespaces         string
+	NamespaceMatching             string
+	IncludeResources              flag.StringArray
+	ExcludeResources              flag.StringArray
+	OrderedResources              flag.StringArray
+	Labels                        flag.Map
+	Selector                      flag.LabelSelector
+	ExcludeSelector               flag.LabelSelector
+	VolumeSnapshotExcludeSelector flag.LabelSelector
+	IncludeClusterResources       flag.OptionalBool
+	IncludeServiceAccountTokens   bool
+	MaxItemSizeBytes              int64
+	EnabledPlugins                flag.StringArray
+	DisabledPlugins               flag.StringArray
+	Wait                          bool
+	StorageLocation               string
+	MirrorStorageLocations        flag.StringArray
+	UploadPolicy                  string
+	PartialFailurePolicy          string
+	OnListError                   string
+	Description                   string
+	Metadata                      flag.Map
+	BaseBackup                    string
+	BackupSet                     string
+	BackupSetOrder                int
+	Transfer                      bool
+	DryRun                        bool
+	IntegrityManifest             bool
+	NotifyWebhook                 string
+	NotifyWebhookSecret           string
 
 	client arkclient.Interface
 }
@@ -79,6 +113,7 @@ func NewCreateOptions() *CreateOptions {
 		TTL:                     30 * 24 * time.Hour,
 		IncludeNamespaces:       flag.NewStringArray("*"),
 		Labels:                  flag.NewMap(),
+		Metadata:                flag.NewMap(),
 		SnapshotVolumes:         flag.NewOptionalBool(nil),
 		IncludeClusterResources: flag.NewOptionalBool(nil),
 	}
@@ -86,20 +121,44 @@ func NewCreateOptions() *CreateOptions {
 
 func (o *CreateOptions) BindFlags(flags *pflag.FlagSet) {
 	flags.DurationVar(&o.TTL, "ttl", o.TTL, "how long before the backup can be garbage collected")
+	flags.DurationVar(&o.Timeout, "timeout", o.Timeout, "how long collecting the backup's items may take before the backup is marked as failed (0 uses the server's default)")
 	flags.Var(&o.IncludeNamespaces, "include-namespaces", "namespaces to include in the backup (use '*' for all namespaces)")
 	flags.Var(&o.ExcludeNamespaces, "exclude-namespaces", "namespaces to exclude from the backup")
+	flags.StringVar(&o.TerminatingNamespaces, "terminating-namespaces", "", fmt.Sprintf("what to do with included namespaces that are being deleted. Valid values are %s (the default) and %s.", api.TerminatingNamespacePolicySkip, api.TerminatingNamespacePolicyInclude))
+	flags.StringVar(&o.NamespaceMatching, "namespace-matching", "", fmt.Sprintf("how included and excluded namespaces are matched. Valid values are %s (the default), for exact names, and %s, for shell-style glob patterns such as 'team-*'.", api.NamespaceMatchingExact, api.NamespaceMatchingGlob))
 	flags.Var(&o.IncludeResources, "include-resources", "resources to include in the backup, formatted as resource.group, such as storageclasses.storage.k8s.io (use '*' for all resources)")
 	flags.Var(&o.ExcludeResources, "exclude-resources", "resources to exclude from the backup, formatted as resource.group, such as storageclasses.storage.k8s.io")
+	flags.Var(&o.OrderedResources, "ordered-resources", "resources to back up first, in the order listed, formatted as resource.group, such as customresourcedefinitions.apiextensions.k8s.io")
 	flags.Var(&o.Labels, "labels", "labels to apply to the backup")
 	flags.StringVar(&o.StorageLocation, "storage-location", "", "location in which to store the backup")
+	flags.Var(&o.MirrorStorageLocations, "mirror-storage-locations", "additional locations to upload the backup to")
+	flags.StringVar(&o.UploadPolicy, "upload-policy", "", fmt.Sprintf("which uploads to the backup's locations must succeed for it to be completed. Valid values are %s (the default), %s and %s.", api.UploadPolicyRequirePrimary, api.UploadPolicyRequireAny, api.UploadPolicyRequireAll))
+	flags.StringVar(&o.PartialFailurePolicy, "partial-failure-policy", "", fmt.Sprintf("what to do when backing up the items in a namespace fails. Valid values are %s (the default), which fails the backup, and %s, which records the error and marks the backup as %s.", api.PartialFailurePolicyFail, api.PartialFailurePolicyContinue, api.BackupPhasePartiallyFailed))
+	flags.StringVar(&o.OnListError, "on-list-error", "", fmt.Sprintf("what to do with a resource whose items can't be listed. Valid values are %s (the default), which handles the error under the partial failure policy, and %s, which leaves the resource out of the backup and records a warning.", api.ListErrorPolicyFail, api.ListErrorPolicySkip))
+	flags.StringVar(&o.Description, "description", "", "free-form text describing the backup, such as why it was taken")
+	flags.Var(&o.Metadata, "metadata", "free-form key=value metadata to record with the backup, such as the git SHA of the application being backed up")
+	flags.StringVar(&o.BaseBackup, "base-backup", "", "name of a completed full backup to take this backup incrementally from, so only the resources that changed since it are stored")
+	flags.StringVar(&o.BackupSet, "backup-set", "", "name of a backup set to group the backup with, so related backups can be listed and restored together")
+	flags.IntVar(&o.BackupSetOrder, "backup-set-order", 0, "order of the backup within its backup set; backups with a lower order are restored first")
 	flags.VarP(&o.Selector, "selector", "l", "only back up resources matching this label selector")
+	flags.Var(&o.ExcludeSelector, "exclude-selector", "don't back up resources matching this label selector, even if they match --selector")
 	f := flags.VarPF(&o.SnapshotVolumes, "snapshot-volumes", "", "take snapshots of PersistentVolumes as part of the backup")
 	// this allows the user to just specify "--snapshot-volumes" as shorthand for "--snapshot-volumes=true"
 	// like a normal bool flag
 	f.NoOptDefVal = "true"
+	flags.Var(&o.VolumeSnapshotExcludeSelector, "volume-snapshot-exclude-selector", "don't snapshot PersistentVolumes that match, or whose PersistentVolumeClaims match, this label selector")
 
 	f = flags.VarPF(&o.IncludeClusterResources, "include-cluster-resources", "", "include cluster-scoped resources in the backup")
 	f.NoOptDefVal = "true"
+
+	flags.BoolVar(&o.FileCopyVolumes, "file-copy-volumes", o.FileCopyVolumes, "copy the files in persistent volume claims mounted by pods, using restic, when their persistent volumes can't be snapshotted")
+	flags.BoolVar(&o.IncludeServiceAccountTokens, "include-service-account-tokens", o.IncludeServiceAccountTokens, "include Secrets of type kubernetes.io/service-account-token in the backup")
+	flags.Int64Var(&o.MaxItemSizeBytes, "max-item-size-bytes", 0, "skip items whose JSON is larger than this many bytes, recording them in the backup's status (0 means no limit)")
+	flags.Var(&o.EnabledPlugins, "enabled-plugins", "names of the backup item action plugins to run during the backup (all of them if not set)")
+	flags.Var(&o.DisabledPlugins, "disabled-plugins", "names of backup item action plugins not to run during the backup")
+	flags.BoolVar(&o.IntegrityManifest, "integrity-manifest", o.IntegrityManifest, "upload a manifest listing each file in the backup's tarball alongside it, so restores can verify the tarball with --verify-manifest")
+	flags.StringVar(&o.NotifyWebhook, "notify-webhook", "", "http or https URL to post a JSON summary of the backup to once it's finished")
+	flags.StringVar(&o.NotifyWebhookSecret, "notify-webhook-secret", "", "secret, in the backup's namespace, to sign the --notify-webhook requests with, formatted as name/key")
 }
 
 // BindWait binds the wait flag separately so it is not called by other create
@@ -108,11 +167,76 @@ func (o *CreateOptions) BindWait(flags *pflag.FlagSet) {
 	flags.BoolVarP(&o.Wait, "wait", "w", o.Wait, "wait for the operation to complete")
 }
 
+// BindDryRun binds the dry-run flag separately since it only applies to
+// individual backups, not schedules.
+func (o *CreateOptions) BindDryRun(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.DryRun, "dry-run", o.DryRun, "only collect the backup's items, listing them in its status, without snapshotting volumes or uploading anything")
+}
+
+// BindTransfer binds the transfer flag separately since it only applies to
+// individual backups, not schedules.
+func (o *CreateOptions) BindTransfer(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.Transfer, "transfer", o.Transfer, "stream the backup to the server's transfer endpoint, for a restore on another cluster to receive, instead of storing it in object storage")
+}
+
 func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Factory) error {
 	if err := output.ValidateFlags(c); err != nil {
 		return err
 	}
 
+	if errs := pkgbackup.ValidateBackupSet(o.BackupSet, o.backupSetOrder()); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	if o.VolumeSnapshotExcludeSelector.LabelSelector != nil && o.SnapshotVolumes.Value != nil && !*o.SnapshotVolumes.Value {
+		return errors.New("--volume-snapshot-exclude-selector can't be used with --snapshot-volumes=false")
+	}
+
+	if o.MaxItemSizeBytes < 0 {
+		return errors.New("--max-item-size-bytes must not be negative")
+	}
+
+	switch api.TerminatingNamespacePolicy(o.TerminatingNamespaces) {
+	case "", api.TerminatingNamespacePolicySkip, api.TerminatingNamespacePolicyInclude:
+	default:
+		return errors.Errorf("--terminating-namespaces must be %s or %s", api.TerminatingNamespacePolicySkip, api.TerminatingNamespacePolicyInclude)
+	}
+
+	switch api.NamespaceMatching(o.NamespaceMatching) {
+	case "", api.NamespaceMatchingExact:
+	case api.NamespaceMatchingGlob:
+		if errs := collections.ValidateGlobs(append(o.IncludeNamespaces, o.ExcludeNamespaces...)); len(errs) > 0 {
+			return errors.Wrap(errs[0], "invalid --include-namespaces or --exclude-namespaces")
+		}
+	default:
+		return errors.Errorf("--namespace-matching must be %s or %s", api.NamespaceMatchingExact, api.NamespaceMatchingGlob)
+	}
+
+	switch api.UploadPolicy(o.UploadPolicy) {
+	case "", api.UploadPolicyRequireAny, api.UploadPolicyRequireAll, api.UploadPolicyRequirePrimary:
+	default:
+		return errors.Errorf("--upload-policy must be %s, %s or %s", api.UploadPolicyRequireAny, api.UploadPolicyRequireAll, api.UploadPolicyRequirePrimary)
+	}
+
+	switch api.PartialFailurePolicy(o.PartialFailurePolicy) {
+	case "", api.PartialFailurePolicyFail, api.PartialFailurePolicyContinue:
+	default:
+		return errors.Errorf("--partial-failure-policy must be %s or %s", api.PartialFailurePolicyFail, api.PartialFailurePolicyContinue)
+	}
+
+	switch api.ListErrorPolicy(o.OnListError) {
+	case "", api.ListErrorPolicyFail, api.ListErrorPolicySkip:
+	default:
+		return errors.Errorf("--on-list-error must be %s or %s", api.ListErrorPolicyFail, api.ListErrorPolicySkip)
+	}
+
+	if o.NotifyWebhookSecret != "" && o.NotifyWebhook == "" {
+		return errors.New("--notify-webhook-secret can't be used without --notify-webhook")
+	}
+	if errs := notify.ValidateWebhook(o.BackupNotifyWebhook()); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
 	if o.StorageLocation != "" {
 		if _, err := o.client.ArkV1().BackupStorageLocations(f.Namespace()).Get(o.StorageLocation, metav1.GetOptions{}); err != nil {
 			return err
@@ -122,6 +246,54 @@ func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Facto
 	return nil
 }
 
+// BackupLabels returns the labels to apply to the backup, including its
+// backup set labels, if any.
+func (o *CreateOptions) BackupLabels() map[string]string {
+	labels := o.Labels.Data()
+
+	if o.BackupSet != "" {
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[api.BackupSetLabel] = o.BackupSet
+
+		if order := o.backupSetOrder(); order != "" {
+			labels[api.BackupSetOrderLabel] = order
+		}
+	}
+
+	return labels
+}
+
+// BackupNotifyWebhook returns the backup's notify webhook, or nil if it
+// doesn't have one.
+func (o *CreateOptions) BackupNotifyWebhook() *api.BackupNotifyWebhook {
+	if o.NotifyWebhook == "" {
+		return nil
+	}
+
+	webhook := &api.BackupNotifyWebhook{URL: o.NotifyWebhook}
+	if o.NotifyWebhookSecret != "" {
+		name, key := o.NotifyWebhookSecret, ""
+		if i := strings.Index(name, "/"); i >= 0 {
+			name, key = name[:i], name[i+1:]
+		}
+		webhook.SecretRef = &corev1api.SecretKeySelector{
+			LocalObjectReference: corev1api.LocalObjectReference{Name: name},
+			Key:                  key,
+		}
+	}
+
+	return webhook
+}
+
+func (o *CreateOptions) backupSetOrder() string {
+	if o.BackupSetOrder == 0 {
+		return ""
+	}
+	return strconv.Itoa(o.BackupSetOrder)
+}
+
 func (o *CreateOptions) Complete(args []string, f client.Factory) error {
 	o.Name = args[0]
 	client, err := f.Client()
@@ -138,21 +310,48 @@ func (o *CreateOptions) Run(c *cobra.Command, f client.Factory) error {
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: f.Namespace(),
 			Name:      o.Name,
-			Labels:    o.Labels.Data(),
+			Labels:    o.BackupLabels(),
 		},
 		Spec: api.BackupSpec{
-			IncludedNamespaces: o.IncludeNamespaces,
-			ExcludedNamespaces: o.ExcludeNamespaces,
-			IncludedResources:  o.IncludeResources,
-			ExcludedResources:  o.ExcludeResources,
-			LabelSelector:      o.Selector.LabelSelector,
-			SnapshotVolumes:    o.SnapshotVolumes.Value,
-			TTL:                metav1.Duration{Duration: o.TTL},
-			IncludeClusterResources: o.IncludeClusterResources.Value,
-			StorageLocation:         o.StorageLocation,
+			IncludedNamespaces:            o.IncludeNamespaces,
+			ExcludedNamespaces:            o.ExcludeNamespaces,
+			TerminatingNamespacePolicy:    api.TerminatingNamespacePolicy(o.TerminatingNamespaces),
+			NamespaceMatching:             api.NamespaceMatching(o.NamespaceMatching),
+			IncludedResources:             o.IncludeResources,
+			ExcludedResources:             o.ExcludeResources,
+			OrderedResources:              o.OrderedResources,
+			LabelSelector:                 o.Selector.LabelSelector,
+			ExcludedLabelSelector:         o.ExcludeSelector.LabelSelector,
+			SnapshotVolumes:               o.SnapshotVolumes.Value,
+			VolumeSnapshotExcludeSelector: o.VolumeSnapshotExcludeSelector.LabelSelector,
+			FileCopyVolumes:               o.FileCopyVolumes,
+			TTL:                           metav1.Duration{Duration: o.TTL},
+			BackupTimeout:                 metav1.Duration{Duration: o.Timeout},
+			IncludeClusterResources:       o.IncludeClusterResources.Value,
+			IncludeServiceAccountTokens:   o.IncludeServiceAccountTokens,
+			MaxItemSizeBytes:              o.MaxItemSizeBytes,
+			EnabledPlugins:                o.EnabledPlugins,
+			DisabledPlugins:               o.DisabledPlugins,
+			StorageLocation:               o.StorageLocation,
+			MirrorStorageLocations:        o.MirrorStorageLocations,
+			UploadPolicy:                  api.UploadPolicy(o.UploadPolicy),
+			PartialFailurePolicy:          api.PartialFailurePolicy(o.PartialFailurePolicy),
+			OnListError:                   api.ListErrorPolicy(o.OnListError),
+			Description:                   o.Description,
+			Metadata:                      o.Metadata.Data(),
+			BaseBackup:                    o.BaseBackup,
+			DryRun:                        o.DryRun,
+			IntegrityManifest:             o.IntegrityManifest,
+			NotifyWebhook:                 o.BackupNotifyWebhook(),
 		},
 	}
 
+	if o.Transfer {
+		backup.Annotations = map[string]string{
+			api.TransferAnnotation: "true",
+		}
+	}
+
 	if printed, err := output.PrintWithFormat(c, backup); printed || err != nil {
 		return err
 	}
@@ -0,0 +1,189 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
+	"github.com/heptio/ark/pkg/client"
+	"github.com/heptio/ark/pkg/cmd"
+	"github.com/heptio/ark/pkg/cmd/util/downloadrequest"
+	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
+	"github.com/heptio/ark/pkg/transform"
+)
+
+func NewDiffCommand(f client.Factory) *cobra.Command {
+	o := NewDiffOptions()
+
+	c := &cobra.Command{
+		Use:   "diff OLD_BACKUP NEW_BACKUP",
+		Short: "Show the resources that differ between two backups",
+		Long: `Show the resources that were added, removed, or changed between two backups.
+
+By default only the backups' inventories are compared. Use --contents to also
+compare the contents of items present in both backups.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(c *cobra.Command, args []string) {
+			cmd.CheckError(o.Complete(args))
+			cmd.CheckError(o.Validate(c, args))
+			cmd.CheckError(o.Run(c, f))
+		},
+	}
+
+	o.BindFlags(c.Flags())
+
+	return c
+}
+
+type DiffOptions struct {
+	OldBackup string
+	NewBackup string
+	Contents  bool
+	Output    string
+	Timeout   time.Duration
+}
+
+func NewDiffOptions() *DiffOptions {
+	return &DiffOptions{
+		Timeout: time.Minute,
+	}
+}
+
+func (o *DiffOptions) BindFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.Contents, "contents", o.Contents, "also compare the contents of items present in both backups")
+	flags.StringVarP(&o.Output, "output", "o", o.Output, "output format. Valid values are 'json' or empty for a human-readable summary")
+	flags.DurationVar(&o.Timeout, "timeout", o.Timeout, "maximum time to wait to process each download request")
+}
+
+func (o *DiffOptions) Validate(c *cobra.Command, args []string) error {
+	if o.Output != "" && o.Output != "json" {
+		return errors.Errorf("invalid output format %q. Valid values are 'json' or empty", o.Output)
+	}
+	return nil
+}
+
+func (o *DiffOptions) Complete(args []string) error {
+	o.OldBackup = args[0]
+	o.NewBackup = args[1]
+	return nil
+}
+
+func (o *DiffOptions) Run(c *cobra.Command, f client.Factory) error {
+	arkClient, err := f.Client()
+	if err != nil {
+		return err
+	}
+
+	oldInventory, oldDigests, err := o.readInventory(arkClient.ArkV1(), f.Namespace(), o.OldBackup)
+	if err != nil {
+		return err
+	}
+
+	newInventory, newDigests, err := o.readInventory(arkClient.ArkV1(), f.Namespace(), o.NewBackup)
+	if err != nil {
+		return err
+	}
+
+	diffs := archive.DiffInventories(oldInventory, newInventory, oldDigests, newDigests)
+
+	if o.Output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(diffs)
+	}
+
+	printDiffs(os.Stdout, diffs)
+	return nil
+}
+
+// readInventory streams the contents of the named backup, undoing any
+// transform stages recorded on it, and reads its inventory, without writing
+// the tarball to disk.
+func (o *DiffOptions) readInventory(client arkv1client.ArkV1Interface, namespace, name string) (archive.Inventory, archive.ItemDigests, error) {
+	backup, err := client.Backups(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	pipeline, err := transform.ForBackup(backup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(downloadrequest.Stream(client, namespace, name, v1.DownloadTargetKindBackupContents, pw, o.Timeout))
+	}()
+	defer pr.Close()
+
+	contents, err := pipeline.Unwrap(pr)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error reading contents of backup "+name)
+	}
+
+	var (
+		inventory archive.Inventory
+		digests   archive.ItemDigests
+	)
+	if o.Contents {
+		inventory, digests, err = archive.ReadInventoryWithDigests(contents, archive.CompressionAlgorithmForBackup(backup))
+	} else {
+		inventory, err = archive.ReadInventory(contents, archive.CompressionAlgorithmForBackup(backup))
+	}
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error reading contents of backup "+name)
+	}
+
+	return inventory, digests, nil
+}
+
+func printDiffs(w io.Writer, diffs []archive.ResourceDiff) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No differences found.")
+		return
+	}
+
+	for _, diff := range diffs {
+		if diff.Namespace == "" {
+			fmt.Fprintf(w, "%s (cluster-scoped):\n", diff.GroupResource)
+		} else {
+			fmt.Fprintf(w, "%s (namespace %s):\n", diff.GroupResource, diff.Namespace)
+		}
+
+		for _, name := range diff.Added {
+			fmt.Fprintf(w, "  + %s\n", name)
+		}
+		for _, name := range diff.Removed {
+			fmt.Fprintf(w, "  - %s\n", name)
+		}
+		for _, name := range diff.Changed {
+			fmt.Fprintf(w, "  ~ %s\n", name)
+		}
+	}
+}
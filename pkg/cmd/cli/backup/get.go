@@ -17,6 +17,8 @@ limitations under the License.
 package backup
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,7 +30,10 @@ import (
 )
 
 func NewGetCommand(f client.Factory, use string) *cobra.Command {
-	var listOptions metav1.ListOptions
+	var (
+		listOptions metav1.ListOptions
+		backupSet   string
+	)
 
 	c := &cobra.Command{
 		Use:   use,
@@ -49,6 +54,10 @@ func NewGetCommand(f client.Factory, use string) *cobra.Command {
 					backups.Items = append(backups.Items, *backup)
 				}
 			} else {
+				if backupSet != "" {
+					listOptions.LabelSelector = appendSelector(listOptions.LabelSelector, fmt.Sprintf("%s=%s", api.BackupSetLabel, backupSet))
+				}
+
 				backups, err = arkClient.ArkV1().Backups(f.Namespace()).List(listOptions)
 				cmd.CheckError(err)
 			}
@@ -59,8 +68,17 @@ func NewGetCommand(f client.Factory, use string) *cobra.Command {
 	}
 
 	c.Flags().StringVarP(&listOptions.LabelSelector, "selector", "l", listOptions.LabelSelector, "only show items matching this label selector")
+	c.Flags().StringVar(&backupSet, "backup-set", backupSet, "only show backups in this backup set")
 
 	output.BindFlags(c.Flags())
 
 	return c
 }
+
+// appendSelector adds a requirement to a label selector string.
+func appendSelector(selector, requirement string) string {
+	if selector == "" {
+		return requirement
+	}
+	return selector + "," + requirement
+}
@@ -17,19 +17,32 @@ limitations under the License.
 package backup
 
 import (
+	"io"
 	"os"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/net"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
 	"github.com/heptio/ark/pkg/apis/ark/v1"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cmd"
 	"github.com/heptio/ark/pkg/cmd/util/downloadrequest"
+	"github.com/heptio/ark/pkg/controller"
 )
 
 func NewLogsCommand(f client.Factory) *cobra.Command {
-	timeout := time.Minute
+	var (
+		timeout    = time.Minute
+		follow     bool
+		serverPort = "8086"
+	)
 
 	c := &cobra.Command{
 		Use:   "logs BACKUP",
@@ -39,12 +52,70 @@ func NewLogsCommand(f client.Factory) *cobra.Command {
 			arkClient, err := f.Client()
 			cmd.CheckError(err)
 
+			if follow {
+				backup, err := arkClient.ArkV1().Backups(f.Namespace()).Get(args[0], metav1.GetOptions{})
+				cmd.CheckError(err)
+
+				if backup.Status.Phase == v1.BackupPhaseInProgress {
+					kubeClient, err := f.KubeClient()
+					cmd.CheckError(err)
+
+					err = followBackupLog(kubeClient.CoreV1(), f.Namespace(), args[0], serverPort, os.Stdout)
+					// a backup that finished before its log could be
+					// followed has uploaded it, so it's downloaded below.
+					if !apierrors.IsNotFound(err) {
+						cmd.CheckError(err)
+						return
+					}
+				}
+			}
+
 			err = downloadrequest.Stream(arkClient.ArkV1(), f.Namespace(), args[0], v1.DownloadTargetKindBackupLog, os.Stdout, timeout)
 			cmd.CheckError(err)
 		},
 	}
 
 	c.Flags().DurationVar(&timeout, "timeout", timeout, "how long to wait to receive logs")
+	c.Flags().BoolVarP(&follow, "follow", "f", follow, "if the backup is in progress, stream its log from the Ark server as it's written, until the backup finishes")
+	c.Flags().StringVar(&serverPort, "server-port", serverPort, "the port of the Ark server's --backup-log-address, which in-progress backups' logs are streamed from")
 
 	return c
 }
+
+// followBackupLog streams the log of an in-progress backup to w from a
+// running Ark server pod, through the Kubernetes API server's proxy.
+func followBackupLog(client corev1client.CoreV1Interface, namespace, name, port string, w io.Writer) error {
+	pods, err := client.Pods(namespace).List(metav1.ListOptions{LabelSelector: "component=ark"})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var server string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1api.PodRunning {
+			server = pod.Name
+			break
+		}
+	}
+	if server == "" {
+		return errors.Errorf("no running Ark server pod found in namespace %s", namespace)
+	}
+
+	stream, err := client.RESTClient().Get().
+		Namespace(namespace).
+		Resource("pods").
+		SubResource("proxy").
+		Name(net.JoinSchemeNamePort("http", server, port)).
+		Suffix(controller.BackupLogPath, namespace, name).
+		Stream()
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return err
+		}
+		return errors.Wrapf(err, "error streaming backup log from Ark server pod %s; is its --backup-log-address set, on port %s?", server, port)
+	}
+	defer stream.Close()
+
+	_, err = io.Copy(w, stream)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/client"
+	"github.com/heptio/ark/pkg/cmd"
+)
+
+// NewRepairCommand creates a new command that requests the repair of a
+// backup's artifacts in its storage location.
+func NewRepairCommand(f client.Factory) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "repair BACKUP",
+		Short: "Re-upload a completed backup's missing artifacts",
+		Long: `Re-upload whichever of a completed backup's artifacts are missing from its
+storage location and can be reconstructed from its tarball.
+
+The backup's metadata and summary are rebuilt from its tarball, and its
+checksum and content index are recomputed from it. A missing tarball or log
+can't be reconstructed. Which artifacts were repaired, and which couldn't
+be, is recorded in the backup's ` + api.RepairResultAnnotation + ` annotation.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(c *cobra.Command, args []string) {
+			arkClient, err := f.Client()
+			cmd.CheckError(err)
+
+			patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}}}`, api.RepairRequestedAnnotation)
+			_, err = arkClient.ArkV1().Backups(f.Namespace()).Patch(args[0], types.MergePatchType, []byte(patch))
+			cmd.CheckError(errors.Wrapf(err, "error requesting repair of backup %s", args[0]))
+
+			fmt.Printf("Request to repair backup %q submitted successfully.\nRun `ark backup describe %s` to see the result.\n", args[0], args[0])
+		},
+	}
+
+	return c
+}
@@ -18,6 +18,7 @@ package backuplocation
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -30,6 +31,7 @@ import (
 	"github.com/heptio/ark/pkg/cmd"
 	"github.com/heptio/ark/pkg/cmd/util/flag"
 	"github.com/heptio/ark/pkg/cmd/util/output"
+	"github.com/heptio/ark/pkg/persistence"
 )
 
 func NewCreateCommand(f client.Factory, use string) *cobra.Command {
@@ -54,12 +56,13 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
 }
 
 type CreateOptions struct {
-	Name     string
-	Provider string
-	Bucket   string
-	Prefix   string
-	Config   flag.Map
-	Labels   flag.Map
+	Name      string
+	Provider  string
+	Bucket    string
+	Prefix    string
+	KeyLayout string
+	Config    flag.Map
+	Labels    flag.Map
 }
 
 func NewCreateOptions() *CreateOptions {
@@ -72,6 +75,7 @@ func (o *CreateOptions) BindFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.Provider, "provider", o.Provider, "name of the backup storage provider (e.g. aws, azure, gcp)")
 	flags.StringVar(&o.Bucket, "bucket", o.Bucket, "name of the object storage bucket where backups should be stored")
 	flags.StringVar(&o.Prefix, "prefix", o.Prefix, "prefix under which all Ark data should be stored within the bucket. Optional.")
+	flags.StringVar(&o.KeyLayout, "key-layout", o.KeyLayout, fmt.Sprintf("layout of the object keys of backups and restores, one of %s. Optional; defaults to %s.", strings.Join(persistence.KeyLayoutNames(), ", "), persistence.FlatKeyLayout))
 	flags.Var(&o.Config, "config", "configuration key-value pairs")
 	flags.Var(&o.Labels, "labels", "labels to apply to the backup storage location")
 }
@@ -89,6 +93,10 @@ func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Facto
 		return errors.New("--bucket is required")
 	}
 
+	if _, err := persistence.GetKeyLayout(o.KeyLayout); err != nil {
+		return errors.Wrap(err, "invalid --key-layout")
+	}
+
 	return nil
 }
 
@@ -108,8 +116,9 @@ func (o *CreateOptions) Run(c *cobra.Command, f client.Factory) error {
 			Provider: o.Provider,
 			StorageType: api.StorageType{
 				ObjectStorage: &api.ObjectStorageLocation{
-					Bucket: o.Bucket,
-					Prefix: o.Prefix,
+					Bucket:    o.Bucket,
+					Prefix:    o.Prefix,
+					KeyLayout: o.KeyLayout,
 				},
 			},
 			Config: o.Config.Data(),
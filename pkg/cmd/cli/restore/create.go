@@ -18,6 +18,7 @@ package restore
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,9 +26,11 @@ import (
 	"github.com/spf13/pflag"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/backup"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cmd"
 	"github.com/heptio/ark/pkg/cmd/util/flag"
@@ -40,7 +43,7 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
 	o := NewCreateOptions()
 
 	c := &cobra.Command{
-		Use:   use + " [RESTORE_NAME] [--from-backup BACKUP_NAME | --from-schedule SCHEDULE_NAME]",
+		Use:   use + " [RESTORE_NAME] [--from-backup BACKUP_NAME | --from-schedule SCHEDULE_NAME | --from-backup-set BACKUP_SET]",
 		Short: "Create a restore",
 		Example: `  # create a restore named "restore-1" from backup "backup-1"
   ark restore create restore-1 --from-backup backup-1
@@ -50,6 +53,9 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
  
   # create a restore from the latest successful backup triggered by schedule "schedule-1"
   ark restore create --from-schedule schedule-1
+
+  # restore each completed backup in backup set "set-1", one at a time, in order
+  ark restore create --from-backup-set set-1
   `,
 		Args: cobra.MaximumNArgs(1),
 		Run: func(c *cobra.Command, args []string) {
@@ -69,6 +75,7 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
 type CreateOptions struct {
 	BackupName              string
 	ScheduleName            string
+	BackupSet               string
 	RestoreName             string
 	RestoreVolumes          flag.OptionalBool
 	Labels                  flag.Map
@@ -79,6 +86,12 @@ type CreateOptions struct {
 	NamespaceMappings       flag.Map
 	Selector                flag.LabelSelector
 	IncludeClusterResources flag.OptionalBool
+	Transfer                bool
+	StorageLocation         string
+	VerifyItemCounts        bool
+	VerifyManifest          bool
+	ExistingResourcePolicy  *flag.Enum
+	ImmutableFieldPolicy    *flag.Enum
 	Wait                    bool
 
 	client arkclient.Interface
@@ -91,12 +104,15 @@ func NewCreateOptions() *CreateOptions {
 		NamespaceMappings:       flag.NewMap().WithEntryDelimiter(",").WithKeyValueDelimiter(":"),
 		RestoreVolumes:          flag.NewOptionalBool(nil),
 		IncludeClusterResources: flag.NewOptionalBool(nil),
+		ExistingResourcePolicy:  flag.NewEnum(string(api.ExistingResourcePolicyNone), string(api.ExistingResourcePolicyNone), string(api.ExistingResourcePolicyUpdate)),
+		ImmutableFieldPolicy:    flag.NewEnum(string(api.ImmutableFieldPolicySkip), string(api.ImmutableFieldPolicySkip), string(api.ImmutableFieldPolicyRecreate), string(api.ImmutableFieldPolicyFail)),
 	}
 }
 
 func (o *CreateOptions) BindFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.BackupName, "from-backup", "", "backup to restore from")
 	flags.StringVar(&o.ScheduleName, "from-schedule", "", "schedule to restore from")
+	flags.StringVar(&o.BackupSet, "from-backup-set", "", "backup set to restore from; each of its completed backups is restored in order, waiting for each restore to complete before starting the next")
 	flags.Var(&o.IncludeNamespaces, "include-namespaces", "namespaces to include in the restore (use '*' for all namespaces)")
 	flags.Var(&o.ExcludeNamespaces, "exclude-namespaces", "namespaces to exclude from the restore")
 	flags.Var(&o.NamespaceMappings, "namespace-mappings", "namespace mappings from name in the backup to desired restored name in the form src1:dst1,src2:dst2,...")
@@ -112,6 +128,12 @@ func (o *CreateOptions) BindFlags(flags *pflag.FlagSet) {
 	f = flags.VarPF(&o.IncludeClusterResources, "include-cluster-resources", "", "include cluster-scoped resources in the restore")
 	f.NoOptDefVal = "true"
 
+	flags.BoolVar(&o.Transfer, "transfer", o.Transfer, "receive the backup from the server's transfer endpoint, as it's streamed from another cluster, instead of reading it from object storage")
+	flags.StringVar(&o.StorageLocation, "storage-location", "", "backup storage location to read the backup from, instead of the one it was written to, e.g. after its bucket has been migrated")
+	flags.Var(o.ExistingResourcePolicy, "existing-resource-policy", fmt.Sprintf("what to do with items that already exist in the cluster and differ from the backup. Valid values are %s.", strings.Join(o.ExistingResourcePolicy.AllowedValues(), ", ")))
+	flags.Var(o.ImmutableFieldPolicy, "immutable-field-policy", fmt.Sprintf("what to do when updating an existing item fails because of immutable fields, if --existing-resource-policy=update. Valid values are %s.", strings.Join(o.ImmutableFieldPolicy.AllowedValues(), ", ")))
+	flags.BoolVar(&o.VerifyItemCounts, "verify-item-counts", o.VerifyItemCounts, "check the number of items restored for each resource against the backup's contents, and warn about any that weren't restored")
+	flags.BoolVar(&o.VerifyManifest, "verify-manifest", o.VerifyManifest, "check the backup's tarball against its integrity manifest before restoring anything, and fail the restore if they differ")
 	flags.BoolVarP(&o.Wait, "wait", "w", o.Wait, "wait for the operation to complete")
 }
 
@@ -123,6 +145,9 @@ func (o *CreateOptions) Complete(args []string, f client.Factory) error {
 		if o.ScheduleName != "" {
 			sourceName = o.ScheduleName
 		}
+		if o.BackupSet != "" {
+			sourceName = o.BackupSet
+		}
 
 		o.RestoreName = fmt.Sprintf("%s-%s", sourceName, time.Now().Format("20060102150405"))
 	}
@@ -137,12 +162,22 @@ func (o *CreateOptions) Complete(args []string, f client.Factory) error {
 }
 
 func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Factory) error {
-	if o.BackupName != "" && o.ScheduleName != "" {
-		return errors.New("either a backup or schedule must be specified, but not both")
+	sources := 0
+	for _, source := range []string{o.BackupName, o.ScheduleName, o.BackupSet} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("exactly one of a backup, schedule, or backup set must be specified")
+	}
+
+	if o.Transfer && o.BackupName == "" {
+		return errors.New("a backup must be specified when restoring from a transfer endpoint")
 	}
 
-	if o.BackupName == "" && o.ScheduleName == "" {
-		return errors.New("either a backup or schedule must be specified, but not both")
+	if o.Transfer && o.StorageLocation != "" {
+		return errors.New("a storage location can't be specified when restoring from a transfer endpoint")
 	}
 
 	if err := output.ValidateFlags(c); err != nil {
@@ -155,6 +190,9 @@ func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Facto
 	}
 
 	switch {
+	case o.Transfer:
+		// the backup is in another cluster, and will be received from the
+		// transfer endpoint when the restore runs.
 	case o.BackupName != "":
 		if _, err := o.client.ArkV1().Backups(f.Namespace()).Get(o.BackupName, metav1.GetOptions{}); err != nil {
 			return err
@@ -163,6 +201,10 @@ func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Facto
 		if _, err := o.client.ArkV1().Schedules(f.Namespace()).Get(o.ScheduleName, metav1.GetOptions{}); err != nil {
 			return err
 		}
+	case o.BackupSet != "":
+		if errs := backup.ValidateBackupSet(o.BackupSet, ""); len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
 	}
 
 	return nil
@@ -183,6 +225,7 @@ func (o *CreateOptions) Run(c *cobra.Command, f client.Factory) error {
 		Spec: api.RestoreSpec{
 			BackupName:              o.BackupName,
 			ScheduleName:            o.ScheduleName,
+			StorageLocationOverride: o.StorageLocation,
 			IncludedNamespaces:      o.IncludeNamespaces,
 			ExcludedNamespaces:      o.ExcludeNamespaces,
 			IncludedResources:       o.IncludeResources,
@@ -191,9 +234,23 @@ func (o *CreateOptions) Run(c *cobra.Command, f client.Factory) error {
 			LabelSelector:           o.Selector.LabelSelector,
 			RestorePVs:              o.RestoreVolumes.Value,
 			IncludeClusterResources: o.IncludeClusterResources.Value,
+			VerifyItemCounts:        o.VerifyItemCounts,
+			VerifyManifest:          o.VerifyManifest,
+			ExistingResourcePolicy:  api.ExistingResourcePolicy(o.ExistingResourcePolicy.String()),
+			ImmutableFieldPolicy:    api.ImmutableFieldPolicy(o.ImmutableFieldPolicy.String()),
 		},
 	}
 
+	if o.Transfer {
+		restore.Annotations = map[string]string{
+			api.TransferAnnotation: "true",
+		}
+	}
+
+	if o.BackupSet != "" {
+		return o.runBackupSet(c, f, restore)
+	}
+
 	if printed, err := output.PrintWithFormat(c, restore); printed || err != nil {
 		return err
 	}
@@ -273,3 +330,77 @@ func (o *CreateOptions) Run(c *cobra.Command, f client.Factory) error {
 
 	return nil
 }
+
+// runBackupSet restores each completed backup in the backup set, in the
+// set's order, using template for everything but the backup name. Each
+// restore must complete before the next is created, so that backups later
+// in the set can depend on what's restored from earlier ones.
+func (o *CreateOptions) runBackupSet(c *cobra.Command, f client.Factory, template *api.Restore) error {
+	backups, err := o.client.ArkV1().Backups(f.Namespace()).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", api.BackupSetLabel, o.BackupSet),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var completed []api.Backup
+	for _, b := range backups.Items {
+		if b.Status.Phase == api.BackupPhaseCompleted {
+			completed = append(completed, b)
+		}
+	}
+	if len(completed) == 0 {
+		return errors.Errorf("no completed backups found in backup set %q", o.BackupSet)
+	}
+	backup.SortBackupSet(completed)
+
+	restores := new(api.RestoreList)
+	for _, b := range completed {
+		restore := template.DeepCopy()
+		restore.Name = fmt.Sprintf("%s-%s", o.RestoreName, b.Name)
+		restore.Spec.BackupName = b.Name
+		if restore.Labels == nil {
+			restore.Labels = make(map[string]string)
+		}
+		restore.Labels[api.BackupSetLabel] = o.BackupSet
+
+		restores.Items = append(restores.Items, *restore)
+	}
+
+	if printed, err := output.PrintWithFormat(c, restores); printed || err != nil {
+		return err
+	}
+
+	for i := range restores.Items {
+		restore, err := o.client.ArkV1().Restores(f.Namespace()).Create(&restores.Items[i])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restore request %q for backup %q submitted successfully. Waiting for it to complete.\n", restore.Name, restore.Spec.BackupName)
+
+		var phase api.RestorePhase
+		err = wait.PollImmediateInfinite(time.Second, func() (bool, error) {
+			res, err := o.client.ArkV1().Restores(restore.Namespace).Get(restore.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, errors.WithStack(err)
+			}
+
+			phase = res.Status.Phase
+			return phase != api.RestorePhaseNew && phase != "" && phase != api.RestorePhaseInProgress, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if phase != api.RestorePhaseCompleted {
+			return errors.Errorf("restore %q completed with status %s, so the rest of the backup set wasn't restored. You may check for more information using the commands `ark restore describe %s` and `ark restore logs %s`", restore.Name, phase, restore.Name, restore.Name)
+		}
+
+		fmt.Printf("Restore %q completed.\n", restore.Name)
+	}
+
+	fmt.Printf("All %d backups in backup set %q were restored.\n", len(restores.Items), o.BackupSet)
+
+	return nil
+}
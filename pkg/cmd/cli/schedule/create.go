@@ -65,8 +65,10 @@ func NewCreateCommand(f client.Factory, use string) *cobra.Command {
 }
 
 type CreateOptions struct {
-	BackupOptions *backup.CreateOptions
-	Schedule      string
+	BackupOptions      *backup.CreateOptions
+	Schedule           string
+	BackupNameTemplate string
+	JitterMaxSeconds   int64
 
 	labelSelector *metav1.LabelSelector
 }
@@ -80,12 +82,17 @@ func NewCreateOptions() *CreateOptions {
 func (o *CreateOptions) BindFlags(flags *pflag.FlagSet) {
 	o.BackupOptions.BindFlags(flags)
 	flags.StringVar(&o.Schedule, "schedule", o.Schedule, "a cron expression specifying a recurring schedule for this backup to run")
+	flags.StringVar(&o.BackupNameTemplate, "backup-name-template", o.BackupNameTemplate, `a Go template to name the schedule's backups with, using .Schedule, .ClusterName, .Timestamp and .Sequence, e.g. '{{.Schedule}}-{{.Timestamp.Format "20060102"}}-{{.Sequence}}'. Defaults to the schedule's name and the backup's creation time, to the second`)
+	flags.Int64Var(&o.JitterMaxSeconds, "jitter-max-seconds", o.JitterMaxSeconds, "delay each of the schedule's backups by a random number of seconds, up to this many, after it's due, so that schedules with the same cron expression don't all create their backups at once")
 }
 
 func (o *CreateOptions) Validate(c *cobra.Command, args []string, f client.Factory) error {
 	if len(o.Schedule) == 0 {
 		return errors.New("--schedule is required")
 	}
+	if o.JitterMaxSeconds < 0 {
+		return errors.New("--jitter-max-seconds must not be negative")
+	}
 
 	return o.BackupOptions.Validate(c, args, f)
 }
@@ -104,20 +111,38 @@ func (o *CreateOptions) Run(c *cobra.Command, f client.Factory) error {
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: f.Namespace(),
 			Name:      o.BackupOptions.Name,
-			Labels:    o.BackupOptions.Labels.Data(),
+			Labels:    o.BackupOptions.BackupLabels(),
 		},
 		Spec: api.ScheduleSpec{
 			Template: api.BackupSpec{
-				IncludedNamespaces: o.BackupOptions.IncludeNamespaces,
-				ExcludedNamespaces: o.BackupOptions.ExcludeNamespaces,
-				IncludedResources:  o.BackupOptions.IncludeResources,
-				ExcludedResources:  o.BackupOptions.ExcludeResources,
-				LabelSelector:      o.BackupOptions.Selector.LabelSelector,
-				SnapshotVolumes:    o.BackupOptions.SnapshotVolumes.Value,
-				TTL:                metav1.Duration{Duration: o.BackupOptions.TTL},
-				StorageLocation:    o.BackupOptions.StorageLocation,
+				IncludedNamespaces:            o.BackupOptions.IncludeNamespaces,
+				ExcludedNamespaces:            o.BackupOptions.ExcludeNamespaces,
+				TerminatingNamespacePolicy:    api.TerminatingNamespacePolicy(o.BackupOptions.TerminatingNamespaces),
+				NamespaceMatching:             api.NamespaceMatching(o.BackupOptions.NamespaceMatching),
+				IncludedResources:             o.BackupOptions.IncludeResources,
+				ExcludedResources:             o.BackupOptions.ExcludeResources,
+				LabelSelector:                 o.BackupOptions.Selector.LabelSelector,
+				ExcludedLabelSelector:         o.BackupOptions.ExcludeSelector.LabelSelector,
+				SnapshotVolumes:               o.BackupOptions.SnapshotVolumes.Value,
+				VolumeSnapshotExcludeSelector: o.BackupOptions.VolumeSnapshotExcludeSelector.LabelSelector,
+				FileCopyVolumes:               o.BackupOptions.FileCopyVolumes,
+				TTL:                           metav1.Duration{Duration: o.BackupOptions.TTL},
+				BackupTimeout:                 metav1.Duration{Duration: o.BackupOptions.Timeout},
+				IncludeServiceAccountTokens:   o.BackupOptions.IncludeServiceAccountTokens,
+				MaxItemSizeBytes:              o.BackupOptions.MaxItemSizeBytes,
+				StorageLocation:               o.BackupOptions.StorageLocation,
+				MirrorStorageLocations:        o.BackupOptions.MirrorStorageLocations,
+				UploadPolicy:                  api.UploadPolicy(o.BackupOptions.UploadPolicy),
+				PartialFailurePolicy:          api.PartialFailurePolicy(o.BackupOptions.PartialFailurePolicy),
+				OnListError:                   api.ListErrorPolicy(o.BackupOptions.OnListError),
+				Description:                   o.BackupOptions.Description,
+				Metadata:                      o.BackupOptions.Metadata.Data(),
+				IntegrityManifest:             o.BackupOptions.IntegrityManifest,
+				NotifyWebhook:                 o.BackupOptions.BackupNotifyWebhook(),
 			},
-			Schedule: o.Schedule,
+			Schedule:           o.Schedule,
+			BackupNameTemplate: o.BackupNameTemplate,
+			JitterMaxSeconds:   o.JitterMaxSeconds,
 		},
 	}
 
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transfer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/heptio/ark/pkg/buildinfo"
+	"github.com/heptio/ark/pkg/cmd"
+	"github.com/heptio/ark/pkg/transfer"
+	"github.com/heptio/ark/pkg/util/logging"
+)
+
+func NewServerCommand() *cobra.Command {
+	var (
+		logLevelFlag = logging.LogLevelFlag(logrus.InfoLevel)
+		address      = ":8086"
+		waitTimeout  = time.Hour
+		tlsCertFile  string
+		tlsKeyFile   string
+		tokenFile    string
+	)
+
+	var command = &cobra.Command{
+		Use:   "server",
+		Short: "Run a transfer server",
+		Long: `Run a transfer server that backups can be streamed through, from the cluster
+taking them to a restore on another cluster, without being stored in object storage.
+
+The server only serves HTTPS, and only to clients that present the token in
+--token-file as a bearer token. Run both clusters' Ark servers with
+--transfer-endpoint pointing at this server and --transfer-token-file holding
+the same token, then create the backup with --transfer and a restore of it on
+the other cluster with --transfer. Each side waits for the other to arrive
+before the backup is streamed.`,
+		Run: func(c *cobra.Command, args []string) {
+			if tlsCertFile == "" || tlsKeyFile == "" {
+				cmd.CheckError(errors.New("--tls-cert-file and --tls-key-file are required"))
+			}
+
+			token, err := readToken(tokenFile)
+			cmd.CheckError(err)
+
+			logLevel := logLevelFlag.Parse()
+			logrus.Infof("Setting log-level to %s", strings.ToUpper(logLevel.String()))
+
+			logger := logging.DefaultLogger(logLevel)
+			logger.Infof("Starting Ark transfer server %s on %s", buildinfo.FormattedGitSHA(), address)
+
+			cmd.CheckError(http.ListenAndServeTLS(address, tlsCertFile, tlsKeyFile, transfer.NewServer(logger, waitTimeout, token)))
+		},
+	}
+
+	command.Flags().Var(logLevelFlag, "log-level", fmt.Sprintf("the level at which to log. Valid values are %s.", strings.Join(logLevelFlag.AllowedValues(), ", ")))
+	command.Flags().StringVar(&address, "address", address, "the address to listen on")
+	command.Flags().DurationVar(&waitTimeout, "wait-timeout", waitTimeout, "how long a backup's sender or receiver waits for the other side to arrive")
+	command.Flags().StringVar(&tlsCertFile, "tls-cert-file", tlsCertFile, "file containing the server's PEM-encoded TLS certificate. Required.")
+	command.Flags().StringVar(&tlsKeyFile, "tls-key-file", tlsKeyFile, "file containing the server's PEM-encoded TLS private key. Required.")
+	command.Flags().StringVar(&tokenFile, "token-file", tokenFile, "file containing the token that senders and receivers must present. Required.")
+
+	return command
+}
+
+// readToken returns the token in file, without surrounding whitespace.
+func readToken(file string) (string, error) {
+	if file == "" {
+		return "", errors.New("--token-file is required")
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading token file")
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.Errorf("token file %s is empty", file)
+	}
+
+	return token, nil
+}
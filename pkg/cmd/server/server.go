@@ -46,27 +46,33 @@ import (
 	"k8s.io/client-go/dynamic"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/backup"
 	"github.com/heptio/ark/pkg/buildinfo"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/cloudprovider/azure"
 	"github.com/heptio/ark/pkg/cmd"
+	"github.com/heptio/ark/pkg/cmd/util/flag"
 	"github.com/heptio/ark/pkg/cmd/util/signals"
 	"github.com/heptio/ark/pkg/controller"
 	arkdiscovery "github.com/heptio/ark/pkg/discovery"
 	clientset "github.com/heptio/ark/pkg/generated/clientset/versioned"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions"
 	"github.com/heptio/ark/pkg/metrics"
+	"github.com/heptio/ark/pkg/notify"
 	"github.com/heptio/ark/pkg/persistence"
 	"github.com/heptio/ark/pkg/plugin"
 	"github.com/heptio/ark/pkg/podexec"
 	"github.com/heptio/ark/pkg/restic"
 	"github.com/heptio/ark/pkg/restore"
+	"github.com/heptio/ark/pkg/transfer"
+	"github.com/heptio/ark/pkg/transform"
 	"github.com/heptio/ark/pkg/util/kube"
 	"github.com/heptio/ark/pkg/util/logging"
 	"github.com/heptio/ark/pkg/util/stringslice"
@@ -78,22 +84,66 @@ const (
 )
 
 type serverConfig struct {
-	pluginDir, metricsAddress, defaultBackupLocation string
-	backupSyncPeriod, podVolumeOperationTimeout      time.Duration
-	restoreResourcePriorities                        []string
-	restoreOnly                                      bool
+	pluginDir, metricsAddress, defaultBackupLocation, clusterName string
+	backupLogAddress                                              string
+	transferEndpoint, transferTokenFile, transferCAFile           string
+	backupSyncPeriod, podVolumeOperationTimeout                   time.Duration
+	backupPatchInterval                                           time.Duration
+	backupPatchQPS                                                float64
+	backupPatchBurst                                              int
+	restoreResourcePriorities, backupTransforms                   []string
+	restoreOnly, validateRestorePermissions, syncOwnBackupsOnly   bool
+	backupContentIndex                                            bool
+	backupCompression                                             string
+	backupCompressionLevel                                        int
+	maxBackupSizeBytes                                            int64
+	minBackupSizeBytes                                            int64
+	backupTimeout                                                 time.Duration
+	backupGCGracePeriod                                           time.Duration
+	backupItemActionTimeout                                       time.Duration
+	streamBackupUploads                                           bool
+	backupLocationLabel                                           string
+	probeUnknownBackupLocations                                   bool
+	backupLogFormat                                               logging.Format
+	backupTempDir                                                 string
+	keepBackupTempFiles                                           bool
+	snapshotConcurrency                                           int
+	maxConcurrentBackups                                          int
+	backupShutdownGracePeriod                                     time.Duration
+	orphanedBackupTimeout                                         time.Duration
+	cleanUpOrphanedBackups                                        bool
+	compressBackupMetadata                                        bool
+	deleteBackupStorageOnRemoval                                  bool
+	syncMinBackupVersion                                          int
+	syncBackupSelector                                            flag.LabelSelector
+	backupRateLimiter                                             controller.RateLimiterConfig
+	backupUploadRetry                                             controller.UploadRetryConfig
+	apiThrottle                                                   client.ThrottleConfig
+	objectStoreRateLimit                                          persistence.ObjectStoreRateLimitConfig
 }
 
 func NewCommand() *cobra.Command {
 	var (
-		logLevelFlag = logging.LogLevelFlag(logrus.InfoLevel)
-		config       = serverConfig{
+		logLevelFlag        = logging.LogLevelFlag(logrus.InfoLevel)
+		backupLogFormatFlag = logging.LogFormatFlag(logging.FormatText)
+		config              = serverConfig{
 			pluginDir:                 "/plugins",
 			metricsAddress:            defaultMetricsAddress,
 			defaultBackupLocation:     "default",
 			backupSyncPeriod:          defaultBackupSyncPeriod,
 			podVolumeOperationTimeout: defaultPodVolumeOperationTimeout,
+			backupPatchInterval:       defaultBackupPatchInterval,
+			backupPatchQPS:            defaultBackupPatchQPS,
+			backupPatchBurst:          defaultBackupPatchBurst,
+			backupCompression:         string(archive.CompressionGzip),
+			backupRateLimiter:         controller.RateLimiterConfig{MaxRetries: defaultBackupMaxRetries},
+			backupUploadRetry:         controller.UploadRetryConfig{MaxRetries: defaultBackupUploadMaxRetries},
 			restoreResourcePriorities: defaultRestorePriorities,
+			apiThrottle:               client.ThrottleConfig{MaxRetries: defaultAPIThrottleMaxRetries},
+			snapshotConcurrency:       defaultSnapshotConcurrency,
+			maxConcurrentBackups:      defaultMaxConcurrentBackups,
+			backupShutdownGracePeriod: defaultBackupShutdownGracePeriod,
+			orphanedBackupTimeout:     defaultOrphanedBackupTimeout,
 		}
 	)
 
@@ -129,6 +179,8 @@ func NewCommand() *cobra.Command {
 			}
 			namespace := getServerNamespace(namespaceFlag)
 
+			config.backupLogFormat = backupLogFormatFlag.Parse()
+
 			s, err := newServer(namespace, fmt.Sprintf("%s-%s", c.Parent().Name(), c.Name()), config, logger)
 			cmd.CheckError(err)
 
@@ -137,17 +189,84 @@ func NewCommand() *cobra.Command {
 	}
 
 	command.Flags().Var(logLevelFlag, "log-level", fmt.Sprintf("the level at which to log. Valid values are %s.", strings.Join(logLevelFlag.AllowedValues(), ", ")))
+	command.Flags().Var(backupLogFormatFlag, "backup-log-format", fmt.Sprintf("the format to write backups' logs in, both to their log files and to the server's output while they run. Valid values are %s.", strings.Join(backupLogFormatFlag.AllowedValues(), ", ")))
 	command.Flags().StringVar(&config.pluginDir, "plugin-dir", config.pluginDir, "directory containing Ark plugins")
 	command.Flags().StringVar(&config.metricsAddress, "metrics-address", config.metricsAddress, "the address to expose prometheus metrics")
+	command.Flags().StringVar(&config.backupLogAddress, "backup-log-address", config.backupLogAddress, "the address to stream in-progress backups' logs from, for ark backup logs --follow. Logs can contain sensitive details of the cluster, and anyone who can reach this address can read them, so it's disabled unless set. The CLI reaches it through the Kubernetes API server's pod proxy, so other access to it can be blocked with a NetworkPolicy")
+	command.Flags().StringVar(&config.transferEndpoint, "transfer-endpoint", config.transferEndpoint, "https URL of the transfer server that backups and restores annotated with ark.heptio.com/transfer are streamed through. Streaming is disabled unless set")
+	command.Flags().StringVar(&config.transferTokenFile, "transfer-token-file", config.transferTokenFile, "file containing the token to authenticate to the transfer server with. Required if --transfer-endpoint is set")
+	command.Flags().StringVar(&config.transferCAFile, "transfer-ca-file", config.transferCAFile, "file containing the PEM-encoded CA certificates to verify the transfer server's certificate against, instead of the system's roots")
 	command.Flags().DurationVar(&config.backupSyncPeriod, "backup-sync-period", config.backupSyncPeriod, "how often to ensure all Ark backups in object storage exist as Backup API objects in the cluster")
 	command.Flags().DurationVar(&config.podVolumeOperationTimeout, "restic-timeout", config.podVolumeOperationTimeout, "how long backups/restores of pod volumes should be allowed to run before timing out")
 	command.Flags().BoolVar(&config.restoreOnly, "restore-only", config.restoreOnly, "run in a mode where only restores are allowed; backups, schedules, and garbage-collection are all disabled")
 	command.Flags().StringSliceVar(&config.restoreResourcePriorities, "restore-resource-priorities", config.restoreResourcePriorities, "desired order of resource restores; any resource not in the list will be restored alphabetically after the prioritized resources")
 	command.Flags().StringVar(&config.defaultBackupLocation, "default-backup-storage-location", config.defaultBackupLocation, "name of the default backup storage location")
+	command.Flags().DurationVar(&config.backupRateLimiter.BaseDelay, "backup-retry-base-delay", config.backupRateLimiter.BaseDelay, "how long to wait before retrying a backup that failed to process; the delay doubles with each subsequent failure (0 uses the default)")
+	command.Flags().DurationVar(&config.backupRateLimiter.MaxDelay, "backup-retry-max-delay", config.backupRateLimiter.MaxDelay, "the maximum amount of time to wait between retries of a backup that failed to process (0 uses the default)")
+	command.Flags().IntVar(&config.backupRateLimiter.MaxRetries, "backup-max-retries", config.backupRateLimiter.MaxRetries, "the number of times to retry a backup that failed to process with an error that may be transient, such as a conflict, before marking it as Failed (0 retries forever)")
+	command.Flags().IntVar(&config.backupUploadRetry.MaxRetries, "backup-upload-max-retries", config.backupUploadRetry.MaxRetries, "the number of times to retry uploading a backup to a storage location after a failure that may be transient; authentication and authorization failures aren't retried")
+	command.Flags().DurationVar(&config.backupUploadRetry.BaseDelay, "backup-upload-retry-base-delay", config.backupUploadRetry.BaseDelay, "how long to wait before retrying a failed backup upload; the delay doubles with each subsequent retry (0 uses the default)")
+	command.Flags().DurationVar(&config.apiThrottle.BaseDelay, "api-throttle-base-delay", config.apiThrottle.BaseDelay, "how long to hold back API requests made during backups and restores after the API server throttles one; the delay doubles with each consecutive throttled request (0 uses the default)")
+	command.Flags().DurationVar(&config.apiThrottle.MaxDelay, "api-throttle-max-delay", config.apiThrottle.MaxDelay, "the maximum amount of time to hold back API requests after the API server throttles one, unless it asks for longer (0 uses the default)")
+	command.Flags().IntVar(&config.apiThrottle.MaxRetries, "api-throttle-max-retries", config.apiThrottle.MaxRetries, "the number of times to retry an API request throttled by the API server before returning the error")
+	command.Flags().Float64Var(&config.objectStoreRateLimit.QPS, "object-store-qps", config.objectStoreRateLimit.QPS, "the maximum number of requests per second to make to object storage with each provider's credentials, shared by all backups, restores and controllers, to stay under the provider's request quota; each object put counts once, even if it's uploaded in several parts (0 means no limit)")
+	command.Flags().IntVar(&config.objectStoreRateLimit.Burst, "object-store-burst", config.objectStoreRateLimit.Burst, "the number of requests that can be made to object storage at once with each provider's credentials before --object-store-qps applies (0 uses 1)")
+	command.Flags().StringSliceVar(&config.backupTransforms, "backup-transforms", config.backupTransforms, fmt.Sprintf("ordered list of transform stages to pass backup tarballs through before they're uploaded; they're undone in reverse order on restore. Valid stages are %s.", strings.Join(transform.StageNames(), ", ")))
+	command.Flags().DurationVar(&config.backupPatchInterval, "backup-patch-interval", config.backupPatchInterval, "how often to write held back updates to in-progress backups; updates that change a backup's phase are always written immediately (0 writes every update immediately)")
+	command.Flags().Float64Var(&config.backupPatchQPS, "backup-patch-qps", config.backupPatchQPS, "the maximum number of patches per second to make to backups, shared by all in-progress backups (0 means no limit)")
+	command.Flags().IntVar(&config.backupPatchBurst, "backup-patch-burst", config.backupPatchBurst, "the number of patches that can be made to backups at once before --backup-patch-qps applies (0 uses 1)")
+	command.Flags().StringVar(&config.backupCompression, "backup-compression", config.backupCompression, fmt.Sprintf("algorithm to compress backup tarballs with. Valid values are %s, %s.", archive.CompressionGzip, archive.CompressionZstd))
+	command.Flags().IntVar(&config.backupCompressionLevel, "backup-compression-level", config.backupCompressionLevel, "level to compress backup tarballs at: 1 (fastest) to 9 (smallest) for gzip, or 1 to 22 for zstd (0 uses the algorithm's default)")
+	command.Flags().DurationVar(&config.backupTimeout, "backup-timeout", config.backupTimeout, "how long collecting a backup's items may take before the backup is marked as failed, for backups that don't set their own timeout (0 means no limit)")
+	command.Flags().DurationVar(&config.backupGCGracePeriod, "backup-gc-grace-period", config.backupGCGracePeriod, "how long after a backup expires to wait before deleting it, to allow for clock skew between the servers that take and garbage-collect backups")
+	command.Flags().StringVar(&config.backupLocationLabel, "backup-location-label", config.backupLocationLabel, "label key (e.g. a region label) used to choose the storage location of backups that don't set one: a backup with the label goes to the backup storage location with the same value for it, if there's one, instead of --default-backup-storage-location")
+	command.Flags().BoolVar(&config.probeUnknownBackupLocations, "probe-unknown-backup-locations", config.probeUnknownBackupLocations, "probe backup storage locations whose availability is unknown (they have no status phase) before backing up to them, failing the backup's validation if the probe fails; locations whose phase is Unavailable always fail it")
+	command.Flags().BoolVar(&config.streamBackupUploads, "stream-backup-uploads", config.streamBackupUploads, "upload backups' tarballs to aws and gcp storage locations as they're written, rather than staging them in --backup-temp-dir first; backups with mirror locations or content indexes are still staged")
+	command.Flags().DurationVar(&config.backupItemActionTimeout, "backup-item-action-timeout", config.backupItemActionTimeout, "how long a backup item action may take to execute on an item before it's skipped for that item with a warning (0 means no limit)")
+	command.Flags().Int64Var(&config.maxBackupSizeBytes, "max-backup-size-bytes", config.maxBackupSizeBytes, "abort backups, marking them as failed, once their tarball exceeds this many bytes, to keep them from filling the server's disk (0 means no limit)")
+	command.Flags().Int64Var(&config.minBackupSizeBytes, "min-backup-size-bytes", config.minBackupSizeBytes, "mark backups whose tarball is smaller than this many bytes as CompletedEmpty rather than Completed, like backups that capture no items, since they're likely misconfigured (0 only marks backups that capture no items)")
+	command.Flags().StringVar(&config.backupTempDir, "backup-temp-dir", config.backupTempDir, "directory to stage backup tarballs and logs in before they're uploaded, e.g. one backed by a large volume (defaults to the OS temp dir)")
+	command.Flags().BoolVar(&config.keepBackupTempFiles, "keep-backup-temp-files", config.keepBackupTempFiles, "leave the temp files backups' tarballs and logs are staged in, rather than removing them after they're uploaded, and record their paths in the backups' statuses. For debugging only: the files aren't cleaned up, so they can fill --backup-temp-dir")
+	command.Flags().IntVar(&config.maxConcurrentBackups, "max-concurrent-backups", config.maxConcurrentBackups, "the maximum number of backups to run at once; backups beyond the limit stay New, and are retried until one of the running backups finishes")
+	command.Flags().DurationVar(&config.backupShutdownGracePeriod, "backup-shutdown-grace-period", config.backupShutdownGracePeriod, "how long backups that are in progress when the server's stopped are given to finish before they're aborted and marked as failed; keep it shorter than the server pod's terminationGracePeriodSeconds, so aborted backups can be updated before the pod is killed")
+	command.Flags().DurationVar(&config.orphanedBackupTimeout, "orphaned-backup-timeout", config.orphanedBackupTimeout, "how long after it started a backup that's InProgress, but isn't being run by the server, e.g. because the server crashed while running it, is marked as failed (0 means never)")
+	command.Flags().BoolVar(&config.cleanUpOrphanedBackups, "clean-up-orphaned-backups", config.cleanUpOrphanedBackups, "delete what was uploaded of backups that are marked as failed because of --orphaned-backup-timeout from object storage")
+	command.Flags().BoolVar(&config.compressBackupMetadata, "compress-backup-metadata", config.compressBackupMetadata, "gzip backups' metadata before uploading it to object storage, where it's stored as ark-backup.json.gz. Uncompressed metadata is still read.")
+	command.Flags().IntVar(&config.snapshotConcurrency, "snapshot-concurrency", config.snapshotConcurrency, "the maximum number of volume snapshots to take at once during a backup; raise it to speed up backups of many volumes, within the cloud provider's rate limits")
+	command.Flags().BoolVar(&config.deleteBackupStorageOnRemoval, "delete-backup-storage-on-removal", config.deleteBackupStorageOnRemoval, "delete backups' data from object storage when their Backup resources are deleted, unless a backup's spec.deleteStorageOnRemoval says otherwise")
+	command.Flags().BoolVar(&config.backupContentIndex, "backup-content-index", config.backupContentIndex, "upload an index listing each backup's items alongside its tarball, so its contents can be searched without downloading it")
+	command.Flags().StringVar(&config.clusterName, "cluster-name", config.clusterName, "name of the cluster the server is running in; backups it takes are labeled with it")
+	command.Flags().BoolVar(&config.syncOwnBackupsOnly, "sync-own-backups-only", config.syncOwnBackupsOnly, "don't sync backups labeled as having been taken by a cluster other than --cluster-name into the cluster")
+	command.Flags().IntVar(&config.syncMinBackupVersion, "sync-min-backup-version", config.syncMinBackupVersion, "don't sync backups with a format version older than this into the cluster")
+	command.Flags().Var(&config.syncBackupSelector, "sync-backup-selector", "only sync backups matching this label selector into the cluster")
+	command.Flags().BoolVar(&config.validateRestorePermissions, "validate-restore-permissions", config.validateRestorePermissions, "check that the server has permission to create every resource type in a backup before starting a restore, and fail validation if not")
 
 	return command
 }
 
+// backupSyncFilter returns the filter for the backups the server syncs
+// into the cluster.
+func (c serverConfig) backupSyncFilter() (persistence.BackupFilter, error) {
+	filter := persistence.BackupFilter{MinVersion: c.syncMinBackupVersion}
+
+	if c.syncOwnBackupsOnly {
+		if c.clusterName == "" {
+			return filter, errors.New("--sync-own-backups-only requires --cluster-name")
+		}
+		filter.ClusterName = c.clusterName
+	}
+
+	if c.syncBackupSelector.LabelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(c.syncBackupSelector.LabelSelector)
+		if err != nil {
+			return filter, errors.WithStack(err)
+		}
+		filter.Selector = selector
+	}
+
+	return filter, nil
+}
+
 func getServerNamespace(namespaceFlag *pflag.Flag) string {
 	if namespaceFlag.Changed {
 		return namespaceFlag.Value.String()
@@ -181,6 +300,7 @@ type server struct {
 	pluginManager         plugin.Manager
 	resticManager         restic.RepositoryManager
 	metrics               *metrics.ServerMetrics
+	transferEndpoint      transfer.Endpoint
 	config                serverConfig
 }
 
@@ -209,7 +329,21 @@ func newServer(namespace, baseName string, config serverConfig, logger *logrus.L
 		return nil, err
 	}
 
-	dynamicClient, err := dynamic.NewForConfig(clientConfig)
+	serverMetrics := metrics.NewServerMetrics()
+
+	// the dynamic client is used for the bulk of the API requests made during
+	// backups and restores, so it backs off when the API server throttles it.
+	dynamicClientConfig := rest.CopyConfig(clientConfig)
+	wrapThrottling := client.NewThrottlingTransportWrapper(config.apiThrottle, serverMetrics.RegisterAPIServerThrottled)
+	if wrap := dynamicClientConfig.WrapTransport; wrap != nil {
+		dynamicClientConfig.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return wrapThrottling(wrap(rt))
+		}
+	} else {
+		dynamicClientConfig.WrapTransport = wrapThrottling
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(dynamicClientConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -231,6 +365,7 @@ func newServer(namespace, baseName string, config serverConfig, logger *logrus.L
 		logLevel:       logger.Level,
 		pluginRegistry: pluginRegistry,
 		pluginManager:  pluginManager,
+		metrics:        serverMetrics,
 		config:         config,
 	}
 
@@ -242,6 +377,14 @@ func (s *server) run() error {
 
 	signals.CancelOnShutdown(s.cancelFunc, s.logger)
 
+	if err := s.validateBackupTempDir(); err != nil {
+		return err
+	}
+
+	if err := s.initTransferEndpoint(); err != nil {
+		return err
+	}
+
 	// Since s.namespace, which specifies where backups/restores/schedules/etc. should live,
 	// *could* be different from the namespace where the Ark server pod runs, check to make
 	// sure it exists, and fail fast if it doesn't.
@@ -393,6 +536,50 @@ func (s *server) arkResourcesExist() error {
 	return nil
 }
 
+// validateBackupTempDir checks that files can be created in the directory
+// backups are staged in, if one is configured, and returns an error if not.
+func (s *server) validateBackupTempDir() error {
+	if s.config.backupTempDir == "" {
+		return nil
+	}
+
+	s.logger.WithField("dir", s.config.backupTempDir).Info("Checking that the backup temp dir is writable")
+
+	file, err := ioutil.TempFile(s.config.backupTempDir, "ark-")
+	if err != nil {
+		return errors.Wrapf(err, "backup temp dir %s is not writable", s.config.backupTempDir)
+	}
+	file.Close()
+
+	return errors.WithStack(os.Remove(file.Name()))
+}
+
+// initTransferEndpoint sets up the transfer endpoint, if one's configured,
+// from its URL, token file, and CA file.
+func (s *server) initTransferEndpoint() error {
+	if s.config.transferEndpoint == "" {
+		return nil
+	}
+
+	if s.config.transferTokenFile == "" {
+		return errors.New("--transfer-token-file is required if --transfer-endpoint is set")
+	}
+	token, err := ioutil.ReadFile(s.config.transferTokenFile)
+	if err != nil {
+		return errors.Wrap(err, "error reading transfer token file")
+	}
+
+	var caCert []byte
+	if s.config.transferCAFile != "" {
+		if caCert, err = ioutil.ReadFile(s.config.transferCAFile); err != nil {
+			return errors.Wrap(err, "error reading transfer CA file")
+		}
+	}
+
+	s.transferEndpoint, err = transfer.NewHTTPEndpoint(s.config.transferEndpoint, strings.TrimSpace(string(token)), caCert)
+	return err
+}
+
 // validateBackupStorageLocations checks to ensure all backup storage locations exist
 // and have a compatible layout, and returns an error if not.
 func (s *server) validateBackupStorageLocations() error {
@@ -452,6 +639,16 @@ func (s *server) loadConfig() (*api.Config, error) {
 const (
 	defaultBackupSyncPeriod          = time.Minute
 	defaultPodVolumeOperationTimeout = 60 * time.Minute
+	defaultAPIThrottleMaxRetries     = 5
+	defaultBackupPatchInterval       = 5 * time.Second
+	defaultBackupPatchQPS            = 10
+	defaultBackupPatchBurst          = 20
+	defaultBackupUploadMaxRetries    = 3
+	defaultBackupMaxRetries          = 15
+	defaultSnapshotConcurrency       = 1
+	defaultMaxConcurrentBackups      = 1
+	defaultBackupShutdownGracePeriod = 20 * time.Second
+	defaultOrphanedBackupTimeout     = 5 * time.Minute
 )
 
 // - Namespaces go first because all namespaced resources depend on them.
@@ -585,6 +782,11 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 	ctx := s.ctx
 	var wg sync.WaitGroup
 
+	// the backup tracker is shared by the backup and deletion controllers,
+	// and the logs of the backups it tracks are served, if enabled, on
+	// their own address.
+	backupTracker := controller.NewBackupTracker()
+
 	go func() {
 		metricsMux := http.NewServeMux()
 		metricsMux.Handle("/metrics", promhttp.Handler())
@@ -593,13 +795,33 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 			s.logger.Fatalf("Failed to start metric server at [%s]: %v", s.metricsAddress, err)
 		}
 	}()
-	s.metrics = metrics.NewServerMetrics()
+
+	if s.config.backupLogAddress != "" {
+		go func() {
+			backupLogMux := http.NewServeMux()
+			backupLogMux.Handle(controller.BackupLogPath, controller.NewBackupLogHandler(backupTracker))
+			s.logger.Infof("Starting backup log server at address [%s]", s.config.backupLogAddress)
+			if err := http.ListenAndServe(s.config.backupLogAddress, backupLogMux); err != nil {
+				s.logger.Fatalf("Failed to start backup log server at [%s]: %v", s.config.backupLogAddress, err)
+			}
+		}()
+	}
 	s.metrics.RegisterAllMetrics()
 
+	objectStoreRateLimiter := persistence.NewObjectStoreRateLimiter(s.config.objectStoreRateLimit)
 	newPluginManager := func(logger logrus.FieldLogger) plugin.Manager {
-		return plugin.NewManager(logger, s.logLevel, s.pluginRegistry)
+		manager := plugin.NewManager(logger, s.logLevel, s.pluginRegistry)
+		if objectStoreRateLimiter == nil {
+			return manager
+		}
+		return &rateLimitedPluginManager{Manager: manager, limiter: objectStoreRateLimiter}
 	}
 
+	encryptionKeys := persistence.NewSecretEncryptionKeyGetter(s.kubeClient.CoreV1())
+
+	backupSyncFilter, err := s.config.backupSyncFilter()
+	cmd.CheckError(err)
+
 	backupSyncController := controller.NewBackupSyncController(
 		s.arkClient.ArkV1(),
 		s.arkClient.ArkV1(),
@@ -608,7 +830,9 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 		s.config.backupSyncPeriod,
 		s.namespace,
 		s.config.defaultBackupLocation,
+		backupSyncFilter,
 		newPluginManager,
+		encryptionKeys,
 		s.logger,
 	)
 	wg.Add(1)
@@ -620,8 +844,6 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 	if s.config.restoreOnly {
 		s.logger.Info("Restore only mode - not starting the backup, schedule, delete-backup, or GC controllers")
 	} else {
-		backupTracker := controller.NewBackupTracker()
-
 		backupper, err := backup.NewKubernetesBackupper(
 			s.discoveryHelper,
 			client.NewDynamicFactory(s.dynamicClient),
@@ -629,25 +851,72 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 			s.blockStore,
 			s.resticManager,
 			s.config.podVolumeOperationTimeout,
+			s.config.snapshotConcurrency,
 		)
 		cmd.CheckError(err)
 
-		backupController := controller.NewBackupController(
+		backupTransforms, err := transform.NewPipeline(s.config.backupTransforms)
+		cmd.CheckError(err)
+
+		backupCompression, err := archive.NewCompression(s.config.backupCompression, s.config.backupCompressionLevel)
+		cmd.CheckError(err)
+
+		backupPatcher := controller.NewBackupPatcher(s.arkClient.ArkV1(), s.config.backupPatchInterval, s.config.backupPatchQPS, s.config.backupPatchBurst, s.metrics, s.logger)
+		wg.Add(1)
+		go func() {
+			backupPatcher.Run(ctx)
+			wg.Done()
+		}()
+
+		backupController := controller.NewBackupControllerWithOptions(
 			s.sharedInformerFactory.Ark().V1().Backups(),
 			s.arkClient.ArkV1(),
+			s.kubeClient.CoreV1(),
 			backupper,
 			s.blockStore != nil,
 			s.logger,
 			s.logLevel,
 			newPluginManager,
+			encryptionKeys,
+			persistence.NewSecretCredentialsGetter(s.kubeClient.CoreV1()),
 			backupTracker,
+			backupPatcher,
 			s.sharedInformerFactory.Ark().V1().BackupStorageLocations(),
 			s.config.defaultBackupLocation,
 			s.metrics,
+			controller.WithClusterName(s.config.clusterName),
+			controller.WithRateLimiterConfig(s.config.backupRateLimiter),
+			controller.WithUploadRetryConfig(s.config.backupUploadRetry),
+			controller.WithTransforms(backupTransforms),
+			controller.WithCompression(backupCompression),
+			controller.WithContentIndex(s.config.backupContentIndex),
+			controller.WithBackupSizeLimits(s.config.minBackupSizeBytes, s.config.maxBackupSizeBytes),
+			controller.WithBackupTimeout(s.config.backupTimeout),
+			controller.WithBackupTempDir(s.config.backupTempDir),
+			controller.WithBackupTempFilesKept(s.config.keepBackupTempFiles),
+			controller.WithStorageDeletedOnRemoval(s.config.deleteBackupStorageOnRemoval),
+			controller.WithItemActionTimeout(s.config.backupItemActionTimeout),
+			controller.WithStreamedUploads(s.config.streamBackupUploads),
+			controller.WithLocationLabel(s.config.backupLocationLabel),
+			controller.WithUnknownLocationProbing(s.config.probeUnknownBackupLocations),
+			controller.WithBackupLogFormat(s.config.backupLogFormat),
+			controller.WithMaxConcurrentBackups(s.config.maxConcurrentBackups),
+			controller.WithShutdownGracePeriod(s.config.backupShutdownGracePeriod),
+			controller.WithOrphanedBackupRecovery(s.config.orphanedBackupTimeout, s.config.cleanUpOrphanedBackups),
+			controller.WithDiscoveryHelper(s.discoveryHelper),
+			controller.WithMetadataCompression(s.config.compressBackupMetadata),
+			controller.WithNotifier(notify.NewWebhookNotifier(s.kubeClient.CoreV1(), notify.DefaultTimeout)),
+			controller.WithTransferEndpoint(s.transferEndpoint),
 		)
+		// each worker runs one backup at a time, so there's a worker for
+		// each backup that can run at once.
+		backupWorkers := s.config.maxConcurrentBackups
+		if backupWorkers < 1 {
+			backupWorkers = 1
+		}
 		wg.Add(1)
 		go func() {
-			backupController.Run(ctx, 1)
+			backupController.Run(ctx, backupWorkers)
 			wg.Done()
 		}()
 
@@ -658,6 +927,7 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 			s.sharedInformerFactory.Ark().V1().Schedules(),
 			s.logger,
 			s.metrics,
+			s.config.clusterName,
 		)
 		wg.Add(1)
 		go func() {
@@ -670,6 +940,8 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 			s.sharedInformerFactory.Ark().V1().Backups(),
 			s.sharedInformerFactory.Ark().V1().DeleteBackupRequests(),
 			s.arkClient.ArkV1(),
+			s.config.backupGCGracePeriod,
+			s.metrics,
 		)
 		wg.Add(1)
 		go func() {
@@ -677,6 +949,33 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 			wg.Done()
 		}()
 
+		backupFinalizerController := controller.NewBackupFinalizerController(
+			s.logger,
+			s.sharedInformerFactory.Ark().V1().Backups(),
+			s.arkClient.ArkV1(),
+			s.sharedInformerFactory.Ark().V1().BackupStorageLocations(),
+			newPluginManager,
+		)
+		wg.Add(1)
+		go func() {
+			backupFinalizerController.Run(ctx, 1)
+			wg.Done()
+		}()
+
+		backupRepairController := controller.NewBackupRepairController(
+			s.logger,
+			s.sharedInformerFactory.Ark().V1().Backups(),
+			s.arkClient.ArkV1(),
+			s.sharedInformerFactory.Ark().V1().BackupStorageLocations(),
+			newPluginManager,
+			encryptionKeys,
+		)
+		wg.Add(1)
+		go func() {
+			backupRepairController.Run(ctx, 1)
+			wg.Done()
+		}()
+
 		backupDeletionController := controller.NewBackupDeletionController(
 			s.logger,
 			s.sharedInformerFactory.Ark().V1().DeleteBackupRequests(),
@@ -712,6 +1011,11 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 	)
 	cmd.CheckError(err)
 
+	var accessReviewClient authorizationv1client.SelfSubjectAccessReviewsGetter
+	if s.config.validateRestorePermissions {
+		accessReviewClient = s.kubeClient.AuthorizationV1()
+	}
+
 	restoreController := controller.NewRestoreController(
 		s.namespace,
 		s.sharedInformerFactory.Ark().V1().Restores(),
@@ -724,8 +1028,12 @@ func (s *server) runControllers(config *api.Config, defaultBackupLocation *api.B
 		s.logger,
 		s.logLevel,
 		newPluginManager,
+		encryptionKeys,
 		s.config.defaultBackupLocation,
 		s.metrics,
+		accessReviewClient,
+		s.discoveryHelper,
+		s.transferEndpoint,
 	)
 
 	wg.Add(1)
@@ -820,3 +1128,18 @@ func (s *server) removeDeprecatedGCFinalizer() {
 		}
 	}
 }
+
+// rateLimitedPluginManager is a plugin.Manager whose object stores share
+// the server's object store rate limiter.
+type rateLimitedPluginManager struct {
+	plugin.Manager
+	limiter *persistence.ObjectStoreRateLimiter
+}
+
+func (m *rateLimitedPluginManager) GetObjectStore(name string) (cloudprovider.ObjectStore, error) {
+	objectStore, err := m.Manager.GetObjectStore(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.limiter.Limit(name, objectStore), nil
+}
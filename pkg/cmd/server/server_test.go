@@ -17,10 +17,14 @@ limitations under the License.
 package server
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -54,6 +58,47 @@ func TestApplyConfigDefaults(t *testing.T) {
 	assert.Equal(t, []string{"a", "b"}, server.config.restoreResourcePriorities)
 }
 
+func TestValidateBackupTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0644))
+
+	tests := []struct {
+		name        string
+		dir         string
+		expectedErr bool
+	}{
+		{name: "unset uses the OS default", dir: ""},
+		{name: "writable dir", dir: dir},
+		{name: "missing dir", dir: filepath.Join(dir, "missing"), expectedErr: true},
+		{name: "not a dir", dir: file, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &server{
+				logger: arktest.NewLogger(),
+				config: serverConfig{backupTempDir: test.dir},
+			}
+
+			err := server.validateBackupTempDir()
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			// the file created to check the dir is removed
+			files, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
 func TestArkResourcesExist(t *testing.T) {
 	var (
 		fakeDiscoveryHelper = &arktest.FakeDiscoveryHelper{}
@@ -35,7 +35,40 @@ func DescribeBackup(backup *arkv1api.Backup, deleteRequests []arkv1api.DeleteBac
 		if phase == "" {
 			phase = arkv1api.BackupPhaseNew
 		}
-		d.Printf("Phase:\t%s\n", phase)
+		if backup.Spec.DryRun {
+			d.Printf("Phase:\t%s (dry run)\n", phase)
+		} else {
+			d.Printf("Phase:\t%s\n", phase)
+		}
+
+		if backup.Spec.Description != "" {
+			d.Println()
+			d.Printf("Description:\t%s\n", backup.Spec.Description)
+		}
+
+		if len(backup.Spec.Metadata) > 0 {
+			d.Println()
+			d.DescribeMap("Metadata", backup.Spec.Metadata)
+		}
+
+		if backup.Spec.BaseBackup != "" {
+			d.Println()
+			d.Printf("Base backup:\t%s (incremental)\n", backup.Spec.BaseBackup)
+		}
+
+		if backup.Spec.IntegrityManifest {
+			d.Println()
+			d.Printf("Integrity manifest:\tuploaded alongside the tarball\n")
+		}
+
+		if backupSet := backup.Labels[arkv1api.BackupSetLabel]; backupSet != "" {
+			d.Println()
+			if order := backup.Labels[arkv1api.BackupSetOrderLabel]; order != "" {
+				d.Printf("Backup set:\t%s (order %s)\n", backupSet, order)
+			} else {
+				d.Printf("Backup set:\t%s\n", backupSet)
+			}
+		}
 
 		d.Println()
 		DescribeBackupSpec(d, backup.Spec)
@@ -73,6 +106,16 @@ func DescribeBackupSpec(d *Describer, spec arkv1api.BackupSpec) {
 	}
 	d.Printf("\tExcluded:\t%s\n", s)
 
+	if spec.NamespaceMatching == arkv1api.NamespaceMatchingGlob {
+		d.Printf("\tMatching:\t%s\n", spec.NamespaceMatching)
+	}
+
+	s = string(spec.TerminatingNamespacePolicy)
+	if s == "" {
+		s = string(arkv1api.TerminatingNamespacePolicySkip)
+	}
+	d.Printf("\tTerminating:\t%s\n", s)
+
 	d.Println()
 	d.Printf("Resources:\n")
 	if len(spec.IncludedResources) == 0 {
@@ -90,27 +133,96 @@ func DescribeBackupSpec(d *Describer, spec arkv1api.BackupSpec) {
 
 	d.Printf("\tCluster-scoped:\t%s\n", BoolPointerString(spec.IncludeClusterResources, "excluded", "included", "auto"))
 
+	s = "excluded"
+	if spec.IncludeServiceAccountTokens {
+		s = "included"
+	}
+	d.Printf("\tService account tokens:\t%s\n", s)
+
+	s = "<unlimited>"
+	if spec.MaxItemSizeBytes > 0 {
+		s = fmt.Sprintf("%d bytes", spec.MaxItemSizeBytes)
+	}
+	d.Printf("\tMax item size:\t%s\n", s)
+
 	d.Println()
 	s = "<none>"
 	if spec.LabelSelector != nil {
 		s = metav1.FormatLabelSelector(spec.LabelSelector)
 	}
 	d.Printf("Label selector:\t%s\n", s)
+	if spec.ExcludedLabelSelector != nil {
+		d.Printf("Excluded label selector:\t%s\n", metav1.FormatLabelSelector(spec.ExcludedLabelSelector))
+	}
 
 	d.Println()
 	d.Printf("Storage Location:\t%s\n", spec.StorageLocation)
+	if len(spec.MirrorStorageLocations) > 0 {
+		d.Printf("Mirror Storage Locations:\t%s\n", strings.Join(spec.MirrorStorageLocations, ", "))
+
+		s = string(spec.UploadPolicy)
+		if s == "" {
+			s = string(arkv1api.UploadPolicyRequirePrimary)
+		}
+		d.Printf("Upload Policy:\t%s\n", s)
+	}
+
+	if spec.PartialFailurePolicy != "" {
+		d.Println()
+		d.Printf("Partial Failure Policy:\t%s\n", spec.PartialFailurePolicy)
+	}
+
+	if spec.OnListError != "" {
+		d.Println()
+		d.Printf("On List Error:\t%s\n", spec.OnListError)
+	}
+
+	if len(spec.EnabledPlugins) > 0 || len(spec.DisabledPlugins) > 0 {
+		d.Println()
+		s := "<all>"
+		if len(spec.EnabledPlugins) > 0 {
+			s = strings.Join(spec.EnabledPlugins, ", ")
+		}
+		d.Printf("Enabled Plugins:\t%s\n", s)
+		s = "<none>"
+		if len(spec.DisabledPlugins) > 0 {
+			s = strings.Join(spec.DisabledPlugins, ", ")
+		}
+		d.Printf("Disabled Plugins:\t%s\n", s)
+	}
+
+	if spec.ConsistencyMode != "" {
+		d.Println()
+		d.Printf("Consistency Mode:\t%s\n", spec.ConsistencyMode)
+	}
 
 	d.Println()
 	d.Printf("Snapshot PVs:\t%s\n", BoolPointerString(spec.SnapshotVolumes, "false", "true", "auto"))
+	if spec.VolumeSnapshotExcludeSelector != nil {
+		d.Printf("Volume snapshot exclude selector:\t%s\n", metav1.FormatLabelSelector(spec.VolumeSnapshotExcludeSelector))
+	}
+
+	if spec.NotifyWebhook != nil {
+		d.Println()
+		d.Printf("Notify Webhook:\t%s\n", spec.NotifyWebhook.URL)
+		if ref := spec.NotifyWebhook.SecretRef; ref != nil {
+			d.Printf("Notify Webhook Secret:\t%s/%s\n", ref.Name, ref.Key)
+		}
+	}
 
 	d.Println()
 	d.Printf("TTL:\t%s\n", spec.TTL.Duration)
+	if spec.BackupTimeout.Duration > 0 {
+		d.Printf("Timeout:\t%s\n", spec.BackupTimeout.Duration)
+	}
 
 	d.Println()
 	if len(spec.Hooks.Resources) == 0 {
 		d.Printf("Hooks:\t<none>\n")
 	} else {
 		d.Printf("Hooks:\n")
+		d.Printf("\tValidate:\t%t\n", spec.Hooks.Validate)
+		d.Printf("\tFail on pre hook error:\t%t\n", spec.Hooks.FailOnPreHookError)
 		d.Printf("\tResources:\n")
 		for _, backupResourceHookSpec := range spec.Hooks.Resources {
 			d.Printf("\t\t%s:\n", backupResourceHookSpec.Name)
@@ -185,8 +297,20 @@ func DescribeBackupStatus(d *Describer, status arkv1api.BackupStatus) {
 
 	d.Println()
 	d.Printf("Expiration:\t%s\n", status.Expiration.Time)
+	if !status.ObjectLockExpiration.Time.IsZero() {
+		d.Printf("Object Lock Expiration:\t%s\n", status.ObjectLockExpiration.Time)
+	}
 	d.Println()
 
+	if status.StorageLocationReason != "" {
+		s := string(status.StorageLocationReason)
+		if status.StorageLocationMatchedLabel != "" {
+			s = fmt.Sprintf("%s (%s)", s, status.StorageLocationMatchedLabel)
+		}
+		d.Printf("Storage location chosen by:\t%s\n", s)
+		d.Println()
+	}
+
 	d.Printf("Validation errors:")
 	if len(status.ValidationErrors) == 0 {
 		d.Printf("\t<none>\n")
@@ -196,6 +320,138 @@ func DescribeBackupStatus(d *Describer, status arkv1api.BackupStatus) {
 		}
 	}
 
+	if len(status.ValidationWarnings) > 0 {
+		d.Printf("Validation warnings:")
+		for _, vw := range status.ValidationWarnings {
+			d.Printf("\t%s\n", vw)
+		}
+	}
+
+	if status.FailureReason != "" {
+		d.Println()
+		d.Printf("Failure reason:\t%s\n", status.FailureReason)
+	}
+
+	if status.TarballChecksum != "" {
+		d.Println()
+		d.Printf("Tarball checksum:\tsha256:%s\n", status.TarballChecksum)
+	}
+
+	if status.Progress != nil {
+		d.Println()
+		describeBackupProgress(d, status.Progress)
+	}
+
+	if status.SkippedItems > 0 {
+		d.Println()
+		d.Printf("Skipped items:\t%d\n", status.SkippedItems)
+	}
+
+	if status.UnchangedItems > 0 {
+		d.Println()
+		d.Printf("Items unchanged since the base backup:\t%d\n", status.UnchangedItems)
+	}
+
+	if resolved := status.ResolvedIncludesExcludes; resolved != nil {
+		d.Println()
+		d.Printf("Resolved namespaces:\n")
+		d.Printf("\tIncluded:\t%s\n", strings.Join(resolved.IncludedNamespaces, ", "))
+		s := "<none>"
+		if len(resolved.ExcludedNamespaces) > 0 {
+			s = strings.Join(resolved.ExcludedNamespaces, ", ")
+		}
+		d.Printf("\tExcluded:\t%s\n", s)
+
+		d.Println()
+		d.Printf("Resolved resources:\n")
+		d.Printf("\tIncluded:\t%s\n", strings.Join(resolved.IncludedResources, ", "))
+		s = "<none>"
+		if len(resolved.ExcludedResources) > 0 {
+			s = strings.Join(resolved.ExcludedResources, ", ")
+		}
+		d.Printf("\tExcluded:\t%s\n", s)
+	}
+
+	if status.Consistency != nil {
+		d.Println()
+		describeBackupConsistency(d, status.Consistency)
+	}
+
+	if len(status.SkippedLargeItems) > 0 {
+		d.Println()
+		d.Printf("Skipped large items:\n")
+		for _, item := range status.SkippedLargeItems {
+			name := item.Name
+			if item.Namespace != "" {
+				name = item.Namespace + "/" + name
+			}
+			d.Printf("\t%s %s:\t%d bytes\n", item.GroupResource, name, item.SizeBytes)
+		}
+	}
+
+	if len(status.SkippedUnlistableResources) > 0 {
+		d.Println()
+		d.Printf("Skipped unlistable resources:\n")
+		for _, resource := range status.SkippedUnlistableResources {
+			name := resource.GroupResource
+			if resource.Namespace != "" {
+				name += " in namespace " + resource.Namespace
+			}
+			d.Printf("\t%s:\t%s\n", name, resource.Error)
+		}
+	}
+
+	if len(status.KeptTempFiles) > 0 {
+		d.Println()
+		d.Printf("Kept temp files:\t%s\n", strings.Join(status.KeptTempFiles, ", "))
+	}
+
+	if len(status.SkippedTerminatingNamespaces) > 0 {
+		d.Println()
+		d.Printf("Skipped terminating namespaces:\t%s\n", strings.Join(status.SkippedTerminatingNamespaces, ", "))
+	}
+
+	if len(status.PartialFailures) > 0 {
+		d.Println()
+		d.Printf("Partial failures:\n")
+		for _, failure := range status.PartialFailures {
+			d.Printf("\t%s\n", failure)
+		}
+	}
+
+	if status.Warnings > 0 {
+		d.Println()
+		d.Printf("Warnings:\t%d (see the backup's log for details)\n", status.Warnings)
+	}
+
+	if status.LogTruncated {
+		d.Println()
+		d.Printf("Log:\tpossibly truncated (it couldn't be written in full)\n")
+	}
+
+	if len(status.BackupItemActionVersions) > 0 {
+		d.Println()
+		d.Printf("Backup item action plugins:\n")
+		names := make([]string, 0, len(status.BackupItemActionVersions))
+		for name := range status.BackupItemActionVersions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d.Printf("\t%s:\t%s\n", name, status.BackupItemActionVersions[name])
+		}
+	}
+
+	if len(status.HookResults) > 0 {
+		d.Println()
+		describeBackupHookResults(d, status.HookResults)
+	}
+
+	if len(status.LocationStatuses) > 0 {
+		d.Println()
+		describeLocationStatuses(d, status.LocationStatuses)
+	}
+
 	d.Println()
 	if len(status.VolumeBackups) == 0 {
 		d.Printf("Persistent Volumes: <none included>\n")
@@ -213,6 +469,100 @@ func DescribeBackupStatus(d *Describer, status arkv1api.BackupStatus) {
 			d.Printf("\t\tIOPS:\t%s\n", iops)
 		}
 	}
+
+	var fileCopiedPVs []string
+	for pvName, method := range status.VolumeBackupMethods {
+		if method == arkv1api.VolumeBackupMethodFileCopy {
+			fileCopiedPVs = append(fileCopiedPVs, pvName)
+		}
+	}
+	if len(fileCopiedPVs) > 0 {
+		sort.Strings(fileCopiedPVs)
+
+		d.Println()
+		d.Printf("File-Copied Persistent Volumes:\n")
+		for _, pvName := range fileCopiedPVs {
+			d.Printf("\t%s\n", pvName)
+		}
+	}
+}
+
+func describeBackupProgress(d *Describer, progress *arkv1api.BackupProgress) {
+	resources := make([]string, 0, len(progress.ResourceCounts))
+	for resource := range progress.ResourceCounts {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	d.Printf("Items backed up:\t%d\n", progress.ItemsBackedUp)
+	for _, resource := range resources {
+		d.Printf("\t%s:\t%d\n", resource, progress.ResourceCounts[resource])
+	}
+	d.Printf("Namespaces backed up:\t%d\n", progress.Namespaces)
+	d.Printf("Volume snapshots taken:\t%d\n", progress.VolumeSnapshots)
+
+	if len(progress.Items) > 0 {
+		d.Println()
+		d.Printf("Items:\n")
+		for _, item := range progress.Items {
+			d.Printf("\t%s\n", item)
+		}
+	}
+}
+
+func describeBackupConsistency(d *Describer, consistency *arkv1api.BackupConsistency) {
+	resources := make([]string, 0, len(consistency.Resources))
+	for resource := range consistency.Resources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	d.Printf("Start resource version:\t%s\n", consistency.StartResourceVersion)
+	d.Printf("Resource versions:\n")
+	for _, resource := range resources {
+		versions := consistency.Resources[resource]
+		s := fmt.Sprintf("%s-%s", versions.Min, versions.Max)
+		if versions.PotentiallyInconsistent {
+			s += " (potentially inconsistent)"
+		}
+		d.Printf("\t%s:\t%s\n", resource, s)
+	}
+
+	if len(consistency.PotentiallyInconsistentItems) > 0 {
+		d.Printf("Potentially inconsistent items:\n")
+		for _, item := range consistency.PotentiallyInconsistentItems {
+			d.Printf("\t%s\n", item)
+		}
+	}
+}
+
+func describeBackupHookResults(d *Describer, results []arkv1api.BackupHookResult) {
+	d.Printf("Hook results:\n")
+	for _, result := range results {
+		outcome := "succeeded"
+		if !result.Succeeded {
+			outcome = fmt.Sprintf("failed: %s", result.Error)
+		}
+		d.Printf("\t%s/%s (%s %s, container %s):\t%s\n", result.Namespace, result.Pod, result.Name, result.Phase, result.Container, outcome)
+	}
+}
+
+func describeLocationStatuses(d *Describer, statuses map[string]arkv1api.UploadStatus) {
+	locations := make([]string, 0, len(statuses))
+	for location := range statuses {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	d.Printf("Uploads:\n")
+	for _, location := range locations {
+		status := statuses[location]
+		outcome := string(status.Phase)
+		if status.Error != "" {
+			outcome = fmt.Sprintf("%s: %s", outcome, status.Error)
+		}
+		d.Printf("\t%s (%s):\t%s\n", location, status.Duration.Duration, outcome)
+	}
 }
 
 // DescribeDeleteBackupRequests describes delete backup requests in human-readable format.
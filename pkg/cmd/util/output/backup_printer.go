@@ -88,6 +88,9 @@ func printBackup(backup *arkv1api.Backup, w io.Writer, options printers.PrintOpt
 	if status == "" {
 		status = arkv1api.BackupPhaseNew
 	}
+	if backup.Spec.DryRun {
+		status += " (dry run)"
+	}
 	if backup.DeletionTimestamp != nil && !backup.DeletionTimestamp.Time.IsZero() {
 		status = "Deleting"
 	}
@@ -35,6 +35,9 @@ func DescribeRestore(restore *v1.Restore, podVolumeRestores []v1.PodVolumeRestor
 
 		d.Println()
 		d.Printf("Backup:\t%s\n", restore.Spec.BackupName)
+		if restore.Spec.StorageLocationOverride != "" {
+			d.Printf("Storage location:\t%s\n", restore.Spec.StorageLocationOverride)
+		}
 
 		d.Println()
 		d.Printf("Namespaces:\n")
@@ -82,6 +85,16 @@ func DescribeRestore(restore *v1.Restore, podVolumeRestores []v1.PodVolumeRestor
 		d.Println()
 		d.Printf("Restore PVs:\t%s\n", BoolPointerString(restore.Spec.RestorePVs, "false", "true", "auto"))
 
+		if restore.Spec.ExistingResourcePolicy == v1.ExistingResourcePolicyUpdate {
+			d.Println()
+			d.Printf("Existing resource policy:\t%s\n", restore.Spec.ExistingResourcePolicy)
+			s = string(restore.Spec.ImmutableFieldPolicy)
+			if s == "" {
+				s = string(v1.ImmutableFieldPolicySkip)
+			}
+			d.Printf("Immutable field policy:\t%s\n", s)
+		}
+
 		d.Println()
 		d.Printf("Phase:\t%s\n", restore.Status.Phase)
 
@@ -95,6 +108,11 @@ func DescribeRestore(restore *v1.Restore, podVolumeRestores []v1.PodVolumeRestor
 			}
 		}
 
+		if len(restore.Status.ItemCounts) > 0 {
+			d.Println()
+			describeRestoreItemCounts(d, restore.Status.ItemCounts)
+		}
+
 		d.Println()
 		describeRestoreResults(d, restore, arkClient)
 
@@ -105,6 +123,14 @@ func DescribeRestore(restore *v1.Restore, podVolumeRestores []v1.PodVolumeRestor
 	})
 }
 
+func describeRestoreItemCounts(d *Describer, counts []v1.RestoreItemCount) {
+	d.Printf("Item counts:\n")
+	d.Printf("\tResource\tIn backup\tRestored\tSkipped\n")
+	for _, count := range counts {
+		d.Printf("\t%s\t%d\t%d\t%d\n", count.GroupResource, count.InBackup, count.Restored, count.Skipped)
+	}
+}
+
 func describeRestoreResults(d *Describer, restore *v1.Restore, arkClient clientset.Interface) {
 	if restore.Status.Warnings == 0 && restore.Status.Errors == 0 {
 		d.Printf("Warnings:\t<none>\nErrors:\t<none>\n")
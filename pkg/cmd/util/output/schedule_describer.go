@@ -18,6 +18,7 @@ package output
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/heptio/ark/pkg/apis/ark/v1"
 )
@@ -36,6 +37,18 @@ func DescribeSchedule(schedule *v1.Schedule) string {
 
 func DescribeScheduleSpec(d *Describer, spec v1.ScheduleSpec) {
 	d.Printf("Schedule:\t%s\n", spec.Schedule)
+	if spec.BackupNameTemplate != "" {
+		d.Printf("Backup name template:\t%s\n", spec.BackupNameTemplate)
+	}
+	if spec.JitterMaxSeconds > 0 {
+		d.Printf("Jitter:\tup to %s\n", time.Duration(spec.JitterMaxSeconds)*time.Second)
+	}
+	if len(spec.BackupOwnerReferences) > 0 {
+		d.Printf("Backup owners:\n")
+		for _, ref := range spec.BackupOwnerReferences {
+			d.Printf("\t%s %s (%s)\n", ref.Kind, ref.Name, ref.APIVersion)
+		}
+	}
 
 	d.Println()
 	d.Println("Backup Template:")
@@ -19,39 +19,59 @@ package controller
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/backup"
+	arkdiscovery "github.com/heptio/ark/pkg/discovery"
 	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions/ark/v1"
 	listers "github.com/heptio/ark/pkg/generated/listers/ark/v1"
 	"github.com/heptio/ark/pkg/metrics"
+	"github.com/heptio/ark/pkg/notify"
 	"github.com/heptio/ark/pkg/persistence"
 	"github.com/heptio/ark/pkg/plugin"
+	"github.com/heptio/ark/pkg/transfer"
+	"github.com/heptio/ark/pkg/transform"
 	"github.com/heptio/ark/pkg/util/collections"
 	"github.com/heptio/ark/pkg/util/encode"
 	kubeutil "github.com/heptio/ark/pkg/util/kube"
 	"github.com/heptio/ark/pkg/util/logging"
+	"github.com/heptio/ark/pkg/util/stringslice"
 )
 
-const backupVersion = 1
-
 type backupController struct {
 	*genericController
 
@@ -59,47 +79,421 @@ type backupController struct {
 	pvProviderExists      bool
 	lister                listers.BackupLister
 	client                arkv1client.BackupsGetter
+	eventClient           corev1client.EventsGetter
+	eventLimiter          *eventRateLimiter
 	clock                 clock.Clock
 	backupLogLevel        logrus.Level
 	newPluginManager      func(logrus.FieldLogger) plugin.Manager
 	backupTracker         BackupTracker
+	patcher               BackupPatcher
 	backupLocationLister  listers.BackupStorageLocationLister
 	defaultBackupLocation string
+	clusterName           string
 	metrics               *metrics.ServerMetrics
+	rateLimiterConfig     RateLimiterConfig
+	uploadRetry           UploadRetryConfig
 	newBackupStore        func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)
+	transferEndpoint      transfer.Endpoint
+	encodeBackup          func(*api.Backup, io.Writer) error
+	newLogWriter          func(io.Writer) io.WriteCloser
+	transforms            transform.Pipeline
+	compression           archive.Compression
+	contentIndex          bool
+	maxBackupSizeBytes    int64
+	minBackupSizeBytes    int64
+	backupTimeout         time.Duration
+	backupTempDir         string
+	// keepTempFiles is whether backups' tarball and log temp files are
+	// left in backupTempDir, for debugging, rather than removed.
+	keepTempFiles         bool
+	credentials           persistence.CredentialsGetter
+	deleteStorage         bool
+	itemActionTimeout     time.Duration
+	streamUploads         bool
+	locationLabel         string
+	probeUnknownLocations bool
+	backupLogFormat       logging.Format
+	// backupSlots holds a value for each backup that's running, when the
+	// number of backups that can run at once is limited.
+	backupSlots chan struct{}
+	// shutdownGracePeriod is how long backups that are in progress when
+	// the controller's stopped are given to finish before they're aborted.
+	shutdownGracePeriod time.Duration
+	// shuttingDown and abortingBackups are set, atomically, once the
+	// controller's been stopped and once its in-progress backups are
+	// being aborted.
+	shuttingDown    int32
+	abortingBackups int32
+	// orphanedBackupTimeout is how long a backup can be InProgress without
+	// this controller running it before it's recovered, and
+	// cleanUpOrphanedBackups is whether what it uploaded is deleted then.
+	orphanedBackupTimeout  time.Duration
+	cleanUpOrphanedBackups bool
+	// discoveryHelper, if set, is used to warn about included resources
+	// the API server doesn't serve. Its resources are cached, and
+	// refreshed periodically by the server, so validating a backup doesn't
+	// query the API server.
+	discoveryHelper arkdiscovery.Helper
+	// compressMetadata is whether backups' metadata is gzipped before
+	// it's uploaded.
+	compressMetadata bool
+	// notifier, if set, notifies backups' webhooks once they've finished.
+	notifier notify.Notifier
+}
+
+const (
+	// backupEventBurst is how many events can be recorded about a backup
+	// at once, and backupEventInterval how often another one can be after
+	// that, so that a backup that's retried rapidly doesn't flood its
+	// namespace's events.
+	backupEventBurst    = 10
+	backupEventInterval = time.Minute
+
+	// maxEventMessageLength is how much of an error is included in an
+	// event's message.
+	maxEventMessageLength = 1024
+
+	// maxBackupMetadataEntries and maxBackupMetadataBytes limit the
+	// metadata in a backup's spec, which is copied into its status, so
+	// that it doesn't bloat the backup.
+	maxBackupMetadataEntries = 64
+	maxBackupMetadataBytes   = 16 * 1024
+
+	// backupSlotRequeueDelay is how long a backup that can't be run because
+	// too many others are waits before it's tried again.
+	backupSlotRequeueDelay = 5 * time.Second
+
+	// shutdownPollInterval is how often the controller checks whether its
+	// in-progress backups have finished while it's shutting down.
+	shutdownPollInterval = time.Second
+
+	// orphanedBackupSweepPeriod is how often the controller looks for
+	// backups that were left InProgress by a server that stopped.
+	orphanedBackupSweepPeriod = time.Minute
+
+	// manualBackupPriority and scheduledBackupPriority are the queue
+	// priorities of backups without and with an ark-schedule label, so
+	// that a burst of scheduled backups doesn't hold up manual ones.
+	manualBackupPriority    = 1
+	scheduledBackupPriority = 0
+)
+
+// BackupControllerOption overrides one of the defaults of a backup
+// controller created with NewBackupControllerWithOptions.
+type BackupControllerOption func(*backupController)
+
+// WithClock sets the clock that the controller reads the time from, e.g.
+// to timestamp backups, instead of the system clock.
+func WithClock(clock clock.Clock) BackupControllerOption {
+	return func(c *backupController) {
+		c.clock = clock
+	}
+}
+
+// WithBackupStoreFactory sets the function that the controller gets the
+// backup stores of storage locations from, instead of the one that creates
+// them with their object store plugins.
+func WithBackupStoreFactory(newBackupStore func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)) BackupControllerOption {
+	return func(c *backupController) {
+		c.newBackupStore = newBackupStore
+	}
+}
+
+// WithPluginManagerFactory sets the function that the controller gets a
+// plugin manager for each backup from, instead of the one it was created
+// with.
+func WithPluginManagerFactory(newPluginManager func(logrus.FieldLogger) plugin.Manager) BackupControllerOption {
+	return func(c *backupController) {
+		c.newPluginManager = newPluginManager
+	}
+}
+
+// WithBackupTempFilesKept sets whether the controller leaves the temp
+// files it stages backups' tarballs and logs in, rather than removing
+// them once the backups have been uploaded, so that they can be inspected
+// when debugging. Their paths are recorded in the backups' statuses.
+func WithBackupTempFilesKept(keep bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.keepTempFiles = keep
+	}
+}
+
+// WithClusterName sets the name of the cluster that the controller records
+// in its backups, to tell apart backups of different clusters that share a
+// storage location.
+func WithClusterName(clusterName string) BackupControllerOption {
+	return func(c *backupController) {
+		c.clusterName = clusterName
+	}
+}
+
+// WithRateLimiterConfig sets how backups that fail to be processed are
+// retried.
+func WithRateLimiterConfig(config RateLimiterConfig) BackupControllerOption {
+	return func(c *backupController) {
+		c.rateLimiterConfig = config
+	}
+}
+
+// WithUploadRetryConfig sets how backups' uploads are retried when they
+// fail.
+func WithUploadRetryConfig(config UploadRetryConfig) BackupControllerOption {
+	return func(c *backupController) {
+		c.uploadRetry = config
+	}
+}
+
+// WithTransforms sets the pipeline that the controller applies to each
+// item it backs up.
+func WithTransforms(transforms transform.Pipeline) BackupControllerOption {
+	return func(c *backupController) {
+		c.transforms = transforms
+	}
+}
+
+// WithCompression sets how backups' tarballs are compressed, instead of
+// gzip at its default level.
+func WithCompression(compression archive.Compression) BackupControllerOption {
+	return func(c *backupController) {
+		c.compression = compression
+	}
+}
+
+// WithContentIndex sets whether the controller uploads an index of each
+// backup's contents alongside its tarball.
+func WithContentIndex(contentIndex bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.contentIndex = contentIndex
+	}
+}
+
+// WithBackupSizeLimits sets the smallest and largest tarballs, in bytes,
+// that backups can have. Zero means no limit.
+func WithBackupSizeLimits(minBytes, maxBytes int64) BackupControllerOption {
+	return func(c *backupController) {
+		c.minBackupSizeBytes = minBytes
+		c.maxBackupSizeBytes = maxBytes
+	}
+}
+
+// WithBackupTimeout sets how long a backup can run before it's aborted.
+// Zero means no limit.
+func WithBackupTimeout(timeout time.Duration) BackupControllerOption {
+	return func(c *backupController) {
+		c.backupTimeout = timeout
+	}
+}
+
+// WithBackupTempDir sets the directory that backups' tarballs and logs are
+// staged in, instead of the system's temp dir.
+func WithBackupTempDir(dir string) BackupControllerOption {
+	return func(c *backupController) {
+		c.backupTempDir = dir
+	}
+}
+
+// WithStorageDeletedOnRemoval sets whether backups' data is deleted from
+// object storage when they're deleted, unless their specs say otherwise.
+func WithStorageDeletedOnRemoval(deleteStorage bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.deleteStorage = deleteStorage
+	}
+}
+
+// WithItemActionTimeout sets how long each backup item action plugin can
+// take on an item. Zero means no limit.
+func WithItemActionTimeout(timeout time.Duration) BackupControllerOption {
+	return func(c *backupController) {
+		c.itemActionTimeout = timeout
+	}
+}
+
+// WithStreamedUploads sets whether backups' tarballs are uploaded as
+// they're written, rather than once they're complete.
+func WithStreamedUploads(streamUploads bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.streamUploads = streamUploads
+	}
+}
+
+// WithLocationLabel sets the label key that chooses the storage location
+// of backups that don't set one: the location with the same value for it.
+func WithLocationLabel(label string) BackupControllerOption {
+	return func(c *backupController) {
+		c.locationLabel = label
+	}
+}
+
+// WithUnknownLocationProbing sets whether storage locations that aren't
+// known to be available are probed before backups are run against them.
+func WithUnknownLocationProbing(probe bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.probeUnknownLocations = probe
+	}
+}
+
+// WithBackupLogFormat sets the format of backups' logs, instead of text.
+func WithBackupLogFormat(format logging.Format) BackupControllerOption {
+	return func(c *backupController) {
+		c.backupLogFormat = format
+	}
+}
+
+// WithMaxConcurrentBackups limits how many backups can run at once. Zero
+// means no limit.
+func WithMaxConcurrentBackups(max int) BackupControllerOption {
+	return func(c *backupController) {
+		c.backupSlots = nil
+		if max > 0 {
+			c.backupSlots = make(chan struct{}, max)
+		}
+	}
 }
 
+// WithShutdownGracePeriod sets how long backups that are in progress when
+// the controller's stopped are given to finish before they're aborted.
+func WithShutdownGracePeriod(period time.Duration) BackupControllerOption {
+	return func(c *backupController) {
+		c.shutdownGracePeriod = period
+	}
+}
+
+// WithOrphanedBackupRecovery sets how long a backup can be InProgress
+// without this controller running it before it's recovered, and whether
+// what it uploaded is deleted then. Zero means orphaned backups aren't
+// recovered.
+func WithOrphanedBackupRecovery(timeout time.Duration, cleanUp bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.orphanedBackupTimeout = timeout
+		c.cleanUpOrphanedBackups = cleanUp
+	}
+}
+
+// WithDiscoveryHelper sets the discovery helper that the controller uses
+// to warn about included resources the API server doesn't serve.
+func WithDiscoveryHelper(helper arkdiscovery.Helper) BackupControllerOption {
+	return func(c *backupController) {
+		c.discoveryHelper = helper
+	}
+}
+
+// WithMetadataCompression sets whether backups' metadata is gzipped before
+// it's uploaded.
+func WithMetadataCompression(compress bool) BackupControllerOption {
+	return func(c *backupController) {
+		c.compressMetadata = compress
+	}
+}
+
+// WithNotifier sets the notifier that the controller notifies backups'
+// webhooks with once they've finished.
+func WithNotifier(notifier notify.Notifier) BackupControllerOption {
+	return func(c *backupController) {
+		c.notifier = notifier
+	}
+}
+
+// WithTransferEndpoint sets the transfer endpoint that backups annotated
+// with api.TransferAnnotation are streamed to. Without one, such backups
+// fail validation.
+func WithTransferEndpoint(endpoint transfer.Endpoint) BackupControllerOption {
+	return func(c *backupController) {
+		c.transferEndpoint = endpoint
+	}
+}
+
+// NewBackupController returns a backup controller with the default
+// settings. Use NewBackupControllerWithOptions to change them.
 func NewBackupController(
 	backupInformer informers.BackupInformer,
 	client arkv1client.BackupsGetter,
+	eventClient corev1client.EventsGetter,
+	backupper backup.Backupper,
+	pvProviderExists bool,
+	logger logrus.FieldLogger,
+	backupLogLevel logrus.Level,
+	newPluginManager func(logrus.FieldLogger) plugin.Manager,
+	encryptionKeys persistence.EncryptionKeyGetter,
+	credentials persistence.CredentialsGetter,
+	backupTracker BackupTracker,
+	patcher BackupPatcher,
+	backupLocationInformer informers.BackupStorageLocationInformer,
+	defaultBackupLocation string,
+	metrics *metrics.ServerMetrics,
+) Interface {
+	return NewBackupControllerWithOptions(
+		backupInformer,
+		client,
+		eventClient,
+		backupper,
+		pvProviderExists,
+		logger,
+		backupLogLevel,
+		newPluginManager,
+		encryptionKeys,
+		credentials,
+		backupTracker,
+		patcher,
+		backupLocationInformer,
+		defaultBackupLocation,
+		metrics,
+	)
+}
+
+// NewBackupControllerWithOptions is NewBackupController, with options that
+// override the controller's defaults.
+func NewBackupControllerWithOptions(
+	backupInformer informers.BackupInformer,
+	client arkv1client.BackupsGetter,
+	eventClient corev1client.EventsGetter,
 	backupper backup.Backupper,
 	pvProviderExists bool,
 	logger logrus.FieldLogger,
 	backupLogLevel logrus.Level,
 	newPluginManager func(logrus.FieldLogger) plugin.Manager,
+	encryptionKeys persistence.EncryptionKeyGetter,
+	credentials persistence.CredentialsGetter,
 	backupTracker BackupTracker,
+	patcher BackupPatcher,
 	backupLocationInformer informers.BackupStorageLocationInformer,
 	defaultBackupLocation string,
 	metrics *metrics.ServerMetrics,
+	options ...BackupControllerOption,
 ) Interface {
 	c := &backupController{
-		genericController:     newGenericController("backup", logger),
 		backupper:             backupper,
 		pvProviderExists:      pvProviderExists,
 		lister:                backupInformer.Lister(),
 		client:                client,
+		eventClient:           eventClient,
+		eventLimiter:          newEventRateLimiter(backupEventBurst, backupEventInterval),
 		clock:                 &clock.RealClock{},
 		backupLogLevel:        backupLogLevel,
 		newPluginManager:      newPluginManager,
 		backupTracker:         backupTracker,
+		patcher:               patcher,
 		backupLocationLister:  backupLocationInformer.Lister(),
 		defaultBackupLocation: defaultBackupLocation,
 		metrics:               metrics,
+		compression:           archive.Compression{Algorithm: archive.CompressionGzip},
+		credentials:           credentials,
+
+		newBackupStore: persistence.NewBackupStoreFactory(encryptionKeys),
+		encodeBackup:   encodeBackupJSON,
+	}
 
-		newBackupStore: persistence.NewObjectBackupStore,
+	for _, option := range options {
+		option(c)
 	}
 
+	// the queue's rate limiter may have been configured by an option.
+	c.genericController = newGenericControllerWithPriority("backup", logger, c.rateLimiterConfig, backupPriority(backupInformer.Lister()))
 	c.syncHandler = c.processBackup
+	c.retriesExhaustedFunc = c.backupRetriesExhausted
+	if c.orphanedBackupTimeout > 0 {
+		c.resyncFunc = c.recoverOrphanedBackups
+		c.resyncPeriod = orphanedBackupSweepPeriod
+	}
 	c.cacheSyncWaiters = append(c.cacheSyncWaiters,
 		backupInformer.Informer().HasSynced,
 		backupLocationInformer.Informer().HasSynced,
@@ -118,6 +512,7 @@ func NewBackupController(
 						"backup": kubeutil.NamespaceAndName(backup),
 						"phase":  backup.Status.Phase,
 					}).Debug("Backup is not new, skipping")
+					c.metrics.RegisterBackupSkippedNotNew(string(backup.Status.Phase))
 					return
 				}
 
@@ -128,25 +523,75 @@ func NewBackupController(
 				}
 				c.queue.Add(key)
 			},
+			UpdateFunc: func(_, obj interface{}) {
+				backup := obj.(*api.Backup)
+
+				// an in-progress backup's key isn't dequeued again until
+				// processBackup returns, so it's cancelled from here.
+				if backup.Spec.Cancel && backup.Status.Phase == api.BackupPhaseInProgress {
+					c.cancelBackup(backup)
+				}
+			},
 		},
 	)
 
 	return c
 }
 
+// backupPriority returns a function that gives the queue key of a backup
+// in lister its priority: manual backups are processed before scheduled
+// ones. Keys of backups that can't be found get the lower priority.
+func backupPriority(lister listers.BackupLister) func(key string) int {
+	return func(key string) int {
+		ns, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return scheduledBackupPriority
+		}
+
+		backup, err := lister.Backups(ns).Get(name)
+		if err != nil {
+			return scheduledBackupPriority
+		}
+
+		if _, ok := backup.GetLabels()["ark-schedule"]; ok {
+			return scheduledBackupPriority
+		}
+		return manualBackupPriority
+	}
+}
+
+// cancelBackup cancels an in-progress backup that's being run by this
+// controller, and moves it to the Cancelling phase. The backup is moved
+// to the Cancelled phase once runBackup has returned.
+func (c *backupController) cancelBackup(backup *api.Backup) {
+	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+
+	if !c.backupTracker.Cancel(backup.Namespace, backup.Name) {
+		log.Debug("Backup is not being run by this controller, so it can't be cancelled")
+		return
+	}
+	log.Info("Cancelling backup")
+
+	updated := backup.DeepCopy()
+	updated.Status.Phase = api.BackupPhaseCancelling
+	if _, err := patchBackup(backup, updated, c.client); err != nil {
+		log.WithError(err).Error("Error updating backup's phase to Cancelling")
+	}
+}
+
 func (c *backupController) processBackup(key string) error {
 	log := c.logger.WithField("key", key)
 
-	log.Debug("Running processBackup")
+	log.WithField("retries", c.numRetries(key)).Debug("Running processBackup")
 	ns, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
-		return errors.Wrap(err, "error splitting queue key")
+		return newPermanentError(errors.Wrap(err, "error splitting queue key"))
 	}
 
 	log.Debug("Getting backup")
 	backup, err := c.lister.Backups(ns).Get(name)
 	if err != nil {
-		return errors.Wrap(err, "error getting backup")
+		return backupSyncError(errors.Wrap(err, "error getting backup"))
 	}
 
 	// Double-check we have the correct phase. In the unlikely event that multiple controller
@@ -164,14 +609,67 @@ func (c *backupController) processBackup(key string) error {
 		return nil
 	}
 
+	// a backup that's cancelled before it starts isn't run at all.
+	if backup.Spec.Cancel {
+		log.Info("Backup was cancelled before it started")
+		updated := backup.DeepCopy()
+		updated.Status.Phase = api.BackupPhaseCancelled
+		patched, err := c.patcher.Patch(backup, updated)
+		if err != nil {
+			return backupSyncError(errors.Wrapf(err, "error updating Backup status to %s", updated.Status.Phase))
+		}
+		c.notifyBackupFinished(patched)
+		return nil
+	}
+
+	// The same key can be dequeued again while it's still being processed,
+	// e.g. when the rate limiter requeues it quickly after a patch
+	// conflict, so make sure this controller only runs it once.
+	if !c.backupTracker.AddIfAbsent(ns, name) {
+		log.Debug("Backup is already being processed")
+		return nil
+	}
+	defer c.backupTracker.Delete(ns, name)
+
+	// backups that haven't started by the time the controller's stopped
+	// stay New, and are run once the server's restarted.
+	if atomic.LoadInt32(&c.shuttingDown) != 0 {
+		log.Info("Not starting backup because the controller is shutting down")
+		return nil
+	}
+
+	// Only so many backups run at once. The rest stay New, and are requeued
+	// until one of the running ones finishes.
+	if !c.acquireBackupSlot() {
+		return newRequeueError(errors.Errorf("%d backups are already in progress", cap(c.backupSlots)), backupSlotRequeueDelay)
+	}
+	defer c.releaseBackupSlot()
+
+	// the backup can be cancelled from the informer's update handler while
+	// it's in progress.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.backupTracker.SetCancelFunc(ns, name, cancel)
+	// the backup may have been missed by shutDown if it was aborting
+	// backups while this one was starting.
+	if atomic.LoadInt32(&c.abortingBackups) != 0 {
+		cancel()
+	}
+
 	log.Debug("Cloning backup")
 	// store ref to original for creating patch
 	original := backup
 	// don't modify items in the cache
 	backup = backup.DeepCopy()
 
-	// set backup version
-	backup.Status.Version = backupVersion
+	// record the format version the backup is written in, so it's uploaded
+	// with the backup's metadata and checked when the backup is restored
+	backup.Status.Version = persistence.BackupVersion(backup)
+
+	// carry the description through to the status so it travels with the
+	// backup's metadata in object storage
+	backup.Status.Description = backup.Spec.Description
+	backup.Status.Metadata = backup.Spec.Metadata
 
 	// calculate expiration
 	if backup.Spec.TTL.Duration > 0 {
@@ -184,196 +682,1879 @@ func (c *backupController) processBackup(key string) error {
 		backup.Status.Phase = api.BackupPhaseFailedValidation
 	} else {
 		backup.Status.Phase = api.BackupPhaseInProgress
+
+		if c.deleteStorageOnRemoval(backup) && !stringslice.Has(backup.Finalizers, api.DeleteBackupStorageFinalizer) {
+			backup.Finalizers = append(backup.Finalizers, api.DeleteBackupStorageFinalizer)
+		}
+
+		// record the base of an incremental backup before it's run, so
+		// that the base isn't deleted while the backup depends on it.
+		if backup.Spec.BaseBackup != "" {
+			if backup.Labels == nil {
+				backup.Labels = make(map[string]string)
+			}
+			backup.Labels[api.BaseBackupNameLabel] = backup.Spec.BaseBackup
+		}
 	}
 
 	// update status
-	updatedBackup, err := patchBackup(original, backup, c.client)
+	updatedBackup, err := c.patcher.Patch(original, backup)
 	if err != nil {
-		return errors.Wrapf(err, "error updating Backup status to %s", backup.Status.Phase)
+		return backupSyncError(errors.Wrapf(err, "error updating Backup status to %s", backup.Status.Phase))
 	}
 	// store ref to just-updated item for creating patch
 	original = updatedBackup
 	backup = updatedBackup.DeepCopy()
 
 	if backup.Status.Phase == api.BackupPhaseFailedValidation {
+		c.notifyBackupFinished(backup)
 		return nil
 	}
 
-	c.backupTracker.Add(backup.Namespace, backup.Name)
-	defer c.backupTracker.Delete(backup.Namespace, backup.Name)
-
 	log.Debug("Running backup")
 	// execution & upload of backup
 	backupScheduleName := backup.GetLabels()["ark-schedule"]
 	c.metrics.RegisterBackupAttempt(backupScheduleName)
 
-	if err := c.runBackup(backup, backupLocation); err != nil {
+	inProgressAt := c.clock.Now()
+	c.metrics.RegisterBackupPendingDuration(backupScheduleName, inProgressAt.Sub(backup.CreationTimestamp.Time).Seconds())
+
+	if timeout := c.timeoutForBackup(backup); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := c.runBackup(ctx, backup, backupLocation); err != nil {
 		log.WithError(err).Error("backup failed")
 		backup.Status.Phase = api.BackupPhaseFailed
 		c.metrics.RegisterBackupFailed(backupScheduleName)
+	} else if backup.Status.Phase == api.BackupPhaseCancelled {
+		log.Info("backup was cancelled")
+	} else if backup.Status.Phase == api.BackupPhasePartiallyFailed {
+		c.metrics.RegisterBackupPartialFailure(backupScheduleName)
+	} else if backup.Status.Phase == api.BackupPhaseCompletedEmpty {
+		c.metrics.RegisterBackupEmpty(backupScheduleName)
 	} else {
 		c.metrics.RegisterBackupSuccess(backupScheduleName)
 	}
 
+	// the backup may have been modified while it ran, so its final status
+	// is patched onto its latest version.
 	log.Debug("Updating backup's final status")
-	if _, err := patchBackup(original, backup, c.client); err != nil {
+	if _, err := c.patcher.PatchLatest(original, backup); err != nil {
 		log.WithError(err).Error("error updating backup's final status")
 	}
+	c.metrics.RegisterBackupInProgressDuration(backupScheduleName, c.clock.Since(inProgressAt).Seconds())
+	c.notifyBackupFinished(backup)
 
 	return nil
 }
 
-func patchBackup(original, updated *api.Backup, client arkv1client.BackupsGetter) (*api.Backup, error) {
-	origBytes, err := json.Marshal(original)
-	if err != nil {
-		return nil, errors.Wrap(err, "error marshalling original backup")
+// notifyBackupFinished notifies backup's webhook, if it has one, that it's
+// finished. The webhook's called in the background, and its errors are
+// only logged, so that it never holds up or fails the backup.
+func (c *backupController) notifyBackupFinished(backup *api.Backup) {
+	if c.notifier == nil || backup.Spec.NotifyWebhook == nil {
+		return
 	}
 
-	updatedBytes, err := json.Marshal(updated)
-	if err != nil {
-		return nil, errors.Wrap(err, "error marshalling updated backup")
+	backup = backup.DeepCopy()
+	go func() {
+		if err := c.notifier.Notify(backup); err != nil {
+			c.logger.WithError(err).WithField("backup", kubeutil.NamespaceAndName(backup)).Warn("Error notifying backup's webhook")
+		}
+	}()
+}
+
+// validateBackupMetadata returns a validation error for each of the
+// metadata's keys that isn't valid, and for metadata that's too large.
+func validateBackupMetadata(metadata map[string]string) []string {
+	var (
+		errs []string
+		keys []string
+		size int
+	)
+
+	for key, value := range metadata {
+		keys = append(keys, key)
+		size += len(key) + len(value)
 	}
+	sort.Strings(keys)
 
-	patchBytes, err := jsonpatch.CreateMergePatch(origBytes, updatedBytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "error creating json merge patch for backup")
+	for _, key := range keys {
+		if problems := validation.IsConfigMapKey(key); len(problems) > 0 {
+			errs = append(errs, fmt.Sprintf("Invalid metadata key %q: %s", key, strings.Join(problems, ", ")))
+		}
 	}
 
-	res, err := client.Backups(original.Namespace).Patch(original.Name, types.MergePatchType, patchBytes)
+	if len(metadata) > maxBackupMetadataEntries {
+		errs = append(errs, fmt.Sprintf("Metadata has %d entries, more than the maximum of %d", len(metadata), maxBackupMetadataEntries))
+	}
+	if size > maxBackupMetadataBytes {
+		errs = append(errs, fmt.Sprintf("Metadata is %d bytes, more than the maximum of %d", size, maxBackupMetadataBytes))
+	}
+
+	return errs
+}
+
+// finalizeExistingBackup finalizes the status of a backup that was
+// already uploaded to location, by an earlier attempt at it, from the
+// metadata that was uploaded with it.
+func finalizeExistingBackup(log logrus.FieldLogger, backup *api.Backup, backupStore persistence.BackupStore, location string) error {
+	existing, err := backupStore.GetBackupMetadata(backup.Name)
 	if err != nil {
-		return nil, errors.Wrap(err, "error patching backup")
+		return errors.WithMessage(err, "error getting metadata of backup that already exists in its storage location")
 	}
 
-	return res, nil
+	log.Info("Backup already exists in its storage location, so finalizing its status instead of running it again")
+
+	if backup.Annotations == nil {
+		backup.Annotations = make(map[string]string)
+	}
+	for k, v := range existing.Annotations {
+		backup.Annotations[k] = v
+	}
+
+	// the uploaded status is the backup's final one, except that its
+	// uploads are recorded after it's uploaded.
+	backup.Status = existing.Status
+	backup.Status.LocationStatuses = map[string]api.UploadStatus{
+		location: {Phase: api.UploadPhaseSucceeded},
+	}
+
+	return nil
 }
 
-func (c *backupController) getLocationAndValidate(itm *api.Backup, defaultBackupLocation string) (*api.BackupStorageLocation, []string) {
-	var validationErrors []string
+// Run runs the controller until ctx is done. Backups that are in progress
+// then are given the shutdown grace period to finish, and are aborted and
+// marked Failed if they haven't, so that none are left InProgress when the
+// server exits.
+func (c *backupController) Run(ctx context.Context, numWorkers int) error {
+	go func() {
+		<-ctx.Done()
+		c.shutDown()
+	}()
+
+	return c.genericController.Run(ctx, numWorkers)
+}
 
-	for _, err := range collections.ValidateIncludesExcludes(itm.Spec.IncludedResources, itm.Spec.ExcludedResources) {
-		validationErrors = append(validationErrors, fmt.Sprintf("Invalid included/excluded resource lists: %v", err))
+// shutDown stops new backups from starting, waits up to the shutdown
+// grace period for the backups in progress to finish, and then aborts any
+// that haven't.
+func (c *backupController) shutDown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+
+	deadline := c.clock.Now().Add(c.shutdownGracePeriod)
+	for {
+		inProgress := c.backupTracker.List()
+		if len(inProgress) == 0 {
+			return
+		}
+
+		if !c.clock.Now().Before(deadline) {
+			break
+		}
+
+		c.logger.WithField("backups", strings.Join(inProgress, ", ")).Info("Waiting for in-progress backups to finish before shutting down")
+		c.clock.Sleep(shutdownPollInterval)
 	}
 
-	for _, err := range collections.ValidateIncludesExcludes(itm.Spec.IncludedNamespaces, itm.Spec.ExcludedNamespaces) {
-		validationErrors = append(validationErrors, fmt.Sprintf("Invalid included/excluded namespace lists: %v", err))
+	atomic.StoreInt32(&c.abortingBackups, 1)
+	for _, key := range c.backupTracker.List() {
+		ns, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+
+		c.logger.WithField("backup", key).Warn("Aborting backup because it didn't finish within the shutdown grace period")
+		c.backupTracker.Cancel(ns, name)
 	}
+}
 
-	if !c.pvProviderExists && itm.Spec.SnapshotVolumes != nil && *itm.Spec.SnapshotVolumes {
-		validationErrors = append(validationErrors, "Server is not configured for PV snapshots")
+// acquireBackupSlot returns whether another backup can run, and if so,
+// takes the slot it runs in.
+func (c *backupController) acquireBackupSlot() bool {
+	if c.backupSlots == nil {
+		return true
 	}
 
-	if itm.Spec.StorageLocation == "" {
-		itm.Spec.StorageLocation = defaultBackupLocation
+	select {
+	case c.backupSlots <- struct{}{}:
+		return true
+	default:
+		return false
 	}
+}
 
-	// add the storage location as a label for easy filtering later.
-	if itm.Labels == nil {
-		itm.Labels = make(map[string]string)
+// releaseBackupSlot frees the slot taken by acquireBackupSlot.
+func (c *backupController) releaseBackupSlot() {
+	if c.backupSlots != nil {
+		<-c.backupSlots
 	}
-	itm.Labels[api.StorageLocationLabel] = itm.Spec.StorageLocation
+}
 
-	var backupLocation *api.BackupStorageLocation
-	backupLocation, err := c.backupLocationLister.BackupStorageLocations(itm.Namespace).Get(itm.Spec.StorageLocation)
+// backupSyncError marks err as permanent if it's an API error that
+// retrying processBackup won't fix, because the backup was deleted or the
+// update to it is invalid. Other errors, such as conflicts, are transient.
+func backupSyncError(err error) error {
+	if cause := errors.Cause(err); apierrors.IsNotFound(cause) || apierrors.IsInvalid(cause) {
+		return newPermanentError(err)
+	}
+	return err
+}
+
+// backupRetriesExhausted records a terminal failure for a backup key that
+// has been dropped from the queue after repeatedly failing to sync, and
+// marks the backup as Failed so that it isn't left New.
+func (c *backupController) backupRetriesExhausted(key string, syncErr error) {
+	var backupScheduleName string
+
+	if ns, name, err := cache.SplitMetaNamespaceKey(key); err == nil {
+		if backup, err := c.lister.Backups(ns).Get(name); err == nil {
+			backupScheduleName = backup.GetLabels()["ark-schedule"]
+			c.failBackupAfterRetries(backup, syncErr)
+		}
+	}
+
+	c.metrics.RegisterBackupRetriesExhausted(backupScheduleName)
+}
+
+func (c *backupController) failBackupAfterRetries(backup *api.Backup, syncErr error) {
+	switch backup.Status.Phase {
+	case "", api.BackupPhaseNew:
+	default:
+		// the backup was processed, so its phase is already final
+		return
+	}
+
+	updated := backup.DeepCopy()
+	updated.Status.Phase = api.BackupPhaseFailed
+	updated.Status.FailureReason = fmt.Sprintf("backup could not be processed after %d attempts: %v", c.maxRetries+1, syncErr)
+
+	if _, err := patchBackup(backup, updated, c.client); err != nil {
+		c.logger.WithError(err).WithField("backup", kubeutil.NamespaceAndName(backup)).Error("Error marking backup as Failed after its retries were exhausted")
+	}
+}
+
+// recoverOrphanedBackups finds the backups that have been InProgress or
+// Cancelling for longer than the orphaned backup timeout without this
+// controller running them, e.g. because the server that was running them
+// crashed, and moves them to a final phase.
+func (c *backupController) recoverOrphanedBackups() {
+	backups, err := c.lister.List(labels.Everything())
 	if err != nil {
-		validationErrors = append(validationErrors, fmt.Sprintf("Error getting backup storage location: %v", err))
+		c.logger.WithError(errors.WithStack(err)).Error("Error listing backups")
+		return
 	}
 
-	return backupLocation, validationErrors
+	for _, backup := range backups {
+		switch backup.Status.Phase {
+		case api.BackupPhaseInProgress, api.BackupPhaseCancelling:
+		default:
+			continue
+		}
+
+		if c.backupTracker.Contains(backup.Namespace, backup.Name) {
+			continue
+		}
+
+		if c.clock.Since(backup.Status.StartTimestamp.Time) < c.orphanedBackupTimeout {
+			continue
+		}
+
+		c.recoverOrphanedBackup(backup)
+	}
 }
 
-func (c *backupController) runBackup(backup *api.Backup, backupLocation *api.BackupStorageLocation) error {
+// recoverOrphanedBackup finalizes an orphaned backup's status from its
+// storage location if all of it was uploaded before the server running it
+// stopped, and otherwise marks it as Failed, or as Cancelled if it was
+// being cancelled.
+func (c *backupController) recoverOrphanedBackup(backup *api.Backup) {
 	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
-	log.Info("Starting backup")
-	backup.Status.StartTimestamp.Time = c.clock.Now()
+	log.Warn("Recovering backup that was left in progress by a server that stopped")
 
-	logFile, err := ioutil.TempFile("", "")
-	if err != nil {
-		return errors.Wrap(err, "error creating temp file for backup log")
+	var (
+		updated = backup.DeepCopy()
+		failErr error
+	)
+	if !c.finalizeOrphanedBackup(log, updated) {
+		if backup.Status.Phase == api.BackupPhaseCancelling {
+			updated.Status.Phase = api.BackupPhaseCancelled
+			updated.Status.FailureReason = "backup was cancelled"
+		} else {
+			updated.Status.Phase = api.BackupPhaseFailed
+			updated.Status.FailureReason = "backup didn't finish because the Ark server running it stopped, e.g. because it was restarted"
+			failErr = errors.New(updated.Status.FailureReason)
+			c.metrics.RegisterBackupFailed(backup.GetLabels()["ark-schedule"])
+		}
+		updated.Status.CompletionTimestamp.Time = c.clock.Now()
 	}
-	gzippedLogFile := gzip.NewWriter(logFile)
-	// Assuming we successfully uploaded the log file, this will have already been closed below. It is safe to call
-	// close multiple times. If we get an error closing this, there's not really anything we can do about it.
-	defer gzippedLogFile.Close()
-	defer closeAndRemoveFile(logFile, c.logger)
 
-	// Log the backup to both a backup log file and to stdout. This will help see what happened if the upload of the
-	// backup log failed for whatever reason.
-	logger := logging.DefaultLogger(c.backupLogLevel)
-	logger.Out = io.MultiWriter(os.Stdout, gzippedLogFile)
-	log = logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+	if _, err := patchBackup(backup, updated, c.client); err != nil {
+		log.WithError(err).Error("Error updating status of backup that was left in progress")
+		return
+	}
 
-	log.Info("Starting backup")
+	c.recordBackupResultEvent(updated, failErr)
+}
 
-	backupFile, err := ioutil.TempFile("", "")
+// finalizeOrphanedBackup finalizes backup's status if all of it was
+// uploaded to its storage location, returning whether it was. If it wasn't,
+// what was uploaded is deleted if the controller cleans up orphaned
+// backups.
+func (c *backupController) finalizeOrphanedBackup(log logrus.FieldLogger, backup *api.Backup) bool {
+	location, err := c.backupLocationLister.BackupStorageLocations(backup.Namespace).Get(backup.Spec.StorageLocation)
 	if err != nil {
-		return errors.Wrap(err, "error creating temp file for backup")
+		log.WithError(errors.WithStack(err)).Warn("Unable to get the backup's storage location to check whether it was uploaded")
+		return false
 	}
-	defer closeAndRemoveFile(backupFile, log)
 
 	pluginManager := c.newPluginManager(log)
 	defer pluginManager.CleanupClients()
 
-	actions, err := pluginManager.GetBackupItemActions()
+	backupStore, err := c.newBackupStore(location, pluginManager, log)
 	if err != nil {
-		return err
+		log.WithError(err).Warn("Unable to check whether the backup was uploaded")
+		return false
 	}
 
-	backupStore, err := c.newBackupStore(backupLocation, pluginManager, log)
+	exists, err := backupStore.BackupExists(backup.Name)
 	if err != nil {
-		return err
+		log.WithError(err).Warn("Unable to check whether the backup was uploaded")
+		return false
 	}
 
-	var errs []error
+	if exists {
+		if err := finalizeExistingBackup(log, backup, backupStore, location.Name); err != nil {
+			log.WithError(err).Warn("Unable to finalize the backup's status from its storage location")
+			return false
+		}
+		return true
+	}
 
-	var backupJSONToUpload, backupFileToUpload io.Reader
+	if c.cleanUpOrphanedBackups {
+		log.Info("Deleting what was uploaded of the backup from its storage location")
+		if err := backupStore.DeleteBackup(backup.Name); err != nil {
+			log.WithError(err).Warn("Unable to delete what was uploaded of the backup")
+		}
+	}
 
-	// Do the actual backup
-	if err := c.backupper.Backup(log, backup, backupFile, actions); err != nil {
-		errs = append(errs, err)
+	return false
+}
 
-		backup.Status.Phase = api.BackupPhaseFailed
-	} else {
-		backup.Status.Phase = api.BackupPhaseCompleted
+func patchBackup(original, updated *api.Backup, client arkv1client.BackupsGetter) (*api.Backup, error) {
+	patchBytes, err := backupMergePatch(original, updated)
+	if err != nil {
+		return nil, err
 	}
 
-	// Mark completion timestamp before serializing and uploading.
-	// Otherwise, the JSON file in object storage has a CompletionTimestamp of 'null'.
-	backup.Status.CompletionTimestamp.Time = c.clock.Now()
-
-	backupJSON := new(bytes.Buffer)
-	if err := encode.EncodeTo(backup, "json", backupJSON); err != nil {
-		errs = append(errs, errors.Wrap(err, "error encoding backup"))
-	} else {
-		// Only upload the json and backup tarball if encoding to json succeeded.
-		backupJSONToUpload = backupJSON
-		backupFileToUpload = backupFile
+	res, err := client.Backups(original.Namespace).Patch(original.Name, types.MergePatchType, patchBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error patching backup")
 	}
 
-	var backupSizeBytes int64
-	if backupFileStat, err := backupFile.Stat(); err != nil {
-		errs = append(errs, errors.Wrap(err, "error getting file info"))
-	} else {
-		backupSizeBytes = backupFileStat.Size()
+	return res, nil
+}
+
+// backupMergePatch returns a JSON merge patch of the changes from original
+// to updated.
+func backupMergePatch(original, updated *api.Backup) ([]byte, error) {
+	// the controller never removes a backup's owner references, so an
+	// updated copy without them mustn't patch them away: they're what
+	// garbage-collects the backup when its owner is deleted.
+	if len(updated.OwnerReferences) == 0 && len(original.OwnerReferences) > 0 {
+		updated = updated.DeepCopy()
+		updated.OwnerReferences = original.OwnerReferences
 	}
 
-	if err := gzippedLogFile.Close(); err != nil {
-		c.logger.WithError(err).Error("error closing gzippedLogFile")
+	origBytes, err := json.Marshal(original)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling original backup")
 	}
 
-	if err := backupStore.PutBackup(backup.Name, backupJSONToUpload, backupFileToUpload, logFile); err != nil {
-		errs = append(errs, err)
+	updatedBytes, err := json.Marshal(updated)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling updated backup")
 	}
 
-	backupScheduleName := backup.GetLabels()["ark-schedule"]
-	c.metrics.SetBackupTarballSizeBytesGauge(backupScheduleName, backupSizeBytes)
+	patchBytes, err := jsonpatch.CreateMergePatch(origBytes, updatedBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating json merge patch for backup")
+	}
+
+	return patchBytes, nil
+}
+
+// patchLatestBackup patches the backup with the changes from original to
+// updated, like patchBackup, except that the patch is conditional on the
+// backup not having been modified since original was got. If it has been,
+// e.g. by another controller adding a label while the backup ran, the
+// latest version of the backup is got, the changes are reapplied to it,
+// and the patch is retried, up to retries times, so that neither the
+// controller's changes nor anyone else's are lost.
+func patchLatestBackup(original, updated *api.Backup, client arkv1client.BackupsGetter, retries int) (*api.Backup, error) {
+	changes, err := backupMergePatch(original, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := original
+	for attempt := 0; ; attempt++ {
+		patchBytes, err := conditionalBackupMergePatch(latest, changes)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := client.Backups(original.Namespace).Patch(original.Name, types.MergePatchType, patchBytes)
+		if err == nil {
+			return res, nil
+		}
+		if !apierrors.IsConflict(err) || attempt >= retries {
+			return nil, errors.Wrap(err, "error patching backup")
+		}
+
+		if latest, err = client.Backups(original.Namespace).Get(original.Name, metav1.GetOptions{}); err != nil {
+			return nil, errors.Wrap(err, "error getting latest backup")
+		}
+	}
+}
+
+// conditionalBackupMergePatch returns a JSON merge patch that applies
+// changes to latest, and that the API server rejects with a conflict if
+// the backup's been modified since latest was got.
+func conditionalBackupMergePatch(latest *api.Backup, changes []byte) ([]byte, error) {
+	latestBytes, err := json.Marshal(latest)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling latest backup")
+	}
+
+	updatedBytes, err := jsonpatch.MergePatch(latestBytes, changes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error applying changes to latest backup")
+	}
+
+	patchBytes, err := jsonpatch.CreateMergePatch(latestBytes, updatedBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating json merge patch for backup")
+	}
+
+	if latest.ResourceVersion == "" {
+		return patchBytes, nil
+	}
+
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(patchBytes, &patch); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling json merge patch for backup")
+	}
+	metadata, _ := patch["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+		patch["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = latest.ResourceVersion
+
+	return json.Marshal(patch)
+}
+
+// resolveResource resolves a resource, which may be a shortcut, to its
+// fully-qualified group-resource name. Resources that can't be resolved,
+// e.g. because the controller has no discovery helper, are returned as is.
+func (c *backupController) resolveResource(resource string) string {
+	if c.discoveryHelper == nil {
+		return resource
+	}
+
+	gvr, _, err := c.discoveryHelper.ResourceFor(schema.ParseGroupResource(resource).WithVersion(""))
+	if err != nil {
+		return resource
+	}
+
+	gr := gvr.GroupResource()
+	return gr.String()
+}
+
+// overlappingIncludesExcludesWarnings returns a warning for each item that's
+// both included and excluded, and so is excluded: because includes and
+// excludes resolve to the same item, because the includes are "*", or,
+// when globs is true, because a pattern in one list matches an item in the
+// other. kind names the items in the warnings. Items that are included and
+// excluded under the same name, and excludes of "*", fail validation
+// instead, so they aren't warned about.
+func overlappingIncludesExcludesWarnings(kind string, includes, excludes []string, resolve func(string) string, globs bool) []string {
+	var warnings []string
+	for _, excluded := range excludes {
+		if excluded == "*" {
+			continue
+		}
+
+		for _, included := range includes {
+			if included == excluded {
+				continue
+			}
+
+			var overlap string
+			switch {
+			case included == "*":
+				overlap = resolve(excluded)
+			case globs && globMatches(included, excluded):
+				overlap = excluded
+			case globs && globMatches(excluded, included):
+				overlap = included
+			case resolve(included) == resolve(excluded):
+				overlap = resolve(included)
+			default:
+				continue
+			}
+
+			warnings = append(warnings, fmt.Sprintf("%s %s is both included, as %s, and excluded, as %s, so it's excluded", kind, overlap, included, excluded))
+		}
+	}
+
+	return warnings
+}
+
+func globMatches(pattern, s string) bool {
+	matched, _ := path.Match(pattern, s)
+	return matched
+}
+
+func (c *backupController) getLocationAndValidate(itm *api.Backup, defaultBackupLocation string) (*api.BackupStorageLocation, []string) {
+	var validationErrors []string
+
+	for _, err := range collections.ValidateIncludesExcludes(itm.Spec.IncludedResources, itm.Spec.ExcludedResources) {
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid included/excluded resource lists: %v", err))
+	}
+
+	for _, err := range collections.ValidateIncludesExcludes(itm.Spec.IncludedNamespaces, itm.Spec.ExcludedNamespaces) {
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid included/excluded namespace lists: %v", err))
+	}
+
+	switch itm.Spec.NamespaceMatching {
+	case "", api.NamespaceMatchingExact:
+	case api.NamespaceMatchingGlob:
+		// build a new slice so appending can't write into the spec's
+		// included namespaces.
+		globs := make([]string, 0, len(itm.Spec.IncludedNamespaces)+len(itm.Spec.ExcludedNamespaces))
+		globs = append(globs, itm.Spec.IncludedNamespaces...)
+		globs = append(globs, itm.Spec.ExcludedNamespaces...)
+
+		for _, err := range collections.ValidateGlobs(globs) {
+			validationErrors = append(validationErrors, fmt.Sprintf("Invalid included/excluded namespace lists: %v", err))
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid namespace matching %q", itm.Spec.NamespaceMatching))
+	}
+
+	if !c.pvProviderExists && itm.Spec.SnapshotVolumes != nil && *itm.Spec.SnapshotVolumes {
+		validationErrors = append(validationErrors, "Server is not configured for PV snapshots")
+	}
+
+	if transferRequested(itm) && c.transferEndpoint == nil {
+		validationErrors = append(validationErrors, "Server is not configured with a transfer endpoint")
+	}
+
+	validationErrors = append(validationErrors, backup.ValidateBackupSet(itm.Labels[api.BackupSetLabel], itm.Labels[api.BackupSetOrderLabel])...)
+
+	if selector := itm.Spec.ExcludedLabelSelector; selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Invalid excluded label selector: %v", err))
+		}
+	}
+
+	if selector := itm.Spec.VolumeSnapshotExcludeSelector; selector != nil {
+		if itm.Spec.SnapshotVolumes != nil && !*itm.Spec.SnapshotVolumes {
+			validationErrors = append(validationErrors, "Volume snapshot exclude selector can't be set when snapshotVolumes is false")
+		}
+		if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Invalid volume snapshot exclude selector: %v", err))
+		}
+	}
+
+	var validationWarnings []string
+
+	// included resources that aren't served, e.g. because their CRDs
+	// aren't installed, don't fail the backup, since they may be installed
+	// later, but nothing would be backed up for them, so they're flagged.
+	if c.discoveryHelper != nil {
+		validationWarnings = append(validationWarnings, backup.UnservedResourceWarnings(c.discoveryHelper, itm.Spec.IncludedResources)...)
+	}
+
+	// items that are both included and excluded under different names, or
+	// by a wildcard or glob on one side, are excluded, which may not be
+	// what was meant, so they're flagged. The same name in both lists fails
+	// validation above.
+	validationWarnings = append(validationWarnings, overlappingIncludesExcludesWarnings("Resource", itm.Spec.IncludedResources, itm.Spec.ExcludedResources, c.resolveResource, false)...)
+	validationWarnings = append(validationWarnings, overlappingIncludesExcludesWarnings("Namespace", itm.Spec.IncludedNamespaces, itm.Spec.ExcludedNamespaces, func(ns string) string { return ns }, itm.Spec.NamespaceMatching == api.NamespaceMatchingGlob)...)
+
+	itm.Status.ValidationWarnings = validationWarnings
+
+	resources := collections.NewIncludesExcludes().Includes(itm.Spec.IncludedResources...).Excludes(itm.Spec.ExcludedResources...)
+	seenOrdered := make(map[string]bool)
+	for _, resource := range itm.Spec.OrderedResources {
+		if seenOrdered[resource] {
+			validationErrors = append(validationErrors, fmt.Sprintf("Ordered resource %s is listed more than once", resource))
+			continue
+		}
+		seenOrdered[resource] = true
+
+		if !resources.ShouldInclude(resource) {
+			validationErrors = append(validationErrors, fmt.Sprintf("Ordered resource %s is not included in the backup", resource))
+		}
+	}
+
+	if itm.Spec.MaxItemSizeBytes < 0 {
+		validationErrors = append(validationErrors, "Maximum item size must not be negative")
+	}
+
+	validationErrors = append(validationErrors, backup.ValidateItemTransforms(itm.Spec.ItemTransforms)...)
+
+	if level := itm.Annotations[api.LogLevelAnnotation]; level != "" {
+		if _, err := logrus.ParseLevel(level); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Invalid log level %q in annotation %s", level, api.LogLevelAnnotation))
+		}
+	}
+
+	validationErrors = append(validationErrors, validateBackupMetadata(itm.Spec.Metadata)...)
+	validationErrors = append(validationErrors, notify.ValidateWebhook(itm.Spec.NotifyWebhook)...)
+
+	switch itm.Spec.TerminatingNamespacePolicy {
+	case "", api.TerminatingNamespacePolicySkip, api.TerminatingNamespacePolicyInclude:
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid terminating namespace policy %q", itm.Spec.TerminatingNamespacePolicy))
+	}
+
+	locationErr := c.selectStorageLocation(itm, defaultBackupLocation)
+	if locationErr != nil {
+		validationErrors = append(validationErrors, fmt.Sprintf("Unable to select a backup storage location: %v", locationErr))
+	}
+
+	// add the storage location as a label for easy filtering later.
+	if itm.Labels == nil {
+		itm.Labels = make(map[string]string)
+	}
+	itm.Labels[api.StorageLocationLabel] = itm.Spec.StorageLocation
+
+	// label the backup with the cluster it was taken in, so that servers sharing
+	// its storage location can tell whether it's theirs.
+	if c.clusterName != "" {
+		itm.Labels[api.ClusterNameLabel] = c.clusterName
+	}
+
+	var backupLocation *api.BackupStorageLocation
+	if locationErr == nil {
+		var err error
+		if backupLocation, err = c.backupLocationLister.BackupStorageLocations(itm.Namespace).Get(itm.Spec.StorageLocation); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Error getting backup storage location: %v", err))
+		} else if backupLocation.Status.Phase == api.BackupStorageLocationPhaseUnavailable {
+			validationErrors = append(validationErrors, fmt.Sprintf("Backup storage location %s is unavailable", backupLocation.Name))
+		} else if backupLocation.Spec.ProbeBeforeBackup || (backupLocation.Status.Phase == "" && c.probeUnknownLocations) {
+			// a location whose availability hasn't been determined yet is
+			// probed, if the server is configured to, rather than assumed
+			// to be available.
+			if err := c.probeBackupLocation(backupLocation); err != nil {
+				validationErrors = append(validationErrors, fmt.Sprintf("Backup storage location %s failed its probe: %v", backupLocation.Name, err))
+			}
+		}
+	}
+
+	switch itm.Spec.UploadPolicy {
+	case "", api.UploadPolicyRequireAny, api.UploadPolicyRequireAll, api.UploadPolicyRequirePrimary:
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid upload policy %q", itm.Spec.UploadPolicy))
+	}
+
+	switch itm.Spec.PartialFailurePolicy {
+	case "", api.PartialFailurePolicyFail, api.PartialFailurePolicyContinue:
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid partial failure policy %q", itm.Spec.PartialFailurePolicy))
+	}
+
+	switch itm.Spec.OnListError {
+	case "", api.ListErrorPolicyFail, api.ListErrorPolicySkip:
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid list error policy %q", itm.Spec.OnListError))
+	}
+
+	if len(itm.Spec.EnabledPlugins) > 0 || len(itm.Spec.DisabledPlugins) > 0 {
+		validationErrors = append(validationErrors, c.validateItemActionNames(itm.Spec)...)
+	}
+
+	switch itm.Spec.ConsistencyMode {
+	case "", api.BackupConsistencyModeNone, api.BackupConsistencyModeResourceVersion:
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("Invalid consistency mode %q", itm.Spec.ConsistencyMode))
+	}
+
+	if itm.Spec.CredentialSecretRef != nil {
+		if _, err := c.credentials.GetCredentials(itm); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Invalid credential secret: %v", err))
+		}
+	}
+
+	if itm.Spec.BaseBackup != "" {
+		if err := c.validateBaseBackup(itm); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Invalid base backup: %v", err))
+		}
+	}
+
+	seen := map[string]bool{itm.Spec.StorageLocation: true}
+	for _, name := range itm.Spec.MirrorStorageLocations {
+		if seen[name] {
+			validationErrors = append(validationErrors, fmt.Sprintf("Storage location %s is listed more than once", name))
+			continue
+		}
+		seen[name] = true
+
+		if _, err := c.backupLocationLister.BackupStorageLocations(itm.Namespace).Get(name); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("Error getting mirror storage location %s: %v", name, err))
+		}
+	}
+
+	return backupLocation, validationErrors
+}
+
+// logLevelForBackup returns the level the backup requested in its
+// LogLevelAnnotation, or the server's backup log level if it didn't
+// request one.
+func (c *backupController) logLevelForBackup(backup *api.Backup) logrus.Level {
+	if level, err := logrus.ParseLevel(backup.Annotations[api.LogLevelAnnotation]); err == nil {
+		return level
+	}
+	return c.backupLogLevel
+}
+
+// newBackupLogger returns a logger for the backup's log, which writes to
+// out in the server's backup log format.
+func (c *backupController) newBackupLogger(backup *api.Backup, out io.Writer) *logrus.Logger {
+	logger := logging.DefaultLogger(c.logLevelForBackup(backup))
+	logging.SetFormat(logger, c.backupLogFormat)
+	logger.Out = out
+	return logger
+}
+
+// probeBackupLocation checks that the location's backup store can be
+// written to and read from.
+func (c *backupController) probeBackupLocation(location *api.BackupStorageLocation) error {
+	log := c.logger.WithField("backupLocation", location.Name)
+
+	pluginManager := c.newPluginManager(log)
+	defer pluginManager.CleanupClients()
+
+	backupStore, err := c.newBackupStore(location, pluginManager, log)
+	if err != nil {
+		return err
+	}
+
+	return backupStore.Probe()
+}
+
+// checkHookResults logs a summary of the hooks run during the backup. It
+// returns an error if any pre hooks failed and the backup is configured to
+// fail in that case.
+func checkHookResults(log logrus.FieldLogger, backup *api.Backup) error {
+	results := backup.Status.HookResults
+	if len(results) == 0 {
+		return nil
+	}
+
+	var failed, failedPre int
+	for _, result := range results {
+		if result.Succeeded {
+			continue
+		}
+		failed++
+		if result.Phase == "pre" {
+			failedPre++
+		}
+	}
+
+	log.Infof("%d of %d hooks failed", failed, len(results))
+
+	if failedPre > 0 && backup.Spec.Hooks.FailOnPreHookError {
+		return errors.Errorf("%d pre hook(s) failed, so the backup may not be consistent", failedPre)
+	}
+
+	return nil
+}
+
+// validateBaseBackup checks that the backup's base backup can be taken
+// incrementally from: it must be a completed, unexpired full backup in the
+// same storage location.
+func (c *backupController) validateBaseBackup(itm *api.Backup) error {
+	base, err := c.lister.Backups(itm.Namespace).Get(itm.Spec.BaseBackup)
+	if apierrors.IsNotFound(err) {
+		return errors.Errorf("backup %s not found", itm.Spec.BaseBackup)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error getting backup %s", itm.Spec.BaseBackup)
+	}
+
+	switch {
+	case base.Status.Phase != api.BackupPhaseCompleted:
+		return errors.Errorf("backup %s is %s, not %s", base.Name, base.Status.Phase, api.BackupPhaseCompleted)
+	case base.Spec.DryRun:
+		return errors.Errorf("backup %s is a dry run", base.Name)
+	case base.Spec.BaseBackup != "":
+		return errors.Errorf("backup %s is itself incremental", base.Name)
+	case !base.Status.Expiration.IsZero() && base.Status.Expiration.Time.Before(c.clock.Now()):
+		return errors.Errorf("backup %s expired at %v", base.Name, base.Status.Expiration.Time)
+	case base.Spec.StorageLocation != itm.Spec.StorageLocation:
+		return errors.Errorf("backup %s is in storage location %s, not %s", base.Name, base.Spec.StorageLocation, itm.Spec.StorageLocation)
+	}
+
+	return nil
+}
+
+// baseResourceVersions reads the resourceVersions of the items in the
+// named base backup's tarball from backupStore.
+func baseResourceVersions(backupStore persistence.BackupStore, name string) (archive.ResourceVersions, error) {
+	base, err := backupStore.GetBackupMetadata(name)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := backupStore.GetBackupContents(name)
+	if err != nil {
+		return nil, err
+	}
+	defer contents.Close()
+
+	untransformed, err := untransform(base, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := archive.ReadResourceVersions(untransformed, archive.CompressionAlgorithmForBackup(base))
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading base backup "+name)
+	}
+
+	return versions, nil
+}
+
+// selectStorageLocation chooses the backup's storage location, if its
+// spec doesn't set one, and records why the location was chosen in its
+// status. If the server has a --backup-location-label and the backup has
+// that label, the location with the same value for it is chosen; otherwise
+// defaultBackupLocation is. It's an error for more than one location to
+// match, since the backup could belong in either.
+func (c *backupController) selectStorageLocation(backup *api.Backup, defaultBackupLocation string) error {
+	if backup.Spec.StorageLocation != "" {
+		backup.Status.StorageLocationReason = api.StorageLocationReasonSpec
+		return nil
+	}
+
+	if value, ok := backup.Labels[c.locationLabel]; c.locationLabel != "" && ok {
+		set := labels.Set{c.locationLabel: value}
+
+		locations, err := c.backupLocationLister.BackupStorageLocations(backup.Namespace).List(labels.SelectorFromSet(set))
+		if err != nil {
+			return errors.Wrapf(err, "error listing backup storage locations labeled %s", set)
+		}
+
+		switch len(locations) {
+		case 0:
+			// fall back to the default location
+		case 1:
+			backup.Spec.StorageLocation = locations[0].Name
+			backup.Status.StorageLocationReason = api.StorageLocationReasonLabelMatch
+			backup.Status.StorageLocationMatchedLabel = set.String()
+			return nil
+		default:
+			var names []string
+			for _, location := range locations {
+				names = append(names, location.Name)
+			}
+			sort.Strings(names)
+			return errors.Errorf("backup storage locations %s are all labeled %s, so the backup must set its storage location", strings.Join(names, ", "), set)
+		}
+	}
+
+	backup.Spec.StorageLocation = defaultBackupLocation
+	backup.Status.StorageLocationReason = api.StorageLocationReasonDefault
+	return nil
+}
+
+// deleteStorageOnRemoval returns whether the backup's data should be
+// deleted from its storage locations when it's deleted. Nothing is
+// uploaded for dry runs, so there's nothing to delete.
+func (c *backupController) deleteStorageOnRemoval(backup *api.Backup) bool {
+	if backup.Spec.DryRun {
+		return false
+	}
+	if backup.Spec.DeleteStorageOnRemoval != nil {
+		return *backup.Spec.DeleteStorageOnRemoval
+	}
+	return c.deleteStorage
+}
+
+// timeoutForBackup returns how long collecting the backup's items may take,
+// or zero if there's no limit.
+func (c *backupController) timeoutForBackup(backup *api.Backup) time.Duration {
+	if backup.Spec.BackupTimeout.Duration > 0 {
+		return backup.Spec.BackupTimeout.Duration
+	}
+	return c.backupTimeout
+}
+
+func (c *backupController) runBackup(ctx context.Context, backup *api.Backup, backupLocation *api.BackupStorageLocation) (err error) {
+	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+	log.Info("Starting backup")
+	// the backup's duration is measured from started, by the clock's
+	// monotonic reading, rather than by subtracting its wall-clock
+	// timestamps, which are only recorded for display.
+	started := c.clock.Now()
+	backup.Status.StartTimestamp.Time = started
+
+	c.recordEvent(backup, corev1api.EventTypeNormal, "BackupStarted", "Started backup")
+	defer func() {
+		c.recordBackupResultEvent(backup, err)
+	}()
+
+	logFile, err := c.createTempFile(backup, "backup log")
+	if err != nil {
+		return err
+	}
+	newLogWriter := c.newLogWriter
+	if newLogWriter == nil {
+		newLogWriter = newGzipLogWriter
+	}
+	gzippedLogFile := newLogWriter(logFile)
+	// Assuming we successfully uploaded the log file, this will have already been closed below. It is safe to call
+	// close multiple times. If we get an error closing this, there's not really anything we can do about it.
+	defer gzippedLogFile.Close()
+	defer c.removeTempFile(backup, logFile, c.logger)
+
+	// Log the backup to both a backup log file and to stdout. This will help see what happened if the upload of the
+	// backup log failed for whatever reason.
+	// the log is also kept in memory while the backup runs, so that it can
+	// be followed before it's uploaded.
+	liveLog := NewLiveLog()
+	defer liveLog.Close()
+	c.backupTracker.SetLog(backup.Namespace, backup.Name, liveLog)
+
+	logger := c.newBackupLogger(backup, io.MultiWriter(os.Stdout, gzippedLogFile, liveLog))
+	log = logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+	backup.Status.LogFormat = string(c.backupLogFormat)
+
+	log.Info("Starting backup")
+
+	pluginManager := c.newPluginManager(log)
+	defer pluginManager.CleanupClients()
+
+	newBackupStore, removeCredentials, err := c.backupStoreFactory(backup)
+	if err != nil {
+		return err
+	}
+	defer removeCredentials()
+
+	backupStore, err := newBackupStore(backupLocation, pluginManager, log)
+	if err != nil {
+		return err
+	}
+
+	// An earlier attempt at the backup may have uploaded all of it before
+	// the server stopped, e.g. while the backup's final status was being
+	// updated, in which case it isn't run again.
+	if exists, err := backupStore.BackupExists(backup.Name); err != nil {
+		log.WithError(err).Warn("Unable to check whether the backup already exists in its storage location")
+	} else if exists {
+		return finalizeExistingBackup(log, backup, backupStore, backupLocation.Name)
+	}
+
+	targets := []uploadTarget{{location: backupLocation.Name, store: backupStore, objectLockRetention: backupLocation.Spec.ObjectLockRetention.Duration}}
+	for _, name := range backup.Spec.MirrorStorageLocations {
+		targets = append(targets, c.newUploadTarget(backup.Namespace, name, newBackupStore, pluginManager, log))
+	}
+
+	err = c.collectAndUploadBackup(ctx, log, backup, backupLocation, pluginManager, backupStore, targets, started)
+
+	// A dry run only shows what would have been backed up, which is
+	// recorded in its status, so nothing is uploaded.
+	if backup.Spec.DryRun {
+		return err
+	}
+
+	// The log is uploaded whether or not the rest of the backup was, since
+	// it's what's needed to debug a failed backup.
+	// A log that couldn't be written in full is still uploaded, since
+	// whatever it has may help, but the backup records that it may be
+	// truncated rather than failing.
+	if err := gzippedLogFile.Close(); err != nil {
+		c.logger.WithError(err).WithField("backup", kubeutil.NamespaceAndName(backup)).Error("Error closing backup log, it may be truncated")
+		backup.Status.LogTruncated = true
+	} else if err := validateGzipFile(logFile); err != nil {
+		c.logger.WithError(err).WithField("backup", kubeutil.NamespaceAndName(backup)).Error("Backup log is invalid, it may be truncated")
+		backup.Status.LogTruncated = true
+	}
+	c.uploadBackupLog(backup, targets, logFile)
+
+	return err
+}
+
+// collectAndUploadBackup collects the backup's items into its tarball and
+// uploads it, with its metadata, to targets. The backup's log isn't
+// uploaded, so that runBackup can upload it however this fails.
+func (c *backupController) collectAndUploadBackup(
+	ctx context.Context,
+	log logrus.FieldLogger,
+	backup *api.Backup,
+	backupLocation *api.BackupStorageLocation,
+	pluginManager plugin.Manager,
+	backupStore persistence.BackupStore,
+	targets []uploadTarget,
+	started time.Time,
+) error {
+	// a streamed backup's tarball is uploaded as it's written, so it isn't
+	// staged in a temp file.
+	streaming := c.streamsUpload(backup, backupLocation)
+
+	var backupFile *os.File
+	if !streaming {
+		var err error
+		if backupFile, err = c.createTempFile(backup, "backup"); err != nil {
+			return err
+		}
+		defer c.removeTempFile(backup, backupFile, log)
+	}
+
+	actions, err := pluginManager.GetBackupItemActions()
+	if err != nil {
+		return err
+	}
+	actions = enabledItemActions(backup.Spec, actions)
+
+	// record which plugins produced the backup, to help debug
+	// incompatibilities when it's restored after an upgrade.
+	pluginVersions := pluginManager.GetPluginVersions()
+	if err := setPluginVersions(backup, pluginVersions); err != nil {
+		log.WithError(err).Warn("Unable to record plugin versions")
+	}
+	backup.Status.BackupItemActionVersions = backupItemActionVersions(pluginVersions)
+	for name := range backup.Status.BackupItemActionVersions {
+		if !itemActionEnabled(backup.Spec, name) {
+			delete(backup.Status.BackupItemActionVersions, name)
+		}
+	}
+
+	// record that the upload ends with a completion marker, so that the
+	// backup is treated as incomplete if the upload is interrupted.
+	if backup.Annotations == nil {
+		backup.Annotations = make(map[string]string)
+	}
+	backup.Annotations[api.CompletionMarkerAnnotation] = "true"
+
+	if len(c.transforms) > 0 {
+		backup.Annotations[api.TransformsAnnotation] = c.transforms.String()
+	}
+	backup.Annotations[api.CompressionAnnotation] = string(c.compression.Algorithm)
+
+	var baseVersions archive.ResourceVersions
+	if backup.Spec.BaseBackup != "" {
+		if baseVersions, err = baseResourceVersions(backupStore, backup.Spec.BaseBackup); err != nil {
+			return err
+		}
+		backup.Annotations[api.BaseBackupAnnotation] = backup.Spec.BaseBackup
+	}
+
+	var errs []error
+
+	var backupJSONToUpload []byte
+	var backupFileToUpload *os.File
+
+	var (
+		backupOutput io.Writer = backupFile
+		stream       *backupStream
+	)
+	if streaming {
+		log.Info("Streaming backup tarball to backup storage location")
+		stream = newBackupStream(backupStore, backup.Name)
+		backupOutput = stream
+	}
+
+	// Do the actual backup
+	limitedBackupFile := newSizeLimitWriter(backupOutput, c.maxBackupSizeBytes)
+	// the tarball is hashed as it's written, so that it doesn't have to be
+	// read again to record its checksum.
+	tarballHash := sha256.New()
+	// the manifest is written as the tarball is, so that it lists what a
+	// backup that fails partway through captured.
+	var (
+		manifest       *bytes.Buffer
+		manifestOutput io.Writer
+	)
+	if backup.Spec.IntegrityManifest {
+		manifest = new(bytes.Buffer)
+		manifestOutput = manifest
+	}
+	actions, actionWarnings := c.timeItemActions(actions)
+	warnings, backupErr := c.backupWithContext(ctx, log, backup, io.MultiWriter(limitedBackupFile, tarballHash), actions, baseVersions, manifestOutput)
+	warnings = append(warnings, actionWarnings()...)
+
+	// warnings are logged and counted, but don't fail the backup.
+	for _, warning := range warnings {
+		log.Warn(warning)
+	}
+	backup.Status.Warnings = len(warnings)
+
+	timedOut := backupErr == context.DeadlineExceeded
+	cancelled := backupErr == context.Canceled
+	// a backup that timed out or was cancelled may still be writing, so
+	// its size isn't checked.
+	aborted := timedOut || cancelled || limitedBackupFile.exceeded
+
+	// an aborted backup's streamed upload is abandoned, so that its
+	// incomplete tarball isn't kept.
+	var streamErr error
+	if stream != nil {
+		streamErr = stream.finish(aborted)
+	}
+
+	if cancelled && atomic.LoadInt32(&c.abortingBackups) != 0 {
+		backup.Status.FailureReason = "backup was aborted because the Ark server shut down before it finished"
+		errs = append(errs, errors.New(backup.Status.FailureReason))
+
+		backup.Status.Phase = api.BackupPhaseFailed
+	} else if cancelled {
+		backup.Status.FailureReason = "backup was cancelled"
+
+		backup.Status.Phase = api.BackupPhaseCancelled
+	} else if timedOut {
+		backup.Status.FailureReason = fmt.Sprintf("backup timed out after %v", c.timeoutForBackup(backup))
+		errs = append(errs, errors.New(backup.Status.FailureReason))
+
+		backup.Status.Phase = api.BackupPhaseFailed
+	} else if aborted {
+		// the backupper's own error is just the failed write, so report why
+		// the write failed instead.
+		backup.Status.FailureReason = fmt.Sprintf("backup was aborted because its tarball exceeded the maximum size of %d bytes", c.maxBackupSizeBytes)
+		errs = append(errs, errors.New(backup.Status.FailureReason))
+
+		backup.Status.Phase = api.BackupPhaseFailed
+	} else if streamErr != nil {
+		backup.Status.FailureReason = fmt.Sprintf("error uploading backup tarball: %v", streamErr)
+		errs = append(errs, errors.New(backup.Status.FailureReason))
+
+		backup.Status.Phase = api.BackupPhaseFailed
+	} else if backupErr != nil {
+		errs = append(errs, backupErr)
+
+		backup.Status.Phase = api.BackupPhaseFailed
+	} else if err := checkHookResults(log, backup); err != nil {
+		errs = append(errs, err)
+
+		backup.Status.Phase = api.BackupPhaseFailed
+	} else if len(backup.Status.PartialFailures) > 0 {
+		backup.Status.Phase = api.BackupPhasePartiallyFailed
+	} else if reason := c.emptyBackupReason(backup, limitedBackupFile.written); reason != "" {
+		log.Warnf("Backup is empty: %s; check its included and excluded resources and namespaces", reason)
+
+		backup.Status.Phase = api.BackupPhaseCompletedEmpty
+	} else {
+		backup.Status.Phase = api.BackupPhaseCompleted
+	}
+
+	// an aborted backup may still be writing its manifest, and its tarball
+	// isn't kept anyway.
+	var manifestToUpload []byte
+	if !aborted {
+		backup.Status.TarballChecksum = hex.EncodeToString(tarballHash.Sum(nil))
+
+		if manifest != nil {
+			manifestToUpload = manifest.Bytes()
+		}
+	}
+
+	var contentIndexToUpload []byte
+	if c.contentIndex && (backup.Status.Phase == api.BackupPhaseCompleted || backup.Status.Phase == api.BackupPhaseCompletedEmpty || backup.Status.Phase == api.BackupPhasePartiallyFailed) && !backup.Spec.DryRun {
+		// the index is only a convenience, so failing to build it
+		// doesn't fail the backup.
+		if index, err := buildContentIndex(backup, backupFile); err != nil {
+			log.WithError(err).Warn("Unable to build content index")
+		} else {
+			backup.Annotations[api.ContentIndexVersionAnnotation] = strconv.Itoa(archive.ContentIndexVersion)
+			contentIndexToUpload = index.Bytes()
+		}
+	}
+
+	// Mark completion timestamp before serializing and uploading.
+	// Otherwise, the JSON file in object storage has a CompletionTimestamp of 'null'.
+	backup.Status.CompletionTimestamp.Time = c.clock.Now()
+	backup.Status.ObjectLockExpiration.Time = objectLockExpiration(backup.Status.CompletionTimestamp.Time, targets)
+
+	backupJSON := new(bytes.Buffer)
+	encodeBackup := c.encodeBackup
+	if encodeBackup == nil {
+		encodeBackup = encodeBackupJSON
+	}
+	if err := encodeBackup(backup, backupJSON); err != nil {
+		errs = append(errs, errors.Wrap(err, "error encoding backup"))
+	} else {
+		// Only upload the json and backup tarball if encoding to json succeeded.
+		backupJSONToUpload = backupJSON.Bytes()
+		backupFileToUpload = backupFile
+
+		// the backup store recognizes gzipped metadata by its header and
+		// stores it under its own key, so it's read back decompressed.
+		if c.compressMetadata {
+			if compressed, err := gzipBytes(backupJSONToUpload); err != nil {
+				log.WithError(err).Warn("Unable to compress backup metadata, uploading it uncompressed")
+			} else {
+				backupJSONToUpload = compressed
+			}
+		}
+	}
+
+	var backupSizeBytes int64
+	if streaming {
+		backupSizeBytes = limitedBackupFile.written
+	} else if backupFileStat, err := backupFile.Stat(); err != nil {
+		errs = append(errs, errors.Wrap(err, "error getting file info"))
+	} else {
+		backupSizeBytes = backupFileStat.Size()
+	}
+
+	// A dry run uploads nothing.
+	if backup.Spec.DryRun {
+		log.Infof("Dry run completed; %d bytes would have been uploaded", backupSizeBytes)
+		return kerrors.NewAggregate(errs)
+	}
+
+	// An aborted backup's tarball is incomplete, and one whose tarball
+	// couldn't be streamed has none, so only their logs are kept.
+	if aborted || streamErr != nil {
+		backupJSONToUpload, backupFileToUpload, contentIndexToUpload, manifestToUpload = nil, nil, nil, nil
+	}
+
+	// If the backup is being streamed straight to a restore, only its log
+	// is kept in object storage. Only a whole tarball is sent, since the
+	// restore would otherwise restore whatever part of it was written.
+	if transferRequested(backup) {
+		if backupJSONToUpload != nil && transferable(backup) {
+			log.Info("Sending backup to transfer endpoint")
+
+			if _, err := backupFile.Seek(0, 0); err != nil {
+				errs = append(errs, errors.Wrap(err, "error seeking backup file"))
+			} else if err := c.transferEndpoint.Send(backup, backupFile); err != nil {
+				errs = append(errs, err)
+			}
+		} else {
+			log.Infof("Not sending backup to transfer endpoint because its phase is %s", backup.Status.Phase)
+		}
+
+		backupJSONToUpload, backupFileToUpload, contentIndexToUpload, manifestToUpload = nil, nil, nil, nil
+	}
+
+	// time the upload separately from collecting the backup's items, so
+	// that it's clear which of them a slow backup is spending its time on.
+	uploadStart := c.clock.Now()
+	uploadErr := c.uploadBackup(backup, targets, backupJSONToUpload, backupFileToUpload, contentIndexToUpload, manifestToUpload)
+	uploadDuration := c.elapsedSince(log, uploadStart, "upload")
+	if uploadErr != nil {
+		errs = append(errs, uploadErr)
+	}
+	if backupJSONToUpload != nil {
+		c.uploadBackupSummary(backup, targets)
+	}
+
+	backupScheduleName := backup.GetLabels()["ark-schedule"]
+
+	// the tarball's streamed upload isn't part of uploadBackup, so its
+	// failure is recorded separately.
+	if streamErr != nil {
+		status := backup.Status.LocationStatuses[backupLocation.Name]
+		status.Phase = api.UploadPhaseFailed
+		status.Error = streamErr.Error()
+		backup.Status.LocationStatuses[backupLocation.Name] = status
+		c.metrics.RegisterBackupUploadFailed(backupScheduleName, backupLocation.Name)
+	}
+	c.metrics.SetBackupTarballSizeBytesGauge(backupScheduleName, backupSizeBytes, aborted)
+	if !aborted {
+		c.setBackupSizeByGroupGauges(backupScheduleName, backup)
+	}
+	c.metrics.RegisterBackupSkippedLargeItems(backupScheduleName, len(backup.Status.SkippedLargeItems))
+	c.metrics.RegisterBackupWarning(backupScheduleName, backup.Status.Warnings)
+
+	backupDuration := c.elapsedSince(log, started, "backup")
+	backupDurationSeconds := float64(backupDuration / time.Second)
+	c.metrics.RegisterBackupDuration(backupScheduleName, backupDurationSeconds)
+
+	c.recordBackupEvent(backup, backupDuration, uploadDuration, uploadErr)
+
+	log.Info("Backup completed")
+
+	return kerrors.NewAggregate(errs)
+}
+
+// setBackupSizeByGroupGauges records the size of each resource's items in
+// backup, as recorded in its progress by the backupper.
+func (c *backupController) setBackupSizeByGroupGauges(backupScheduleName string, backup *api.Backup) {
+	if backup.Status.Progress == nil {
+		return
+	}
+
+	for group, size := range backup.Status.Progress.ResourceSizes {
+		c.metrics.SetBackupSizeByGroupGauge(backupScheduleName, group, size)
+	}
+}
+
+// gzipBytes returns data, gzipped.
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	if _, err := gzw.Write(data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// elapsedSince returns how long it's been since start by c's clock. The
+// real clock's times carry a monotonic reading, which it's measured with,
+// so that it isn't thrown off by the wall clock being set back, e.g. by an
+// NTP correction. Clocks without one can still go backwards, in which case
+// a warning is logged and zero is returned rather than a negative
+// duration.
+func (c *backupController) elapsedSince(log logrus.FieldLogger, start time.Time, what string) time.Duration {
+	elapsed := c.clock.Since(start)
+	if elapsed < 0 {
+		log.WithField("elapsed", elapsed).Warnf("Clock went backwards while timing the %s, recording its duration as zero", what)
+		return 0
+	}
+	return elapsed
+}
+
+// recordBackupEvent adds an event to the backup summarizing how long it took
+// to collect its items and to upload it.
+func (c *backupController) recordBackupEvent(backup *api.Backup, collectDuration, uploadDuration time.Duration, uploadErr error) {
+	if c.eventClient == nil {
+		return
+	}
+
+	collectDuration, uploadDuration = collectDuration.Round(time.Millisecond), uploadDuration.Round(time.Millisecond)
+
+	eventType, reason := corev1api.EventTypeNormal, "BackupUploaded"
+	message := fmt.Sprintf("Collected backup items in %v and uploaded the backup in %v", collectDuration, uploadDuration)
+	if uploadErr != nil {
+		eventType, reason = corev1api.EventTypeWarning, "BackupUploadFailed"
+		message = fmt.Sprintf("Collected backup items in %v, but failed to upload the backup after %v: %v", collectDuration, uploadDuration, uploadErr)
+	}
+
+	c.recordEvent(backup, eventType, reason, message)
+}
+
+// recordBackupResultEvent adds an event to the backup recording how it
+// finished. err is the error runBackup returned, which fails the backup.
+func (c *backupController) recordBackupResultEvent(backup *api.Backup, err error) {
+	switch {
+	case err != nil:
+		c.recordEvent(backup, corev1api.EventTypeWarning, "BackupFailed", truncateEventMessage(fmt.Sprintf("Backup failed: %v", err)))
+	case backup.Status.Phase == api.BackupPhaseCancelled:
+		c.recordEvent(backup, corev1api.EventTypeNormal, "BackupCancelled", "Backup was cancelled")
+	case backup.Status.Phase == api.BackupPhasePartiallyFailed:
+		c.recordEvent(backup, corev1api.EventTypeWarning, "BackupPartiallyFailed", fmt.Sprintf("Backup completed, but backing up %d namespaces failed", len(backup.Status.PartialFailures)))
+	case backup.Status.Phase == api.BackupPhaseCompletedEmpty:
+		c.recordEvent(backup, corev1api.EventTypeWarning, "BackupCompletedEmpty", "Backup completed, but it's empty; check its included and excluded resources and namespaces")
+	case backup.Status.Warnings > 0:
+		c.recordEvent(backup, corev1api.EventTypeNormal, "BackupCompleted", fmt.Sprintf("Backup completed with %d warnings", backup.Status.Warnings))
+	default:
+		c.recordEvent(backup, corev1api.EventTypeNormal, "BackupCompleted", "Backup completed")
+	}
+}
 
-	backupDuration := backup.Status.CompletionTimestamp.Time.Sub(backup.Status.StartTimestamp.Time)
-	backupDurationSeconds := float64(backupDuration / time.Second)
-	c.metrics.RegisterBackupDuration(backupScheduleName, backupDurationSeconds)
+// emptyBackupReason returns why a backup that ran without errors is
+// empty, or "" if it isn't: it captured no items, or its tarball, of
+// tarballSize bytes, is smaller than the server's minimum backup size.
+// Backupers that don't report their progress aren't checked for items,
+// and dry runs, which don't write a tarball, aren't checked for size.
+func (c *backupController) emptyBackupReason(backup *api.Backup, tarballSize int64) string {
+	if progress := backup.Status.Progress; progress != nil && progress.ItemsBackedUp == 0 {
+		return "it captured no items"
+	}
+	if c.minBackupSizeBytes > 0 && !backup.Spec.DryRun && tarballSize < c.minBackupSizeBytes {
+		return fmt.Sprintf("its tarball is %d bytes, less than the minimum backup size of %d bytes", tarballSize, c.minBackupSizeBytes)
+	}
+	return ""
+}
 
-	log.Info("Backup completed")
+// truncateEventMessage shortens message, e.g. a long list of errors, to
+// maxEventMessageLength.
+func truncateEventMessage(message string) string {
+	if len(message) <= maxEventMessageLength {
+		return message
+	}
+	return message[:maxEventMessageLength-3] + "..."
+}
 
-	return kerrors.NewAggregate(errs)
+// recordEvent adds an event to the backup, unless too many have been
+// recorded about it recently.
+func (c *backupController) recordEvent(backup *api.Backup, eventType, reason, message string) {
+	if c.eventClient == nil {
+		return
+	}
+
+	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+
+	if c.eventLimiter != nil && !c.eventLimiter.allow(kubeutil.NamespaceAndName(backup), c.clock.Now()) {
+		log.WithField("reason", reason).Debug("Not recording backup event because too many have been recorded recently")
+		return
+	}
+
+	now := metav1.NewTime(c.clock.Now())
+	event := &corev1api.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: backup.Namespace,
+			Name:      fmt.Sprintf("%s.%x", backup.Name, now.UnixNano()),
+		},
+		InvolvedObject: corev1api.ObjectReference{
+			APIVersion:      api.SchemeGroupVersion.String(),
+			Kind:            "Backup",
+			Namespace:       backup.Namespace,
+			Name:            backup.Name,
+			UID:             backup.UID,
+			ResourceVersion: backup.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1api.EventSource{Component: "ark-backup-controller"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if _, err := c.eventClient.Events(backup.Namespace).Create(event); err != nil {
+		log.WithError(err).Warn("Error recording backup event")
+	}
+}
+
+// backupResult is the outcome of a call to the backupper.
+type backupResult struct {
+	warnings []string
+	err      error
+}
+
+// backupWithContext runs transformedBackup, giving up on it if ctx is done
+// first. The backupper works on a copy of arkBackup, which is only copied
+// back if it finishes, and its writes to backupFile fail once ctx is done,
+// so that a backup that's given up on stops at its next write.
+func (c *backupController) backupWithContext(ctx context.Context, log logrus.FieldLogger, arkBackup *api.Backup, backupFile io.Writer, actions []backup.ItemAction, baseVersions archive.ResourceVersions, manifest io.Writer) ([]string, error) {
+	inProgress := arkBackup.DeepCopy()
+
+	done := make(chan backupResult, 1)
+	go func() {
+		warnings, err := c.transformedBackup(log, inProgress, &contextWriter{ctx: ctx, w: backupFile}, actions, baseVersions, manifest)
+		done <- backupResult{warnings: warnings, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		inProgress.DeepCopyInto(arkBackup)
+		return res.warnings, res.err
+	case <-ctx.Done():
+		if ctx.Err() == context.Canceled {
+			log.Info("Backup was cancelled")
+		} else {
+			log.Error("Backup timed out")
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// contextWriter writes to w until ctx is done.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// transformedBackup runs the backup, compressing its tarball and passing it
+// through the controller's transform pipeline on the way to backupFile.
+func (c *backupController) transformedBackup(log logrus.FieldLogger, arkBackup *api.Backup, backupFile io.Writer, actions []backup.ItemAction, baseVersions archive.ResourceVersions, manifest io.Writer) ([]string, error) {
+	w, err := c.transforms.Wrap(backupFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cw, err := c.compression.NewWriter(w)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	warnings, err := c.backupper.Backup(log, arkBackup, cw, actions, baseVersions, manifest)
+	if err != nil {
+		cw.Close()
+		w.Close()
+		return warnings, err
+	}
+
+	if err := cw.Close(); err != nil {
+		w.Close()
+		return warnings, errors.Wrap(err, "error closing compressed tarball")
+	}
+
+	return warnings, errors.Wrap(w.Close(), "error closing transform pipeline")
+}
+
+// sizeLimitWriter writes to w until limit bytes have been written, after
+// which it writes no more and returns errBackupSizeLimitExceeded. A limit
+// of zero means there's no limit.
+type sizeLimitWriter struct {
+	w        io.Writer
+	limit    int64
+	written  int64
+	exceeded bool
+}
+
+var errBackupSizeLimitExceeded = errors.New("backup size limit exceeded")
+
+func newSizeLimitWriter(w io.Writer, limit int64) *sizeLimitWriter {
+	return &sizeLimitWriter{w: w, limit: limit}
+}
+
+func (w *sizeLimitWriter) Write(p []byte) (int, error) {
+	if w.limit <= 0 {
+		n, err := w.w.Write(p)
+		w.written += int64(n)
+		return n, err
+	}
+
+	if w.written+int64(len(p)) > w.limit {
+		w.exceeded = true
+		p = p[:w.limit-w.written]
+	}
+
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if w.exceeded {
+		return n, errBackupSizeLimitExceeded
+	}
+
+	return n, nil
+}
+
+// streamsUpload returns whether the backup's tarball is streamed to
+// location as it's written, rather than staged in a temp file and uploaded
+// afterwards. A streamed tarball can only be read once, so it's only
+// streamed if it isn't needed again: the backup isn't a dry run or sent to
+// a transfer endpoint, has no mirror locations, and no content index is
+// built from it. Streaming also needs an object store that can upload a
+// body without knowing its length.
+func (c *backupController) streamsUpload(backup *api.Backup, location *api.BackupStorageLocation) bool {
+	return c.streamUploads &&
+		!backup.Spec.DryRun &&
+		!transferRequested(backup) &&
+		len(backup.Spec.MirrorStorageLocations) == 0 &&
+		!c.contentIndex &&
+		persistence.SupportsStreamingUploads(location)
+}
+
+// transferRequested returns whether obj, a backup or restore, is annotated
+// to be streamed through the server's transfer endpoint.
+func transferRequested(obj metav1.Object) bool {
+	return obj.GetAnnotations()[api.TransferAnnotation] == "true"
+}
+
+// transferable returns whether backup's tarball is whole, so that it can be
+// sent to a restore through the transfer endpoint: the backup completed, or
+// partially failed under a policy that allows that.
+func transferable(backup *api.Backup) bool {
+	switch backup.Status.Phase {
+	case api.BackupPhaseCompleted, api.BackupPhaseCompletedEmpty:
+		return true
+	case api.BackupPhasePartiallyFailed:
+		return backup.Spec.PartialFailurePolicy == api.PartialFailurePolicyContinue
+	default:
+		return false
+	}
+}
+
+// backupStream uploads a backup's tarball to a backup store as it's
+// written to it.
+type backupStream struct {
+	w    *io.PipeWriter
+	done chan error
+}
+
+var errBackupStreamAborted = errors.New("backup was aborted")
+
+func newBackupStream(store persistence.BackupStore, name string) *backupStream {
+	r, w := io.Pipe()
+	s := &backupStream{
+		w:    w,
+		done: make(chan error, 1),
+	}
+
+	go func() {
+		err := store.PutBackupContents(name, r)
+		// if the upload failed before the whole tarball was read, writes
+		// to the stream fail rather than blocking.
+		if err != nil {
+			r.CloseWithError(err)
+		} else {
+			r.Close()
+		}
+		s.done <- err
+	}()
+
+	return s
+}
+
+func (s *backupStream) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// finish ends the tarball and waits for its upload to finish, returning
+// its error. If abort is true, the upload is made to fail instead, so the
+// incomplete tarball isn't kept.
+func (s *backupStream) finish(abort bool) error {
+	if abort {
+		s.w.CloseWithError(errBackupStreamAborted)
+	} else {
+		s.w.Close()
+	}
+
+	err := <-s.done
+	if abort {
+		return nil
+	}
+	return err
+}
+
+// buildContentIndex reads back the backup's tarball from backupFile and
+// returns its content index.
+func buildContentIndex(backup *api.Backup, backupFile io.ReadSeeker) (*bytes.Buffer, error) {
+	if _, err := backupFile.Seek(0, 0); err != nil {
+		return nil, errors.Wrap(err, "error seeking backup file")
+	}
+
+	contents, err := untransform(backup, backupFile)
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(bytes.Buffer)
+	if err := archive.WriteContentIndex(contents, archive.CompressionAlgorithmForBackup(backup), index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// setPluginVersions stores the given plugin versions in the backup's
+// PluginVersionsAnnotation.
+func setPluginVersions(backup *api.Backup, versions map[string]string) error {
+	data, err := json.Marshal(versions)
+	if err != nil {
+		return errors.Wrap(err, "error marshalling plugin versions")
+	}
+
+	if backup.Annotations == nil {
+		backup.Annotations = make(map[string]string)
+	}
+	backup.Annotations[api.PluginVersionsAnnotation] = string(data)
+
+	return nil
+}
+
+// backupItemActionVersions returns the versions of the BackupItemAction
+// plugins in versions, which are keyed by "<kind>/<name>", keyed by plugin
+// name. Empty versions are returned as "unknown".
+func backupItemActionVersions(versions map[string]string) map[string]string {
+	prefix := string(plugin.PluginKindBackupItemAction) + "/"
+
+	var res map[string]string
+	for id, version := range versions {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+
+		if version == "" {
+			version = "unknown"
+		}
+		if res == nil {
+			res = make(map[string]string)
+		}
+		res[strings.TrimPrefix(id, prefix)] = version
+	}
+
+	return res
+}
+
+// itemActionEnabled returns whether the backup item action plugin name is
+// run during a backup with spec: if it's in the spec's EnabledPlugins, or
+// they're empty, and it isn't in its DisabledPlugins.
+func itemActionEnabled(spec api.BackupSpec, name string) bool {
+	if len(spec.EnabledPlugins) > 0 && !stringslice.Has(spec.EnabledPlugins, name) {
+		return false
+	}
+	return !stringslice.Has(spec.DisabledPlugins, name)
+}
+
+// enabledItemActions returns the backup item actions that are run during
+// a backup with spec.
+func enabledItemActions(spec api.BackupSpec, actions []backup.ItemAction) []backup.ItemAction {
+	var enabled []backup.ItemAction
+	for _, action := range actions {
+		if itemActionEnabled(spec, itemActionName(action)) {
+			enabled = append(enabled, action)
+		}
+	}
+	return enabled
+}
+
+// validateItemActionNames returns an error for each of the plugins named in
+// the spec's EnabledPlugins and DisabledPlugins that isn't a registered
+// backup item action.
+func (c *backupController) validateItemActionNames(spec api.BackupSpec) []string {
+	pluginManager := c.newPluginManager(c.logger)
+	defer pluginManager.CleanupClients()
+
+	registered := backupItemActionVersions(pluginManager.GetPluginVersions())
+
+	var errs []string
+	for _, name := range spec.EnabledPlugins {
+		if _, found := registered[name]; !found {
+			errs = append(errs, fmt.Sprintf("Invalid enabled plugin %q: no backup item action plugin is registered with that name", name))
+		}
+	}
+	for _, name := range spec.DisabledPlugins {
+		if _, found := registered[name]; !found {
+			errs = append(errs, fmt.Sprintf("Invalid disabled plugin %q: no backup item action plugin is registered with that name", name))
+		}
+	}
+	return errs
+}
+
+// timeItemActions wraps the backup item actions so that each of their
+// executions is recorded in the server's metrics and, if the server has a
+// backup item action timeout, is abandoned once it times out, leaving the
+// item as it was. The returned function returns a warning for each action
+// that timed out, once the backup is done.
+func (c *backupController) timeItemActions(actions []backup.ItemAction) ([]backup.ItemAction, func() []string) {
+	var (
+		lock     sync.Mutex
+		timeouts = make(map[string]int)
+	)
+
+	observe := func(action string, duration time.Duration, err error) {
+		c.metrics.RegisterBackupItemActionDuration(action, duration.Seconds())
+		if err == nil {
+			return
+		}
+
+		_, timedOut := err.(*backup.ItemActionTimeoutError)
+		c.metrics.RegisterBackupItemActionFailed(action, timedOut)
+		if timedOut {
+			lock.Lock()
+			timeouts[action]++
+			lock.Unlock()
+		}
+	}
+
+	timed := make([]backup.ItemAction, 0, len(actions))
+	for _, action := range actions {
+		timed = append(timed, backup.NewTimedItemAction(action, itemActionName(action), c.itemActionTimeout, observe))
+	}
+
+	warnings := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+
+		var res []string
+		for _, action := range sets.StringKeySet(timeouts).List() {
+			res = append(res, fmt.Sprintf("Backup item action %s timed out after %v on %d items, which were backed up without its changes", action, c.itemActionTimeout, timeouts[action]))
+		}
+		return res
+	}
+
+	return timed, warnings
+}
+
+// itemActionName returns the name a backup item action's plugin is
+// registered under, or its type if it isn't a plugin.
+func itemActionName(action backup.ItemAction) string {
+	if named, ok := action.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", action)
+}
+
+// newBackupStoreFunc gets a BackupStore for a storage location.
+type newBackupStoreFunc func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)
+
+// backupStoreFactory returns the function to get the backup's stores with.
+// If the backup references a credential secret, its credentials are
+// written to a temp file that the stores' object stores are pointed at,
+// and the returned func removes it.
+func (c *backupController) backupStoreFactory(backup *api.Backup) (newBackupStoreFunc, func(), error) {
+	if backup.Spec.CredentialSecretRef == nil {
+		return c.newBackupStore, func() {}, nil
+	}
+
+	credentials, err := c.credentials.GetCredentials(backup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credentialsFile, err := c.createTempFile(backup, "backup credentials")
+	if err != nil {
+		return nil, nil, err
+	}
+	removeCredentials := func() { closeAndRemoveFile(credentialsFile, c.logger) }
+
+	if _, err := credentialsFile.Write(credentials); err != nil {
+		removeCredentials()
+		return nil, nil, errors.Wrap(err, "error writing backup credentials")
+	}
+
+	newBackupStore := func(location *api.BackupStorageLocation, objectStoreGetter persistence.ObjectStoreGetter, log logrus.FieldLogger) (persistence.BackupStore, error) {
+		return c.newBackupStore(persistence.WithCredentialsFile(location, credentialsFile.Name()), objectStoreGetter, log)
+	}
+
+	return newBackupStore, removeCredentials, nil
+}
+
+// createTempFile creates a temp file in the controller's backup temp dir,
+// or the OS default temp dir if it's not set, to stage the backup's
+// purpose in. If the file can't be created, the backup's failure reason
+// says why.
+func (c *backupController) createTempFile(backup *api.Backup, purpose string) (*os.File, error) {
+	file, err := ioutil.TempFile(c.backupTempDir, "ark-")
+	if err != nil {
+		backup.Status.FailureReason = fmt.Sprintf("error creating temp file for %s: %v", purpose, err)
+		return nil, errors.Wrapf(err, "error creating temp file for %s", purpose)
+	}
+	return file, nil
+}
+
+// removeTempFile closes and removes one of backup's temp files, unless
+// the controller keeps them, in which case it's only closed, and its path
+// is recorded in backup's status.
+func (c *backupController) removeTempFile(backup *api.Backup, file *os.File, log logrus.FieldLogger) {
+	if !c.keepTempFiles {
+		closeAndRemoveFile(file, log)
+		return
+	}
+
+	if err := file.Close(); err != nil {
+		log.WithError(err).WithField("file", file.Name()).Error("error closing file")
+	}
+	log.WithField("file", file.Name()).Info("Keeping backup temp file for debugging")
+	backup.Status.KeptTempFiles = append(backup.Status.KeptTempFiles, file.Name())
 }
 
 func closeAndRemoveFile(file *os.File, log logrus.FieldLogger) {
@@ -384,3 +2565,340 @@ func closeAndRemoveFile(file *os.File, log logrus.FieldLogger) {
 		log.WithError(err).WithField("file", file.Name()).Error("error removing file")
 	}
 }
+
+// uploadTarget is a storage location to upload a backup to.
+type uploadTarget struct {
+	location string
+	store    persistence.BackupStore
+	// objectLockRetention is how long the location locks the objects
+	// put in it for.
+	objectLockRetention time.Duration
+	// err is why the location's backup store couldn't be set up, if it
+	// couldn't be.
+	err error
+}
+
+func (c *backupController) newUploadTarget(namespace, name string, newBackupStore newBackupStoreFunc, pluginManager plugin.Manager, log logrus.FieldLogger) uploadTarget {
+	target := uploadTarget{location: name}
+
+	location, err := c.backupLocationLister.BackupStorageLocations(namespace).Get(name)
+	if err != nil {
+		target.err = errors.Wrap(err, "error getting backup storage location")
+		return target
+	}
+
+	target.objectLockRetention = location.Spec.ObjectLockRetention.Duration
+	target.store, target.err = newBackupStore(location, pluginManager, log)
+	return target
+}
+
+// objectLockExpiration returns when the object locks on a backup's objects
+// in targets expire, given when it completed, or the zero time if none of
+// targets lock them. Each object's lock starts when it's put, after the
+// backup completed, so it expires no earlier than this.
+func objectLockExpiration(completed time.Time, targets []uploadTarget) time.Time {
+	var expiration time.Time
+	for _, target := range targets {
+		if target.err != nil || target.objectLockRetention <= 0 {
+			continue
+		}
+		if targetExpiration := completed.Add(target.objectLockRetention); targetExpiration.After(expiration) {
+			expiration = targetExpiration
+		}
+	}
+	return expiration
+}
+
+// upload uploads the backup's files to the target. Nil files aren't
+// uploaded. Each target reads the files independently, so uploads can
+// run concurrently.
+func (t uploadTarget) upload(name string, metadata []byte, contents *os.File, contentIndex, manifest []byte) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	var metadataReader, contentsReader, contentIndexReader, manifestReader io.Reader
+	var err error
+
+	if metadata != nil {
+		metadataReader = bytes.NewBuffer(metadata)
+	}
+	if contents != nil {
+		if contentsReader, err = newFileReader(contents); err != nil {
+			return err
+		}
+	}
+	if contentIndex != nil {
+		contentIndexReader = bytes.NewBuffer(contentIndex)
+	}
+	if manifest != nil {
+		manifestReader = bytes.NewBuffer(manifest)
+	}
+	// the log is uploaded separately, by uploadBackupLog.
+	return t.store.PutBackup(name, metadataReader, contentsReader, contentIndexReader, manifestReader, nil)
+}
+
+// UploadRetryConfig configures how uploads of a backup to a storage location
+// are retried when they fail with an error that may be transient.
+type UploadRetryConfig struct {
+	// MaxRetries is the number of times a failed upload is retried. Zero
+	// means failed uploads aren't retried.
+	MaxRetries int
+
+	// BaseDelay is how long to wait before the first retry of a failed
+	// upload. The delay doubles with each subsequent retry. Zero uses the
+	// default.
+	BaseDelay time.Duration
+}
+
+const defaultUploadRetryBaseDelay = time.Second
+
+// permanentUploadErrors are substrings of the messages of object store
+// errors that retrying won't fix, such as authentication and authorization
+// failures and missing buckets. Errors from object store plugins reach the
+// server as strings, so they can only be recognized by their messages.
+var permanentUploadErrors = []string{
+	"AccessDenied",
+	"InvalidAccessKeyId",
+	"SignatureDoesNotMatch",
+	"ExpiredToken",
+	"NoSuchBucket",
+	"AuthenticationFailed",
+	"AuthorizationFailure",
+	"ContainerNotFound",
+	"status code: 401",
+	"status code: 403",
+	"Error 401",
+	"Error 403",
+}
+
+// isRetriableUploadError returns whether an upload that failed with err
+// may succeed if it's retried.
+func isRetriableUploadError(err error) bool {
+	msg := err.Error()
+	for _, permanent := range permanentUploadErrors {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// uploadWithRetries uploads the backup's files to the target, retrying
+// with exponential backoff if the upload fails with an error that may be
+// transient.
+func (c *backupController) uploadWithRetries(log logrus.FieldLogger, target uploadTarget, name string, metadata []byte, contents *os.File, contentIndex, manifest []byte) error {
+	// the target's backup store couldn't be set up, so there's nothing to retry
+	if target.err != nil {
+		return target.err
+	}
+
+	log = log.WithField("backupLocation", target.location)
+
+	delay := c.uploadRetry.BaseDelay
+	if delay <= 0 {
+		delay = defaultUploadRetryBaseDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := target.upload(name, metadata, contents, contentIndex, manifest)
+		if err == nil {
+			return nil
+		}
+
+		log := log.WithError(err).WithField("attempt", attempt)
+		if !isRetriableUploadError(err) {
+			log.Warn("Upload failed with an error that won't be retried")
+			return err
+		}
+		if attempt > c.uploadRetry.MaxRetries {
+			log.Warn("Upload failed and has no retries left")
+			return err
+		}
+
+		log.Warnf("Upload failed, retrying in %v", delay)
+		c.clock.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// newGzipLogWriter is the default newLogWriter: it gzips the backup log to w.
+func newGzipLogWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+// validateGzipFile returns an error if file isn't a complete gzip stream:
+// if it can't be decompressed to its end, or its checksum doesn't match.
+func validateGzipFile(file *os.File) error {
+	reader, err := newFileReader(file)
+	if err != nil {
+		return err
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return errors.Wrap(err, "error reading gzip header")
+	}
+	defer gzipReader.Close()
+
+	if _, err := io.Copy(ioutil.Discard, gzipReader); err != nil {
+		return errors.Wrap(err, "error decompressing gzip stream")
+	}
+	return nil
+}
+
+// newFileReader returns a reader over the whole of file that doesn't share
+// file's offset.
+func newFileReader(file *os.File) (io.Reader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting file info")
+	}
+
+	return io.NewSectionReader(file, 0, info.Size()), nil
+}
+
+// uploadBackup uploads the backup's files, other than its log, to each of its storage locations
+// concurrently, and records the outcome of each upload in the backup's
+// status. It returns an error if the uploads that succeeded don't satisfy
+// the backup's upload policy; otherwise, failed uploads are only logged.
+func (c *backupController) uploadBackup(backup *api.Backup, targets []uploadTarget, metadata []byte, contents *os.File, contentIndex, manifest []byte) error {
+	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+	backupScheduleName := backup.GetLabels()["ark-schedule"]
+
+	uploadErrs := make([]error, len(targets))
+	durations := make([]time.Duration, len(targets))
+
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			start := c.clock.Now()
+			uploadErrs[i] = c.uploadWithRetries(log, targets[i], backup.Name, metadata, contents, contentIndex, manifest)
+			durations[i] = c.clock.Since(start)
+		}(i)
+	}
+	wg.Wait()
+
+	backup.Status.LocationStatuses = make(map[string]api.UploadStatus, len(targets))
+
+	var failures []error
+	for i, target := range targets {
+		status := api.UploadStatus{
+			Phase:    api.UploadPhaseSucceeded,
+			Duration: metav1.Duration{Duration: durations[i]},
+		}
+		c.metrics.RegisterBackupUploadDuration(backupScheduleName, target.location, durations[i].Seconds())
+
+		if err := uploadErrs[i]; err != nil {
+			status.Phase = api.UploadPhaseFailed
+			status.Error = err.Error()
+			c.metrics.RegisterBackupUploadFailed(backupScheduleName, target.location)
+			failures = append(failures, errors.Wrapf(err, "error uploading backup to location %s", target.location))
+		}
+
+		backup.Status.LocationStatuses[target.location] = status
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	// the primary location is always the first target
+	primaryFailed := uploadErrs[0] != nil
+
+	policy := backup.Spec.UploadPolicy
+	if policy == "" {
+		policy = api.UploadPolicyRequirePrimary
+	}
+
+	if len(failures) == len(targets) || policy == api.UploadPolicyRequireAll ||
+		(policy == api.UploadPolicyRequirePrimary && primaryFailed) {
+		return kerrors.NewAggregate(failures)
+	}
+
+	for _, err := range failures {
+		log.WithError(err).Warn("Backup was not uploaded to all of its storage locations")
+		backup.Status.Warnings++
+	}
+
+	// the backup's data has to be in its primary location, so if that upload
+	// failed, make the first location it was uploaded to the primary instead.
+	if primaryFailed {
+		for i, target := range targets {
+			if uploadErrs[i] == nil {
+				log.Warnf("Making %s the backup's storage location because its upload to %s failed", target.location, backup.Spec.StorageLocation)
+				setPrimaryStorageLocation(backup, target.location)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// setPrimaryStorageLocation makes location, one of the backup's mirror
+// storage locations, its primary storage location. The former primary
+// location takes its place among the mirrors.
+func setPrimaryStorageLocation(backup *api.Backup, location string) {
+	for i := range backup.Spec.MirrorStorageLocations {
+		if backup.Spec.MirrorStorageLocations[i] == location {
+			backup.Spec.MirrorStorageLocations[i] = backup.Spec.StorageLocation
+			break
+		}
+	}
+	backup.Spec.StorageLocation = location
+
+	if backup.Labels == nil {
+		backup.Labels = make(map[string]string)
+	}
+	backup.Labels[api.StorageLocationLabel] = location
+}
+
+// encodeBackupJSON writes backup's metadata, as it's uploaded, to w.
+func encodeBackupJSON(backup *api.Backup, w io.Writer) error {
+	return encode.EncodeTo(backup, "json", w)
+}
+
+// uploadBackupSummary uploads the summary of the backup's metadata to each
+// of targets it was uploaded to. The summary only saves listing the backup
+// from downloading its full metadata, so failures are logged rather than
+// returned.
+func (c *backupController) uploadBackupSummary(backup *api.Backup, targets []uploadTarget) {
+	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+	summary := persistence.NewBackupSummary(backup)
+
+	for _, target := range targets {
+		if target.err != nil || backup.Status.LocationStatuses[target.location].Phase != api.UploadPhaseSucceeded {
+			continue
+		}
+
+		if err := target.store.PutBackupSummary(backup.Name, summary); err != nil {
+			log.WithError(err).WithField("backupLocation", target.location).Error("Error uploading backup summary")
+		}
+	}
+}
+
+// uploadBackupLog uploads the backup's log to each of targets. Uploading
+// the log is best-effort, so failures are logged rather than returned.
+func (c *backupController) uploadBackupLog(backup *api.Backup, targets []uploadTarget, logFile *os.File) {
+	log := c.logger.WithField("backup", kubeutil.NamespaceAndName(backup))
+
+	for _, target := range targets {
+		if target.err != nil {
+			continue
+		}
+
+		logReader, err := newFileReader(logFile)
+		if err != nil {
+			log.WithError(err).Error("Error reading backup log")
+			return
+		}
+
+		if err := target.store.PutBackupLog(backup.Name, logReader); err != nil {
+			log.WithError(err).WithField("backupLocation", target.location).Error("Error uploading backup log")
+		}
+	}
+}
@@ -18,23 +18,45 @@ package controller
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	core "k8s.io/client-go/testing"
 
 	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/backup"
+	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions"
 	"github.com/heptio/ark/pkg/metrics"
@@ -42,6 +64,8 @@ import (
 	persistencemocks "github.com/heptio/ark/pkg/persistence/mocks"
 	"github.com/heptio/ark/pkg/plugin"
 	pluginmocks "github.com/heptio/ark/pkg/plugin/mocks"
+	"github.com/heptio/ark/pkg/transform"
+	"github.com/heptio/ark/pkg/util/boolptr"
 	"github.com/heptio/ark/pkg/util/collections"
 	"github.com/heptio/ark/pkg/util/logging"
 	arktest "github.com/heptio/ark/pkg/util/test"
@@ -51,9 +75,10 @@ type fakeBackupper struct {
 	mock.Mock
 }
 
-func (b *fakeBackupper) Backup(logger logrus.FieldLogger, backup *v1.Backup, backupFile io.Writer, actions []backup.ItemAction) error {
-	args := b.Called(logger, backup, backupFile, actions)
-	return args.Error(0)
+func (b *fakeBackupper) Backup(logger logrus.FieldLogger, backup *v1.Backup, backupFile io.Writer, actions []backup.ItemAction, baseVersions archive.ResourceVersions, manifest io.Writer) ([]string, error) {
+	args := b.Called(logger, backup, backupFile, actions, baseVersions, manifest)
+	warnings, _ := args.Get(0).([]string)
+	return warnings, args.Error(1)
 }
 
 func TestProcessBackup(t *testing.T) {
@@ -119,6 +144,18 @@ func TestProcessBackup(t *testing.T) {
 			backup:       arktest.NewTestBackup().WithName("backup1").WithPhase(v1.BackupPhaseNew).WithIncludedNamespaces("foo").WithExcludedNamespaces("foo"),
 			expectBackup: false,
 		},
+		{
+			name:         "invalid backup set name fails validation",
+			key:          "heptio-ark/backup1",
+			backup:       arktest.NewTestBackup().WithName("backup1").WithPhase(v1.BackupPhaseNew).WithLabel(v1.BackupSetLabel, "Not_Valid"),
+			expectBackup: false,
+		},
+		{
+			name:         "invalid terminating namespace policy fails validation",
+			key:          "heptio-ark/backup1",
+			backup:       arktest.NewTestBackup().WithName("backup1").WithPhase(v1.BackupPhaseNew).WithTerminatingNamespacePolicy("Delete"),
+			expectBackup: false,
+		},
 		{
 			name:             "make sure specified included and excluded resources are honored",
 			key:              "heptio-ark/backup1",
@@ -164,6 +201,12 @@ func TestProcessBackup(t *testing.T) {
 			backup:       arktest.NewTestBackup().WithName("backup1").WithPhase(v1.BackupPhaseNew).WithStorageLocation("loc1"),
 			expectBackup: true,
 		},
+		{
+			name:         "dry run completes without uploading",
+			key:          "heptio-ark/backup1",
+			backup:       arktest.NewTestBackup().WithName("backup1").WithPhase(v1.BackupPhaseNew).WithDryRun(true),
+			expectBackup: true,
+		},
 		{
 			name:         "Backup with non-existent location will fail validation",
 			key:          "heptio-ark/backup1",
@@ -190,12 +233,16 @@ func TestProcessBackup(t *testing.T) {
 			c := NewBackupController(
 				sharedInformers.Ark().V1().Backups(),
 				client.ArkV1(),
+				nil,
 				backupper,
 				test.allowSnapshots,
 				logger,
 				logrus.InfoLevel,
 				func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				nil,
+				nil,
 				NewBackupTracker(),
+				NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
 				sharedInformers.Ark().V1().BackupStorageLocations(),
 				"default",
 				metrics.NewServerMetrics(),
@@ -232,12 +279,19 @@ func TestProcessBackup(t *testing.T) {
 				backup.Status.Expiration.Time = expiration
 				backup.Status.StartTimestamp.Time = startTime
 				backup.Status.Version = 1
+				backup.Annotations = map[string]string{
+					v1.PluginVersionsAnnotation:   `{"ObjectStore/aws":"v0.10.0"}`,
+					v1.CompletionMarkerAnnotation: "true",
+					v1.CompressionAnnotation:      "gzip",
+				}
 				backupper.On("Backup",
 					mock.Anything, // logger
 					backup,
 					mock.Anything, // backup file
 					mock.Anything, // actions
-				).Return(nil)
+					mock.Anything, // base versions
+					mock.Anything, // manifest
+				).Return(nil, nil)
 
 				defaultLocation := &v1.BackupStorageLocation{
 					ObjectMeta: metav1.ObjectMeta{
@@ -271,6 +325,7 @@ func TestProcessBackup(t *testing.T) {
 				require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(loc1))
 
 				pluginManager.On("GetBackupItemActions").Return(nil, nil)
+				pluginManager.On("GetPluginVersions").Return(map[string]string{"ObjectStore/aws": "v0.10.0"})
 
 				// Ensure we have a CompletionTimestamp when uploading.
 				// Failures will display the bytes in buf.
@@ -280,7 +335,13 @@ func TestProcessBackup(t *testing.T) {
 
 					return strings.Contains(json, timeString)
 				}
-				backupStore.On("PutBackup", test.backup.Name, mock.MatchedBy(completionTimestampIsPresent), mock.Anything, mock.Anything).Return(nil)
+				backupStore.On("BackupExists", test.backup.Name).Return(false, nil)
+				// dry runs aren't uploaded, so any call to PutBackup fails the test
+				if !test.backup.Spec.DryRun {
+					backupStore.On("PutBackup", test.backup.Name, mock.MatchedBy(completionTimestampIsPresent), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+					backupStore.On("PutBackupLog", test.backup.Name, mock.Anything).Return(nil)
+					backupStore.On("PutBackupSummary", test.backup.Name, mock.Anything).Return(nil)
+				}
 				pluginManager.On("CleanupClients").Return()
 			}
 
@@ -349,17 +410,21 @@ func TestProcessBackup(t *testing.T) {
 
 			// structs and func for decoding patch content
 			type StatusPatch struct {
-				Expiration          time.Time      `json:"expiration"`
-				Version             int            `json:"version"`
-				Phase               v1.BackupPhase `json:"phase"`
-				StartTimestamp      metav1.Time    `json:"startTimestamp"`
-				CompletionTimestamp metav1.Time    `json:"completionTimestamp"`
+				Expiration            time.Time                  `json:"expiration"`
+				Version               int                        `json:"version"`
+				Phase                 v1.BackupPhase             `json:"phase"`
+				StartTimestamp        metav1.Time                `json:"startTimestamp"`
+				CompletionTimestamp   metav1.Time                `json:"completionTimestamp"`
+				LocationStatuses      map[string]v1.UploadStatus `json:"locationStatuses"`
+				StorageLocationReason v1.StorageLocationReason   `json:"storageLocationReason"`
+				TarballChecksum       string                     `json:"tarballChecksum"`
 			}
 			type SpecPatch struct {
 				StorageLocation string `json:"storageLocation"`
 			}
 			type ObjectMetaPatch struct {
-				Labels map[string]string `json:"labels"`
+				Labels      map[string]string `json:"labels"`
+				Annotations map[string]string `json:"annotations"`
 			}
 
 			type Patch struct {
@@ -380,9 +445,10 @@ func TestProcessBackup(t *testing.T) {
 			if test.backup.Spec.StorageLocation == "" {
 				expected = Patch{
 					Status: StatusPatch{
-						Version:    1,
-						Phase:      v1.BackupPhaseInProgress,
-						Expiration: expiration,
+						Version:               1,
+						Phase:                 v1.BackupPhaseInProgress,
+						Expiration:            expiration,
+						StorageLocationReason: v1.StorageLocationReasonDefault,
 					},
 					Spec: SpecPatch{
 						StorageLocation: "default",
@@ -396,9 +462,10 @@ func TestProcessBackup(t *testing.T) {
 			} else {
 				expected = Patch{
 					Status: StatusPatch{
-						Version:    1,
-						Phase:      v1.BackupPhaseInProgress,
-						Expiration: expiration,
+						Version:               1,
+						Phase:                 v1.BackupPhaseInProgress,
+						Expiration:            expiration,
+						StorageLocationReason: v1.StorageLocationReasonSpec,
 					},
 					ObjectMeta: ObjectMetaPatch{
 						Labels: map[string]string{
@@ -410,15 +477,3918 @@ func TestProcessBackup(t *testing.T) {
 
 			arktest.ValidatePatch(t, actions[0], expected, decode)
 
-			// validate Patch call 2 (setting phase, startTimestamp, completionTimestamp)
+			// validate Patch call 2 (setting phase, startTimestamp, completionTimestamp,
+			// location statuses, annotations)
 			expected = Patch{
 				Status: StatusPatch{
 					Phase:               v1.BackupPhaseCompleted,
 					StartTimestamp:      metav1.Time{Time: c.clock.Now()},
 					CompletionTimestamp: metav1.Time{Time: c.clock.Now()},
 				},
+				ObjectMeta: ObjectMetaPatch{
+					Annotations: map[string]string{
+						v1.PluginVersionsAnnotation:   `{"ObjectStore/aws":"v0.10.0"}`,
+						v1.CompletionMarkerAnnotation: "true",
+						v1.CompressionAnnotation:      "gzip",
+					},
+				},
+			}
+			// the fake backupper writes nothing, so the tarball is an empty
+			// compressed stream.
+			emptyTarball := new(bytes.Buffer)
+			compressor, err := c.compression.NewWriter(emptyTarball)
+			require.NoError(t, err)
+			require.NoError(t, compressor.Close())
+			checksum := sha256.Sum256(emptyTarball.Bytes())
+			expected.Status.TarballChecksum = hex.EncodeToString(checksum[:])
+
+			// dry runs aren't uploaded, so they have no location statuses
+			if !test.backup.Spec.DryRun {
+				location := test.backup.Spec.StorageLocation
+				if location == "" {
+					location = "default"
+				}
+				expected.Status.LocationStatuses = map[string]v1.UploadStatus{
+					location: {Phase: v1.UploadPhaseSucceeded},
+				}
 			}
 			arktest.ValidatePatch(t, actions[1], expected, decode)
 		})
 	}
 }
+
+func TestCheckHookResults(t *testing.T) {
+	succeeded := v1.BackupHookResult{Name: "hook", Phase: "pre", Succeeded: true}
+	failedPre := v1.BackupHookResult{Name: "hook", Phase: "pre", Error: "quiesce failed"}
+	failedPost := v1.BackupHookResult{Name: "hook", Phase: "post", Error: "unquiesce failed"}
+
+	tests := []struct {
+		name               string
+		results            []v1.BackupHookResult
+		failOnPreHookError bool
+		expectErr          bool
+	}{
+		{
+			name:               "no hooks",
+			failOnPreHookError: true,
+		},
+		{
+			name:               "all hooks succeeded",
+			results:            []v1.BackupHookResult{succeeded, succeeded},
+			failOnPreHookError: true,
+		},
+		{
+			name:    "failed pre hook is ignored by default",
+			results: []v1.BackupHookResult{succeeded, failedPre},
+		},
+		{
+			name:               "failed pre hook fails the backup when configured",
+			results:            []v1.BackupHookResult{succeeded, failedPre},
+			failOnPreHookError: true,
+			expectErr:          true,
+		},
+		{
+			name:               "failed post hook doesn't fail the backup",
+			results:            []v1.BackupHookResult{succeeded, failedPost},
+			failOnPreHookError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+			backup.Spec.Hooks.FailOnPreHookError = test.failOnPreHookError
+			backup.Status.HookResults = test.results
+
+			err := checkHookResults(arktest.NewLogger(), backup)
+			if test.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTransformedBackupRoundTrip(t *testing.T) {
+	pipeline, err := transform.NewPipeline([]string{"gzip"})
+	require.NoError(t, err)
+
+	backupper := &fakeBackupper{}
+	defer backupper.AssertExpectations(t)
+
+	c := &backupController{
+		backupper:   backupper,
+		transforms:  pipeline,
+		compression: archive.Compression{Algorithm: archive.CompressionZstd},
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").
+		WithAnnotation(v1.TransformsAnnotation, pipeline.String()).
+		WithAnnotation(v1.CompressionAnnotation, string(archive.CompressionZstd)).Backup
+
+	backupper.On("Backup", mock.Anything, backup, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(2).(io.Writer).Write([]byte("contents"))
+		}).
+		Return(nil, nil)
+
+	backupFile := new(bytes.Buffer)
+	_, err = c.transformedBackup(arktest.NewLogger(), backup, backupFile, nil, nil, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, "contents", backupFile.String())
+
+	r, err := untransform(backup, backupFile)
+	require.NoError(t, err)
+	zr, err := archive.NewReader(r, archive.CompressionAlgorithmForBackup(backup))
+	require.NoError(t, err)
+	defer zr.Close()
+	contents, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(contents))
+}
+
+func TestUploadBackup(t *testing.T) {
+	tests := []struct {
+		name             string
+		policy           v1.UploadPolicy
+		mirrorErr        error
+		primaryErr       error
+		expectErr        bool
+		expectedWarnings int
+		expectedLocation string
+		expectedPhases   map[string]v1.UploadPhase
+	}{
+		{
+			name: "all uploads succeed",
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseSucceeded,
+				"mirror":  v1.UploadPhaseSucceeded,
+			},
+		},
+		{
+			name:             "a failed mirror upload is a warning by default",
+			mirrorErr:        errors.New("upload failed"),
+			expectedWarnings: 1,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseSucceeded,
+				"mirror":  v1.UploadPhaseFailed,
+			},
+		},
+		{
+			name:       "a failed primary upload fails the backup by default",
+			primaryErr: errors.New("upload failed"),
+			expectErr:  true,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseFailed,
+				"mirror":  v1.UploadPhaseSucceeded,
+			},
+		},
+		{
+			name:             "a failed upload is ok when any may succeed",
+			policy:           v1.UploadPolicyRequireAny,
+			mirrorErr:        errors.New("upload failed"),
+			expectedWarnings: 1,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseSucceeded,
+				"mirror":  v1.UploadPhaseFailed,
+			},
+		},
+		{
+			name:             "a failed primary upload makes the mirror the primary when any may succeed",
+			policy:           v1.UploadPolicyRequireAny,
+			primaryErr:       errors.New("upload failed"),
+			expectedWarnings: 1,
+			expectedLocation: "mirror",
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseFailed,
+				"mirror":  v1.UploadPhaseSucceeded,
+			},
+		},
+		{
+			name:      "a failed upload fails the backup when all must succeed",
+			policy:    v1.UploadPolicyRequireAll,
+			mirrorErr: errors.New("upload failed"),
+			expectErr: true,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseSucceeded,
+				"mirror":  v1.UploadPhaseFailed,
+			},
+		},
+		{
+			name:             "a failed mirror upload is ok when the primary must succeed",
+			policy:           v1.UploadPolicyRequirePrimary,
+			mirrorErr:        errors.New("upload failed"),
+			expectedWarnings: 1,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseSucceeded,
+				"mirror":  v1.UploadPhaseFailed,
+			},
+		},
+		{
+			name:       "a failed primary upload fails the backup when the primary must succeed",
+			policy:     v1.UploadPolicyRequirePrimary,
+			primaryErr: errors.New("upload failed"),
+			expectErr:  true,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseFailed,
+				"mirror":  v1.UploadPhaseSucceeded,
+			},
+		},
+		{
+			name:       "all uploads failing fails the backup",
+			primaryErr: errors.New("upload failed"),
+			mirrorErr:  errors.New("upload failed"),
+			expectErr:  true,
+			expectedPhases: map[string]v1.UploadPhase{
+				"primary": v1.UploadPhaseFailed,
+				"mirror":  v1.UploadPhaseFailed,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &backupController{
+				genericController: newGenericController("backup", arktest.NewLogger()),
+				clock:             clock.NewFakeClock(time.Now()),
+				metrics:           metrics.NewServerMetrics(),
+			}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("primary").WithLabel(v1.StorageLocationLabel, "primary").Backup
+			backup.Spec.MirrorStorageLocations = []string{"mirror"}
+			backup.Spec.UploadPolicy = test.policy
+
+			primary, mirror := new(persistencemocks.BackupStore), new(persistencemocks.BackupStore)
+			defer primary.AssertExpectations(t)
+			defer mirror.AssertExpectations(t)
+
+			primary.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(test.primaryErr)
+			mirror.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(test.mirrorErr)
+
+			targets := []uploadTarget{
+				{location: "primary", store: primary},
+				{location: "mirror", store: mirror},
+			}
+
+			err := c.uploadBackup(backup, targets, []byte("{}"), nil, nil, nil)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			phases := make(map[string]v1.UploadPhase)
+			for location, status := range backup.Status.LocationStatuses {
+				phases[location] = status.Phase
+			}
+			assert.Equal(t, test.expectedPhases, phases)
+			assert.Equal(t, test.expectedWarnings, backup.Status.Warnings)
+
+			expectedMirror := "mirror"
+			if test.expectedLocation == "" {
+				test.expectedLocation = "primary"
+			} else {
+				expectedMirror = "primary"
+			}
+			assert.Equal(t, test.expectedLocation, backup.Spec.StorageLocation)
+			assert.Equal(t, test.expectedLocation, backup.Labels[v1.StorageLocationLabel])
+			assert.Equal(t, []string{expectedMirror}, backup.Spec.MirrorStorageLocations)
+		})
+	}
+}
+
+type fakeEvents struct {
+	corev1client.EventInterface
+	events []*corev1api.Event
+}
+
+func (f *fakeEvents) Events(namespace string) corev1client.EventInterface {
+	return f
+}
+
+func (f *fakeEvents) Create(event *corev1api.Event) (*corev1api.Event, error) {
+	f.events = append(f.events, event)
+	return event, nil
+}
+
+func TestRecordBackupEvent(t *testing.T) {
+	tests := []struct {
+		name            string
+		uploadErr       error
+		expectedType    string
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "successful upload",
+			expectedType:    corev1api.EventTypeNormal,
+			expectedReason:  "BackupUploaded",
+			expectedMessage: "Collected backup items in 1m30s and uploaded the backup in 20.5s",
+		},
+		{
+			name:            "failed upload",
+			uploadErr:       errors.New("bucket not found"),
+			expectedType:    corev1api.EventTypeWarning,
+			expectedReason:  "BackupUploadFailed",
+			expectedMessage: "Collected backup items in 1m30s, but failed to upload the backup after 20.5s: bucket not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := new(fakeEvents)
+			c := &backupController{
+				genericController: newGenericController("backup", arktest.NewLogger()),
+				eventClient:       events,
+				clock:             clock.NewFakeClock(time.Now()),
+			}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+
+			c.recordBackupEvent(backup, 90*time.Second, 20500*time.Millisecond, test.uploadErr)
+
+			require.Len(t, events.events, 1)
+			event := events.events[0]
+			assert.Equal(t, backup.Namespace, event.Namespace)
+			assert.Equal(t, corev1api.ObjectReference{
+				APIVersion: v1.SchemeGroupVersion.String(),
+				Kind:       "Backup",
+				Namespace:  backup.Namespace,
+				Name:       "backup-1",
+			}, event.InvolvedObject)
+			assert.Equal(t, test.expectedType, event.Type)
+			assert.Equal(t, test.expectedReason, event.Reason)
+			assert.Equal(t, test.expectedMessage, event.Message)
+		})
+	}
+}
+
+func TestRecordBackupResultEvent(t *testing.T) {
+	tests := []struct {
+		name            string
+		backup          *v1.Backup
+		err             error
+		expectedType    string
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "completed backup",
+			backup:          arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseCompleted).Backup,
+			expectedType:    corev1api.EventTypeNormal,
+			expectedReason:  "BackupCompleted",
+			expectedMessage: "Backup completed",
+		},
+		{
+			name: "completed backup with warnings",
+			backup: func() *v1.Backup {
+				backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseCompleted).Backup
+				backup.Status.Warnings = 2
+				return backup
+			}(),
+			expectedType:    corev1api.EventTypeNormal,
+			expectedReason:  "BackupCompleted",
+			expectedMessage: "Backup completed with 2 warnings",
+		},
+		{
+			name: "partially failed backup",
+			backup: func() *v1.Backup {
+				backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhasePartiallyFailed).Backup
+				backup.Status.PartialFailures = []string{"ns-1: error backing up pods"}
+				return backup
+			}(),
+			expectedType:    corev1api.EventTypeWarning,
+			expectedReason:  "BackupPartiallyFailed",
+			expectedMessage: "Backup completed, but backing up 1 namespaces failed",
+		},
+		{
+			name:            "empty backup",
+			backup:          arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseCompletedEmpty).Backup,
+			expectedType:    corev1api.EventTypeWarning,
+			expectedReason:  "BackupCompletedEmpty",
+			expectedMessage: "Backup completed, but it's empty; check its included and excluded resources and namespaces",
+		},
+		{
+			name:            "cancelled backup",
+			backup:          arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseCancelled).Backup,
+			expectedType:    corev1api.EventTypeNormal,
+			expectedReason:  "BackupCancelled",
+			expectedMessage: "Backup was cancelled",
+		},
+		{
+			name:            "failed backup",
+			backup:          arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseFailed).Backup,
+			err:             errors.New("error getting backup item actions"),
+			expectedType:    corev1api.EventTypeWarning,
+			expectedReason:  "BackupFailed",
+			expectedMessage: "Backup failed: error getting backup item actions",
+		},
+		{
+			name:            "long errors are truncated",
+			backup:          arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseFailed).Backup,
+			err:             errors.New(strings.Repeat("a", 2000)),
+			expectedType:    corev1api.EventTypeWarning,
+			expectedReason:  "BackupFailed",
+			expectedMessage: "Backup failed: " + strings.Repeat("a", maxEventMessageLength-len("Backup failed: ")-3) + "...",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := new(fakeEvents)
+			c := &backupController{
+				genericController: newGenericController("backup", arktest.NewLogger()),
+				eventClient:       events,
+				clock:             clock.NewFakeClock(time.Now()),
+			}
+
+			c.recordBackupResultEvent(test.backup, test.err)
+
+			require.Len(t, events.events, 1)
+			event := events.events[0]
+			assert.Equal(t, test.expectedType, event.Type)
+			assert.Equal(t, test.expectedReason, event.Reason)
+			assert.Equal(t, test.expectedMessage, event.Message)
+		})
+	}
+}
+
+func TestRunBackupRecordsEvents(t *testing.T) {
+	events := new(fakeEvents)
+
+	// the backup temp dir doesn't exist, so the backup fails right after
+	// it's started.
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	c := &backupController{
+		genericController: newGenericController("backup", arktest.NewLogger()),
+		eventClient:       events,
+		eventLimiter:      newEventRateLimiter(backupEventBurst, backupEventInterval),
+		clock:             clock.NewFakeClock(time.Now()),
+		backupTempDir:     filepath.Join(tempDir, "missing"),
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	require.Error(t, c.runBackup(context.Background(), backup, nil))
+
+	var reasons []string
+	for _, event := range events.events {
+		reasons = append(reasons, event.Reason)
+	}
+	assert.Equal(t, []string{"BackupStarted", "BackupFailed"}, reasons)
+	assert.Equal(t, corev1api.EventTypeWarning, events.events[1].Type)
+	assert.Contains(t, events.events[1].Message, "error creating temp file for backup log")
+}
+
+func TestRecordEventIsRateLimited(t *testing.T) {
+	var (
+		events    = new(fakeEvents)
+		fakeClock = clock.NewFakeClock(time.Now())
+		c         = &backupController{
+			genericController: newGenericController("backup", arktest.NewLogger()),
+			eventClient:       events,
+			eventLimiter:      newEventRateLimiter(2, time.Minute),
+			clock:             fakeClock,
+		}
+		backup1 = arktest.NewTestBackup().WithName("backup-1").Backup
+		backup2 = arktest.NewTestBackup().WithName("backup-2").Backup
+	)
+
+	// each backup can have 2 events recorded at once...
+	for i := 0; i < 3; i++ {
+		c.recordEvent(backup1, corev1api.EventTypeNormal, "BackupStarted", "Started backup")
+	}
+	assert.Len(t, events.events, 2)
+
+	// ...regardless of how many other backups have had recorded...
+	c.recordEvent(backup2, corev1api.EventTypeNormal, "BackupStarted", "Started backup")
+	assert.Len(t, events.events, 3)
+
+	// ...and one more each interval after that.
+	fakeClock.Step(time.Minute)
+	c.recordEvent(backup1, corev1api.EventTypeNormal, "BackupStarted", "Started backup")
+	c.recordEvent(backup1, corev1api.EventTypeNormal, "BackupStarted", "Started backup")
+	assert.Len(t, events.events, 4)
+}
+
+func TestEventRateLimiterDropsRefilledBuckets(t *testing.T) {
+	now := time.Now()
+	l := newEventRateLimiter(2, time.Minute)
+
+	assert.True(t, l.allow("ns/backup-1", now))
+	assert.True(t, l.allow("ns/backup-2", now))
+	assert.Len(t, l.buckets, 2)
+
+	// after 2 minutes, backup-1's bucket is full again, so it's dropped.
+	assert.True(t, l.allow("ns/backup-2", now.Add(2*time.Minute)))
+	assert.Len(t, l.buckets, 1)
+	assert.Contains(t, l.buckets, "ns/backup-2")
+}
+
+func TestProcessBackupWithFailedProbe(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		backupper       = &fakeBackupper{}
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+	)
+	defer backupper.AssertExpectations(t)
+	defer pluginManager.AssertExpectations(t)
+	defer backupStore.AssertExpectations(t)
+
+	var phases []string
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status struct {
+				Phase            string   `json:"phase"`
+				ValidationErrors []string `json:"validationErrors"`
+			} `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		phases = append(phases, patch.Status.Phase)
+
+		res := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup
+		res.Status.ValidationErrors = patch.Status.ValidationErrors
+		return true, res, nil
+	})
+
+	c := NewBackupController(
+		sharedInformers.Ark().V1().Backups(),
+		client.ArkV1(),
+		nil,
+		backupper,
+		false,
+		logger,
+		logrus.InfoLevel,
+		func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		nil,
+		nil,
+		NewBackupTracker(),
+		NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		"default",
+		metrics.NewServerMetrics(),
+	).(*backupController)
+
+	c.newBackupStore = func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).Backup
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:          "myCloud",
+			StorageType:       v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+			ProbeBeforeBackup: true,
+		},
+	}
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	pluginManager.On("CleanupClients").Return()
+	backupStore.On("Probe").Return(errors.New("access denied"))
+
+	require.NoError(t, c.processBackup("heptio-ark/backup-1"))
+
+	// the backup fails validation without ever being marked InProgress
+	// or handed to the backupper
+	assert.Equal(t, []string{string(v1.BackupPhaseFailedValidation)}, phases)
+	backupper.AssertNotCalled(t, "Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessBackupSkipsBackupAlreadyBeingProcessed(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		backupper       = &fakeBackupper{}
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+	)
+	defer pluginManager.AssertExpectations(t)
+	defer backupStore.AssertExpectations(t)
+
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+
+		return true, arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup, nil
+	})
+
+	c := NewBackupController(
+		sharedInformers.Ark().V1().Backups(),
+		client.ArkV1(),
+		nil,
+		backupper,
+		false,
+		logger,
+		logrus.InfoLevel,
+		func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		nil,
+		nil,
+		NewBackupTracker(),
+		NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		"default",
+		metrics.NewServerMetrics(),
+	).(*backupController)
+
+	c.newBackupStore = func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	// the informer's copy of the backup stays New, so without the tracker
+	// the second invocation would run it again.
+	backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).Backup
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	pluginManager.On("GetBackupItemActions").Return(nil, nil)
+	pluginManager.On("GetPluginVersions").Return(map[string]string{})
+	pluginManager.On("CleanupClients").Return()
+	backupStore.On("BackupExists", "backup-1").Return(false, nil)
+	backupStore.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	backupStore.On("PutBackupLog", "backup-1", mock.Anything).Return(nil)
+	backupStore.On("PutBackupSummary", "backup-1", mock.Anything).Return(nil)
+
+	// hold the first invocation in the backupper until the second has
+	// finished.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(nil, nil)
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- c.processBackup("heptio-ark/backup-1")
+	}()
+
+	<-started
+	go func() {
+		errs <- c.processBackup("heptio-ark/backup-1")
+	}()
+	require.NoError(t, <-errs)
+
+	close(release)
+	require.NoError(t, <-errs)
+
+	backupper.AssertNumberOfCalls(t, "Backup", 1)
+}
+
+// blockingBackupPatcher holds each patch until release is closed, and
+// records how many patches it's held at once.
+type blockingBackupPatcher struct {
+	patched chan string
+	release chan struct{}
+
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (p *blockingBackupPatcher) Patch(original, updated *v1.Backup) (*v1.Backup, error) {
+	p.lock.Lock()
+	p.inFlight++
+	if p.inFlight > p.maxInFlight {
+		p.maxInFlight = p.inFlight
+	}
+	p.lock.Unlock()
+
+	p.patched <- updated.Name
+	<-p.release
+
+	p.lock.Lock()
+	p.inFlight--
+	p.lock.Unlock()
+
+	return updated, nil
+}
+
+func (p *blockingBackupPatcher) PatchLatest(original, updated *v1.Backup) (*v1.Backup, error) {
+	return p.Patch(original, updated)
+}
+
+func (p *blockingBackupPatcher) Run(ctx context.Context) {}
+
+func TestProcessBackupLimitsConcurrentBackups(t *testing.T) {
+	const maxConcurrentBackups = 2
+
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		patcher         = &blockingBackupPatcher{patched: make(chan string, 5), release: make(chan struct{})}
+		names           = []string{"backup-1", "backup-2", "backup-3", "backup-4"}
+	)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", logger),
+		lister:               sharedInformers.Ark().V1().Backups().Lister(),
+		client:               client.ArkV1(),
+		clock:                &clock.RealClock{},
+		backupTracker:        NewBackupTracker(),
+		patcher:              patcher,
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		metrics:              metrics.NewServerMetrics(),
+		backupSlots:          make(chan struct{}, maxConcurrentBackups),
+	}
+
+	for _, name := range names {
+		backup := arktest.NewTestBackup().WithName(name).WithPhase(v1.BackupPhaseNew).Backup
+		require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	}
+
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		go func(name string) {
+			errs <- c.processBackup("heptio-ark/" + name)
+		}(name)
+	}
+
+	// the first backups to be dequeued run, up to the limit...
+	running := sets.NewString()
+	for i := 0; i < maxConcurrentBackups; i++ {
+		running.Insert(<-patcher.patched)
+	}
+
+	// ...and the rest are requeued, rather than failed, while they do.
+	for i := maxConcurrentBackups; i < len(names); i++ {
+		err := <-errs
+		after, ok := requeueDelay(err)
+		require.True(t, ok, "expected a requeue error, got %v", err)
+		assert.Equal(t, backupSlotRequeueDelay, after)
+	}
+
+	close(patcher.release)
+	for i := 0; i < maxConcurrentBackups; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, maxConcurrentBackups, patcher.maxInFlight)
+
+	// once the running backups finish, a requeued one can run.
+	var requeued string
+	for _, name := range names {
+		if !running.Has(name) {
+			requeued = name
+			break
+		}
+	}
+	require.NoError(t, c.processBackup("heptio-ark/"+requeued))
+	assert.Equal(t, requeued, <-patcher.patched)
+	assert.Len(t, c.backupSlots, 0)
+}
+
+func TestProcessBackupRecordsPhaseDurations(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		serverMetrics   = metrics.NewServerMetrics()
+		fakeClock       = clock.NewFakeClock(time.Now())
+	)
+
+	// the backup temp dir doesn't exist, so the backup fails right after
+	// it's started.
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	c := &backupController{
+		genericController:     newGenericController("backup", logger),
+		lister:                sharedInformers.Ark().V1().Backups().Lister(),
+		client:                client.ArkV1(),
+		clock:                 fakeClock,
+		backupTracker:         NewBackupTracker(),
+		patcher:               NewBackupPatcher(client.ArkV1(), 0, 0, 0, serverMetrics, logger),
+		backupLocationLister:  sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		defaultBackupLocation: "default",
+		metrics:               serverMetrics,
+		backupTempDir:         filepath.Join(tempDir, "missing"),
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithLabel("ark-schedule", "daily").WithPhase(v1.BackupPhaseNew).Backup
+	backup.CreationTimestamp = metav1.NewTime(fakeClock.Now().Add(-30 * time.Second))
+
+	// each patch takes a minute, so the backup is InProgress for exactly
+	// the minute its final status takes to be patched.
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		fakeClock.Step(time.Minute)
+		return true, backup.DeepCopy(), nil
+	})
+
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	require.NoError(t, c.processBackup("heptio-ark/backup-1"))
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(serverMetrics))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	histograms := make(map[string]*dto.Histogram)
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			if metric.Histogram == nil {
+				continue
+			}
+			for _, label := range metric.Label {
+				if label.GetName() == "schedule" && label.GetValue() == "daily" {
+					histograms[family.GetName()] = metric.Histogram
+				}
+			}
+		}
+	}
+
+	// the backup was created 30s before it was picked up, and started once
+	// the minute-long patch to InProgress finished.
+	require.Contains(t, histograms, "ark_backup_pending_duration_seconds")
+	assert.Equal(t, uint64(1), histograms["ark_backup_pending_duration_seconds"].GetSampleCount())
+	assert.Equal(t, float64(90), histograms["ark_backup_pending_duration_seconds"].GetSampleSum())
+
+	require.Contains(t, histograms, "ark_backup_in_progress_duration_seconds")
+	assert.Equal(t, uint64(1), histograms["ark_backup_in_progress_duration_seconds"].GetSampleCount())
+	assert.Equal(t, float64(60), histograms["ark_backup_in_progress_duration_seconds"].GetSampleSum())
+}
+
+func TestProcessBackupCancelledBeforeStart(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		backupper       = &fakeBackupper{}
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+	)
+	defer backupper.AssertExpectations(t)
+
+	c := &backupController{
+		genericController: newGenericController("backup", logger),
+		backupper:         backupper,
+		lister:            sharedInformers.Ark().V1().Backups().Lister(),
+		client:            client.ArkV1(),
+		clock:             &clock.RealClock{},
+		backupTracker:     NewBackupTracker(),
+		patcher:           NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+		metrics:           metrics.NewServerMetrics(),
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).Backup
+	backup.Spec.Cancel = true
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+
+	var phases []string
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		phases = append(phases, patch.Status.Phase)
+
+		return true, arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup, nil
+	})
+
+	require.NoError(t, c.processBackup("heptio-ark/backup-1"))
+	assert.Equal(t, []string{string(v1.BackupPhaseCancelled)}, phases)
+	assert.False(t, c.backupTracker.Contains("heptio-ark", "backup-1"))
+}
+
+// fakeNotifier sends the backups it's notified of on a channel.
+type fakeNotifier chan *v1.Backup
+
+func (n fakeNotifier) Notify(backup *v1.Backup) error {
+	n <- backup
+	return nil
+}
+
+func TestProcessBackupNotifiesWebhook(t *testing.T) {
+	tests := []struct {
+		name          string
+		backup        *v1.Backup
+		expectedPhase v1.BackupPhase
+	}{
+		{
+			name:          "backup cancelled before it started",
+			backup:        arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).WithCancel(true).WithNotifyWebhook("https://hooks.example.com/ark").Backup,
+			expectedPhase: v1.BackupPhaseCancelled,
+		},
+		{
+			name:          "backup that failed validation",
+			backup:        arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).WithStorageLocation("missing").WithNotifyWebhook("https://hooks.example.com/ark").Backup,
+			expectedPhase: v1.BackupPhaseFailedValidation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				client          = fake.NewSimpleClientset()
+				sharedInformers = informers.NewSharedInformerFactory(client, 0)
+				logger          = arktest.NewLogger()
+				notifier        = make(fakeNotifier, 1)
+			)
+
+			c := &backupController{
+				genericController:    newGenericController("backup", logger),
+				lister:               sharedInformers.Ark().V1().Backups().Lister(),
+				backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+				client:               client.ArkV1(),
+				clock:                &clock.RealClock{},
+				backupTracker:        NewBackupTracker(),
+				patcher:              NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+				metrics:              metrics.NewServerMetrics(),
+				notifier:             notifier,
+			}
+
+			require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(test.backup))
+
+			client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+				patch := struct {
+					Status struct {
+						Phase string `json:"phase"`
+					} `json:"status"`
+				}{}
+				require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+
+				patched := test.backup.DeepCopy()
+				patched.Status.Phase = v1.BackupPhase(patch.Status.Phase)
+				return true, patched, nil
+			})
+
+			require.NoError(t, c.processBackup("heptio-ark/backup-1"))
+
+			select {
+			case notified := <-notifier:
+				assert.Equal(t, test.expectedPhase, notified.Status.Phase)
+			case <-time.After(5 * time.Second):
+				t.Fatal("webhook wasn't notified")
+			}
+		})
+	}
+}
+
+func TestCancelBackup(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var phases []string
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		phases = append(phases, patch.Status.Phase)
+
+		return true, arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup, nil
+	})
+
+	c := &backupController{
+		genericController: newGenericController("backup", arktest.NewLogger()),
+		client:            client.ArkV1(),
+		backupTracker:     NewBackupTracker(),
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+	backup.Spec.Cancel = true
+
+	// a backup that this controller isn't running is left alone
+	c.cancelBackup(backup)
+	assert.Empty(t, phases)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.backupTracker.Add(backup.Namespace, backup.Name)
+	c.backupTracker.SetCancelFunc(backup.Namespace, backup.Name, cancel)
+
+	c.cancelBackup(backup)
+	assert.Equal(t, context.Canceled, ctx.Err())
+	assert.Equal(t, []string{string(v1.BackupPhaseCancelling)}, phases)
+}
+
+func TestPatchBackupPreservesOwnerReferences(t *testing.T) {
+	owners := []metav1.OwnerReference{
+		{APIVersion: "example.com/v1", Kind: "Application", Name: "app-1", UID: "uid-1"},
+	}
+
+	tests := []struct {
+		name   string
+		update func(*v1.Backup)
+	}{
+		{
+			name:   "updated backup with the owner references",
+			update: func(backup *v1.Backup) {},
+		},
+		{
+			name:   "updated backup without the owner references",
+			update: func(backup *v1.Backup) { backup.OwnerReferences = nil },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").WithPhase(v1.BackupPhaseNew).Backup
+			backup.OwnerReferences = owners
+			client := fake.NewSimpleClientset(backup)
+
+			updated := backup.DeepCopy()
+			updated.Status.Phase = v1.BackupPhaseInProgress
+			test.update(updated)
+
+			res, err := patchBackup(backup, updated, client.ArkV1())
+			require.NoError(t, err)
+			assert.Equal(t, v1.BackupPhaseInProgress, res.Status.Phase)
+			assert.Equal(t, owners, res.OwnerReferences)
+
+			// and through another patch of the patched backup.
+			updated = res.DeepCopy()
+			updated.Status.Phase = v1.BackupPhaseCompleted
+			test.update(updated)
+
+			res, err = patchBackup(res, updated, client.ArkV1())
+			require.NoError(t, err)
+			assert.Equal(t, v1.BackupPhaseCompleted, res.Status.Phase)
+			assert.Equal(t, owners, res.OwnerReferences)
+
+			stored, err := client.ArkV1().Backups("ark").Get("backup-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, owners, stored.OwnerReferences)
+		})
+	}
+}
+
+func TestProcessBackupCancelledWhileInProgress(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		backupper       = &fakeBackupper{}
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+	)
+	defer pluginManager.AssertExpectations(t)
+	defer backupStore.AssertExpectations(t)
+
+	// the Cancelling patch races with the backup's final one.
+	var (
+		lock   sync.Mutex
+		phases []string
+	)
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		lock.Lock()
+		phases = append(phases, patch.Status.Phase)
+		lock.Unlock()
+
+		return true, arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup, nil
+	})
+
+	c := NewBackupController(
+		sharedInformers.Ark().V1().Backups(),
+		client.ArkV1(),
+		nil,
+		backupper,
+		false,
+		logger,
+		logrus.InfoLevel,
+		func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		nil,
+		nil,
+		NewBackupTracker(),
+		NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		"default",
+		metrics.NewServerMetrics(),
+	).(*backupController)
+
+	c.newBackupStore = func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).Backup
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	pluginManager.On("GetBackupItemActions").Return(nil, nil)
+	pluginManager.On("GetPluginVersions").Return(map[string]string{})
+	pluginManager.On("CleanupClients").Return()
+	backupStore.On("BackupExists", "backup-1").Return(false, nil)
+	// only the log of a cancelled backup is uploaded
+	backupStore.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		assert.Nil(t, args.Get(1))
+		assert.Nil(t, args.Get(2))
+	}).Return(nil)
+	backupStore.On("PutBackupLog", "backup-1", mock.Anything).Return(nil)
+
+	// block in the backupper until the backup's been cancelled.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(nil, nil)
+	defer close(release)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.processBackup("heptio-ark/backup-1")
+	}()
+
+	<-started
+	inProgress := backup.DeepCopy()
+	inProgress.Spec.Cancel = true
+	inProgress.Status.Phase = v1.BackupPhaseInProgress
+	c.cancelBackup(inProgress)
+
+	require.NoError(t, <-errs)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.ElementsMatch(t, []string{
+		string(v1.BackupPhaseInProgress),
+		string(v1.BackupPhaseCancelling),
+		string(v1.BackupPhaseCancelled),
+	}, phases)
+	assert.False(t, c.backupTracker.Contains("heptio-ark", "backup-1"))
+}
+
+func TestProcessBackupAbortedOnShutdown(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		backupper       = &fakeBackupper{}
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+	)
+	defer pluginManager.AssertExpectations(t)
+	defer backupStore.AssertExpectations(t)
+
+	var (
+		lock          sync.Mutex
+		phases        []string
+		failureReason string
+	)
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status struct {
+				Phase         string `json:"phase"`
+				FailureReason string `json:"failureReason"`
+			} `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		lock.Lock()
+		phases = append(phases, patch.Status.Phase)
+		if patch.Status.FailureReason != "" {
+			failureReason = patch.Status.FailureReason
+		}
+		lock.Unlock()
+
+		return true, arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup, nil
+	})
+
+	c := NewBackupController(
+		sharedInformers.Ark().V1().Backups(),
+		client.ArkV1(),
+		nil,
+		backupper,
+		false,
+		logger,
+		logrus.InfoLevel,
+		func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		nil,
+		nil,
+		NewBackupTracker(),
+		NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		"default",
+		metrics.NewServerMetrics(),
+	).(*backupController)
+
+	c.newBackupStore = func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseNew).Backup
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(
+		arktest.NewTestBackup().WithName("backup-2").WithPhase(v1.BackupPhaseNew).Backup,
+	))
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	pluginManager.On("GetBackupItemActions").Return(nil, nil)
+	pluginManager.On("GetPluginVersions").Return(map[string]string{})
+	pluginManager.On("CleanupClients").Return()
+	backupStore.On("BackupExists", "backup-1").Return(false, nil)
+	// only the log of an aborted backup is uploaded
+	backupStore.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		assert.Nil(t, args.Get(1))
+		assert.Nil(t, args.Get(2))
+	}).Return(nil)
+	backupStore.On("PutBackupLog", "backup-1", mock.Anything).Return(nil)
+
+	// block in the backupper until the server's shut down.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(nil, nil)
+	defer close(release)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.processBackup("heptio-ark/backup-1")
+	}()
+
+	<-started
+	c.shutDown()
+
+	// the backup is failed rather than left InProgress...
+	require.NoError(t, <-errs)
+	lock.Lock()
+	assert.Equal(t, []string{string(v1.BackupPhaseInProgress), string(v1.BackupPhaseFailed)}, phases)
+	assert.Contains(t, failureReason, "Ark server shut down")
+	lock.Unlock()
+	assert.Empty(t, c.backupTracker.List())
+
+	// ...and backups that haven't started stay New until the server's
+	// restarted.
+	require.NoError(t, c.processBackup("heptio-ark/backup-2"))
+	lock.Lock()
+	assert.Len(t, phases, 2)
+	lock.Unlock()
+}
+
+// sleepHookClock is a fake clock that runs onSleep each time it's slept
+// on.
+type sleepHookClock struct {
+	*clock.FakeClock
+	onSleep func()
+}
+
+func (c *sleepHookClock) Sleep(d time.Duration) {
+	c.FakeClock.Sleep(d)
+	c.onSleep()
+}
+
+func TestBackupControllerShutDown(t *testing.T) {
+	tests := []struct {
+		name            string
+		inProgress      []string
+		finishAfter     int
+		expectCancelled []string
+	}{
+		{
+			name: "no backups in progress",
+		},
+		{
+			name:        "backups that finish within the grace period aren't aborted",
+			inProgress:  []string{"backup-1", "backup-2"},
+			finishAfter: 3,
+		},
+		{
+			name:            "backups that don't finish within the grace period are aborted",
+			inProgress:      []string{"backup-1", "backup-2"},
+			finishAfter:     -1,
+			expectCancelled: []string{"backup-1", "backup-2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				tracker   = NewBackupTracker()
+				sleeps    int
+				cancelled []string
+				start     = time.Now()
+				fakeClock = &sleepHookClock{FakeClock: clock.NewFakeClock(start)}
+			)
+
+			fakeClock.onSleep = func() {
+				sleeps++
+				if sleeps == test.finishAfter {
+					for _, name := range test.inProgress {
+						tracker.Delete("heptio-ark", name)
+					}
+				}
+			}
+
+			for _, name := range test.inProgress {
+				name := name
+				tracker.Add("heptio-ark", name)
+				tracker.SetCancelFunc("heptio-ark", name, func() { cancelled = append(cancelled, name) })
+			}
+
+			c := &backupController{
+				genericController:   newGenericController("backup", arktest.NewLogger()),
+				clock:               fakeClock,
+				backupTracker:       tracker,
+				shutdownGracePeriod: 10 * time.Second,
+			}
+
+			c.shutDown()
+
+			assert.Equal(t, test.expectCancelled, cancelled)
+			assert.Equal(t, len(test.expectCancelled) > 0, c.abortingBackups != 0)
+			assert.NotZero(t, c.shuttingDown)
+			// backups are only waited on for the grace period.
+			assert.False(t, fakeClock.Now().After(start.Add(c.shutdownGracePeriod)))
+		})
+	}
+}
+
+func TestRecoverOrphanedBackups(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+		fakeClock       = clock.NewFakeClock(time.Now())
+		tracker         = NewBackupTracker()
+	)
+	defer backupStore.AssertExpectations(t)
+
+	c := &backupController{
+		genericController:      newGenericController("backup", logger),
+		lister:                 sharedInformers.Ark().V1().Backups().Lister(),
+		client:                 client.ArkV1(),
+		clock:                  fakeClock,
+		backupTracker:          tracker,
+		backupLocationLister:   sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		metrics:                metrics.NewServerMetrics(),
+		newPluginManager:       func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		orphanedBackupTimeout:  5 * time.Minute,
+		cleanUpOrphanedBackups: true,
+		newBackupStore: func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+			return backupStore, nil
+		},
+	}
+
+	type status struct {
+		Phase         string `json:"phase"`
+		FailureReason string `json:"failureReason"`
+	}
+	patched := make(map[string]status)
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := struct {
+			Status status `json:"status"`
+		}{}
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		name := action.(core.PatchAction).GetName()
+		patched[name] = patch.Status
+
+		return true, arktest.NewTestBackup().WithName(name).WithPhase(v1.BackupPhase(patch.Status.Phase)).Backup, nil
+	})
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(
+		arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("myCloud").WithObjectStorage("bucket").BackupStorageLocation,
+	))
+
+	newBackup := func(name string, phase v1.BackupPhase, startedAgo time.Duration) *v1.Backup {
+		backup := arktest.NewTestBackup().WithName(name).WithStorageLocation("default").WithPhase(phase).Backup
+		backup.Status.StartTimestamp = metav1.NewTime(fakeClock.Now().Add(-startedAgo))
+		require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+		return backup
+	}
+
+	newBackup("orphaned", v1.BackupPhaseInProgress, 10*time.Minute)
+	newBackup("cancelling", v1.BackupPhaseCancelling, 10*time.Minute)
+	uploaded := newBackup("uploaded", v1.BackupPhaseInProgress, 10*time.Minute)
+	newBackup("recent", v1.BackupPhaseInProgress, time.Minute)
+	newBackup("running", v1.BackupPhaseInProgress, 10*time.Minute)
+	newBackup("completed", v1.BackupPhaseCompleted, 10*time.Minute)
+	tracker.Add("heptio-ark", "running")
+
+	pluginManager.On("CleanupClients").Return()
+	// what was uploaded of backups that didn't finish is deleted...
+	backupStore.On("BackupExists", "orphaned").Return(false, nil)
+	backupStore.On("DeleteBackup", "orphaned").Return(nil)
+	backupStore.On("BackupExists", "cancelling").Return(false, nil)
+	backupStore.On("DeleteBackup", "cancelling").Return(nil)
+	// ...and a backup that was uploaded is finalized from its metadata.
+	stored := uploaded.DeepCopy()
+	stored.Status.Phase = v1.BackupPhaseCompleted
+	backupStore.On("BackupExists", "uploaded").Return(true, nil)
+	backupStore.On("GetBackupMetadata", "uploaded").Return(stored, nil)
+
+	c.recoverOrphanedBackups()
+
+	require.Len(t, patched, 3)
+	assert.Equal(t, string(v1.BackupPhaseFailed), patched["orphaned"].Phase)
+	assert.Contains(t, patched["orphaned"].FailureReason, "Ark server running it stopped")
+	assert.Equal(t, string(v1.BackupPhaseCancelled), patched["cancelling"].Phase)
+	assert.Equal(t, string(v1.BackupPhaseCompleted), patched["uploaded"].Phase)
+
+	// once the recovered backups' phases reach the informer, backups that
+	// hadn't been in progress for long enough are recovered when they have.
+	for name, status := range patched {
+		backup, err := c.lister.Backups("heptio-ark").Get(name)
+		require.NoError(t, err)
+		updated := backup.DeepCopy()
+		updated.Status.Phase = v1.BackupPhase(status.Phase)
+		require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Update(updated))
+	}
+	patched = make(map[string]status)
+
+	fakeClock.Step(5 * time.Minute)
+	backupStore.On("BackupExists", "recent").Return(false, nil)
+	backupStore.On("DeleteBackup", "recent").Return(nil)
+
+	c.recoverOrphanedBackups()
+
+	require.Len(t, patched, 1)
+	assert.Equal(t, string(v1.BackupPhaseFailed), patched["recent"].Phase)
+}
+
+func TestBackupSyncError(t *testing.T) {
+	backups := schema.GroupResource{Group: "ark.heptio.com", Resource: "backups"}
+
+	assert.True(t, isPermanentError(backupSyncError(errors.Wrap(apierrors.NewNotFound(backups, "backup-1"), "error getting backup"))))
+	assert.True(t, isPermanentError(backupSyncError(apierrors.NewInvalid(schema.GroupKind{Group: "ark.heptio.com", Kind: "Backup"}, "backup-1", nil))))
+	assert.False(t, isPermanentError(backupSyncError(apierrors.NewConflict(backups, "backup-1", errors.New("conflict")))))
+	assert.False(t, isPermanentError(backupSyncError(errors.New("error"))))
+}
+
+func TestBackupRetriesExhaustedFailsBackup(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	var patches []map[string]interface{}
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		patches = append(patches, patch)
+		return true, arktest.NewTestBackup().WithName(action.(core.PatchAction).GetName()).Backup, nil
+	})
+
+	c := &backupController{
+		genericController: newGenericControllerWithRateLimiter("backup", arktest.NewLogger(), RateLimiterConfig{MaxRetries: 2}),
+		lister:            sharedInformers.Ark().V1().Backups().Lister(),
+		client:            client.ArkV1(),
+		metrics:           metrics.NewServerMetrics(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(
+		arktest.NewTestBackup().WithName("new").WithPhase(v1.BackupPhaseNew).Backup,
+	))
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(
+		arktest.NewTestBackup().WithName("completed").WithPhase(v1.BackupPhaseCompleted).Backup,
+	))
+
+	// a backup whose phase is already final is left alone
+	c.backupRetriesExhausted("heptio-ark/completed", errors.New("conflict"))
+	assert.Empty(t, patches)
+
+	c.backupRetriesExhausted("heptio-ark/new", errors.New("conflict"))
+	require.Len(t, patches, 1)
+	assert.Equal(t, map[string]interface{}{
+		"status": map[string]interface{}{
+			"phase":         string(v1.BackupPhaseFailed),
+			"failureReason": "backup could not be processed after 3 attempts: conflict",
+		},
+	}, patches[0])
+}
+
+func TestLogLevelForBackup(t *testing.T) {
+	tests := []struct {
+		name     string
+		backup   *v1.Backup
+		expected logrus.Level
+	}{
+		{
+			name:     "no annotation uses the server's level",
+			backup:   arktest.NewTestBackup().WithName("backup-1").Backup,
+			expected: logrus.InfoLevel,
+		},
+		{
+			name:     "annotation overrides the server's level",
+			backup:   arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.LogLevelAnnotation, "debug").Backup,
+			expected: logrus.DebugLevel,
+		},
+		{
+			name:     "invalid annotation uses the server's level",
+			backup:   arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.LogLevelAnnotation, "verbose").Backup,
+			expected: logrus.InfoLevel,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &backupController{backupLogLevel: logrus.InfoLevel}
+			assert.Equal(t, test.expected, c.logLevelForBackup(test.backup))
+		})
+	}
+}
+
+func TestNewBackupLogger(t *testing.T) {
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+
+	t.Run("json", func(t *testing.T) {
+		c := &backupController{backupLogLevel: logrus.InfoLevel, backupLogFormat: logging.FormatJSON}
+
+		var buf bytes.Buffer
+		log := c.newBackupLogger(backup, &buf).WithField("backup", "heptio-ark/backup-1")
+		log.Info("Starting backup")
+		log.WithError(errors.New("boom")).Error("Backup failed")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+
+		for i, msg := range []string{"Starting backup", "Backup failed"} {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(lines[i]), &entry))
+			assert.Equal(t, msg, entry["msg"])
+			assert.Equal(t, "heptio-ark/backup-1", entry["backup"])
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		c := &backupController{backupLogLevel: logrus.InfoLevel, backupLogFormat: logging.FormatText}
+
+		var buf bytes.Buffer
+		c.newBackupLogger(backup, &buf).Info("Starting backup")
+
+		assert.Contains(t, buf.String(), `msg="Starting backup"`)
+		assert.Error(t, json.Unmarshal(buf.Bytes(), &map[string]interface{}{}))
+	})
+}
+
+func TestValidateLogLevelAnnotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	_, errs := c.getLocationAndValidate(arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.LogLevelAnnotation, "debug").Backup, "default")
+	assert.Empty(t, errs)
+
+	_, errs = c.getLocationAndValidate(arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.LogLevelAnnotation, "verbose").Backup, "default")
+	assert.Equal(t, []string{`Invalid log level "verbose" in annotation ark.heptio.com/log-level`}, errs)
+}
+
+func TestValidateOrderedResources(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithExcludedResources("secrets").Backup
+	backup.Spec.OrderedResources = []string{"namespaces", "persistentvolumes"}
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.OrderedResources = []string{"namespaces", "secrets", "namespaces"}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	assert.Equal(t, []string{
+		"Ordered resource secrets is not included in the backup",
+		"Ordered resource namespaces is listed more than once",
+	}, errs)
+
+	backup = arktest.NewTestBackup().WithName("backup-1").WithIncludedResources("namespaces").Backup
+	backup.Spec.OrderedResources = []string{"persistentvolumes"}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	assert.Equal(t, []string{"Ordered resource persistentvolumes is not included in the backup"}, errs)
+}
+
+func TestValidateItemTransforms(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.ItemTransforms = []v1.ItemTransform{{Remove: "{.metadata.managedFields}"}, {Remove: "{.status}"}}
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.ItemTransforms = []v1.ItemTransform{{Remove: "{.spec.containers[0].env}"}}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], `Invalid item transform "{.spec.containers[0].env}"`)
+}
+
+func TestValidateExcludedLabelSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.ExcludedLabelSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"skip": "true"}}
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.ExcludedLabelSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "skip", Operator: "Bogus"}},
+	}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "Invalid excluded label selector")
+}
+
+func TestValidateVolumeSnapshotExcludeSelector(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.VolumeSnapshotExcludeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"snapshot": "skip"}}
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.SnapshotVolumes = boolptr.False()
+	_, errs = c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "snapshotVolumes is false")
+
+	backup.Spec.SnapshotVolumes = nil
+	backup.Spec.VolumeSnapshotExcludeSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "snapshot", Operator: "Bogus"}},
+	}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "Invalid volume snapshot exclude selector")
+}
+
+func TestValidateUnservedIncludedResources(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		discoveryHelper: arktest.NewFakeDiscoveryHelper(false, map[schema.GroupVersionResource]schema.GroupVersionResource{
+			{Resource: "pods"}: {Version: "v1", Resource: "pods"},
+		}),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	for _, included := range []string{"pods", "*"} {
+		backup := arktest.NewTestBackup().WithName("backup-1").WithIncludedResources(included).Backup
+		_, errs := c.getLocationAndValidate(backup, "default")
+		assert.Empty(t, errs, included)
+		assert.Empty(t, backup.Status.ValidationWarnings, included)
+	}
+
+	// unserved resources are warned about, but don't fail validation.
+	backup := arktest.NewTestBackup().WithName("backup-1").WithIncludedResources("pods", "widgets.example.com").Backup
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+	require.Len(t, backup.Status.ValidationWarnings, 1)
+	assert.Contains(t, backup.Status.ValidationWarnings[0], "Included resource widgets.example.com isn't served by the API server")
+}
+
+func TestValidateOverlappingIncludesExcludes(t *testing.T) {
+	tests := []struct {
+		name               string
+		includedResources  []string
+		excludedResources  []string
+		includedNamespaces []string
+		excludedNamespaces []string
+		namespaceMatching  v1.NamespaceMatching
+		expectedErrors     int
+		expectedWarnings   []string
+	}{
+		{
+			name:               "no overlap",
+			includedResources:  []string{"foo"},
+			excludedResources:  []string{"bar"},
+			includedNamespaces: []string{"ns-1"},
+			excludedNamespaces: []string{"ns-2"},
+		},
+		{
+			name:              "the same resource in both lists fails validation instead",
+			includedResources: []string{"foo"},
+			excludedResources: []string{"foo"},
+			expectedErrors:    1,
+		},
+		{
+			name:               "the same namespace in both lists fails validation instead",
+			includedNamespaces: []string{"ns-1"},
+			excludedNamespaces: []string{"ns-1"},
+			expectedErrors:     1,
+		},
+		{
+			name:              "shortcut included and fully-qualified name excluded",
+			includedResources: []string{"foo"},
+			excludedResources: []string{"foodies.somegroup"},
+			expectedWarnings:  []string{"Resource foodies.somegroup is both included, as foo, and excluded, as foodies.somegroup, so it's excluded"},
+		},
+		{
+			name:              "fully-qualified name included and shortcut excluded",
+			includedResources: []string{"bazaars.anothergroup", "foo"},
+			excludedResources: []string{"baz"},
+			expectedWarnings:  []string{"Resource bazaars.anothergroup is both included, as bazaars.anothergroup, and excluded, as baz, so it's excluded"},
+		},
+		{
+			name:              "wildcard resource include and a literal exclude",
+			includedResources: []string{"*"},
+			excludedResources: []string{"foo"},
+			expectedWarnings:  []string{"Resource foodies.somegroup is both included, as *, and excluded, as foo, so it's excluded"},
+		},
+		{
+			name:              "wildcard resource exclude fails validation instead",
+			includedResources: []string{"foo"},
+			excludedResources: []string{"*"},
+			expectedErrors:    1,
+		},
+		{
+			name:              "unresolvable resources don't overlap",
+			includedResources: []string{"bad1"},
+			excludedResources: []string{"bad2"},
+		},
+		{
+			name:               "wildcard namespace include and a literal exclude",
+			includedNamespaces: []string{"*"},
+			excludedNamespaces: []string{"ns-1"},
+			expectedWarnings:   []string{"Namespace ns-1 is both included, as *, and excluded, as ns-1, so it's excluded"},
+		},
+		{
+			name:               "glob namespace include matching a literal exclude",
+			includedNamespaces: []string{"prod-*"},
+			excludedNamespaces: []string{"prod-db", "staging-db"},
+			namespaceMatching:  v1.NamespaceMatchingGlob,
+			expectedWarnings:   []string{"Namespace prod-db is both included, as prod-*, and excluded, as prod-db, so it's excluded"},
+		},
+		{
+			name:               "glob namespace exclude matching a literal include",
+			includedNamespaces: []string{"prod-db", "staging-db"},
+			excludedNamespaces: []string{"prod-*"},
+			namespaceMatching:  v1.NamespaceMatchingGlob,
+			expectedWarnings:   []string{"Namespace prod-db is both included, as prod-db, and excluded, as prod-*, so it's excluded"},
+		},
+		{
+			name:               "namespace patterns aren't globs with exact matching",
+			includedNamespaces: []string{"prod-*"},
+			excludedNamespaces: []string{"prod-db"},
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		discoveryHelper: arktest.NewFakeDiscoveryHelper(false, map[schema.GroupVersionResource]schema.GroupVersionResource{
+			{Resource: "foo"}:                            {Group: "somegroup", Resource: "foodies"},
+			{Resource: "foodies", Group: "somegroup"}:    {Group: "somegroup", Resource: "foodies"},
+			{Resource: "baz"}:                            {Group: "anothergroup", Resource: "bazaars"},
+			{Resource: "bazaars", Group: "anothergroup"}: {Group: "anothergroup", Resource: "bazaars"},
+			{Resource: "bar"}:                            {Group: "anothergroup", Resource: "barnacles"},
+		}),
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithName("backup-1").
+				WithIncludedResources(test.includedResources...).
+				WithExcludedResources(test.excludedResources...).
+				WithIncludedNamespaces(test.includedNamespaces...).
+				WithExcludedNamespaces(test.excludedNamespaces...).
+				Backup
+			backup.Spec.NamespaceMatching = test.namespaceMatching
+
+			_, errs := c.getLocationAndValidate(backup, "default")
+			assert.Len(t, errs, test.expectedErrors)
+
+			// unresolvable resources are also warned about as unserved
+			var warnings []string
+			for _, warning := range backup.Status.ValidationWarnings {
+				if !strings.Contains(warning, "isn't served by the API server") {
+					warnings = append(warnings, warning)
+				}
+			}
+			assert.Equal(t, test.expectedWarnings, warnings)
+		})
+	}
+}
+
+func TestValidatePartialFailurePolicy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	for _, policy := range []v1.PartialFailurePolicy{"", v1.PartialFailurePolicyFail, v1.PartialFailurePolicyContinue} {
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		backup.Spec.PartialFailurePolicy = policy
+		_, errs := c.getLocationAndValidate(backup, "default")
+		assert.Empty(t, errs, string(policy))
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.PartialFailurePolicy = "Ignore"
+	_, errs := c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Equal(t, `Invalid partial failure policy "Ignore"`, errs[0])
+}
+
+func TestValidateTransfer(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.TransferAnnotation, "true").Backup
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Equal(t, []string{"Server is not configured with a transfer endpoint"}, errs)
+
+	c.transferEndpoint = &fakeTransferEndpoint{}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+}
+
+func TestValidateConsistencyMode(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	for _, mode := range []v1.BackupConsistencyMode{"", v1.BackupConsistencyModeNone, v1.BackupConsistencyModeResourceVersion} {
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		backup.Spec.ConsistencyMode = mode
+		_, errs := c.getLocationAndValidate(backup, "default")
+		assert.Empty(t, errs, string(mode))
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.ConsistencyMode = "Transactional"
+	_, errs := c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Equal(t, `Invalid consistency mode "Transactional"`, errs[0])
+}
+
+func TestValidateOnListError(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	for _, policy := range []v1.ListErrorPolicy{"", v1.ListErrorPolicyFail, v1.ListErrorPolicySkip} {
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		backup.Spec.OnListError = policy
+		_, errs := c.getLocationAndValidate(backup, "default")
+		assert.Empty(t, errs, string(policy))
+	}
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.OnListError = "Ignore"
+	_, errs := c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Equal(t, `Invalid list error policy "Ignore"`, errs[0])
+}
+
+func TestValidateNamespaceMatching(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	tests := []struct {
+		name         string
+		matching     v1.NamespaceMatching
+		includes     []string
+		excludes     []string
+		expectedErrs []string
+	}{
+		{
+			name:     "exact matching doesn't check glob syntax",
+			includes: []string{"team-["},
+		},
+		{
+			name:     "valid globs",
+			matching: v1.NamespaceMatchingGlob,
+			includes: []string{"team-*", "prod-??"},
+			excludes: []string{"team-[xy]"},
+		},
+		{
+			name:     "invalid globs",
+			matching: v1.NamespaceMatchingGlob,
+			includes: []string{"team-["},
+			excludes: []string{"prod-\\"},
+			expectedErrs: []string{
+				`Invalid included/excluded namespace lists: invalid glob pattern "team-[": syntax error in pattern`,
+				`Invalid included/excluded namespace lists: invalid glob pattern "prod-\\": syntax error in pattern`,
+			},
+		},
+		{
+			name:         "unknown matching",
+			matching:     "Regexp",
+			expectedErrs: []string{`Invalid namespace matching "Regexp"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithName("backup-1").WithIncludedNamespaces(test.includes...).WithExcludedNamespaces(test.excludes...).Backup
+			backup.Spec.NamespaceMatching = test.matching
+
+			_, errs := c.getLocationAndValidate(backup, "default")
+			assert.Equal(t, test.expectedErrs, errs)
+		})
+	}
+}
+
+func TestValidateBackupMetadata(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := 0; i <= maxBackupMetadataEntries; i++ {
+		tooMany[fmt.Sprintf("key-%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		expected []string
+	}{
+		{
+			name: "no metadata",
+		},
+		{
+			name:     "valid metadata",
+			metadata: map[string]string{"git-sha": "0123abc", "change_ticket.id": "CHG-42"},
+		},
+		{
+			name:     "invalid keys",
+			metadata: map[string]string{"": "empty", "has space": "value", "ok": "value"},
+			expected: []string{
+				`Invalid metadata key "": a valid config key must consist of alphanumeric characters, '-', '_' or '.' (e.g. 'key.name',  or 'KEY_NAME',  or 'key-name', regex used for validation is '[-._a-zA-Z0-9]+')`,
+				`Invalid metadata key "has space": a valid config key must consist of alphanumeric characters, '-', '_' or '.' (e.g. 'key.name',  or 'KEY_NAME',  or 'key-name', regex used for validation is '[-._a-zA-Z0-9]+')`,
+			},
+		},
+		{
+			name:     "too many entries",
+			metadata: tooMany,
+			expected: []string{fmt.Sprintf("Metadata has %d entries, more than the maximum of %d", maxBackupMetadataEntries+1, maxBackupMetadataEntries)},
+		},
+		{
+			name:     "too large",
+			metadata: map[string]string{"notes": strings.Repeat("a", maxBackupMetadataBytes)},
+			expected: []string{fmt.Sprintf("Metadata is %d bytes, more than the maximum of %d", maxBackupMetadataBytes+len("notes"), maxBackupMetadataBytes)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, validateBackupMetadata(test.metadata))
+		})
+	}
+}
+
+// fakeCredentialsGetter gets backups' credentials from a map of credential
+// secret name to credentials.
+type fakeCredentialsGetter map[string][]byte
+
+func (g fakeCredentialsGetter) GetCredentials(backup *v1.Backup) ([]byte, error) {
+	credentials, ok := g[backup.Spec.CredentialSecretRef.Name]
+	if !ok {
+		return nil, errors.Errorf("credential secret %s not found", backup.Spec.CredentialSecretRef.Name)
+	}
+	return credentials, nil
+}
+
+func TestValidateCredentialSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		credentials:          fakeCredentialsGetter{"team-a": []byte("team-a-credentials")},
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.CredentialSecretRef = &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "team-a"}, Key: "cloud"}
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.CredentialSecretRef.Name = "team-b"
+	_, errs = c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Equal(t, "Invalid credential secret: credential secret team-b not found", errs[0])
+}
+
+func TestValidateNotifyWebhook(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithNotifyWebhook("https://hooks.example.com/ark").Backup
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.NotifyWebhook.URL = "hooks.example.com/ark"
+	_, errs = c.getLocationAndValidate(backup, "default")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "Invalid notify webhook URL")
+}
+
+func TestValidateBackupLocationAvailability(t *testing.T) {
+	tests := []struct {
+		name                  string
+		phase                 v1.BackupStorageLocationPhase
+		probeUnknownLocations bool
+		probeErr              error
+		expectProbe           bool
+		expectedErrs          []string
+	}{
+		{
+			name:  "available location is used without a probe",
+			phase: v1.BackupStorageLocationPhaseAvailable,
+		},
+		{
+			name:                  "available location isn't probed when the server probes unknown locations",
+			phase:                 v1.BackupStorageLocationPhaseAvailable,
+			probeUnknownLocations: true,
+		},
+		{
+			name:         "unavailable location fails validation",
+			phase:        v1.BackupStorageLocationPhaseUnavailable,
+			expectedErrs: []string{"Backup storage location default is unavailable"},
+		},
+		{
+			name: "unknown location is used without a probe by default",
+		},
+		{
+			name:                  "unknown location that passes its probe is used",
+			probeUnknownLocations: true,
+			expectProbe:           true,
+		},
+		{
+			name:                  "unknown location that fails its probe fails validation",
+			probeUnknownLocations: true,
+			probeErr:              errors.New("access denied"),
+			expectProbe:           true,
+			expectedErrs:          []string{"Backup storage location default failed its probe: access denied"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				client          = fake.NewSimpleClientset()
+				sharedInformers = informers.NewSharedInformerFactory(client, 0)
+				pluginManager   = &pluginmocks.Manager{}
+				backupStore     = &persistencemocks.BackupStore{}
+			)
+			defer pluginManager.AssertExpectations(t)
+			defer backupStore.AssertExpectations(t)
+
+			c := &backupController{
+				genericController:     newGenericController("backup", arktest.NewLogger()),
+				backupLocationLister:  sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+				probeUnknownLocations: test.probeUnknownLocations,
+				newPluginManager:      func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				newBackupStore: func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+					return backupStore, nil
+				},
+			}
+
+			location := &v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+				Status:     v1.BackupStorageLocationStatus{Phase: test.phase},
+			}
+			require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+			if test.expectProbe {
+				pluginManager.On("CleanupClients").Return()
+				backupStore.On("Probe").Return(test.probeErr)
+			}
+
+			_, errs := c.getLocationAndValidate(arktest.NewTestBackup().WithName("backup-1").Backup, "default")
+			assert.Equal(t, test.expectedErrs, errs)
+		})
+	}
+}
+
+func TestValidateBaseBackup(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		base          *v1.Backup
+		expectedError string
+	}{
+		{
+			name: "completed full backup in the same location is valid",
+			base: arktest.NewTestBackup().WithName("base").WithPhase(v1.BackupPhaseCompleted).WithStorageLocation("default").
+				WithExpiration(now.Add(time.Hour)).Backup,
+		},
+		{
+			name:          "missing base backup is rejected",
+			expectedError: "Invalid base backup: backup base not found",
+		},
+		{
+			name:          "base backup that isn't completed is rejected",
+			base:          arktest.NewTestBackup().WithName("base").WithPhase(v1.BackupPhaseFailed).WithStorageLocation("default").Backup,
+			expectedError: "Invalid base backup: backup base is Failed, not Completed",
+		},
+		{
+			name: "expired base backup is rejected",
+			base: arktest.NewTestBackup().WithName("base").WithPhase(v1.BackupPhaseCompleted).WithStorageLocation("default").
+				WithExpiration(now.Add(-time.Hour)).Backup,
+			expectedError: "Invalid base backup: backup base expired at 2018-05-31 23:00:00 +0000 UTC",
+		},
+		{
+			name:          "dry run base backup is rejected",
+			base:          arktest.NewTestBackup().WithName("base").WithPhase(v1.BackupPhaseCompleted).WithStorageLocation("default").WithDryRun(true).Backup,
+			expectedError: "Invalid base backup: backup base is a dry run",
+		},
+		{
+			name:          "base backup in another storage location is rejected",
+			base:          arktest.NewTestBackup().WithName("base").WithPhase(v1.BackupPhaseCompleted).WithStorageLocation("secondary").Backup,
+			expectedError: "Invalid base backup: backup base is in storage location secondary, not default",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+			c := &backupController{
+				genericController:    newGenericController("backup", arktest.NewLogger()),
+				lister:               sharedInformers.Ark().V1().Backups().Lister(),
+				backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+				clock:                clock.NewFakeClock(now),
+			}
+
+			require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+			}))
+			if test.base != nil {
+				require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(test.base))
+			}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+			backup.Spec.BaseBackup = "base"
+
+			_, errs := c.getLocationAndValidate(backup, "default")
+			if test.expectedError == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, test.expectedError, errs[0])
+		})
+	}
+}
+
+func TestSelectStorageLocation(t *testing.T) {
+	locations := []*v1.BackupStorageLocation{
+		arktest.NewTestBackupStorageLocation().WithName("default").BackupStorageLocation,
+		arktest.NewTestBackupStorageLocation().WithName("us-east-1").WithLabel("region", "us-east-1").BackupStorageLocation,
+		arktest.NewTestBackupStorageLocation().WithName("eu-west-1-a").WithLabel("region", "eu-west-1").BackupStorageLocation,
+		arktest.NewTestBackupStorageLocation().WithName("eu-west-1-b").WithLabel("region", "eu-west-1").BackupStorageLocation,
+	}
+
+	tests := []struct {
+		name                  string
+		locationLabel         string
+		backup                *v1.Backup
+		expectedLocation      string
+		expectedReason        v1.StorageLocationReason
+		expectedMatchedLabel  string
+		expectedValidationErr string
+	}{
+		{
+			name:             "location in the spec wins over a label match",
+			locationLabel:    "region",
+			backup:           arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").WithLabel("region", "us-east-1").Backup,
+			expectedLocation: "default",
+			expectedReason:   v1.StorageLocationReasonSpec,
+		},
+		{
+			name:                 "location with the backup's label value is chosen",
+			locationLabel:        "region",
+			backup:               arktest.NewTestBackup().WithName("backup-1").WithLabel("region", "us-east-1").Backup,
+			expectedLocation:     "us-east-1",
+			expectedReason:       v1.StorageLocationReasonLabelMatch,
+			expectedMatchedLabel: "region=us-east-1",
+		},
+		{
+			name:             "default location is chosen when no location matches the backup's label",
+			locationLabel:    "region",
+			backup:           arktest.NewTestBackup().WithName("backup-1").WithLabel("region", "ap-south-1").Backup,
+			expectedLocation: "default",
+			expectedReason:   v1.StorageLocationReasonDefault,
+		},
+		{
+			name:             "default location is chosen when the backup doesn't have the label",
+			locationLabel:    "region",
+			backup:           arktest.NewTestBackup().WithName("backup-1").Backup,
+			expectedLocation: "default",
+			expectedReason:   v1.StorageLocationReasonDefault,
+		},
+		{
+			name:             "default location is chosen when the server has no location label",
+			backup:           arktest.NewTestBackup().WithName("backup-1").WithLabel("region", "us-east-1").Backup,
+			expectedLocation: "default",
+			expectedReason:   v1.StorageLocationReasonDefault,
+		},
+		{
+			name:                  "more than one location matching the backup's label is an error",
+			locationLabel:         "region",
+			backup:                arktest.NewTestBackup().WithName("backup-1").WithLabel("region", "eu-west-1").Backup,
+			expectedValidationErr: "Unable to select a backup storage location: backup storage locations eu-west-1-a, eu-west-1-b are all labeled region=eu-west-1, so the backup must set its storage location",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			sharedInformers := informers.NewSharedInformerFactory(client, 0)
+
+			c := &backupController{
+				genericController:    newGenericController("backup", arktest.NewLogger()),
+				backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+				locationLabel:        test.locationLabel,
+			}
+
+			for _, location := range locations {
+				require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+			}
+
+			location, errs := c.getLocationAndValidate(test.backup, "default")
+			if test.expectedValidationErr != "" {
+				assert.Equal(t, []string{test.expectedValidationErr}, errs)
+				assert.Nil(t, location)
+				return
+			}
+
+			require.Empty(t, errs)
+			assert.Equal(t, test.expectedLocation, location.Name)
+			assert.Equal(t, test.expectedLocation, test.backup.Spec.StorageLocation)
+			assert.Equal(t, test.expectedLocation, test.backup.Labels[v1.StorageLocationLabel])
+			assert.Equal(t, test.expectedReason, test.backup.Status.StorageLocationReason)
+			assert.Equal(t, test.expectedMatchedLabel, test.backup.Status.StorageLocationMatchedLabel)
+		})
+	}
+}
+
+// credentialsRecordingObjectStore is an in-memory object store that
+// records the contents of the credentials file it was initialized with.
+type credentialsRecordingObjectStore struct {
+	*cloudprovider.InMemoryObjectStore
+
+	credentialsFile string
+	credentials     string
+}
+
+func (o *credentialsRecordingObjectStore) Init(config map[string]string) error {
+	if file := config[cloudprovider.CredentialsFileConfigKey]; file != "" {
+		credentials, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		o.credentialsFile = file
+		o.credentials = string(credentials)
+	}
+	return o.InMemoryObjectStore.Init(config)
+}
+
+func TestBackupStoreFactoryUsesBackupCredentials(t *testing.T) {
+	tests := []struct {
+		name                string
+		secret              string
+		expectedCredentials string
+	}{
+		{name: "backup without a credential secret uses the default credentials"},
+		{name: "team A's backup uses team A's credentials", secret: "team-a", expectedCredentials: "team-a-credentials"},
+		{name: "team B's backup uses team B's credentials", secret: "team-b", expectedCredentials: "team-b-credentials"},
+	}
+
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := &credentialsRecordingObjectStore{InMemoryObjectStore: cloudprovider.NewInMemoryObjectStore("bucket")}
+			pluginManager := &pluginmocks.Manager{}
+			pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+
+			c := &backupController{
+				genericController: newGenericController("backup", arktest.NewLogger()),
+				newBackupStore:    persistence.NewObjectBackupStore,
+				credentials: fakeCredentialsGetter{
+					"team-a": []byte("team-a-credentials"),
+					"team-b": []byte("team-b-credentials"),
+				},
+			}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+			if test.secret != "" {
+				backup.Spec.CredentialSecretRef = &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: test.secret}, Key: "cloud"}
+			}
+
+			newBackupStore, removeCredentials, err := c.backupStoreFactory(backup)
+			require.NoError(t, err)
+
+			_, err = newBackupStore(location, pluginManager, arktest.NewLogger())
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCredentials, objectStore.credentials)
+
+			// the location itself isn't modified
+			assert.NotContains(t, location.Spec.Config, cloudprovider.CredentialsFileConfigKey)
+
+			removeCredentials()
+			if test.secret != "" {
+				_, err = os.Stat(objectStore.credentialsFile)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestObjectLockExpiration(t *testing.T) {
+	completed := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		targets  []uploadTarget
+		expected time.Time
+	}{
+		{
+			name:    "no target locks its objects",
+			targets: []uploadTarget{{location: "default"}, {location: "mirror"}},
+		},
+		{
+			name: "the latest of the targets' locks is used",
+			targets: []uploadTarget{
+				{location: "default", objectLockRetention: 24 * time.Hour},
+				{location: "mirror", objectLockRetention: 48 * time.Hour},
+				{location: "unlocked"},
+			},
+			expected: completed.Add(48 * time.Hour),
+		},
+		{
+			name: "targets that couldn't be set up aren't uploaded to, so their locks aren't used",
+			targets: []uploadTarget{
+				{location: "default", objectLockRetention: 24 * time.Hour},
+				{location: "mirror", objectLockRetention: 48 * time.Hour, err: errors.New("no such location")},
+			},
+			expected: completed.Add(24 * time.Hour),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, objectLockExpiration(completed, test.targets))
+		})
+	}
+}
+
+func TestSizeLimitWriter(t *testing.T) {
+	tests := []struct {
+		name           string
+		limit          int64
+		writes         []string
+		expected       string
+		expectExceeded bool
+	}{
+		{
+			name:     "no limit",
+			writes:   []string{"abc", "def"},
+			expected: "abcdef",
+		},
+		{
+			name:     "writes up to the limit succeed",
+			limit:    6,
+			writes:   []string{"abc", "def"},
+			expected: "abcdef",
+		},
+		{
+			name:           "writes past the limit are truncated",
+			limit:          4,
+			writes:         []string{"abc", "def", "ghi"},
+			expected:       "abcd",
+			expectExceeded: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := newSizeLimitWriter(buf, test.limit)
+
+			var err error
+			for _, data := range test.writes {
+				if _, err = w.Write([]byte(data)); err != nil {
+					break
+				}
+			}
+
+			assert.Equal(t, test.expected, buf.String())
+			assert.Equal(t, test.expectExceeded, w.exceeded)
+			if test.expectExceeded {
+				assert.Equal(t, errBackupSizeLimitExceeded, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTimeoutForBackup(t *testing.T) {
+	c := &backupController{backupTimeout: time.Hour}
+
+	assert.Equal(t, time.Hour, c.timeoutForBackup(arktest.NewTestBackup().Backup))
+
+	backup := arktest.NewTestBackup().Backup
+	backup.Spec.BackupTimeout.Duration = time.Minute
+	assert.Equal(t, time.Minute, c.timeoutForBackup(backup))
+}
+
+func TestDeleteStorageOnRemoval(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name          string
+		serverDefault bool
+		backup        *v1.Backup
+		expected      bool
+	}{
+		{
+			name:          "server default is used when the backup doesn't specify",
+			serverDefault: true,
+			backup:        arktest.NewTestBackup().Backup,
+			expected:      true,
+		},
+		{
+			name:          "backup's spec overrides the server default",
+			serverDefault: true,
+			backup:        &v1.Backup{Spec: v1.BackupSpec{DeleteStorageOnRemoval: &no}},
+			expected:      false,
+		},
+		{
+			name:     "backup can opt in when the server default is off",
+			backup:   &v1.Backup{Spec: v1.BackupSpec{DeleteStorageOnRemoval: &yes}},
+			expected: true,
+		},
+		{
+			name:          "dry runs never have storage to delete",
+			serverDefault: true,
+			backup:        arktest.NewTestBackup().WithDryRun(true).Backup,
+			expected:      false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &backupController{deleteStorage: test.serverDefault}
+			assert.Equal(t, test.expected, c.deleteStorageOnRemoval(test.backup))
+		})
+	}
+}
+
+func TestCreateTempFileInBackupTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &backupController{backupTempDir: dir}
+	backup := arktest.NewTestBackup().Backup
+
+	file, err := c.createTempFile(backup, "backup")
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(file.Name()))
+	assert.Empty(t, backup.Status.FailureReason)
+
+	closeAndRemoveFile(file, arktest.NewLogger())
+	_, err = os.Stat(file.Name())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunBackupFailsWhenBackupTempDirIsMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &backupController{
+		genericController: newGenericController("backup-test", arktest.NewLogger()),
+		clock:             clock.NewFakeClock(time.Now()),
+		backupTempDir:     filepath.Join(dir, "missing"),
+	}
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+
+	assert.Error(t, c.runBackup(context.Background(), backup, nil))
+	assert.Contains(t, backup.Status.FailureReason, "error creating temp file for backup log")
+}
+
+func TestRunBackupFinalizesBackupThatAlreadyExists(t *testing.T) {
+	newController := func(backupStore persistence.BackupStore, pluginManager plugin.Manager) *backupController {
+		return &backupController{
+			genericController: newGenericController("backup-test", arktest.NewLogger()),
+			backupper:         &fakeBackupper{},
+			clock:             clock.NewFakeClock(time.Now()),
+			backupTracker:     NewBackupTracker(),
+			newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+			newBackupStore: func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+				return backupStore, nil
+			},
+		}
+	}
+	newPluginManager := func() *pluginmocks.Manager {
+		pluginManager := &pluginmocks.Manager{}
+		pluginManager.On("CleanupClients").Return()
+		return pluginManager
+	}
+	location := arktest.NewTestBackupStorageLocation().WithName("default").BackupStorageLocation
+
+	t.Run("a backup that was uploaded before the server stopped isn't run again", func(t *testing.T) {
+		var (
+			backupStore   = &persistencemocks.BackupStore{}
+			pluginManager = newPluginManager()
+			c             = newController(backupStore, pluginManager)
+			backup        = arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+			uploaded      = arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseCompleted).Backup
+		)
+		defer backupStore.AssertExpectations(t)
+		defer pluginManager.AssertExpectations(t)
+
+		uploaded.Annotations = map[string]string{v1.CompletionMarkerAnnotation: "true"}
+		uploaded.Status.Warnings = 2
+		uploaded.Status.TarballChecksum = "abc123"
+		uploaded.Status.CompletionTimestamp = metav1.NewTime(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+
+		backupStore.On("BackupExists", "backup-1").Return(true, nil)
+		backupStore.On("GetBackupMetadata", "backup-1").Return(uploaded, nil)
+
+		// neither the plugin manager nor the fake backupper have
+		// expectations for running the backup, so running it fails the
+		// test.
+		require.NoError(t, c.runBackup(context.Background(), backup, location))
+
+		assert.Equal(t, v1.BackupPhaseCompleted, backup.Status.Phase)
+		assert.Equal(t, 2, backup.Status.Warnings)
+		assert.Equal(t, "abc123", backup.Status.TarballChecksum)
+		assert.Equal(t, uploaded.Status.CompletionTimestamp, backup.Status.CompletionTimestamp)
+		assert.Equal(t, "true", backup.Annotations[v1.CompletionMarkerAnnotation])
+		assert.Equal(t, map[string]v1.UploadStatus{"default": {Phase: v1.UploadPhaseSucceeded}}, backup.Status.LocationStatuses)
+	})
+
+	t.Run("a backup whose upload was interrupted is run again", func(t *testing.T) {
+		var (
+			backupStore   = &persistencemocks.BackupStore{}
+			pluginManager = newPluginManager()
+			c             = newController(backupStore, pluginManager)
+			backup        = arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+		)
+		defer backupStore.AssertExpectations(t)
+		defer pluginManager.AssertExpectations(t)
+
+		// the backup's tarball was uploaded, but not its completion marker.
+		backupStore.On("BackupExists", "backup-1").Return(false, nil)
+		pluginManager.On("GetBackupItemActions").Return(nil, nil)
+		pluginManager.On("GetPluginVersions").Return(map[string]string{})
+
+		// getting the base backup's metadata is the first thing running the
+		// backup does with its store, so failing it stops the backup there.
+		backup.Spec.BaseBackup = "base"
+		backupStore.On("GetBackupMetadata", "base").Return(nil, errors.New("stop"))
+
+		// the log of the attempt is still uploaded.
+		backupStore.On("PutBackupLog", "backup-1", mock.Anything).Return(nil)
+
+		assert.EqualError(t, c.runBackup(context.Background(), backup, location), "stop")
+	})
+}
+
+func TestRunBackupUploadsLogWhenBackupFails(t *testing.T) {
+	tests := []struct {
+		name        string
+		actionsErr  error
+		encodeErr   error
+		expectedErr string
+	}{
+		{
+			name:        "getting the backup's item actions fails",
+			actionsErr:  errors.New("no actions"),
+			expectedErr: "no actions",
+		},
+		{
+			name:        "encoding the backup's metadata fails",
+			encodeErr:   errors.New("bad json"),
+			expectedErr: "error encoding backup: bad json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+			pluginManager := &pluginmocks.Manager{}
+			pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+			pluginManager.On("GetBackupItemActions").Return(nil, test.actionsErr)
+			pluginManager.On("GetPluginVersions").Return(map[string]string{}).Maybe()
+			pluginManager.On("CleanupClients").Return()
+
+			backupper := &fakeBackupper{}
+			backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+
+			c := &backupController{
+				genericController: newGenericController("backup-test", arktest.NewLogger()),
+				backupper:         backupper,
+				clock:             clock.NewFakeClock(time.Now()),
+				backupTracker:     NewBackupTracker(),
+				metrics:           metrics.NewServerMetrics(),
+				compression:       archive.Compression{Algorithm: archive.CompressionGzip},
+				newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				newBackupStore:    persistence.NewObjectBackupStore,
+				encodeBackup: func(*v1.Backup, io.Writer) error {
+					return test.encodeErr
+				},
+			}
+
+			location := &v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+				Spec: v1.BackupStorageLocationSpec{
+					Provider:    "myCloud",
+					StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+				},
+			}
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+
+			err := c.runBackup(context.Background(), backup, location)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+
+			// the backup's log is uploaded, but nothing else.
+			keys, err := objectStore.ListObjects("bucket", "backups/backup-1/")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"backups/backup-1/backup-1-logs.gz"}, keys)
+		})
+	}
+}
+
+func TestRunBackupDetectsEmptyBackups(t *testing.T) {
+	tests := []struct {
+		name               string
+		itemsBackedUp      int
+		tarballSize        int
+		minBackupSizeBytes int64
+		expectedPhase      v1.BackupPhase
+	}{
+		{
+			name:          "a backup that captured no items is empty",
+			expectedPhase: v1.BackupPhaseCompletedEmpty,
+		},
+		{
+			name:          "a backup that captured items isn't empty",
+			itemsBackedUp: 3,
+			tarballSize:   10,
+			expectedPhase: v1.BackupPhaseCompleted,
+		},
+		{
+			name:               "a backup whose tarball is smaller than the minimum size is empty",
+			itemsBackedUp:      3,
+			tarballSize:        10,
+			minBackupSizeBytes: 1024,
+			expectedPhase:      v1.BackupPhaseCompletedEmpty,
+		},
+		{
+			name:               "a backup whose tarball is at least the minimum size isn't empty",
+			itemsBackedUp:      3,
+			tarballSize:        2048,
+			minBackupSizeBytes: 1024,
+			expectedPhase:      v1.BackupPhaseCompleted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+			pluginManager := &pluginmocks.Manager{}
+			pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+			pluginManager.On("GetBackupItemActions").Return(nil, nil)
+			pluginManager.On("GetPluginVersions").Return(map[string]string{})
+			pluginManager.On("CleanupClients").Return()
+
+			backupper := &fakeBackupper{}
+			backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				args.Get(1).(*v1.Backup).Status.Progress = &v1.BackupProgress{ItemsBackedUp: test.itemsBackedUp}
+				// random contents don't compress, so the tarball's about
+				// as large as what's written to it.
+				contents := make([]byte, test.tarballSize)
+				rand.Read(contents)
+				args.Get(2).(io.Writer).Write(contents)
+			}).Return(nil, nil)
+
+			serverMetrics := metrics.NewServerMetrics()
+			c := &backupController{
+				genericController:  newGenericController("backup-test", arktest.NewLogger()),
+				backupper:          backupper,
+				clock:              clock.NewFakeClock(time.Now()),
+				backupTracker:      NewBackupTracker(),
+				metrics:            serverMetrics,
+				compression:        archive.Compression{Algorithm: archive.CompressionGzip},
+				minBackupSizeBytes: test.minBackupSizeBytes,
+				newPluginManager:   func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				newBackupStore:     persistence.NewObjectBackupStore,
+				encodeBackup: func(backup *v1.Backup, w io.Writer) error {
+					_, err := w.Write([]byte(backup.Name))
+					return err
+				},
+			}
+
+			location := &v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+				Spec: v1.BackupStorageLocationSpec{
+					Provider:    "myCloud",
+					StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+				},
+			}
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+
+			require.NoError(t, c.runBackup(context.Background(), backup, location))
+			assert.Equal(t, test.expectedPhase, backup.Status.Phase)
+
+			// empty backups are still uploaded.
+			exists, err := objectStore.ListObjects("bucket", "backups/backup-1/backup-1.tar.gz")
+			require.NoError(t, err)
+			assert.NotEmpty(t, exists)
+		})
+	}
+}
+
+func TestRunBackupSendsOnlyWholeBackupsToTransferEndpoint(t *testing.T) {
+	tests := []struct {
+		name                 string
+		partialFailurePolicy v1.PartialFailurePolicy
+		partialFailures      []string
+		backupErr            error
+		expectedPhase        v1.BackupPhase
+		expectSent           bool
+	}{
+		{
+			name:          "completed backup is sent",
+			expectedPhase: v1.BackupPhaseCompleted,
+			expectSent:    true,
+		},
+		{
+			name:                 "partially failed backup is sent when partial failures are allowed",
+			partialFailurePolicy: v1.PartialFailurePolicyContinue,
+			partialFailures:      []string{"namespace ns-1: forbidden"},
+			expectedPhase:        v1.BackupPhasePartiallyFailed,
+			expectSent:           true,
+		},
+		{
+			name:          "failed backup isn't sent",
+			backupErr:     errors.New("backup failed"),
+			expectedPhase: v1.BackupPhaseFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+			pluginManager := &pluginmocks.Manager{}
+			pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+			pluginManager.On("GetBackupItemActions").Return(nil, nil)
+			pluginManager.On("GetPluginVersions").Return(map[string]string{})
+			pluginManager.On("CleanupClients").Return()
+
+			backupper := &fakeBackupper{}
+			backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				backup := args.Get(1).(*v1.Backup)
+				backup.Status.Progress = &v1.BackupProgress{ItemsBackedUp: 3}
+				backup.Status.PartialFailures = test.partialFailures
+				args.Get(2).(io.Writer).Write([]byte("contents"))
+			}).Return(nil, test.backupErr)
+
+			endpoint := &fakeTransferEndpoint{}
+			c := &backupController{
+				genericController: newGenericController("backup-test", arktest.NewLogger()),
+				backupper:         backupper,
+				clock:             clock.NewFakeClock(time.Now()),
+				backupTracker:     NewBackupTracker(),
+				metrics:           metrics.NewServerMetrics(),
+				compression:       archive.Compression{Algorithm: archive.CompressionGzip},
+				transferEndpoint:  endpoint,
+				newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				newBackupStore:    persistence.NewObjectBackupStore,
+				encodeBackup: func(backup *v1.Backup, w io.Writer) error {
+					_, err := w.Write([]byte(backup.Name))
+					return err
+				},
+			}
+
+			location := &v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+				Spec: v1.BackupStorageLocationSpec{
+					Provider:    "myCloud",
+					StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+				},
+			}
+			backup := arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.TransferAnnotation, "true").Backup
+			backup.Spec.PartialFailurePolicy = test.partialFailurePolicy
+
+			err := c.runBackup(context.Background(), backup, location)
+			if test.backupErr != nil {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedPhase, backup.Status.Phase)
+
+			if test.expectSent {
+				assert.Equal(t, []string{"backup-1"}, endpoint.sent)
+			} else {
+				assert.Empty(t, endpoint.sent)
+			}
+
+			// whether or not it's sent, only the backup's log is kept in
+			// object storage.
+			keys, err := objectStore.ListObjects("bucket", "backups/backup-1/")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"backups/backup-1/backup-1-logs.gz"}, keys)
+		})
+	}
+}
+
+func TestRunBackupUploadsSummary(t *testing.T) {
+	objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+	pluginManager := &pluginmocks.Manager{}
+	pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+	pluginManager.On("GetBackupItemActions").Return(nil, nil)
+	pluginManager.On("GetPluginVersions").Return(map[string]string{})
+	pluginManager.On("CleanupClients").Return()
+
+	backupper := &fakeBackupper{}
+	backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*v1.Backup).Status.Progress = &v1.BackupProgress{ItemsBackedUp: 3}
+		args.Get(2).(io.Writer).Write([]byte("contents"))
+	}).Return(nil, nil)
+
+	c := &backupController{
+		genericController: newGenericController("backup-test", arktest.NewLogger()),
+		backupper:         backupper,
+		clock:             clock.NewFakeClock(time.Now()),
+		backupTracker:     NewBackupTracker(),
+		metrics:           metrics.NewServerMetrics(),
+		compression:       archive.Compression{Algorithm: archive.CompressionGzip},
+		newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		newBackupStore:    persistence.NewObjectBackupStore,
+		// the full metadata can't be decoded, so the summary can only be
+		// read from its own object.
+		encodeBackup: func(backup *v1.Backup, w io.Writer) error {
+			_, err := w.Write([]byte("not a backup"))
+			return err
+		},
+	}
+
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	backup := arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").WithLabel("app", "nginx").Backup
+
+	require.NoError(t, c.runBackup(context.Background(), backup, location))
+
+	keys, err := objectStore.ListObjects("bucket", "backups/backup-1/backup-1-summary.json")
+	require.NoError(t, err)
+	assert.NotEmpty(t, keys)
+
+	store, err := persistence.NewObjectBackupStore(location, pluginManager, arktest.NewLogger())
+	require.NoError(t, err)
+	summary, err := store.GetBackupMetadataSummary("backup-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "backup-1", summary.Name)
+	assert.Equal(t, map[string]string{"app": "nginx"}, summary.Labels)
+	assert.Equal(t, v1.BackupPhaseCompleted, summary.Phase)
+	assert.Equal(t, "default", summary.StorageLocation)
+	assert.Equal(t, 3, summary.ItemsBackedUp)
+	assert.Equal(t, backup.Status.TarballChecksum, summary.TarballChecksum)
+	// timestamps are stored to the second.
+	assert.Equal(t, backup.Status.CompletionTimestamp.Unix(), summary.CompletionTimestamp.Unix())
+}
+
+// truncatingLogWriter is a gzip log writer whose Close flushes what's
+// been written without the end of the gzip stream, like a close that runs
+// out of disk space, and returns err.
+type truncatingLogWriter struct {
+	*gzip.Writer
+	err error
+}
+
+func (w *truncatingLogWriter) Close() error {
+	w.Writer.Flush()
+	return w.err
+}
+
+func TestRunBackupKeepsTempFiles(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keep=%t", keep), func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "ark-test-")
+			require.NoError(t, err)
+			defer os.RemoveAll(tempDir)
+
+			objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+			pluginManager := &pluginmocks.Manager{}
+			pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+			pluginManager.On("GetBackupItemActions").Return(nil, nil)
+			pluginManager.On("GetPluginVersions").Return(map[string]string{})
+			pluginManager.On("CleanupClients").Return()
+
+			backupper := &fakeBackupper{}
+			backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				args.Get(1).(*v1.Backup).Status.Progress = &v1.BackupProgress{ItemsBackedUp: 1}
+				args.Get(2).(io.Writer).Write([]byte("contents"))
+			}).Return(nil, nil)
+
+			c := &backupController{
+				genericController: newGenericController("backup-test", arktest.NewLogger()),
+				backupper:         backupper,
+				clock:             clock.NewFakeClock(time.Now()),
+				backupTracker:     NewBackupTracker(),
+				metrics:           metrics.NewServerMetrics(),
+				compression:       archive.Compression{Algorithm: archive.CompressionGzip},
+				newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				newBackupStore:    persistence.NewObjectBackupStore,
+				backupLogLevel:    logrus.InfoLevel,
+				backupTempDir:     tempDir,
+				encodeBackup: func(backup *v1.Backup, w io.Writer) error {
+					return json.NewEncoder(w).Encode(backup)
+				},
+			}
+			WithBackupTempFilesKept(keep)(c)
+
+			location := &v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+				Spec: v1.BackupStorageLocationSpec{
+					Provider:    "myCloud",
+					StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+				},
+			}
+			backup := arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").Backup
+
+			require.NoError(t, c.runBackup(context.Background(), backup, location))
+			assert.Equal(t, v1.BackupPhaseCompleted, backup.Status.Phase)
+
+			entries, err := ioutil.ReadDir(tempDir)
+			require.NoError(t, err)
+
+			if !keep {
+				// by default, the temp files are removed.
+				assert.Empty(t, entries)
+				assert.Empty(t, backup.Status.KeptTempFiles)
+				return
+			}
+
+			// the tarball's and the log's temp files are kept, and their
+			// paths recorded.
+			var files []string
+			for _, entry := range entries {
+				files = append(files, filepath.Join(tempDir, entry.Name()))
+			}
+			require.Len(t, files, 2)
+			assert.ElementsMatch(t, files, backup.Status.KeptTempFiles)
+
+			// the kept files hold the tarball and the log.
+			for _, file := range files {
+				data, err := ioutil.ReadFile(file)
+				require.NoError(t, err)
+				assert.NotEmpty(t, data)
+			}
+		})
+	}
+}
+
+func TestRunBackupRecordsTruncatedLog(t *testing.T) {
+	tests := []struct {
+		name                 string
+		newLogWriter         func(io.Writer) io.WriteCloser
+		expectedLogTruncated bool
+	}{
+		{
+			name: "complete log",
+		},
+		{
+			name: "log that fails to close",
+			newLogWriter: func(w io.Writer) io.WriteCloser {
+				return &truncatingLogWriter{Writer: gzip.NewWriter(w), err: errors.New("no space left on device")}
+			},
+			expectedLogTruncated: true,
+		},
+		{
+			name: "log that closes without error but is truncated",
+			newLogWriter: func(w io.Writer) io.WriteCloser {
+				return &truncatingLogWriter{Writer: gzip.NewWriter(w)}
+			},
+			expectedLogTruncated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+			pluginManager := &pluginmocks.Manager{}
+			pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+			pluginManager.On("GetBackupItemActions").Return(nil, nil)
+			pluginManager.On("GetPluginVersions").Return(map[string]string{})
+			pluginManager.On("CleanupClients").Return()
+
+			backupper := &fakeBackupper{}
+			backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				args.Get(1).(*v1.Backup).Status.Progress = &v1.BackupProgress{ItemsBackedUp: 1}
+				args.Get(2).(io.Writer).Write([]byte("contents"))
+			}).Return(nil, nil)
+
+			c := &backupController{
+				genericController: newGenericController("backup-test", arktest.NewLogger()),
+				backupper:         backupper,
+				clock:             clock.NewFakeClock(time.Now()),
+				backupTracker:     NewBackupTracker(),
+				metrics:           metrics.NewServerMetrics(),
+				compression:       archive.Compression{Algorithm: archive.CompressionGzip},
+				newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				newBackupStore:    persistence.NewObjectBackupStore,
+				backupLogLevel:    logrus.InfoLevel,
+				newLogWriter:      test.newLogWriter,
+				encodeBackup: func(backup *v1.Backup, w io.Writer) error {
+					return json.NewEncoder(w).Encode(backup)
+				},
+			}
+
+			location := &v1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+				Spec: v1.BackupStorageLocationSpec{
+					Provider:    "myCloud",
+					StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+				},
+			}
+			backup := arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").Backup
+
+			// a truncated log doesn't fail the backup.
+			require.NoError(t, c.runBackup(context.Background(), backup, location))
+			assert.Equal(t, v1.BackupPhaseCompleted, backup.Status.Phase)
+			assert.Equal(t, test.expectedLogTruncated, backup.Status.LogTruncated)
+
+			// the log is uploaded either way.
+			logObject, err := objectStore.GetObject("bucket", "backups/backup-1/backup-1-logs.gz")
+			require.NoError(t, err)
+			defer logObject.Close()
+
+			gzipReader, err := gzip.NewReader(logObject)
+			require.NoError(t, err)
+			log, err := ioutil.ReadAll(gzipReader)
+			if test.expectedLogTruncated {
+				assert.Equal(t, io.ErrUnexpectedEOF, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Contains(t, string(log), "Starting backup")
+		})
+	}
+}
+
+func TestValidateGzipFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "ark-")
+	require.NoError(t, err)
+	defer closeAndRemoveFile(file, arktest.NewLogger())
+
+	gzipWriter := gzip.NewWriter(file)
+	_, err = gzipWriter.Write([]byte("a log line"))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Flush())
+
+	// the stream's missing its end until it's closed.
+	assert.Error(t, validateGzipFile(file))
+
+	require.NoError(t, gzipWriter.Close())
+	assert.NoError(t, validateGzipFile(file))
+
+	// a corrupted stream's checksum doesn't match.
+	info, err := file.Stat()
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte{0}, info.Size()-5)
+	require.NoError(t, err)
+	assert.Error(t, validateGzipFile(file))
+}
+
+func TestRunBackupClampsNegativeDuration(t *testing.T) {
+	objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+	pluginManager := &pluginmocks.Manager{}
+	pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+	pluginManager.On("GetBackupItemActions").Return(nil, nil)
+	pluginManager.On("GetPluginVersions").Return(map[string]string{})
+	pluginManager.On("CleanupClients").Return()
+
+	// the clock is set back an hour while the backup's items are
+	// collected, like an NTP correction.
+	fakeClock := clock.NewFakeClock(time.Now())
+	start := fakeClock.Now()
+
+	backupper := &fakeBackupper{}
+	backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*v1.Backup).Status.Progress = &v1.BackupProgress{ItemsBackedUp: 1}
+		args.Get(2).(io.Writer).Write([]byte("contents"))
+		fakeClock.SetTime(start.Add(-time.Hour))
+	}).Return(nil, nil)
+
+	serverMetrics := metrics.NewServerMetrics()
+	c := &backupController{
+		genericController: newGenericController("backup-test", arktest.NewLogger()),
+		backupper:         backupper,
+		clock:             fakeClock,
+		backupTracker:     NewBackupTracker(),
+		metrics:           serverMetrics,
+		compression:       archive.Compression{Algorithm: archive.CompressionGzip},
+		newPluginManager:  func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		newBackupStore:    persistence.NewObjectBackupStore,
+		encodeBackup: func(backup *v1.Backup, w io.Writer) error {
+			return json.NewEncoder(w).Encode(backup)
+		},
+	}
+
+	location := &v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+		Spec: v1.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: v1.StorageType{ObjectStorage: &v1.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	backup := arktest.NewTestBackup().WithName("backup-1").WithLabel("ark-schedule", "daily").WithStorageLocation("default").Backup
+
+	require.NoError(t, c.runBackup(context.Background(), backup, location))
+
+	// the wall-clock timestamps are still recorded as they were read.
+	assert.Equal(t, start, backup.Status.StartTimestamp.Time)
+	assert.Equal(t, start.Add(-time.Hour), backup.Status.CompletionTimestamp.Time)
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(serverMetrics))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	var histogram *dto.Histogram
+	for _, family := range families {
+		if family.GetName() != "ark_backup_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			for _, label := range metric.Label {
+				if label.GetName() == "schedule" && label.GetValue() == "daily" {
+					histogram = metric.Histogram
+				}
+			}
+		}
+	}
+
+	require.NotNil(t, histogram)
+	assert.Equal(t, uint64(1), histogram.GetSampleCount())
+	assert.Equal(t, float64(0), histogram.GetSampleSum())
+}
+
+func TestBackupSkippedNotNewMetric(t *testing.T) {
+	completed := arktest.NewTestBackup().WithNamespace(v1.DefaultNamespace).WithName("backup-1").WithPhase(v1.BackupPhaseCompleted).Backup
+	client := fake.NewSimpleClientset(completed)
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+	logger := arktest.NewLogger()
+	serverMetrics := metrics.NewServerMetrics()
+
+	c := NewBackupController(
+		sharedInformers.Ark().V1().Backups(),
+		client.ArkV1(),
+		nil,
+		&fakeBackupper{},
+		false,
+		logger,
+		logrus.InfoLevel,
+		nil,
+		nil,
+		nil,
+		NewBackupTracker(),
+		NewBackupPatcher(client.ArkV1(), 0, 0, 0, serverMetrics, logger),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		"default",
+		serverMetrics,
+	).(*backupController)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	go sharedInformers.Start(ctx.Done())
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(serverMetrics))
+
+	skipped := func() map[string]float64 {
+		counts := make(map[string]float64)
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "ark_backup_skipped_not_new_total" {
+				continue
+			}
+			for _, metric := range family.Metric {
+				for _, label := range metric.Label {
+					if label.GetName() == "phase" {
+						counts[label.GetValue()] = metric.Counter.GetValue()
+					}
+				}
+			}
+		}
+		return counts
+	}
+
+	err := wait.PollImmediateUntil(time.Millisecond, func() (bool, error) {
+		return len(skipped()) > 0, nil
+	}, ctx.Done())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{string(v1.BackupPhaseCompleted): 1}, skipped())
+	assert.Equal(t, 0, c.queue.Len())
+}
+
+func TestBackupPriority(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+	backups := sharedInformers.Ark().V1().Backups().Informer().GetStore()
+
+	require.NoError(t, backups.Add(arktest.NewTestBackup().WithNamespace("ark").WithName("manual").Backup))
+	require.NoError(t, backups.Add(arktest.NewTestBackup().WithNamespace("ark").WithName("scheduled").WithLabel("ark-schedule", "daily").Backup))
+
+	priority := backupPriority(sharedInformers.Ark().V1().Backups().Lister())
+	assert.Equal(t, manualBackupPriority, priority("ark/manual"))
+	assert.Equal(t, scheduledBackupPriority, priority("ark/scheduled"))
+	assert.Equal(t, scheduledBackupPriority, priority("ark/missing"))
+
+	// under mixed load, the controller's queue hands out the manual
+	// backup ahead of scheduled ones that were queued before it.
+	c := newGenericControllerWithPriority("backup", arktest.NewLogger(), RateLimiterConfig{}, priority)
+	defer c.queue.ShutDown()
+
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("scheduled-%d", i)
+		require.NoError(t, backups.Add(arktest.NewTestBackup().WithNamespace("ark").WithName(name).WithLabel("ark-schedule", "daily").Backup))
+		c.queue.Add("ark/" + name)
+	}
+	c.queue.Add("ark/manual")
+
+	var keys []string
+	c.syncHandler = func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}
+	for c.queue.Len() > 0 {
+		require.True(t, c.processNextWorkItem())
+	}
+	assert.Equal(t, []string{"ark/manual", "ark/scheduled-0", "ark/scheduled-1", "ark/scheduled-2", "ark/scheduled-3", "ark/scheduled-4"}, keys)
+}
+
+func TestElapsedSince(t *testing.T) {
+	start := time.Now()
+	fakeClock := clock.NewFakeClock(start)
+	c := &backupController{clock: fakeClock}
+	log := arktest.NewLogger()
+
+	fakeClock.Step(time.Minute)
+	assert.Equal(t, time.Minute, c.elapsedSince(log, start, "backup"))
+
+	fakeClock.SetTime(start.Add(-time.Minute))
+	assert.Equal(t, time.Duration(0), c.elapsedSince(log, start, "backup"))
+}
+
+func TestSetBackupSizeByGroupGauges(t *testing.T) {
+	backupper := &fakeBackupper{}
+	serverMetrics := metrics.NewServerMetrics()
+	c := &backupController{
+		backupper:   backupper,
+		compression: archive.Compression{Algorithm: archive.CompressionGzip},
+		metrics:     serverMetrics,
+	}
+
+	backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*v1.Backup).Status.Progress = &v1.BackupProgress{
+			ItemsBackedUp:  3,
+			ResourceCounts: map[string]int{"pods": 2, "deployments.apps": 1},
+			ResourceSizes:  map[string]int64{"pods": 2048, "deployments.apps": 512},
+		}
+	}).Return(nil, nil)
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	_, err := c.backupWithContext(context.Background(), arktest.NewLogger(), backup, new(bytes.Buffer), nil, nil, nil)
+	require.NoError(t, err)
+
+	c.setBackupSizeByGroupGauges("daily", backup)
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(serverMetrics))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	sizes := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "ark_backup_size_by_group_bytes" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			labels := make(map[string]string)
+			for _, label := range metric.Label {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assert.Equal(t, "daily", labels["schedule"])
+			sizes[labels["group"]] = metric.Gauge.GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"pods": 2048, "deployments.apps": 512}, sizes)
+}
+
+func TestBackupWithContext(t *testing.T) {
+	t.Run("a backup that finishes in time updates the backup", func(t *testing.T) {
+		backupper := &fakeBackupper{}
+		c := &backupController{
+			backupper:   backupper,
+			compression: archive.Compression{Algorithm: archive.CompressionGzip},
+		}
+
+		backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*v1.Backup).Status.SkippedItems = 1
+		}).Return(nil, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		_, err := c.backupWithContext(ctx, arktest.NewLogger(), backup, new(bytes.Buffer), nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, backup.Status.SkippedItems)
+	})
+
+	t.Run("the backupper's warnings are returned separately from its error", func(t *testing.T) {
+		backupper := &fakeBackupper{}
+		c := &backupController{
+			backupper:   backupper,
+			compression: archive.Compression{Algorithm: archive.CompressionGzip},
+		}
+
+		backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return([]string{"warning 1", "warning 2"}, errors.New("backup failed"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		warnings, err := c.backupWithContext(ctx, arktest.NewLogger(), backup, new(bytes.Buffer), nil, nil, nil)
+		assert.EqualError(t, err, "backup failed")
+		assert.Equal(t, []string{"warning 1", "warning 2"}, warnings)
+	})
+
+	t.Run("a backup that blocks past its deadline is given up on", func(t *testing.T) {
+		backupper := &fakeBackupper{}
+		c := &backupController{
+			backupper:   backupper,
+			compression: archive.Compression{Algorithm: archive.CompressionGzip},
+		}
+
+		release := make(chan struct{})
+		writeErrs := make(chan error, 1)
+		backupper.On("Backup", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*v1.Backup).Status.SkippedItems = 1
+			<-release
+
+			// the backup can't write anything once it's been given up on
+			_, err := args.Get(2).(io.Writer).Write([]byte("foo"))
+			writeErrs <- err
+		}).Return(nil, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		backup := arktest.NewTestBackup().WithName("backup-1").Backup
+		buf := new(bytes.Buffer)
+		_, err := c.backupWithContext(ctx, arktest.NewLogger(), backup, buf, nil, nil, nil)
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Equal(t, 0, backup.Status.SkippedItems)
+
+		close(release)
+		assert.Error(t, <-writeErrs)
+		assert.Equal(t, 0, buf.Len())
+	})
+}
+
+func TestStreamsUpload(t *testing.T) {
+	awsLocation := arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("aws").BackupStorageLocation
+
+	tests := []struct {
+		name          string
+		streamUploads bool
+		contentIndex  bool
+		backup        *v1.Backup
+		location      *v1.BackupStorageLocation
+		expected      bool
+	}{
+		{
+			name:          "backup is streamed when the server streams uploads and the store supports it",
+			streamUploads: true,
+			backup:        arktest.NewTestBackup().WithName("backup-1").Backup,
+			location:      awsLocation,
+			expected:      true,
+		},
+		{
+			name:     "backup is staged when the server doesn't stream uploads",
+			backup:   arktest.NewTestBackup().WithName("backup-1").Backup,
+			location: awsLocation,
+		},
+		{
+			name:          "backup is staged when the store needs to know the tarball's length",
+			streamUploads: true,
+			backup:        arktest.NewTestBackup().WithName("backup-1").Backup,
+			location:      arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("azure").BackupStorageLocation,
+		},
+		{
+			name:          "dry run is staged",
+			streamUploads: true,
+			backup:        arktest.NewTestBackup().WithName("backup-1").WithDryRun(true).Backup,
+			location:      awsLocation,
+		},
+		{
+			name:          "backup with mirror locations is staged",
+			streamUploads: true,
+			backup:        arktest.NewTestBackup().WithName("backup-1").WithMirrorStorageLocations("mirror").Backup,
+			location:      awsLocation,
+		},
+		{
+			name:          "backup sent to a transfer endpoint is staged",
+			streamUploads: true,
+			backup:        arktest.NewTestBackup().WithName("backup-1").WithAnnotation(v1.TransferAnnotation, "true").Backup,
+			location:      awsLocation,
+		},
+		{
+			name:          "backup is staged when content indexes are built",
+			streamUploads: true,
+			contentIndex:  true,
+			backup:        arktest.NewTestBackup().WithName("backup-1").Backup,
+			location:      awsLocation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &backupController{streamUploads: test.streamUploads, contentIndex: test.contentIndex}
+			assert.Equal(t, test.expected, c.streamsUpload(test.backup, test.location))
+		})
+	}
+}
+
+func TestBackupStream(t *testing.T) {
+	t.Run("tarball is uploaded as it's written", func(t *testing.T) {
+		var (
+			store    = &persistencemocks.BackupStore{}
+			uploaded bytes.Buffer
+		)
+		store.On("PutBackupContents", "backup-1", mock.Anything).Return(func(_ string, r io.Reader) error {
+			_, err := io.Copy(&uploaded, r)
+			return err
+		})
+
+		stream := newBackupStream(store, "backup-1")
+		_, err := stream.Write([]byte("con"))
+		require.NoError(t, err)
+		_, err = stream.Write([]byte("tents"))
+		require.NoError(t, err)
+
+		require.NoError(t, stream.finish(false))
+		assert.Equal(t, "contents", uploaded.String())
+	})
+
+	t.Run("failed upload fails writes and is returned", func(t *testing.T) {
+		store := &persistencemocks.BackupStore{}
+		store.On("PutBackupContents", "backup-1", mock.Anything).Return(errors.New("upload failed"))
+
+		stream := newBackupStream(store, "backup-1")
+
+		// writes fail once the upload has failed, rather than blocking
+		var err error
+		for err == nil {
+			_, err = stream.Write([]byte("contents"))
+		}
+		assert.EqualError(t, err, "upload failed")
+
+		assert.EqualError(t, stream.finish(false), "upload failed")
+	})
+
+	t.Run("aborted upload fails", func(t *testing.T) {
+		var (
+			store   = &persistencemocks.BackupStore{}
+			readErr error
+		)
+		store.On("PutBackupContents", "backup-1", mock.Anything).Return(func(_ string, r io.Reader) error {
+			_, readErr = ioutil.ReadAll(r)
+			return readErr
+		})
+
+		stream := newBackupStream(store, "backup-1")
+		_, err := stream.Write([]byte("partial contents"))
+		require.NoError(t, err)
+
+		assert.NoError(t, stream.finish(true))
+		assert.Equal(t, errBackupStreamAborted, readErr)
+	})
+}
+
+func TestBackupItemActionVersions(t *testing.T) {
+	versions := map[string]string{
+		"BackupItemAction/pod":         "v1.2.0",
+		"BackupItemAction/unversioned": "",
+		"ObjectStore/aws":              "v0.10.0",
+	}
+
+	assert.Equal(t, map[string]string{
+		"pod":         "v1.2.0",
+		"unversioned": "unknown",
+	}, backupItemActionVersions(versions))
+
+	assert.Nil(t, backupItemActionVersions(map[string]string{"ObjectStore/aws": "v0.10.0"}))
+}
+
+// fakeItemAction is a backup item action registered under name. Its
+// executions don't finish until release is closed, if it's non-nil.
+type fakeItemAction struct {
+	name    string
+	release chan struct{}
+}
+
+func (a *fakeItemAction) Name() string {
+	return a.name
+}
+
+func (a *fakeItemAction) AppliesTo() (backup.ResourceSelector, error) {
+	return backup.ResourceSelector{}, nil
+}
+
+func (a *fakeItemAction) Execute(item runtime.Unstructured, _ *v1.Backup) (runtime.Unstructured, []backup.ResourceIdentifier, error) {
+	if a.release != nil {
+		<-a.release
+	}
+	return item, nil, nil
+}
+
+func TestTimeItemActions(t *testing.T) {
+	var (
+		fast = &fakeItemAction{name: "fast"}
+		slow = &fakeItemAction{name: "slow", release: make(chan struct{})}
+		item = arktest.UnstructuredOrDie(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"namespace":"ns","name":"cm"}}`)
+		c    = &backupController{metrics: metrics.NewServerMetrics(), itemActionTimeout: 10 * time.Millisecond}
+	)
+	defer close(slow.release)
+
+	actions, warnings := c.timeItemActions([]backup.ItemAction{fast, slow})
+	require.Len(t, actions, 2)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := actions[0].Execute(item, arktest.NewTestBackup().Backup)
+		require.NoError(t, err)
+
+		_, _, err = actions[1].Execute(item, arktest.NewTestBackup().Backup)
+		assert.Equal(t, &backup.ItemActionTimeoutError{Action: "slow", Timeout: 10 * time.Millisecond}, err)
+	}
+
+	assert.Equal(t, []string{"Backup item action slow timed out after 10ms on 2 items, which were backed up without its changes"}, warnings())
+}
+
+func TestEnabledItemActions(t *testing.T) {
+	var (
+		pod            = &fakeItemAction{name: "pod"}
+		pvRelabel      = &fakeItemAction{name: "pv-relabel"}
+		serviceAccount = &fakeItemAction{name: "service-account"}
+		actions        = []backup.ItemAction{pod, pvRelabel, serviceAccount}
+	)
+
+	tests := []struct {
+		name     string
+		spec     v1.BackupSpec
+		expected []backup.ItemAction
+	}{
+		{
+			name:     "all actions run by default",
+			expected: actions,
+		},
+		{
+			name:     "only enabled actions run",
+			spec:     v1.BackupSpec{EnabledPlugins: []string{"pod", "service-account"}},
+			expected: []backup.ItemAction{pod, serviceAccount},
+		},
+		{
+			name:     "disabled actions don't run",
+			spec:     v1.BackupSpec{DisabledPlugins: []string{"pv-relabel"}},
+			expected: []backup.ItemAction{pod, serviceAccount},
+		},
+		{
+			name:     "disabled actions don't run even if they're enabled",
+			spec:     v1.BackupSpec{EnabledPlugins: []string{"pod", "pv-relabel"}, DisabledPlugins: []string{"pv-relabel"}},
+			expected: []backup.ItemAction{pod},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, enabledItemActions(test.spec, actions))
+		})
+	}
+}
+
+func TestValidateItemActionNames(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	sharedInformers := informers.NewSharedInformerFactory(client, 0)
+	pluginManager := &pluginmocks.Manager{}
+
+	c := &backupController{
+		genericController:    newGenericController("backup", arktest.NewLogger()),
+		backupLocationLister: sharedInformers.Ark().V1().BackupStorageLocations().Lister(),
+		newPluginManager:     func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(&v1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: v1.DefaultNamespace, Name: "default"},
+	}))
+
+	pluginManager.On("GetPluginVersions").Return(map[string]string{
+		"BackupItemAction/pod":        "v1.0.0",
+		"BackupItemAction/pv-relabel": "",
+		"ObjectStore/aws":             "v0.10.0",
+	})
+	pluginManager.On("CleanupClients").Return()
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.EnabledPlugins = []string{"pod", "pv-relabel"}
+	backup.Spec.DisabledPlugins = []string{"pv-relabel"}
+	_, errs := c.getLocationAndValidate(backup, "default")
+	assert.Empty(t, errs)
+
+	backup.Spec.EnabledPlugins = []string{"pod", "aws"}
+	backup.Spec.DisabledPlugins = []string{"missing"}
+	_, errs = c.getLocationAndValidate(backup, "default")
+	assert.Equal(t, []string{
+		`Invalid enabled plugin "aws": no backup item action plugin is registered with that name`,
+		`Invalid disabled plugin "missing": no backup item action plugin is registered with that name`,
+	}, errs)
+}
+
+func TestUploadBackupRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxRetries    int
+		errs          []error
+		expectErr     bool
+		expectedCalls int
+	}{
+		{
+			name:          "transient failures are retried until the upload succeeds",
+			maxRetries:    3,
+			errs:          []error{errors.New("503 Service Unavailable"), errors.New("connection reset by peer")},
+			expectedCalls: 3,
+		},
+		{
+			name:          "upload fails once its retries are exhausted",
+			maxRetries:    2,
+			errs:          []error{errors.New("503 Service Unavailable"), errors.New("503 Service Unavailable"), errors.New("503 Service Unavailable")},
+			expectErr:     true,
+			expectedCalls: 3,
+		},
+		{
+			name:          "authorization failures aren't retried",
+			maxRetries:    3,
+			errs:          []error{errors.New("AccessDenied: Access Denied\n\tstatus code: 403")},
+			expectErr:     true,
+			expectedCalls: 1,
+		},
+		{
+			name:          "failures aren't retried by default",
+			errs:          []error{errors.New("503 Service Unavailable")},
+			expectErr:     true,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &backupController{
+				genericController: newGenericController("backup", arktest.NewLogger()),
+				clock:             clock.NewFakeClock(time.Now()),
+				metrics:           metrics.NewServerMetrics(),
+				uploadRetry:       UploadRetryConfig{MaxRetries: test.maxRetries, BaseDelay: time.Second},
+			}
+
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+
+			// the store fails with each of the test's errors in turn, then succeeds
+			store := new(persistencemocks.BackupStore)
+			for _, err := range test.errs {
+				store.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(err).Once()
+			}
+			store.On("PutBackup", "backup-1", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			err := c.uploadBackup(backup, []uploadTarget{{location: "default", store: store}}, []byte("{}"), nil, nil, nil)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			store.AssertNumberOfCalls(t, "PutBackup", test.expectedCalls)
+		})
+	}
+}
+
+func TestNewBackupControllerWithOptions(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+		fakeClock       = clock.NewFakeClock(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+	)
+
+	// the temp dir doesn't exist, so the backup fails right after it's
+	// started.
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	c := NewBackupControllerWithOptions(
+		sharedInformers.Ark().V1().Backups(),
+		client.ArkV1(),
+		nil,
+		&fakeBackupper{},
+		false,
+		logger,
+		logrus.InfoLevel,
+		nil,
+		nil,
+		nil,
+		NewBackupTracker(),
+		NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), logger),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		"default",
+		metrics.NewServerMetrics(),
+		WithClock(fakeClock),
+		WithBackupStoreFactory(func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+			return backupStore, nil
+		}),
+		WithPluginManagerFactory(func(logrus.FieldLogger) plugin.Manager { return pluginManager }),
+		WithBackupTempDir(filepath.Join(tempDir, "missing")),
+		WithMaxConcurrentBackups(2),
+		WithOrphanedBackupRecovery(time.Minute, true),
+	).(*backupController)
+
+	assert.Equal(t, pluginManager, c.newPluginManager(logger))
+	assert.Equal(t, 2, cap(c.backupSlots))
+	assert.Equal(t, time.Minute, c.orphanedBackupTimeout)
+	assert.True(t, c.cleanUpOrphanedBackups)
+	assert.NotNil(t, c.resyncFunc)
+	store, err := c.newBackupStore(nil, nil, logger)
+	require.NoError(t, err)
+	assert.Equal(t, backupStore, store)
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	location := arktest.NewTestBackupStorageLocation().WithName("default").BackupStorageLocation
+	require.Error(t, c.runBackup(context.Background(), backup, location))
+
+	assert.Equal(t, fakeClock.Now(), backup.Status.StartTimestamp.Time)
+}
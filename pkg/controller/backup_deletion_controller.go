@@ -19,6 +19,8 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
@@ -234,6 +236,38 @@ func (c *backupDeletionController) processRequest(req *v1.DeleteBackupRequest) e
 		return err
 	}
 
+	// A backup's objects can't be deleted until their object lock expires,
+	// so don't delete any of the backup before then.
+	if lockExpiration := backup.Status.ObjectLockExpiration.Time; lockExpiration.After(c.clock.Now()) {
+		req, err = c.patchDeleteBackupRequest(req, func(r *v1.DeleteBackupRequest) {
+			r.Status.Phase = v1.DeleteBackupRequestPhaseProcessed
+			r.Status.Errors = []string{fmt.Sprintf("unable to delete backup because its objects are locked by its storage location's object lock retention until %s", lockExpiration.UTC().Format(time.RFC3339))}
+		})
+
+		return err
+	}
+
+	// An incremental backup can't be restored without its base, so a base
+	// can't be deleted while incremental backups depend on it.
+	dependents, err := c.backupClient.Backups(backup.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{v1.BaseBackupNameLabel: backup.Name}).String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error listing incremental backups of backup")
+	}
+	var candidates []*v1.Backup
+	for i := range dependents.Items {
+		candidates = append(candidates, &dependents.Items[i])
+	}
+	if names := incrementalDependents(backup, candidates); len(names) > 0 {
+		req, err = c.patchDeleteBackupRequest(req, func(r *v1.DeleteBackupRequest) {
+			r.Status.Phase = v1.DeleteBackupRequestPhaseProcessed
+			r.Status.Errors = []string{fmt.Sprintf("unable to delete backup because incremental backups taken from it depend on it: %s; delete them first", strings.Join(names, ", "))}
+		})
+
+		return err
+	}
+
 	// Set backup status to Deleting
 	backup, err = c.patchBackup(backup, func(b *v1.Backup) {
 		b.Status.Phase = v1.BackupPhaseDeleting
@@ -362,6 +362,85 @@ func TestBackupDeletionControllerProcessRequest(t *testing.T) {
 		assert.Equal(t, expectedActions, td.client.Actions())
 	})
 
+	t.Run("backup whose objects are still locked isn't deleted", func(t *testing.T) {
+		td := setupBackupDeletionControllerTest()
+		td.controller.clock = clock.NewFakeClock(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+
+		td.client.PrependReactor("get", "backups", func(action core.Action) (bool, runtime.Object, error) {
+			backup := arktest.NewTestBackup().WithName("backup-1").
+				WithObjectLockExpiration(time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)).
+				Backup
+			return true, backup, nil
+		})
+
+		td.client.PrependReactor("patch", "deletebackuprequests", func(action core.Action) (bool, runtime.Object, error) {
+			return true, td.req, nil
+		})
+
+		err := td.controller.processRequest(td.req)
+		require.NoError(t, err)
+
+		expectedActions := []core.Action{
+			core.NewPatchAction(
+				v1.SchemeGroupVersion.WithResource("deletebackuprequests"),
+				td.req.Namespace,
+				td.req.Name,
+				[]byte(`{"status":{"phase":"InProgress"}}`),
+			),
+			core.NewGetAction(
+				v1.SchemeGroupVersion.WithResource("backups"),
+				td.req.Namespace,
+				td.req.Spec.BackupName,
+			),
+			core.NewPatchAction(
+				v1.SchemeGroupVersion.WithResource("deletebackuprequests"),
+				td.req.Namespace,
+				td.req.Name,
+				[]byte(`{"status":{"errors":["unable to delete backup because its objects are locked by its storage location's object lock retention until 2018-07-01T12:00:00Z"],"phase":"Processed"}}`),
+			),
+		}
+
+		assert.Equal(t, expectedActions, td.client.Actions())
+	})
+
+	t.Run("base backup that incremental backups depend on isn't deleted", func(t *testing.T) {
+		base := arktest.NewTestBackup().WithName("foo").WithPhase(v1.BackupPhaseCompleted).Backup
+		base.UID = "uid"
+
+		incremental := func(name string, phase v1.BackupPhase) *v1.Backup {
+			backup := arktest.NewTestBackup().WithName(name).WithPhase(phase).WithLabel(v1.BaseBackupNameLabel, "foo").Backup
+			backup.Spec.BaseBackup = "foo"
+			return backup
+		}
+
+		td := setupBackupDeletionControllerTest(
+			base,
+			incremental("incremental-2", v1.BackupPhaseCompleted),
+			incremental("incremental-1", v1.BackupPhasePartiallyFailed),
+			// failed backups can't be restored, so they don't depend on
+			// their base.
+			incremental("incremental-3", v1.BackupPhaseFailed),
+		)
+
+		td.client.PrependReactor("patch", "deletebackuprequests", func(action core.Action) (bool, runtime.Object, error) {
+			return true, td.req, nil
+		})
+
+		err := td.controller.processRequest(td.req)
+		require.NoError(t, err)
+
+		var patches []string
+		for _, action := range td.client.Actions() {
+			if patch, ok := action.(core.PatchAction); ok {
+				patches = append(patches, action.GetResource().Resource+" "+string(patch.GetPatch()))
+			}
+		}
+		assert.Equal(t, []string{
+			`deletebackuprequests {"status":{"phase":"InProgress"}}`,
+			`deletebackuprequests {"status":{"errors":["unable to delete backup because incremental backups taken from it depend on it: incremental-1, incremental-2; delete them first"],"phase":"Processed"}}`,
+		}, patches)
+	})
+
 	t.Run("full delete, no errors", func(t *testing.T) {
 		backup := arktest.NewTestBackup().WithName("foo").WithSnapshot("pv-1", "snap-1").Backup
 		backup.UID = "uid"
@@ -434,6 +513,12 @@ func TestBackupDeletionControllerProcessRequest(t *testing.T) {
 				td.req.Name,
 				[]byte(`{"metadata":{"labels":{"ark.heptio.com/backup-uid":"uid"}}}`),
 			),
+			core.NewListAction(
+				v1.SchemeGroupVersion.WithResource("backups"),
+				v1.SchemeGroupVersion.WithKind("Backup"),
+				td.req.Namespace,
+				metav1.ListOptions{LabelSelector: "ark.heptio.com/base-backup-name=foo"},
+			),
 			core.NewPatchAction(
 				v1.SchemeGroupVersion.WithResource("backups"),
 				td.req.Namespace,
@@ -0,0 +1,173 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
+	informers "github.com/heptio/ark/pkg/generated/informers/externalversions/ark/v1"
+	listers "github.com/heptio/ark/pkg/generated/listers/ark/v1"
+	"github.com/heptio/ark/pkg/persistence"
+	"github.com/heptio/ark/pkg/plugin"
+	"github.com/heptio/ark/pkg/util/stringslice"
+)
+
+// dependentBackupRequeueDelay is how long the controller waits before
+// checking again whether a deleted backup's data can be deleted, while
+// incremental backups depend on it.
+const dependentBackupRequeueDelay = time.Minute
+
+// backupFinalizerController deletes the data of deleted backups that have
+// the DeleteBackupStorageFinalizer from their storage locations, and then
+// removes the finalizer so the backups can be removed.
+type backupFinalizerController struct {
+	*genericController
+
+	backupLister         listers.BackupLister
+	backupClient         arkv1client.BackupsGetter
+	backupLocationLister listers.BackupStorageLocationLister
+	newPluginManager     func(logrus.FieldLogger) plugin.Manager
+	newBackupStore       func(*arkv1api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)
+}
+
+// NewBackupFinalizerController constructs a new backupFinalizerController.
+func NewBackupFinalizerController(
+	logger logrus.FieldLogger,
+	backupInformer informers.BackupInformer,
+	backupClient arkv1client.BackupsGetter,
+	backupLocationInformer informers.BackupStorageLocationInformer,
+	newPluginManager func(logrus.FieldLogger) plugin.Manager,
+) Interface {
+	c := &backupFinalizerController{
+		genericController:    newGenericController("backup-finalizer", logger),
+		backupLister:         backupInformer.Lister(),
+		backupClient:         backupClient,
+		backupLocationLister: backupLocationInformer.Lister(),
+
+		// use variables to refer to these functions so they can be
+		// replaced with fakes for testing.
+		newPluginManager: newPluginManager,
+		newBackupStore:   persistence.NewObjectBackupStore,
+	}
+
+	c.syncHandler = c.processQueueItem
+	c.cacheSyncWaiters = append(c.cacheSyncWaiters,
+		backupInformer.Informer().HasSynced,
+		backupLocationInformer.Informer().HasSynced,
+	)
+
+	backupInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.enqueue,
+			UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
+		},
+	)
+
+	return c
+}
+
+func (c *backupFinalizerController) processQueueItem(key string) error {
+	log := c.logger.WithField("backup", key)
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return newPermanentError(errors.Wrap(err, "error splitting queue key"))
+	}
+
+	backup, err := c.backupLister.Backups(ns).Get(name)
+	if apierrors.IsNotFound(err) {
+		log.Debug("Unable to find backup")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error getting backup")
+	}
+
+	if backup.DeletionTimestamp == nil || !stringslice.Has(backup.Finalizers, arkv1api.DeleteBackupStorageFinalizer) {
+		return nil
+	}
+
+	// an incremental backup can't be restored without its base, so the
+	// base's data is kept, and the base isn't removed, until the
+	// incremental backups taken from it have been deleted.
+	dependents, err := c.backupLister.Backups(ns).List(labels.SelectorFromSet(labels.Set{arkv1api.BaseBackupNameLabel: backup.Name}))
+	if err != nil {
+		return errors.Wrap(err, "error listing incremental backups of backup")
+	}
+	if names := incrementalDependents(backup, dependents); len(names) > 0 {
+		return newRequeueError(errors.Errorf("incremental backups taken from the backup depend on it: %s", strings.Join(names, ", ")), dependentBackupRequeueDelay)
+	}
+
+	if err := c.deleteBackupData(backup, log); err != nil {
+		// the finalizer is left in place, so the backup isn't removed
+		// until its data has been deleted.
+		return err
+	}
+
+	log.Info("Removing finalizer from backup")
+	updated := backup.DeepCopy()
+	updated.Finalizers = stringslice.Except(updated.Finalizers, arkv1api.DeleteBackupStorageFinalizer)
+	if _, err := patchBackup(backup, updated, c.backupClient); err != nil {
+		return errors.Wrap(err, "error removing finalizer from backup")
+	}
+
+	return nil
+}
+
+// deleteBackupData deletes the backup's data from its storage location
+// and any mirror locations. Locations that no longer exist are skipped,
+// since there's nothing that can be deleted from them.
+func (c *backupFinalizerController) deleteBackupData(backup *arkv1api.Backup, log logrus.FieldLogger) error {
+	pluginManager := c.newPluginManager(log)
+	defer pluginManager.CleanupClients()
+
+	locations := append([]string{backup.Spec.StorageLocation}, backup.Spec.MirrorStorageLocations...)
+	for _, name := range locations {
+		log := log.WithField("location", name)
+
+		location, err := c.backupLocationLister.BackupStorageLocations(backup.Namespace).Get(name)
+		if apierrors.IsNotFound(err) {
+			log.Warn("Backup storage location no longer exists, so the backup's data can't be deleted from it")
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "error getting backup storage location %s", name)
+		}
+
+		backupStore, err := c.newBackupStore(location, pluginManager, log)
+		if err != nil {
+			return err
+		}
+
+		log.Info("Deleting backup's data from storage location")
+		if err := backupStore.DeleteBackup(backup.Name); err != nil {
+			return errors.Wrapf(err, "error deleting backup from storage location %s", name)
+		}
+	}
+
+	return nil
+}
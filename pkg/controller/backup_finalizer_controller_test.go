@@ -0,0 +1,155 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	core "k8s.io/client-go/testing"
+
+	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
+	informers "github.com/heptio/ark/pkg/generated/informers/externalversions"
+	"github.com/heptio/ark/pkg/persistence"
+	persistencemocks "github.com/heptio/ark/pkg/persistence/mocks"
+	"github.com/heptio/ark/pkg/plugin"
+	pluginmocks "github.com/heptio/ark/pkg/plugin/mocks"
+	"github.com/heptio/ark/pkg/util/kube"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestBackupFinalizerControllerProcessQueueItem(t *testing.T) {
+	deleted := time.Now()
+
+	tests := []struct {
+		name             string
+		backup           *v1.Backup
+		otherBackups     []*v1.Backup
+		locations        []string
+		deleteBackupErr  error
+		expectedDeletes  int
+		expectedErr      bool
+		expectFinalizing bool
+	}{
+		{
+			name:   "backup that isn't being deleted is ignored",
+			backup: arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithFinalizers(v1.DeleteBackupStorageFinalizer).Backup,
+		},
+		{
+			name:   "deleted backup without the finalizer is ignored",
+			backup: arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithDeletionTimestamp(deleted).Backup,
+		},
+		{
+			name:             "backup's data is deleted before the finalizer is removed",
+			backup:           arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithDeletionTimestamp(deleted).WithFinalizers(v1.DeleteBackupStorageFinalizer, "other").Backup,
+			locations:        []string{"loc-1"},
+			expectedDeletes:  1,
+			expectFinalizing: true,
+		},
+		{
+			name:            "error deleting backup's data leaves the finalizer in place",
+			backup:          arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithDeletionTimestamp(deleted).WithFinalizers(v1.DeleteBackupStorageFinalizer).Backup,
+			locations:       []string{"loc-1"},
+			deleteBackupErr: errors.New("bucket unavailable"),
+			expectedDeletes: 1,
+			expectedErr:     true,
+		},
+		{
+			name:   "backup that an incremental backup depends on keeps its data and finalizer",
+			backup: arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithDeletionTimestamp(deleted).WithFinalizers(v1.DeleteBackupStorageFinalizer).Backup,
+			otherBackups: []*v1.Backup{
+				arktest.NewTestBackup().WithName("backup-2").WithBaseBackup("backup-1").WithLabel(v1.BaseBackupNameLabel, "backup-1").WithPhase(v1.BackupPhaseCompleted).Backup,
+			},
+			locations:   []string{"loc-1"},
+			expectedErr: true,
+		},
+		{
+			name:             "storage location that no longer exists is skipped",
+			backup:           arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithDeletionTimestamp(deleted).WithFinalizers(v1.DeleteBackupStorageFinalizer, "other").Backup,
+			expectFinalizing: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				client          = fake.NewSimpleClientset(test.backup)
+				sharedInformers = informers.NewSharedInformerFactory(client, 0)
+				pluginManager   = &pluginmocks.Manager{}
+				backupStore     = &persistencemocks.BackupStore{}
+			)
+
+			controller := NewBackupFinalizerController(
+				arktest.NewLogger(),
+				sharedInformers.Ark().V1().Backups(),
+				client.ArkV1(),
+				sharedInformers.Ark().V1().BackupStorageLocations(),
+				func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+			).(*backupFinalizerController)
+
+			controller.newBackupStore = func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+				return backupStore, nil
+			}
+
+			pluginManager.On("CleanupClients").Return(nil)
+			backupStore.On("DeleteBackup", test.backup.Name).Return(test.deleteBackupErr)
+
+			sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(test.backup)
+			for _, backup := range test.otherBackups {
+				sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup)
+			}
+			for _, name := range test.locations {
+				location := arktest.NewTestBackupStorageLocation().WithNamespace(test.backup.Namespace).WithName(name).BackupStorageLocation
+				sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location)
+			}
+
+			err := controller.processQueueItem(kube.NamespaceAndName(test.backup))
+			assert.Equal(t, test.expectedErr, err != nil)
+
+			backupStore.AssertNumberOfCalls(t, "DeleteBackup", test.expectedDeletes)
+
+			var patches []core.PatchAction
+			for _, action := range client.Actions() {
+				if patch, ok := action.(core.PatchAction); ok {
+					patches = append(patches, patch)
+				}
+			}
+
+			if !test.expectFinalizing {
+				assert.Empty(t, patches)
+				return
+			}
+
+			require.Len(t, patches, 1)
+
+			var patch map[string]interface{}
+			require.NoError(t, json.Unmarshal(patches[0].GetPatch(), &patch))
+			assert.Equal(t, map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"finalizers": []interface{}{"other"},
+				},
+			}, patch)
+		})
+	}
+}
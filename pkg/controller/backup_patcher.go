@@ -0,0 +1,273 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
+	"github.com/heptio/ark/pkg/metrics"
+	kubeutil "github.com/heptio/ark/pkg/util/kube"
+)
+
+// BackupPatcher patches backups on behalf of all of the in-flight backups,
+// coalescing frequent updates to a backup so that they don't overwhelm the
+// API server, and limiting the rate of the patches it makes.
+type BackupPatcher interface {
+	// Patch patches original to match updated, and returns the result.
+	// Updates that change the backup's phase are patched immediately,
+	// along with any pending updates to the backup. Other updates may be
+	// held back and merged with later ones, in which case updated is
+	// returned as-is.
+	Patch(original, updated *api.Backup) (*api.Backup, error)
+
+	// PatchLatest immediately patches the latest version of the backup
+	// with the changes from original to updated, along with any pending
+	// updates to the backup, retrying if the backup's modified by someone
+	// else in the meantime. It's used for a backup's final update, which
+	// comes after its longest-running work.
+	PatchLatest(original, updated *api.Backup) (*api.Backup, error)
+
+	// Run flushes held back updates every interval until ctx is done,
+	// then flushes any that remain.
+	Run(ctx context.Context)
+}
+
+// latestPatchRetries is how many times PatchLatest retries a patch that
+// conflicts with someone else's update to the backup.
+const latestPatchRetries = 5
+
+type pendingBackupPatch struct {
+	// lock is held while the backup's patched, so that a phase change
+	// can't be patched before the updates that preceded it. It's not
+	// held while other backups are patched.
+	lock sync.Mutex
+
+	// users is the number of callers that are using the entry, so that
+	// it's only removed once none are. It's guarded by the patcher's
+	// lock.
+	users int
+
+	// original is the backup as it was last patched.
+	original *api.Backup
+	// updated is the most recent update to the backup that's been held
+	// back, or nil if there isn't one.
+	updated *api.Backup
+}
+
+type backupPatcher struct {
+	client   arkv1client.BackupsGetter
+	interval time.Duration
+	limiter  *rate.Limiter
+	metrics  *metrics.ServerMetrics
+	logger   logrus.FieldLogger
+
+	lock    sync.Mutex
+	pending map[string]*pendingBackupPatch
+}
+
+// NewBackupPatcher returns a new BackupPatcher that flushes held back
+// updates every interval, and makes at most qps patches per second, after
+// an initial burst. If interval is zero, every update is patched
+// immediately. If qps is zero, patches aren't limited.
+func NewBackupPatcher(client arkv1client.BackupsGetter, interval time.Duration, qps float64, burst int, metrics *metrics.ServerMetrics, logger logrus.FieldLogger) BackupPatcher {
+	limit := rate.Inf
+	if qps > 0 {
+		limit = rate.Limit(qps)
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &backupPatcher{
+		client:   client,
+		interval: interval,
+		limiter:  rate.NewLimiter(limit, burst),
+		metrics:  metrics,
+		logger:   logger,
+		pending:  make(map[string]*pendingBackupPatch),
+	}
+}
+
+// lockBackup returns the entry for the backup identified by key, creating
+// it if there isn't one, with its lock held.
+func (p *backupPatcher) lockBackup(key string) *pendingBackupPatch {
+	p.lock.Lock()
+	pending, ok := p.pending[key]
+	if !ok {
+		pending = &pendingBackupPatch{}
+		p.pending[key] = pending
+	}
+	pending.users++
+	p.lock.Unlock()
+
+	pending.lock.Lock()
+	return pending
+}
+
+// unlockBackup releases the lock on the backup's entry that was returned
+// by lockBackup, removing the entry if it's no longer used and has no
+// held back update.
+func (p *backupPatcher) unlockBackup(key string, pending *pendingBackupPatch) {
+	p.lock.Lock()
+	pending.users--
+	if pending.users == 0 && pending.updated == nil {
+		delete(p.pending, key)
+	}
+	p.lock.Unlock()
+
+	pending.lock.Unlock()
+}
+
+// wait blocks until the limiter allows another patch.
+func (p *backupPatcher) wait() error {
+	return errors.Wrap(p.limiter.Wait(context.Background()), "error waiting to patch backup")
+}
+
+func (p *backupPatcher) Patch(original, updated *api.Backup) (*api.Backup, error) {
+	if p.interval <= 0 {
+		if err := p.wait(); err != nil {
+			return nil, err
+		}
+		return patchBackup(original, updated, p.client)
+	}
+
+	key := kubeutil.NamespaceAndName(original)
+	pending := p.lockBackup(key)
+	defer p.unlockBackup(key, pending)
+
+	// original may itself be an update that's been held back, so the
+	// patch has to be from the backup as it was last patched.
+	if pending.updated != nil {
+		original = pending.original
+	}
+
+	if updated.Status.Phase != original.Status.Phase {
+		if err := p.wait(); err != nil {
+			return nil, err
+		}
+		// if the patch fails, any held back update is left to be
+		// flushed.
+		res, err := patchBackup(original, updated, p.client)
+		if err != nil {
+			return nil, err
+		}
+		pending.updated = nil
+		return res, nil
+	}
+
+	if pending.updated != nil {
+		p.metrics.RegisterBackupPatchCoalesced()
+	} else {
+		pending.original = original
+	}
+	pending.updated = updated
+
+	return updated, nil
+}
+
+func (p *backupPatcher) PatchLatest(original, updated *api.Backup) (*api.Backup, error) {
+	key := kubeutil.NamespaceAndName(original)
+	pending := p.lockBackup(key)
+	defer p.unlockBackup(key, pending)
+
+	if pending.updated != nil {
+		original = pending.original
+	}
+
+	if err := p.wait(); err != nil {
+		return nil, err
+	}
+	res, err := patchLatestBackup(original, updated, p.client, latestPatchRetries)
+	if err != nil {
+		return nil, err
+	}
+	pending.updated = nil
+
+	return res, nil
+}
+
+func (p *backupPatcher) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.flush()
+		case <-ctx.Done():
+			p.flush()
+			return
+		}
+	}
+}
+
+// flush patches all of the held back updates. Updates whose patches fail
+// with transient errors are kept, and retried the next time they're
+// flushed, so that later patches to the backup still include them.
+// Updates to backups that were deleted, or that are invalid, are dropped,
+// since retrying them would never succeed.
+func (p *backupPatcher) flush() {
+	p.lock.Lock()
+	keys := make([]string, 0, len(p.pending))
+	for key := range p.pending {
+		keys = append(keys, key)
+	}
+	p.lock.Unlock()
+
+	for _, key := range keys {
+		p.flushBackup(key)
+	}
+}
+
+func (p *backupPatcher) flushBackup(key string) {
+	pending := p.lockBackup(key)
+	defer p.unlockBackup(key, pending)
+
+	// the update may have been patched along with a phase change since
+	// the keys were listed.
+	if pending.updated == nil {
+		return
+	}
+
+	if err := p.wait(); err != nil {
+		p.logger.WithError(err).WithField("backup", key).Error("Error patching backup")
+		return
+	}
+	if _, err := patchBackup(pending.original, pending.updated, p.client); err != nil {
+		log := p.logger.WithError(err).WithField("backup", key)
+		if cause := errors.Cause(err); !apierrors.IsNotFound(cause) && !apierrors.IsInvalid(cause) {
+			log.Error("Error patching backup, will retry at the next flush")
+			return
+		}
+		log.Error("Error patching backup, dropping the update")
+	}
+	pending.updated = nil
+}
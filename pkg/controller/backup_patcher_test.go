@@ -0,0 +1,357 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	core "k8s.io/client-go/testing"
+
+	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
+	"github.com/heptio/ark/pkg/metrics"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestBackupPatcherCoalescesUpdatesWithinAPhase(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var patches []map[string]interface{}
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		patches = append(patches, patch)
+		return true, arktest.NewTestBackup().WithName("backup-1").Backup, nil
+	})
+
+	p := NewBackupPatcher(client.ArkV1(), time.Minute, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger()).(*backupPatcher)
+
+	original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+
+	// updates that don't change the phase are held back
+	first := original.DeepCopy()
+	first.Status.SkippedItems = 1
+	res, err := p.Patch(original, first)
+	require.NoError(t, err)
+	assert.Equal(t, first, res)
+
+	second := first.DeepCopy()
+	second.Status.SkippedItems = 2
+	_, err = p.Patch(first, second)
+	require.NoError(t, err)
+	assert.Empty(t, patches)
+
+	// a phase change is patched immediately, along with the held back updates
+	completed := second.DeepCopy()
+	completed.Status.Phase = v1.BackupPhaseCompleted
+	_, err = p.Patch(second, completed)
+	require.NoError(t, err)
+
+	require.Len(t, patches, 1)
+	assert.Equal(t, map[string]interface{}{
+		"status": map[string]interface{}{
+			"phase":        string(v1.BackupPhaseCompleted),
+			"skippedItems": float64(2),
+		},
+	}, patches[0])
+
+	// nothing is left to flush
+	p.flush()
+	assert.Len(t, patches, 1)
+}
+
+func TestBackupPatcherFlush(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var patchCount int
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patchCount++
+		return true, arktest.NewTestBackup().WithName("backup-1").Backup, nil
+	})
+
+	p := NewBackupPatcher(client.ArkV1(), time.Minute, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger()).(*backupPatcher)
+
+	original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+	updated := original.DeepCopy()
+	updated.Status.SkippedItems = 1
+
+	_, err := p.Patch(original, updated)
+	require.NoError(t, err)
+	assert.Equal(t, 0, patchCount)
+
+	p.flush()
+	assert.Equal(t, 1, patchCount)
+	assert.Empty(t, p.pending)
+}
+
+func TestBackupPatcherFlushRetriesFailedPatches(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var patches []map[string]interface{}
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		patches = append(patches, patch)
+
+		if len(patches) == 1 {
+			return true, nil, apierrors.NewInternalError(errors.New("etcd is unavailable"))
+		}
+		return true, arktest.NewTestBackup().WithName("backup-1").Backup, nil
+	})
+
+	p := NewBackupPatcher(client.ArkV1(), time.Minute, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger()).(*backupPatcher)
+
+	original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+	first := original.DeepCopy()
+	first.Status.SkippedItems = 1
+	_, err := p.Patch(original, first)
+	require.NoError(t, err)
+
+	// the failed update's kept for the next flush
+	p.flush()
+	require.Len(t, patches, 1)
+	assert.Len(t, p.pending, 1)
+
+	// later patches are still from the backup as it was last patched, so
+	// they include the update that failed
+	second := first.DeepCopy()
+	second.Status.ValidationErrors = []string{"warning"}
+	_, err = p.Patch(first, second)
+	require.NoError(t, err)
+
+	p.flush()
+	require.Len(t, patches, 2)
+	assert.Equal(t, map[string]interface{}{
+		"status": map[string]interface{}{
+			"skippedItems":     float64(1),
+			"validationErrors": []interface{}{"warning"},
+		},
+	}, patches[1])
+	assert.Empty(t, p.pending)
+}
+
+func TestBackupPatcherFlushDropsPermanentlyFailedPatches(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{
+			name: "backup not found",
+			err:  apierrors.NewNotFound(v1.Resource("backups"), "backup-1"),
+		},
+		{
+			name: "invalid update",
+			err:  apierrors.NewInvalid(v1.SchemeGroupVersion.WithKind("Backup").GroupKind(), "backup-1", nil),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+
+			var patchCount int
+			client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+				patchCount++
+				return true, nil, test.err
+			})
+
+			p := NewBackupPatcher(client.ArkV1(), time.Minute, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger()).(*backupPatcher)
+
+			original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+			updated := original.DeepCopy()
+			updated.Status.SkippedItems = 1
+			_, err := p.Patch(original, updated)
+			require.NoError(t, err)
+
+			p.flush()
+			assert.Equal(t, 1, patchCount)
+			assert.Empty(t, p.pending)
+
+			// the dropped update isn't retried
+			p.flush()
+			assert.Equal(t, 1, patchCount)
+		})
+	}
+}
+
+func TestBackupPatcherLimitsPatchRate(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var patchCount int
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patchCount++
+		return true, arktest.NewTestBackup().WithName("backup-1").Backup, nil
+	})
+
+	// after a burst of one, patches are made every 50ms
+	p := NewBackupPatcher(client.ArkV1(), 0, 20, 1, metrics.NewServerMetrics(), arktest.NewLogger())
+
+	original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+	updated := original.DeepCopy()
+	updated.Status.SkippedItems = 1
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := p.Patch(original, updated)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, patchCount)
+	assert.True(t, time.Since(start) >= 90*time.Millisecond, "patches weren't limited")
+}
+
+func TestBackupPatcherWithoutIntervalPatchesImmediately(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var patchCount int
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patchCount++
+		return true, arktest.NewTestBackup().WithName("backup-1").Backup, nil
+	})
+
+	p := NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger())
+
+	original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).Backup
+	updated := original.DeepCopy()
+	updated.Status.SkippedItems = 1
+
+	_, err := p.Patch(original, updated)
+	require.NoError(t, err)
+	assert.Equal(t, 1, patchCount)
+}
+
+func TestBackupPatcherPatchLatestRetriesConflicts(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	// another controller labels the backup while it's running, so the
+	// first patch, which is conditional on the backup's resource version
+	// when it started running, conflicts.
+	latest := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).WithResourceVersion("2").
+		WithLabel("team", "payments").Backup
+
+	var patches []map[string]interface{}
+	client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		patch := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+		patches = append(patches, patch)
+
+		if len(patches) == 1 {
+			return true, nil, apierrors.NewConflict(v1.SchemeGroupVersion.WithResource("backups").GroupResource(), "backup-1", errors.New("the object has been modified"))
+		}
+		return true, latest, nil
+	})
+	var gets int
+	client.PrependReactor("get", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		gets++
+		return true, latest, nil
+	})
+
+	p := NewBackupPatcher(client.ArkV1(), time.Minute, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger())
+
+	original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).WithResourceVersion("1").Backup
+
+	// a held back update is patched along with the final one
+	progress := original.DeepCopy()
+	progress.Status.SkippedItems = 1
+	_, err := p.Patch(original, progress)
+	require.NoError(t, err)
+
+	completed := progress.DeepCopy()
+	completed.Status.Phase = v1.BackupPhaseCompleted
+	_, err = p.PatchLatest(progress, completed)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, gets)
+	require.Len(t, patches, 2)
+
+	expectedStatus := map[string]interface{}{
+		"phase":        string(v1.BackupPhaseCompleted),
+		"skippedItems": float64(1),
+	}
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": "1"},
+		"status":   expectedStatus,
+	}, patches[0])
+
+	// the retry is against the latest version, so it doesn't undo the
+	// label that was added.
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": "2"},
+		"status":   expectedStatus,
+	}, patches[1])
+
+	// nothing is left to flush
+	p.(*backupPatcher).flush()
+	assert.Len(t, patches, 2)
+}
+
+func TestBackupPatcherPatchLatestErrors(t *testing.T) {
+	conflict := apierrors.NewConflict(v1.SchemeGroupVersion.WithResource("backups").GroupResource(), "backup-1", errors.New("the object has been modified"))
+
+	tests := []struct {
+		name            string
+		patchErr        error
+		expectedPatches int
+	}{
+		{
+			name:            "conflicts are retried a bounded number of times",
+			patchErr:        conflict,
+			expectedPatches: latestPatchRetries + 1,
+		},
+		{
+			name:            "other errors aren't retried",
+			patchErr:        apierrors.NewInternalError(errors.New("etcd is unavailable")),
+			expectedPatches: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+
+			latest := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).WithResourceVersion("2").Backup
+
+			var patchCount int
+			client.PrependReactor("patch", "backups", func(action core.Action) (bool, runtime.Object, error) {
+				patchCount++
+				return true, nil, test.patchErr
+			})
+			client.PrependReactor("get", "backups", func(action core.Action) (bool, runtime.Object, error) {
+				return true, latest, nil
+			})
+
+			p := NewBackupPatcher(client.ArkV1(), 0, 0, 0, metrics.NewServerMetrics(), arktest.NewLogger())
+
+			original := arktest.NewTestBackup().WithName("backup-1").WithPhase(v1.BackupPhaseInProgress).WithResourceVersion("1").Backup
+			completed := original.DeepCopy()
+			completed.Status.Phase = v1.BackupPhaseCompleted
+
+			_, err := p.PatchLatest(original, completed)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "error patching backup")
+			assert.Equal(t, test.expectedPatches, patchCount)
+		})
+	}
+}
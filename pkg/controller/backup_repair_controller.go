@@ -0,0 +1,182 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
+	informers "github.com/heptio/ark/pkg/generated/informers/externalversions/ark/v1"
+	listers "github.com/heptio/ark/pkg/generated/listers/ark/v1"
+	"github.com/heptio/ark/pkg/persistence"
+	"github.com/heptio/ark/pkg/plugin"
+)
+
+// backupRepairController repairs the backups that have the
+// RepairRequestedAnnotation, re-uploading whichever of their artifacts are
+// missing from their storage locations and can be reconstructed, and
+// records the outcome in their RepairResultAnnotation.
+type backupRepairController struct {
+	*genericController
+
+	backupLister         listers.BackupLister
+	backupClient         arkv1client.BackupsGetter
+	backupLocationLister listers.BackupStorageLocationLister
+	newPluginManager     func(logrus.FieldLogger) plugin.Manager
+	newBackupStore       func(*arkv1api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)
+}
+
+// NewBackupRepairController constructs a new backupRepairController.
+func NewBackupRepairController(
+	logger logrus.FieldLogger,
+	backupInformer informers.BackupInformer,
+	backupClient arkv1client.BackupsGetter,
+	backupLocationInformer informers.BackupStorageLocationInformer,
+	newPluginManager func(logrus.FieldLogger) plugin.Manager,
+	encryptionKeys persistence.EncryptionKeyGetter,
+) Interface {
+	c := &backupRepairController{
+		genericController:    newGenericController("backup-repair", logger),
+		backupLister:         backupInformer.Lister(),
+		backupClient:         backupClient,
+		backupLocationLister: backupLocationInformer.Lister(),
+
+		// use variables to refer to these functions so they can be
+		// replaced with fakes for testing.
+		newPluginManager: newPluginManager,
+		newBackupStore:   persistence.NewBackupStoreFactory(encryptionKeys),
+	}
+
+	c.syncHandler = c.processQueueItem
+	c.cacheSyncWaiters = append(c.cacheSyncWaiters,
+		backupInformer.Informer().HasSynced,
+		backupLocationInformer.Informer().HasSynced,
+	)
+
+	backupInformer.Informer().AddEventHandler(
+		cache.FilteringResourceEventHandler{
+			FilterFunc: func(obj interface{}) bool {
+				backup, ok := obj.(*arkv1api.Backup)
+				return ok && backup.Annotations[arkv1api.RepairRequestedAnnotation] != ""
+			},
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc:    c.enqueue,
+				UpdateFunc: c.enqueueSecond,
+			},
+		},
+	)
+
+	return c
+}
+
+func (c *backupRepairController) processQueueItem(key string) error {
+	log := c.logger.WithField("backup", key)
+
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return newPermanentError(errors.Wrap(err, "error splitting queue key"))
+	}
+
+	backup, err := c.backupLister.Backups(ns).Get(name)
+	if apierrors.IsNotFound(err) {
+		log.Debug("Unable to find backup")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error getting backup")
+	}
+
+	if backup.Annotations[arkv1api.RepairRequestedAnnotation] == "" {
+		return nil
+	}
+
+	var result string
+	switch backup.Status.Phase {
+	case arkv1api.BackupPhaseCompleted, arkv1api.BackupPhaseCompletedEmpty, arkv1api.BackupPhasePartiallyFailed:
+		log.Info("Repairing backup")
+		res, err := c.repairBackup(backup, log)
+		if err != nil {
+			return err
+		}
+		result = formatBackupRepairResult(res)
+		log.Infof("Repaired backup: %s", result)
+	default:
+		// the artifacts of backups that haven't finished may still be
+		// being uploaded, and those of failed backups were never all
+		// uploaded.
+		result = fmt.Sprintf("not repaired, because the backup's phase is %s", backup.Status.Phase)
+		log.Info("Not repairing backup, because it hasn't completed")
+	}
+
+	updated := backup.DeepCopy()
+	delete(updated.Annotations, arkv1api.RepairRequestedAnnotation)
+	updated.Annotations[arkv1api.RepairResultAnnotation] = result
+	if _, err := patchBackup(backup, updated, c.backupClient); err != nil {
+		return errors.Wrap(err, "error recording backup's repair result")
+	}
+
+	return nil
+}
+
+// repairBackup repairs the backup's artifacts in its storage location.
+func (c *backupRepairController) repairBackup(backup *arkv1api.Backup, log logrus.FieldLogger) (*persistence.BackupRepairResult, error) {
+	location, err := c.backupLocationLister.BackupStorageLocations(backup.Namespace).Get(backup.Spec.StorageLocation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting backup storage location %s", backup.Spec.StorageLocation)
+	}
+
+	pluginManager := c.newPluginManager(log)
+	defer pluginManager.CleanupClients()
+
+	backupStore, err := c.newBackupStore(location, pluginManager, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return persistence.RepairBackup(backupStore, backup.Name, backup)
+}
+
+// formatBackupRepairResult describes res for the RepairResultAnnotation.
+func formatBackupRepairResult(res *persistence.BackupRepairResult) string {
+	join := func(artifacts []persistence.BackupArtifact) string {
+		names := make([]string, 0, len(artifacts))
+		for _, artifact := range artifacts {
+			names = append(names, string(artifact))
+		}
+		return strings.Join(names, ", ")
+	}
+
+	var parts []string
+	if len(res.Repaired) > 0 {
+		parts = append(parts, "repaired: "+join(res.Repaired))
+	}
+	if len(res.Unrecoverable) > 0 {
+		parts = append(parts, "unrecoverable: "+join(res.Unrecoverable))
+	}
+	if len(parts) == 0 {
+		return "nothing to repair"
+	}
+	return strings.Join(parts, "; ")
+}
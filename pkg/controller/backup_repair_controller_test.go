@@ -0,0 +1,139 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	core "k8s.io/client-go/testing"
+
+	"github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
+	informers "github.com/heptio/ark/pkg/generated/informers/externalversions"
+	"github.com/heptio/ark/pkg/persistence"
+	persistencemocks "github.com/heptio/ark/pkg/persistence/mocks"
+	"github.com/heptio/ark/pkg/plugin"
+	pluginmocks "github.com/heptio/ark/pkg/plugin/mocks"
+	"github.com/heptio/ark/pkg/util/kube"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestBackupRepairControllerProcessQueueItem(t *testing.T) {
+	tests := []struct {
+		name           string
+		backup         *v1.Backup
+		expectRepair   bool
+		expectedResult string
+	}{
+		{
+			name:   "backup without a repair request is ignored",
+			backup: arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithPhase(v1.BackupPhaseCompleted).Backup,
+		},
+		{
+			name: "completed backup is repaired",
+			backup: arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithPhase(v1.BackupPhaseCompleted).
+				WithAnnotation(v1.RepairRequestedAnnotation, "true").Backup,
+			expectRepair:   true,
+			expectedResult: "repaired: summary; unrecoverable: log",
+		},
+		{
+			name: "in-progress backup isn't repaired",
+			backup: arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("loc-1").WithPhase(v1.BackupPhaseInProgress).
+				WithAnnotation(v1.RepairRequestedAnnotation, "true").Backup,
+			expectedResult: "not repaired, because the backup's phase is InProgress",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				client          = fake.NewSimpleClientset(test.backup)
+				sharedInformers = informers.NewSharedInformerFactory(client, 0)
+				pluginManager   = &pluginmocks.Manager{}
+				backupStore     = &persistencemocks.BackupStore{}
+			)
+
+			controller := NewBackupRepairController(
+				arktest.NewLogger(),
+				sharedInformers.Ark().V1().Backups(),
+				client.ArkV1(),
+				sharedInformers.Ark().V1().BackupStorageLocations(),
+				func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				nil,
+			).(*backupRepairController)
+
+			controller.newBackupStore = func(*v1.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+				return backupStore, nil
+			}
+
+			pluginManager.On("CleanupClients").Return(nil)
+			// the backup's summary and log are missing
+			backupStore.On("ListBackupArtifacts", test.backup.Name).Return([]persistence.BackupArtifact{
+				persistence.BackupArtifactContents,
+				persistence.BackupArtifactMetadata,
+				persistence.BackupArtifactChecksum,
+				persistence.BackupArtifactContentIndex,
+				persistence.BackupArtifactCompletionMarker,
+			}, nil)
+			backupStore.On("GetBackupMetadata", test.backup.Name).Return(test.backup, nil)
+			backupStore.On("PutBackupSummary", test.backup.Name, mock.Anything).Return(nil)
+
+			sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(test.backup)
+			location := arktest.NewTestBackupStorageLocation().WithNamespace(test.backup.Namespace).WithName("loc-1").BackupStorageLocation
+			sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location)
+
+			require.NoError(t, controller.processQueueItem(kube.NamespaceAndName(test.backup)))
+
+			if test.expectRepair {
+				backupStore.AssertCalled(t, "PutBackupSummary", test.backup.Name, mock.Anything)
+			} else {
+				backupStore.AssertNotCalled(t, "ListBackupArtifacts", test.backup.Name)
+			}
+
+			var patches []core.PatchAction
+			for _, action := range client.Actions() {
+				if patch, ok := action.(core.PatchAction); ok {
+					patches = append(patches, patch)
+				}
+			}
+
+			if test.expectedResult == "" {
+				assert.Empty(t, patches)
+				return
+			}
+
+			require.Len(t, patches, 1)
+
+			var patch map[string]interface{}
+			require.NoError(t, json.Unmarshal(patches[0].GetPatch(), &patch))
+			assert.Equal(t, map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						v1.RepairRequestedAnnotation: nil,
+						v1.RepairResultAnnotation:    test.expectedResult,
+					},
+				},
+			}, patch)
+		})
+	}
+}
@@ -48,6 +48,7 @@ type backupSyncController struct {
 	backupStorageLocationLister listers.BackupStorageLocationLister
 	namespace                   string
 	defaultBackupLocation       string
+	backupFilter                persistence.BackupFilter
 	newPluginManager            func(logrus.FieldLogger) plugin.Manager
 	newBackupStore              func(*arkv1api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)
 }
@@ -60,7 +61,9 @@ func NewBackupSyncController(
 	syncPeriod time.Duration,
 	namespace string,
 	defaultBackupLocation string,
+	backupFilter persistence.BackupFilter,
 	newPluginManager func(logrus.FieldLogger) plugin.Manager,
+	encryptionKeys persistence.EncryptionKeyGetter,
 	logger logrus.FieldLogger,
 ) Interface {
 	if syncPeriod < time.Minute {
@@ -74,13 +77,14 @@ func NewBackupSyncController(
 		backupLocationClient:        backupLocationClient,
 		namespace:                   namespace,
 		defaultBackupLocation:       defaultBackupLocation,
+		backupFilter:                backupFilter,
 		backupLister:                backupInformer.Lister(),
 		backupStorageLocationLister: backupStorageLocationInformer.Lister(),
 
 		// use variables to refer to these functions so they can be
 		// replaced with fakes for testing.
 		newPluginManager: newPluginManager,
-		newBackupStore:   persistence.NewObjectBackupStore,
+		newBackupStore:   persistence.NewBackupStoreFactory(encryptionKeys),
 	}
 
 	c.resyncFunc = c.run
@@ -179,9 +183,32 @@ func (c *backupSyncController) run() {
 				continue
 			}
 
+			if reason := c.backupFilter.Exclude(backup); reason != "" {
+				log.Debugf("Not syncing backup because %s", reason)
+				continue
+			}
+
+			// don't sync backups that are still being uploaded, or whose upload
+			// was interrupted. The former are synced once their upload is done.
+			complete, err := persistence.IsBackupComplete(backupStore, backup)
+			if err != nil {
+				log.WithError(errors.WithStack(err)).Error("Error checking whether backup is complete in backup store")
+				continue
+			}
+			if !complete {
+				log.Warn("Not syncing backup since it's incomplete in backup store")
+				continue
+			}
+
 			// remove the pre-v0.8.0 gcFinalizer if it exists
 			// TODO(1.0): remove this
 			backup.Finalizers = stringslice.Except(backup.Finalizers, gcFinalizer)
+			// a synced backup's data isn't deleted when it's removed from
+			// this cluster, since it may not be the cluster that took it.
+			backup.Finalizers = stringslice.Except(backup.Finalizers, arkv1api.DeleteBackupStorageFinalizer)
+			// nor are its owners, which would garbage-collect it again if it
+			// was removed along with them.
+			backup.OwnerReferences = nil
 			backup.Namespace = c.namespace
 			backup.ResourceVersion = ""
 
@@ -233,7 +260,7 @@ func (c *backupSyncController) run() {
 }
 
 // deleteOrphanedBackups deletes backup objects from Kubernetes that have the specified location
-// and a phase of Completed, but no corresponding backup in object storage.
+// and a phase of Completed or PartiallyFailed, but no corresponding backup in object storage.
 func (c *backupSyncController) deleteOrphanedBackups(locationName string, cloudBackupNames sets.String, log logrus.FieldLogger) {
 	locationSelector := labels.Set(map[string]string{
 		arkv1api.StorageLocationLabel: locationName,
@@ -250,7 +277,7 @@ func (c *backupSyncController) deleteOrphanedBackups(locationName string, cloudB
 
 	for _, backup := range backups {
 		log = log.WithField("backup", backup.Name)
-		if backup.Status.Phase != arkv1api.BackupPhaseCompleted || cloudBackupNames.Has(backup.Name) {
+		if (backup.Status.Phase != arkv1api.BackupPhaseCompleted && backup.Status.Phase != arkv1api.BackupPhaseCompletedEmpty && backup.Status.Phase != arkv1api.BackupPhasePartiallyFailed) || cloudBackupNames.Has(backup.Name) {
 			continue
 		}
 
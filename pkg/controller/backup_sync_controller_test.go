@@ -25,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	kuberrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
@@ -110,6 +111,17 @@ func TestBackupSyncControllerRun(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "owner references get removed on sync",
+			namespace: "ns-1",
+			locations: defaultLocationsList("ns-1"),
+			cloudBackups: map[string][]*arkv1api.Backup{
+				"bucket-1": {
+					arktest.NewTestBackup().WithNamespace("ns-1").WithName("backup-1").
+						WithOwnerReferences(metav1.OwnerReference{APIVersion: "example.com/v1", Kind: "Application", Name: "app-1", UID: "uid-1"}).Backup,
+				},
+			},
+		},
 		{
 			name:      "all synced backups get created in Ark server's namespace",
 			namespace: "heptio-ark",
@@ -179,7 +191,9 @@ func TestBackupSyncControllerRun(t *testing.T) {
 				time.Duration(0),
 				test.namespace,
 				"",
+				persistence.BackupFilter{},
 				func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				nil,
 				arktest.NewLogger(),
 			).(*backupSyncController)
 
@@ -241,6 +255,9 @@ func TestBackupSyncControllerRun(t *testing.T) {
 						// verify that the GC finalizer is removed
 						assert.Equal(t, stringslice.Except(cloudBackup.Finalizers, gcFinalizer), obj.Finalizers)
 
+						// verify that the owner references are removed
+						assert.Empty(t, obj.OwnerReferences)
+
 						// verify that the storage location field and label are set properly
 						for _, location := range test.locations {
 							if location.Spec.ObjectStorage.Bucket == bucket {
@@ -256,6 +273,108 @@ func TestBackupSyncControllerRun(t *testing.T) {
 	}
 }
 
+func TestBackupSyncControllerSkipsIncompleteBackups(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+		location        = defaultLocationsList("ns-1")[0]
+	)
+
+	c := NewBackupSyncController(
+		client.ArkV1(),
+		client.ArkV1(),
+		sharedInformers.Ark().V1().Backups(),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		time.Duration(0),
+		"ns-1",
+		"",
+		persistence.BackupFilter{},
+		func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		nil,
+		arktest.NewLogger(),
+	).(*backupSyncController)
+
+	c.newBackupStore = func(*arkv1api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	pluginManager.On("CleanupClients").Return(nil)
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	complete := arktest.NewTestBackup().WithNamespace("ns-1").WithName("complete").WithAnnotation(arkv1api.CompletionMarkerAnnotation, "true").Backup
+	incomplete := arktest.NewTestBackup().WithNamespace("ns-1").WithName("incomplete").WithAnnotation(arkv1api.CompletionMarkerAnnotation, "true").Backup
+	legacy := arktest.NewTestBackup().WithNamespace("ns-1").WithName("legacy").Backup
+
+	backupStore.On("GetRevision").Return("foo", nil)
+	backupStore.On("ListBackups").Return([]string{"complete", "incomplete", "legacy"}, nil)
+	for _, backup := range []*arkv1api.Backup{complete, incomplete, legacy} {
+		backupStore.On("GetBackupMetadata", backup.Name).Return(backup, nil)
+	}
+	backupStore.On("ListBackupArtifacts", "complete").Return([]persistence.BackupArtifact{persistence.BackupArtifactMetadata, persistence.BackupArtifactContents, persistence.BackupArtifactCompletionMarker}, nil)
+	backupStore.On("ListBackupArtifacts", "incomplete").Return([]persistence.BackupArtifact{persistence.BackupArtifactMetadata}, nil)
+
+	c.run()
+
+	_, err := client.ArkV1().Backups("ns-1").Get("complete", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	_, err = client.ArkV1().Backups("ns-1").Get("legacy", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	_, err = client.ArkV1().Backups("ns-1").Get("incomplete", metav1.GetOptions{})
+	assert.True(t, kuberrs.IsNotFound(err))
+}
+
+func TestBackupSyncControllerFiltersBackups(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+		location        = defaultLocationsList("ns-1")[0]
+	)
+
+	c := NewBackupSyncController(
+		client.ArkV1(),
+		client.ArkV1(),
+		sharedInformers.Ark().V1().Backups(),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		time.Duration(0),
+		"ns-1",
+		"",
+		persistence.BackupFilter{ClusterName: "cluster-1"},
+		func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+		nil,
+		arktest.NewLogger(),
+	).(*backupSyncController)
+
+	c.newBackupStore = func(*arkv1api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	pluginManager.On("CleanupClients").Return(nil)
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(location))
+
+	own := arktest.NewTestBackup().WithNamespace("ns-1").WithName("own").WithLabel(arkv1api.ClusterNameLabel, "cluster-1").Backup
+	other := arktest.NewTestBackup().WithNamespace("ns-1").WithName("other").WithLabel(arkv1api.ClusterNameLabel, "cluster-2").Backup
+
+	backupStore.On("GetRevision").Return("foo", nil)
+	backupStore.On("ListBackups").Return([]string{"own", "other"}, nil)
+	for _, backup := range []*arkv1api.Backup{own, other} {
+		backupStore.On("GetBackupMetadata", backup.Name).Return(backup, nil)
+	}
+
+	c.run()
+
+	_, err := client.ArkV1().Backups("ns-1").Get("own", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	_, err = client.ArkV1().Backups("ns-1").Get("other", metav1.GetOptions{})
+	assert.True(t, kuberrs.IsNotFound(err))
+}
+
 func TestDeleteOrphanedBackups(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -353,7 +472,9 @@ func TestDeleteOrphanedBackups(t *testing.T) {
 				time.Duration(0),
 				test.namespace,
 				"",
+				persistence.BackupFilter{},
 				nil, // new plugin manager func
+				nil,
 				arktest.NewLogger(),
 			).(*backupSyncController)
 
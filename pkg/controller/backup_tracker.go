@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -27,21 +28,40 @@ import (
 type BackupTracker interface {
 	// Add informs the tracker that a backup is in progress.
 	Add(ns, name string)
+	// AddIfAbsent informs the tracker that a backup is in progress, unless
+	// it's already being tracked. It returns true if the backup was added.
+	AddIfAbsent(ns, name string) bool
 	// Delete informs the tracker that a backup is no longer in progress.
 	Delete(ns, name string)
 	// Contains returns true if the tracker is tracking the backup.
 	Contains(ns, name string) bool
+	// List returns the sorted namespace/name keys of the tracked backups.
+	List() []string
+	// SetCancelFunc records the function that cancels a tracked backup.
+	SetCancelFunc(ns, name string, cancel context.CancelFunc)
+	// Cancel cancels a tracked backup. It returns true if the backup is
+	// being tracked and has a cancel function.
+	Cancel(ns, name string) bool
+	// SetLog records the log that a tracked backup is writing.
+	SetLog(ns, name string, log *LiveLog)
+	// Log returns the log that a tracked backup is writing, and whether
+	// it has one.
+	Log(ns, name string) (*LiveLog, bool)
 }
 
 type backupTracker struct {
-	lock    sync.RWMutex
-	backups sets.String
+	lock        sync.RWMutex
+	backups     sets.String
+	cancelFuncs map[string]context.CancelFunc
+	logs        map[string]*LiveLog
 }
 
 // NewBackupTracker returns a new BackupTracker.
 func NewBackupTracker() BackupTracker {
 	return &backupTracker{
-		backups: sets.NewString(),
+		backups:     sets.NewString(),
+		cancelFuncs: make(map[string]context.CancelFunc),
+		logs:        make(map[string]*LiveLog),
 	}
 }
 
@@ -52,11 +72,27 @@ func (bt *backupTracker) Add(ns, name string) {
 	bt.backups.Insert(backupTrackerKey(ns, name))
 }
 
+func (bt *backupTracker) AddIfAbsent(ns, name string) bool {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	key := backupTrackerKey(ns, name)
+	if bt.backups.Has(key) {
+		return false
+	}
+	bt.backups.Insert(key)
+
+	return true
+}
+
 func (bt *backupTracker) Delete(ns, name string) {
 	bt.lock.Lock()
 	defer bt.lock.Unlock()
 
-	bt.backups.Delete(backupTrackerKey(ns, name))
+	key := backupTrackerKey(ns, name)
+	bt.backups.Delete(key)
+	delete(bt.cancelFuncs, key)
+	delete(bt.logs, key)
 }
 
 func (bt *backupTracker) Contains(ns, name string) bool {
@@ -66,6 +102,54 @@ func (bt *backupTracker) Contains(ns, name string) bool {
 	return bt.backups.Has(backupTrackerKey(ns, name))
 }
 
+func (bt *backupTracker) List() []string {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
+
+	return bt.backups.List()
+}
+
+func (bt *backupTracker) SetCancelFunc(ns, name string, cancel context.CancelFunc) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	key := backupTrackerKey(ns, name)
+	if bt.backups.Has(key) {
+		bt.cancelFuncs[key] = cancel
+	}
+}
+
+func (bt *backupTracker) Cancel(ns, name string) bool {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
+
+	cancel, ok := bt.cancelFuncs[backupTrackerKey(ns, name)]
+	if !ok {
+		return false
+	}
+	cancel()
+
+	return true
+}
+
+func (bt *backupTracker) SetLog(ns, name string, log *LiveLog) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	key := backupTrackerKey(ns, name)
+	if bt.backups.Has(key) {
+		bt.logs[key] = log
+	}
+}
+
+func (bt *backupTracker) Log(ns, name string) (*LiveLog, bool) {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
+
+	log, ok := bt.logs[backupTrackerKey(ns, name)]
+	return log, ok
+}
+
 func backupTrackerKey(ns, name string) string {
 	return fmt.Sprintf("%s/%s", ns, name)
 }
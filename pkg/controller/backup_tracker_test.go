@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -41,3 +42,57 @@ func TestBackupTracker(t *testing.T) {
 	bt.Delete("ns2", "name2")
 	assert.False(t, bt.Contains("ns2", "name2"))
 }
+
+func TestBackupTrackerAddIfAbsent(t *testing.T) {
+	bt := NewBackupTracker()
+
+	assert.True(t, bt.AddIfAbsent("ns", "name"))
+	assert.True(t, bt.Contains("ns", "name"))
+	assert.False(t, bt.AddIfAbsent("ns", "name"))
+
+	bt.Delete("ns", "name")
+	assert.True(t, bt.AddIfAbsent("ns", "name"))
+}
+
+func TestBackupTrackerCancel(t *testing.T) {
+	bt := NewBackupTracker()
+
+	// untracked backups can't be cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bt.SetCancelFunc("ns", "name", cancel)
+	assert.False(t, bt.Cancel("ns", "name"))
+
+	bt.Add("ns", "name")
+	assert.False(t, bt.Cancel("ns", "name"))
+
+	bt.SetCancelFunc("ns", "name", cancel)
+	assert.True(t, bt.Cancel("ns", "name"))
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	// deleting a backup forgets its cancel function
+	bt.Delete("ns", "name")
+	bt.Add("ns", "name")
+	assert.False(t, bt.Cancel("ns", "name"))
+}
+
+func TestBackupTrackerLog(t *testing.T) {
+	bt := NewBackupTracker()
+	log := NewLiveLog()
+
+	// untracked backups can't have logs
+	bt.SetLog("ns", "name", log)
+	_, ok := bt.Log("ns", "name")
+	assert.False(t, ok)
+
+	bt.Add("ns", "name")
+	bt.SetLog("ns", "name", log)
+	got, ok := bt.Log("ns", "name")
+	assert.True(t, ok)
+	assert.Equal(t, log, got)
+
+	// deleting a backup forgets its log
+	bt.Delete("ns", "name")
+	_, ok = bt.Log("ns", "name")
+	assert.False(t, ok)
+}
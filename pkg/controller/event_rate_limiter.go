@@ -0,0 +1,74 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// eventRateLimiter limits how often events are recorded about each object,
+// so that an object that's processed again and again in quick succession
+// doesn't flood its namespace's events. Each object can have burst events
+// recorded at once, and one more every interval after that.
+type eventRateLimiter struct {
+	burst    int
+	interval time.Duration
+
+	lock    sync.Mutex
+	buckets map[string]*eventBucket
+}
+
+type eventBucket struct {
+	limiter *rate.Limiter
+	last    time.Time
+}
+
+func newEventRateLimiter(burst int, interval time.Duration) *eventRateLimiter {
+	return &eventRateLimiter{
+		burst:    burst,
+		interval: interval,
+		buckets:  make(map[string]*eventBucket),
+	}
+}
+
+// allow returns whether an event about the object identified by key can be
+// recorded at now.
+func (l *eventRateLimiter) allow(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// a bucket that's had time to refill is the same as a new one, so it's
+	// dropped, so that the buckets of deleted objects don't accumulate.
+	refill := time.Duration(l.burst) * l.interval
+	for k, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, k)
+		}
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &eventBucket{limiter: rate.NewLimiter(rate.Every(l.interval), l.burst)}
+		l.buckets[key] = bucket
+	}
+	bucket.last = now
+
+	return bucket.limiter.AllowN(now, 1)
+}
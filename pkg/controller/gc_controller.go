@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"sort"
 	"time"
 
 	pkgbackup "github.com/heptio/ark/pkg/backup"
@@ -32,6 +33,7 @@ import (
 	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions/ark/v1"
 	listers "github.com/heptio/ark/pkg/generated/listers/ark/v1"
+	"github.com/heptio/ark/pkg/metrics"
 )
 
 const (
@@ -45,16 +47,21 @@ type gcController struct {
 	backupLister              listers.BackupLister
 	deleteBackupRequestLister listers.DeleteBackupRequestLister
 	deleteBackupRequestClient arkv1client.DeleteBackupRequestsGetter
+	gracePeriod               time.Duration
+	metrics                   *metrics.ServerMetrics
 
 	clock clock.Clock
 }
 
-// NewGCController constructs a new gcController.
+// NewGCController constructs a new gcController. Backups are deleted once
+// gracePeriod has passed since they expired.
 func NewGCController(
 	logger logrus.FieldLogger,
 	backupInformer informers.BackupInformer,
 	deleteBackupRequestInformer informers.DeleteBackupRequestInformer,
 	deleteBackupRequestClient arkv1client.DeleteBackupRequestsGetter,
+	gracePeriod time.Duration,
+	metrics *metrics.ServerMetrics,
 ) Interface {
 	c := &gcController{
 		genericController:         newGenericController("gc-controller", logger),
@@ -62,6 +69,8 @@ func NewGCController(
 		backupLister:              backupInformer.Lister(),
 		deleteBackupRequestLister: deleteBackupRequestInformer.Lister(),
 		deleteBackupRequestClient: deleteBackupRequestClient,
+		gracePeriod:               gracePeriod,
+		metrics:                   metrics,
 	}
 
 	c.syncHandler = c.processQueueItem
@@ -77,12 +86,30 @@ func NewGCController(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc:    c.enqueue,
 			UpdateFunc: func(_, obj interface{}) { c.enqueue(obj) },
+			DeleteFunc: c.enqueueBaseBackup,
 		},
 	)
 
 	return c
 }
 
+// enqueueBaseBackup enqueues the base backup of a deleted incremental
+// backup, so that the base is deleted, if it's expired, once no other
+// backups depend on it.
+func (c *gcController) enqueueBaseBackup(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	backup, ok := obj.(*arkv1api.Backup)
+	if !ok {
+		return
+	}
+
+	if base := backup.Labels[arkv1api.BaseBackupNameLabel]; base != "" {
+		c.queue.Add(backup.Namespace + "/" + base)
+	}
+}
+
 // enqueueAllBackups lists all backups from cache and enqueues all of them so we can check each one
 // for expiration.
 func (c *gcController) enqueueAllBackups() {
@@ -131,6 +158,32 @@ func (c *gcController) processQueueItem(key string) error {
 		return nil
 	}
 
+	// the grace period allows for the server's clock being ahead of the
+	// one the expiration was computed with, as well as for backups that
+	// should be kept around for a while after they expire.
+	if expiration.Add(c.gracePeriod).After(now) {
+		log.Debug("Backup is within its grace period after expiring, skipping")
+		return nil
+	}
+
+	// a backup's objects can't be deleted while they're locked, so it's
+	// deleted on the first resync after its object lock expires instead.
+	if lockExpiration := backup.Status.ObjectLockExpiration.Time; lockExpiration.After(now) {
+		log.WithField("objectLockExpiration", lockExpiration).Info("Backup has expired, but its objects are locked until its object lock expires, skipping")
+		return nil
+	}
+
+	// incremental backups can't be restored without their base, so it's
+	// kept until they've all been deleted.
+	dependents, err := c.backupLister.Backups(ns).List(labels.SelectorFromSet(labels.Set{arkv1api.BaseBackupNameLabel: backup.Name}))
+	if err != nil {
+		return errors.Wrap(err, "error listing incremental backups of backup")
+	}
+	if names := incrementalDependents(backup, dependents); len(names) > 0 {
+		log.WithField("dependents", names).Info("Backup has expired, but incremental backups taken from it depend on it, skipping")
+		return nil
+	}
+
 	log.Info("Backup has expired")
 
 	selector := labels.SelectorFromSet(labels.Set(map[string]string{
@@ -160,5 +213,33 @@ func (c *gcController) processQueueItem(key string) error {
 		return errors.Wrap(err, "error creating DeleteBackupRequest")
 	}
 
+	c.metrics.RegisterBackupExpiredDeletion(backup.GetLabels()["ark-schedule"])
+
 	return nil
 }
+
+// incrementalDependents returns the sorted names of the backups in backups
+// that are incremental backups taken from base and that can still be
+// restored, so base can't be deleted while they exist. Backups that failed,
+// or are being deleted, can't be restored, so they don't depend on base.
+func incrementalDependents(base *arkv1api.Backup, backups []*arkv1api.Backup) []string {
+	var names []string
+	for _, backup := range backups {
+		if backup.Namespace != base.Namespace || backup.Spec.BaseBackup != base.Name {
+			continue
+		}
+
+		switch backup.Status.Phase {
+		case arkv1api.BackupPhaseFailedValidation, arkv1api.BackupPhaseFailed, arkv1api.BackupPhaseDeleting:
+			continue
+		}
+		if backup.DeletionTimestamp != nil {
+			continue
+		}
+
+		names = append(names, backup.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
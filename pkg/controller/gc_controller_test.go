@@ -32,10 +32,12 @@ import (
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/watch"
 	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
 	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions"
+	"github.com/heptio/ark/pkg/metrics"
 	"github.com/heptio/ark/pkg/util/kube"
 	arktest "github.com/heptio/ark/pkg/util/test"
 )
@@ -50,6 +52,8 @@ func TestGCControllerEnqueueAllBackups(t *testing.T) {
 			sharedInformers.Ark().V1().Backups(),
 			sharedInformers.Ark().V1().DeleteBackupRequests(),
 			client.ArkV1(),
+			0,
+			metrics.NewServerMetrics(),
 		).(*gcController)
 	)
 
@@ -113,6 +117,8 @@ func TestGCControllerHasUpdateFunc(t *testing.T) {
 		sharedInformers.Ark().V1().Backups(),
 		sharedInformers.Ark().V1().DeleteBackupRequests(),
 		client.ArkV1(),
+		0,
+		metrics.NewServerMetrics(),
 	).(*gcController)
 
 	keys := make(chan string)
@@ -148,12 +154,63 @@ func TestGCControllerHasUpdateFunc(t *testing.T) {
 	}
 }
 
+func TestGCControllerEnqueueBaseBackup(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      interface{}
+		expected []string
+	}{
+		{
+			name:     "deleted incremental backup enqueues its base",
+			obj:      arktest.NewTestBackup().WithName("backup-2").WithLabel(api.BaseBackupNameLabel, "backup-1").Backup,
+			expected: []string{api.DefaultNamespace + "/backup-1"},
+		},
+		{
+			name:     "tombstone of an incremental backup enqueues its base",
+			obj:      cache.DeletedFinalStateUnknown{Obj: arktest.NewTestBackup().WithName("backup-2").WithLabel(api.BaseBackupNameLabel, "backup-1").Backup},
+			expected: []string{api.DefaultNamespace + "/backup-1"},
+		},
+		{
+			name: "deleted full backup enqueues nothing",
+			obj:  arktest.NewTestBackup().WithName("backup-1").Backup,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				client          = fake.NewSimpleClientset()
+				sharedInformers = informers.NewSharedInformerFactory(client, 0)
+			)
+
+			controller := NewGCController(
+				arktest.NewLogger(),
+				sharedInformers.Ark().V1().Backups(),
+				sharedInformers.Ark().V1().DeleteBackupRequests(),
+				client.ArkV1(),
+				0,
+				metrics.NewServerMetrics(),
+			).(*gcController)
+
+			controller.enqueueBaseBackup(test.obj)
+
+			var keys []string
+			for controller.queue.Len() > 0 {
+				key, _ := controller.queue.Get()
+				keys = append(keys, key.(string))
+			}
+			assert.Equal(t, test.expected, keys)
+		})
+	}
+}
+
 func TestGCControllerProcessQueueItem(t *testing.T) {
 	fakeClock := clock.NewFakeClock(time.Now())
 
 	tests := []struct {
 		name                           string
 		backup                         *api.Backup
+		otherBackups                   []*api.Backup
 		deleteBackupRequests           []*api.DeleteBackupRequest
 		expectDeletion                 bool
 		createDeleteBackupRequestError bool
@@ -176,6 +233,22 @@ func TestGCControllerProcessQueueItem(t *testing.T) {
 				Backup,
 			expectDeletion: true,
 		},
+		{
+			name: "expired backup whose objects are still locked is not deleted",
+			backup: arktest.NewTestBackup().WithName("backup-1").
+				WithExpiration(fakeClock.Now().Add(-1 * time.Second)).
+				WithObjectLockExpiration(fakeClock.Now().Add(1 * time.Hour)).
+				Backup,
+			expectDeletion: false,
+		},
+		{
+			name: "expired backup whose object lock has expired is deleted",
+			backup: arktest.NewTestBackup().WithName("backup-1").
+				WithExpiration(fakeClock.Now().Add(-1 * time.Second)).
+				WithObjectLockExpiration(fakeClock.Now().Add(-1 * time.Second)).
+				Backup,
+			expectDeletion: true,
+		},
 		{
 			name: "expired backup with a pending deletion request is not deleted",
 			backup: arktest.NewTestBackup().WithName("backup-1").
@@ -220,6 +293,30 @@ func TestGCControllerProcessQueueItem(t *testing.T) {
 			},
 			expectDeletion: true,
 		},
+		{
+			name: "expired backup that an incremental backup depends on is not deleted",
+			backup: arktest.NewTestBackup().WithName("backup-1").
+				WithExpiration(fakeClock.Now().Add(-1 * time.Second)).
+				Backup,
+			otherBackups: []*api.Backup{
+				arktest.NewTestBackup().WithName("backup-2").WithBaseBackup("backup-1").
+					WithLabel(api.BaseBackupNameLabel, "backup-1").WithPhase(api.BackupPhaseCompleted).
+					Backup,
+			},
+			expectDeletion: false,
+		},
+		{
+			name: "expired backup whose only incremental backup failed is deleted",
+			backup: arktest.NewTestBackup().WithName("backup-1").
+				WithExpiration(fakeClock.Now().Add(-1 * time.Second)).
+				Backup,
+			otherBackups: []*api.Backup{
+				arktest.NewTestBackup().WithName("backup-2").WithBaseBackup("backup-1").
+					WithLabel(api.BaseBackupNameLabel, "backup-1").WithPhase(api.BackupPhaseFailed).
+					Backup,
+			},
+			expectDeletion: true,
+		},
 		{
 			name: "create DeleteBackupRequest error returns an error",
 			backup: arktest.NewTestBackup().WithName("backup-1").
@@ -243,6 +340,8 @@ func TestGCControllerProcessQueueItem(t *testing.T) {
 				sharedInformers.Ark().V1().Backups(),
 				sharedInformers.Ark().V1().DeleteBackupRequests(),
 				client.ArkV1(),
+				0,
+				metrics.NewServerMetrics(),
 			).(*gcController)
 			controller.clock = fakeClock
 
@@ -252,6 +351,10 @@ func TestGCControllerProcessQueueItem(t *testing.T) {
 				sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(test.backup)
 			}
 
+			for _, backup := range test.otherBackups {
+				sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup)
+			}
+
 			for _, dbr := range test.deleteBackupRequests {
 				sharedInformers.Ark().V1().DeleteBackupRequests().Informer().GetStore().Add(dbr)
 			}
@@ -279,3 +382,42 @@ func TestGCControllerProcessQueueItem(t *testing.T) {
 		})
 	}
 }
+
+func TestGCControllerGracePeriod(t *testing.T) {
+	var (
+		fakeClock       = clock.NewFakeClock(time.Now())
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		backup          = arktest.NewTestBackup().WithName("backup-1").WithExpiration(fakeClock.Now().Add(1 * time.Minute)).Backup
+	)
+
+	controller := NewGCController(
+		arktest.NewLogger(),
+		sharedInformers.Ark().V1().Backups(),
+		sharedInformers.Ark().V1().DeleteBackupRequests(),
+		client.ArkV1(),
+		time.Hour,
+		metrics.NewServerMetrics(),
+	).(*gcController)
+	controller.clock = fakeClock
+
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+
+	// the backup hasn't expired yet
+	require.NoError(t, controller.processQueueItem(kube.NamespaceAndName(backup)))
+	assert.Len(t, client.Actions(), 0)
+
+	// the backup has expired, but is within its grace period
+	fakeClock.Step(30 * time.Minute)
+	require.NoError(t, controller.processQueueItem(kube.NamespaceAndName(backup)))
+	assert.Len(t, client.Actions(), 0)
+
+	// the grace period has passed
+	fakeClock.Step(31 * time.Minute)
+	require.NoError(t, controller.processQueueItem(kube.NamespaceAndName(backup)))
+	require.Len(t, client.Actions(), 1)
+
+	createAction, ok := client.Actions()[0].(core.CreateAction)
+	require.True(t, ok)
+	assert.Equal(t, "deletebackuprequests", createAction.GetResource().Resource)
+}
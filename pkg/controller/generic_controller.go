@@ -23,12 +23,40 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
+const (
+	// these match the per-item backoff used by workqueue.DefaultControllerRateLimiter.
+	defaultRetryBaseDelay = 5 * time.Millisecond
+	defaultRetryMaxDelay  = 1000 * time.Second
+
+	// retryJitterFactor is the maximum fraction of a retry's delay that's
+	// added to it at random, so that keys that failed together, e.g.
+	// because the API server was unavailable, aren't retried in lockstep.
+	retryJitterFactor = 0.2
+)
+
+// RateLimiterConfig configures how a controller retries keys whose sync
+// failed.
+type RateLimiterConfig struct {
+	// BaseDelay is how long to wait before the first retry of a failed key.
+	// The delay doubles with each subsequent failure, up to MaxDelay.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum amount of time to wait between retries of a
+	// failed key.
+	MaxDelay time.Duration
+
+	// MaxRetries is the number of times a failed key is retried before it's
+	// dropped from the queue. Zero means it's retried until it succeeds.
+	MaxRetries int
+}
+
 type genericController struct {
 	name             string
 	queue            workqueue.RateLimitingInterface
@@ -37,6 +65,70 @@ type genericController struct {
 	resyncFunc       func()
 	resyncPeriod     time.Duration
 	cacheSyncWaiters []cache.InformerSynced
+	maxRetries       int
+	// retriesExhaustedFunc, if set, is called with a key, and the error its
+	// last sync failed with, when it's dropped from the queue after failing
+	// maxRetries times.
+	retriesExhaustedFunc func(key string, err error)
+}
+
+// permanentError is a sync error that retrying won't fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// newPermanentError marks err as a sync error that retrying won't fix, so
+// that the key isn't requeued.
+func newPermanentError(err error) error {
+	return permanentError{err: err}
+}
+
+// isPermanentError returns whether err, or the error it wraps, was marked
+// by newPermanentError.
+func isPermanentError(err error) bool {
+	_, ok := errors.Cause(err).(permanentError)
+	return ok
+}
+
+// requeueError is a sync error that isn't a failure: the key can't be
+// processed yet, so it's requeued after a delay without counting as a retry.
+type requeueError struct {
+	err   error
+	after time.Duration
+}
+
+func (e requeueError) Error() string {
+	return e.err.Error()
+}
+
+// newRequeueError marks err as the reason a key can't be processed yet, so
+// that it's requeued after the given delay instead of being retried with
+// backoff.
+func newRequeueError(err error, after time.Duration) error {
+	return requeueError{err: err, after: after}
+}
+
+// requeueDelay returns how long to wait before requeueing a key whose sync
+// returned err, if err, or the error it wraps, was marked by
+// newRequeueError.
+func requeueDelay(err error) (time.Duration, bool) {
+	e, ok := errors.Cause(err).(requeueError)
+	return e.after, ok
+}
+
+// jitteredRateLimiter adds up to maxFactor of each delay returned by
+// RateLimiter to it at random.
+type jitteredRateLimiter struct {
+	workqueue.RateLimiter
+	maxFactor float64
+}
+
+func (r *jitteredRateLimiter) When(item interface{}) time.Duration {
+	return wait.Jitter(r.RateLimiter.When(item), r.maxFactor)
 }
 
 func newGenericController(name string, logger logrus.FieldLogger) *genericController {
@@ -49,6 +141,60 @@ func newGenericController(name string, logger logrus.FieldLogger) *genericContro
 	return c
 }
 
+// newGenericControllerWithRateLimiter returns a genericController whose queue
+// retries failed keys according to config, with jitter added to each delay.
+// Zero-valued delays are replaced with the defaults used by
+// newGenericController.
+func newGenericControllerWithRateLimiter(name string, logger logrus.FieldLogger, config RateLimiterConfig) *genericController {
+	c := &genericController{
+		name:       name,
+		queue:      workqueue.NewNamedRateLimitingQueue(newRateLimiter(config), name),
+		logger:     logger.WithField("controller", name),
+		maxRetries: config.MaxRetries,
+	}
+
+	return c
+}
+
+// newGenericControllerWithPriority returns a genericController like
+// newGenericControllerWithRateLimiter's, except that its queue hands out
+// the keys with the highest priority first, as returned by priority when
+// they're added. Keys of the same priority are handed out in the order
+// they were added.
+func newGenericControllerWithPriority(name string, logger logrus.FieldLogger, config RateLimiterConfig, priority func(key string) int) *genericController {
+	c := &genericController{
+		name: name,
+		queue: newPriorityQueue(newRateLimiter(config), func(item interface{}) int {
+			return priority(item.(string))
+		}),
+		logger:     logger.WithField("controller", name),
+		maxRetries: config.MaxRetries,
+	}
+
+	return c
+}
+
+// newRateLimiter returns a rate limiter that delays retries of failed keys
+// according to config, with jitter added to each delay.
+func newRateLimiter(config RateLimiterConfig) workqueue.RateLimiter {
+	baseDelay, maxDelay := config.BaseDelay, config.MaxDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	return workqueue.NewMaxOfRateLimiter(
+		&jitteredRateLimiter{
+			RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+			maxFactor:   retryJitterFactor,
+		},
+		// overall rate limiting for the queue, matching workqueue.DefaultControllerRateLimiter
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
 // Run is a blocking function that runs the specified number of worker goroutines
 // to process items in the work queue. It will return when it receives on the
 // ctx.Done() channel.
@@ -135,7 +281,35 @@ func (c *genericController) processNextWorkItem() bool {
 		return true
 	}
 
-	c.logger.WithError(err).WithField("key", key).Error("Error in syncHandler, re-adding item to queue")
+	retries := c.queue.NumRequeues(key)
+	log := c.logger.WithError(err).WithFields(logrus.Fields{
+		"key":     key,
+		"retries": retries,
+	})
+
+	if after, ok := requeueDelay(err); ok {
+		log.WithField("after", after).Debug("Key can't be processed yet, re-adding it to the queue")
+		// the key hasn't failed, so its failure history is left as it is.
+		c.queue.AddAfter(key, after)
+		return true
+	}
+
+	if isPermanentError(err) {
+		log.Error("Error in syncHandler that won't be fixed by retrying, dropping item from queue")
+		c.queue.Forget(key)
+		return true
+	}
+
+	if c.maxRetries > 0 && retries >= c.maxRetries {
+		log.Error("Error in syncHandler, retries exhausted, dropping item from queue")
+		c.queue.Forget(key)
+		if c.retriesExhaustedFunc != nil {
+			c.retriesExhaustedFunc(key.(string), err)
+		}
+		return true
+	}
+
+	log.Error("Error in syncHandler, re-adding item to queue")
 	// we had an error processing the item so add it back
 	// into the queue for re-processing with rate-limiting
 	c.queue.AddRateLimited(key)
@@ -143,6 +317,12 @@ func (c *genericController) processNextWorkItem() bool {
 	return true
 }
 
+// numRetries returns the number of times the given key has been
+// retried since it last synced successfully.
+func (c *genericController) numRetries(key string) int {
+	return c.queue.NumRequeues(key)
+}
+
 func (c *genericController) enqueue(obj interface{}) {
 	key, err := cache.MetaNamespaceKeyFunc(obj)
 	if err != nil {
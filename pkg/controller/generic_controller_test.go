@@ -0,0 +1,151 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/client-go/util/workqueue"
+
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestProcessNextWorkItemRetries(t *testing.T) {
+	c := newGenericControllerWithRateLimiter("test", arktest.NewLogger(), RateLimiterConfig{
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+		MaxRetries: 2,
+	})
+	defer c.queue.ShutDown()
+
+	var exhausted []string
+	c.syncHandler = func(key string) error { return errors.New("sync failed") }
+	c.retriesExhaustedFunc = func(key string, err error) {
+		assert.EqualError(t, err, "sync failed")
+		exhausted = append(exhausted, key)
+	}
+
+	c.queue.Add("ns/name")
+
+	// the initial attempt and first retry should put the key back in the queue
+	require.True(t, c.processNextWorkItem())
+	assert.Equal(t, 1, c.numRetries("ns/name"))
+	require.True(t, c.processNextWorkItem())
+	assert.Equal(t, 2, c.numRetries("ns/name"))
+	assert.Empty(t, exhausted)
+
+	// the second retry exhausts the retries, so the key should be dropped
+	require.True(t, c.processNextWorkItem())
+	assert.Equal(t, []string{"ns/name"}, exhausted)
+	assert.Equal(t, 0, c.numRetries("ns/name"))
+	assert.Equal(t, 0, c.queue.Len())
+}
+
+func TestProcessNextWorkItemNoMaxRetries(t *testing.T) {
+	c := newGenericControllerWithRateLimiter("test", arktest.NewLogger(), RateLimiterConfig{
+		BaseDelay: time.Millisecond,
+		MaxDelay:  time.Millisecond,
+	})
+	defer c.queue.ShutDown()
+
+	failures := 0
+	c.syncHandler = func(key string) error {
+		if failures < 5 {
+			failures++
+			return errors.New("sync failed")
+		}
+		return nil
+	}
+	c.retriesExhaustedFunc = func(key string, _ error) { t.Errorf("unexpected call to retriesExhaustedFunc for %s", key) }
+
+	c.queue.Add("ns/name")
+
+	for i := 0; i < 6; i++ {
+		require.True(t, c.processNextWorkItem())
+	}
+
+	assert.Equal(t, 0, c.numRetries("ns/name"))
+	assert.Equal(t, 0, c.queue.Len())
+}
+
+func TestProcessNextWorkItemPermanentError(t *testing.T) {
+	c := newGenericControllerWithRateLimiter("test", arktest.NewLogger(), RateLimiterConfig{
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+		MaxRetries: 2,
+	})
+	defer c.queue.ShutDown()
+
+	c.syncHandler = func(key string) error {
+		return errors.Wrap(newPermanentError(errors.New("sync failed")), "wrapped")
+	}
+	c.retriesExhaustedFunc = func(key string, _ error) { t.Errorf("unexpected call to retriesExhaustedFunc for %s", key) }
+
+	c.queue.Add("ns/name")
+
+	// the key is dropped without being retried
+	require.True(t, c.processNextWorkItem())
+	assert.Equal(t, 0, c.numRetries("ns/name"))
+	assert.Equal(t, 0, c.queue.Len())
+}
+
+func TestProcessNextWorkItemRequeueError(t *testing.T) {
+	c := newGenericControllerWithRateLimiter("test", arktest.NewLogger(), RateLimiterConfig{
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+		MaxRetries: 1,
+	})
+	defer c.queue.ShutDown()
+
+	syncs := 0
+	c.syncHandler = func(key string) error {
+		syncs++
+		if syncs < 3 {
+			return errors.Wrap(newRequeueError(errors.New("not yet"), time.Millisecond), "wrapped")
+		}
+		return nil
+	}
+	c.retriesExhaustedFunc = func(key string, _ error) { t.Errorf("unexpected call to retriesExhaustedFunc for %s", key) }
+
+	c.queue.Add("ns/name")
+
+	// requeueing the key doesn't count as a retry, so it isn't dropped
+	for i := 0; i < 3; i++ {
+		require.True(t, c.processNextWorkItem())
+		assert.Equal(t, 0, c.numRetries("ns/name"))
+	}
+
+	assert.Equal(t, 3, syncs)
+	assert.Equal(t, 0, c.queue.Len())
+}
+
+func TestJitteredRateLimiter(t *testing.T) {
+	r := &jitteredRateLimiter{
+		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(time.Second, time.Minute),
+		maxFactor:   0.5,
+	}
+
+	for i, base := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := r.When("ns/name")
+		assert.True(t, delay >= base && delay <= base+base/2, "retry %d: delay %v isn't within 50%% above %v", i, delay, base)
+	}
+}
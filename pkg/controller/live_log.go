@@ -0,0 +1,199 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// BackupLogPath is the path that the logs of in-progress backups are
+// served under, as BackupLogPath + "<namespace>/<name>".
+const BackupLogPath = "/backups/logs/"
+
+// defaultLiveLogSize is how much of the end of an in-progress backup's log
+// is kept in memory for its followers.
+const defaultLiveLogSize = 1024 * 1024
+
+// LiveLog is the log of an in-progress backup. The end of it is kept in
+// memory while it's written, so that any number of readers can follow it.
+// Followers that fall more than its size behind skip ahead; the whole log
+// is uploaded when the backup finishes.
+type LiveLog struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	// data is the end of the log, and start is the offset of its first
+	// byte in the whole log.
+	data    []byte
+	start   int
+	maxSize int
+	closed  bool
+}
+
+// NewLiveLog returns a new, empty LiveLog.
+func NewLiveLog() *LiveLog {
+	return newLiveLog(defaultLiveLogSize)
+}
+
+func newLiveLog(maxSize int) *LiveLog {
+	l := &LiveLog{maxSize: maxSize}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+func (l *LiveLog) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return 0, errors.New("live log is closed")
+	}
+
+	l.data = append(l.data, p...)
+	// only compact once there's twice as much as is kept, so that it's not
+	// done on every write. It's copied into a new slice, rather than moved
+	// within the old one, because followers may be writing chunks of it.
+	if len(l.data) > 2*l.maxSize {
+		drop := len(l.data) - l.maxSize
+		l.data = append([]byte(nil), l.data[drop:]...)
+		l.start += drop
+	}
+	l.cond.Broadcast()
+
+	return len(p), nil
+}
+
+// Close marks the log as finished, so that its followers return once
+// they've read all of it. It's safe to call more than once.
+func (l *LiveLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.closed = true
+	l.cond.Broadcast()
+
+	return nil
+}
+
+// Follow writes the log to w, from the beginning of what's kept of it, as
+// it's written, until it's closed or ctx is done. If it falls behind what's
+// kept, it writes a note of how much it skipped and continues from the next
+// whole line.
+func (l *LiveLog) Follow(ctx context.Context, w io.Writer) error {
+	// wake up the wait below if ctx is done while the log is idle.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.lock.Lock()
+			l.cond.Broadcast()
+			l.lock.Unlock()
+		case <-stop:
+		}
+	}()
+
+	var offset int
+	for {
+		l.lock.Lock()
+		for offset == l.start+len(l.data) && !l.closed && ctx.Err() == nil {
+			l.cond.Wait()
+		}
+
+		var skipped int
+		if offset < l.start {
+			skipped = l.start - offset
+			offset = l.start
+		}
+		// data is only ever appended to or replaced, so the chunk can be
+		// written without holding the lock.
+		chunk, closed := l.data[offset-l.start:], l.closed
+		l.lock.Unlock()
+
+		if skipped > 0 {
+			if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+				skipped += i + 1
+				offset += i + 1
+				chunk = chunk[i+1:]
+			}
+			if _, err := fmt.Fprintf(w, "... skipped %d bytes of the log; the whole log can be downloaded when the backup finishes ...\n", skipped); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		if len(chunk) > 0 {
+			if _, err := w.Write(chunk); err != nil {
+				return errors.WithStack(err)
+			}
+			offset += len(chunk)
+			continue
+		}
+
+		if closed {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// NewBackupLogHandler returns an http.Handler that streams the logs of the
+// in-progress backups tracked by tracker, at BackupLogPath + "<namespace>/<name>",
+// until each backup finishes or its client disconnects. Backups that aren't
+// in progress aren't found; their logs are downloaded from object storage.
+func NewBackupLogHandler(tracker BackupTracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, BackupLogPath), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		log, ok := tracker.Log(parts[0], parts[1])
+		if !ok {
+			http.Error(w, "backup is not in progress", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		// the client going away is the usual way for this to end early, so
+		// there's nothing to do with the error.
+		log.Follow(r.Context(), &flushWriter{w: w})
+	})
+}
+
+// flushWriter flushes each write to an http.ResponseWriter, so that it's
+// sent to the client right away.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestLiveLogFollow(t *testing.T) {
+	log := NewLiveLog()
+
+	_, err := log.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+
+	// followers that start before and after more is written both read the
+	// whole log.
+	var (
+		wg      sync.WaitGroup
+		outputs = make([]bytes.Buffer, 2)
+	)
+	follow := func(i int) {
+		defer wg.Done()
+		assert.NoError(t, log.Follow(context.Background(), &outputs[i]))
+	}
+
+	wg.Add(1)
+	go follow(0)
+
+	_, err = log.Write([]byte("line 2\n"))
+	require.NoError(t, err)
+
+	wg.Add(1)
+	go follow(1)
+
+	_, err = log.Write([]byte("line 3\n"))
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	wg.Wait()
+	for i := range outputs {
+		assert.Equal(t, "line 1\nline 2\nline 3\n", outputs[i].String())
+	}
+
+	_, err = log.Write([]byte("line 4\n"))
+	assert.Error(t, err)
+}
+
+func TestLiveLogKeepsOnlyItsEnd(t *testing.T) {
+	log := newLiveLog(16)
+
+	// a follower that starts early may or may not fall behind
+	var (
+		wg    sync.WaitGroup
+		early bytes.Buffer
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, log.Follow(context.Background(), &early))
+	}()
+
+	var whole string
+	for i := 0; i < 10; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		whole += line
+		_, err := log.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	// only up to twice its size is kept in memory
+	log.lock.Lock()
+	assert.True(t, len(log.data) <= 32, "live log holds %d bytes", len(log.data))
+	start := log.start
+	log.lock.Unlock()
+	assert.NotZero(t, start)
+
+	// a follower that starts late skips to the next whole line of what's
+	// kept, and says how much it skipped
+	require.NoError(t, log.Close())
+	var late bytes.Buffer
+	require.NoError(t, log.Follow(context.Background(), &late))
+
+	lines := strings.SplitN(late.String(), "\n", 2)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "skipped")
+	assert.True(t, strings.HasSuffix(whole, lines[1]))
+	assert.True(t, strings.HasPrefix(lines[1], "line "))
+	assert.Contains(t, lines[0], fmt.Sprintf("skipped %d bytes", len(whole)-len(lines[1])))
+
+	wg.Wait()
+	// but it always ends with the end of the log
+	assert.True(t, strings.HasSuffix(early.String(), "line 9\n"))
+}
+
+func TestLiveLogFollowStopsWhenContextIsDone(t *testing.T) {
+	log := NewLiveLog()
+	_, err := log.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- log.Follow(ctx, &buf)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow didn't return after its context was cancelled")
+	}
+}
+
+func TestBackupLogHandler(t *testing.T) {
+	tracker := NewBackupTracker()
+	server := httptest.NewServer(NewBackupLogHandler(tracker))
+	defer server.Close()
+
+	// backups that aren't in progress have no live log
+	res, err := http.Get(server.URL + BackupLogPath + "heptio-ark/backup-1")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, err = http.Get(server.URL + BackupLogPath + "heptio-ark")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	// start a backup, which logs the way runBackup does
+	tracker.Add("heptio-ark", "backup-1")
+	liveLog := NewLiveLog()
+	tracker.SetLog("heptio-ark", "backup-1", liveLog)
+
+	c := &backupController{backupLogLevel: logrus.InfoLevel}
+	log := c.newBackupLogger(arktest.NewTestBackup().WithName("backup-1").Backup, liveLog)
+	log.Info("Starting backup")
+
+	res, err = http.Get(server.URL + BackupLogPath + "heptio-ark/backup-1")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// the log is streamed as it accumulates
+	body := bufio.NewReader(res.Body)
+	line, err := body.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `msg="Starting backup"`)
+
+	log.Info("Backing up items")
+	line, err = body.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `msg="Backing up items"`)
+
+	// the response ends when the backup finishes
+	log.Info("Backup completed")
+	require.NoError(t, liveLog.Close())
+	tracker.Delete("heptio-ark", "backup-1")
+
+	rest, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	assert.Contains(t, string(rest), `msg="Backup completed"`)
+
+	res, err = http.Get(server.URL + BackupLogPath + "heptio-ark/backup-1")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
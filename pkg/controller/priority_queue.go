@@ -0,0 +1,192 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// priorityQueue is a workqueue.RateLimitingInterface whose items are
+// dequeued highest priority first, and in the order they were added among
+// items of the same priority. Otherwise it behaves like a workqueue: an
+// item that's added while it's queued is only queued once, and one that's
+// added while it's being processed is queued again once it's done.
+type priorityQueue struct {
+	priority    func(item interface{}) int
+	rateLimiter workqueue.RateLimiter
+
+	cond         *sync.Cond
+	items        priorityItems
+	seq          uint64
+	dirty        map[interface{}]bool
+	processing   map[interface{}]bool
+	priorities   map[interface{}]int
+	shuttingDown bool
+}
+
+// newPriorityQueue returns a priorityQueue that calls priority for each
+// item when it's added, and delays rate-limited adds by rateLimiter.
+func newPriorityQueue(rateLimiter workqueue.RateLimiter, priority func(item interface{}) int) *priorityQueue {
+	return &priorityQueue{
+		priority:    priority,
+		rateLimiter: rateLimiter,
+		cond:        sync.NewCond(&sync.Mutex{}),
+		dirty:       make(map[interface{}]bool),
+		processing:  make(map[interface{}]bool),
+		priorities:  make(map[interface{}]int),
+	}
+}
+
+func (q *priorityQueue) Add(item interface{}) {
+	// the priority function may be slow, e.g. if it gets the item from
+	// a lister, so it's called before taking the lock.
+	priority := q.priority(item)
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	if q.shuttingDown || q.dirty[item] {
+		return
+	}
+
+	q.dirty[item] = true
+	q.priorities[item] = priority
+	if q.processing[item] {
+		return
+	}
+
+	q.push(item)
+}
+
+// push adds item to the heap. q.cond.L must be held.
+func (q *priorityQueue) push(item interface{}) {
+	q.seq++
+	heap.Push(&q.items, &priorityItem{item: item, priority: q.priorities[item], seq: q.seq})
+	q.cond.Signal()
+}
+
+func (q *priorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	return q.items.Len()
+}
+
+func (q *priorityQueue) Get() (interface{}, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	for q.items.Len() == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if q.items.Len() == 0 {
+		return nil, true
+	}
+
+	item := heap.Pop(&q.items).(*priorityItem).item
+	q.processing[item] = true
+	delete(q.dirty, item)
+
+	return item, false
+}
+
+func (q *priorityQueue) Done(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	delete(q.processing, item)
+	if q.dirty[item] {
+		q.push(item)
+	} else {
+		delete(q.priorities, item)
+	}
+}
+
+func (q *priorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+func (q *priorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	return q.shuttingDown
+}
+
+func (q *priorityQueue) AddAfter(item interface{}, duration time.Duration) {
+	if q.ShuttingDown() {
+		return
+	}
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+
+	time.AfterFunc(duration, func() { q.Add(item) })
+}
+
+func (q *priorityQueue) AddRateLimited(item interface{}) {
+	q.AddAfter(item, q.rateLimiter.When(item))
+}
+
+func (q *priorityQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
+
+func (q *priorityQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+// priorityItem is a queued item, its priority, and the order it was
+// queued in.
+type priorityItem struct {
+	item     interface{}
+	priority int
+	seq      uint64
+}
+
+// priorityItems implements heap.Interface, with the highest priority,
+// earliest queued item first.
+type priorityItems []*priorityItem
+
+func (p priorityItems) Len() int { return len(p) }
+
+func (p priorityItems) Less(i, j int) bool {
+	if p[i].priority != p[j].priority {
+		return p[i].priority > p[j].priority
+	}
+	return p[i].seq < p[j].seq
+}
+
+func (p priorityItems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p *priorityItems) Push(x interface{}) { *p = append(*p, x.(*priorityItem)) }
+
+func (p *priorityItems) Pop() interface{} {
+	old := *p
+	item := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// testPriority gives items starting with "manual" a higher priority than
+// the rest.
+func testPriority(item interface{}) int {
+	if strings.HasPrefix(item.(string), "manual") {
+		return manualBackupPriority
+	}
+	return scheduledBackupPriority
+}
+
+// drain gets every item in q, marking each one done.
+func drain(t *testing.T, q workqueue.Interface) []string {
+	var items []string
+	for q.Len() > 0 {
+		item, shutdown := q.Get()
+		require.False(t, shutdown)
+		items = append(items, item.(string))
+		q.Done(item)
+	}
+	return items
+}
+
+func TestPriorityQueueOrdering(t *testing.T) {
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), testPriority)
+	defer q.ShutDown()
+
+	for _, item := range []string{"scheduled-1", "scheduled-2", "manual-1", "scheduled-3", "manual-2", "scheduled-4", "manual-3"} {
+		q.Add(item)
+	}
+
+	assert.Equal(t, []string{"manual-1", "manual-2", "manual-3", "scheduled-1", "scheduled-2", "scheduled-3", "scheduled-4"}, drain(t, q))
+}
+
+func TestPriorityQueueManualBackupJumpsBacklog(t *testing.T) {
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), testPriority)
+	defer q.ShutDown()
+
+	for i := 0; i < 100; i++ {
+		q.Add(fmt.Sprintf("scheduled-%03d", i))
+	}
+
+	// a few scheduled backups are processed before the manual one arrives
+	var got []string
+	for i := 0; i < 3; i++ {
+		item, _ := q.Get()
+		got = append(got, item.(string))
+		q.Done(item)
+	}
+	assert.Equal(t, []string{"scheduled-000", "scheduled-001", "scheduled-002"}, got)
+
+	q.Add("manual-urgent")
+
+	item, _ := q.Get()
+	assert.Equal(t, "manual-urgent", item)
+	q.Done(item)
+	assert.Equal(t, 97, q.Len())
+}
+
+func TestPriorityQueueDeduplicates(t *testing.T) {
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), testPriority)
+	defer q.ShutDown()
+
+	q.Add("scheduled-1")
+	q.Add("manual-1")
+	q.Add("scheduled-1")
+	q.Add("manual-1")
+	assert.Equal(t, 2, q.Len())
+
+	// an item added while it's being processed is queued again once it's done.
+	item, _ := q.Get()
+	require.Equal(t, "manual-1", item)
+	q.Add("manual-1")
+	assert.Equal(t, 1, q.Len())
+	q.Done(item)
+	assert.Equal(t, 2, q.Len())
+
+	assert.Equal(t, []string{"manual-1", "scheduled-1"}, drain(t, q))
+}
+
+func TestPriorityQueueAddAfter(t *testing.T) {
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), testPriority)
+	defer q.ShutDown()
+
+	q.AddAfter("scheduled-1", 10*time.Millisecond)
+	assert.Equal(t, 0, q.Len())
+
+	item, shutdown := q.Get()
+	assert.False(t, shutdown)
+	assert.Equal(t, "scheduled-1", item)
+	q.Done(item)
+
+	q.AddRateLimited("manual-1")
+	assert.Equal(t, 1, q.NumRequeues("manual-1"))
+	item, _ = q.Get()
+	assert.Equal(t, "manual-1", item)
+	q.Done(item)
+	q.Forget(item)
+	assert.Equal(t, 0, q.NumRequeues("manual-1"))
+}
+
+func TestPriorityQueueShutDown(t *testing.T) {
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), testPriority)
+
+	done := make(chan bool)
+	go func() {
+		_, shutdown := q.Get()
+		done <- shutdown
+	}()
+
+	q.ShutDown()
+	assert.True(t, <-done)
+	assert.True(t, q.ShuttingDown())
+
+	q.Add("manual-1")
+	assert.Equal(t, 0, q.Len())
+}
@@ -24,25 +24,34 @@ import (
 	"io/ioutil"
 	"os"
 	"sort"
+	"strings"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	authorizationv1api "k8s.io/api/authorization/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
+	arkdiscovery "github.com/heptio/ark/pkg/discovery"
 	arkv1client "github.com/heptio/ark/pkg/generated/clientset/versioned/typed/ark/v1"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions/ark/v1"
 	listers "github.com/heptio/ark/pkg/generated/listers/ark/v1"
+	"github.com/heptio/ark/pkg/kuberesource"
 	"github.com/heptio/ark/pkg/metrics"
 	"github.com/heptio/ark/pkg/persistence"
 	"github.com/heptio/ark/pkg/plugin"
 	"github.com/heptio/ark/pkg/restore"
+	"github.com/heptio/ark/pkg/transfer"
+	"github.com/heptio/ark/pkg/transform"
 	"github.com/heptio/ark/pkg/util/boolptr"
 	"github.com/heptio/ark/pkg/util/collections"
 	kubeutil "github.com/heptio/ark/pkg/util/kube"
@@ -79,6 +88,9 @@ type restoreController struct {
 	restoreLogLevel       logrus.Level
 	defaultBackupLocation string
 	metrics               *metrics.ServerMetrics
+	accessReviewClient    authorizationv1client.SelfSubjectAccessReviewsGetter
+	discoveryHelper       arkdiscovery.Helper
+	transferEndpoint      transfer.Endpoint
 
 	newPluginManager func(logger logrus.FieldLogger) plugin.Manager
 	newBackupStore   func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error)
@@ -96,8 +108,12 @@ func NewRestoreController(
 	logger logrus.FieldLogger,
 	restoreLogLevel logrus.Level,
 	newPluginManager func(logrus.FieldLogger) plugin.Manager,
+	encryptionKeys persistence.EncryptionKeyGetter,
 	defaultBackupLocation string,
 	metrics *metrics.ServerMetrics,
+	accessReviewClient authorizationv1client.SelfSubjectAccessReviewsGetter,
+	discoveryHelper arkdiscovery.Helper,
+	transferEndpoint transfer.Endpoint,
 ) Interface {
 	c := &restoreController{
 		genericController:     newGenericController("restore", logger),
@@ -112,11 +128,14 @@ func NewRestoreController(
 		restoreLogLevel:       restoreLogLevel,
 		defaultBackupLocation: defaultBackupLocation,
 		metrics:               metrics,
+		accessReviewClient:    accessReviewClient,
+		discoveryHelper:       discoveryHelper,
+		transferEndpoint:      transferEndpoint,
 
 		// use variables to refer to these functions so they can be
 		// replaced with fakes for testing.
 		newPluginManager: newPluginManager,
-		newBackupStore:   persistence.NewObjectBackupStore,
+		newBackupStore:   persistence.NewBackupStoreFactory(encryptionKeys),
 	}
 
 	c.syncHandler = c.processRestore
@@ -202,6 +221,17 @@ func (c *restoreController) processRestore(key string) error {
 
 	// validate the restore and fetch the backup
 	info := c.validateAndComplete(restore, pluginManager)
+
+	// if configured, check up front that we're allowed to create everything
+	// in the backup, rather than discovering RBAC failures item-by-item.
+	// Transferred backups aren't received until the restore runs, so they
+	// aren't checked.
+	if len(restore.Status.ValidationErrors) == 0 && c.accessReviewClient != nil && !info.transfer {
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, c.validatePermissions(restore, &info)...)
+	}
+	if info.backupFile != nil {
+		defer closeAndRemoveFile(info.backupFile, c.logger)
+	}
 	backupScheduleName := restore.Spec.ScheduleName
 	// Register attempts after validation so we don't have to fetch the backup multiple times
 	c.metrics.RegisterRestoreAttempt(backupScheduleName)
@@ -233,6 +263,7 @@ func (c *restoreController) processRestore(key string) error {
 		restore,
 		actions,
 		info,
+		pluginManager.GetPluginVersions(),
 	)
 
 	restore.Status.Warnings = len(restoreWarnings.Ark) + len(restoreWarnings.Cluster)
@@ -268,6 +299,16 @@ func (c *restoreController) processRestore(key string) error {
 type backupInfo struct {
 	backup      *api.Backup
 	backupStore persistence.BackupStore
+	// transfer is set if the backup is to be received from the transfer
+	// endpoint when the restore runs, rather than read from backupStore.
+	// Until then, backup is nil.
+	transfer bool
+	// contents is the backup's tarball once it's been received from the
+	// transfer endpoint.
+	contents io.ReadCloser
+	// backupFile, if set, is the backup's tarball, already downloaded from
+	// backupStore to check permissions, so that it's only downloaded once.
+	backupFile *os.File
 }
 
 func (c *restoreController) validateAndComplete(restore *api.Restore, pluginManager plugin.Manager) backupInfo {
@@ -302,12 +343,44 @@ func (c *restoreController) validateAndComplete(restore *api.Restore, pluginMana
 		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, "Server is not configured for PV snapshot restores")
 	}
 
+	switch restore.Spec.ExistingResourcePolicy {
+	case "", api.ExistingResourcePolicyNone, api.ExistingResourcePolicyUpdate:
+	default:
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, fmt.Sprintf("Invalid existing resource policy %q", restore.Spec.ExistingResourcePolicy))
+	}
+
+	switch restore.Spec.ImmutableFieldPolicy {
+	case "", api.ImmutableFieldPolicySkip, api.ImmutableFieldPolicyRecreate, api.ImmutableFieldPolicyFail:
+	default:
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, fmt.Sprintf("Invalid immutable field policy %q", restore.Spec.ImmutableFieldPolicy))
+	}
+
 	// validate that exactly one of BackupName and ScheduleName have been specified
 	if !backupXorScheduleProvided(restore) {
 		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, "Either a backup or schedule must be specified as a source for the restore, but not both")
 		return backupInfo{}
 	}
 
+	if transferRequested(restore) {
+		if restore.Spec.BackupName == "" {
+			restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, "A backup name must be specified when restoring from a transfer endpoint")
+			return backupInfo{}
+		}
+
+		if c.transferEndpoint == nil {
+			restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, "Server is not configured with a transfer endpoint")
+			return backupInfo{}
+		}
+
+		info, err := c.transferBackupInfo(pluginManager)
+		if err != nil {
+			restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, fmt.Sprintf("Error getting backup storage location for the restore's results: %v", err))
+			return backupInfo{}
+		}
+
+		return info
+	}
+
 	// if ScheduleName is specified, fill in BackupName with the most recent successful backup from
 	// the schedule
 	if restore.Spec.ScheduleName != "" {
@@ -332,12 +405,40 @@ func (c *restoreController) validateAndComplete(restore *api.Restore, pluginMana
 		}
 	}
 
-	info, err := c.fetchBackupInfo(restore.Spec.BackupName, pluginManager)
+	var (
+		info backupInfo
+		err  error
+	)
+	if restore.Spec.StorageLocationOverride != "" {
+		info, err = c.fetchBackupInfoFromLocation(restore.Spec.StorageLocationOverride, restore.Spec.BackupName, pluginManager)
+	} else {
+		info, err = c.fetchBackupInfo(restore.Spec.BackupName, pluginManager)
+	}
 	if err != nil {
 		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, fmt.Sprintf("Error retrieving backup: %v", err))
 		return backupInfo{}
 	}
 
+	if info.backup.Spec.DryRun {
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, "Backup is a dry run, so it has no contents to restore")
+		return backupInfo{}
+	}
+
+	if err := persistence.CheckBackupVersion(info.backup); err != nil {
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, fmt.Sprintf("Unsupported backup format: %v", err))
+		return backupInfo{}
+	}
+
+	complete, err := persistence.IsBackupComplete(info.backupStore, info.backup)
+	if err != nil {
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, fmt.Sprintf("Error checking whether backup is complete: %v", err))
+		return backupInfo{}
+	}
+	if !complete {
+		restore.Status.ValidationErrors = append(restore.Status.ValidationErrors, "Backup is incomplete: its upload to the backup storage location didn't finish")
+		return backupInfo{}
+	}
+
 	// Fill in the ScheduleName so it's easier to consume for metrics.
 	if restore.Spec.ScheduleName == "" {
 		restore.Spec.ScheduleName = info.backup.GetLabels()["ark-schedule"]
@@ -346,6 +447,149 @@ func (c *restoreController) validateAndComplete(restore *api.Restore, pluginMana
 	return info
 }
 
+// validatePermissions lists the items in the restore's backup and uses
+// SelfSubjectAccessReviews to check that the server has permission to create
+// each included group-resource in each of its target namespaces. It returns
+// a validation error for each group-resource that can't be created.
+func (c *restoreController) validatePermissions(restore *api.Restore, info *backupInfo) []string {
+	inventory, err := c.backupInventory(info)
+	if err != nil {
+		return []string{fmt.Sprintf("Error reading backup contents for permission checks: %v", err)}
+	}
+
+	resources := collections.NewIncludesExcludes().
+		Includes(restore.Spec.IncludedResources...).
+		Excludes(restore.Spec.ExcludedResources...)
+	namespaces := collections.NewIncludesExcludes().
+		Includes(restore.Spec.IncludedNamespaces...).
+		Excludes(restore.Spec.ExcludedNamespaces...)
+
+	var validationErrors []string
+	for _, resource := range inventory.GroupResources() {
+		if !resources.ShouldInclude(resource) {
+			continue
+		}
+
+		// the resource is checked under the name the API server serves it
+		// as now. Resources it doesn't serve yet, e.g. custom resources
+		// whose CRDs are restored first, can't be checked.
+		gr := schema.ParseGroupResource(resource)
+		if c.discoveryHelper != nil {
+			gvr, _, err := c.discoveryHelper.ResourceFor(gr.WithVersion(""))
+			if err != nil {
+				continue
+			}
+			gr = gvr.GroupResource()
+		}
+		items := inventory[resource]
+
+		if len(items.ClusterScoped) > 0 && !boolptr.IsSetToFalse(restore.Spec.IncludeClusterResources) {
+			allowed, err := c.canCreate(gr, "")
+			if err != nil {
+				validationErrors = append(validationErrors, fmt.Sprintf("Error checking permission to create %s: %v", resource, err))
+			} else if !allowed {
+				validationErrors = append(validationErrors, fmt.Sprintf("Not permitted to create %s", resource))
+			}
+		}
+
+		var forbidden []string
+		for namespace := range items.Namespaced {
+			if !namespaces.ShouldInclude(namespace) {
+				continue
+			}
+
+			targetNamespace := namespace
+			if mapped, ok := restore.Spec.NamespaceMapping[namespace]; ok {
+				targetNamespace = mapped
+			}
+
+			allowed, err := c.canCreate(gr, targetNamespace)
+			if err != nil {
+				validationErrors = append(validationErrors, fmt.Sprintf("Error checking permission to create %s in namespace %s: %v", resource, targetNamespace, err))
+			} else if !allowed {
+				forbidden = append(forbidden, targetNamespace)
+			}
+		}
+
+		if len(forbidden) > 0 {
+			sort.Strings(forbidden)
+			validationErrors = append(validationErrors, fmt.Sprintf("Not permitted to create %s in namespaces: %s", resource, strings.Join(forbidden, ", ")))
+		}
+	}
+
+	return validationErrors
+}
+
+// backupInventory lists the items in info's backup. Its content index or
+// integrity manifest is read if it has one, so that its tarball doesn't have
+// to be downloaded; otherwise its tarball is downloaded to info.backupFile,
+// for the restore to use too.
+func (c *restoreController) backupInventory(info *backupInfo) (archive.Inventory, error) {
+	log := c.logger.WithField("backup", info.backup.Name)
+
+	if info.backup.Annotations[api.ContentIndexVersionAnnotation] != "" {
+		inventory, err := readContentIndex(info)
+		if err == nil {
+			return inventory, nil
+		}
+		log.WithError(err).Warn("Error reading backup's content index, checking its manifest or tarball instead")
+	}
+
+	if info.backup.Spec.IntegrityManifest {
+		entries, err := info.backupStore.GetBackupManifest(info.backup.Name)
+		if err == nil {
+			return archive.InventoryFromManifest(entries), nil
+		}
+		log.WithError(err).Warn("Error getting backup's integrity manifest, checking its tarball instead")
+	}
+
+	backupFile, err := downloadToTempFile(info.backup, info.backupStore, c.logger)
+	if err != nil {
+		return nil, err
+	}
+	info.backupFile = backupFile
+
+	inventory, err := archive.ReadInventory(backupFile, archive.CompressionAlgorithmForBackup(info.backup))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = backupFile.Seek(0, 0)
+	return inventory, errors.Wrap(err, "error resetting backup file offset to 0")
+}
+
+func readContentIndex(info *backupInfo) (archive.Inventory, error) {
+	index, err := info.backupStore.GetBackupContentIndex(info.backup.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	return archive.ReadContentIndex(index)
+}
+
+// canCreate returns whether the server is allowed to create the given
+// group-resource in the given namespace ("" for cluster-scoped resources).
+func (c *restoreController) canCreate(gr schema.GroupResource, namespace string) (bool, error) {
+	review := &authorizationv1api.SelfSubjectAccessReview{
+		Spec: authorizationv1api.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1api.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "create",
+				Group:     gr.Group,
+				Resource:  gr.Resource,
+			},
+		},
+	}
+
+	res, err := c.accessReviewClient.SelfSubjectAccessReviews().Create(review)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return res.Status.Allowed, nil
+}
+
 // backupXorScheduleProvided returns true if exactly one of BackupName and
 // ScheduleName are non-empty for the restore, or false otherwise.
 func backupXorScheduleProvided(restore *api.Restore) bool {
@@ -361,7 +605,7 @@ func backupXorScheduleProvided(restore *api.Restore) bool {
 }
 
 // mostRecentCompletedBackup returns the most recent backup that's
-// completed from a list of backups.
+// completed, and isn't a dry run, from a list of backups.
 func mostRecentCompletedBackup(backups []*api.Backup) *api.Backup {
 	sort.Slice(backups, func(i, j int) bool {
 		// Use .After() because we want descending sort.
@@ -369,7 +613,7 @@ func mostRecentCompletedBackup(backups []*api.Backup) *api.Backup {
 	})
 
 	for _, backup := range backups {
-		if backup.Status.Phase == api.BackupPhaseCompleted {
+		if backup.Status.Phase == api.BackupPhaseCompleted && !backup.Spec.DryRun {
 			return backup
 		}
 	}
@@ -407,6 +651,75 @@ func (c *restoreController) fetchBackupInfo(backupName string, pluginManager plu
 	}, nil
 }
 
+// fetchBackupInfoFromLocation returns the named backup, read from the named
+// backup storage location rather than the one recorded in the backup, which
+// may no longer exist. It returns an error if the location doesn't exist or
+// doesn't contain the backup.
+func (c *restoreController) fetchBackupInfoFromLocation(locationName, backupName string, pluginManager plugin.Manager) (backupInfo, error) {
+	location, err := c.backupLocationLister.BackupStorageLocations(c.namespace).Get(locationName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return backupInfo{}, errors.Errorf("backup storage location %s doesn't exist", locationName)
+		}
+		return backupInfo{}, errors.WithStack(err)
+	}
+
+	backupStore, err := c.newBackupStore(location, pluginManager, c.logger)
+	if err != nil {
+		return backupInfo{}, err
+	}
+
+	// the completion marker isn't required here, since backups uploaded
+	// before it existed don't have one; validateAndComplete checks it for
+	// backups that should.
+	exists, err := persistence.HasBackupData(backupStore, backupName)
+	if err != nil {
+		return backupInfo{}, err
+	}
+	if !exists {
+		return backupInfo{}, errors.Errorf("backup %s not found in backup storage location %s", backupName, locationName)
+	}
+
+	backup, err := c.backupLister.Backups(c.namespace).Get(backupName)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return backupInfo{}, errors.WithStack(err)
+		}
+
+		c.logger.WithFields(logrus.Fields{
+			"backupName":   backupName,
+			"locationName": locationName,
+		}).Debug("Backup not found in backupLister, fetching it from the backup storage location")
+		return c.backupInfoForLocation(location, backupName, pluginManager)
+	}
+
+	return backupInfo{
+		backup:      backup,
+		backupStore: backupStore,
+	}, nil
+}
+
+// transferBackupInfo returns the backupInfo for a backup that's to be
+// received from the transfer endpoint. The restore's log and results are
+// stored in the default backup storage location, since the backup itself
+// isn't in object storage.
+func (c *restoreController) transferBackupInfo(pluginManager plugin.Manager) (backupInfo, error) {
+	location, err := c.backupLocationLister.BackupStorageLocations(c.namespace).Get(c.defaultBackupLocation)
+	if err != nil {
+		return backupInfo{}, errors.WithStack(err)
+	}
+
+	backupStore, err := c.newBackupStore(location, pluginManager, c.logger)
+	if err != nil {
+		return backupInfo{}, err
+	}
+
+	return backupInfo{
+		backupStore: backupStore,
+		transfer:    true,
+	}, nil
+}
+
 // fetchFromBackupStorage checks each backup storage location, starting with the default,
 // looking for a backup that matches the given backup name.
 func (c *restoreController) fetchFromBackupStorage(backupName string, pluginManager plugin.Manager) (backupInfo, error) {
@@ -452,7 +765,7 @@ func orderedBackupLocations(locations []*api.BackupStorageLocation, defaultLocat
 }
 
 func (c *restoreController) backupInfoForLocation(location *api.BackupStorageLocation, backupName string, pluginManager plugin.Manager) (backupInfo, error) {
-	backupStore, err := persistence.NewObjectBackupStore(location, pluginManager, c.logger)
+	backupStore, err := c.newBackupStore(location, pluginManager, c.logger)
 	if err != nil {
 		return backupInfo{}, err
 	}
@@ -482,6 +795,7 @@ func (c *restoreController) runRestore(
 	restore *api.Restore,
 	actions []restore.ItemAction,
 	info backupInfo,
+	pluginVersions map[string]string,
 ) (restoreWarnings, restoreErrors api.RestoreResult, restoreFailure error) {
 	logFile, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -513,14 +827,63 @@ func (c *restoreController) runRestore(
 			"backup":  restore.Spec.BackupName,
 		})
 
-	backupFile, err := downloadToTempFile(restore.Spec.BackupName, info.backupStore, c.logger)
-	if err != nil {
-		log.WithError(err).Error("Error downloading backup")
-		restoreErrors.Ark = append(restoreErrors.Ark, err.Error())
-		restoreFailure = err
-		return
+	// a transferred backup is only received now, rather than while the
+	// restore's validated, since its sender may take a long time to arrive.
+	if info.transfer {
+		log.Info("Waiting for backup from transfer endpoint")
+
+		info.backup, info.contents, err = c.transferEndpoint.Receive(restore.Spec.BackupName)
+		if err != nil {
+			err = errors.Wrap(err, "error receiving backup from transfer endpoint")
+			log.WithError(err).Error("Error receiving backup")
+			restoreErrors.Ark = append(restoreErrors.Ark, err.Error())
+			restoreFailure = err
+			return
+		}
+		defer info.contents.Close()
+
+		restore.Spec.ScheduleName = info.backup.GetLabels()["ark-schedule"]
+	}
+
+	// a backup that was downloaded to check permissions is removed by
+	// processRestore.
+	backupFile := info.backupFile
+	if backupFile == nil {
+		if info.contents != nil {
+			backupFile, err = copyToTempFile(info.backup, info.contents, c.logger)
+		} else {
+			backupFile, err = downloadToTempFile(info.backup, info.backupStore, c.logger)
+		}
+		if err != nil {
+			log.WithError(err).Error("Error downloading backup")
+			restoreErrors.Ark = append(restoreErrors.Ark, err.Error())
+			restoreFailure = err
+			return
+		}
+		defer closeAndRemoveFile(backupFile, c.logger)
+	}
+
+	// the manifest describes the backup's own tarball, so it's checked
+	// before an incremental backup is layered over its base.
+	if restore.Spec.VerifyManifest {
+		if err := verifyManifest(info, backupFile); err != nil {
+			log.WithError(err).Error("Error verifying backup against its manifest")
+			restoreErrors.Ark = append(restoreErrors.Ark, err.Error())
+			restoreFailure = err
+			return
+		}
+	}
+
+	if info.backup.Annotations[api.BaseBackupAnnotation] != "" {
+		backupFile, err = layerOverBaseBackup(info.backup, backupFile, info.backupStore, c.logger)
+		if err != nil {
+			log.WithError(err).Error("Error layering incremental backup over its base backup")
+			restoreErrors.Ark = append(restoreErrors.Ark, err.Error())
+			restoreFailure = err
+			return
+		}
+		defer closeAndRemoveFile(backupFile, c.logger)
 	}
-	defer closeAndRemoveFile(backupFile, c.logger)
 
 	resultsFile, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -533,10 +896,31 @@ func (c *restoreController) runRestore(
 
 	// Any return statement above this line means a total restore failure
 	// Some failures after this line *may* be a total restore failure
+	versionWarnings := pluginVersionWarnings(info.backup, pluginVersions, log)
+	for _, warning := range versionWarnings {
+		log.Warn(warning)
+	}
+
 	log.Info("starting restore")
 	restoreWarnings, restoreErrors = c.restorer.Restore(log, restore, info.backup, backupFile, actions)
 	log.Info("restore completed")
 
+	restoreWarnings.Ark = append(restoreWarnings.Ark, versionWarnings...)
+
+	if restore.Spec.VerifyItemCounts {
+		if _, err := backupFile.Seek(0, 0); err != nil {
+			restoreWarnings.Ark = append(restoreWarnings.Ark, fmt.Sprintf("unable to verify item counts: error resetting backup file offset to 0: %v", err))
+		} else if inventory, err := archive.ReadInventory(backupFile, archive.CompressionAlgorithmForBackup(info.backup)); err != nil {
+			restoreWarnings.Ark = append(restoreWarnings.Ark, fmt.Sprintf("unable to verify item counts: %v", err))
+		} else {
+			countWarnings := verifyItemCounts(restore, inventory)
+			for _, warning := range countWarnings {
+				log.Warn(warning)
+			}
+			restoreWarnings.Ark = append(restoreWarnings.Ark, countWarnings...)
+		}
+	}
+
 	// Try to upload the log file. This is best-effort. If we fail, we'll add to the ark errors.
 	if err := gzippedLogFile.Close(); err != nil {
 		c.logger.WithError(err).Error("error closing gzippedLogFile")
@@ -575,28 +959,149 @@ func (c *restoreController) runRestore(
 	return
 }
 
+// pluginVersionWarnings compares the plugin versions recorded when the backup
+// was taken with the versions currently available, and returns a warning
+// for each plugin whose version changed significantly. Plugins that only
+// exist on one side are ignored.
+func pluginVersionWarnings(backup *api.Backup, current map[string]string, log logrus.FieldLogger) []string {
+	data := backup.Annotations[api.PluginVersionsAnnotation]
+	if data == "" {
+		return nil
+	}
+
+	var recorded map[string]string
+	if err := json.Unmarshal([]byte(data), &recorded); err != nil {
+		log.WithError(errors.WithStack(err)).Warn("Unable to parse backup's plugin versions")
+		return nil
+	}
+
+	var warnings []string
+	for _, plugin := range sets.StringKeySet(recorded).List() {
+		currentVersion, found := current[plugin]
+		if !found || !significantVersionChange(recorded[plugin], currentVersion) {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf("plugin %s was version %s when the backup was taken but is now version %s", plugin, recorded[plugin], currentVersion))
+	}
+
+	return warnings
+}
+
+// significantVersionChange returns true if two plugin versions differ by
+// more than a patch release. Versions that aren't of the form
+// vMAJOR.MINOR[.PATCH], such as executable digests, are significant if they
+// differ at all. Unknown (empty) versions are never significant.
+func significantVersionChange(oldVersion, newVersion string) bool {
+	if oldVersion == "" || newVersion == "" || oldVersion == newVersion {
+		return false
+	}
+
+	oldParts := strings.SplitN(strings.TrimPrefix(oldVersion, "v"), ".", 3)
+	newParts := strings.SplitN(strings.TrimPrefix(newVersion, "v"), ".", 3)
+	if len(oldParts) < 2 || len(newParts) < 2 {
+		return true
+	}
+
+	return oldParts[0] != newParts[0] || oldParts[1] != newParts[1]
+}
+
+// verifyManifest checks the backup's tarball, in backupFile, against the
+// manifest uploaded alongside it, and returns backupFile to its start.
+func verifyManifest(info backupInfo, backupFile *os.File) error {
+	if !info.backup.Spec.IntegrityManifest {
+		return errors.Errorf("backup %s doesn't have an integrity manifest", info.backup.Name)
+	}
+	if info.contents != nil {
+		return errors.Errorf("backup %s was received from a transfer endpoint, so its integrity manifest wasn't uploaded", info.backup.Name)
+	}
+
+	entries, err := info.backupStore.GetBackupManifest(info.backup.Name)
+	if err != nil {
+		return errors.Wrap(err, "error getting backup's integrity manifest")
+	}
+
+	if err := archive.VerifyManifest(backupFile, archive.CompressionAlgorithmForBackup(info.backup), entries); err != nil {
+		return errors.Wrap(err, "backup's tarball doesn't match its integrity manifest")
+	}
+
+	_, err = backupFile.Seek(0, 0)
+	return errors.Wrap(err, "error resetting backup file offset to 0")
+}
+
+// verifyItemCounts records the number of items of each group-resource in the
+// backup's inventory in the restore's item counts, and returns a warning for
+// each group-resource with items that were neither restored nor skipped.
+// Namespaces aren't verified, since they're created as needed rather than
+// restored like other items.
+func verifyItemCounts(restore *api.Restore, inventory archive.Inventory) []string {
+	counts := make(map[string]api.RestoreItemCount)
+	for _, count := range restore.Status.ItemCounts {
+		counts[count.GroupResource] = count
+	}
+
+	var warnings []string
+	for _, groupResource := range sets.StringKeySet(inventory).List() {
+		if groupResource == kuberesource.Namespaces.String() {
+			continue
+		}
+
+		items := inventory[groupResource]
+		count := counts[groupResource]
+		count.GroupResource = groupResource
+		count.InBackup = len(items.ClusterScoped)
+		for _, names := range items.Namespaced {
+			count.InBackup += len(names)
+		}
+		counts[groupResource] = count
+
+		if missing := count.InBackup - count.Restored - count.Skipped; missing > 0 {
+			warnings = append(warnings, fmt.Sprintf("%d of the %d %s in the backup were neither restored nor skipped (%d restored, %d skipped)", missing, count.InBackup, groupResource, count.Restored, count.Skipped))
+		}
+	}
+
+	restore.Status.ItemCounts = nil
+	for _, groupResource := range sets.StringKeySet(counts).List() {
+		restore.Status.ItemCounts = append(restore.Status.ItemCounts, counts[groupResource])
+	}
+
+	return warnings
+}
+
 func downloadToTempFile(
-	backupName string,
+	backup *api.Backup,
 	backupStore persistence.BackupStore,
 	logger logrus.FieldLogger,
 ) (*os.File, error) {
-	readCloser, err := backupStore.GetBackupContents(backupName)
+	readCloser, err := backupStore.GetBackupContents(backup.Name)
 	if err != nil {
 		return nil, err
 	}
 	defer readCloser.Close()
 
-	file, err := ioutil.TempFile("", backupName)
+	return copyToTempFile(backup, readCloser, logger)
+}
+
+// copyToTempFile copies a backup's contents to a temp file, undoing any
+// transform stages recorded on the backup, and returns the file positioned
+// at its start.
+func copyToTempFile(backup *api.Backup, contents io.Reader, logger logrus.FieldLogger) (*os.File, error) {
+	untransformed, err := untransform(backup, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile("", backup.Name)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating Backup temp file")
 	}
 
-	n, err := io.Copy(file, readCloser)
+	n, err := io.Copy(file, untransformed)
 	if err != nil {
 		return nil, errors.Wrap(err, "error copying Backup to temp file")
 	}
 
-	log := logger.WithField("backup", backupName)
+	log := logger.WithField("backup", backup.Name)
 
 	log.WithFields(logrus.Fields{
 		"fileName": file.Name(),
@@ -610,6 +1115,67 @@ func downloadToTempFile(
 	return file, nil
 }
 
+// layerOverBaseBackup reconstructs the full tarball of an incremental
+// backup, whose own tarball is in backupFile, by layering it over its base
+// backup's, and returns a temp file containing it, positioned at its start.
+func layerOverBaseBackup(backup *api.Backup, backupFile *os.File, backupStore persistence.BackupStore, logger logrus.FieldLogger) (*os.File, error) {
+	baseName := backup.Annotations[api.BaseBackupAnnotation]
+	if backupStore == nil {
+		return nil, errors.Errorf("backup %s is incremental, so it can only be restored from its storage location", backup.Name)
+	}
+
+	base, err := backupStore.GetBackupMetadata(baseName)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error getting base backup "+baseName)
+	}
+
+	contents, err := backupStore.GetBackupContents(baseName)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error getting base backup "+baseName)
+	}
+	defer contents.Close()
+
+	untransformed, err := untransform(base, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile("", backup.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating Backup temp file")
+	}
+
+	algorithm := archive.CompressionAlgorithmForBackup(backup)
+	if err := archive.LayerIncremental(file, algorithm, backupFile, algorithm, untransformed, archive.CompressionAlgorithmForBackup(base)); err != nil {
+		closeAndRemoveFile(file, logger)
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"backup":     backup.Name,
+		"baseBackup": baseName,
+		"fileName":   file.Name(),
+	}).Debug("Layered incremental backup over its base backup")
+
+	if _, err := file.Seek(0, 0); err != nil {
+		closeAndRemoveFile(file, logger)
+		return nil, errors.Wrap(err, "error resetting Backup file offset")
+	}
+
+	return file, nil
+}
+
+// untransform returns a reader that undoes, in reverse order, the transform
+// stages recorded on the backup on its contents.
+func untransform(backup *api.Backup, contents io.Reader) (io.Reader, error) {
+	pipeline, err := transform.ForBackup(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	return pipeline.Unwrap(contents)
+}
+
 func patchRestore(original, updated *api.Restore, client arkv1client.RestoresGetter) (*api.Restore, error) {
 	origBytes, err := json.Marshal(original)
 	if err != nil {
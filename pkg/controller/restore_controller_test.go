@@ -17,10 +17,13 @@ limitations under the License.
 package controller
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,12 +33,18 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	authorizationv1api "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
+	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/generated/clientset/versioned/fake"
 	informers "github.com/heptio/ark/pkg/generated/informers/externalversions"
 	"github.com/heptio/ark/pkg/metrics"
@@ -108,8 +117,12 @@ func TestFetchBackupInfo(t *testing.T) {
 				logger,
 				logrus.InfoLevel,
 				func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				nil,
 				"default",
 				metrics.NewServerMetrics(),
+				nil,
+				nil,
+				nil,
 			).(*restoreController)
 
 			c.newBackupStore = func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
@@ -201,8 +214,12 @@ func TestProcessRestoreSkips(t *testing.T) {
 				logger,
 				logrus.InfoLevel,
 				nil,
+				nil,
 				"default",
 				metrics.NewServerMetrics(),
+				nil,
+				nil,
+				nil,
 			).(*restoreController)
 
 			if test.restore != nil {
@@ -232,6 +249,7 @@ func TestProcessRestore(t *testing.T) {
 		expectedRestorerCall            *api.Restore
 		backupStoreGetBackupMetadataErr error
 		backupStoreGetBackupContentsErr error
+		backupStoreArtifacts            []persistence.BackupArtifact
 		putRestoreLogErr                error
 		expectedFinalPhase              string
 	}{
@@ -329,6 +347,34 @@ func TestProcessRestore(t *testing.T) {
 			expectedPhase:            string(api.RestorePhaseFailedValidation),
 			expectedValidationErrors: []string{"Server is not configured for PV snapshot restores"},
 		},
+		{
+			name:                     "restore with invalid existing resource and immutable field policies fails validation",
+			location:                 arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("myCloud").WithObjectStorage("bucket").BackupStorageLocation,
+			restore:                  NewRestore("foo", "bar", "backup-1", "ns-1", "", api.RestorePhaseNew).WithExistingResourcePolicy("overwrite").WithImmutableFieldPolicy("ignore").Restore,
+			backup:                   arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").Backup,
+			expectedErr:              false,
+			expectedPhase:            string(api.RestorePhaseFailedValidation),
+			expectedValidationErrors: []string{`Invalid existing resource policy "overwrite"`, `Invalid immutable field policy "ignore"`},
+		},
+		{
+			name:                     "restore of an incompletely uploaded backup fails validation",
+			location:                 arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("myCloud").WithObjectStorage("bucket").BackupStorageLocation,
+			restore:                  NewRestore("foo", "bar", "backup-1", "ns-1", "", api.RestorePhaseNew).Restore,
+			backup:                   arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").WithAnnotation(api.CompletionMarkerAnnotation, "true").Backup,
+			backupStoreArtifacts:     []persistence.BackupArtifact{persistence.BackupArtifactMetadata, persistence.BackupArtifactContents},
+			expectedErr:              false,
+			expectedPhase:            string(api.RestorePhaseFailedValidation),
+			expectedValidationErrors: []string{"Backup is incomplete: its upload to the backup storage location didn't finish"},
+		},
+		{
+			name:                     "restore of a backup with a newer format version than the server understands fails validation",
+			location:                 arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("myCloud").WithObjectStorage("bucket").BackupStorageLocation,
+			restore:                  NewRestore("foo", "bar", "backup-1", "ns-1", "", api.RestorePhaseNew).Restore,
+			backup:                   arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("default").WithVersion(persistence.BackupFormatVersion + 1).Backup,
+			expectedErr:              false,
+			expectedPhase:            string(api.RestorePhaseFailedValidation),
+			expectedValidationErrors: []string{"Unsupported backup format: backup backup-1 has format version 3, but this server only understands versions up to 2; upgrade Ark to restore it"},
+		},
 		{
 			name:          "restoration of nodes is not supported",
 			location:      arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("myCloud").WithObjectStorage("bucket").BackupStorageLocation,
@@ -426,8 +472,12 @@ func TestProcessRestore(t *testing.T) {
 				logger,
 				logrus.InfoLevel,
 				func(logrus.FieldLogger) plugin.Manager { return pluginManager },
+				nil,
 				"default",
 				metrics.NewServerMetrics(),
+				nil,
+				nil,
+				nil,
 			).(*restoreController)
 
 			c.newBackupStore = func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
@@ -516,6 +566,10 @@ func TestProcessRestore(t *testing.T) {
 				backupStore.On("GetBackupMetadata", test.restore.Spec.BackupName).Return(nil, test.backupStoreGetBackupMetadataErr).Maybe()
 			}
 
+			if test.backupStoreArtifacts != nil {
+				backupStore.On("ListBackupArtifacts", test.backup.Name).Return(test.backupStoreArtifacts, nil)
+			}
+
 			if test.backupStoreGetBackupContentsErr != nil {
 				// TODO why do I need .Maybe() here?
 				backupStore.On("GetBackupContents", test.restore.Spec.BackupName).Return(nil, test.backupStoreGetBackupContentsErr).Maybe()
@@ -523,6 +577,7 @@ func TestProcessRestore(t *testing.T) {
 
 			if test.restore != nil {
 				pluginManager.On("GetRestoreItemActions").Return(nil, nil)
+				pluginManager.On("GetPluginVersions").Return(map[string]string{}).Maybe()
 				pluginManager.On("CleanupClients")
 			}
 
@@ -633,8 +688,12 @@ func TestvalidateAndCompleteWhenScheduleNameSpecified(t *testing.T) {
 		logger,
 		logrus.DebugLevel,
 		nil,
+		nil,
 		"default",
 		nil,
+		nil,
+		nil,
+		nil,
 	).(*restoreController)
 
 	restore := &api.Restore{
@@ -698,6 +757,256 @@ func TestvalidateAndCompleteWhenScheduleNameSpecified(t *testing.T) {
 	assert.Equal(t, "bar", restore.Spec.BackupName)
 }
 
+// fakeTransferEndpoint is a transfer.Endpoint that returns a fixed backup
+// when one is received, and records the names of the backups sent to it
+// and that it was asked to receive.
+type fakeTransferEndpoint struct {
+	backup   *api.Backup
+	contents string
+	sent     []string
+	received []string
+}
+
+func (e *fakeTransferEndpoint) Send(backup *api.Backup, _ io.Reader) error {
+	e.sent = append(e.sent, backup.Name)
+	return nil
+}
+
+func (e *fakeTransferEndpoint) Receive(name string) (*api.Backup, io.ReadCloser, error) {
+	e.received = append(e.received, name)
+	if e.backup == nil || e.backup.Name != name {
+		return nil, nil, errors.New("no backup sent")
+	}
+	return e.backup, ioutil.NopCloser(strings.NewReader(e.contents)), nil
+}
+
+func newTransferRestore(backupName, scheduleName string) *api.Restore {
+	return &api.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   api.DefaultNamespace,
+			Name:        "restore-1",
+			Annotations: map[string]string{api.TransferAnnotation: "true"},
+		},
+		Spec: api.RestoreSpec{
+			BackupName:   backupName,
+			ScheduleName: scheduleName,
+		},
+	}
+}
+
+func TestValidateAndCompleteWithTransferEndpoint(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+		endpoint        = &fakeTransferEndpoint{}
+	)
+
+	c := NewRestoreController(
+		api.DefaultNamespace,
+		sharedInformers.Ark().V1().Restores(),
+		client.ArkV1(),
+		client.ArkV1(),
+		nil,
+		sharedInformers.Ark().V1().Backups(),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		false,
+		logger,
+		logrus.DebugLevel,
+		nil,
+		nil,
+		"default",
+		nil,
+		nil,
+		nil,
+		nil,
+	).(*restoreController)
+
+	c.newBackupStore = func(*api.BackupStorageLocation, persistence.ObjectStoreGetter, logrus.FieldLogger) (persistence.BackupStore, error) {
+		return backupStore, nil
+	}
+
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(
+		arktest.NewTestBackupStorageLocation().WithName("default").WithProvider("myCloud").WithObjectStorage("bucket").BackupStorageLocation,
+	))
+
+	// restoring from a schedule isn't supported
+	restore := newTransferRestore("", "schedule-1")
+	c.validateAndComplete(restore, pluginManager)
+	assert.Equal(t, []string{"A backup name must be specified when restoring from a transfer endpoint"}, restore.Status.ValidationErrors)
+
+	// the server must be configured with a transfer endpoint
+	restore = newTransferRestore("backup-1", "")
+	c.validateAndComplete(restore, pluginManager)
+	assert.Equal(t, []string{"Server is not configured with a transfer endpoint"}, restore.Status.ValidationErrors)
+
+	// the backup isn't received until the restore runs, and its results
+	// go to the default location.
+	c.transferEndpoint = endpoint
+	restore = newTransferRestore("backup-1", "")
+	info := c.validateAndComplete(restore, pluginManager)
+	assert.Empty(t, restore.Status.ValidationErrors)
+	assert.True(t, info.transfer)
+	assert.Nil(t, info.backup)
+	assert.Equal(t, backupStore, info.backupStore)
+	assert.Empty(t, endpoint.received)
+}
+
+func TestRunRestoreReceivesTransferredBackup(t *testing.T) {
+	tests := []struct {
+		name                 string
+		endpoint             *fakeTransferEndpoint
+		expectedScheduleName string
+		expectedFailure      string
+	}{
+		{
+			name: "backup is received and restored",
+			endpoint: &fakeTransferEndpoint{
+				backup:   arktest.NewTestBackup().WithName("backup-1").WithLabel("ark-schedule", "schedule-1").Backup,
+				contents: "contents",
+			},
+			expectedScheduleName: "schedule-1",
+		},
+		{
+			name:            "error receiving the backup fails the restore",
+			endpoint:        &fakeTransferEndpoint{},
+			expectedFailure: "error receiving backup from transfer endpoint: no backup sent",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				restorer    = &fakeRestorer{}
+				backupStore = &persistencemocks.BackupStore{}
+				restore     = newTransferRestore("backup-1", "")
+			)
+
+			c := &restoreController{
+				genericController: newGenericController("restore-test", arktest.NewLogger()),
+				restorer:          restorer,
+				transferEndpoint:  test.endpoint,
+			}
+
+			restorer.On("Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(api.RestoreResult{}, api.RestoreResult{})
+			backupStore.On("PutRestoreLog", "backup-1", "restore-1", mock.Anything).Return(nil)
+			backupStore.On("PutRestoreResults", "backup-1", "restore-1", mock.Anything).Return(nil)
+
+			_, _, failure := c.runRestore(restore, nil, backupInfo{backupStore: backupStore, transfer: true}, nil)
+
+			assert.Equal(t, []string{"backup-1"}, test.endpoint.received)
+			if test.expectedFailure != "" {
+				require.Error(t, failure)
+				assert.Equal(t, test.expectedFailure, failure.Error())
+				restorer.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			require.NoError(t, failure)
+			restorer.AssertNumberOfCalls(t, "Restore", 1)
+			assert.Equal(t, test.endpoint.backup, restorer.Calls[0].Arguments.Get(2))
+			assert.Equal(t, test.expectedScheduleName, restore.Spec.ScheduleName)
+		})
+	}
+}
+
+func TestValidateAndCompleteWithStorageLocationOverride(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		logger          = arktest.NewLogger()
+		pluginManager   = &pluginmocks.Manager{}
+		backupStore     = &persistencemocks.BackupStore{}
+	)
+	defer backupStore.AssertExpectations(t)
+
+	c := NewRestoreController(
+		api.DefaultNamespace,
+		sharedInformers.Ark().V1().Restores(),
+		client.ArkV1(),
+		client.ArkV1(),
+		nil,
+		sharedInformers.Ark().V1().Backups(),
+		sharedInformers.Ark().V1().BackupStorageLocations(),
+		false,
+		logger,
+		logrus.DebugLevel,
+		nil,
+		nil,
+		"default",
+		nil,
+		nil,
+		nil,
+		nil,
+	).(*restoreController)
+
+	var storeLocations []string
+	c.newBackupStore = func(location *api.BackupStorageLocation, _ persistence.ObjectStoreGetter, _ logrus.FieldLogger) (persistence.BackupStore, error) {
+		storeLocations = append(storeLocations, location.Name)
+		return backupStore, nil
+	}
+
+	// the backup was written to a location that no longer exists.
+	backup := arktest.NewTestBackup().WithName("backup-1").WithStorageLocation("old-bucket").Backup
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(backup))
+	require.NoError(t, sharedInformers.Ark().V1().BackupStorageLocations().Informer().GetStore().Add(
+		arktest.NewTestBackupStorageLocation().WithName("new-bucket").WithProvider("myCloud").WithObjectStorage("new-bucket").BackupStorageLocation,
+	))
+
+	newRestore := func(backupName, locationName string) *api.Restore {
+		return &api.Restore{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: api.DefaultNamespace,
+				Name:      "restore-1",
+			},
+			Spec: api.RestoreSpec{
+				BackupName:              backupName,
+				StorageLocationOverride: locationName,
+			},
+		}
+	}
+
+	// without an override, the backup's recorded location is used
+	restore := newRestore("backup-1", "")
+	c.validateAndComplete(restore, pluginManager)
+	require.Len(t, restore.Status.ValidationErrors, 1)
+	assert.Contains(t, restore.Status.ValidationErrors[0], "Error retrieving backup")
+	assert.Empty(t, storeLocations)
+
+	// the override location must exist
+	restore = newRestore("backup-1", "missing")
+	c.validateAndComplete(restore, pluginManager)
+	assert.Equal(t, []string{"Error retrieving backup: backup storage location missing doesn't exist"}, restore.Status.ValidationErrors)
+
+	// and contain the backup's metadata and tarball
+	backupStore.On("ListBackupArtifacts", "backup-2").Return([]persistence.BackupArtifact{persistence.BackupArtifactMetadata}, nil)
+	restore = newRestore("backup-2", "new-bucket")
+	c.validateAndComplete(restore, pluginManager)
+	assert.Equal(t, []string{"Error retrieving backup: backup backup-2 not found in backup storage location new-bucket"}, restore.Status.ValidationErrors)
+
+	// a backup that was uploaded with a completion marker must still have it
+	incomplete := arktest.NewTestBackup().WithName("backup-3").WithStorageLocation("old-bucket").
+		WithAnnotation(api.CompletionMarkerAnnotation, "true").Backup
+	require.NoError(t, sharedInformers.Ark().V1().Backups().Informer().GetStore().Add(incomplete))
+	backupStore.On("ListBackupArtifacts", "backup-3").Return([]persistence.BackupArtifact{persistence.BackupArtifactMetadata, persistence.BackupArtifactContents}, nil)
+	restore = newRestore("backup-3", "new-bucket")
+	c.validateAndComplete(restore, pluginManager)
+	assert.Equal(t, []string{"Backup is incomplete: its upload to the backup storage location didn't finish"}, restore.Status.ValidationErrors)
+
+	// the backup is read from the override location rather than its own,
+	// even though it was uploaded before completion markers existed.
+	storeLocations = nil
+	backupStore.On("ListBackupArtifacts", "backup-1").Return([]persistence.BackupArtifact{persistence.BackupArtifactMetadata, persistence.BackupArtifactContents}, nil)
+	restore = newRestore("backup-1", "new-bucket")
+	info := c.validateAndComplete(restore, pluginManager)
+	assert.Empty(t, restore.Status.ValidationErrors)
+	assert.Equal(t, []string{"new-bucket"}, storeLocations)
+	assert.Equal(t, backup, info.backup)
+	assert.Equal(t, backupStore, info.backupStore)
+}
+
 func TestBackupXorScheduleProvided(t *testing.T) {
 	r := &api.Restore{}
 	assert.False(t, backupXorScheduleProvided(r))
@@ -786,6 +1095,273 @@ func TestMostRecentCompletedBackup(t *testing.T) {
 	backups = append(backups, expected)
 
 	assert.Equal(t, expected, mostRecentCompletedBackup(backups))
+
+	// dry runs have no contents, so they're never the most recent backup
+	backups = append(backups, &api.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "dry-run",
+		},
+		Spec: api.BackupSpec{
+			DryRun: true,
+		},
+		Status: api.BackupStatus{
+			Phase:          api.BackupPhaseCompleted,
+			StartTimestamp: metav1.Time{Time: now.Add(2 * time.Second)},
+		},
+	})
+
+	assert.Equal(t, expected, mostRecentCompletedBackup(backups))
+}
+
+func TestValidatePermissions(t *testing.T) {
+	var (
+		backupStore  = &persistencemocks.BackupStore{}
+		accessReview = &fakeAccessReviewClient{
+			allowed: sets.NewString("/namespaces", "ns-1/pods", "ns-2/pods", "ns-1/deployments.apps"),
+		}
+		c = &restoreController{
+			genericController:  newGenericController("restore-test", arktest.NewLogger()),
+			accessReviewClient: accessReview,
+		}
+	)
+
+	paths := []string{
+		"resources/namespaces/cluster/ns-1.json",
+		"resources/persistentvolumes/cluster/pv-1.json",
+		"resources/pods/namespaces/ns-1/pod-1.json",
+		"resources/pods/namespaces/ns-2/pod-1.json",
+		"resources/deployments.apps/namespaces/ns-1/deploy-1.json",
+		"resources/deployments.apps/namespaces/ns-2/deploy-1.json",
+		"resources/deployments.apps/namespaces/ns-3/deploy-1.json",
+		"resources/secrets/namespaces/ns-1/secret-1.json",
+	}
+	tarball := newTestTarball(t, paths...).Bytes()
+	backupStore.On("GetBackupContents", "backup-1").Return(ioutil.NopCloser(bytes.NewReader(tarball)), nil).Once()
+
+	restore := NewRestore(api.DefaultNamespace, "restore-1", "backup-1", "", "", api.RestorePhaseNew).
+		WithExcludedResource("secrets").
+		WithExcludedNamespace("ns-3").
+		Restore
+
+	// a backup without a content index or manifest has its tarball
+	// downloaded, and kept for the restore.
+	info := &backupInfo{backup: arktest.NewTestBackup().WithName("backup-1").Backup, backupStore: backupStore}
+	errs := c.validatePermissions(restore, info)
+
+	assert.Equal(t, []string{
+		"Not permitted to create deployments.apps in namespaces: ns-2",
+		"Not permitted to create persistentvolumes",
+	}, errs)
+
+	require.NotNil(t, info.backupFile)
+	defer closeAndRemoveFile(info.backupFile, c.logger)
+	contents, err := ioutil.ReadAll(info.backupFile)
+	require.NoError(t, err)
+	assert.Equal(t, tarball, contents)
+	backupStore.AssertNumberOfCalls(t, "GetBackupContents", 1)
+
+	// namespace mappings should be applied to the namespaces checked. A
+	// backup with a content index has it read instead of its tarball.
+	restore.Spec.NamespaceMapping = map[string]string{"ns-2": "ns-1"}
+	accessReview.reviewed = nil
+
+	index := "# ark-content-index v1\n" +
+		"/v1/namespaces//ns-1\n" +
+		"/v1/persistentvolumes//pv-1\n" +
+		"/v1/pods/ns-1/pod-1\n" +
+		"/v1/pods/ns-2/pod-1\n" +
+		"apps/v1/deployments/ns-1/deploy-1\n" +
+		"apps/v1/deployments/ns-2/deploy-1\n" +
+		"apps/v1/deployments/ns-3/deploy-1\n" +
+		"/v1/secrets/ns-1/secret-1\n"
+	backupStore.On("GetBackupContentIndex", "backup-1").Return(ioutil.NopCloser(strings.NewReader(index)), nil).Once()
+
+	info = &backupInfo{
+		backup:      arktest.NewTestBackup().WithName("backup-1").WithAnnotation(api.ContentIndexVersionAnnotation, "1").Backup,
+		backupStore: backupStore,
+	}
+	errs = c.validatePermissions(restore, info)
+
+	assert.Equal(t, []string{"Not permitted to create persistentvolumes"}, errs)
+	assert.NotContains(t, accessReview.reviewed, "ns-2/pods")
+	assert.Nil(t, info.backupFile)
+
+	// a backup with an integrity manifest has it read instead of its
+	// tarball.
+	var entries []archive.ManifestEntry
+	for _, path := range paths {
+		entries = append(entries, archive.NewManifestEntry(path, 0, 0))
+	}
+	backupStore.On("GetBackupManifest", "backup-1").Return(entries, nil).Once()
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.IntegrityManifest = true
+	info = &backupInfo{backup: backup, backupStore: backupStore}
+	errs = c.validatePermissions(restore, info)
+
+	assert.Equal(t, []string{"Not permitted to create persistentvolumes"}, errs)
+	assert.Nil(t, info.backupFile)
+	backupStore.AssertNumberOfCalls(t, "GetBackupContents", 1)
+}
+
+func TestValidatePermissionsResolvesResources(t *testing.T) {
+	var (
+		backupStore  = &persistencemocks.BackupStore{}
+		accessReview = &fakeAccessReviewClient{
+			allowed: sets.NewString("ns-1/deployments.apps"),
+		}
+		c = &restoreController{
+			genericController:  newGenericController("restore-test", arktest.NewLogger()),
+			accessReviewClient: accessReview,
+			discoveryHelper: arktest.NewFakeDiscoveryHelper(false, map[schema.GroupVersionResource]schema.GroupVersionResource{
+				{Group: "extensions", Resource: "deployments"}: {Group: "apps", Version: "v1", Resource: "deployments"},
+			}),
+		}
+	)
+
+	// resources are checked under the names they're served as now, and
+	// ones that aren't served, e.g. because their CRDs are restored first,
+	// aren't checked.
+	var entries []archive.ManifestEntry
+	for _, path := range []string{
+		"resources/deployments.extensions/namespaces/ns-1/deploy-1.json",
+		"resources/widgets.example.com/namespaces/ns-1/widget-1.json",
+	} {
+		entries = append(entries, archive.NewManifestEntry(path, 0, 0))
+	}
+	backupStore.On("GetBackupManifest", "backup-1").Return(entries, nil)
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Spec.IntegrityManifest = true
+	restore := NewRestore(api.DefaultNamespace, "restore-1", "backup-1", "", "", api.RestorePhaseNew).Restore
+
+	errs := c.validatePermissions(restore, &backupInfo{backup: backup, backupStore: backupStore})
+
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"ns-1/deployments.apps"}, accessReview.reviewed)
+}
+
+// newTestTarball returns a gzipped tarball containing an empty regular file at
+// each of the provided paths.
+func newTestTarball(t *testing.T, paths ...string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	for _, path := range paths {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     path,
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+		}))
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return buf
+}
+
+// fakeAccessReviewClient allows SelfSubjectAccessReviews for create
+// requests whose "<namespace>/<group-resource>" is in allowed, and records
+// every review it's asked for.
+type fakeAccessReviewClient struct {
+	allowed  sets.String
+	reviewed []string
+}
+
+func (c *fakeAccessReviewClient) SelfSubjectAccessReviews() authorizationv1client.SelfSubjectAccessReviewInterface {
+	return c
+}
+
+func (c *fakeAccessReviewClient) Create(review *authorizationv1api.SelfSubjectAccessReview) (*authorizationv1api.SelfSubjectAccessReview, error) {
+	attrs := review.Spec.ResourceAttributes
+	gr := schema.GroupResource{Group: attrs.Group, Resource: attrs.Resource}
+	key := attrs.Namespace + "/" + gr.String()
+	c.reviewed = append(c.reviewed, key)
+
+	res := review.DeepCopy()
+	res.Status.Allowed = attrs.Verb == "create" && c.allowed.Has(key)
+
+	return res, nil
+}
+
+func TestVerifyItemCounts(t *testing.T) {
+	restore := &api.Restore{
+		Status: api.RestoreStatus{
+			ItemCounts: []api.RestoreItemCount{
+				{GroupResource: "pods", Restored: 2, Skipped: 1},
+				{GroupResource: "secrets", Restored: 1},
+			},
+		},
+	}
+
+	inventory := archive.Inventory{
+		"namespaces":        {ClusterScoped: []string{"ns-1"}},
+		"persistentvolumes": {ClusterScoped: []string{"pv-1"}},
+		"pods": {Namespaced: map[string][]string{
+			"ns-1": {"pod-1", "pod-2"},
+			"ns-2": {"pod-3"},
+		}},
+		"secrets": {Namespaced: map[string][]string{
+			"ns-1": {"secret-1", "secret-2"},
+		}},
+	}
+
+	warnings := verifyItemCounts(restore, inventory)
+
+	assert.Equal(t, []string{
+		"1 of the 1 persistentvolumes in the backup were neither restored nor skipped (0 restored, 0 skipped)",
+		"1 of the 2 secrets in the backup were neither restored nor skipped (1 restored, 0 skipped)",
+	}, warnings)
+
+	assert.Equal(t, []api.RestoreItemCount{
+		{GroupResource: "persistentvolumes", InBackup: 1},
+		{GroupResource: "pods", InBackup: 3, Restored: 2, Skipped: 1},
+		{GroupResource: "secrets", InBackup: 2, Restored: 1},
+	}, restore.Status.ItemCounts)
+}
+
+func TestPluginVersionWarnings(t *testing.T) {
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	backup.Annotations = map[string]string{
+		api.PluginVersionsAnnotation: `{"ObjectStore/aws":"v0.9.5","BlockStore/aws":"v0.9.5","BackupItemAction/custom":"sha256:abc","BackupItemAction/removed":"v1.0.0"}`,
+	}
+
+	current := map[string]string{
+		"ObjectStore/aws":         "v0.9.6",
+		"BlockStore/aws":          "v0.10.0",
+		"BackupItemAction/custom": "sha256:def",
+	}
+
+	expected := []string{
+		"plugin BackupItemAction/custom was version sha256:abc when the backup was taken but is now version sha256:def",
+		"plugin BlockStore/aws was version v0.9.5 when the backup was taken but is now version v0.10.0",
+	}
+	assert.Equal(t, expected, pluginVersionWarnings(backup, current, arktest.NewLogger()))
+
+	// backups taken before plugin versions were recorded have nothing to compare
+	assert.Empty(t, pluginVersionWarnings(arktest.NewTestBackup().Backup, current, arktest.NewLogger()))
+}
+
+func TestSignificantVersionChange(t *testing.T) {
+	tests := []struct {
+		oldVersion, newVersion string
+		expected               bool
+	}{
+		{oldVersion: "v0.10.0", newVersion: "v0.10.0", expected: false},
+		{oldVersion: "v0.10.0", newVersion: "v0.10.1", expected: false},
+		{oldVersion: "v0.9.0", newVersion: "v0.10.0", expected: true},
+		{oldVersion: "v0.10.0", newVersion: "v1.0.0", expected: true},
+		{oldVersion: "sha256:abc", newVersion: "sha256:def", expected: true},
+		{oldVersion: "", newVersion: "v0.10.0", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.oldVersion+"->"+test.newVersion, func(t *testing.T) {
+			assert.Equal(t, test.expected, significantVersionChange(test.oldVersion, test.newVersion))
+		})
+	}
 }
 
 func NewRestore(ns, name, backup, includeNS, includeResource string, phase api.RestorePhase) *arktest.TestRestore {
@@ -824,3 +1400,99 @@ func (r *fakeRestorer) Restore(
 
 	return res.Get(0).(api.RestoreResult), res.Get(1).(api.RestoreResult)
 }
+
+func TestDownloadToTempFileDetectsCorruptContents(t *testing.T) {
+	objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+	pluginManager := &pluginmocks.Manager{}
+	pluginManager.On("GetObjectStore", "myCloud").Return(objectStore, nil)
+
+	location := &api.BackupStorageLocation{
+		Spec: api.BackupStorageLocationSpec{
+			Provider:    "myCloud",
+			StorageType: api.StorageType{ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+	backupStore, err := persistence.NewObjectBackupStore(location, pluginManager, arktest.NewLogger())
+	require.NoError(t, err)
+
+	backup := arktest.NewTestBackup().WithName("backup-1").Backup
+	require.NoError(t, backupStore.PutBackup("backup-1", strings.NewReader("metadata"), strings.NewReader("contents"), nil, nil, nil))
+
+	file, err := downloadToTempFile(backup, backupStore, arktest.NewLogger())
+	require.NoError(t, err)
+	closeAndRemoveFile(file, arktest.NewLogger())
+
+	objectStore.Data["bucket"]["backups/backup-1/backup-1.tar.gz"] = []byte("c0ntents")
+
+	_, err = downloadToTempFile(backup, backupStore, arktest.NewLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "backup contents are corrupt")
+}
+
+func TestVerifyManifest(t *testing.T) {
+	const (
+		path     = "resources/pods/namespaces/ns-1/pod-1.json"
+		contents = `{"metadata":{"name":"pod-1"}}`
+	)
+
+	tests := []struct {
+		name              string
+		integrityManifest bool
+		entries           []archive.ManifestEntry
+		expectedErr       string
+	}{
+		{
+			name:        "backup without a manifest is an error",
+			expectedErr: "backup backup-1 doesn't have an integrity manifest",
+		},
+		{
+			name:              "matching manifest",
+			integrityManifest: true,
+			// a single file's contents follow its 512-byte header.
+			entries: []archive.ManifestEntry{archive.NewManifestEntry(path, 512, int64(len(contents)))},
+		},
+		{
+			name:              "manifest that doesn't match is an error",
+			integrityManifest: true,
+			entries:           []archive.ManifestEntry{archive.NewManifestEntry(path, 512, 1)},
+			expectedErr:       "backup's tarball doesn't match its integrity manifest",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+			backup.Spec.IntegrityManifest = test.integrityManifest
+
+			backupStore := &persistencemocks.BackupStore{}
+			backupStore.On("GetBackupManifest", "backup-1").Return(test.entries, nil)
+
+			backupFile, err := ioutil.TempFile("", "")
+			require.NoError(t, err)
+			defer closeAndRemoveFile(backupFile, arktest.NewLogger())
+
+			gzw := gzip.NewWriter(backupFile)
+			tw := tar.NewWriter(gzw)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: path, Typeflag: tar.TypeReg, Size: int64(len(contents))}))
+			_, err = tw.Write([]byte(contents))
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+			require.NoError(t, gzw.Close())
+			_, err = backupFile.Seek(0, 0)
+			require.NoError(t, err)
+
+			err = verifyManifest(backupInfo{backup: backup, backupStore: backupStore}, backupFile)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			// the file is left at its start, ready to be restored.
+			offset, err := backupFile.Seek(0, io.SeekCurrent)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), offset)
+		})
+	}
+}
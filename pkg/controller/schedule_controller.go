@@ -17,8 +17,13 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
@@ -31,6 +36,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/cache"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
@@ -43,6 +49,15 @@ import (
 
 const (
 	scheduleSyncPeriod = time.Minute
+
+	// defaultBackupNameTemplate names a schedule's backups after the
+	// schedule and the time they were created, to the second.
+	defaultBackupNameTemplate = `{{.Schedule}}-{{.Timestamp.Format "20060102150405"}}`
+
+	// maxBackupNameSequence is how many times a scheduled backup's name is
+	// rendered, with an incremented .Sequence, before giving up on finding
+	// one that isn't taken.
+	maxBackupNameSequence = 100
 )
 
 type scheduleController struct {
@@ -54,6 +69,22 @@ type scheduleController struct {
 	schedulesLister listers.ScheduleLister
 	clock           clock.Clock
 	metrics         *metrics.ServerMetrics
+	clusterName     string
+
+	// rand draws the delays added to schedules' runs by their jitter.
+	rand *rand.Rand
+	// jitters holds, for each schedule whose run is being delayed by its
+	// jitter, the run and its delay, so that the delay's only drawn once
+	// however many times the schedule's processed while it waits.
+	jitterLock sync.Mutex
+	jitters    map[string]jitteredRun
+}
+
+// jitteredRun is a schedule's run that was due at due, and that's delayed
+// by delay.
+type jitteredRun struct {
+	due   time.Time
+	delay time.Duration
 }
 
 func NewScheduleController(
@@ -63,6 +94,7 @@ func NewScheduleController(
 	schedulesInformer informers.ScheduleInformer,
 	logger logrus.FieldLogger,
 	metrics *metrics.ServerMetrics,
+	clusterName string,
 ) *scheduleController {
 	c := &scheduleController{
 		genericController: newGenericController("schedule", logger),
@@ -72,6 +104,9 @@ func NewScheduleController(
 		schedulesLister:   schedulesInformer.Lister(),
 		clock:             clock.RealClock{},
 		metrics:           metrics,
+		clusterName:       clusterName,
+		rand:              rand.New(rand.NewSource(time.Now().UnixNano())),
+		jitters:           make(map[string]jitteredRun),
 	}
 
 	c.syncHandler = c.processSchedule
@@ -171,6 +206,11 @@ func (c *scheduleController) processSchedule(key string) error {
 	currentPhase := schedule.Status.Phase
 
 	cronSchedule, errs := parseCronSchedule(schedule, c.logger)
+	errs = append(errs, c.validateBackupNameTemplate(schedule)...)
+	if schedule.Spec.JitterMaxSeconds < 0 {
+		errs = append(errs, "Jitter max seconds must not be negative")
+	}
+	errs = append(errs, validateBackupOwnerReferences(schedule)...)
 	if len(errs) > 0 {
 		schedule.Status.Phase = api.SchedulePhaseFailedValidation
 		schedule.Status.ValidationErrors = errs
@@ -251,6 +291,19 @@ func (c *scheduleController) submitBackupIfDue(item *api.Schedule, cronSchedule
 		return nil
 	}
 
+	// the backup's created on the first sync after its delay's passed, or
+	// when the schedule's requeued for then, whichever's first.
+	if delay := c.jitterDelay(item, nextRunTime); delay > 0 {
+		if runAt := nextRunTime.Add(delay); now.Before(runAt) {
+			log.WithFields(logrus.Fields{
+				"nextRunTime": nextRunTime,
+				"runAt":       runAt,
+			}).Info("Schedule is due, delaying Backup by its jitter")
+			c.enqueueAfter(item, runAt.Sub(now))
+			return nil
+		}
+	}
+
 	// Don't attempt to "catch up" if there are any missed or failed runs - simply
 	// trigger a Backup if it's time.
 	//
@@ -258,10 +311,10 @@ func (c *scheduleController) submitBackupIfDue(item *api.Schedule, cronSchedule
 	// backups so that we don't overlap runs (for disk snapshots in particular, this can
 	// lead to performance issues).
 	log.WithField("nextRunTime", nextRunTime).Info("Schedule is due, submitting Backup")
-	backup := getBackup(item, now)
-	if _, err := c.backupsClient.Backups(backup.Namespace).Create(backup); err != nil {
-		return errors.Wrap(err, "error creating Backup")
+	if err := c.createBackup(item, now); err != nil {
+		return err
 	}
+	c.forgetJitter(item)
 
 	original := item
 	schedule := item.DeepCopy()
@@ -275,6 +328,50 @@ func (c *scheduleController) submitBackupIfDue(item *api.Schedule, cronSchedule
 	return nil
 }
 
+// jitterDelay returns how long the schedule's run that was due at due is
+// delayed by its jitter. The delay's drawn the first time it's asked for,
+// and the same delay's returned for the run after that.
+func (c *scheduleController) jitterDelay(item *api.Schedule, due time.Time) time.Duration {
+	if item.Spec.JitterMaxSeconds <= 0 {
+		return 0
+	}
+
+	c.jitterLock.Lock()
+	defer c.jitterLock.Unlock()
+
+	key := kubeutil.NamespaceAndName(item)
+	if run, ok := c.jitters[key]; ok && run.due.Equal(due) {
+		return run.delay
+	}
+
+	run := jitteredRun{
+		due:   due,
+		delay: time.Duration(c.rand.Int63n(item.Spec.JitterMaxSeconds+1)) * time.Second,
+	}
+	c.jitters[key] = run
+
+	return run.delay
+}
+
+// forgetJitter forgets the delay drawn for the schedule's last run, once
+// its backup's been created.
+func (c *scheduleController) forgetJitter(item *api.Schedule) {
+	c.jitterLock.Lock()
+	defer c.jitterLock.Unlock()
+
+	delete(c.jitters, kubeutil.NamespaceAndName(item))
+}
+
+// enqueueAfter requeues the schedule to be processed again after delay.
+func (c *scheduleController) enqueueAfter(item *api.Schedule, delay time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(item)
+	if err != nil {
+		c.logger.WithError(errors.WithStack(err)).WithField("schedule", kubeutil.NamespaceAndName(item)).Error("Error creating queue key, item not requeued")
+		return
+	}
+	c.queue.AddAfter(key, delay)
+}
+
 func getNextRunTime(schedule *api.Schedule, cronSchedule cron.Schedule, asOf time.Time) (bool, time.Time) {
 	// get the latest run time (if the schedule hasn't run yet, this will be the zero value which will trigger
 	// an immediate backup)
@@ -285,21 +382,120 @@ func getNextRunTime(schedule *api.Schedule, cronSchedule cron.Schedule, asOf tim
 	return asOf.After(nextRunTime), nextRunTime
 }
 
-func getBackup(item *api.Schedule, timestamp time.Time) *api.Backup {
+// createBackup creates the schedule's backup for timestamp. If the backup's
+// name is taken, it's rendered again with an incremented .Sequence, so an
+// error is returned if the schedule's backup name template doesn't use it.
+func (c *scheduleController) createBackup(item *api.Schedule, timestamp time.Time) error {
+	var previous string
+	for sequence := 0; sequence < maxBackupNameSequence; sequence++ {
+		name, err := backupName(item, c.clusterName, timestamp, sequence)
+		if err != nil {
+			return err
+		}
+		if name == previous {
+			return errors.Errorf("backup %s already exists, and the schedule's backup name template doesn't use .Sequence to make its names unique", name)
+		}
+		previous = name
+
+		_, err = c.backupsClient.Backups(item.Namespace).Create(getBackup(item, name))
+		if apierrors.IsAlreadyExists(err) {
+			continue
+		}
+		return errors.Wrap(err, "error creating Backup")
+	}
+
+	return errors.Errorf("the names of the schedule's backups for sequence numbers up to %d are all taken", maxBackupNameSequence)
+}
+
+// backupNameData is what a schedule's backup name template is rendered
+// with.
+type backupNameData struct {
+	Schedule    string
+	ClusterName string
+	Timestamp   time.Time
+	Sequence    int
+}
+
+// backupName renders the name of the schedule's backup for timestamp from
+// the schedule's backup name template, or the default one if it doesn't
+// have one. It returns an error if the name isn't a valid backup name.
+func backupName(item *api.Schedule, clusterName string, timestamp time.Time, sequence int) (string, error) {
+	text := item.Spec.BackupNameTemplate
+	if text == "" {
+		text = defaultBackupNameTemplate
+	}
+
+	tmpl, err := template.New("backupName").Parse(text)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var buf bytes.Buffer
+	data := backupNameData{
+		Schedule:    item.Name,
+		ClusterName: clusterName,
+		Timestamp:   timestamp,
+		Sequence:    sequence,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	name := buf.String()
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", errors.Errorf("backup name %q is invalid: %s", name, strings.Join(errs, "; "))
+	}
+
+	return name, nil
+}
+
+// validateBackupNameTemplate checks that the schedule's backup name
+// template, if it has one, renders a valid backup name.
+func (c *scheduleController) validateBackupNameTemplate(item *api.Schedule) []string {
+	if item.Spec.BackupNameTemplate == "" {
+		return nil
+	}
+
+	if _, err := backupName(item, c.clusterName, c.clock.Now(), 0); err != nil {
+		return []string{fmt.Sprintf("Invalid backup name template: %v", err)}
+	}
+
+	return nil
+}
+
+func getBackup(item *api.Schedule, name string) *api.Backup {
 	backup := &api.Backup{
 		Spec: item.Spec.Template,
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: item.Namespace,
-			Name:      fmt.Sprintf("%s-%s", item.Name, timestamp.Format("20060102150405")),
+			Name:      name,
 		},
 	}
 
 	// add schedule labels and 'ark-schedule' label to the backup
 	addLabelsToBackup(item, backup)
 
+	for _, ref := range item.Spec.BackupOwnerReferences {
+		backup.OwnerReferences = append(backup.OwnerReferences, *ref.DeepCopy())
+	}
+
 	return backup
 }
 
+// validateBackupOwnerReferences returns the reasons the schedule's backup
+// owner references are invalid, if any.
+func validateBackupOwnerReferences(item *api.Schedule) []string {
+	var errs []string
+
+	for i, ref := range item.Spec.BackupOwnerReferences {
+		if ref.APIVersion == "" || ref.Kind == "" || ref.Name == "" || ref.UID == "" {
+			errs = append(errs, fmt.Sprintf("Backup owner reference %d must specify apiVersion, kind, name and uid", i))
+		}
+	}
+
+	return errs
+}
+
 func addLabelsToBackup(item *api.Schedule, backup *api.Backup) {
 	labels := item.Labels
 	if labels == nil {
@@ -18,6 +18,8 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -25,6 +27,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/clock"
@@ -104,6 +107,44 @@ func TestProcessSchedule(t *testing.T) {
 			expectedBackupCreate: arktest.NewTestBackup().WithNamespace("ns").WithName("name-20170101120000").WithLabel("ark-schedule", "name").Backup,
 			expectedLastBackup:   "2017-01-01 12:00:00",
 		},
+		{
+			name: "schedule with an invalid backup name template gets failed",
+			schedule: arktest.NewTestSchedule("ns", "name").WithPhase(api.SchedulePhaseNew).
+				WithCronSchedule("@every 5m").WithBackupNameTemplate("{{.Schedule}}_backup").Schedule,
+			fakeClockTime:            "2017-01-01 12:00:00",
+			expectedErr:              false,
+			expectedPhase:            string(api.SchedulePhaseFailedValidation),
+			expectedValidationErrors: []string{`Invalid backup name template: backup name "name_backup" is invalid: a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`},
+		},
+		{
+			name: "schedule with a backup name template triggers a backup named from it",
+			schedule: arktest.NewTestSchedule("ns", "name").WithPhase(api.SchedulePhaseNew).
+				WithCronSchedule("@every 5m").WithBackupNameTemplate(`{{.ClusterName}}-{{.Schedule}}-{{.Timestamp.Format "200601021504"}}`).Schedule,
+			fakeClockTime:        "2017-01-01 12:00:00",
+			expectedErr:          false,
+			expectedPhase:        string(api.SchedulePhaseEnabled),
+			expectedBackupCreate: arktest.NewTestBackup().WithNamespace("ns").WithName("cluster-1-name-201701011200").WithLabel("ark-schedule", "name").Backup,
+			expectedLastBackup:   "2017-01-01 12:00:00",
+		},
+		{
+			name: "schedule with a negative jitter gets failed",
+			schedule: arktest.NewTestSchedule("ns", "name").WithPhase(api.SchedulePhaseNew).
+				WithCronSchedule("@every 5m").WithJitterMaxSeconds(-1).Schedule,
+			fakeClockTime:            "2017-01-01 12:00:00",
+			expectedErr:              false,
+			expectedPhase:            string(api.SchedulePhaseFailedValidation),
+			expectedValidationErrors: []string{"Jitter max seconds must not be negative"},
+		},
+		{
+			name: "schedule with an incomplete backup owner reference gets failed",
+			schedule: arktest.NewTestSchedule("ns", "name").WithPhase(api.SchedulePhaseNew).
+				WithCronSchedule("@every 5m").
+				WithBackupOwnerReferences(metav1.OwnerReference{APIVersion: "example.com/v1", Kind: "Application", Name: "app-1"}).Schedule,
+			fakeClockTime:            "2017-01-01 12:00:00",
+			expectedErr:              false,
+			expectedPhase:            string(api.SchedulePhaseFailedValidation),
+			expectedValidationErrors: []string{"Backup owner reference 0 must specify apiVersion, kind, name and uid"},
+		},
 		{
 			name: "schedule that's already run gets LastBackup updated",
 			schedule: arktest.NewTestSchedule("ns", "name").WithPhase(api.SchedulePhaseEnabled).
@@ -130,6 +171,7 @@ func TestProcessSchedule(t *testing.T) {
 				sharedInformers.Ark().V1().Schedules(),
 				logger,
 				metrics.NewServerMetrics(),
+				"cluster-1",
 			)
 
 			var (
@@ -251,6 +293,98 @@ func TestProcessSchedule(t *testing.T) {
 	}
 }
 
+func TestScheduleJitterSpreadsBackups(t *testing.T) {
+	var (
+		client          = fake.NewSimpleClientset()
+		sharedInformers = informers.NewSharedInformerFactory(client, 0)
+		start           = parseTime("2017-01-01 12:00:00")
+		fakeClock       = clock.NewFakeClock(start)
+		window          = 5 * time.Minute
+		created         = make(map[string]time.Time)
+		schedules       = make(map[string]*api.Schedule)
+	)
+
+	c := NewScheduleController(
+		"namespace",
+		client.ArkV1(),
+		client.ArkV1(),
+		sharedInformers.Ark().V1().Schedules(),
+		arktest.NewLogger(),
+		metrics.NewServerMetrics(),
+		"cluster-1",
+	)
+	c.clock = fakeClock
+	c.rand = rand.New(rand.NewSource(1))
+
+	// twenty schedules with the same Cron expression are all due at 12:00.
+	for i := 0; i < 20; i++ {
+		schedule := arktest.NewTestSchedule("ns", fmt.Sprintf("schedule-%d", i)).WithPhase(api.SchedulePhaseEnabled).
+			WithCronSchedule("0 * * * *").WithLastBackupTime("2017-01-01 11:00:00").WithJitterMaxSeconds(int64(window / time.Second)).Schedule
+		schedules[schedule.Name] = schedule
+	}
+
+	client.PrependReactor("create", "backups", func(action core.Action) (bool, runtime.Object, error) {
+		backup := action.(core.CreateAction).GetObject().(*api.Backup)
+		created[backup.Labels["ark-schedule"]] = fakeClock.Now()
+		return true, backup, nil
+	})
+	client.PrependReactor("patch", "schedules", func(action core.Action) (bool, runtime.Object, error) {
+		schedule := schedules[action.(core.PatchAction).GetName()].DeepCopy()
+		schedule.Status.LastBackup = metav1.NewTime(fakeClock.Now())
+		return true, schedule, nil
+	})
+
+	// the schedules are processed every second until after the window.
+	for now := start; !now.After(start.Add(window + time.Minute)); now = now.Add(time.Second) {
+		fakeClock.SetTime(now)
+		for name, schedule := range schedules {
+			if _, ok := created[name]; ok {
+				continue
+			}
+			cronSchedule, errs := parseCronSchedule(schedule, c.logger)
+			require.Empty(t, errs)
+			require.NoError(t, c.submitBackupIfDue(schedule, cronSchedule))
+		}
+	}
+
+	// each schedule's backup is created once, within the window, and the
+	// backups are spread across it rather than created at once.
+	require.Len(t, created, len(schedules))
+	first, last := start.Add(window), start
+	for name, createdAt := range created {
+		assert.False(t, createdAt.Before(start), "%s was created at %v", name, createdAt)
+		assert.False(t, createdAt.After(start.Add(window)), "%s was created at %v", name, createdAt)
+
+		if createdAt.Before(first) {
+			first = createdAt
+		}
+		if createdAt.After(last) {
+			last = createdAt
+		}
+	}
+	assert.True(t, last.Sub(first) >= window/2, "backups were created between %v and %v", first, last)
+	assert.Empty(t, c.jitters)
+}
+
+func TestJitterDelayIsDrawnOncePerRun(t *testing.T) {
+	c := &scheduleController{
+		rand:    rand.New(rand.NewSource(1)),
+		jitters: make(map[string]jitteredRun),
+	}
+
+	schedule := arktest.NewTestSchedule("ns", "name").WithJitterMaxSeconds(3600).Schedule
+	due := parseTime("2017-01-01 12:00:00")
+
+	delay := c.jitterDelay(schedule, due)
+	assert.True(t, delay >= 0 && delay <= time.Hour, "delay is %v", delay)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, delay, c.jitterDelay(schedule, due))
+	}
+
+	// schedules without a jitter aren't delayed.
+	assert.Equal(t, time.Duration(0), c.jitterDelay(arktest.NewTestSchedule("ns", "other").Schedule, due))
+}
+
 func parseTime(timeString string) time.Time {
 	res, _ := time.Parse("2006-01-02 15:04:05", timeString)
 	return res
@@ -499,6 +633,35 @@ func TestGetBackup(t *testing.T) {
 				Spec: api.BackupSpec{},
 			},
 		},
+		{
+			name: "ensure schedule backup owner references are copied",
+			schedule: &api.Schedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "bar",
+				},
+				Spec: api.ScheduleSpec{
+					Template: api.BackupSpec{},
+					BackupOwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "example.com/v1", Kind: "Application", Name: "app-1", UID: "uid-1"},
+					},
+				},
+			},
+			testClockTime: "2017-07-25 14:15:00",
+			expectedBackup: &api.Backup{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "bar-20170725141500",
+					Labels: map[string]string{
+						"ark-schedule": "bar",
+					},
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "example.com/v1", Kind: "Application", Name: "app-1", UID: "uid-1"},
+					},
+				},
+				Spec: api.BackupSpec{},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -506,12 +669,136 @@ func TestGetBackup(t *testing.T) {
 			testTime, err := time.Parse("2006-01-02 15:04:05", test.testClockTime)
 			require.NoError(t, err, "unable to parse test.testClockTime: %v", err)
 
-			backup := getBackup(test.schedule, clock.NewFakeClock(testTime).Now())
+			name, err := backupName(test.schedule, "", clock.NewFakeClock(testTime).Now(), 0)
+			require.NoError(t, err)
+
+			backup := getBackup(test.schedule, name)
 
 			assert.Equal(t, test.expectedBackup.Namespace, backup.Namespace)
 			assert.Equal(t, test.expectedBackup.Name, backup.Name)
 			assert.Equal(t, test.expectedBackup.Labels, backup.Labels)
+			assert.Equal(t, test.expectedBackup.OwnerReferences, backup.OwnerReferences)
 			assert.Equal(t, test.expectedBackup.Spec, backup.Spec)
 		})
 	}
 }
+
+func TestBackupName(t *testing.T) {
+	testTime, err := time.Parse("2006-01-02 15:04:05", "2017-07-25 09:15:00")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		template    string
+		sequence    int
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:     "empty template uses the schedule's name and the timestamp",
+			expected: "bar-20170725091500",
+		},
+		{
+			name:     "template can use the schedule's name, cluster name, timestamp and sequence",
+			template: `{{.ClusterName}}-{{.Schedule}}-{{.Timestamp.Format "2006-01-02"}}-{{.Sequence}}`,
+			sequence: 2,
+			expected: "cluster-1-bar-2017-07-25-2",
+		},
+		{
+			name:        "unparseable template is an error",
+			template:    "{{.Schedule",
+			expectedErr: true,
+		},
+		{
+			name:        "unknown field is an error",
+			template:    "{{.Namespace}}",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid backup name is an error",
+			template:    "{{.Schedule}}_{{.Sequence}}",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule := arktest.NewTestSchedule("foo", "bar").Schedule
+			schedule.Spec.BackupNameTemplate = test.template
+
+			name, err := backupName(schedule, "cluster-1", testTime, test.sequence)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, name)
+		})
+	}
+}
+
+func TestCreateBackupNameCollision(t *testing.T) {
+	testTime, err := time.Parse("2006-01-02 15:04:05", "2017-07-25 09:15:00")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		template     string
+		expectedName string
+		expectedErr  bool
+	}{
+		{
+			name:        "default template is an error if a backup was already created in the same second",
+			expectedErr: true,
+		},
+		{
+			name:         "template using .Sequence is rendered again until the name isn't taken",
+			template:     `{{.Schedule}}-{{.Timestamp.Format "20060102150405"}}-{{.Sequence}}`,
+			expectedName: "bar-20170725091500-1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				client          = fake.NewSimpleClientset()
+				sharedInformers = informers.NewSharedInformerFactory(client, 0)
+				created         []string
+			)
+
+			c := NewScheduleController(
+				"namespace",
+				client.ArkV1(),
+				client.ArkV1(),
+				sharedInformers.Ark().V1().Schedules(),
+				arktest.NewLogger(),
+				metrics.NewServerMetrics(),
+				"cluster-1",
+			)
+
+			// the first name rendered for the timestamp is taken.
+			client.PrependReactor("create", "backups", func(action core.Action) (bool, runtime.Object, error) {
+				backup := action.(core.CreateAction).GetObject().(*api.Backup)
+				if len(created) == 0 {
+					created = append(created, backup.Name)
+					return true, nil, apierrors.NewAlreadyExists(api.SchemeGroupVersion.WithResource("backups").GroupResource(), backup.Name)
+				}
+				created = append(created, backup.Name)
+				return true, backup, nil
+			})
+
+			schedule := arktest.NewTestSchedule("foo", "bar").Schedule
+			schedule.Spec.BackupNameTemplate = test.template
+
+			err := c.createBackup(schedule, testTime)
+			if test.expectedErr {
+				assert.Error(t, err)
+				assert.Len(t, created, 1)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, created, 2)
+			assert.Equal(t, test.expectedName, created[1])
+		})
+	}
+}
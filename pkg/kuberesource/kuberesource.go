@@ -28,5 +28,6 @@ var (
 	PersistentVolumeClaims = schema.GroupResource{Group: "", Resource: "persistentvolumeclaims"}
 	PersistentVolumes      = schema.GroupResource{Group: "", Resource: "persistentvolumes"}
 	Pods                   = schema.GroupResource{Group: "", Resource: "pods"}
+	Secrets                = schema.GroupResource{Group: "", Resource: "secrets"}
 	ServiceAccounts        = schema.GroupResource{Group: "", Resource: "serviceaccounts"}
 )
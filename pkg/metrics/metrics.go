@@ -17,6 +17,7 @@ limitations under the License.
 package metrics
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,18 +31,40 @@ type ServerMetrics struct {
 const (
 	metricNamespace             = "ark"
 	backupTarballSizeBytesGauge = "backup_tarball_size_bytes"
+	backupSizeByGroupBytesGauge = "backup_size_by_group_bytes"
 	// TODO: Rename the Count variables to match their strings
-	backupAttemptCount           = "backup_attempt_total"
-	backupSuccessCount           = "backup_success_total"
-	backupFailureCount           = "backup_failure_total"
-	backupDurationSeconds        = "backup_duration_seconds"
-	restoreAttemptTotal          = "restore_attempt_total"
-	restoreValidationFailedTotal = "restore_validation_failed_total"
-	restoreSuccessTotal          = "restore_success_total"
-	restoreFailedTotal           = "restore_failed_total"
+	backupAttemptCount              = "backup_attempt_total"
+	backupSuccessCount              = "backup_success_total"
+	backupFailureCount              = "backup_failure_total"
+	backupPartialFailureTotal       = "backup_partial_failure_total"
+	backupEmptyTotal                = "backup_empty_total"
+	backupWarningTotal              = "backup_warning_total"
+	backupDurationSeconds           = "backup_duration_seconds"
+	backupPendingDurationSeconds    = "backup_pending_duration_seconds"
+	backupInProgressDurationSeconds = "backup_in_progress_duration_seconds"
+	backupRetriesExhaustedTotal     = "backup_retries_exhausted_total"
+	backupSkippedLargeItemsTotal    = "backup_skipped_large_items_total"
+	backupUploadDurationSeconds     = "backup_upload_duration_seconds"
+	backupUploadFailureTotal        = "backup_upload_failure_total"
+	restoreAttemptTotal             = "restore_attempt_total"
+	restoreValidationFailedTotal    = "restore_validation_failed_total"
+	restoreSuccessTotal             = "restore_success_total"
+	restoreFailedTotal              = "restore_failed_total"
+	apiServerThrottledTotal         = "apiserver_throttled_total"
+	backupPatchCoalescedTotal       = "backup_patch_coalesced_total"
+	backupItemActionDuration        = "backup_item_action_duration_seconds"
+	backupItemActionFailureTotal    = "backup_item_action_failure_total"
+	backupExpiredDeletionTotal      = "backup_expired_deletion_total"
+	backupSkippedNotNewTotal        = "backup_skipped_not_new_total"
 
 	scheduleLabel   = "schedule"
 	backupNameLabel = "backupName"
+	locationLabel   = "location"
+	abortedLabel    = "aborted"
+	actionLabel     = "action"
+	timedOutLabel   = "timedOut"
+	groupLabel      = "group"
+	phaseLabel      = "phase"
 
 	secondsInMinute = 60.0
 )
@@ -56,7 +79,15 @@ func NewServerMetrics() *ServerMetrics {
 					Name:      backupTarballSizeBytesGauge,
 					Help:      "Size, in bytes, of a backup",
 				},
-				[]string{scheduleLabel},
+				[]string{scheduleLabel, abortedLabel},
+			),
+			backupSizeByGroupBytesGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace: metricNamespace,
+					Name:      backupSizeByGroupBytesGauge,
+					Help:      "Size, in bytes, of the items of each resource, formatted as resource.group, in a backup, before the backup was compressed",
+				},
+				[]string{scheduleLabel, groupLabel},
 			),
 			backupAttemptCount: prometheus.NewCounterVec(
 				prometheus.CounterOpts{
@@ -82,6 +113,22 @@ func NewServerMetrics() *ServerMetrics {
 				},
 				[]string{scheduleLabel},
 			),
+			backupPartialFailureTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupPartialFailureTotal,
+					Help:      "Total number of partially failed backups",
+				},
+				[]string{scheduleLabel},
+			),
+			backupEmptyTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupEmptyTotal,
+					Help:      "Total number of backups that completed without capturing any items, or with a tarball smaller than the minimum backup size",
+				},
+				[]string{scheduleLabel},
+			),
 			backupDurationSeconds: prometheus.NewHistogramVec(
 				prometheus.HistogramOpts{
 					Namespace: metricNamespace,
@@ -101,6 +148,93 @@ func NewServerMetrics() *ServerMetrics {
 				},
 				[]string{scheduleLabel},
 			),
+			backupPendingDurationSeconds: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      backupPendingDurationSeconds,
+					Help:      "Time backups spent New, from their creation until they started, in seconds",
+					Buckets: []float64{
+						toSeconds(1 * time.Second),
+						toSeconds(5 * time.Second),
+						toSeconds(10 * time.Second),
+						toSeconds(30 * time.Second),
+						toSeconds(1 * time.Minute),
+						toSeconds(5 * time.Minute),
+						toSeconds(10 * time.Minute),
+						toSeconds(30 * time.Minute),
+						toSeconds(1 * time.Hour),
+					},
+				},
+				[]string{scheduleLabel},
+			),
+			backupInProgressDurationSeconds: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      backupInProgressDurationSeconds,
+					Help:      "Time backups spent InProgress, from when they started until they reached a terminal phase, in seconds",
+					Buckets: []float64{
+						toSeconds(1 * time.Minute),
+						toSeconds(5 * time.Minute),
+						toSeconds(10 * time.Minute),
+						toSeconds(15 * time.Minute),
+						toSeconds(30 * time.Minute),
+						toSeconds(1 * time.Hour),
+						toSeconds(2 * time.Hour),
+						toSeconds(3 * time.Hour),
+						toSeconds(4 * time.Hour),
+					},
+				},
+				[]string{scheduleLabel},
+			),
+			backupRetriesExhaustedTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupRetriesExhaustedTotal,
+					Help:      "Total number of backups dropped from the work queue after exhausting sync retries",
+				},
+				[]string{scheduleLabel},
+			),
+			backupWarningTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupWarningTotal,
+					Help:      "Total number of warnings logged by backups, which don't fail them",
+				},
+				[]string{scheduleLabel},
+			),
+			backupSkippedLargeItemsTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupSkippedLargeItemsTotal,
+					Help:      "Total number of items left out of backups for being larger than the maximum item size",
+				},
+				[]string{scheduleLabel},
+			),
+			backupUploadDurationSeconds: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      backupUploadDurationSeconds,
+					Help:      "Time taken to upload a backup to a storage location, in seconds, whether or not the upload succeeded",
+					Buckets: []float64{
+						toSeconds(10 * time.Second),
+						toSeconds(30 * time.Second),
+						toSeconds(1 * time.Minute),
+						toSeconds(5 * time.Minute),
+						toSeconds(10 * time.Minute),
+						toSeconds(30 * time.Minute),
+						toSeconds(1 * time.Hour),
+					},
+				},
+				[]string{scheduleLabel, locationLabel},
+			),
+			backupUploadFailureTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupUploadFailureTotal,
+					Help:      "Total number of failed uploads of backups to a storage location",
+				},
+				[]string{scheduleLabel, locationLabel},
+			),
 			restoreAttemptTotal: prometheus.NewCounterVec(
 				prometheus.CounterOpts{
 					Namespace: metricNamespace,
@@ -133,6 +267,53 @@ func NewServerMetrics() *ServerMetrics {
 				},
 				[]string{scheduleLabel},
 			),
+			apiServerThrottledTotal: prometheus.NewCounter(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      apiServerThrottledTotal,
+					Help:      "Total number of requests throttled by the Kubernetes API server",
+				},
+			),
+			backupPatchCoalescedTotal: prometheus.NewCounter(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupPatchCoalescedTotal,
+					Help:      "Total number of backup patches saved by merging updates to a backup that were held back",
+				},
+			),
+			backupItemActionDuration: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      backupItemActionDuration,
+					Help:      "Time taken by a backup item action to execute on an item, in seconds, whether or not it succeeded",
+					Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+				},
+				[]string{actionLabel},
+			),
+			backupItemActionFailureTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupItemActionFailureTotal,
+					Help:      "Total number of failed executions of a backup item action, including those that timed out",
+				},
+				[]string{actionLabel, timedOutLabel},
+			),
+			backupExpiredDeletionTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupExpiredDeletionTotal,
+					Help:      "Total number of deletion requests created for backups that expired",
+				},
+				[]string{scheduleLabel},
+			),
+			backupSkippedNotNewTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      backupSkippedNotNewTotal,
+					Help:      "Total number of backups observed by the backup controller that weren't processed because they weren't new, e.g. when the server restarts",
+				},
+				[]string{phaseLabel},
+			),
 		},
 	}
 }
@@ -144,6 +325,22 @@ func (m *ServerMetrics) RegisterAllMetrics() {
 	}
 }
 
+// Describe sends the descriptors of all of the metrics to ch, so that
+// ServerMetrics can be registered with a registry other than the default
+// one as a single prometheus.Collector.
+func (m *ServerMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, pm := range m.metrics {
+		pm.Describe(ch)
+	}
+}
+
+// Collect sends the current values of all of the metrics to ch.
+func (m *ServerMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, pm := range m.metrics {
+		pm.Collect(ch)
+	}
+}
+
 func (m *ServerMetrics) InitSchedule(scheduleName string) {
 	if c, ok := m.metrics[backupAttemptCount].(*prometheus.CounterVec); ok {
 		c.WithLabelValues(scheduleName).Set(0)
@@ -154,6 +351,21 @@ func (m *ServerMetrics) InitSchedule(scheduleName string) {
 	if c, ok := m.metrics[backupFailureCount].(*prometheus.CounterVec); ok {
 		c.WithLabelValues(scheduleName).Set(0)
 	}
+	if c, ok := m.metrics[backupPartialFailureTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(scheduleName).Set(0)
+	}
+	if c, ok := m.metrics[backupEmptyTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(scheduleName).Set(0)
+	}
+	if c, ok := m.metrics[backupRetriesExhaustedTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(scheduleName).Set(0)
+	}
+	if c, ok := m.metrics[backupSkippedLargeItemsTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(scheduleName).Set(0)
+	}
+	if c, ok := m.metrics[backupWarningTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(scheduleName).Set(0)
+	}
 	if c, ok := m.metrics[restoreAttemptTotal].(*prometheus.CounterVec); ok {
 		c.WithLabelValues(scheduleName).Set(0)
 	}
@@ -169,9 +381,20 @@ func (m *ServerMetrics) InitSchedule(scheduleName string) {
 }
 
 // SetBackupTarballSizeBytesGauge records the size, in bytes, of a backup tarball.
-func (m *ServerMetrics) SetBackupTarballSizeBytesGauge(backupSchedule string, size int64) {
+// If the backup was aborted for exceeding the maximum backup size, size is
+// the size of the truncated tarball.
+func (m *ServerMetrics) SetBackupTarballSizeBytesGauge(backupSchedule string, size int64, aborted bool) {
 	if g, ok := m.metrics[backupTarballSizeBytesGauge].(*prometheus.GaugeVec); ok {
-		g.WithLabelValues(backupSchedule).Set(float64(size))
+		g.WithLabelValues(backupSchedule, strconv.FormatBool(aborted)).Set(float64(size))
+	}
+}
+
+// SetBackupSizeByGroupGauge records the size, in bytes, of the items of a
+// resource, formatted as resource.group, in a backup. Items are compressed
+// together, rather than one by one, so size is their uncompressed size.
+func (m *ServerMetrics) SetBackupSizeByGroupGauge(backupSchedule, group string, size int64) {
+	if g, ok := m.metrics[backupSizeByGroupBytesGauge].(*prometheus.GaugeVec); ok {
+		g.WithLabelValues(backupSchedule, group).Set(float64(size))
 	}
 }
 
@@ -196,6 +419,22 @@ func (m *ServerMetrics) RegisterBackupFailed(backupSchedule string) {
 	}
 }
 
+// RegisterBackupPartialFailure records a backup that completed with
+// errors backing up the items in some namespaces.
+func (m *ServerMetrics) RegisterBackupPartialFailure(backupSchedule string) {
+	if c, ok := m.metrics[backupPartialFailureTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule).Inc()
+	}
+}
+
+// RegisterBackupEmpty records a backup that completed without capturing
+// any items, or with a tarball smaller than the minimum backup size.
+func (m *ServerMetrics) RegisterBackupEmpty(backupSchedule string) {
+	if c, ok := m.metrics[backupEmptyTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule).Inc()
+	}
+}
+
 // RegisterBackupDuration records the number of seconds a backup took.
 func (m *ServerMetrics) RegisterBackupDuration(backupSchedule string, seconds float64) {
 	if c, ok := m.metrics[backupDurationSeconds].(*prometheus.HistogramVec); ok {
@@ -203,6 +442,80 @@ func (m *ServerMetrics) RegisterBackupDuration(backupSchedule string, seconds fl
 	}
 }
 
+// RegisterBackupPendingDuration records the number of seconds a backup
+// spent New, from its creation until it started, which grows when backups
+// queue up behind each other.
+func (m *ServerMetrics) RegisterBackupPendingDuration(backupSchedule string, seconds float64) {
+	if c, ok := m.metrics[backupPendingDurationSeconds].(*prometheus.HistogramVec); ok {
+		c.WithLabelValues(backupSchedule).Observe(seconds)
+	}
+}
+
+// RegisterBackupInProgressDuration records the number of seconds a backup
+// spent InProgress, from when it started until it reached a terminal phase.
+func (m *ServerMetrics) RegisterBackupInProgressDuration(backupSchedule string, seconds float64) {
+	if c, ok := m.metrics[backupInProgressDurationSeconds].(*prometheus.HistogramVec); ok {
+		c.WithLabelValues(backupSchedule).Observe(seconds)
+	}
+}
+
+// RegisterBackupRetriesExhausted records a backup that was dropped from the
+// work queue after failing to sync too many times.
+func (m *ServerMetrics) RegisterBackupRetriesExhausted(backupSchedule string) {
+	if c, ok := m.metrics[backupRetriesExhaustedTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule).Inc()
+	}
+}
+
+// RegisterBackupSkippedLargeItems records items that were left out of a
+// backup for being larger than its maximum item size.
+func (m *ServerMetrics) RegisterBackupSkippedLargeItems(backupSchedule string, count int) {
+	if c, ok := m.metrics[backupSkippedLargeItemsTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule).Add(float64(count))
+	}
+}
+
+// RegisterBackupWarning records the warnings that were logged by a backup,
+// so that backups that completed with warnings can be alerted on.
+func (m *ServerMetrics) RegisterBackupWarning(backupSchedule string, count int) {
+	if c, ok := m.metrics[backupWarningTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule).Add(float64(count))
+	}
+}
+
+// RegisterBackupUploadDuration records the number of seconds it took to
+// upload a backup to a storage location, separately from the backup's
+// overall duration. Failed uploads are recorded too.
+func (m *ServerMetrics) RegisterBackupUploadDuration(backupSchedule, location string, seconds float64) {
+	if c, ok := m.metrics[backupUploadDurationSeconds].(*prometheus.HistogramVec); ok {
+		c.WithLabelValues(backupSchedule, location).Observe(seconds)
+	}
+}
+
+// RegisterBackupUploadFailed records a failed upload of a backup to a
+// storage location.
+func (m *ServerMetrics) RegisterBackupUploadFailed(backupSchedule, location string) {
+	if c, ok := m.metrics[backupUploadFailureTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule, location).Inc()
+	}
+}
+
+// RegisterBackupItemActionDuration records the number of seconds a backup
+// item action took to execute on an item.
+func (m *ServerMetrics) RegisterBackupItemActionDuration(action string, seconds float64) {
+	if c, ok := m.metrics[backupItemActionDuration].(*prometheus.HistogramVec); ok {
+		c.WithLabelValues(action).Observe(seconds)
+	}
+}
+
+// RegisterBackupItemActionFailed records a failed execution of a backup
+// item action, and whether it failed by timing out.
+func (m *ServerMetrics) RegisterBackupItemActionFailed(action string, timedOut bool) {
+	if c, ok := m.metrics[backupItemActionFailureTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(action, strconv.FormatBool(timedOut)).Inc()
+	}
+}
+
 // toSeconds translates a time.Duration value into a float64
 // representing the number of seconds in that duration.
 func toSeconds(d time.Duration) float64 {
@@ -236,3 +549,35 @@ func (m *ServerMetrics) RegisterRestoreValidationFailed(backupSchedule string) {
 		c.WithLabelValues(backupSchedule).Inc()
 	}
 }
+
+// RegisterAPIServerThrottled records a request that was throttled by the
+// Kubernetes API server.
+func (m *ServerMetrics) RegisterAPIServerThrottled() {
+	if c, ok := m.metrics[apiServerThrottledTotal].(prometheus.Counter); ok {
+		c.Inc()
+	}
+}
+
+// RegisterBackupPatchCoalesced records a patch to a backup that was saved
+// by merging it with another.
+func (m *ServerMetrics) RegisterBackupPatchCoalesced() {
+	if c, ok := m.metrics[backupPatchCoalescedTotal].(prometheus.Counter); ok {
+		c.Inc()
+	}
+}
+
+// RegisterBackupExpiredDeletion records a deletion request created by the
+// garbage collector for an expired backup.
+func (m *ServerMetrics) RegisterBackupExpiredDeletion(backupSchedule string) {
+	if c, ok := m.metrics[backupExpiredDeletionTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(backupSchedule).Inc()
+	}
+}
+
+// RegisterBackupSkippedNotNew records a backup that the backup controller
+// observed but didn't process because it was in phase, not New.
+func (m *ServerMetrics) RegisterBackupSkippedNotNew(phase string) {
+	if c, ok := m.metrics[backupSkippedNotNewTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(phase).Inc()
+	}
+}
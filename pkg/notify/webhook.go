@@ -0,0 +1,195 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+const (
+	// SignatureHeader is the header a webhook request's signature is sent
+	// in, if its webhook has a secret: "sha256=" followed by the
+	// hex-encoded HMAC-SHA256 of the request body.
+	SignatureHeader = "X-Ark-Signature"
+
+	// DefaultTimeout is how long a webhook request can take, including
+	// reading its response, before it's abandoned.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Payload is the JSON summary of a finished backup that's posted to its
+// webhook.
+type Payload struct {
+	Namespace           string          `json:"namespace"`
+	Name                string          `json:"name"`
+	Phase               api.BackupPhase `json:"phase"`
+	FailureReason       string          `json:"failureReason,omitempty"`
+	ValidationErrors    []string        `json:"validationErrors,omitempty"`
+	PartialFailures     []string        `json:"partialFailures,omitempty"`
+	Warnings            int             `json:"warnings"`
+	StorageLocation     string          `json:"storageLocation"`
+	ItemsBackedUp       int             `json:"itemsBackedUp"`
+	StartTimestamp      metav1.Time     `json:"startTimestamp"`
+	CompletionTimestamp metav1.Time     `json:"completionTimestamp"`
+}
+
+// NewPayload returns the summary of backup that's posted to its webhook.
+func NewPayload(backup *api.Backup) Payload {
+	payload := Payload{
+		Namespace:           backup.Namespace,
+		Name:                backup.Name,
+		Phase:               backup.Status.Phase,
+		FailureReason:       backup.Status.FailureReason,
+		ValidationErrors:    backup.Status.ValidationErrors,
+		PartialFailures:     backup.Status.PartialFailures,
+		Warnings:            backup.Status.Warnings,
+		StorageLocation:     backup.Spec.StorageLocation,
+		StartTimestamp:      backup.Status.StartTimestamp,
+		CompletionTimestamp: backup.Status.CompletionTimestamp,
+	}
+	if backup.Status.Progress != nil {
+		payload.ItemsBackedUp = backup.Status.Progress.ItemsBackedUp
+	}
+
+	return payload
+}
+
+// Notifier notifies finished backups' webhooks.
+type Notifier interface {
+	// Notify posts backup's summary to its webhook. It does nothing if
+	// backup doesn't have one.
+	Notify(backup *api.Backup) error
+}
+
+type webhookNotifier struct {
+	secrets corev1client.SecretsGetter
+	client  *http.Client
+}
+
+// NewWebhookNotifier returns a Notifier that posts backups' summaries to
+// their webhooks, signing them with the secrets they reference, and
+// abandons requests that take longer than timeout.
+func NewWebhookNotifier(secrets corev1client.SecretsGetter, timeout time.Duration) Notifier {
+	return &webhookNotifier{
+		secrets: secrets,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *webhookNotifier) Notify(backup *api.Backup) error {
+	webhook := backup.Spec.NotifyWebhook
+	if webhook == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(NewPayload(backup))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if webhook.SecretRef != nil {
+		key, err := n.getSecret(backup.Namespace, webhook.SecretRef.Name, webhook.SecretRef.Key)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(SignatureHeader, Sign(key, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error posting to notify webhook")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("notify webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func (n *webhookNotifier) getSecret(namespace, name, key string) ([]byte, error) {
+	secret, err := n.secrets.Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting notify webhook secret %s/%s", namespace, name)
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, errors.Errorf("notify webhook secret %s/%s does not have key %q", namespace, name, key)
+	}
+
+	return value, nil
+}
+
+// Sign returns the signature of body, keyed with key, that's sent in a
+// webhook request's SignatureHeader.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateWebhook returns the reasons webhook is invalid, if any.
+func ValidateWebhook(webhook *api.BackupNotifyWebhook) []string {
+	if webhook == nil {
+		return nil
+	}
+
+	var errs []string
+
+	u, err := url.Parse(webhook.URL)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Sprintf("Invalid notify webhook URL %q: %v", webhook.URL, err))
+	case u.Scheme != "http" && u.Scheme != "https":
+		errs = append(errs, fmt.Sprintf("Invalid notify webhook URL %q: scheme must be http or https", webhook.URL))
+	case u.Host == "":
+		errs = append(errs, fmt.Sprintf("Invalid notify webhook URL %q: host must be specified", webhook.URL))
+	}
+
+	if ref := webhook.SecretRef; ref != nil && (ref.Name == "" || ref.Key == "") {
+		errs = append(errs, "Invalid notify webhook secretRef: name and key must be specified")
+	}
+
+	return errs
+}
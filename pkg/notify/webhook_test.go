@@ -0,0 +1,251 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+type fakeSecrets struct {
+	corev1client.SecretInterface
+	secrets map[string]*corev1api.Secret
+}
+
+func (f *fakeSecrets) Secrets(namespace string) corev1client.SecretInterface {
+	return f
+}
+
+func (f *fakeSecrets) Get(name string, _ metav1.GetOptions) (*corev1api.Secret, error) {
+	secret, ok := f.secrets[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret, nil
+}
+
+// request is a request received by a fake webhook server.
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookServer returns a server that responds to each request with
+// status, and a channel it sends the requests it receives on.
+func newWebhookServer(status int) (*httptest.Server, <-chan request) {
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func TestNotify(t *testing.T) {
+	// metav1.Times are unmarshalled in the local time zone.
+	start := metav1.NewTime(time.Date(2018, 7, 1, 12, 0, 0, 0, time.Local))
+	completion := metav1.NewTime(start.Add(time.Minute))
+
+	tests := []struct {
+		name     string
+		backup   *arktest.TestBackup
+		expected Payload
+	}{
+		{
+			name: "completed backup",
+			backup: arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").
+				WithStorageLocation("default").
+				WithPhase(api.BackupPhaseCompleted).
+				WithStartTimestamp(start.Time).
+				WithCompletionTimestamp(completion.Time),
+			expected: Payload{
+				Namespace:           "ark",
+				Name:                "backup-1",
+				Phase:               api.BackupPhaseCompleted,
+				StorageLocation:     "default",
+				StartTimestamp:      start,
+				CompletionTimestamp: completion,
+			},
+		},
+		{
+			name: "failed backup",
+			backup: arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").
+				WithStorageLocation("default").
+				WithPhase(api.BackupPhaseFailed).
+				WithFailureReason("error uploading backup").
+				WithStartTimestamp(start.Time).
+				WithCompletionTimestamp(completion.Time),
+			expected: Payload{
+				Namespace:           "ark",
+				Name:                "backup-1",
+				Phase:               api.BackupPhaseFailed,
+				FailureReason:       "error uploading backup",
+				StorageLocation:     "default",
+				StartTimestamp:      start,
+				CompletionTimestamp: completion,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := newWebhookServer(http.StatusOK)
+			defer server.Close()
+
+			backup := test.backup.Backup
+			backup.Spec.NotifyWebhook = &api.BackupNotifyWebhook{URL: server.URL + "/hooks/ark"}
+
+			notifier := NewWebhookNotifier(&fakeSecrets{}, DefaultTimeout)
+			require.NoError(t, notifier.Notify(backup))
+
+			req := <-requests
+			assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+			assert.Empty(t, req.header.Get(SignatureHeader))
+
+			var payload Payload
+			require.NoError(t, json.Unmarshal(req.body, &payload))
+			assert.Equal(t, test.expected, payload)
+		})
+	}
+}
+
+func TestNotifySignsPayload(t *testing.T) {
+	server, requests := newWebhookServer(http.StatusNoContent)
+	defer server.Close()
+
+	secrets := &fakeSecrets{
+		secrets: map[string]*corev1api.Secret{
+			"webhook": {Data: map[string][]byte{"key": []byte("signing-key")}},
+		},
+	}
+
+	backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").WithPhase(api.BackupPhaseCompleted).Backup
+	backup.Spec.NotifyWebhook = &api.BackupNotifyWebhook{
+		URL:       server.URL,
+		SecretRef: &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "webhook"}, Key: "key"},
+	}
+
+	require.NoError(t, NewWebhookNotifier(secrets, DefaultTimeout).Notify(backup))
+
+	req := <-requests
+	assert.Equal(t, Sign([]byte("signing-key"), req.body), req.header.Get(SignatureHeader))
+}
+
+func TestNotifyErrors(t *testing.T) {
+	backup := arktest.NewTestBackup().WithNamespace("ark").WithName("backup-1").WithPhase(api.BackupPhaseCompleted).Backup
+
+	// backups without a webhook aren't notified.
+	assert.NoError(t, NewWebhookNotifier(&fakeSecrets{}, DefaultTimeout).Notify(backup))
+
+	t.Run("error response", func(t *testing.T) {
+		server, _ := newWebhookServer(http.StatusInternalServerError)
+		defer server.Close()
+
+		backup := backup.DeepCopy()
+		backup.Spec.NotifyWebhook = &api.BackupNotifyWebhook{URL: server.URL}
+
+		err := NewWebhookNotifier(&fakeSecrets{}, DefaultTimeout).Notify(backup)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "notify webhook returned 500")
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		server, requests := newWebhookServer(http.StatusOK)
+		defer server.Close()
+
+		backup := backup.DeepCopy()
+		backup.Spec.NotifyWebhook = &api.BackupNotifyWebhook{
+			URL:       server.URL,
+			SecretRef: &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "webhook"}, Key: "key"},
+		}
+
+		err := NewWebhookNotifier(&fakeSecrets{}, DefaultTimeout).Notify(backup)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "error getting notify webhook secret ark/webhook")
+		assert.Len(t, requests, 0)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		done := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer server.Close()
+		defer close(done)
+
+		backup := backup.DeepCopy()
+		backup.Spec.NotifyWebhook = &api.BackupNotifyWebhook{URL: server.URL}
+
+		start := time.Now()
+		err := NewWebhookNotifier(&fakeSecrets{}, 50*time.Millisecond).Notify(backup)
+		require.Error(t, err)
+		assert.True(t, time.Since(start) < 5*time.Second, "notify took %v", time.Since(start))
+	})
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name        string
+		webhook     *api.BackupNotifyWebhook
+		expectedErr string
+	}{
+		{name: "no webhook"},
+		{name: "https URL", webhook: &api.BackupNotifyWebhook{URL: "https://hooks.example.com/ark"}},
+		{name: "http URL", webhook: &api.BackupNotifyWebhook{URL: "http://ark-notifier.ark.svc:8080"}},
+		{name: "missing URL", webhook: &api.BackupNotifyWebhook{}, expectedErr: "scheme must be http or https"},
+		{name: "unsupported scheme", webhook: &api.BackupNotifyWebhook{URL: "ftp://example.com"}, expectedErr: "scheme must be http or https"},
+		{name: "missing host", webhook: &api.BackupNotifyWebhook{URL: "https:///ark"}, expectedErr: "host must be specified"},
+		{name: "unparseable URL", webhook: &api.BackupNotifyWebhook{URL: "https://exa mple.com"}, expectedErr: "Invalid notify webhook URL"},
+		{
+			name: "secret without key",
+			webhook: &api.BackupNotifyWebhook{
+				URL:       "https://hooks.example.com/ark",
+				SecretRef: &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "webhook"}},
+			},
+			expectedErr: "name and key must be specified",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := ValidateWebhook(test.webhook)
+			if test.expectedErr == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Contains(t, errs[0], test.expectedErr)
+		})
+	}
+}
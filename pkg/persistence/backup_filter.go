@@ -0,0 +1,60 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// BackupFilter scopes the backups in a backup store to those that are
+// relevant to a server, e.g. when several clusters or versions of Ark
+// share a bucket. The zero value doesn't filter out any backups.
+type BackupFilter struct {
+	// ClusterName, if non-empty, filters out backups labeled as having
+	// been taken by a different cluster. Backups without the label are
+	// kept, since they may predate it.
+	ClusterName string
+
+	// MinVersion filters out backups with an older format version.
+	MinVersion int
+
+	// Selector, if non-nil, filters out backups whose labels don't
+	// match it.
+	Selector labels.Selector
+}
+
+// Exclude returns the reason the backup should be filtered out, or an
+// empty string if it shouldn't be.
+func (f BackupFilter) Exclude(backup *arkv1api.Backup) string {
+	if cluster := backup.Labels[arkv1api.ClusterNameLabel]; f.ClusterName != "" && cluster != "" && cluster != f.ClusterName {
+		return fmt.Sprintf("it was taken by cluster %s", cluster)
+	}
+
+	if backup.Status.Version < f.MinVersion {
+		return fmt.Sprintf("its format version %d is older than %d", backup.Status.Version, f.MinVersion)
+	}
+
+	if f.Selector != nil && !f.Selector.Matches(labels.Set(backup.Labels)) {
+		return fmt.Sprintf("its labels don't match selector %s", f.Selector)
+	}
+
+	return ""
+}
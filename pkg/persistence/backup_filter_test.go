@@ -0,0 +1,80 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestBackupFilterExclude(t *testing.T) {
+	tests := []struct {
+		name           string
+		filter         BackupFilter
+		backup         *api.Backup
+		expectedReason string
+	}{
+		{
+			name:   "zero value filter excludes nothing",
+			backup: arktest.NewTestBackup().WithLabel(api.ClusterNameLabel, "cluster-2").Backup,
+		},
+		{
+			name:           "backup from another cluster is excluded",
+			filter:         BackupFilter{ClusterName: "cluster-1"},
+			backup:         arktest.NewTestBackup().WithLabel(api.ClusterNameLabel, "cluster-2").WithVersion(1).Backup,
+			expectedReason: "it was taken by cluster cluster-2",
+		},
+		{
+			name:   "backup from this cluster is kept",
+			filter: BackupFilter{ClusterName: "cluster-1"},
+			backup: arktest.NewTestBackup().WithLabel(api.ClusterNameLabel, "cluster-1").Backup,
+		},
+		{
+			name:   "backup without a cluster label is kept",
+			filter: BackupFilter{ClusterName: "cluster-1"},
+			backup: arktest.NewTestBackup().Backup,
+		},
+		{
+			name:           "backup with an old version is excluded",
+			filter:         BackupFilter{MinVersion: 2},
+			backup:         arktest.NewTestBackup().WithVersion(1).Backup,
+			expectedReason: "its format version 1 is older than 2",
+		},
+		{
+			name:           "backup not matching selector is excluded",
+			filter:         BackupFilter{Selector: labels.SelectorFromSet(labels.Set{"env": "prod"})},
+			backup:         arktest.NewTestBackup().WithLabel("env", "dev").Backup,
+			expectedReason: "its labels don't match selector env=prod",
+		},
+		{
+			name:   "backup matching selector is kept",
+			filter: BackupFilter{Selector: labels.SelectorFromSet(labels.Set{"env": "prod"})},
+			backup: arktest.NewTestBackup().WithLabel("env", "prod").Backup,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expectedReason, test.filter.Exclude(test.backup))
+		})
+	}
+}
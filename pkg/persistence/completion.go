@@ -0,0 +1,67 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// IsBackupComplete returns true if all of the backup's artifacts were
+// uploaded to the backup store, i.e. its completion marker exists. Backups
+// that weren't uploaded with a completion marker (see
+// arkv1api.CompletionMarkerAnnotation) are assumed to be complete.
+func IsBackupComplete(store BackupStore, backup *arkv1api.Backup) (bool, error) {
+	if backup.Annotations[arkv1api.CompletionMarkerAnnotation] != "true" {
+		return true, nil
+	}
+
+	artifacts, err := store.ListBackupArtifacts(backup.Name)
+	if err != nil {
+		return false, err
+	}
+
+	for _, artifact := range artifacts {
+		if artifact == BackupArtifactCompletionMarker {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// HasBackupData returns true if the backup's metadata and tarball are both
+// in the backup store. Unlike BackupStore.BackupExists, it doesn't require
+// the completion marker, so that backups uploaded before the marker existed
+// are found; use IsBackupComplete to check the marker where it's expected.
+func HasBackupData(store BackupStore, name string) (bool, error) {
+	artifacts, err := store.ListBackupArtifacts(name)
+	if err != nil {
+		return false, err
+	}
+
+	var metadata, contents bool
+	for _, artifact := range artifacts {
+		switch artifact {
+		case BackupArtifactMetadata:
+			metadata = true
+		case BackupArtifactContents:
+			contents = true
+		}
+	}
+
+	return metadata && contents, nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"github.com/pkg/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/cloudprovider"
+)
+
+// CredentialsGetter gets the cloud credentials that backups reference in
+// their CredentialSecretRef.
+type CredentialsGetter interface {
+	GetCredentials(backup *arkv1api.Backup) ([]byte, error)
+}
+
+type secretCredentialsGetter struct {
+	secrets corev1client.SecretsGetter
+}
+
+// NewSecretCredentialsGetter returns a CredentialsGetter that gets
+// backups' credentials from the secrets referenced by their
+// CredentialSecretRef.
+func NewSecretCredentialsGetter(secrets corev1client.SecretsGetter) CredentialsGetter {
+	return &secretCredentialsGetter{secrets: secrets}
+}
+
+func (g *secretCredentialsGetter) GetCredentials(backup *arkv1api.Backup) ([]byte, error) {
+	ref := backup.Spec.CredentialSecretRef
+	if ref == nil {
+		return nil, errors.Errorf("backup %s does not have a credential secret", backup.Name)
+	}
+
+	secret, err := g.secrets.Secrets(backup.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting credential secret %s/%s", backup.Namespace, ref.Name)
+	}
+
+	credentials, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, errors.Errorf("credential secret %s/%s does not have key %q", backup.Namespace, ref.Name, ref.Key)
+	}
+	if len(credentials) == 0 {
+		return nil, errors.Errorf("key %q of credential secret %s/%s is empty", ref.Key, backup.Namespace, ref.Name)
+	}
+
+	return credentials, nil
+}
+
+// WithCredentialsFile returns a copy of location whose object store uses
+// the credentials in credentialsFile instead of its default credentials.
+func WithCredentialsFile(location *arkv1api.BackupStorageLocation, credentialsFile string) *arkv1api.BackupStorageLocation {
+	location = location.DeepCopy()
+
+	if location.Spec.Config == nil {
+		location.Spec.Config = make(map[string]string)
+	}
+	location.Spec.Config[cloudprovider.CredentialsFileConfigKey] = credentialsFile
+
+	return location
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1api "k8s.io/api/core/v1"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/cloudprovider"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestSecretCredentialsGetter(t *testing.T) {
+	secrets := &fakeSecrets{
+		secrets: map[string]*corev1api.Secret{
+			"team-a": {Data: map[string][]byte{"cloud": []byte("team-a-credentials"), "empty": nil}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		secret      *corev1api.SecretKeySelector
+		expectedErr bool
+	}{
+		{
+			name:   "credentials are returned",
+			secret: &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "team-a"}, Key: "cloud"},
+		},
+		{
+			name:        "missing secret is an error",
+			secret:      &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "missing"}, Key: "cloud"},
+			expectedErr: true,
+		},
+		{
+			name:        "missing key is an error",
+			secret:      &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "team-a"}, Key: "other"},
+			expectedErr: true,
+		},
+		{
+			name:        "empty key is an error",
+			secret:      &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "team-a"}, Key: "empty"},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithName("backup-1").Backup
+			backup.Spec.CredentialSecretRef = test.secret
+
+			credentials, err := NewSecretCredentialsGetter(secrets).GetCredentials(backup)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "team-a-credentials", string(credentials))
+		})
+	}
+}
+
+func TestWithCredentialsFile(t *testing.T) {
+	location := &api.BackupStorageLocation{
+		Spec: api.BackupStorageLocationSpec{
+			Config: map[string]string{"region": "us-east-1"},
+		},
+	}
+
+	res := WithCredentialsFile(location, "/tmp/credentials")
+
+	assert.Equal(t, map[string]string{"region": "us-east-1", cloudprovider.CredentialsFileConfigKey: "/tmp/credentials"}, res.Spec.Config)
+	assert.Equal(t, map[string]string{"region": "us-east-1"}, location.Spec.Config)
+}
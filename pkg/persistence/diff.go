@@ -0,0 +1,70 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/heptio/ark/pkg/archive"
+)
+
+// DiffBackups compares the contents of two backups in a backup store and
+// returns the items that were added, removed, or (if compareContents is
+// true) changed between the older and the newer backup. Each tarball is
+// streamed from the store, so only their inventories are held in memory.
+func DiffBackups(store BackupStore, oldBackup, newBackup string, compareContents bool) ([]archive.ResourceDiff, error) {
+	oldInventory, oldDigests, err := readBackupInventory(store, oldBackup, compareContents)
+	if err != nil {
+		return nil, err
+	}
+
+	newInventory, newDigests, err := readBackupInventory(store, newBackup, compareContents)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.DiffInventories(oldInventory, newInventory, oldDigests, newDigests), nil
+}
+
+func readBackupInventory(store BackupStore, name string, withDigests bool) (archive.Inventory, archive.ItemDigests, error) {
+	backup, err := store.GetBackupMetadata(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	algorithm := archive.CompressionAlgorithmForBackup(backup)
+
+	contents, err := store.GetBackupContents(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer contents.Close()
+
+	var (
+		inventory archive.Inventory
+		digests   archive.ItemDigests
+	)
+	if withDigests {
+		inventory, digests, err = archive.ReadInventoryWithDigests(contents, algorithm)
+	} else {
+		inventory, err = archive.ReadInventory(contents, algorithm)
+	}
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error reading contents of backup "+name)
+	}
+
+	return inventory, digests, nil
+}
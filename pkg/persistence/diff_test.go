@@ -0,0 +1,85 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/heptio/ark/pkg/archive"
+)
+
+func newTarball(t *testing.T, files map[string]string) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	for path, contents := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     path,
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+			Size:     int64(len(contents)),
+		}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return buf
+}
+
+func TestDiffBackups(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+
+	oldContents := newTarball(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-1.json": "{}",
+		"resources/pods/namespaces/ns-1/pod-2.json": "{}",
+	})
+	newContents := newTarball(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-1.json": `{"changed":true}`,
+		"resources/pods/namespaces/ns-1/pod-3.json": "{}",
+	})
+
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/old/ark-backup.json", strings.NewReader(`{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"old"}}`)))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/new/ark-backup.json", strings.NewReader(`{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"new"}}`)))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/old/old.tar.gz", bytes.NewReader(oldContents.Bytes())))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/new/new.tar.gz", bytes.NewReader(newContents.Bytes())))
+
+	diffs, err := DiffBackups(harness, "old", "new", false)
+	require.NoError(t, err)
+	assert.Equal(t, []archive.ResourceDiff{
+		{GroupResource: "pods", Namespace: "ns-1", Added: []string{"pod-3"}, Removed: []string{"pod-2"}},
+	}, diffs)
+
+	diffs, err = DiffBackups(harness, "old", "new", true)
+	require.NoError(t, err)
+	assert.Equal(t, []archive.ResourceDiff{
+		{GroupResource: "pods", Namespace: "ns-1", Added: []string{"pod-3"}, Removed: []string{"pod-2"}, Changed: []string{"pod-1"}},
+	}, diffs)
+
+	_, err = DiffBackups(harness, "old", "missing", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,364 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// Encrypted objects are written as a header followed by a sequence of
+// chunks. The header is encryptionMagic, followed by a random salt, which
+// is used to derive a key for the object from the location's key with
+// HKDF-SHA256, so that no two objects share a key. Each chunk is a 4-byte
+// big-endian length, whose top bit is set for the last chunk, followed by
+// up to encryptionChunkSize bytes of plaintext sealed with AES-256-GCM
+// under the object's key. A chunk's nonce is its 8-byte big-endian
+// sequence number, padded to the nonce size with leading zeros, and
+// whether it's the last chunk is authenticated as additional data so that
+// truncated objects can't be decrypted.
+var encryptionMagic = []byte("ARKENC\x00\x02")
+
+// encryptionMagicV1 starts objects written by earlier versions of Ark,
+// whose chunks were sealed under the location's key itself, with a random
+// 4-byte nonce prefix in place of the salt. So many objects shared the key
+// that their nonces could collide, so they're still decrypted, but no
+// longer written.
+var encryptionMagicV1 = []byte("ARKENC\x00\x01")
+
+const (
+	// EncryptionKeySize is the size in bytes of the AES-256 keys that
+	// backups are encrypted with.
+	EncryptionKeySize = 32
+
+	encryptionSaltSize          = 32
+	encryptionNoncePrefixSizeV1 = 4
+	encryptionChunkSize         = 64 * 1024
+	encryptionLastChunkFlag     = 1 << 31
+)
+
+// encryptionKeyInfo is the HKDF info that objects' keys are derived with.
+var encryptionKeyInfo = []byte("ark.heptio.com/backup-encryption")
+
+// EncryptionKeyGetter gets the keys that backups in backup storage
+// locations are encrypted with.
+type EncryptionKeyGetter interface {
+	GetEncryptionKey(location *arkv1api.BackupStorageLocation) ([]byte, error)
+}
+
+type secretEncryptionKeyGetter struct {
+	secrets corev1client.SecretsGetter
+}
+
+// NewSecretEncryptionKeyGetter returns an EncryptionKeyGetter that gets
+// locations' keys from the secrets referenced by their
+// EncryptionKeySecret.
+func NewSecretEncryptionKeyGetter(secrets corev1client.SecretsGetter) EncryptionKeyGetter {
+	return &secretEncryptionKeyGetter{secrets: secrets}
+}
+
+func (g *secretEncryptionKeyGetter) GetEncryptionKey(location *arkv1api.BackupStorageLocation) ([]byte, error) {
+	ref := location.Spec.EncryptionKeySecret
+	if ref == nil {
+		return nil, errors.Errorf("backup storage location %s does not have an encryption key secret", location.Name)
+	}
+
+	secret, err := g.secrets.Secrets(location.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting encryption key secret %s/%s", location.Namespace, ref.Name)
+	}
+
+	key, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, errors.Errorf("encryption key secret %s/%s does not have key %q", location.Namespace, ref.Name, ref.Key)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, errors.Errorf("encryption key in secret %s/%s must be %d bytes, not %d", location.Namespace, ref.Name, EncryptionKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+// NewBackupStoreFactory returns a function with the same signature as
+// NewObjectBackupStore that gets the keys for locations with an
+// EncryptionKeySecret from keys, so that the backups in them are
+// encrypted and decrypted. If keys is nil, it returns
+// NewObjectBackupStore.
+func NewBackupStoreFactory(keys EncryptionKeyGetter) func(*arkv1api.BackupStorageLocation, ObjectStoreGetter, logrus.FieldLogger) (BackupStore, error) {
+	if keys == nil {
+		return NewObjectBackupStore
+	}
+
+	return func(location *arkv1api.BackupStorageLocation, objectStoreGetter ObjectStoreGetter, logger logrus.FieldLogger) (BackupStore, error) {
+		var key []byte
+		if location.Spec.EncryptionKeySecret != nil {
+			var err error
+			if key, err = keys.GetEncryptionKey(location); err != nil {
+				return nil, err
+			}
+		}
+
+		return newObjectBackupStore(location, objectStoreGetter, logger, key)
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return aead, nil
+}
+
+// deriveObjectKey derives the key for an object with the given salt from
+// the location's key, using HKDF-SHA256 (RFC 5869). A single block of
+// output is as long as an AES-256 key, so expanding takes one HMAC.
+func deriveObjectKey(locationKey, salt []byte) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(locationKey)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(encryptionKeyInfo)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:EncryptionKeySize]
+}
+
+// chunkNonce returns the nonce for the chunk with sequence number seq:
+// prefix, zero-padded, followed by seq.
+func chunkNonce(aead cipher.AEAD, prefix []byte, seq uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], seq)
+	return nonce
+}
+
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type encryptingReader struct {
+	src       io.Reader
+	aead      cipher.AEAD
+	seq       uint64
+	plaintext []byte
+	out       bytes.Buffer
+	done      bool
+}
+
+// newEncryptingReader returns a reader of the encrypted form of src,
+// under a key derived from key for it alone.
+func newEncryptingReader(src io.Reader, key []byte) (io.Reader, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrap(err, "error generating salt")
+	}
+
+	aead, err := newAEAD(deriveObjectKey(key, salt))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &encryptingReader{
+		src:       src,
+		aead:      aead,
+		plaintext: make([]byte, encryptionChunkSize),
+	}
+
+	r.out.Write(encryptionMagic)
+	r.out.Write(salt)
+
+	return r, nil
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.out.Read(p)
+}
+
+// sealChunk reads the next chunk of plaintext from src and writes it,
+// sealed, to out. A short read means src is exhausted, so the chunk is
+// the last one; if src's length is a multiple of the chunk size, the last
+// chunk is empty.
+func (r *encryptingReader) sealChunk() error {
+	n, err := io.ReadFull(r.src, r.plaintext)
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		r.done = true
+	default:
+		return err
+	}
+
+	sealed := r.aead.Seal(nil, chunkNonce(r.aead, nil, r.seq), r.plaintext[:n], chunkAdditionalData(r.done))
+	r.seq++
+
+	length := uint32(len(sealed))
+	if r.done {
+		length |= encryptionLastChunkFlag
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], length)
+	r.out.Write(header[:])
+	r.out.Write(sealed)
+
+	return nil
+}
+
+type decryptingReader struct {
+	src  io.Reader
+	aead cipher.AEAD
+	// noncePrefix is the random prefix of a V1 object's nonces, or nil.
+	noncePrefix []byte
+	seq         uint64
+	out         []byte
+	done        bool
+}
+
+// newDecryptingReader returns a reader of the decrypted form of src. If
+// src doesn't start with encryptionMagic or encryptionMagicV1, it's
+// assumed to be unencrypted and is returned as-is, so backups stored
+// before a location was configured for encryption remain readable. If src
+// is encrypted and key is nil, an error is returned.
+func newDecryptingReader(src io.Reader, key []byte) (io.Reader, error) {
+	buffered := bufio.NewReader(src)
+
+	magic, err := buffered.Peek(len(encryptionMagic))
+	if err != nil && err != io.EOF {
+		return nil, errors.WithStack(err)
+	}
+
+	var headerSize int
+	switch {
+	case bytes.Equal(magic, encryptionMagic):
+		headerSize = len(encryptionMagic) + encryptionSaltSize
+	case bytes.Equal(magic, encryptionMagicV1):
+		headerSize = len(encryptionMagicV1) + encryptionNoncePrefixSizeV1
+	default:
+		return buffered, nil
+	}
+
+	if key == nil {
+		return nil, errors.New("object is encrypted, but the backup storage location does not have an encryption key secret")
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(buffered, header); err != nil {
+		return nil, errors.Wrap(err, "error reading encryption header")
+	}
+
+	r := &decryptingReader{src: buffered}
+	if bytes.Equal(magic, encryptionMagicV1) {
+		r.noncePrefix = header[len(encryptionMagicV1):]
+	} else {
+		key = deriveObjectKey(key, header[len(encryptionMagic):])
+	}
+
+	if r.aead, err = newAEAD(key); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.openChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) openChunk() error {
+	var header [4]byte
+	if _, err := io.ReadFull(r.src, header[:]); err != nil {
+		return errors.Wrap(unexpectedEOF(err), "error reading encrypted chunk")
+	}
+
+	length := binary.BigEndian.Uint32(header[:])
+	last := length&encryptionLastChunkFlag != 0
+	length &^= encryptionLastChunkFlag
+
+	if length > encryptionChunkSize+uint32(r.aead.Overhead()) {
+		return errors.Errorf("encrypted chunk is too large (%d bytes)", length)
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(r.src, sealed); err != nil {
+		return errors.Wrap(unexpectedEOF(err), "error reading encrypted chunk")
+	}
+
+	plaintext, err := r.aead.Open(sealed[:0], chunkNonce(r.aead, r.noncePrefix, r.seq), sealed, chunkAdditionalData(last))
+	if err != nil {
+		return errors.Wrap(err, "error decrypting chunk")
+	}
+	r.seq++
+
+	r.out = plaintext
+	r.done = last
+
+	return nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF, since an
+// encrypted object must end with its last chunk.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
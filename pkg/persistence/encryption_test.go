@@ -0,0 +1,321 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{0x42}, EncryptionKeySize)
+
+const testBackupMetadata = `{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"backup-1"}}`
+
+const testBackupContentIndex = "resources/secrets/namespaces/ns-1/secret-1.json\n"
+
+const testBackupManifest = `{"manifestVersion":1}
+{"path":"resources/secrets/namespaces/ns-1/secret-1.json","resource":"secrets","namespace":"ns-1","name":"secret-1","offset":512,"size":100}
+`
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "less than a chunk", size: 100},
+		{name: "exactly one chunk", size: encryptionChunkSize},
+		{name: "several chunks", size: 3*encryptionChunkSize + 17},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plaintext := bytes.Repeat([]byte("a"), test.size)
+
+			encrypted, err := newEncryptingReader(bytes.NewReader(plaintext), testEncryptionKey)
+			require.NoError(t, err)
+			ciphertext, err := ioutil.ReadAll(encrypted)
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(ciphertext, encryptionMagic))
+
+			decrypted, err := newDecryptingReader(bytes.NewReader(ciphertext), testEncryptionKey)
+			require.NoError(t, err)
+			res, err := ioutil.ReadAll(decrypted)
+			require.NoError(t, err)
+			assert.Equal(t, plaintext, res)
+		})
+	}
+}
+
+func TestDecryptionDetectsTampering(t *testing.T) {
+	encrypted, err := newEncryptingReader(bytes.NewReader(bytes.Repeat([]byte("a"), 2*encryptionChunkSize)), testEncryptionKey)
+	require.NoError(t, err)
+	ciphertext, err := ioutil.ReadAll(encrypted)
+	require.NoError(t, err)
+
+	// dropping the last chunk
+	truncated := ciphertext[:len(encryptionMagic)+encryptionSaltSize+2*(4+encryptionChunkSize+16)]
+	decrypted, err := newDecryptingReader(bytes.NewReader(truncated), testEncryptionKey)
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(decrypted)
+	assert.Error(t, err)
+
+	// using the wrong key
+	decrypted, err = newDecryptingReader(bytes.NewReader(ciphertext), bytes.Repeat([]byte{0x24}, EncryptionKeySize))
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(decrypted)
+	assert.Error(t, err)
+}
+
+func TestEncryptionUsesAKeyPerObject(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("a"), 100)
+
+	var headers [][]byte
+	for i := 0; i < 2; i++ {
+		encrypted, err := newEncryptingReader(bytes.NewReader(plaintext), testEncryptionKey)
+		require.NoError(t, err)
+		ciphertext, err := ioutil.ReadAll(encrypted)
+		require.NoError(t, err)
+		headers = append(headers, ciphertext[:len(encryptionMagic)+encryptionSaltSize])
+
+		// the chunks aren't sealed under the location's key itself
+		salt := ciphertext[len(encryptionMagic) : len(encryptionMagic)+encryptionSaltSize]
+		objectKey := deriveObjectKey(testEncryptionKey, salt)
+		assert.NotEqual(t, testEncryptionKey, objectKey)
+		aead, err := newAEAD(objectKey)
+		require.NoError(t, err)
+		sealed := ciphertext[len(encryptionMagic)+encryptionSaltSize+4:]
+		opened, err := aead.Open(nil, chunkNonce(aead, nil, 0), sealed, chunkAdditionalData(true))
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, opened)
+	}
+
+	// the same plaintext encrypted twice gets different salts, and so
+	// different keys
+	assert.NotEqual(t, headers[0], headers[1])
+}
+
+func TestDecryptV1Objects(t *testing.T) {
+	// objects written before keys were derived per object are sealed
+	// under the location's key, with a random nonce prefix
+	plaintext := []byte("contents")
+	noncePrefix := []byte{1, 2, 3, 4}
+
+	aead, err := newAEAD(testEncryptionKey)
+	require.NoError(t, err)
+	sealed := aead.Seal(nil, chunkNonce(aead, noncePrefix, 0), plaintext, chunkAdditionalData(true))
+
+	var ciphertext bytes.Buffer
+	ciphertext.Write(encryptionMagicV1)
+	ciphertext.Write(noncePrefix)
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(sealed))|encryptionLastChunkFlag)
+	ciphertext.Write(header[:])
+	ciphertext.Write(sealed)
+
+	decrypted, err := newDecryptingReader(bytes.NewReader(ciphertext.Bytes()), testEncryptionKey)
+	require.NoError(t, err)
+	res, err := ioutil.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, res)
+
+	_, err = newDecryptingReader(bytes.NewReader(ciphertext.Bytes()), nil)
+	assert.Error(t, err)
+}
+
+func TestPutBackupEncrypted(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+	harness.encrypted = true
+	harness.encryptionKey = testEncryptionKey
+
+	require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(testBackupMetadata), newStringReadSeeker("contents"), newStringReadSeeker(testBackupContentIndex), newStringReadSeeker(testBackupManifest), newStringReadSeeker("log")))
+
+	// the metadata and contents, and the content index and manifest, which
+	// list their items, are stored encrypted
+	for _, key := range []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1-index.txt", "backups/backup-1/backup-1-manifest.jsonl"} {
+		assert.True(t, bytes.HasPrefix(harness.objectStore.Data[harness.bucket][key], encryptionMagic), key)
+		assert.NotContains(t, string(harness.objectStore.Data[harness.bucket][key]), "secret-1", key)
+	}
+
+	index, err := harness.GetBackupContentIndex("backup-1")
+	require.NoError(t, err)
+	defer index.Close()
+	res, err := ioutil.ReadAll(index)
+	require.NoError(t, err)
+	assert.Equal(t, testBackupContentIndex, string(res))
+
+	// a content index that's regenerated, e.g. by RepairBackup, is
+	// encrypted too
+	require.NoError(t, harness.PutBackupContentIndex("backup-1", newStringReadSeeker(testBackupContentIndex)))
+	assert.True(t, bytes.HasPrefix(harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1-index.txt"], encryptionMagic))
+
+	manifest, err := harness.GetBackupManifest("backup-1")
+	require.NoError(t, err)
+	require.Len(t, manifest, 1)
+	assert.Equal(t, "secret-1", manifest[0].Name)
+
+	backup, err := harness.GetBackupMetadata("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, "backup-1", backup.Name)
+
+	contents, err := harness.GetBackupContents("backup-1")
+	require.NoError(t, err)
+	defer contents.Close()
+	res, err = ioutil.ReadAll(contents)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(res))
+
+	// an encrypted backup can't be read without the key
+	harness.encryptionKey = nil
+	_, err = harness.GetBackupMetadata("backup-1")
+	assert.Error(t, err)
+	_, err = harness.GetBackupContents("backup-1")
+	assert.Error(t, err)
+	_, err = harness.GetBackupContentIndex("backup-1")
+	assert.Error(t, err)
+	_, err = harness.GetBackupManifest("backup-1")
+	assert.Error(t, err)
+
+	// nor can it be put
+	assert.Error(t, harness.PutBackup("backup-2", newStringReadSeeker(testBackupMetadata), newStringReadSeeker("contents"), nil, nil, nil))
+	assert.Empty(t, harness.objectStore.Data[harness.bucket]["backups/backup-2/ark-backup.json"])
+}
+
+func TestGetUnencryptedBackupFromEncryptedStore(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+	require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(testBackupMetadata), newStringReadSeeker("contents"), nil, nil, nil))
+
+	harness.encrypted = true
+	harness.encryptionKey = testEncryptionKey
+
+	backup, err := harness.GetBackupMetadata("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, "backup-1", backup.Name)
+
+	contents, err := harness.GetBackupContents("backup-1")
+	require.NoError(t, err)
+	defer contents.Close()
+	res, err := ioutil.ReadAll(contents)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(res))
+}
+
+type fakeSecrets struct {
+	corev1client.SecretInterface
+	secrets map[string]*corev1api.Secret
+}
+
+func (f *fakeSecrets) Secrets(namespace string) corev1client.SecretInterface {
+	return f
+}
+
+func (f *fakeSecrets) Get(name string, _ metav1.GetOptions) (*corev1api.Secret, error) {
+	secret, ok := f.secrets[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret, nil
+}
+
+func TestSecretEncryptionKeyGetter(t *testing.T) {
+	secrets := &fakeSecrets{
+		secrets: map[string]*corev1api.Secret{
+			"ark-key":   {Data: map[string][]byte{"key": testEncryptionKey}},
+			"short-key": {Data: map[string][]byte{"key": []byte("too short")}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		secret      *corev1api.SecretKeySelector
+		expectedErr bool
+	}{
+		{
+			name:   "key is returned",
+			secret: &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "ark-key"}, Key: "key"},
+		},
+		{
+			name:        "missing secret is an error",
+			secret:      &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "missing"}, Key: "key"},
+			expectedErr: true,
+		},
+		{
+			name:        "missing key is an error",
+			secret:      &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "ark-key"}, Key: "other"},
+			expectedErr: true,
+		},
+		{
+			name:        "key of the wrong size is an error",
+			secret:      &corev1api.SecretKeySelector{LocalObjectReference: corev1api.LocalObjectReference{Name: "short-key"}, Key: "key"},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location := &api.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: api.DefaultNamespace, Name: "default"},
+				Spec: api.BackupStorageLocationSpec{
+					Provider:            "objStoreProvider",
+					StorageType:         api.StorageType{ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket"}},
+					EncryptionKeySecret: test.secret,
+				},
+			}
+
+			key, err := NewSecretEncryptionKeyGetter(secrets).GetEncryptionKey(location)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testEncryptionKey, key)
+		})
+	}
+}
+
+func TestBackupStoreFactoryFailsWithoutKey(t *testing.T) {
+	location := &api.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: api.DefaultNamespace, Name: "default"},
+		Spec: api.BackupStorageLocationSpec{
+			Provider:    "objStoreProvider",
+			StorageType: api.StorageType{ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket"}},
+			EncryptionKeySecret: &corev1api.SecretKeySelector{
+				LocalObjectReference: corev1api.LocalObjectReference{Name: "missing"},
+				Key:                  "key",
+			},
+		},
+	}
+
+	newBackupStore := NewBackupStoreFactory(NewSecretEncryptionKeyGetter(&fakeSecrets{}))
+
+	_, err := newBackupStore(location, nil, arktest.NewLogger())
+	assert.Error(t, err)
+}
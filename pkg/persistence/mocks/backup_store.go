@@ -1,9 +1,11 @@
 // Code generated by mockery v1.0.0
 package mocks
 
+import archive "github.com/heptio/ark/pkg/archive"
 import io "io"
 import mock "github.com/stretchr/testify/mock"
 
+import persistence "github.com/heptio/ark/pkg/persistence"
 import v1 "github.com/heptio/ark/pkg/apis/ark/v1"
 
 // BackupStore is an autogenerated mock type for the BackupStore type
@@ -11,6 +13,27 @@ type BackupStore struct {
 	mock.Mock
 }
 
+// BackupExists provides a mock function with given fields: name
+func (_m *BackupStore) BackupExists(name string) (bool, error) {
+	ret := _m.Called(name)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteBackup provides a mock function with given fields: name
 func (_m *BackupStore) DeleteBackup(name string) error {
 	ret := _m.Called(name)
@@ -62,6 +85,52 @@ func (_m *BackupStore) GetBackupContents(name string) (io.ReadCloser, error) {
 	return r0, r1
 }
 
+// GetBackupContentIndex provides a mock function with given fields: name
+func (_m *BackupStore) GetBackupContentIndex(name string) (io.ReadCloser, error) {
+	ret := _m.Called(name)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(string) io.ReadCloser); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBackupManifest provides a mock function with given fields: name
+func (_m *BackupStore) GetBackupManifest(name string) ([]archive.ManifestEntry, error) {
+	ret := _m.Called(name)
+
+	var r0 []archive.ManifestEntry
+	if rf, ok := ret.Get(0).(func(string) []archive.ManifestEntry); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]archive.ManifestEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetBackupMetadata provides a mock function with given fields: name
 func (_m *BackupStore) GetBackupMetadata(name string) (*v1.Backup, error) {
 	ret := _m.Called(name)
@@ -85,6 +154,29 @@ func (_m *BackupStore) GetBackupMetadata(name string) (*v1.Backup, error) {
 	return r0, r1
 }
 
+// GetBackupMetadataSummary provides a mock function with given fields: name
+func (_m *BackupStore) GetBackupMetadataSummary(name string) (*persistence.BackupSummary, error) {
+	ret := _m.Called(name)
+
+	var r0 *persistence.BackupSummary
+	if rf, ok := ret.Get(0).(func(string) *persistence.BackupSummary); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*persistence.BackupSummary)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDownloadURL provides a mock function with given fields: target
 func (_m *BackupStore) GetDownloadURL(target v1.DownloadTarget) (string, error) {
 	ret := _m.Called(target)
@@ -120,6 +212,20 @@ func (_m *BackupStore) IsValid() error {
 	return r0
 }
 
+// Probe provides a mock function with given fields:
+func (_m *BackupStore) Probe() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetRevision provides a mock function with given fields:
 func (_m *BackupStore) GetRevision() (string, error) {
 	ret := _m.Called()
@@ -141,6 +247,29 @@ func (_m *BackupStore) GetRevision() (string, error) {
 	return r0, r1
 }
 
+// ListBackupArtifacts provides a mock function with given fields: name
+func (_m *BackupStore) ListBackupArtifacts(name string) ([]persistence.BackupArtifact, error) {
+	ret := _m.Called(name)
+
+	var r0 []persistence.BackupArtifact
+	if rf, ok := ret.Get(0).(func(string) []persistence.BackupArtifact); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]persistence.BackupArtifact)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListBackups provides a mock function with given fields:
 func (_m *BackupStore) ListBackups() ([]string, error) {
 	ret := _m.Called()
@@ -164,13 +293,118 @@ func (_m *BackupStore) ListBackups() ([]string, error) {
 	return r0, r1
 }
 
-// PutBackup provides a mock function with given fields: name, metadata, contents, log
-func (_m *BackupStore) PutBackup(name string, metadata io.Reader, contents io.Reader, log io.Reader) error {
-	ret := _m.Called(name, metadata, contents, log)
+// PutBackup provides a mock function with given fields: name, metadata, contents, contentIndex, manifest, log
+func (_m *BackupStore) PutBackup(name string, metadata io.Reader, contents io.Reader, contentIndex io.Reader, manifest io.Reader, log io.Reader) error {
+	ret := _m.Called(name, metadata, contents, contentIndex, manifest, log)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Reader, io.Reader, io.Reader, io.Reader, io.Reader) error); ok {
+		r0 = rf(name, metadata, contents, contentIndex, manifest, log)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutBackupContents provides a mock function with given fields: name, contents
+func (_m *BackupStore) PutBackupContents(name string, contents io.Reader) error {
+	ret := _m.Called(name, contents)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Reader) error); ok {
+		r0 = rf(name, contents)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutBackupChecksum provides a mock function with given fields: name
+func (_m *BackupStore) PutBackupChecksum(name string) (string, error) {
+	ret := _m.Called(name)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PutBackupCompletionMarker provides a mock function with given fields: name
+func (_m *BackupStore) PutBackupCompletionMarker(name string) error {
+	ret := _m.Called(name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutBackupContentIndex provides a mock function with given fields: name, contentIndex
+func (_m *BackupStore) PutBackupContentIndex(name string, contentIndex io.Reader) error {
+	ret := _m.Called(name, contentIndex)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Reader) error); ok {
+		r0 = rf(name, contentIndex)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutBackupLog provides a mock function with given fields: name, log
+func (_m *BackupStore) PutBackupLog(name string, log io.Reader) error {
+	ret := _m.Called(name, log)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Reader) error); ok {
+		r0 = rf(name, log)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutBackupSummary provides a mock function with given fields: name, summary
+func (_m *BackupStore) PutBackupSummary(name string, summary *persistence.BackupSummary) error {
+	ret := _m.Called(name, summary)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, *persistence.BackupSummary) error); ok {
+		r0 = rf(name, summary)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PutBackupMetadata provides a mock function with given fields: name, metadata
+func (_m *BackupStore) PutBackupMetadata(name string, metadata io.Reader) error {
+	ret := _m.Called(name, metadata)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, io.Reader, io.Reader, io.Reader) error); ok {
-		r0 = rf(name, metadata, contents, log)
+	if rf, ok := ret.Get(0).(func(string, io.Reader) error); ok {
+		r0 = rf(name, metadata)
 	} else {
 		r0 = ret.Error(0)
 	}
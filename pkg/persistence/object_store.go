@@ -17,9 +17,17 @@ limitations under the License.
 package persistence
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
 	"io"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,8 +35,10 @@ import (
 	"github.com/sirupsen/logrus"
 
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/generated/clientset/versioned/scheme"
 )
@@ -37,13 +47,26 @@ import (
 // Ark backup and restore data in/from a persistent backup store.
 type BackupStore interface {
 	IsValid() error
+	Probe() error
 	GetRevision() (string, error)
 
 	ListBackups() ([]string, error)
 
-	PutBackup(name string, metadata, contents, log io.Reader) error
+	PutBackup(name string, metadata, contents, contentIndex, manifest, log io.Reader) error
+	PutBackupContents(name string, contents io.Reader) error
+	PutBackupChecksum(name string) (string, error)
+	PutBackupContentIndex(name string, contentIndex io.Reader) error
+	PutBackupMetadata(name string, metadata io.Reader) error
+	PutBackupLog(name string, log io.Reader) error
+	PutBackupSummary(name string, summary *BackupSummary) error
+	PutBackupCompletionMarker(name string) error
 	GetBackupMetadata(name string) (*arkv1api.Backup, error)
+	GetBackupMetadataSummary(name string) (*BackupSummary, error)
 	GetBackupContents(name string) (io.ReadCloser, error)
+	GetBackupContentIndex(name string) (io.ReadCloser, error)
+	GetBackupManifest(name string) ([]archive.ManifestEntry, error)
+	ListBackupArtifacts(name string) ([]BackupArtifact, error)
+	BackupExists(name string) (bool, error)
 	DeleteBackup(name string) error
 
 	PutRestoreLog(backup, restore string, log io.Reader) error
@@ -56,20 +79,114 @@ type BackupStore interface {
 // DownloadURLTTL is how long a download URL is valid for.
 const DownloadURLTTL = 10 * time.Minute
 
+// BackupArtifact identifies one of the objects stored in a backup store
+// for a single backup.
+type BackupArtifact string
+
+const (
+	// BackupArtifactContents is the backup's gzipped tarball.
+	BackupArtifactContents BackupArtifact = "contents"
+
+	// BackupArtifactMetadata is the backup's JSON-encoded Backup API object.
+	BackupArtifactMetadata BackupArtifact = "metadata"
+
+	// BackupArtifactLog is the backup's gzipped log file.
+	BackupArtifactLog BackupArtifact = "log"
+
+	// BackupArtifactChecksum is the hex-encoded SHA-256 checksum of the
+	// backup's tarball, as stored (i.e. after it's been encrypted, for
+	// locations with an encryption key).
+	BackupArtifactChecksum BackupArtifact = "checksum"
+
+	// BackupArtifactContentIndex is the newline-delimited index of the
+	// items in the backup's tarball, written by archive.WriteContentIndex.
+	// It's only uploaded for some backups.
+	BackupArtifactContentIndex BackupArtifact = "content-index"
+
+	// BackupArtifactManifest is the manifest of the files in the backup's
+	// tarball, written by archive.ManifestWriter. It's only uploaded for
+	// backups with Spec.IntegrityManifest.
+	BackupArtifactManifest BackupArtifact = "manifest"
+
+	// BackupArtifactSummary is the JSON-encoded BackupSummary of the
+	// backup's metadata.
+	BackupArtifactSummary BackupArtifact = "summary"
+
+	// BackupArtifactCompletionMarker is an empty object that's uploaded
+	// after all of the backup's other artifacts, marking the backup as
+	// fully uploaded.
+	BackupArtifactCompletionMarker BackupArtifact = "completion-marker"
+)
+
 type objectBackupStore struct {
 	objectStore cloudprovider.ObjectStore
 	bucket      string
 	layout      *ObjectStoreLayout
 	logger      logrus.FieldLogger
+
+	// located caches the layouts that backups' and restores' objects
+	// were found in, by their directories in the store's own layout.
+	locatedLock sync.Mutex
+	located     map[string]*ObjectStoreLayout
+
+	// encrypted is whether backups' metadata and contents must be
+	// encrypted with encryptionKey before they're stored. encryptionKey
+	// may be nil even if encrypted is true, if the store wasn't created
+	// with the location's key, in which case they can't be stored.
+	encrypted     bool
+	encryptionKey []byte
+
+	// objectLockRetention is how long the objects put in the store are
+	// locked for, if the location locks them.
+	objectLockRetention time.Duration
 }
 
+// streamingProviders are the object store providers whose PutObject
+// uploads a body as it's read, without needing to know its length first.
+// Others may read the whole body into memory to find its length (azure's
+// does), so backups aren't streamed to them.
+var streamingProviders = sets.NewString("aws", "gcp")
+
+// SupportsStreamingUploads returns whether backups' tarballs can be
+// streamed to location with PutBackupContents while they're written,
+// rather than staged in full first.
+func SupportsStreamingUploads(location *arkv1api.BackupStorageLocation) bool {
+	return streamingProviders.Has(location.Spec.Provider)
+}
+
+// The metadata that backups' and restores' objects are stored with, so that
+// object store browsers and CDNs serve them with the right content types.
+// Gzipped objects are stored as application/gzip, rather than with a gzip
+// Content-Encoding, so that they're downloaded as they were uploaded instead
+// of being decompressed by stores and clients that honor the encoding.
+var (
+	jsonObjectMetadata      = cloudprovider.ObjectMetadata{ContentType: "application/json"}
+	gzipObjectMetadata      = cloudprovider.ObjectMetadata{ContentType: "application/gzip"}
+	zstdObjectMetadata      = cloudprovider.ObjectMetadata{ContentType: "application/zstd"}
+	encryptedObjectMetadata = cloudprovider.ObjectMetadata{ContentType: "application/octet-stream"}
+)
+
+// zstdMagic is the magic number that zstd-compressed tarballs start with,
+// and gzipMagic the one that gzipped metadata does.
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
 // ObjectStoreGetter is a type that can get a cloudprovider.ObjectStore
 // from a provider name.
 type ObjectStoreGetter interface {
 	GetObjectStore(provider string) (cloudprovider.ObjectStore, error)
 }
 
+// NewObjectBackupStore returns a BackupStore for location. It doesn't get
+// the location's encryption key, if it has one, so backups can't be put in
+// or read from encrypted locations; use NewBackupStoreFactory for that.
 func NewObjectBackupStore(location *arkv1api.BackupStorageLocation, objectStoreGetter ObjectStoreGetter, logger logrus.FieldLogger) (BackupStore, error) {
+	return newObjectBackupStore(location, objectStoreGetter, logger, nil)
+}
+
+func newObjectBackupStore(location *arkv1api.BackupStorageLocation, objectStoreGetter ObjectStoreGetter, logger logrus.FieldLogger, encryptionKey []byte) (BackupStore, error) {
 	if location.Spec.ObjectStorage == nil {
 		return nil, errors.New("backup storage location does not use object storage")
 	}
@@ -83,6 +200,10 @@ func NewObjectBackupStore(location *arkv1api.BackupStorageLocation, objectStoreG
 		return nil, err
 	}
 
+	// the location's config is added to below, and the location may be
+	// shared, e.g. by an informer's cache, so work on a copy of it.
+	location = location.DeepCopy()
+
 	// add the bucket name to the config map so that object stores can use
 	// it when initializing. The AWS object store uses this to determine the
 	// bucket's region when setting up its client.
@@ -93,6 +214,29 @@ func NewObjectBackupStore(location *arkv1api.BackupStorageLocation, objectStoreG
 		location.Spec.Config["bucket"] = location.Spec.ObjectStorage.Bucket
 	}
 
+	// add the server-side encryption options to the config map so that
+	// object stores that support them can use them when putting objects.
+	// Object stores that don't support them ignore these keys.
+	if sse := location.Spec.ServerSideEncryption; sse != nil {
+		if sse.Algorithm != "" {
+			location.Spec.Config[cloudprovider.ServerSideEncryptionConfigKey] = sse.Algorithm
+		}
+		if sse.KMSKeyID != "" {
+			location.Spec.Config[cloudprovider.KMSKeyIDConfigKey] = sse.KMSKeyID
+		}
+	}
+
+	// add the object lock retention to the config map so that object
+	// stores that support object locks lock the objects they put.
+	if retention := location.Spec.ObjectLockRetention.Duration; retention > 0 {
+		location.Spec.Config[cloudprovider.ObjectLockRetentionConfigKey] = retention.String()
+	}
+
+	keys, err := GetKeyLayout(location.Spec.ObjectStorage.KeyLayout)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := objectStore.Init(location.Spec.Config); err != nil {
 		return nil, err
 	}
@@ -105,8 +249,13 @@ func NewObjectBackupStore(location *arkv1api.BackupStorageLocation, objectStoreG
 	return &objectBackupStore{
 		objectStore: objectStore,
 		bucket:      location.Spec.ObjectStorage.Bucket,
-		layout:      NewObjectStoreLayout(location.Spec.ObjectStorage.Prefix),
+		layout:      NewObjectStoreLayoutWithKeys(location.Spec.ObjectStorage.Prefix, keys),
 		logger:      log,
+
+		encrypted:     location.Spec.EncryptionKeySecret != nil,
+		encryptionKey: encryptionKey,
+
+		objectLockRetention: location.Spec.ObjectLockRetention.Duration,
 	}, nil
 }
 
@@ -135,8 +284,43 @@ func (s *objectBackupStore) IsValid() error {
 	return nil
 }
 
+// Probe checks that the store can be written to and read from by putting,
+// getting, and deleting a small sentinel object.
+func (s *objectBackupStore) Probe() error {
+	id := uuid.NewV4().String()
+	key := s.layout.getProbeKey(id)
+
+	if err := s.objectStore.PutObject(s.bucket, key, strings.NewReader(id)); err != nil {
+		return errors.Wrap(err, "error writing probe object")
+	}
+
+	res, err := s.objectStore.GetObject(s.bucket, key)
+	if err != nil {
+		return errors.Wrap(err, "error reading probe object")
+	}
+	data, err := ioutil.ReadAll(res)
+	res.Close()
+	if err != nil {
+		return errors.Wrap(err, "error reading probe object")
+	}
+	if string(data) != id {
+		return errors.New("probe object read back with unexpected contents")
+	}
+
+	return errors.Wrap(s.objectStore.DeleteObject(s.bucket, key), "error deleting probe object")
+}
+
+// ListBackups returns the names of the backups in the store, in any of the
+// key layouts, so that backups stored before the location's key layout
+// was changed are still listed.
 func (s *objectBackupStore) ListBackups() ([]string, error) {
-	prefixes, err := s.objectStore.ListCommonPrefixes(s.bucket, s.layout.subdirs["backups"], "/")
+	return s.listNames(s.layout.subdirs["backups"])
+}
+
+// listNames returns the names of the backups or restores whose
+// directories are in dir, or in the partitions in dir.
+func (s *objectBackupStore) listNames(dir string) ([]string, error) {
+	prefixes, err := s.objectStore.ListCommonPrefixes(s.bucket, dir, "/")
 	if err != nil {
 		return nil, err
 	}
@@ -149,18 +333,101 @@ func (s *objectBackupStore) ListBackups() ([]string, error) {
 	for _, prefix := range prefixes {
 		// values returned from a call to cloudprovider.ObjectStore's
 		// ListCommonPrefixes method return the *full* prefix, inclusive
-		// of s.backupsPrefix, and include the delimiter ("/") as a suffix. Trim
+		// of dir, and include the delimiter ("/") as a suffix. Trim
 		// each of those off to get the backup name.
-		backupName := strings.TrimSuffix(strings.TrimPrefix(prefix, s.layout.subdirs["backups"]), "/")
+		subdir := strings.TrimPrefix(prefix, dir)
+		if !isPartition(subdir) {
+			output = append(output, strings.TrimSuffix(subdir, "/"))
+			continue
+		}
 
-		output = append(output, backupName)
+		names, err := s.listNames(prefix)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, names...)
 	}
 
 	return output, nil
 }
 
-func (s *objectBackupStore) PutBackup(name string, metadata io.Reader, contents io.Reader, log io.Reader) error {
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getBackupLogKey(name), log); err != nil {
+// isPartition returns whether any key layout partitions backups or
+// restores into dir.
+func isPartition(dir string) bool {
+	for _, keys := range keyLayouts {
+		if keys.IsPartition(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// backupLayout returns the layout that the named backup is stored in.
+func (s *objectBackupStore) backupLayout(name string) *ObjectStoreLayout {
+	return s.locate(name, (*ObjectStoreLayout).getBackupDir)
+}
+
+// restoreLayout returns the layout that the named restore is stored in.
+func (s *objectBackupStore) restoreLayout(name string) *ObjectStoreLayout {
+	return s.locate(name, (*ObjectStoreLayout).getRestoreDir)
+}
+
+// locate returns the layout that the named backup or restore, whose
+// directory in a layout is returned by dir, is stored in: the store's own,
+// unless its objects are only where another key layout puts them, e.g.
+// because it was stored before the location's key layout was changed.
+// Backups and restores that aren't stored yet use the store's own layout.
+func (s *objectBackupStore) locate(name string, dir func(*ObjectStoreLayout, string) string) *ObjectStoreLayout {
+	ownDir := dir(s.layout, name)
+
+	s.locatedLock.Lock()
+	located, ok := s.located[ownDir]
+	s.locatedLock.Unlock()
+	if ok {
+		return located
+	}
+
+	candidates := []*ObjectStoreLayout{s.layout}
+	seen := map[string]bool{ownDir: true}
+	for _, keyLayoutName := range KeyLayoutNames() {
+		layout := s.layout.withKeys(keyLayouts[keyLayoutName])
+		if !seen[dir(layout, name)] {
+			seen[dir(layout, name)] = true
+			candidates = append(candidates, layout)
+		}
+	}
+	// every layout stores it in the same place, so there's nothing to
+	// look for.
+	if len(candidates) == 1 {
+		return s.layout
+	}
+
+	for _, layout := range candidates {
+		objects, err := s.objectStore.ListObjects(s.bucket, dir(layout, name))
+		if err != nil {
+			s.logger.WithError(err).WithField("name", name).Warn("Error looking for objects in the store's key layouts, using its own")
+			return s.layout
+		}
+		if len(objects) == 0 {
+			continue
+		}
+
+		s.locatedLock.Lock()
+		if s.located == nil {
+			s.located = make(map[string]*ObjectStoreLayout)
+		}
+		s.located[ownDir] = layout
+		s.locatedLock.Unlock()
+
+		return layout
+	}
+
+	return s.layout
+}
+
+func (s *objectBackupStore) PutBackup(name string, metadata, contents, contentIndex, manifest, log io.Reader) error {
+	layout := s.backupLayout(name)
+	if err := seekAndPutObject(s.objectStore, s.bucket, layout.getBackupLogKey(name), log, gzipObjectMetadata); err != nil {
 		// Uploading the log file is best-effort; if it fails, we log the error but it doesn't impact the
 		// backup's status.
 		s.logger.WithError(err).WithField("backup", name).Error("Error uploading log file")
@@ -173,16 +440,181 @@ func (s *objectBackupStore) PutBackup(name string, metadata io.Reader, contents
 		return nil
 	}
 
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getBackupMetadataKey(name), metadata); err != nil {
+	metadataKey, metadataObjectMetadata, metadata, err := s.metadataKeyAndObjectMetadata(name, metadata)
+	if err != nil {
+		return err
+	}
+	metadata, err = s.encrypt(metadata)
+	if err != nil {
+		return err
+	}
+
+	contentsObjectMetadata, contents, err := s.contentsObjectMetadata(contents)
+	if err != nil {
+		return err
+	}
+	contents, err = s.encrypt(contents)
+	if err != nil {
+		return err
+	}
+
+	// the content index and manifest list every item in the tarball, so
+	// they're encrypted along with it.
+	contentIndex, err = s.encrypt(contentIndex)
+	if err != nil {
+		return err
+	}
+	manifest, err = s.encrypt(manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := seekAndPutObject(s.objectStore, s.bucket, metadataKey, metadata, metadataObjectMetadata); err != nil {
 		// failure to upload metadata file is a hard-stop
 		return err
 	}
 
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getBackupContentsKey(name), contents); err != nil {
-		deleteErr := s.objectStore.DeleteObject(s.bucket, s.layout.getBackupMetadataKey(name))
+	// nil contents have already been uploaded with PutBackupContents.
+	if contents != nil {
+		checksum := sha256.New()
+		if err := seekAndPutObject(s.objectStore, s.bucket, layout.getBackupContentsKey(name), teeReader(contents, checksum), contentsObjectMetadata); err != nil {
+			deleteErr := s.objectStore.DeleteObject(s.bucket, metadataKey)
+			return kerrors.NewAggregate([]error{err, deleteErr})
+		}
+
+		if err := s.putBackupChecksum(name, checksum); err != nil {
+			return err
+		}
+	}
+
+	if err := seekAndPutObject(s.objectStore, s.bucket, layout.getBackupContentIndexKey(name), contentIndex, s.sidecarObjectMetadata()); err != nil {
+		// Like the log file, the content index is best-effort; it can be
+		// regenerated from the tarball by RepairBackup.
+		s.logger.WithError(err).WithField("backup", name).Error("Error uploading content index")
+	}
+
+	if err := seekAndPutObject(s.objectStore, s.bucket, layout.getBackupManifestKey(name), manifest, s.sidecarObjectMetadata()); err != nil {
+		// The manifest is also best-effort, since the tarball can be
+		// restored without it.
+		s.logger.WithError(err).WithField("backup", name).Error("Error uploading manifest")
+	}
+
+	// The completion marker must be uploaded last: until it exists, the
+	// backup is treated as incomplete (see IsBackupComplete).
+	if err := s.PutBackupCompletionMarker(name); err != nil {
+		return err
+	}
+
+	if err := s.putRevision(); err != nil {
+		s.logger.WithField("backup", name).WithError(err).Warn("Error updating backup store revision")
+	}
+
+	return nil
+}
+
+// PutBackupContents uploads the backup's tarball, and its checksum, as
+// it's read from contents, so that it can be streamed to the store while
+// it's being written. contents needn't be seekable, so a failed upload
+// can't be retried, and whatever of the tarball was stored is deleted. The
+// rest of the backup is uploaded afterwards by calling PutBackup with nil
+// contents.
+func (s *objectBackupStore) PutBackupContents(name string, contents io.Reader) error {
+	objectMetadata, contents, err := s.contentsObjectMetadata(contents)
+	if err != nil {
+		return err
+	}
+	contents, err = s.encrypt(contents)
+	if err != nil {
+		return err
+	}
+
+	layout := s.backupLayout(name)
+	checksum := sha256.New()
+	if err := cloudprovider.PutObjectWithMetadata(s.objectStore, s.bucket, layout.getBackupContentsKey(name), io.TeeReader(contents, checksum), objectMetadata); err != nil {
+		deleteErr := s.objectStore.DeleteObject(s.bucket, layout.getBackupContentsKey(name))
 		return kerrors.NewAggregate([]error{err, deleteErr})
 	}
 
+	return s.putBackupChecksum(name, checksum)
+}
+
+// PutBackupChecksum computes the checksum of the backup's tarball, as it's
+// stored, uploads it, and returns it. It's for backups whose checksum was
+// lost, since the checksum is otherwise computed as the tarball's uploaded.
+func (s *objectBackupStore) PutBackupChecksum(name string) (string, error) {
+	res, err := s.objectStore.GetObject(s.bucket, s.backupLayout(name).getBackupContentsKey(name))
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	checksum := sha256.New()
+	if _, err := io.Copy(checksum, res); err != nil {
+		return "", errors.Wrap(err, "error reading backup contents")
+	}
+
+	if err := s.putBackupChecksum(name, checksum); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(checksum.Sum(nil)), nil
+}
+
+func (s *objectBackupStore) putBackupChecksum(name string, checksum hash.Hash) error {
+	if err := s.objectStore.PutObject(s.bucket, s.backupLayout(name).getBackupChecksumKey(name), strings.NewReader(hex.EncodeToString(checksum.Sum(nil)))); err != nil {
+		return errors.Wrap(err, "error uploading backup checksum")
+	}
+	return nil
+}
+
+// PutBackupCompletionMarker uploads the backup's completion marker, which
+// marks all of its other artifacts as uploaded.
+func (s *objectBackupStore) PutBackupCompletionMarker(name string) error {
+	if err := s.objectStore.PutObject(s.bucket, s.backupLayout(name).getBackupCompletionMarkerKey(name), strings.NewReader("")); err != nil {
+		return errors.Wrap(err, "error uploading backup completion marker")
+	}
+	return nil
+}
+
+// PutBackupLog uploads a backup's gzipped log on its own, so that it can be
+// kept even if the rest of the backup can't be uploaded.
+func (s *objectBackupStore) PutBackupLog(name string, log io.Reader) error {
+	return seekAndPutObject(s.objectStore, s.bucket, s.backupLayout(name).getBackupLogKey(name), log, gzipObjectMetadata)
+}
+
+// PutBackupSummary uploads the summary of a backup's metadata, which is
+// read by GetBackupMetadataSummary.
+func (s *objectBackupStore) PutBackupSummary(name string, summary *BackupSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	objectMetadata := jsonObjectMetadata
+	if s.encrypted {
+		objectMetadata = encryptedObjectMetadata
+	}
+	body, err := s.encrypt(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	return seekAndPutObject(s.objectStore, s.bucket, s.backupLayout(name).getBackupSummaryKey(name), body, objectMetadata)
+}
+
+func (s *objectBackupStore) PutBackupMetadata(name string, metadata io.Reader) error {
+	key, objectMetadata, metadata, err := s.metadataKeyAndObjectMetadata(name, metadata)
+	if err != nil {
+		return err
+	}
+	metadata, err = s.encrypt(metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := seekAndPutObject(s.objectStore, s.bucket, key, metadata, objectMetadata); err != nil {
+		return err
+	}
+
 	if err := s.putRevision(); err != nil {
 		s.logger.WithField("backup", name).WithError(err).Warn("Error updating backup store revision")
 	}
@@ -190,16 +622,50 @@ func (s *objectBackupStore) PutBackup(name string, metadata io.Reader, contents
 	return nil
 }
 
+func (s *objectBackupStore) PutBackupContentIndex(name string, contentIndex io.Reader) error {
+	contentIndex, err := s.encrypt(contentIndex)
+	if err != nil {
+		return err
+	}
+
+	return seekAndPutObject(s.objectStore, s.bucket, s.backupLayout(name).getBackupContentIndexKey(name), contentIndex, s.sidecarObjectMetadata())
+}
+
+// GetBackupMetadata returns the named backup's metadata, which is read
+// from its gzipped metadata object if it doesn't have an uncompressed one.
 func (s *objectBackupStore) GetBackupMetadata(name string) (*arkv1api.Backup, error) {
-	key := s.layout.getBackupMetadataKey(name)
+	layout := s.backupLayout(name)
+	key := layout.getBackupMetadataKey(name)
 
 	res, err := s.objectStore.GetObject(s.bucket, key)
 	if err != nil {
-		return nil, err
+		// object stores' errors for missing objects can't be told apart
+		// from others, so the uncompressed object's error is the one
+		// returned if there's no gzipped one either.
+		compressedKey := layout.getBackupCompressedMetadataKey(name)
+		compressedRes, compressedErr := s.objectStore.GetObject(s.bucket, compressedKey)
+		if compressedErr != nil {
+			return nil, err
+		}
+		key, res = compressedKey, compressedRes
 	}
 	defer res.Close()
 
-	data, err := ioutil.ReadAll(res)
+	decrypted, err := newDecryptingReader(res, s.encryptionKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading backup metadata")
+	}
+
+	if key == layout.getBackupCompressedMetadataKey(name) {
+		gzr, err := gzip.NewReader(decrypted)
+		if err != nil {
+			return nil, errors.Wrap(err, "error decompressing backup metadata")
+		}
+		defer gzr.Close()
+		decrypted = gzr
+	}
+
+	data, err := ioutil.ReadAll(decrypted)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -219,12 +685,346 @@ func (s *objectBackupStore) GetBackupMetadata(name string) (*arkv1api.Backup, er
 
 }
 
+// GetBackupMetadataSummary returns the summary of the named backup's
+// metadata, which is read from its small summary object rather than its
+// full metadata. Backups uploaded without a summary have theirs read from
+// their full metadata instead.
+func (s *objectBackupStore) GetBackupMetadataSummary(name string) (*BackupSummary, error) {
+	res, err := s.objectStore.GetObject(s.bucket, s.backupLayout(name).getBackupSummaryKey(name))
+	if err != nil {
+		// as with GetBackupMetadata, a missing object can't be told apart
+		// from other errors, so the full metadata's error is returned if
+		// it can't be read either.
+		backup, metadataErr := s.GetBackupMetadata(name)
+		if metadataErr != nil {
+			return nil, metadataErr
+		}
+		return NewBackupSummary(backup), nil
+	}
+	defer res.Close()
+
+	decrypted, err := newDecryptingReader(res, s.encryptionKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading backup summary")
+	}
+
+	summary := new(BackupSummary)
+	if err := json.NewDecoder(decrypted).Decode(summary); err != nil {
+		return nil, errors.Wrap(err, "error decoding backup summary")
+	}
+
+	return summary, nil
+}
+
+// GetBackupContents returns a reader of the backup's tarball. If the
+// backup has a checksum, reading the tarball to its end fails if the
+// stored bytes don't match it. Backups uploaded without a checksum are
+// read without being verified.
 func (s *objectBackupStore) GetBackupContents(name string) (io.ReadCloser, error) {
-	return s.objectStore.GetObject(s.bucket, s.layout.getBackupContentsKey(name))
+	checksum, err := s.getBackupChecksum(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.objectStore.GetObject(s.bucket, s.backupLayout(name).getBackupContentsKey(name))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		raw      io.Reader = res
+		verifier *checksumVerifier
+	)
+	if checksum != "" {
+		verifier = newChecksumVerifier(res, checksum)
+		raw = verifier
+	}
+
+	decrypted, err := newDecryptingReader(raw, s.encryptionKey)
+	if err != nil {
+		res.Close()
+		return nil, errors.WithMessage(err, "error reading backup contents")
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{&verifiedReader{r: decrypted, verifier: verifier}, res}, nil
+}
+
+// GetBackupContentIndex returns a reader of the content index uploaded
+// alongside the backup's tarball, decrypted if it's encrypted.
+func (s *objectBackupStore) GetBackupContentIndex(name string) (io.ReadCloser, error) {
+	res, err := s.objectStore.GetObject(s.bucket, s.backupLayout(name).getBackupContentIndexKey(name))
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := newDecryptingReader(res, s.encryptionKey)
+	if err != nil {
+		res.Close()
+		return nil, errors.WithMessage(err, "error reading backup content index")
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{decrypted, res}, nil
+}
+
+// GetBackupManifest returns the entries of the manifest uploaded alongside
+// the backup's tarball.
+func (s *objectBackupStore) GetBackupManifest(name string) ([]archive.ManifestEntry, error) {
+	res, err := s.objectStore.GetObject(s.bucket, s.backupLayout(name).getBackupManifestKey(name))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	decrypted, err := newDecryptingReader(res, s.encryptionKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading backup manifest")
+	}
+
+	return archive.ReadManifest(decrypted)
+}
+
+// getBackupChecksum returns the checksum uploaded alongside the backup's
+// tarball, or an empty string if there isn't one.
+func (s *objectBackupStore) getBackupChecksum(name string) (string, error) {
+	artifacts, err := s.ListBackupArtifacts(name)
+	if err != nil {
+		return "", err
+	}
+
+	found := false
+	for _, artifact := range artifacts {
+		if artifact == BackupArtifactChecksum {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", nil
+	}
+
+	res, err := s.objectStore.GetObject(s.bucket, s.backupLayout(name).getBackupChecksumKey(name))
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	checksum, err := ioutil.ReadAll(res)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading backup checksum")
+	}
+
+	return strings.TrimSpace(string(checksum)), nil
+}
+
+// checksumVerifier hashes the bytes read through it, so that they can be
+// compared to an expected SHA-256 checksum once they've all been read.
+type checksumVerifier struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func newChecksumVerifier(r io.Reader, expected string) *checksumVerifier {
+	h := sha256.New()
+	return &checksumVerifier{r: io.TeeReader(r, h), hash: h, expected: expected}
+}
+
+func (v *checksumVerifier) Read(p []byte) (int, error) {
+	return v.r.Read(p)
+}
+
+// verify reads and hashes anything that's left, which the decrypting reader
+// may not have read, and compares the result to the expected checksum.
+func (v *checksumVerifier) verify() error {
+	if _, err := io.Copy(ioutil.Discard, v.r); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected {
+		return errors.Errorf("backup contents are corrupt: their checksum is %s, but %s was expected", actual, v.expected)
+	}
+
+	return nil
+}
+
+// verifiedReader reads from r, and verifies the checksum, if there is one,
+// when r has been read to its end.
+type verifiedReader struct {
+	r        io.Reader
+	verifier *checksumVerifier
+}
+
+func (r *verifiedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF && r.verifier != nil {
+		if verifyErr := r.verifier.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+// encrypt returns a reader of the encrypted form of r if the store's
+// backups must be encrypted, or r otherwise. r is seeked to its beginning
+// first, since the returned reader can't be.
+func (s *objectBackupStore) encrypt(r io.Reader) (io.Reader, error) {
+	if !s.encrypted || r == nil {
+		return r, nil
+	}
+
+	if s.encryptionKey == nil {
+		return nil, errors.New("backup storage location requires encryption, but its encryption key is not available")
+	}
+
+	if err := seekToBeginning(r); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return newEncryptingReader(r, s.encryptionKey)
+}
+
+// sidecarObjectMetadata returns the object metadata to store the files
+// uploaded alongside a backup's tarball that describe its contents, such
+// as its manifest, with.
+func (s *objectBackupStore) sidecarObjectMetadata() cloudprovider.ObjectMetadata {
+	if s.encrypted {
+		return encryptedObjectMetadata
+	}
+	return cloudprovider.ObjectMetadata{}
+}
+
+// metadataKeyAndObjectMetadata returns the key to store the named backup's
+// metadata, read from metadata, under, the object metadata to store it
+// with, and a reader of the whole of it to use in place of metadata.
+// Metadata that starts with gzip's magic number is stored under the
+// backup's compressed metadata key.
+func (s *objectBackupStore) metadataKeyAndObjectMetadata(name string, metadata io.Reader) (string, cloudprovider.ObjectMetadata, io.Reader, error) {
+	magic, metadata, err := peekMagic(metadata, len(gzipMagic))
+	if err != nil {
+		return "", cloudprovider.ObjectMetadata{}, nil, err
+	}
+
+	layout := s.backupLayout(name)
+	key, objectMetadata := layout.getBackupMetadataKey(name), jsonObjectMetadata
+	if bytes.Equal(magic, gzipMagic) {
+		key, objectMetadata = layout.getBackupCompressedMetadataKey(name), gzipObjectMetadata
+	}
+	if s.encrypted {
+		objectMetadata = encryptedObjectMetadata
+	}
+
+	return key, objectMetadata, metadata, nil
+}
+
+// contentsObjectMetadata returns the object metadata to store a backup's
+// tarball, read from contents, with, and a reader of the whole tarball to
+// use in place of contents. Unencrypted tarballs are gzipped unless they
+// start with zstd's magic number.
+func (s *objectBackupStore) contentsObjectMetadata(contents io.Reader) (cloudprovider.ObjectMetadata, io.Reader, error) {
+	if contents == nil {
+		return cloudprovider.ObjectMetadata{}, nil, nil
+	}
+	if s.encrypted {
+		return encryptedObjectMetadata, contents, nil
+	}
+
+	magic, contents, err := peekMagic(contents, len(zstdMagic))
+	if err != nil {
+		return cloudprovider.ObjectMetadata{}, nil, err
+	}
+
+	if bytes.Equal(magic, zstdMagic) {
+		return zstdObjectMetadata, contents, nil
+	}
+	return gzipObjectMetadata, contents, nil
+}
+
+// peekMagic returns up to the first n bytes of r, for telling how it's
+// compressed, and a reader of the whole of r to use in place of it.
+func peekMagic(r io.Reader, n int) ([]byte, io.Reader, error) {
+	if r == nil {
+		return nil, nil, nil
+	}
+	if _, ok := r.(io.Seeker); ok {
+		// seekable readers are read from and then seeked back to their
+		// beginning, so that they stay seekable.
+		if err := seekToBeginning(r); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		magic := make([]byte, n)
+		read, err := io.ReadFull(r, magic)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, nil, errors.WithStack(err)
+		}
+		if err := seekToBeginning(r); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return magic[:read], r, nil
+	}
+
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(n)
+	return magic, buffered, nil
+}
+
+// ListBackupArtifacts returns the artifacts that exist in the backup store
+// for the named backup.
+func (s *objectBackupStore) ListBackupArtifacts(name string) ([]BackupArtifact, error) {
+	layout := s.backupLayout(name)
+	objects, err := s.objectStore.ListObjects(s.bucket, layout.getBackupDir(name))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(objects))
+	for _, key := range objects {
+		keys[key] = true
+	}
+
+	var artifacts []BackupArtifact
+	for _, artifact := range []BackupArtifact{BackupArtifactContents, BackupArtifactMetadata, BackupArtifactLog, BackupArtifactChecksum, BackupArtifactContentIndex, BackupArtifactManifest, BackupArtifactSummary, BackupArtifactCompletionMarker} {
+		if keys[layout.getBackupArtifactKey(name, artifact)] ||
+			(artifact == BackupArtifactMetadata && keys[layout.getBackupCompressedMetadataKey(name)]) {
+			artifacts = append(artifacts, artifact)
+		}
+	}
+
+	return artifacts, nil
+}
+
+// BackupExists returns whether all of the named backup's objects are in
+// the store. A backup whose upload was interrupted before its completion
+// marker was uploaded is treated as if it doesn't exist, since it may be
+// missing any of the others.
+func (s *objectBackupStore) BackupExists(name string) (bool, error) {
+	artifacts, err := s.ListBackupArtifacts(name)
+	if err != nil {
+		return false, err
+	}
+
+	var metadata, contents, completionMarker bool
+	for _, artifact := range artifacts {
+		switch artifact {
+		case BackupArtifactMetadata:
+			metadata = true
+		case BackupArtifactContents:
+			contents = true
+		case BackupArtifactCompletionMarker:
+			completionMarker = true
+		}
+	}
+
+	return metadata && contents && completionMarker, nil
 }
 
 func (s *objectBackupStore) DeleteBackup(name string) error {
-	objects, err := s.objectStore.ListObjects(s.bucket, s.layout.getBackupDir(name))
+	objects, err := s.objectStore.ListObjects(s.bucket, s.backupLayout(name).getBackupDir(name))
 	if err != nil {
 		return err
 	}
@@ -235,7 +1035,7 @@ func (s *objectBackupStore) DeleteBackup(name string) error {
 			"key": key,
 		}).Debug("Trying to delete object")
 		if err := s.objectStore.DeleteObject(s.bucket, key); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, s.deleteObjectError(key, err))
 		}
 	}
 
@@ -246,8 +1046,18 @@ func (s *objectBackupStore) DeleteBackup(name string) error {
 	return errors.WithStack(kerrors.NewAggregate(errs))
 }
 
+// deleteObjectError returns the error to report for failing to delete key.
+// If the store locks its objects, the object's lock is the likely reason,
+// so the error says so.
+func (s *objectBackupStore) deleteObjectError(key string, err error) error {
+	if s.objectLockRetention <= 0 {
+		return err
+	}
+	return errors.Wrapf(err, "error deleting %s, which may still be locked by its storage location's object lock retention of %v", key, s.objectLockRetention)
+}
+
 func (s *objectBackupStore) DeleteRestore(name string) error {
-	objects, err := s.objectStore.ListObjects(s.bucket, s.layout.getRestoreDir(name))
+	objects, err := s.objectStore.ListObjects(s.bucket, s.restoreLayout(name).getRestoreDir(name))
 	if err != nil {
 		return err
 	}
@@ -258,7 +1068,7 @@ func (s *objectBackupStore) DeleteRestore(name string) error {
 			"key": key,
 		}).Debug("Trying to delete object")
 		if err := s.objectStore.DeleteObject(s.bucket, key); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, s.deleteObjectError(key, err))
 		}
 	}
 
@@ -270,23 +1080,23 @@ func (s *objectBackupStore) DeleteRestore(name string) error {
 }
 
 func (s *objectBackupStore) PutRestoreLog(backup string, restore string, log io.Reader) error {
-	return s.objectStore.PutObject(s.bucket, s.layout.getRestoreLogKey(restore), log)
+	return cloudprovider.PutObjectWithMetadata(s.objectStore, s.bucket, s.restoreLayout(restore).getRestoreLogKey(restore), log, gzipObjectMetadata)
 }
 
 func (s *objectBackupStore) PutRestoreResults(backup string, restore string, results io.Reader) error {
-	return s.objectStore.PutObject(s.bucket, s.layout.getRestoreResultsKey(restore), results)
+	return cloudprovider.PutObjectWithMetadata(s.objectStore, s.bucket, s.restoreLayout(restore).getRestoreResultsKey(restore), results, gzipObjectMetadata)
 }
 
 func (s *objectBackupStore) GetDownloadURL(target arkv1api.DownloadTarget) (string, error) {
 	switch target.Kind {
 	case arkv1api.DownloadTargetKindBackupContents:
-		return s.objectStore.CreateSignedURL(s.bucket, s.layout.getBackupContentsKey(target.Name), DownloadURLTTL)
+		return s.objectStore.CreateSignedURL(s.bucket, s.backupLayout(target.Name).getBackupContentsKey(target.Name), DownloadURLTTL)
 	case arkv1api.DownloadTargetKindBackupLog:
-		return s.objectStore.CreateSignedURL(s.bucket, s.layout.getBackupLogKey(target.Name), DownloadURLTTL)
+		return s.objectStore.CreateSignedURL(s.bucket, s.backupLayout(target.Name).getBackupLogKey(target.Name), DownloadURLTTL)
 	case arkv1api.DownloadTargetKindRestoreLog:
-		return s.objectStore.CreateSignedURL(s.bucket, s.layout.getRestoreLogKey(target.Name), DownloadURLTTL)
+		return s.objectStore.CreateSignedURL(s.bucket, s.restoreLayout(target.Name).getRestoreLogKey(target.Name), DownloadURLTTL)
 	case arkv1api.DownloadTargetKindRestoreResults:
-		return s.objectStore.CreateSignedURL(s.bucket, s.layout.getRestoreResultsKey(target.Name), DownloadURLTTL)
+		return s.objectStore.CreateSignedURL(s.bucket, s.restoreLayout(target.Name).getRestoreResultsKey(target.Name), DownloadURLTTL)
 	default:
 		return "", errors.Errorf("unsupported download target kind %q", target.Kind)
 	}
@@ -309,7 +1119,7 @@ func (s *objectBackupStore) GetRevision() (string, error) {
 func (s *objectBackupStore) putRevision() error {
 	rdr := strings.NewReader(uuid.NewV4().String())
 
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getRevisionKey(), rdr); err != nil {
+	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getRevisionKey(), rdr, cloudprovider.ObjectMetadata{}); err != nil {
 		return errors.Wrap(err, "error updating revision file")
 	}
 
@@ -326,7 +1136,25 @@ func seekToBeginning(r io.Reader) error {
 	return err
 }
 
-func seekAndPutObject(objectStore cloudprovider.ObjectStore, bucket, key string, file io.Reader) error {
+// teeReader is like io.TeeReader, except that the returned reader is also
+// an io.Seeker if r is, so that seekAndPutObject seeks r back to its
+// beginning before anything is read from it.
+func teeReader(r io.Reader, w io.Writer) io.Reader {
+	if r == nil {
+		return nil
+	}
+
+	tee := io.TeeReader(r, w)
+	if seeker, ok := r.(io.Seeker); ok {
+		return struct {
+			io.Reader
+			io.Seeker
+		}{tee, seeker}
+	}
+	return tee
+}
+
+func seekAndPutObject(objectStore cloudprovider.ObjectStore, bucket, key string, file io.Reader, metadata cloudprovider.ObjectMetadata) error {
 	if file == nil {
 		return nil
 	}
@@ -335,5 +1163,5 @@ func seekAndPutObject(objectStore cloudprovider.ObjectStore, bucket, key string,
 		return errors.WithStack(err)
 	}
 
-	return objectStore.PutObject(bucket, key, file)
+	return cloudprovider.PutObjectWithMetadata(objectStore, bucket, key, file, metadata)
 }
@@ -19,17 +19,127 @@ package persistence
 import (
 	"fmt"
 	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KeyLayout is a strategy for how the objects of each backup and restore
+// are arranged within a backup store's backups or restores directory.
+type KeyLayout interface {
+	// Dir returns the directory, relative to the backups or restores
+	// directory and ending in "/", that the objects of the backup or
+	// restore named name are stored in.
+	Dir(name string) string
+
+	// IsPartition returns whether dir, a directory directly within the
+	// backups or restores directory, holds other backups' or restores'
+	// directories rather than being one's own.
+	IsPartition(dir string) bool
+}
+
+const (
+	// FlatKeyLayout is the name of the default key layout, which stores
+	// each backup's or restore's objects in a directory of its own name,
+	// e.g. backups/<name>/.
+	FlatKeyLayout = "flat"
+
+	// DateKeyLayout is the name of the key layout that partitions backups
+	// and restores by the date in their names, e.g.
+	// backups/date=2018-07-25/<name>-20180725091500/, so that object
+	// storage lifecycle policies can match them by date. Backups and
+	// restores whose names don't end in a timestamp aren't partitioned.
+	DateKeyLayout = "date"
 )
 
+var keyLayouts = map[string]KeyLayout{
+	FlatKeyLayout: flatKeyLayout{},
+	DateKeyLayout: dateKeyLayout{},
+}
+
+// GetKeyLayout returns the key layout named name, or the flat layout if
+// name is empty.
+func GetKeyLayout(name string) (KeyLayout, error) {
+	if name == "" {
+		name = FlatKeyLayout
+	}
+
+	keys, ok := keyLayouts[name]
+	if !ok {
+		return nil, errors.Errorf("unknown object key layout %q, must be one of %s", name, strings.Join(KeyLayoutNames(), ", "))
+	}
+	return keys, nil
+}
+
+// KeyLayoutNames returns the names of the key layouts, sorted.
+func KeyLayoutNames() []string {
+	names := make([]string, 0, len(keyLayouts))
+	for name := range keyLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type flatKeyLayout struct{}
+
+func (flatKeyLayout) Dir(name string) string {
+	return name + "/"
+}
+
+func (flatKeyLayout) IsPartition(dir string) bool {
+	return false
+}
+
+// datePartitionPrefix starts the names of the date key layout's
+// partitions. "=" can't be in backups' or restores' names, so partitions
+// can't be mistaken for the flat layout's directories.
+const datePartitionPrefix = "date="
+
+// nameTimestamp matches the timestamp that schedules' backups' and
+// restores' default names end in.
+var nameTimestamp = regexp.MustCompile(`-(\d{14})$`)
+
+type dateKeyLayout struct{}
+
+func (dateKeyLayout) Dir(name string) string {
+	match := nameTimestamp.FindStringSubmatch(name)
+	if match == nil {
+		return name + "/"
+	}
+
+	timestamp, err := time.Parse("20060102150405", match[1])
+	if err != nil {
+		return name + "/"
+	}
+
+	return datePartitionPrefix + timestamp.Format("2006-01-02") + "/" + name + "/"
+}
+
+func (dateKeyLayout) IsPartition(dir string) bool {
+	return strings.HasPrefix(dir, datePartitionPrefix)
+}
+
 // ObjectStoreLayout defines how Ark's persisted files map to
 // keys in an object storage bucket.
 type ObjectStoreLayout struct {
 	rootPrefix string
 	subdirs    map[string]string
+	keys       KeyLayout
 }
 
+// NewObjectStoreLayout returns the layout of a backup store under prefix
+// whose backups and restores use the flat key layout.
 func NewObjectStoreLayout(prefix string) *ObjectStoreLayout {
+	return NewObjectStoreLayoutWithKeys(prefix, flatKeyLayout{})
+}
+
+// NewObjectStoreLayoutWithKeys returns the layout of a backup store under
+// prefix whose backups and restores use keys.
+func NewObjectStoreLayoutWithKeys(prefix string, keys KeyLayout) *ObjectStoreLayout {
 	if prefix != "" && !strings.HasSuffix(prefix, "/") {
 		prefix = prefix + "/"
 	}
@@ -44,6 +154,17 @@ func NewObjectStoreLayout(prefix string) *ObjectStoreLayout {
 	return &ObjectStoreLayout{
 		rootPrefix: prefix,
 		subdirs:    subdirs,
+		keys:       keys,
+	}
+}
+
+// withKeys returns a copy of the layout whose backups and restores use
+// keys.
+func (l *ObjectStoreLayout) withKeys(keys KeyLayout) *ObjectStoreLayout {
+	return &ObjectStoreLayout{
+		rootPrefix: l.rootPrefix,
+		subdirs:    l.subdirs,
+		keys:       keys,
 	}
 }
 
@@ -63,30 +184,84 @@ func (l *ObjectStoreLayout) getRevisionKey() string {
 	return path.Join(l.subdirs["metadata"], "revision")
 }
 
+func (l *ObjectStoreLayout) getProbeKey(id string) string {
+	return path.Join(l.subdirs["metadata"], "probe-"+id)
+}
+
 func (l *ObjectStoreLayout) getBackupDir(backup string) string {
-	return path.Join(l.subdirs["backups"], backup) + "/"
+	return l.subdirs["backups"] + l.keys.Dir(backup)
 }
 
 func (l *ObjectStoreLayout) getRestoreDir(restore string) string {
-	return path.Join(l.subdirs["restores"], restore) + "/"
+	return l.subdirs["restores"] + l.keys.Dir(restore)
 }
 
 func (l *ObjectStoreLayout) getBackupMetadataKey(backup string) string {
-	return path.Join(l.subdirs["backups"], backup, "ark-backup.json")
+	return path.Join(l.getBackupDir(backup), "ark-backup.json")
+}
+
+// getBackupCompressedMetadataKey is the key of a backup's metadata when
+// it's gzipped. A backup's metadata is stored under only one of this key
+// and getBackupMetadataKey.
+func (l *ObjectStoreLayout) getBackupCompressedMetadataKey(backup string) string {
+	return path.Join(l.getBackupDir(backup), "ark-backup.json.gz")
 }
 
 func (l *ObjectStoreLayout) getBackupContentsKey(backup string) string {
-	return path.Join(l.subdirs["backups"], backup, fmt.Sprintf("%s.tar.gz", backup))
+	return path.Join(l.getBackupDir(backup), fmt.Sprintf("%s.tar.gz", backup))
 }
 
 func (l *ObjectStoreLayout) getBackupLogKey(backup string) string {
-	return path.Join(l.subdirs["backups"], backup, fmt.Sprintf("%s-logs.gz", backup))
+	return path.Join(l.getBackupDir(backup), fmt.Sprintf("%s-logs.gz", backup))
+}
+
+func (l *ObjectStoreLayout) getBackupChecksumKey(backup string) string {
+	return path.Join(l.getBackupDir(backup), fmt.Sprintf("%s.tar.gz.sha256", backup))
+}
+
+func (l *ObjectStoreLayout) getBackupContentIndexKey(backup string) string {
+	return path.Join(l.getBackupDir(backup), fmt.Sprintf("%s-index.txt", backup))
+}
+
+func (l *ObjectStoreLayout) getBackupManifestKey(backup string) string {
+	return path.Join(l.getBackupDir(backup), fmt.Sprintf("%s-manifest.jsonl", backup))
+}
+
+func (l *ObjectStoreLayout) getBackupSummaryKey(backup string) string {
+	return path.Join(l.getBackupDir(backup), fmt.Sprintf("%s-summary.json", backup))
+}
+
+func (l *ObjectStoreLayout) getBackupCompletionMarkerKey(backup string) string {
+	return path.Join(l.getBackupDir(backup), "ark-backup-complete")
+}
+
+func (l *ObjectStoreLayout) getBackupArtifactKey(backup string, artifact BackupArtifact) string {
+	switch artifact {
+	case BackupArtifactContents:
+		return l.getBackupContentsKey(backup)
+	case BackupArtifactMetadata:
+		return l.getBackupMetadataKey(backup)
+	case BackupArtifactLog:
+		return l.getBackupLogKey(backup)
+	case BackupArtifactChecksum:
+		return l.getBackupChecksumKey(backup)
+	case BackupArtifactContentIndex:
+		return l.getBackupContentIndexKey(backup)
+	case BackupArtifactManifest:
+		return l.getBackupManifestKey(backup)
+	case BackupArtifactSummary:
+		return l.getBackupSummaryKey(backup)
+	case BackupArtifactCompletionMarker:
+		return l.getBackupCompletionMarkerKey(backup)
+	default:
+		return ""
+	}
 }
 
 func (l *ObjectStoreLayout) getRestoreLogKey(restore string) string {
-	return path.Join(l.subdirs["restores"], restore, fmt.Sprintf("restore-%s-logs.gz", restore))
+	return path.Join(l.getRestoreDir(restore), fmt.Sprintf("restore-%s-logs.gz", restore))
 }
 
 func (l *ObjectStoreLayout) getRestoreResultsKey(restore string) string {
-	return path.Join(l.subdirs["restores"], restore, fmt.Sprintf("restore-%s-results.gz", restore))
+	return path.Join(l.getRestoreDir(restore), fmt.Sprintf("restore-%s-results.gz", restore))
 }
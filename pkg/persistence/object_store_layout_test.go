@@ -0,0 +1,115 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetKeyLayout(t *testing.T) {
+	keys, err := GetKeyLayout("")
+	require.NoError(t, err)
+	assert.Equal(t, flatKeyLayout{}, keys)
+
+	keys, err = GetKeyLayout(FlatKeyLayout)
+	require.NoError(t, err)
+	assert.Equal(t, flatKeyLayout{}, keys)
+
+	keys, err = GetKeyLayout(DateKeyLayout)
+	require.NoError(t, err)
+	assert.Equal(t, dateKeyLayout{}, keys)
+
+	_, err = GetKeyLayout("hashed")
+	require.Error(t, err)
+	assert.Equal(t, `unknown object key layout "hashed", must be one of date, flat`, err.Error())
+}
+
+func TestKeyLayoutKeys(t *testing.T) {
+	tests := []struct {
+		name                string
+		prefix              string
+		keys                KeyLayout
+		backup              string
+		restore             string
+		expectedBackupDir   string
+		expectedContentsKey string
+		expectedMetadataKey string
+		expectedRestoreLog  string
+	}{
+		{
+			name:                "flat layout",
+			keys:                flatKeyLayout{},
+			backup:              "daily-20180725091500",
+			restore:             "daily-20180725091500-20180726101500",
+			expectedBackupDir:   "backups/daily-20180725091500/",
+			expectedContentsKey: "backups/daily-20180725091500/daily-20180725091500.tar.gz",
+			expectedMetadataKey: "backups/daily-20180725091500/ark-backup.json",
+			expectedRestoreLog:  "restores/daily-20180725091500-20180726101500/restore-daily-20180725091500-20180726101500-logs.gz",
+		},
+		{
+			name:                "date layout with timestamped names",
+			prefix:              "cluster-1",
+			keys:                dateKeyLayout{},
+			backup:              "daily-20180725091500",
+			restore:             "daily-20180725091500-20180726101500",
+			expectedBackupDir:   "cluster-1/backups/date=2018-07-25/daily-20180725091500/",
+			expectedContentsKey: "cluster-1/backups/date=2018-07-25/daily-20180725091500/daily-20180725091500.tar.gz",
+			expectedMetadataKey: "cluster-1/backups/date=2018-07-25/daily-20180725091500/ark-backup.json",
+			expectedRestoreLog:  "cluster-1/restores/date=2018-07-26/daily-20180725091500-20180726101500/restore-daily-20180725091500-20180726101500-logs.gz",
+		},
+		{
+			name:                "date layout with names without timestamps",
+			keys:                dateKeyLayout{},
+			backup:              "backup-1",
+			restore:             "restore-1",
+			expectedBackupDir:   "backups/backup-1/",
+			expectedContentsKey: "backups/backup-1/backup-1.tar.gz",
+			expectedMetadataKey: "backups/backup-1/ark-backup.json",
+			expectedRestoreLog:  "restores/restore-1/restore-restore-1-logs.gz",
+		},
+		{
+			name:                "date layout with a name ending in digits that aren't a timestamp",
+			keys:                dateKeyLayout{},
+			backup:              "backup-20189999999999",
+			restore:             "restore-1",
+			expectedBackupDir:   "backups/backup-20189999999999/",
+			expectedContentsKey: "backups/backup-20189999999999/backup-20189999999999.tar.gz",
+			expectedMetadataKey: "backups/backup-20189999999999/ark-backup.json",
+			expectedRestoreLog:  "restores/restore-1/restore-restore-1-logs.gz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layout := NewObjectStoreLayoutWithKeys(test.prefix, test.keys)
+
+			assert.Equal(t, test.expectedBackupDir, layout.getBackupDir(test.backup))
+			assert.Equal(t, test.expectedContentsKey, layout.getBackupContentsKey(test.backup))
+			assert.Equal(t, test.expectedMetadataKey, layout.getBackupMetadataKey(test.backup))
+			assert.Equal(t, test.expectedRestoreLog, layout.getRestoreLogKey(test.restore))
+		})
+	}
+}
+
+func TestKeyLayoutPartitions(t *testing.T) {
+	assert.False(t, flatKeyLayout{}.IsPartition("date=2018-07-25/"))
+	assert.True(t, dateKeyLayout{}.IsPartition("date=2018-07-25/"))
+	assert.False(t, dateKeyLayout{}.IsPartition("backup-1/"))
+}
@@ -18,6 +18,7 @@ package persistence
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -25,6 +26,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -32,6 +34,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
 	"github.com/heptio/ark/pkg/cloudprovider"
@@ -157,6 +160,30 @@ func TestIsValid(t *testing.T) {
 	}
 }
 
+func TestProbe(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "bar")
+
+	require.NoError(t, harness.Probe())
+
+	// the probe object is cleaned up
+	assert.Empty(t, harness.objectStore.Data[harness.bucket])
+}
+
+func TestProbeWriteFailure(t *testing.T) {
+	objectStore := new(cloudprovidermocks.ObjectStore)
+	backupStore := &objectBackupStore{
+		objectStore: objectStore,
+		bucket:      "test-bucket",
+		layout:      NewObjectStoreLayout(""),
+		logger:      arktest.NewLogger(),
+	}
+	defer objectStore.AssertExpectations(t)
+
+	objectStore.On("PutObject", "test-bucket", mock.Anything, mock.Anything).Return(errors.New("access denied"))
+
+	arktest.AssertErrorMatches(t, "error writing probe object: access denied", backupStore.Probe())
+}
+
 func TestListBackups(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -210,6 +237,8 @@ func TestPutBackup(t *testing.T) {
 		prefix       string
 		metadata     io.Reader
 		contents     io.Reader
+		contentIndex io.Reader
+		manifest     io.Reader
 		log          io.Reader
 		expectedErr  string
 		expectedKeys []string
@@ -220,7 +249,7 @@ func TestPutBackup(t *testing.T) {
 			contents:     newStringReadSeeker("contents"),
 			log:          newStringReadSeeker("log"),
 			expectedErr:  "",
-			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1.tar.gz.sha256", "backups/backup-1/ark-backup-complete", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
 		},
 		{
 			name:         "normal case with backup store prefix",
@@ -229,7 +258,7 @@ func TestPutBackup(t *testing.T) {
 			contents:     newStringReadSeeker("contents"),
 			log:          newStringReadSeeker("log"),
 			expectedErr:  "",
-			expectedKeys: []string{"prefix-1/backups/backup-1/ark-backup.json", "prefix-1/backups/backup-1/backup-1.tar.gz", "prefix-1/backups/backup-1/backup-1-logs.gz", "prefix-1/metadata/revision"},
+			expectedKeys: []string{"prefix-1/backups/backup-1/ark-backup.json", "prefix-1/backups/backup-1/backup-1.tar.gz", "prefix-1/backups/backup-1/backup-1.tar.gz.sha256", "prefix-1/backups/backup-1/ark-backup-complete", "prefix-1/backups/backup-1/backup-1-logs.gz", "prefix-1/metadata/revision"},
 		},
 		{
 			name:         "error on metadata upload does not upload data",
@@ -247,13 +276,56 @@ func TestPutBackup(t *testing.T) {
 			expectedErr:  "error readers return errors",
 			expectedKeys: []string{"backups/backup-1/backup-1-logs.gz"},
 		},
+		{
+			name:         "content index is uploaded",
+			metadata:     newStringReadSeeker("metadata"),
+			contents:     newStringReadSeeker("contents"),
+			contentIndex: newStringReadSeeker("index"),
+			log:          newStringReadSeeker("log"),
+			expectedErr:  "",
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1.tar.gz.sha256", "backups/backup-1/backup-1-index.txt", "backups/backup-1/ark-backup-complete", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
+		},
+		{
+			name:         "error on content index upload is ok",
+			metadata:     newStringReadSeeker("metadata"),
+			contents:     newStringReadSeeker("contents"),
+			contentIndex: new(errorReader),
+			log:          newStringReadSeeker("log"),
+			expectedErr:  "",
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1.tar.gz.sha256", "backups/backup-1/ark-backup-complete", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
+		},
+		{
+			name:         "manifest is uploaded",
+			metadata:     newStringReadSeeker("metadata"),
+			contents:     newStringReadSeeker("contents"),
+			manifest:     newStringReadSeeker("manifest"),
+			log:          newStringReadSeeker("log"),
+			expectedErr:  "",
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1.tar.gz.sha256", "backups/backup-1/backup-1-manifest.jsonl", "backups/backup-1/ark-backup-complete", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
+		},
+		{
+			name:         "error on manifest upload is ok",
+			metadata:     newStringReadSeeker("metadata"),
+			contents:     newStringReadSeeker("contents"),
+			manifest:     new(errorReader),
+			log:          newStringReadSeeker("log"),
+			expectedErr:  "",
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1.tar.gz.sha256", "backups/backup-1/ark-backup-complete", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
+		},
 		{
 			name:         "error on log upload is ok",
 			metadata:     newStringReadSeeker("foo"),
 			contents:     newStringReadSeeker("bar"),
 			log:          new(errorReader),
 			expectedErr:  "",
-			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "metadata/revision"},
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/backup-1.tar.gz", "backups/backup-1/backup-1.tar.gz.sha256", "backups/backup-1/ark-backup-complete", "metadata/revision"},
+		},
+		{
+			name:         "nil contents aren't uploaded, since they were streamed with PutBackupContents",
+			metadata:     newStringReadSeeker("metadata"),
+			log:          newStringReadSeeker("log"),
+			expectedErr:  "",
+			expectedKeys: []string{"backups/backup-1/ark-backup.json", "backups/backup-1/ark-backup-complete", "backups/backup-1/backup-1-logs.gz", "metadata/revision"},
 		},
 		{
 			name:         "don't upload data when metadata is nil",
@@ -269,7 +341,7 @@ func TestPutBackup(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			harness := newObjectBackupStoreTestHarness("foo", tc.prefix)
 
-			err := harness.PutBackup("backup-1", tc.metadata, tc.contents, tc.log)
+			err := harness.PutBackup("backup-1", tc.metadata, tc.contents, tc.contentIndex, tc.manifest, tc.log)
 
 			arktest.AssertErrorMatches(t, tc.expectedErr, err)
 			assert.Len(t, harness.objectStore.Data[harness.bucket], len(tc.expectedKeys))
@@ -280,6 +352,842 @@ func TestPutBackup(t *testing.T) {
 	}
 }
 
+func TestPutBackupChecksum(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+
+	require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker("metadata"), newStringReadSeeker("contents"), nil, nil, nil))
+
+	// sha256 of "contents"
+	assert.Equal(t, "d1b2a59fbea7e20077af9f91b27e95e865061b270be03ff539ab3b73587882e8", string(harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz.sha256"]))
+	assert.Equal(t, "contents", string(harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"]))
+}
+
+func TestPutBackupContents(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+
+	// a pipe isn't seekable, like a tarball that's being streamed as it's
+	// written.
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("con"))
+		w.Write([]byte("tents"))
+		w.Close()
+	}()
+
+	require.NoError(t, harness.PutBackupContents("backup-1", r))
+
+	// sha256 of "contents"
+	assert.Equal(t, "d1b2a59fbea7e20077af9f91b27e95e865061b270be03ff539ab3b73587882e8", string(harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz.sha256"]))
+	assert.Equal(t, "contents", string(harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"]))
+}
+
+func TestPutBackupContentsError(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("partial contents"))
+		w.CloseWithError(errors.New("backup was aborted"))
+	}()
+
+	assert.EqualError(t, harness.PutBackupContents("backup-1", r), "backup was aborted")
+	assert.Empty(t, harness.objectStore.Data[harness.bucket])
+}
+
+func TestSupportsStreamingUploads(t *testing.T) {
+	for provider, expected := range map[string]bool{"aws": true, "gcp": true, "azure": false, "my-plugin": false} {
+		location := arktest.NewTestBackupStorageLocation().WithProvider(provider).BackupStorageLocation
+		assert.Equal(t, expected, SupportsStreamingUploads(location), provider)
+	}
+}
+
+// sseRecordingObjectStore is an in-memory object store that records the
+// server-side encryption options it was initialized with, and which of
+// them each object was put with.
+type sseRecordingObjectStore struct {
+	*cloudprovider.InMemoryObjectStore
+
+	sse        api.ServerSideEncryption
+	putOptions map[string]api.ServerSideEncryption
+}
+
+func (o *sseRecordingObjectStore) Init(config map[string]string) error {
+	o.sse = api.ServerSideEncryption{
+		Algorithm: config[cloudprovider.ServerSideEncryptionConfigKey],
+		KMSKeyID:  config[cloudprovider.KMSKeyIDConfigKey],
+	}
+	return o.InMemoryObjectStore.Init(config)
+}
+
+func (o *sseRecordingObjectStore) PutObject(bucket, key string, body io.Reader) error {
+	o.putOptions[key] = o.sse
+	return o.InMemoryObjectStore.PutObject(bucket, key, body)
+}
+
+type fakeObjectStoreGetter struct {
+	objectStore cloudprovider.ObjectStore
+}
+
+func (g *fakeObjectStoreGetter) GetObjectStore(provider string) (cloudprovider.ObjectStore, error) {
+	return g.objectStore, nil
+}
+
+func TestServerSideEncryptionPassedToObjectStore(t *testing.T) {
+	tests := []struct {
+		name string
+		sse  *api.ServerSideEncryption
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name: "algorithm only",
+			sse:  &api.ServerSideEncryption{Algorithm: "AES256"},
+		},
+		{
+			name: "algorithm and KMS key ID",
+			sse:  &api.ServerSideEncryption{Algorithm: "aws:kms", KMSKeyID: "alias/ark"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := &sseRecordingObjectStore{
+				InMemoryObjectStore: cloudprovider.NewInMemoryObjectStore("bucket"),
+				putOptions:          make(map[string]api.ServerSideEncryption),
+			}
+
+			location := &api.BackupStorageLocation{
+				Spec: api.BackupStorageLocationSpec{
+					Provider:             "objStoreProvider",
+					StorageType:          api.StorageType{ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket"}},
+					ServerSideEncryption: test.sse,
+				},
+			}
+
+			store, err := NewObjectBackupStore(location, &fakeObjectStoreGetter{objectStore: objectStore}, arktest.NewLogger())
+			require.NoError(t, err)
+			require.NoError(t, store.PutBackup("backup-1", newStringReadSeeker("metadata"), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+
+			// the options are passed to the object store without
+			// modifying the location.
+			assert.Nil(t, location.Spec.Config)
+
+			var expected api.ServerSideEncryption
+			if test.sse != nil {
+				expected = *test.sse
+			}
+
+			require.NotEmpty(t, objectStore.putOptions)
+			for key, options := range objectStore.putOptions {
+				assert.Equal(t, expected, options, key)
+			}
+		})
+	}
+}
+
+// objectLockingObjectStore locks each object it puts for the retention
+// it's initialized with, and refuses to delete objects whose lock hasn't
+// expired, like a bucket with object lock enabled.
+type objectLockingObjectStore struct {
+	*cloudprovider.InMemoryObjectStore
+
+	clock       clock.Clock
+	retention   string
+	retainUntil map[string]time.Time
+}
+
+func (o *objectLockingObjectStore) Init(config map[string]string) error {
+	o.retention = config[cloudprovider.ObjectLockRetentionConfigKey]
+	return o.InMemoryObjectStore.Init(config)
+}
+
+func (o *objectLockingObjectStore) PutObject(bucket, key string, body io.Reader) error {
+	if o.retention != "" {
+		retention, err := time.ParseDuration(o.retention)
+		if err != nil {
+			return err
+		}
+		o.retainUntil[key] = o.clock.Now().Add(retention)
+	}
+	return o.InMemoryObjectStore.PutObject(bucket, key, body)
+}
+
+func (o *objectLockingObjectStore) DeleteObject(bucket, key string) error {
+	if o.clock.Now().Before(o.retainUntil[key]) {
+		return errors.New("access denied")
+	}
+	return o.InMemoryObjectStore.DeleteObject(bucket, key)
+}
+
+func TestObjectLockRetention(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC))
+	objectStore := &objectLockingObjectStore{
+		InMemoryObjectStore: cloudprovider.NewInMemoryObjectStore("bucket"),
+		clock:               fakeClock,
+		retainUntil:         make(map[string]time.Time),
+	}
+
+	location := &api.BackupStorageLocation{
+		Spec: api.BackupStorageLocationSpec{
+			Provider:            "objStoreProvider",
+			StorageType:         api.StorageType{ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket"}},
+			ObjectLockRetention: metav1.Duration{Duration: 30 * 24 * time.Hour},
+		},
+	}
+
+	store, err := NewObjectBackupStore(location, &fakeObjectStoreGetter{objectStore: objectStore}, arktest.NewLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "720h0m0s", objectStore.retention)
+
+	// the retention is passed to the object store without modifying the
+	// location, which may be shared by an informer's cache.
+	assert.NotContains(t, location.Spec.Config, cloudprovider.ObjectLockRetentionConfigKey)
+
+	require.NoError(t, store.PutBackup("backup-1", newStringReadSeeker("metadata"), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+
+	// each of the backup's objects is locked for the retention.
+	require.NotEmpty(t, objectStore.retainUntil)
+	for key, retainUntil := range objectStore.retainUntil {
+		assert.Equal(t, fakeClock.Now().Add(30*24*time.Hour), retainUntil, key)
+	}
+
+	// deleting the backup before its objects' locks expire fails, and
+	// says why.
+	fakeClock.Step(29 * 24 * time.Hour)
+	err = store.DeleteBackup("backup-1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "may still be locked by its storage location's object lock retention of 720h0m0s")
+	assert.Contains(t, err.Error(), "access denied")
+
+	exists, err := store.BackupExists("backup-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// once the locks expire, it's deleted.
+	fakeClock.Step(24 * time.Hour)
+	require.NoError(t, store.DeleteBackup("backup-1"))
+
+	exists, err = store.BackupExists("backup-1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestObjectLockRetentionUnset(t *testing.T) {
+	objectStore := &objectLockingObjectStore{
+		InMemoryObjectStore: cloudprovider.NewInMemoryObjectStore("bucket"),
+		clock:               clock.NewFakeClock(time.Now()),
+		retainUntil:         make(map[string]time.Time),
+	}
+
+	location := &api.BackupStorageLocation{
+		Spec: api.BackupStorageLocationSpec{
+			Provider:    "objStoreProvider",
+			StorageType: api.StorageType{ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket"}},
+		},
+	}
+
+	store, err := NewObjectBackupStore(location, &fakeObjectStoreGetter{objectStore: objectStore}, arktest.NewLogger())
+	require.NoError(t, err)
+	assert.NotContains(t, location.Spec.Config, cloudprovider.ObjectLockRetentionConfigKey)
+
+	require.NoError(t, store.PutBackup("backup-1", newStringReadSeeker("metadata"), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+	assert.Empty(t, objectStore.retainUntil)
+	assert.NoError(t, store.DeleteBackup("backup-1"))
+}
+
+// metadataRecordingObjectStore is an in-memory object store that records
+// the metadata each object was put with.
+type metadataRecordingObjectStore struct {
+	*cloudprovider.InMemoryObjectStore
+
+	metadata map[string]cloudprovider.ObjectMetadata
+}
+
+func (o *metadataRecordingObjectStore) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
+	o.metadata[key] = metadata
+	return o.InMemoryObjectStore.PutObject(bucket, key, body)
+}
+
+func TestPutBackupSetsObjectMetadata(t *testing.T) {
+	zstdTarball := string([]byte{0x28, 0xb5, 0x2f, 0xfd}) + "contents"
+
+	tests := []struct {
+		name      string
+		contents  string
+		encrypted bool
+		streamed  bool
+		expected  map[string]cloudprovider.ObjectMetadata
+	}{
+		{
+			name:     "gzipped tarball",
+			contents: "contents",
+			expected: map[string]cloudprovider.ObjectMetadata{
+				"backups/backup-1/ark-backup.json":     {ContentType: "application/json"},
+				"backups/backup-1/backup-1.tar.gz":     {ContentType: "application/gzip"},
+				"backups/backup-1/backup-1-logs.gz":    {ContentType: "application/gzip"},
+				"backups/backup-1/backup-1-index.txt":  {},
+				"backups/backup-1/ark-backup-complete": {},
+			},
+		},
+		{
+			name:     "zstd-compressed tarball",
+			contents: zstdTarball,
+			expected: map[string]cloudprovider.ObjectMetadata{
+				"backups/backup-1/ark-backup.json":  {ContentType: "application/json"},
+				"backups/backup-1/backup-1.tar.gz":  {ContentType: "application/zstd"},
+				"backups/backup-1/backup-1-logs.gz": {ContentType: "application/gzip"},
+			},
+		},
+		{
+			name:     "streamed zstd-compressed tarball",
+			contents: zstdTarball,
+			streamed: true,
+			expected: map[string]cloudprovider.ObjectMetadata{
+				"backups/backup-1/ark-backup.json":  {ContentType: "application/json"},
+				"backups/backup-1/backup-1.tar.gz":  {ContentType: "application/zstd"},
+				"backups/backup-1/backup-1-logs.gz": {ContentType: "application/gzip"},
+			},
+		},
+		{
+			name:      "encrypted backup",
+			contents:  "contents",
+			encrypted: true,
+			expected: map[string]cloudprovider.ObjectMetadata{
+				"backups/backup-1/ark-backup.json":  {ContentType: "application/octet-stream"},
+				"backups/backup-1/backup-1.tar.gz":  {ContentType: "application/octet-stream"},
+				"backups/backup-1/backup-1-logs.gz": {ContentType: "application/gzip"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			harness := newObjectBackupStoreTestHarness("foo", "")
+			objectStore := &metadataRecordingObjectStore{
+				InMemoryObjectStore: harness.objectStore,
+				metadata:            make(map[string]cloudprovider.ObjectMetadata),
+			}
+			harness.objectBackupStore.objectStore = objectStore
+			if test.encrypted {
+				harness.encrypted = true
+				harness.encryptionKey = testEncryptionKey
+			}
+
+			contents := io.Reader(newStringReadSeeker(test.contents))
+			if test.streamed {
+				require.NoError(t, harness.PutBackupContents("backup-1", strings.NewReader(test.contents)))
+				contents = nil
+			}
+			require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(testBackupMetadata), contents, newStringReadSeeker("index"), nil, newStringReadSeeker("log")))
+
+			for key, expected := range test.expected {
+				assert.Equal(t, expected, objectStore.metadata[key], key)
+			}
+
+			// the whole tarball is uploaded, including the bytes that were
+			// read to tell how it's compressed.
+			if !test.encrypted {
+				assert.Equal(t, test.contents, string(harness.objectStore.Data["foo"]["backups/backup-1/backup-1.tar.gz"]))
+			}
+		})
+	}
+}
+
+func TestPutRestoreLogSetsObjectMetadata(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+	objectStore := &metadataRecordingObjectStore{
+		InMemoryObjectStore: harness.objectStore,
+		metadata:            make(map[string]cloudprovider.ObjectMetadata),
+	}
+	harness.objectBackupStore.objectStore = objectStore
+
+	require.NoError(t, harness.PutRestoreLog("backup-1", "restore-1", strings.NewReader("log")))
+	require.NoError(t, harness.PutRestoreResults("backup-1", "restore-1", strings.NewReader("results")))
+
+	assert.Equal(t, cloudprovider.ObjectMetadata{ContentType: "application/gzip"}, objectStore.metadata["restores/restore-1/restore-restore-1-logs.gz"])
+	assert.Equal(t, cloudprovider.ObjectMetadata{ContentType: "application/gzip"}, objectStore.metadata["restores/restore-1/restore-restore-1-results.gz"])
+}
+
+func TestPutBackupLog(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+	objectStore := &metadataRecordingObjectStore{
+		InMemoryObjectStore: harness.objectStore,
+		metadata:            make(map[string]cloudprovider.ObjectMetadata),
+	}
+	harness.objectBackupStore.objectStore = objectStore
+
+	require.NoError(t, harness.PutBackupLog("backup-1", strings.NewReader("log")))
+
+	// only the log is uploaded.
+	assert.Equal(t, cloudprovider.BucketData{"backups/backup-1/backup-1-logs.gz": []byte("log")}, harness.objectStore.Data[harness.bucket])
+	assert.Equal(t, cloudprovider.ObjectMetadata{ContentType: "application/gzip"}, objectStore.metadata["backups/backup-1/backup-1-logs.gz"])
+}
+
+func TestGetBackupContentsVerifiesChecksum(t *testing.T) {
+	tests := []struct {
+		name        string
+		encrypted   bool
+		corrupt     func(harness *objectBackupStoreTestHarness)
+		expectedErr bool
+	}{
+		{
+			name: "intact contents are read",
+		},
+		{
+			name:      "intact encrypted contents are read",
+			encrypted: true,
+		},
+		{
+			name: "modified contents fail",
+			corrupt: func(harness *objectBackupStoreTestHarness) {
+				harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"][0] ^= 0xff
+			},
+			expectedErr: true,
+		},
+		{
+			name: "truncated contents fail",
+			corrupt: func(harness *objectBackupStoreTestHarness) {
+				data := harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"]
+				harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"] = data[:len(data)-1]
+			},
+			expectedErr: true,
+		},
+		{
+			name:      "bytes appended to encrypted contents fail",
+			encrypted: true,
+			corrupt: func(harness *objectBackupStoreTestHarness) {
+				data := harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"]
+				harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"] = append(data, 'x')
+			},
+			expectedErr: true,
+		},
+		{
+			name: "contents without a checksum aren't verified",
+			corrupt: func(harness *objectBackupStoreTestHarness) {
+				delete(harness.objectStore.Data[harness.bucket], "backups/backup-1/backup-1.tar.gz.sha256")
+				harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"] = []byte("modified")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			harness := newObjectBackupStoreTestHarness("foo", "")
+			if test.encrypted {
+				harness.encrypted = true
+				harness.encryptionKey = testEncryptionKey
+			}
+
+			require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(testBackupMetadata), newStringReadSeeker("contents"), nil, nil, nil))
+			if test.corrupt != nil {
+				test.corrupt(harness)
+			}
+
+			contents, err := harness.GetBackupContents("backup-1")
+			require.NoError(t, err)
+			defer contents.Close()
+
+			_, err = ioutil.ReadAll(contents)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestIsBackupComplete(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "")
+
+	for _, key := range []string{
+		"backups/backup-1/ark-backup.json",
+		"backups/backup-1/backup-1.tar.gz",
+		"backups/backup-1/ark-backup-complete",
+		"backups/backup-2/ark-backup.json",
+		"backups/backup-2/backup-2.tar.gz",
+	} {
+		require.NoError(t, harness.objectStore.PutObject(harness.bucket, key, newStringReadSeeker("foo")))
+	}
+
+	withMarker := func(backup *api.Backup) *api.Backup {
+		backup.Annotations = map[string]string{api.CompletionMarkerAnnotation: "true"}
+		return backup
+	}
+
+	complete, err := IsBackupComplete(harness, withMarker(arktest.NewTestBackup().WithName("backup-1").Backup))
+	require.NoError(t, err)
+	assert.True(t, complete)
+
+	complete, err = IsBackupComplete(harness, withMarker(arktest.NewTestBackup().WithName("backup-2").Backup))
+	require.NoError(t, err)
+	assert.False(t, complete)
+
+	// backups uploaded without a completion marker are assumed complete
+	complete, err = IsBackupComplete(harness, arktest.NewTestBackup().WithName("backup-2").Backup)
+	require.NoError(t, err)
+	assert.True(t, complete)
+}
+
+func TestBackupExists(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "")
+
+	metadata := `{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"backup-1"}}`
+	require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(metadata), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+
+	// backup-2's upload was interrupted after its tarball was uploaded, but
+	// before its completion marker was.
+	for _, key := range []string{
+		"backups/backup-2/ark-backup.json",
+		"backups/backup-2/backup-2.tar.gz",
+		"backups/backup-2/backup-2-logs.gz",
+	} {
+		require.NoError(t, harness.objectStore.PutObject(harness.bucket, key, newStringReadSeeker("foo")))
+	}
+
+	exists, err := harness.BackupExists("backup-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = harness.BackupExists("backup-2")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = harness.BackupExists("backup-3")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestBackupMetadataRoundTrips(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "")
+
+	metadata := `{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"backup-1"},` +
+		`"spec":{"metadata":{"git-sha":"0123abc","change-ticket":"CHG-42"}},` +
+		`"status":{"metadata":{"git-sha":"0123abc","change-ticket":"CHG-42"}}}`
+	require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(metadata), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+
+	backup, err := harness.GetBackupMetadata("backup-1")
+	require.NoError(t, err)
+
+	expected := map[string]string{"git-sha": "0123abc", "change-ticket": "CHG-42"}
+	assert.Equal(t, expected, backup.Spec.Metadata)
+	assert.Equal(t, expected, backup.Status.Metadata)
+}
+
+func TestCompressedBackupMetadataRoundTrips(t *testing.T) {
+	compressed := new(bytes.Buffer)
+	gzw := gzip.NewWriter(compressed)
+	_, err := gzw.Write([]byte(testBackupMetadata))
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+
+	tests := []struct {
+		name        string
+		metadata    string
+		encrypted   bool
+		expectedKey string
+		otherKey    string
+	}{
+		{
+			name:        "uncompressed metadata",
+			metadata:    testBackupMetadata,
+			expectedKey: "backups/backup-1/ark-backup.json",
+			otherKey:    "backups/backup-1/ark-backup.json.gz",
+		},
+		{
+			name:        "compressed metadata",
+			metadata:    compressed.String(),
+			expectedKey: "backups/backup-1/ark-backup.json.gz",
+			otherKey:    "backups/backup-1/ark-backup.json",
+		},
+		{
+			name:        "encrypted compressed metadata",
+			metadata:    compressed.String(),
+			encrypted:   true,
+			expectedKey: "backups/backup-1/ark-backup.json.gz",
+			otherKey:    "backups/backup-1/ark-backup.json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			harness := newObjectBackupStoreTestHarness("test-bucket", "")
+			if test.encrypted {
+				harness.encrypted = true
+				harness.encryptionKey = testEncryptionKey
+			}
+
+			require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(test.metadata), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+
+			assert.Contains(t, harness.objectStore.Data[harness.bucket], test.expectedKey)
+			assert.NotContains(t, harness.objectStore.Data[harness.bucket], test.otherKey)
+
+			backup, err := harness.GetBackupMetadata("backup-1")
+			require.NoError(t, err)
+			assert.Equal(t, "backup-1", backup.Name)
+
+			exists, err := harness.BackupExists("backup-1")
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestPutBackupMetadataCompressed(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "")
+
+	compressed := new(bytes.Buffer)
+	gzw := gzip.NewWriter(compressed)
+	_, err := gzw.Write([]byte(testBackupMetadata))
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+
+	require.NoError(t, harness.PutBackupMetadata("backup-1", bytes.NewReader(compressed.Bytes())))
+	assert.Equal(t, compressed.Bytes(), harness.objectStore.Data[harness.bucket]["backups/backup-1/ark-backup.json.gz"])
+
+	backup, err := harness.GetBackupMetadata("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, "backup-1", backup.Name)
+}
+
+func TestGetBackupMetadataMissing(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "")
+
+	_, err := harness.GetBackupMetadata("backup-1")
+	assert.EqualError(t, err, "key not found")
+}
+
+func TestBackupSummaryRoundTrips(t *testing.T) {
+	for _, encrypted := range []bool{false, true} {
+		harness := newObjectBackupStoreTestHarness("test-bucket", "")
+		objectStore := &metadataRecordingObjectStore{
+			InMemoryObjectStore: harness.objectStore,
+			metadata:            make(map[string]cloudprovider.ObjectMetadata),
+		}
+		harness.objectBackupStore.objectStore = objectStore
+		if encrypted {
+			harness.encrypted = true
+			harness.encryptionKey = testEncryptionKey
+		}
+
+		summary := &BackupSummary{
+			Name:            "backup-1",
+			Namespace:       "heptio-ark",
+			Labels:          map[string]string{"app": "nginx"},
+			Phase:           api.BackupPhaseCompleted,
+			StorageLocation: "default",
+			ItemsBackedUp:   3,
+			TarballChecksum: "abc123",
+		}
+		require.NoError(t, harness.PutBackupSummary("backup-1", summary))
+
+		// the summary is the only object that's put, and it's readable
+		// without the backup's full metadata.
+		key := "backups/backup-1/backup-1-summary.json"
+		assert.Len(t, harness.objectStore.Data[harness.bucket], 1)
+		assert.Equal(t, encrypted, bytes.HasPrefix(harness.objectStore.Data[harness.bucket][key], encryptionMagic))
+		if encrypted {
+			assert.Equal(t, encryptedObjectMetadata, objectStore.metadata[key])
+		} else {
+			assert.Equal(t, jsonObjectMetadata, objectStore.metadata[key])
+		}
+
+		res, err := harness.GetBackupMetadataSummary("backup-1")
+		require.NoError(t, err)
+		assert.Equal(t, summary, res)
+	}
+}
+
+func TestGetBackupMetadataSummaryWithoutSummary(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "")
+
+	// backups uploaded without a summary have theirs read from their
+	// full metadata.
+	metadata := `{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"backup-1","namespace":"heptio-ark"},` +
+		`"spec":{"storageLocation":"default"},"status":{"phase":"Completed","progress":{"itemsBackedUp":3}}}`
+	require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(metadata), newStringReadSeeker("contents"), nil, nil, nil))
+
+	summary, err := harness.GetBackupMetadataSummary("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, "backup-1", summary.Name)
+	assert.Equal(t, "heptio-ark", summary.Namespace)
+	assert.Equal(t, api.BackupPhaseCompleted, summary.Phase)
+	assert.Equal(t, "default", summary.StorageLocation)
+	assert.Equal(t, 3, summary.ItemsBackedUp)
+
+	_, err = harness.GetBackupMetadataSummary("backup-2")
+	assert.EqualError(t, err, "key not found")
+}
+
+func TestListBackupArtifacts(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("test-bucket", "prefix-1")
+
+	for _, key := range []string{
+		"prefix-1/backups/backup-1/ark-backup.json",
+		"prefix-1/backups/backup-1/backup-1.tar.gz",
+		"prefix-1/backups/backup-2/backup-2.tar.gz",
+		"prefix-1/backups/backup-2/backup-2-logs.gz",
+		"prefix-1/backups/backup-2/backup-2-summary.json",
+		"prefix-1/backups/backup-3/ark-backup.json.gz",
+	} {
+		require.NoError(t, harness.objectStore.PutObject(harness.bucket, key, newStringReadSeeker("foo")))
+	}
+
+	artifacts, err := harness.ListBackupArtifacts("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, []BackupArtifact{BackupArtifactContents, BackupArtifactMetadata}, artifacts)
+
+	artifacts, err = harness.ListBackupArtifacts("backup-2")
+	require.NoError(t, err)
+	assert.Equal(t, []BackupArtifact{BackupArtifactContents, BackupArtifactLog, BackupArtifactSummary}, artifacts)
+
+	artifacts, err = harness.ListBackupArtifacts("backup-3")
+	require.NoError(t, err)
+	assert.Equal(t, []BackupArtifact{BackupArtifactMetadata}, artifacts)
+
+	artifacts, err = harness.ListBackupArtifacts("backup-4")
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
+
+func TestBackupStoresWithDifferentPrefixesDontCollide(t *testing.T) {
+	team1 := newObjectBackupStoreTestHarness("shared-bucket", "team-1")
+	team2 := newObjectBackupStoreTestHarness("shared-bucket", "team-2")
+	// both stores use the same bucket
+	team2.objectStore = team1.objectStore
+	team2.objectBackupStore.objectStore = team1.objectStore
+
+	for _, harness := range []*objectBackupStoreTestHarness{team1, team2} {
+		metadata := `{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"backup-1","labels":{"team":"` + harness.prefix + `"}}}`
+		require.NoError(t, harness.PutBackup("backup-1", newStringReadSeeker(metadata), newStringReadSeeker(harness.prefix), nil, nil, newStringReadSeeker("log")))
+	}
+
+	for _, key := range []string{
+		"team-1/backups/backup-1/ark-backup.json",
+		"team-1/backups/backup-1/backup-1.tar.gz",
+		"team-1/backups/backup-1/backup-1-logs.gz",
+		"team-2/backups/backup-1/ark-backup.json",
+		"team-2/backups/backup-1/backup-1.tar.gz",
+		"team-2/backups/backup-1/backup-1-logs.gz",
+	} {
+		assert.Contains(t, team1.objectStore.Data["shared-bucket"], key)
+	}
+
+	for _, harness := range []*objectBackupStoreTestHarness{team1, team2} {
+		backup, err := harness.GetBackupMetadata("backup-1")
+		require.NoError(t, err)
+		assert.Equal(t, harness.prefix, backup.Labels["team"])
+
+		contents, err := harness.GetBackupContents("backup-1")
+		require.NoError(t, err)
+		res, err := ioutil.ReadAll(contents)
+		contents.Close()
+		require.NoError(t, err)
+		assert.Equal(t, harness.prefix, string(res))
+	}
+
+	// deleting one team's backup leaves the other's alone
+	require.NoError(t, team1.DeleteBackup("backup-1"))
+
+	artifacts, err := team1.ListBackupArtifacts("backup-1")
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+
+	artifacts, err = team2.ListBackupArtifacts("backup-1")
+	require.NoError(t, err)
+	assert.Contains(t, artifacts, BackupArtifactContents)
+	assert.Contains(t, artifacts, BackupArtifactMetadata)
+}
+
+func TestCrossKeyLayoutLookups(t *testing.T) {
+	objectStore := cloudprovider.NewInMemoryObjectStore("test-bucket")
+	newStore := func(keys KeyLayout) *objectBackupStore {
+		return &objectBackupStore{
+			objectStore: objectStore,
+			bucket:      "test-bucket",
+			layout:      NewObjectStoreLayoutWithKeys("", keys),
+			logger:      arktest.NewLogger(),
+		}
+	}
+	flatStore, dateStore := newStore(flatKeyLayout{}), newStore(dateKeyLayout{})
+
+	putBackup := func(store *objectBackupStore, name string) {
+		metadata := `{"apiVersion":"ark.heptio.com/v1","kind":"Backup","metadata":{"name":"` + name + `"}}`
+		require.NoError(t, store.PutBackup(name, newStringReadSeeker(metadata), newStringReadSeeker("contents"), nil, nil, newStringReadSeeker("log")))
+	}
+
+	// the backup stored before the location's key layout was changed, and
+	// the ones stored after.
+	putBackup(flatStore, "daily-20180724091500")
+	putBackup(dateStore, "daily-20180725091500")
+	putBackup(dateStore, "backup-1")
+
+	keys, err := objectStore.ListObjects("test-bucket", "backups/")
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Contains(t, keys, "backups/daily-20180724091500/daily-20180724091500.tar.gz")
+	assert.Contains(t, keys, "backups/date=2018-07-25/daily-20180725091500/daily-20180725091500.tar.gz")
+	assert.Contains(t, keys, "backups/backup-1/backup-1.tar.gz")
+
+	for _, store := range []*objectBackupStore{flatStore, dateStore} {
+		names, err := store.ListBackups()
+		require.NoError(t, err)
+		sort.Strings(names)
+		assert.Equal(t, []string{"backup-1", "daily-20180724091500", "daily-20180725091500"}, names)
+
+		for _, name := range names {
+			exists, err := store.BackupExists(name)
+			require.NoError(t, err)
+			assert.True(t, exists, "backup %s", name)
+
+			backup, err := store.GetBackupMetadata(name)
+			require.NoError(t, err)
+			assert.Equal(t, name, backup.Name)
+		}
+	}
+
+	// a backup put in another layout keeps all of its objects there.
+	require.NoError(t, dateStore.PutBackupLog("daily-20180724091500", newStringReadSeeker("new log")))
+	_, err = objectStore.GetObject("test-bucket", "backups/daily-20180724091500/daily-20180724091500-logs.gz")
+	require.NoError(t, err)
+	_, err = objectStore.GetObject("test-bucket", "backups/date=2018-07-25/daily-20180724091500/daily-20180724091500-logs.gz")
+	assert.Error(t, err)
+
+	// restores are found, and deleted, in either layout too.
+	require.NoError(t, flatStore.PutRestoreLog("daily-20180724091500", "daily-20180724091500-20180726101500", newStringReadSeeker("log")))
+	// the in-memory store only signs URLs for keys that exist.
+	_, err = dateStore.GetDownloadURL(api.DownloadTarget{Kind: api.DownloadTargetKindRestoreLog, Name: "daily-20180724091500-20180726101500"})
+	require.NoError(t, err)
+	require.NoError(t, dateStore.DeleteRestore("daily-20180724091500-20180726101500"))
+	keys, err = objectStore.ListObjects("test-bucket", "restores/")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// and so are backups.
+	require.NoError(t, flatStore.DeleteBackup("daily-20180725091500"))
+	require.NoError(t, dateStore.DeleteBackup("daily-20180724091500"))
+	names, err := dateStore.ListBackups()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backup-1"}, names)
+}
+
+func TestNewObjectBackupStoreUnknownKeyLayout(t *testing.T) {
+	location := &api.BackupStorageLocation{
+		Spec: api.BackupStorageLocationSpec{
+			Provider: "myCloud",
+			StorageType: api.StorageType{
+				ObjectStorage: &api.ObjectStorageLocation{Bucket: "bucket", KeyLayout: "hashed"},
+			},
+		},
+	}
+	objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+
+	_, err := NewObjectBackupStore(location, &fakeObjectStoreGetter{objectStore: objectStore}, arktest.NewLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown object key layout "hashed"`)
+}
+
 func TestGetBackupContents(t *testing.T) {
 	harness := newObjectBackupStoreTestHarness("test-bucket", "")
 
@@ -437,10 +1345,6 @@ func newStringReadSeeker(s string) *stringReadSeeker {
 	}
 }
 
-func (srs *stringReadSeeker) Seek(offset int64, whence int) (int64, error) {
-	return 0, nil
-}
-
 type errorReader struct{}
 
 func (r *errorReader) Read([]byte) (int, error) {
@@ -0,0 +1,180 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/heptio/ark/pkg/cloudprovider"
+)
+
+// ObjectStoreRateLimitConfig configures the rate of requests made to
+// object stores.
+type ObjectStoreRateLimitConfig struct {
+	// QPS is the number of requests per second that can be made with each
+	// set of credentials. Zero means requests aren't limited.
+	QPS float64
+
+	// Burst is the number of requests that can be made at once with each
+	// set of credentials before they're limited to QPS. Zero uses 1.
+	Burst int
+}
+
+// ObjectStoreRateLimiter limits the rate of requests made to object
+// stores. Requests made with the same provider and credentials share a
+// token bucket, whichever backup, restore or controller they're made for,
+// since that's what cloud providers' request quotas apply to.
+type ObjectStoreRateLimiter struct {
+	config ObjectStoreRateLimitConfig
+
+	lock     sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewObjectStoreRateLimiter returns an ObjectStoreRateLimiter for config,
+// or nil if config doesn't limit requests.
+func NewObjectStoreRateLimiter(config ObjectStoreRateLimitConfig) *ObjectStoreRateLimiter {
+	if config.QPS <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+
+	return &ObjectStoreRateLimiter{
+		config:   config,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Limit returns an ObjectStore that makes objectStore's requests at the
+// limiter's rate for the credentials it's initialized with. If the
+// limiter is nil, it returns objectStore.
+func (l *ObjectStoreRateLimiter) Limit(provider string, objectStore cloudprovider.ObjectStore) cloudprovider.ObjectStore {
+	if l == nil {
+		return objectStore
+	}
+
+	return &rateLimitedObjectStore{
+		ObjectStore: objectStore,
+		provider:    provider,
+		limiters:    l,
+	}
+}
+
+// limiterFor returns the token bucket shared by the requests made with
+// the credentials identified by scope.
+func (l *ObjectStoreRateLimiter) limiterFor(scope string) *rate.Limiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limiter, ok := l.limiters[scope]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.config.QPS), l.config.Burst)
+		l.limiters[scope] = limiter
+	}
+
+	return limiter
+}
+
+// credentialsScope returns the scope of the requests made by a provider's
+// object store initialized with config: the provider, and, if the object
+// store doesn't use the provider's default credentials, a hash of the
+// credentials it does use. Credentials files are written afresh for each
+// backup that uses them, so it's their contents, rather than their paths,
+// that identify them.
+func credentialsScope(provider string, config map[string]string) string {
+	credentialsFile := config[cloudprovider.CredentialsFileConfigKey]
+	if credentialsFile == "" {
+		return provider
+	}
+
+	credentials, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return provider + "/" + credentialsFile
+	}
+
+	sum := sha256.Sum256(credentials)
+	return provider + "/" + hex.EncodeToString(sum[:])
+}
+
+// rateLimitedObjectStore waits for a token from the bucket for its
+// credentials before each request it makes. CreateSignedURL signs URLs
+// without making requests, so it isn't limited. A PutObject takes a single
+// token, although the object store may make several requests for it, e.g.
+// one for each part of an AWS multipart upload, since how it splits an
+// object up isn't visible from here.
+type rateLimitedObjectStore struct {
+	cloudprovider.ObjectStore
+
+	provider string
+	limiters *ObjectStoreRateLimiter
+
+	// limiter is set by Init, once the credentials are known.
+	limiter *rate.Limiter
+}
+
+func (s *rateLimitedObjectStore) Init(config map[string]string) error {
+	s.limiter = s.limiters.limiterFor(credentialsScope(s.provider, config))
+	return s.ObjectStore.Init(config)
+}
+
+func (s *rateLimitedObjectStore) wait() {
+	if s.limiter == nil {
+		return
+	}
+	// the context is never done, so Wait only fails if a single request
+	// needs more tokens than the burst, which it can't.
+	_ = s.limiter.Wait(context.Background())
+}
+
+func (s *rateLimitedObjectStore) PutObject(bucket, key string, body io.Reader) error {
+	s.wait()
+	return s.ObjectStore.PutObject(bucket, key, body)
+}
+
+func (s *rateLimitedObjectStore) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
+	s.wait()
+	return cloudprovider.PutObjectWithMetadata(s.ObjectStore, bucket, key, body, metadata)
+}
+
+func (s *rateLimitedObjectStore) GetObject(bucket, key string) (io.ReadCloser, error) {
+	s.wait()
+	return s.ObjectStore.GetObject(bucket, key)
+}
+
+func (s *rateLimitedObjectStore) ListCommonPrefixes(bucket, prefix, delimiter string) ([]string, error) {
+	s.wait()
+	return s.ObjectStore.ListCommonPrefixes(bucket, prefix, delimiter)
+}
+
+func (s *rateLimitedObjectStore) ListObjects(bucket, prefix string) ([]string, error) {
+	s.wait()
+	return s.ObjectStore.ListObjects(bucket, prefix)
+}
+
+func (s *rateLimitedObjectStore) DeleteObject(bucket, key string) error {
+	s.wait()
+	return s.ObjectStore.DeleteObject(bucket, key)
+}
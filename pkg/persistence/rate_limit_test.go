@@ -0,0 +1,105 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/heptio/ark/pkg/cloudprovider"
+)
+
+func newRateLimitedTestStore(t *testing.T, limiter *ObjectStoreRateLimiter, config map[string]string) cloudprovider.ObjectStore {
+	objectStore := limiter.Limit("aws", cloudprovider.NewInMemoryObjectStore("bucket"))
+	require.NoError(t, objectStore.Init(config))
+	return objectStore
+}
+
+func writeCredentialsFile(t *testing.T, contents string) string {
+	file, err := ioutil.TempFile("", "credentials")
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(contents)
+	require.NoError(t, err)
+	return file.Name()
+}
+
+func TestNewObjectStoreRateLimiterUnlimited(t *testing.T) {
+	assert.Nil(t, NewObjectStoreRateLimiter(ObjectStoreRateLimitConfig{}))
+
+	objectStore := cloudprovider.NewInMemoryObjectStore("bucket")
+	var limiter *ObjectStoreRateLimiter
+	assert.Equal(t, objectStore, limiter.Limit("aws", objectStore))
+}
+
+func TestObjectStoreRateLimiterSpacesRequests(t *testing.T) {
+	limiter := NewObjectStoreRateLimiter(ObjectStoreRateLimitConfig{QPS: 20, Burst: 2})
+
+	// two object stores with the provider's default credentials, e.g. for
+	// two concurrent backups, share a bucket.
+	store1 := newRateLimitedTestStore(t, limiter, nil)
+	store2 := newRateLimitedTestStore(t, limiter, nil)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store1.PutObject("bucket", "key", newStringReadSeeker("foo")))
+		_, err := store2.ListObjects("bucket", "")
+		require.NoError(t, err)
+	}
+
+	// the burst's 2 requests are made at once, and the other 4 are spaced
+	// 50ms apart.
+	assert.True(t, time.Since(start) >= 200*time.Millisecond-10*time.Millisecond, "requests took %v", time.Since(start))
+}
+
+func TestObjectStoreRateLimiterScopedByCredentials(t *testing.T) {
+	limiter := NewObjectStoreRateLimiter(ObjectStoreRateLimitConfig{QPS: 1, Burst: 1})
+
+	credentials1 := writeCredentialsFile(t, "credentials-1")
+	defer os.Remove(credentials1)
+	credentials2 := writeCredentialsFile(t, "credentials-2")
+	defer os.Remove(credentials2)
+	sameAsCredentials1 := writeCredentialsFile(t, "credentials-1")
+	defer os.Remove(sameAsCredentials1)
+
+	stores := []cloudprovider.ObjectStore{
+		newRateLimitedTestStore(t, limiter, nil),
+		newRateLimitedTestStore(t, limiter, map[string]string{cloudprovider.CredentialsFileConfigKey: credentials1}),
+		newRateLimitedTestStore(t, limiter, map[string]string{cloudprovider.CredentialsFileConfigKey: credentials2}),
+	}
+
+	// each set of credentials has its own bucket, so each store's first
+	// request is made right away.
+	start := time.Now()
+	for _, store := range stores {
+		_, err := store.ListObjects("bucket", "")
+		require.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "requests took %v", time.Since(start))
+
+	// credentials are identified by their contents, so a store using
+	// another file with the same credentials shares their bucket.
+	assert.Len(t, limiter.limiters, 3)
+	newRateLimitedTestStore(t, limiter, map[string]string{cloudprovider.CredentialsFileConfigKey: sameAsCredentials1})
+	assert.Len(t, limiter.limiters, 3)
+}
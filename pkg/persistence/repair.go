@@ -0,0 +1,263 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
+	"github.com/heptio/ark/pkg/generated/clientset/versioned/scheme"
+	"github.com/heptio/ark/pkg/transform"
+	"github.com/heptio/ark/pkg/util/encode"
+)
+
+// BackupRepairResult reports the outcome of repairing a backup's
+// artifacts in a backup store.
+type BackupRepairResult struct {
+	// Repaired lists the artifacts that were missing and have been
+	// re-uploaded.
+	Repaired []BackupArtifact
+
+	// Unrecoverable lists the artifacts that are missing and could
+	// not be reconstructed.
+	Unrecoverable []BackupArtifact
+}
+
+// RepairBackup checks which of the named backup's artifacts exist in the
+// backup store and re-uploads any missing ones that can be reconstructed.
+//
+// The metadata file is rebuilt from the copy of the backup's API object in
+// its tarball, or from backup, the backup's API object from the cluster,
+// if it's non-nil and the tarball predates the copy. The tarball's copy was
+// written before the backup finished, so it's marked Completed. The
+// summary is rebuilt from the metadata, the checksum is recomputed from
+// the tarball, and the content index, if the backup has one, is
+// regenerated from the tarball. The tarball and log file can't be
+// regenerated, so if missing they're reported as unrecoverable. Once the
+// tarball and metadata are both in the store, the completion marker is
+// uploaded, if it's missing, so that the backup's treated as complete. If
+// the tarball is missing, nothing is re-uploaded, since a backup without
+// contents can't be restored.
+//
+// backup, if it's non-nil, is also used to read the tarball when the
+// metadata's missing, since it records how the tarball was compressed and
+// transformed.
+func RepairBackup(store BackupStore, name string, backup *arkv1api.Backup) (*BackupRepairResult, error) {
+	artifacts, err := store.ListBackupArtifacts(name)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[BackupArtifact]bool, len(artifacts))
+	for _, artifact := range artifacts {
+		exists[artifact] = true
+	}
+
+	res := new(BackupRepairResult)
+
+	if !exists[BackupArtifactContents] {
+		for _, artifact := range []BackupArtifact{BackupArtifactContents, BackupArtifactMetadata, BackupArtifactLog, BackupArtifactChecksum, BackupArtifactSummary, BackupArtifactCompletionMarker} {
+			if !exists[artifact] {
+				res.Unrecoverable = append(res.Unrecoverable, artifact)
+			}
+		}
+		return res, nil
+	}
+
+	var checksum string
+	if !exists[BackupArtifactChecksum] {
+		if checksum, err = store.PutBackupChecksum(name); err != nil {
+			return nil, errors.WithMessage(err, "error repairing backup checksum")
+		}
+		res.Repaired = append(res.Repaired, BackupArtifactChecksum)
+	}
+
+	// metadata is the backup's metadata, as it's rebuilt or, if it's
+	// needed to rebuild other artifacts, as it's stored. It's nil if it
+	// can't be rebuilt.
+	var metadata *arkv1api.Backup
+	if exists[BackupArtifactMetadata] {
+		if !exists[BackupArtifactSummary] || !exists[BackupArtifactContentIndex] {
+			if metadata, err = store.GetBackupMetadata(name); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if metadata, err = backupFromTarball(store, name, backup); err != nil {
+			return nil, err
+		}
+		if metadata == nil {
+			metadata = backup
+		}
+
+		if metadata == nil {
+			res.Unrecoverable = append(res.Unrecoverable, BackupArtifactMetadata)
+		} else {
+			if metadata.Status.TarballChecksum == "" {
+				metadata.Status.TarballChecksum = checksum
+			}
+
+			buf := new(bytes.Buffer)
+			if err := encode.EncodeTo(metadata, "json", buf); err != nil {
+				return nil, errors.Wrap(err, "error encoding backup metadata")
+			}
+
+			if err := store.PutBackupMetadata(name, buf); err != nil {
+				return nil, errors.Wrap(err, "error uploading backup metadata")
+			}
+			res.Repaired = append(res.Repaired, BackupArtifactMetadata)
+		}
+	}
+
+	if !exists[BackupArtifactSummary] {
+		if metadata == nil {
+			res.Unrecoverable = append(res.Unrecoverable, BackupArtifactSummary)
+		} else {
+			if err := store.PutBackupSummary(name, NewBackupSummary(metadata)); err != nil {
+				return nil, errors.Wrap(err, "error uploading backup summary")
+			}
+			res.Repaired = append(res.Repaired, BackupArtifactSummary)
+		}
+	}
+
+	if !exists[BackupArtifactContentIndex] && metadata != nil && metadata.Annotations[arkv1api.ContentIndexVersionAnnotation] != "" {
+		if err := repairContentIndex(store, name, metadata); err != nil {
+			return nil, err
+		}
+		res.Repaired = append(res.Repaired, BackupArtifactContentIndex)
+	}
+
+	if !exists[BackupArtifactLog] {
+		// the log is only ever written while the backup runs, so it's lost.
+		res.Unrecoverable = append(res.Unrecoverable, BackupArtifactLog)
+	}
+
+	// the marker's uploaded last, like it is by PutBackup, once the rest of
+	// the backup that can be has been repaired.
+	if !exists[BackupArtifactCompletionMarker] {
+		if exists[BackupArtifactMetadata] || hasArtifact(res.Repaired, BackupArtifactMetadata) {
+			if err := store.PutBackupCompletionMarker(name); err != nil {
+				return nil, err
+			}
+			res.Repaired = append(res.Repaired, BackupArtifactCompletionMarker)
+		} else {
+			res.Unrecoverable = append(res.Unrecoverable, BackupArtifactCompletionMarker)
+		}
+	}
+
+	return res, nil
+}
+
+func hasArtifact(artifacts []BackupArtifact, artifact BackupArtifact) bool {
+	for _, a := range artifacts {
+		if a == artifact {
+			return true
+		}
+	}
+	return false
+}
+
+// backupFromTarball returns the copy of the backup's API object from its
+// tarball, read using backup's transforms and compression if backup is
+// non-nil, or as a plain gzipped or zstd-compressed tarball otherwise. It
+// returns nil if the tarball doesn't have a copy.
+func backupFromTarball(store BackupStore, name string, backup *arkv1api.Backup) (*arkv1api.Backup, error) {
+	contents, err := store.GetBackupContents(name)
+	if err != nil {
+		return nil, err
+	}
+	defer contents.Close()
+
+	var (
+		tarball   io.Reader = contents
+		algorithm           = archive.CompressionGzip
+	)
+	if backup != nil {
+		pipeline, err := transform.ForBackup(backup)
+		if err != nil {
+			return nil, err
+		}
+		if tarball, err = pipeline.Unwrap(contents); err != nil {
+			return nil, err
+		}
+		algorithm = archive.CompressionAlgorithmForBackup(backup)
+	} else {
+		var magic []byte
+		if magic, tarball, err = peekMagic(contents, len(zstdMagic)); err != nil {
+			return nil, err
+		}
+		if bytes.Equal(magic, zstdMagic) {
+			algorithm = archive.CompressionZstd
+		}
+	}
+
+	data, err := archive.ReadBackupMetadata(tarball, algorithm)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading backup metadata from tarball")
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDecoder(arkv1api.SchemeGroupVersion).Decode(data, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding backup metadata from tarball")
+	}
+	res, ok := obj.(*arkv1api.Backup)
+	if !ok {
+		return nil, errors.Errorf("unexpected type for backup metadata in tarball: %T", obj)
+	}
+
+	// the copy was written before the backup finished, but the backup's
+	// tarball is only uploaded once its items have all been backed up.
+	if res.Status.Phase == arkv1api.BackupPhaseInProgress {
+		res.Status.Phase = arkv1api.BackupPhaseCompleted
+	}
+
+	return res, nil
+}
+
+// repairContentIndex regenerates the backup's content index from its
+// tarball and uploads it.
+func repairContentIndex(store BackupStore, name string, backup *arkv1api.Backup) error {
+	pipeline, err := transform.ForBackup(backup)
+	if err != nil {
+		return err
+	}
+
+	contents, err := store.GetBackupContents(name)
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	untransformed, err := pipeline.Unwrap(contents)
+	if err != nil {
+		return err
+	}
+
+	index := new(bytes.Buffer)
+	if err := archive.WriteContentIndex(untransformed, archive.CompressionAlgorithmForBackup(backup), index); err != nil {
+		return errors.Wrap(err, "error generating content index")
+	}
+
+	return errors.Wrap(store.PutBackupContentIndex(name, index), "error uploading content index")
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
+	"github.com/heptio/ark/pkg/cloudprovider"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+// newTestTarball returns a gzipped tarball of files, keyed by their paths.
+func newTestTarball(t *testing.T, files map[string]string) []byte {
+	contents := new(bytes.Buffer)
+	gzw := gzip.NewWriter(contents)
+	tw := tar.NewWriter(gzw)
+	for path, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     path,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+			Mode:     0755,
+		}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	return contents.Bytes()
+}
+
+func TestRepairBackup(t *testing.T) {
+	inProgress := arktest.NewTestBackup().WithName("backup-1").WithPhase(api.BackupPhaseInProgress).WithLabel("team", "payments").Backup
+	inProgress.APIVersion, inProgress.Kind = api.SchemeGroupVersion.String(), "Backup"
+	inProgressJSON, err := json.Marshal(inProgress)
+	require.NoError(t, err)
+
+	completed := arktest.NewTestBackup().WithName("backup-1").WithPhase(api.BackupPhaseCompleted).Backup
+	completed.APIVersion, completed.Kind = api.SchemeGroupVersion.String(), "Backup"
+	completedJSON, err := json.Marshal(completed)
+	require.NoError(t, err)
+
+	tarballWithMetadata := newTestTarball(t, map[string]string{archive.BackupMetadataPath: string(inProgressJSON)})
+	tarball := newTestTarball(t, nil)
+	checksum := sha256.Sum256(tarball)
+
+	tests := []struct {
+		name                  string
+		storageData           cloudprovider.BucketData
+		backup                *api.Backup
+		expectedRepaired      []BackupArtifact
+		expectedUnrecoverable []BackupArtifact
+		expectedMetadata      *api.Backup
+	}{
+		{
+			name: "nothing missing",
+			storageData: map[string][]byte{
+				"backups/backup-1/ark-backup.json":        completedJSON,
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/ark-backup-complete":    {},
+				"backups/backup-1/backup-1-logs.gz":       {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+				"backups/backup-1/backup-1-summary.json":  []byte(`{"name":"backup-1"}`),
+			},
+			backup: completed,
+		},
+		{
+			name: "missing log is unrecoverable",
+			storageData: map[string][]byte{
+				"backups/backup-1/ark-backup.json":        completedJSON,
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/ark-backup-complete":    {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+				"backups/backup-1/backup-1-summary.json":  []byte(`{"name":"backup-1"}`),
+			},
+			backup:                completed,
+			expectedUnrecoverable: []BackupArtifact{BackupArtifactLog},
+		},
+		{
+			name: "missing checksum is recomputed from the tarball",
+			storageData: map[string][]byte{
+				"backups/backup-1/ark-backup.json":       completedJSON,
+				"backups/backup-1/backup-1.tar.gz":       tarball,
+				"backups/backup-1/ark-backup-complete":   {},
+				"backups/backup-1/backup-1-logs.gz":      {},
+				"backups/backup-1/backup-1-summary.json": []byte(`{"name":"backup-1"}`),
+			},
+			backup:           completed,
+			expectedRepaired: []BackupArtifact{BackupArtifactChecksum},
+		},
+		{
+			name: "missing summary is rebuilt from the metadata",
+			storageData: map[string][]byte{
+				"backups/backup-1/ark-backup.json":        completedJSON,
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/ark-backup-complete":    {},
+				"backups/backup-1/backup-1-logs.gz":       {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+			},
+			expectedRepaired: []BackupArtifact{BackupArtifactSummary},
+			expectedMetadata: completed,
+		},
+		{
+			name: "missing metadata and summary are rebuilt from the tarball",
+			storageData: map[string][]byte{
+				"backups/backup-1/backup-1.tar.gz":  tarballWithMetadata,
+				"backups/backup-1/backup-1-logs.gz": {},
+			},
+			expectedRepaired: []BackupArtifact{BackupArtifactChecksum, BackupArtifactMetadata, BackupArtifactSummary, BackupArtifactCompletionMarker},
+			expectedMetadata: arktest.NewTestBackup().WithName("backup-1").WithPhase(api.BackupPhaseCompleted).WithLabel("team", "payments").Backup,
+		},
+		{
+			name: "missing metadata is rebuilt from the backup if the tarball doesn't have a copy",
+			storageData: map[string][]byte{
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/ark-backup-complete":    {},
+				"backups/backup-1/backup-1-logs.gz":       {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+				"backups/backup-1/backup-1-summary.json":  []byte(`{"name":"backup-1"}`),
+			},
+			backup:           completed,
+			expectedRepaired: []BackupArtifact{BackupArtifactMetadata},
+			expectedMetadata: completed,
+		},
+		{
+			name: "missing metadata and summary are unrecoverable without a backup",
+			storageData: map[string][]byte{
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/ark-backup-complete":    {},
+				"backups/backup-1/backup-1-logs.gz":       {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+			},
+			expectedUnrecoverable: []BackupArtifact{BackupArtifactMetadata, BackupArtifactSummary},
+		},
+		{
+			name: "missing completion marker is uploaded once the tarball and metadata are present",
+			storageData: map[string][]byte{
+				"backups/backup-1/ark-backup.json":        completedJSON,
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/backup-1-logs.gz":       {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+				"backups/backup-1/backup-1-summary.json":  []byte(`{"name":"backup-1"}`),
+			},
+			backup:           completed,
+			expectedRepaired: []BackupArtifact{BackupArtifactCompletionMarker},
+		},
+		{
+			name: "missing completion marker isn't uploaded without metadata",
+			storageData: map[string][]byte{
+				"backups/backup-1/backup-1.tar.gz":        tarball,
+				"backups/backup-1/backup-1-logs.gz":       {},
+				"backups/backup-1/backup-1.tar.gz.sha256": []byte(hex.EncodeToString(checksum[:])),
+				"backups/backup-1/backup-1-summary.json":  []byte(`{"name":"backup-1"}`),
+			},
+			expectedUnrecoverable: []BackupArtifact{BackupArtifactMetadata, BackupArtifactCompletionMarker},
+		},
+		{
+			name: "nothing is re-uploaded when the tarball is missing",
+			storageData: map[string][]byte{
+				"backups/backup-1/backup-1-logs.gz": {},
+			},
+			backup:                completed,
+			expectedUnrecoverable: []BackupArtifact{BackupArtifactContents, BackupArtifactMetadata, BackupArtifactChecksum, BackupArtifactSummary, BackupArtifactCompletionMarker},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			harness := newObjectBackupStoreTestHarness("foo", "")
+
+			for key, obj := range tc.storageData {
+				require.NoError(t, harness.objectStore.PutObject(harness.bucket, key, bytes.NewReader(obj)))
+			}
+
+			res, err := RepairBackup(harness, "backup-1", tc.backup)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedRepaired, res.Repaired)
+			assert.Equal(t, tc.expectedUnrecoverable, res.Unrecoverable)
+
+			for _, artifact := range res.Repaired {
+				switch artifact {
+				case BackupArtifactChecksum:
+					stored, err := harness.getBackupChecksum("backup-1")
+					require.NoError(t, err)
+					contents := sha256.Sum256(harness.objectStore.Data[harness.bucket]["backups/backup-1/backup-1.tar.gz"])
+					assert.Equal(t, hex.EncodeToString(contents[:]), stored)
+				case BackupArtifactMetadata:
+					backup, err := harness.GetBackupMetadata("backup-1")
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedMetadata.Name, backup.Name)
+					assert.Equal(t, tc.expectedMetadata.Labels, backup.Labels)
+					assert.Equal(t, tc.expectedMetadata.Status.Phase, backup.Status.Phase)
+					assert.Contains(t, harness.objectStore.Data[harness.bucket], "metadata/revision")
+				case BackupArtifactCompletionMarker:
+					exists, err := harness.BackupExists("backup-1")
+					require.NoError(t, err)
+					assert.True(t, exists)
+				case BackupArtifactSummary:
+					summary, err := harness.GetBackupMetadataSummary("backup-1")
+					require.NoError(t, err)
+					assert.Equal(t, tc.expectedMetadata.Name, summary.Name)
+					assert.Equal(t, tc.expectedMetadata.Status.Phase, summary.Phase)
+				}
+			}
+		})
+	}
+}
+
+func TestRepairBackupRegeneratesContentIndex(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+
+	contents := newTestTarball(t, map[string]string{
+		"resources/pods/namespaces/ns-1/pod-1.json": `{"apiVersion":"v1","kind":"Pod"}`,
+	})
+	checksum := sha256.Sum256(contents)
+
+	backup := arktest.NewTestBackup().WithName("backup-1").WithAnnotation(api.ContentIndexVersionAnnotation, "1").Backup
+	backup.APIVersion, backup.Kind = api.SchemeGroupVersion.String(), "Backup"
+	metadata, err := json.Marshal(backup)
+	require.NoError(t, err)
+
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/backup-1/backup-1.tar.gz", bytes.NewReader(contents)))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/backup-1/backup-1.tar.gz.sha256", newStringReadSeeker(hex.EncodeToString(checksum[:]))))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/backup-1/ark-backup.json", bytes.NewReader(metadata)))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/backup-1/backup-1-summary.json", newStringReadSeeker(`{"name":"backup-1"}`)))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/backup-1/backup-1-logs.gz", newStringReadSeeker("")))
+	require.NoError(t, harness.objectStore.PutObject(harness.bucket, "backups/backup-1/ark-backup-complete", newStringReadSeeker("")))
+
+	// the content index is only regenerated for backups that had one, which
+	// is recorded in the stored metadata.
+	res, err := RepairBackup(harness, "backup-1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []BackupArtifact{BackupArtifactContentIndex}, res.Repaired)
+	assert.Empty(t, res.Unrecoverable)
+
+	index, err := harness.objectStore.GetObject(harness.bucket, "backups/backup-1/backup-1-index.txt")
+	require.NoError(t, err)
+	indexBytes, err := ioutil.ReadAll(index)
+	require.NoError(t, err)
+	assert.Equal(t, "# ark-content-index v1\n/v1/pods/ns-1/pod-1\n", string(indexBytes))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// BackupSummary is the part of a backup's metadata that's needed to list
+// it. It's stored in its own small object alongside the backup's full
+// metadata, so that many backups can be listed without downloading all of
+// their metadata.
+type BackupSummary struct {
+	Name                string               `json:"name"`
+	Namespace           string               `json:"namespace"`
+	Labels              map[string]string    `json:"labels,omitempty"`
+	Phase               arkv1api.BackupPhase `json:"phase"`
+	StorageLocation     string               `json:"storageLocation"`
+	Description         string               `json:"description,omitempty"`
+	FailureReason       string               `json:"failureReason,omitempty"`
+	Warnings            int                  `json:"warnings,omitempty"`
+	PartialFailures     int                  `json:"partialFailures,omitempty"`
+	ItemsBackedUp       int                  `json:"itemsBackedUp"`
+	StartTimestamp      metav1.Time          `json:"startTimestamp"`
+	CompletionTimestamp metav1.Time          `json:"completionTimestamp"`
+	Expiration          metav1.Time          `json:"expiration"`
+	TarballChecksum     string               `json:"tarballChecksum,omitempty"`
+}
+
+// NewBackupSummary returns backup's summary.
+func NewBackupSummary(backup *arkv1api.Backup) *BackupSummary {
+	summary := &BackupSummary{
+		Name:                backup.Name,
+		Namespace:           backup.Namespace,
+		Labels:              backup.Labels,
+		Phase:               backup.Status.Phase,
+		StorageLocation:     backup.Spec.StorageLocation,
+		Description:         backup.Status.Description,
+		FailureReason:       backup.Status.FailureReason,
+		Warnings:            backup.Status.Warnings,
+		PartialFailures:     len(backup.Status.PartialFailures),
+		StartTimestamp:      backup.Status.StartTimestamp,
+		CompletionTimestamp: backup.Status.CompletionTimestamp,
+		Expiration:          backup.Status.Expiration,
+		TarballChecksum:     backup.Status.TarballChecksum,
+	}
+	if backup.Status.Progress != nil {
+		summary.ItemsBackedUp = backup.Status.Progress.ItemsBackedUp
+	}
+
+	return summary
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"github.com/pkg/errors"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+const (
+	// BackupFormatVersion is the newest version of the format of the backups
+	// in a backup store, i.e. the layout of their tarballs and of the files
+	// uploaded alongside them, that this version of Ark can write and read.
+	BackupFormatVersion = IncrementalBackupFormatVersion
+
+	// InitialBackupFormatVersion is the format of full backups: a tarball
+	// holding every backed-up item.
+	InitialBackupFormatVersion = 1
+
+	// IncrementalBackupFormatVersion is the format of incremental backups,
+	// whose tarballs leave out the items that are unchanged since their base
+	// backup and list them instead. Older versions of Ark would restore only
+	// the changed items from them.
+	IncrementalBackupFormatVersion = 2
+)
+
+// BackupVersion returns the format version to record for backup: the
+// oldest one that can represent it, so that versions of Ark that don't
+// understand newer formats can still restore the backups that don't need
+// them.
+func BackupVersion(backup *arkv1api.Backup) int {
+	if backup.Spec.BaseBackup != "" {
+		return IncrementalBackupFormatVersion
+	}
+
+	return InitialBackupFormatVersion
+}
+
+// CheckBackupVersion returns an error if backup's format version is newer
+// than BackupFormatVersion, so it can't be read. Backups taken before the
+// version was recorded have a version of 0, and are read as version 1.
+func CheckBackupVersion(backup *arkv1api.Backup) error {
+	if backup.Status.Version > BackupFormatVersion {
+		return errors.Errorf("backup %s has format version %d, but this server only understands versions up to %d; upgrade Ark to restore it", backup.Name, backup.Status.Version, BackupFormatVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestBackupVersion(t *testing.T) {
+	full := arktest.NewTestBackup().WithName("backup-1").Backup
+	assert.Equal(t, InitialBackupFormatVersion, BackupVersion(full))
+
+	incremental := arktest.NewTestBackup().WithName("backup-2").Backup
+	incremental.Spec.BaseBackup = "backup-1"
+	assert.Equal(t, IncrementalBackupFormatVersion, BackupVersion(incremental))
+}
+
+func TestCheckBackupVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		expectedErr string
+	}{
+		{
+			name:    "backup taken before the version was recorded can be read",
+			version: 0,
+		},
+		{
+			name:    "initial version can be read",
+			version: InitialBackupFormatVersion,
+		},
+		{
+			name:    "current version can be read",
+			version: BackupFormatVersion,
+		},
+		{
+			name:        "newer version can't be read",
+			version:     BackupFormatVersion + 1,
+			expectedErr: "backup backup-1 has format version 3, but this server only understands versions up to 2; upgrade Ark to restore it",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backup := arktest.NewTestBackup().WithName("backup-1").WithVersion(test.version).Backup
+
+			err := CheckBackupVersion(backup)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
@@ -18,10 +18,12 @@ var _ = fmt.Errorf
 var _ = math.Inf
 
 type PutObjectRequest struct {
-	Plugin string `protobuf:"bytes,1,opt,name=plugin" json:"plugin,omitempty"`
-	Bucket string `protobuf:"bytes,2,opt,name=bucket" json:"bucket,omitempty"`
-	Key    string `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
-	Body   []byte `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	Plugin          string `protobuf:"bytes,1,opt,name=plugin" json:"plugin,omitempty"`
+	Bucket          string `protobuf:"bytes,2,opt,name=bucket" json:"bucket,omitempty"`
+	Key             string `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
+	Body            []byte `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	ContentType     string `protobuf:"bytes,5,opt,name=contentType" json:"contentType,omitempty"`
+	ContentEncoding string `protobuf:"bytes,6,opt,name=contentEncoding" json:"contentEncoding,omitempty"`
 }
 
 func (m *PutObjectRequest) Reset()                    { *m = PutObjectRequest{} }
@@ -57,6 +59,20 @@ func (m *PutObjectRequest) GetBody() []byte {
 	return nil
 }
 
+func (m *PutObjectRequest) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *PutObjectRequest) GetContentEncoding() string {
+	if m != nil {
+		return m.ContentEncoding
+	}
+	return ""
+}
+
 type GetObjectRequest struct {
 	Plugin string `protobuf:"bytes,1,opt,name=plugin" json:"plugin,omitempty"`
 	Bucket string `protobuf:"bytes,2,opt,name=bucket" json:"bucket,omitempty"`
@@ -645,35 +661,37 @@ var _ObjectStore_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("ObjectStore.proto", fileDescriptor2) }
 
 var fileDescriptor2 = []byte{
-	// 468 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0x51, 0x8b, 0xd3, 0x40,
-	0x10, 0x26, 0x26, 0x1e, 0x66, 0xae, 0x60, 0x9c, 0x83, 0x1a, 0x73, 0x2a, 0x75, 0x51, 0xa8, 0x08,
-	0xe5, 0xd0, 0x17, 0x1f, 0x7c, 0x10, 0x4f, 0x11, 0xa1, 0xe0, 0x91, 0x2a, 0xfa, 0xe0, 0x4b, 0x7a,
-	0x19, 0x7b, 0xb1, 0x69, 0x12, 0x37, 0x13, 0x30, 0x8f, 0xbe, 0xf9, 0xb3, 0x65, 0x37, 0x6b, 0x6f,
-	0xd3, 0xeb, 0x79, 0x70, 0xf4, 0x6d, 0x66, 0x76, 0xbe, 0x99, 0x2f, 0xbb, 0xdf, 0x17, 0xb8, 0xf3,
-	0x71, 0xfe, 0x83, 0x4e, 0x79, 0xc6, 0xa5, 0xa4, 0x49, 0x25, 0x4b, 0x2e, 0xd1, 0x5f, 0x50, 0x41,
-	0x32, 0x61, 0x4a, 0xa3, 0xc1, 0xec, 0x2c, 0x91, 0x94, 0x76, 0x07, 0xe2, 0x0c, 0x82, 0x93, 0x86,
-	0x3b, 0x40, 0x4c, 0x3f, 0x1b, 0xaa, 0x19, 0x87, 0xb0, 0x57, 0xe5, 0xcd, 0x22, 0x2b, 0x42, 0x67,
-	0xe4, 0x8c, 0xfd, 0xd8, 0x64, 0xaa, 0x3e, 0x6f, 0x4e, 0x97, 0xc4, 0xe1, 0x8d, 0xae, 0xde, 0x65,
-	0x18, 0x80, 0xbb, 0xa4, 0x36, 0x74, 0x75, 0x51, 0x85, 0x88, 0xe0, 0xcd, 0xcb, 0xb4, 0x0d, 0xbd,
-	0x91, 0x33, 0x1e, 0xc4, 0x3a, 0x16, 0x9f, 0x20, 0x78, 0x4f, 0xbb, 0xde, 0x24, 0x0e, 0xe1, 0xe6,
-	0x9b, 0x96, 0xa9, 0x56, 0x2b, 0xd3, 0x84, 0x13, 0x3d, 0x68, 0x10, 0xeb, 0x58, 0xfc, 0x76, 0xe0,
-	0xde, 0x34, 0xab, 0xf9, 0xb8, 0x5c, 0xad, 0xca, 0xe2, 0x44, 0xd2, 0xf7, 0xec, 0x17, 0xd5, 0xd7,
-	0x5d, 0x7e, 0x1f, 0xfc, 0x94, 0xf2, 0x6c, 0x95, 0x31, 0x49, 0x43, 0xe1, 0xbc, 0xa0, 0xa7, 0xe9,
-	0x05, 0xfa, 0xa3, 0xd5, 0x34, 0x9d, 0x89, 0x97, 0x10, 0x6d, 0xa3, 0x50, 0x57, 0x65, 0x51, 0x13,
-	0x46, 0x70, 0xab, 0x32, 0xb5, 0xd0, 0x19, 0xb9, 0x63, 0x3f, 0x5e, 0xe7, 0xe2, 0x1b, 0xa0, 0x42,
-	0x76, 0x37, 0x76, 0x6d, 0xd6, 0xe7, 0xbc, 0xdc, 0x1e, 0xaf, 0xa7, 0x70, 0xd0, 0x9b, 0x6e, 0x08,
-	0x21, 0x78, 0x4b, 0x6a, 0xff, 0x91, 0xd1, 0xb1, 0xf8, 0x02, 0x07, 0x6f, 0x29, 0x27, 0xa6, 0x5d,
-	0x3f, 0x5e, 0x0e, 0xc3, 0x63, 0x49, 0x09, 0xd3, 0x2c, 0x5b, 0x14, 0x94, 0x7e, 0x8e, 0xa7, 0xbb,
-	0x93, 0x60, 0x00, 0x2e, 0x73, 0xae, 0x1f, 0xc3, 0x8d, 0x55, 0x28, 0x9e, 0xc1, 0xdd, 0x0b, 0xdb,
-	0xcc, 0x57, 0x07, 0xe0, 0x36, 0x32, 0x37, 0xbb, 0x54, 0xf8, 0xfc, 0x8f, 0x07, 0xfb, 0x96, 0x8d,
-	0xf0, 0x08, 0xbc, 0x0f, 0x45, 0xc6, 0x38, 0x9c, 0xac, 0x9d, 0x34, 0x51, 0x05, 0x43, 0x38, 0x0a,
-	0xac, 0xfa, 0xbb, 0x55, 0xc5, 0x2d, 0xbe, 0x02, 0x7f, 0xed, 0x2c, 0x3c, 0xb4, 0x8e, 0x37, 0xfd,
-	0x76, 0x11, 0x3b, 0x76, 0x14, 0x7a, 0xed, 0x96, 0x1e, 0x7a, 0xd3, 0x43, 0x3d, 0xb4, 0xb6, 0xc2,
-	0x91, 0x83, 0x49, 0x27, 0x9d, 0xbe, 0xe8, 0xf0, 0xb1, 0xd5, 0x79, 0xa9, 0x2d, 0xa2, 0x27, 0x57,
-	0x74, 0x99, 0x2b, 0x9b, 0xc2, 0xbe, 0xa5, 0x1f, 0x7c, 0xb0, 0x81, 0xea, 0xab, 0x36, 0x7a, 0x78,
-	0xd9, 0xb1, 0x99, 0xf6, 0x1a, 0x06, 0xb6, 0xc4, 0xd0, 0xee, 0xdf, 0xa2, 0xbd, 0x2d, 0xd7, 0xfd,
-	0x15, 0x6e, 0x6f, 0xbc, 0x2e, 0x3e, 0xb2, 0x9a, 0xb6, 0xeb, 0x2c, 0x12, 0xff, 0x6b, 0xe9, 0xb8,
-	0xcd, 0xf7, 0xf4, 0x9f, 0xf2, 0xc5, 0xdf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x54, 0xac, 0xfe, 0xa7,
-	0x57, 0x05, 0x00, 0x00,
+	// 498 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x03, 0xad, 0x54, 0xc1, 0x6a, 0xdb, 0x40,
+	0x10, 0x45, 0x95, 0x63, 0xea, 0xb1, 0x21, 0xee, 0x06, 0x12, 0x55, 0x69, 0x4b, 0xba, 0xb4, 0xe0,
+	0x50, 0x30, 0xa1, 0xbd, 0xe4, 0xd0, 0x43, 0x48, 0x1a, 0x4a, 0xc1, 0xd0, 0x20, 0xa7, 0x24, 0x87,
+	0x5e, 0x64, 0x6b, 0xea, 0x28, 0x91, 0x77, 0x55, 0x69, 0x05, 0xd5, 0x31, 0xb7, 0x7c, 0x50, 0x3e,
+	0x30, 0xbb, 0xab, 0xad, 0xb3, 0x52, 0x9c, 0x16, 0x82, 0x6f, 0x33, 0x6f, 0xe7, 0xcd, 0x3e, 0xcd,
+	0xbe, 0x11, 0xbc, 0xf8, 0x3e, 0xb9, 0xc4, 0xa9, 0x18, 0x0b, 0x9e, 0xe1, 0x30, 0xcd, 0xb8, 0xe0,
+	0xa4, 0x33, 0x43, 0x86, 0x59, 0x28, 0x30, 0xf2, 0x7b, 0xe3, 0x8b, 0x30, 0xc3, 0xa8, 0x3a, 0xa0,
+	0xb7, 0x0e, 0xf4, 0x4f, 0x0a, 0x51, 0x31, 0x02, 0xfc, 0x5d, 0x60, 0x2e, 0xc8, 0x26, 0xb4, 0xd3,
+	0xa4, 0x98, 0xc5, 0xcc, 0x73, 0x76, 0x9c, 0x41, 0x27, 0x30, 0x99, 0xc2, 0x27, 0xc5, 0xf4, 0x0a,
+	0x85, 0xf7, 0xac, 0xc2, 0xab, 0x8c, 0xf4, 0xc1, 0xbd, 0xc2, 0xd2, 0x73, 0x35, 0xa8, 0x42, 0x42,
+	0xa0, 0x35, 0xe1, 0x51, 0xe9, 0xb5, 0x24, 0xd4, 0x0b, 0x74, 0x4c, 0x76, 0xa0, 0x3b, 0xe5, 0x4c,
+	0x20, 0x13, 0xa7, 0x65, 0x8a, 0xde, 0x9a, 0xae, 0xb6, 0x21, 0x32, 0x80, 0x75, 0x93, 0x1e, 0xb3,
+	0x29, 0x8f, 0x62, 0x36, 0xf3, 0xda, 0xba, 0xaa, 0x09, 0xd3, 0x53, 0xe8, 0x7f, 0xc5, 0x55, 0xab,
+	0xa6, 0xdb, 0xb0, 0x76, 0x58, 0x0a, 0xcc, 0x95, 0xfc, 0x28, 0x14, 0xa1, 0x6e, 0x24, 0xe5, 0xab,
+	0x98, 0x5e, 0x3b, 0xf0, 0x72, 0x14, 0xe7, 0xe2, 0x88, 0xcf, 0xe7, 0x9c, 0x9d, 0x64, 0xf8, 0x2b,
+	0xfe, 0x83, 0xf9, 0x53, 0x2f, 0x7f, 0x05, 0x9d, 0x08, 0x93, 0x78, 0x1e, 0x0b, 0xcc, 0x8c, 0x84,
+	0x7b, 0x40, 0x77, 0xd3, 0x17, 0xe8, 0x01, 0xaa, 0x6e, 0x3a, 0xa3, 0xfb, 0xe0, 0x2f, 0x93, 0x90,
+	0xa7, 0x9c, 0xe5, 0x48, 0x7c, 0x78, 0x9e, 0x1a, 0x4c, 0xaa, 0x70, 0x25, 0x6f, 0x91, 0xd3, 0x9f,
+	0x40, 0x14, 0xb3, 0x9a, 0xd8, 0x93, 0x55, 0xdf, 0xeb, 0x72, 0x6b, 0xba, 0x76, 0x61, 0xa3, 0xd6,
+	0xdd, 0x08, 0x92, 0x63, 0x94, 0x63, 0xfd, 0x2b, 0x46, 0xc7, 0xf4, 0x0c, 0x36, 0xbe, 0x60, 0x82,
+	0x02, 0x57, 0xfd, 0x78, 0x09, 0x6c, 0x1e, 0x65, 0x28, 0x2d, 0x3e, 0x8e, 0x67, 0x0c, 0xa3, 0x1f,
+	0xc1, 0x68, 0x75, 0x76, 0x96, 0x88, 0x10, 0x89, 0x7e, 0x0c, 0x37, 0x50, 0x21, 0xfd, 0x00, 0x5b,
+	0x0f, 0x6e, 0x33, 0x5f, 0x2d, 0x8b, 0x8b, 0x2c, 0x31, 0x77, 0xa9, 0xf0, 0xe3, 0x4d, 0x0b, 0xba,
+	0xd6, 0x4e, 0x92, 0x3d, 0x68, 0x7d, 0x63, 0xb1, 0x14, 0x36, 0x5c, 0xac, 0xe5, 0x50, 0x01, 0x46,
+	0xb0, 0xdf, 0xb7, 0xf0, 0xe3, 0x79, 0x2a, 0x4a, 0xf2, 0x19, 0x3a, 0x8b, 0x2d, 0x25, 0xdb, 0xd6,
+	0x71, 0x73, 0x77, 0x1f, 0x72, 0x07, 0x8e, 0x62, 0x2f, 0xb6, 0xa5, 0xc6, 0x6e, 0xee, 0x50, 0x8d,
+	0xad, 0x57, 0x61, 0xcf, 0x21, 0x61, 0x65, 0x9d, 0xba, 0xe9, 0xc8, 0x3b, 0xab, 0xf2, 0xd1, 0xb5,
+	0xf0, 0xdf, 0xff, 0xa7, 0xca, 0x8c, 0x6c, 0x04, 0x5d, 0xcb, 0x3f, 0xe4, 0x75, 0x83, 0x55, 0x77,
+	0xad, 0xff, 0xe6, 0xb1, 0x63, 0xd3, 0xed, 0x00, 0x7a, 0xb6, 0xc5, 0x88, 0x5d, 0xbf, 0xc4, 0x7b,
+	0x4b, 0xc6, 0x7d, 0x0e, 0xeb, 0x8d, 0xd7, 0x25, 0x6f, 0xad, 0xa2, 0xe5, 0x3e, 0xf3, 0xe9, 0xbf,
+	0x4a, 0x2a, 0x6d, 0x93, 0xb6, 0xfe, 0xed, 0x7e, 0xba, 0x03, 0x7d, 0x7e, 0x00, 0x90, 0xa4, 0x05,
+	0x00, 0x00,
 }
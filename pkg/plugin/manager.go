@@ -17,6 +17,7 @@ limitations under the License.
 package plugin
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/sirupsen/logrus"
@@ -46,6 +47,10 @@ type Manager interface {
 	// GetRestoreItemAction returns the restore item action plugin for name.
 	GetRestoreItemAction(name string) (restore.ItemAction, error)
 
+	// GetPluginVersions returns the versions of all available plugins, keyed
+	// by "<kind>/<name>".
+	GetPluginVersions() map[string]string
+
 	// CleanupClients terminates all of the Manager's running plugin processes.
 	CleanupClients()
 }
@@ -208,3 +213,17 @@ func (m *manager) GetRestoreItemAction(name string) (restore.ItemAction, error)
 	r := newRestartableRestoreItemAction(name, restartableProcess)
 	return r, nil
 }
+
+// GetPluginVersions returns the versions of all available plugins, keyed
+// by "<kind>/<name>".
+func (m *manager) GetPluginVersions() map[string]string {
+	versions := make(map[string]string)
+
+	for _, kind := range allPluginKinds.List() {
+		for _, id := range m.registry.List(PluginKind(kind)) {
+			versions[fmt.Sprintf("%s/%s", id.Kind, id.Name)] = id.Version
+		}
+	}
+
+	return versions
+}
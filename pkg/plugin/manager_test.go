@@ -473,3 +473,28 @@ func TestGetRestoreItemActions(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPluginVersions(t *testing.T) {
+	registry := &mockRegistry{}
+	defer registry.AssertExpectations(t)
+
+	m := NewManager(test.NewLogger(), logrus.InfoLevel, registry)
+
+	registry.On("List", PluginKindObjectStore).Return([]PluginIdentifier{
+		{Kind: PluginKindObjectStore, Name: "aws", Command: "/ark", Version: "v0.10.0"},
+	})
+	registry.On("List", PluginKindBlockStore).Return([]PluginIdentifier{
+		{Kind: PluginKindBlockStore, Name: "aws", Command: "/ark", Version: "v0.10.0"},
+	})
+	registry.On("List", PluginKindBackupItemAction).Return([]PluginIdentifier{
+		{Kind: PluginKindBackupItemAction, Name: "custom", Command: "/plugins/custom", Version: "sha256:abc"},
+	})
+	registry.On("List", PluginKindRestoreItemAction).Return([]PluginIdentifier{})
+
+	expected := map[string]string{
+		"ObjectStore/aws":         "v0.10.0",
+		"BlockStore/aws":          "v0.10.0",
+		"BackupItemAction/custom": "sha256:abc",
+	}
+	assert.Equal(t, expected, m.GetPluginVersions())
+}
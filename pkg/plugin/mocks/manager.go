@@ -124,6 +124,22 @@ func (_m *Manager) GetObjectStore(name string) (cloudprovider.ObjectStore, error
 	return r0, r1
 }
 
+// GetPluginVersions provides a mock function with given fields:
+func (_m *Manager) GetPluginVersions() map[string]string {
+	ret := _m.Called()
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func() map[string]string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	return r0
+}
+
 // GetRestoreItemAction provides a mock function with given fields: name
 func (_m *Manager) GetRestoreItemAction(name string) (restore.ItemAction, error) {
 	ret := _m.Called(name)
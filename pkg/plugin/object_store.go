@@ -82,6 +82,13 @@ func (c *ObjectStoreGRPCClient) Init(config map[string]string) error {
 // PutObject creates a new object using the data in body within the specified
 // object storage bucket with the given key.
 func (c *ObjectStoreGRPCClient) PutObject(bucket, key string, body io.Reader) error {
+	return c.PutObjectWithMetadata(bucket, key, body, cloudprovider.ObjectMetadata{})
+}
+
+// PutObjectWithMetadata is like PutObject, except that the object is stored
+// with metadata. The metadata is sent with the first chunk of the body, and
+// is ignored by plugins whose object stores don't support it.
+func (c *ObjectStoreGRPCClient) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
 	stream, err := c.grpcClient.PutObject(context.Background())
 	if err != nil {
 		return err
@@ -90,6 +97,7 @@ func (c *ObjectStoreGRPCClient) PutObject(bucket, key string, body io.Reader) er
 	// read from the provider io.Reader into chunks, and send each one over
 	// the gRPC stream
 	chunk := make([]byte, byteChunkSize)
+	first := true
 	for {
 		n, err := body.Read(chunk)
 		if err == io.EOF {
@@ -101,7 +109,14 @@ func (c *ObjectStoreGRPCClient) PutObject(bucket, key string, body io.Reader) er
 			return err
 		}
 
-		if err := stream.Send(&proto.PutObjectRequest{Plugin: c.plugin, Bucket: bucket, Key: key, Body: chunk[0:n]}); err != nil {
+		req := &proto.PutObjectRequest{Plugin: c.plugin, Bucket: bucket, Key: key, Body: chunk[0:n]}
+		if first {
+			req.ContentType = metadata.ContentType
+			req.ContentEncoding = metadata.ContentEncoding
+			first = false
+		}
+
+		if err := stream.Send(req); err != nil {
 			return err
 		}
 	}
@@ -246,6 +261,10 @@ func (s *ObjectStoreGRPCServer) PutObject(stream proto.ObjectStore_PutObjectServ
 
 	bucket := firstChunk.Bucket
 	key := firstChunk.Key
+	metadata := cloudprovider.ObjectMetadata{
+		ContentType:     firstChunk.ContentType,
+		ContentEncoding: firstChunk.ContentEncoding,
+	}
 
 	receive := func() ([]byte, error) {
 		if firstChunk != nil {
@@ -265,7 +284,7 @@ func (s *ObjectStoreGRPCServer) PutObject(stream proto.ObjectStore_PutObjectServ
 		return nil
 	}
 
-	if err := impl.PutObject(bucket, key, &StreamReadCloser{receive: receive, close: close}); err != nil {
+	if err := cloudprovider.PutObjectWithMetadata(impl, bucket, key, &StreamReadCloser{receive: receive, close: close}, metadata); err != nil {
 		return err
 	}
 
@@ -28,6 +28,9 @@ type PluginIdentifier struct {
 	Command string
 	Kind    PluginKind
 	Name    string
+	// Version identifies the build of the plugin's command. It's set by
+	// the registry and isn't sent over the plugin protocol.
+	Version string
 }
 
 // PluginLister lists plugins.
@@ -16,10 +16,12 @@ limitations under the License.
 package plugin
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/heptio/ark/pkg/buildinfo"
 	"github.com/heptio/ark/pkg/util/filesystem"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -89,11 +91,16 @@ func (r *registry) discoverPlugins(commands []string) error {
 			return err
 		}
 
+		version := r.commandVersion(command)
+
 		for _, plugin := range plugins {
+			plugin.Version = version
+
 			r.logger.WithFields(logrus.Fields{
 				"kind":    plugin.Kind,
 				"name":    plugin.Name,
 				"command": command,
+				"version": version,
 			}).Info("registering plugin")
 
 			if err := r.register(plugin); err != nil {
@@ -105,6 +112,24 @@ func (r *registry) discoverPlugins(commands []string) error {
 	return nil
 }
 
+// commandVersion returns a string identifying the build of a plugin command.
+// The plugin protocol doesn't carry version information, so Ark's own
+// version is used for its internal plugins, and a digest of the executable
+// is used for everything else.
+func (r *registry) commandVersion(command string) string {
+	if command == os.Args[0] && buildinfo.Version != "" {
+		return buildinfo.Version
+	}
+
+	data, err := r.fs.ReadFile(command)
+	if err != nil {
+		r.logger.WithError(errors.WithStack(err)).WithField("command", command).Warn("Unable to read plugin executable to determine its version")
+		return ""
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
 // List returns info about all plugin binaries that implement the given
 // PluginKind.
 func (r *registry) List(kind PluginKind) []PluginIdentifier {
@@ -40,6 +40,11 @@ func newRestartableBackupItemAction(name string, sharedPluginProcess Restartable
 	return r
 }
 
+// Name returns the name the backup item action is registered under.
+func (r *restartableBackupItemAction) Name() string {
+	return r.key.name
+}
+
 // getBackupItemAction returns the backup item action for this restartableBackupItemAction. It does *not* restart the
 // plugin process.
 func (r *restartableBackupItemAction) getBackupItemAction() (backup.ItemAction, error) {
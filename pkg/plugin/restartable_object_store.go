@@ -117,6 +117,15 @@ func (r *restartableObjectStore) PutObject(bucket string, key string, body io.Re
 	return delegate.PutObject(bucket, key, body)
 }
 
+// PutObjectWithMetadata restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) PutObjectWithMetadata(bucket string, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
+	delegate, err := r.getDelegate()
+	if err != nil {
+		return err
+	}
+	return cloudprovider.PutObjectWithMetadata(delegate, bucket, key, body, metadata)
+}
+
 // GetObject restarts the plugin's process if needed, then delegates the call.
 func (r *restartableObjectStore) GetObject(bucket string, key string) (io.ReadCloser, error) {
 	delegate, err := r.getDelegate()
@@ -17,6 +17,7 @@ limitations under the License.
 package plugin
 
 import (
+	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -26,6 +27,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/heptio/ark/pkg/cloudprovider"
 	cloudprovidermocks "github.com/heptio/ark/pkg/cloudprovider/mocks"
 )
 
@@ -233,3 +235,48 @@ func TestRestartableObjectStoreDelegatedFunctions(t *testing.T) {
 		},
 	)
 }
+
+// metadataObjectStore is a mock object store that supports putting objects
+// with metadata.
+type metadataObjectStore struct {
+	*cloudprovidermocks.ObjectStore
+
+	metadata cloudprovider.ObjectMetadata
+}
+
+func (o *metadataObjectStore) PutObjectWithMetadata(bucket, key string, body io.Reader, metadata cloudprovider.ObjectMetadata) error {
+	o.metadata = metadata
+	return nil
+}
+
+func TestRestartableObjectStorePutObjectWithMetadata(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+	p.On("resetIfNeeded").Return(nil)
+
+	metadata := cloudprovider.ObjectMetadata{ContentType: "application/json"}
+	body := strings.NewReader("body")
+
+	// a delegate that can't store metadata puts the object without it.
+	objectStore := new(cloudprovidermocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	objectStore.On("PutObject", "bucket", "key", body).Return(nil)
+	p.On("getByKindAndName", key).Return(objectStore, nil).Once()
+
+	assert.NoError(t, r.PutObjectWithMetadata("bucket", "key", body, metadata))
+
+	// one that can is passed the metadata.
+	metadataStore := &metadataObjectStore{ObjectStore: new(cloudprovidermocks.ObjectStore)}
+	p.On("getByKindAndName", key).Return(metadataStore, nil).Once()
+
+	assert.NoError(t, r.PutObjectWithMetadata("bucket", "key", body, metadata))
+	assert.Equal(t, metadata, metadataStore.metadata)
+}
@@ -33,7 +33,9 @@ import (
 	"github.com/heptio/ark/pkg/util/collections"
 )
 
-const defaultTimeout = 30 * time.Second
+// DefaultTimeout is how long a hook is allowed to run if its timeout
+// isn't specified.
+const DefaultTimeout = 30 * time.Second
 
 // PodCommandExecutor is capable of executing a command in a container in a pod.
 type PodCommandExecutor interface {
@@ -105,7 +107,7 @@ func (e *defaultPodCommandExecutor) ExecutePodCommand(log logrus.FieldLogger, it
 	}
 
 	if hook.Timeout.Duration == 0 {
-		hook.Timeout.Duration = defaultTimeout
+		hook.Timeout.Duration = DefaultTimeout
 	}
 
 	hookLog := log.WithFields(
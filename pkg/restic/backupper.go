@@ -34,8 +34,8 @@ import (
 
 // Backupper can execute restic backups of volumes in a pod.
 type Backupper interface {
-	// BackupPodVolumes backs up all annotated volumes in a pod.
-	BackupPodVolumes(backup *arkv1api.Backup, pod *corev1api.Pod, log logrus.FieldLogger) (map[string]string, []error)
+	// BackupPodVolumes backs up the specified volumes in a pod.
+	BackupPodVolumes(backup *arkv1api.Backup, pod *corev1api.Pod, volumesToBackup []string, log logrus.FieldLogger) (map[string]string, []error)
 }
 
 type backupper struct {
@@ -89,9 +89,7 @@ func resultsKey(ns, name string) string {
 	return fmt.Sprintf("%s/%s", ns, name)
 }
 
-func (b *backupper) BackupPodVolumes(backup *arkv1api.Backup, pod *corev1api.Pod, log logrus.FieldLogger) (map[string]string, []error) {
-	// get volumes to backup from pod's annotations
-	volumesToBackup := GetVolumesToBackup(pod)
+func (b *backupper) BackupPodVolumes(backup *arkv1api.Backup, pod *corev1api.Pod, volumesToBackup []string, log logrus.FieldLogger) (map[string]string, []error) {
 	if len(volumesToBackup) == 0 {
 		return nil, nil
 	}
@@ -12,13 +12,13 @@ type Backupper struct {
 	mock.Mock
 }
 
-// BackupPodVolumes provides a mock function with given fields: backup, pod, log
-func (_m *Backupper) BackupPodVolumes(backup *v1.Backup, pod *corev1.Pod, log logrus.FieldLogger) (map[string]string, []error) {
-	ret := _m.Called(backup, pod, log)
+// BackupPodVolumes provides a mock function with given fields: backup, pod, volumesToBackup, log
+func (_m *Backupper) BackupPodVolumes(backup *v1.Backup, pod *corev1.Pod, volumesToBackup []string, log logrus.FieldLogger) (map[string]string, []error) {
+	ret := _m.Called(backup, pod, volumesToBackup, log)
 
 	var r0 map[string]string
-	if rf, ok := ret.Get(0).(func(*v1.Backup, *corev1.Pod, logrus.FieldLogger) map[string]string); ok {
-		r0 = rf(backup, pod, log)
+	if rf, ok := ret.Get(0).(func(*v1.Backup, *corev1.Pod, []string, logrus.FieldLogger) map[string]string); ok {
+		r0 = rf(backup, pod, volumesToBackup, log)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(map[string]string)
@@ -26,8 +26,8 @@ func (_m *Backupper) BackupPodVolumes(backup *v1.Backup, pod *corev1.Pod, log lo
 	}
 
 	var r1 []error
-	if rf, ok := ret.Get(1).(func(*v1.Backup, *corev1.Pod, logrus.FieldLogger) []error); ok {
-		r1 = rf(backup, pod, log)
+	if rf, ok := ret.Get(1).(func(*v1.Backup, *corev1.Pod, []string, logrus.FieldLogger) []error); ok {
+		r1 = rf(backup, pod, volumesToBackup, log)
 	} else {
 		if ret.Get(1) != nil {
 			r1 = ret.Get(1).([]error)
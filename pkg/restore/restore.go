@@ -18,7 +18,6 @@ package restore
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	go_context "context"
 	"encoding/json"
 	"fmt"
@@ -27,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -41,12 +41,15 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	kubeerrs "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	"github.com/heptio/ark/pkg/archive"
 	"github.com/heptio/ark/pkg/client"
 	"github.com/heptio/ark/pkg/cloudprovider"
 	"github.com/heptio/ark/pkg/discovery"
@@ -66,6 +69,10 @@ type Restorer interface {
 	Restore(log logrus.FieldLogger, restore *api.Restore, backup *api.Backup, backupReader io.Reader, actions []ItemAction) (api.RestoreResult, api.RestoreResult)
 }
 
+// recreateTimeout is how long to wait for an existing item to be deleted
+// when recreating it.
+const recreateTimeout = time.Minute
+
 type gvString string
 type kindString string
 
@@ -232,6 +239,7 @@ func (kr *kubernetesRestorer) Restore(log logrus.FieldLogger, restore *api.Resto
 		backupReader:         backupReader,
 		restore:              restore,
 		prioritizedResources: prioritizedResources,
+		resourceFilter:       resourceIncludesExcludes,
 		selector:             selector,
 		log:                  log,
 		dynamicFactory:       kr.dynamicFactory,
@@ -244,7 +252,39 @@ func (kr *kubernetesRestorer) Restore(log logrus.FieldLogger, restore *api.Resto
 		pvRestorer:           pvRestorer,
 	}
 
-	return restoreCtx.execute()
+	warnings, errs := restoreCtx.execute()
+
+	for _, gr := range unservedGroupResources(backup.Status.GroupVersions, kr.discoveryHelper.APIGroups()) {
+		if !resourceIncludesExcludes.ShouldInclude(gr) {
+			continue
+		}
+		addArkError(&warnings, errors.Errorf("%s were backed up at %s, which the cluster no longer serves", gr, backup.Status.GroupVersions[gr]))
+	}
+
+	return warnings, errs
+}
+
+// unservedGroupResources returns, sorted, the group-resources in
+// groupVersions, a backup's record of the group version each resource was
+// backed up at, whose group version isn't one of apiGroups' versions.
+func unservedGroupResources(groupVersions map[string]string, apiGroups []metav1.APIGroup) []string {
+	served := sets.NewString()
+	for _, group := range apiGroups {
+		served.Insert(group.PreferredVersion.GroupVersion)
+		for _, version := range group.Versions {
+			served.Insert(version.GroupVersion)
+		}
+	}
+
+	var unserved []string
+	for gr, gv := range groupVersions {
+		if !served.Has(gv) {
+			unserved = append(unserved, gr)
+		}
+	}
+	sort.Strings(unserved)
+
+	return unserved
 }
 
 // getResourceIncludesExcludes takes the lists of resources to include and exclude, uses the
@@ -313,6 +353,7 @@ type context struct {
 	backupReader         io.Reader
 	restore              *api.Restore
 	prioritizedResources []schema.GroupResource
+	resourceFilter       *collections.IncludesExcludes
 	selector             labels.Selector
 	log                  logrus.FieldLogger
 	dynamicFactory       client.DynamicFactory
@@ -326,6 +367,9 @@ type context struct {
 	resourceWatches      []watch.Interface
 	pvsToProvision       sets.String
 	pvRestorer           PVRestorer
+	// itemCounts tracks, by group-resource, how many items were restored
+	// or intentionally skipped, for verifying item counts.
+	itemCounts map[string]*api.RestoreItemCount
 }
 
 func (ctx *context) execute() (api.RestoreResult, api.RestoreResult) {
@@ -338,7 +382,96 @@ func (ctx *context) execute() (api.RestoreResult, api.RestoreResult) {
 	}
 	defer ctx.fileSystem.RemoveAll(dir)
 
-	return ctx.restoreFromDir(dir)
+	warnings, errs := ctx.restoreFromDir(dir)
+
+	if ctx.restore.Spec.VerifyItemCounts {
+		ctx.restore.Status.ItemCounts = ctx.sortedItemCounts()
+	}
+
+	return warnings, errs
+}
+
+// itemCount returns the item counts for the given group-resource.
+func (ctx *context) itemCount(resource string) *api.RestoreItemCount {
+	if ctx.itemCounts == nil {
+		ctx.itemCounts = make(map[string]*api.RestoreItemCount)
+	}
+
+	count, found := ctx.itemCounts[resource]
+	if !found {
+		count = &api.RestoreItemCount{GroupResource: resource}
+		ctx.itemCounts[resource] = count
+	}
+	return count
+}
+
+// sortedItemCounts returns the item counts for all group-resources, sorted
+// by group-resource.
+func (ctx *context) sortedItemCounts() []api.RestoreItemCount {
+	var counts []api.RestoreItemCount
+	for _, count := range ctx.itemCounts {
+		counts = append(counts, *count)
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].GroupResource < counts[j].GroupResource
+	})
+
+	return counts
+}
+
+// countFiles returns the number of files (i.e. items) in dir. Directories
+// are only read if the restore's item counts are being verified.
+func (ctx *context) countFiles(dir string) int {
+	if !ctx.restore.Spec.VerifyItemCounts {
+		return 0
+	}
+
+	files, err := ctx.fileSystem.ReadDir(dir)
+	if err != nil {
+		ctx.log.WithError(errors.WithStack(err)).Warnf("Unable to count items in %s", dir)
+		return 0
+	}
+
+	count := 0
+	for _, file := range files {
+		if !file.IsDir() {
+			count++
+		}
+	}
+	return count
+}
+
+// countResourceFiles returns the number of items, cluster-scoped or in any
+// namespace, in a resource's directory. Like countFiles, it returns zero
+// unless the restore's item counts are being verified.
+func (ctx *context) countResourceFiles(resourcePath string) int {
+	if !ctx.restore.Spec.VerifyItemCounts {
+		return 0
+	}
+
+	count := 0
+
+	if exists, _ := ctx.fileSystem.DirExists(filepath.Join(resourcePath, api.ClusterScopedDir)); exists {
+		count += ctx.countFiles(filepath.Join(resourcePath, api.ClusterScopedDir))
+	}
+
+	nsSubDir := filepath.Join(resourcePath, api.NamespaceScopedDir)
+	if exists, _ := ctx.fileSystem.DirExists(nsSubDir); exists {
+		nsDirs, err := ctx.fileSystem.ReadDir(nsSubDir)
+		if err != nil {
+			ctx.log.WithError(errors.WithStack(err)).Warnf("Unable to count items in %s", nsSubDir)
+			return count
+		}
+
+		for _, nsDir := range nsDirs {
+			if nsDir.IsDir() {
+				count += ctx.countFiles(filepath.Join(nsSubDir, nsDir.Name()))
+			}
+		}
+	}
+
+	return count
 }
 
 // restoreFromDir executes a restore based on backup data contained within a local
@@ -375,6 +508,19 @@ func (ctx *context) restoreFromDir(dir string) (api.RestoreResult, api.RestoreRe
 		resourceDirsMap[rscName] = rscDir
 	}
 
+	// count the items of resources excluded by the restore as skipped. The
+	// items of resources that are included but don't exist in the cluster
+	// aren't, since they're silently not restored.
+	prioritized := sets.NewString()
+	for _, resource := range ctx.prioritizedResources {
+		prioritized.Insert(resource.String())
+	}
+	for name := range resourceDirsMap {
+		if !prioritized.Has(name) && name != kuberesource.Namespaces.String() && ctx.resourceFilter != nil && !ctx.resourceFilter.ShouldInclude(name) {
+			ctx.itemCount(name).Skipped += ctx.countResourceFiles(filepath.Join(resourcesDir, name))
+		}
+	}
+
 	existingNamespaces := sets.NewString()
 
 	// TODO this is not optimal since it'll keep watches open for all resources/namespaces
@@ -439,6 +585,7 @@ func (ctx *context) restoreFromDir(dir string) (api.RestoreResult, api.RestoreRe
 
 			if !namespaceFilter.ShouldInclude(nsName) {
 				ctx.log.Infof("Skipping namespace %s", nsName)
+				ctx.itemCount(resource.String()).Skipped += ctx.countFiles(nsPath)
 				continue
 			}
 
@@ -566,6 +713,7 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 
 	if ctx.restore.Spec.IncludeClusterResources != nil && !*ctx.restore.Spec.IncludeClusterResources && namespace == "" {
 		ctx.log.Infof("Skipping resource %s because it's cluster-scoped", resource)
+		ctx.itemCount(resource).Skipped += ctx.countFiles(resourcePath)
 		return warnings, errs
 	}
 
@@ -614,6 +762,7 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 		}
 
 		if !ctx.selector.Matches(labels.Set(obj.GetLabels())) {
+			ctx.itemCount(resource).Skipped++
 			continue
 		}
 
@@ -624,6 +773,7 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 		}
 		if complete {
 			ctx.log.Infof("%s is complete - skipping", kube.NamespaceAndName(obj))
+			ctx.itemCount(resource).Skipped++
 			continue
 		}
 
@@ -650,6 +800,7 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 		// TODO: move to restore item action if/when we add a ShouldRestore() method to the interface
 		if groupResource == kuberesource.Pods && obj.GetAnnotations()[v1.MirrorPodAnnotationKey] != "" {
 			ctx.log.Infof("Not restoring pod because it's a mirror pod")
+			ctx.itemCount(resource).Skipped++
 			continue
 		}
 
@@ -660,6 +811,16 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 				ctx.log.Infof("Not restoring PV because it doesn't have a snapshot and its reclaim policy is Delete.")
 
 				ctx.pvsToProvision.Insert(name)
+				ctx.itemCount(resource).Skipped++
+
+				continue
+			}
+
+			if ctx.backup.Status.VolumeBackupMethods[name] == api.VolumeBackupMethodFileCopy {
+				ctx.log.Infof("Not restoring PV because its files were copied, so they'll be restored into a newly-provisioned volume.")
+
+				ctx.pvsToProvision.Insert(name)
+				ctx.itemCount(resource).Skipped++
 
 				continue
 			}
@@ -699,7 +860,7 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 			}
 
 			if volumeName, exists := spec["volumeName"]; exists && ctx.pvsToProvision.Has(volumeName.(string)) {
-				ctx.log.Infof("Resetting PersistentVolumeClaim %s/%s for dynamic provisioning because its PV %v has a reclaim policy of Delete", namespace, name, volumeName)
+				ctx.log.Infof("Resetting PersistentVolumeClaim %s/%s for dynamic provisioning because its PV %v isn't being restored", namespace, name, volumeName)
 
 				delete(spec, "volumeName")
 
@@ -756,12 +917,16 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 		ctx.log.Infof("Restoring %s: %v", obj.GroupVersionKind().Kind, name)
 		createdObj, restoreErr := resourceClient.Create(obj)
 		if apierrors.IsAlreadyExists(restoreErr) {
+			ctx.itemCount(resource).Restored++
+
 			fromCluster, err := resourceClient.Get(name, metav1.GetOptions{})
 			if err != nil {
 				ctx.log.Infof("Error retrieving cluster version of %s: %v", kube.NamespaceAndName(obj), err)
 				addToResult(&warnings, namespace, err)
 				continue
 			}
+			existingUID := fromCluster.GetUID()
+
 			// Remove insubstantial metadata
 			fromCluster, err = resetMetadataAndStatus(fromCluster)
 			if err != nil {
@@ -804,8 +969,19 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 						ctx.log.Infof("ServiceAccount %s successfully updated", kube.NamespaceAndName(obj))
 					}
 				default:
-					e := errors.Errorf("not restored: %s and is different from backed up version.", restoreErr)
-					addToResult(&warnings, namespace, e)
+					if ctx.restore.Spec.ExistingResourcePolicy != api.ExistingResourcePolicyUpdate {
+						e := errors.Errorf("not restored: %s and is different from backed up version.", restoreErr)
+						addToResult(&warnings, namespace, e)
+						continue
+					}
+
+					warning, err := ctx.updateExisting(resourceClient, groupResource, existingUID, fromCluster, obj)
+					if warning != nil {
+						addToResult(&warnings, namespace, warning)
+					}
+					if err != nil {
+						addToResult(&errs, namespace, err)
+					}
 				}
 			}
 			continue
@@ -816,6 +992,7 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 			addToResult(&errs, namespace, fmt.Errorf("error restoring %s: %v", fullPath, restoreErr))
 			continue
 		}
+		ctx.itemCount(resource).Restored++
 
 		if groupResource == kuberesource.Pods && len(restic.GetPodSnapshotAnnotations(obj)) > 0 {
 			if ctx.resticRestorer == nil {
@@ -842,6 +1019,90 @@ func (ctx *context) restoreResource(resource, namespace, resourcePath string) (a
 	return warnings, errs
 }
 
+// updateExisting patches an item that already exists in the cluster to match
+// its backed-up version. If the patch is rejected because immutable fields
+// differ, the restore's ImmutableFieldPolicy is applied, and the outcome is
+// returned as a warning or an error.
+func (ctx *context) updateExisting(resourceClient client.Dynamic, groupResource schema.GroupResource, existingUID types.UID, fromCluster, obj *unstructured.Unstructured) (warning, err error) {
+	patchBytes, err := generatePatch(fromCluster, obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating patch for %s", kube.NamespaceAndName(obj))
+	}
+	if patchBytes == nil {
+		return nil, nil
+	}
+
+	_, patchErr := resourceClient.Patch(obj.GetName(), patchBytes)
+	if patchErr == nil {
+		ctx.log.Infof("%s %s successfully updated", obj.GetKind(), kube.NamespaceAndName(obj))
+		return nil, nil
+	}
+	if !isImmutableFieldError(patchErr) {
+		return nil, errors.Errorf("error updating %s: %v", kube.NamespaceAndName(obj), patchErr)
+	}
+
+	policy := ctx.restore.Spec.ImmutableFieldPolicy
+	if policy == api.ImmutableFieldPolicyRecreate && (groupResource == kuberesource.PersistentVolumes || groupResource == kuberesource.PersistentVolumeClaims) {
+		ctx.log.Infof("Not recreating %s %s because deleting it could delete its volume", obj.GetKind(), kube.NamespaceAndName(obj))
+		policy = api.ImmutableFieldPolicySkip
+	}
+
+	ctx.log.Infof("Applying immutable field policy %q to %s %s", policy, obj.GetKind(), kube.NamespaceAndName(obj))
+
+	switch policy {
+	case api.ImmutableFieldPolicyRecreate:
+		if err := ctx.recreate(resourceClient, existingUID, obj); err != nil {
+			return nil, err
+		}
+		return errors.Errorf("recreated %s because its immutable fields differ from the backed up version: %v", kube.NamespaceAndName(obj), patchErr), nil
+	case api.ImmutableFieldPolicyFail:
+		return nil, errors.Errorf("not updated: immutable fields of %s differ from the backed up version: %v", kube.NamespaceAndName(obj), patchErr)
+	default:
+		return errors.Errorf("not updated: immutable fields of %s differ from the backed up version: %v", kube.NamespaceAndName(obj), patchErr), nil
+	}
+}
+
+// recreate deletes the existing item with the given UID and creates obj in
+// its place. Dependents of the existing item are orphaned rather than
+// garbage-collected along with it.
+func (ctx *context) recreate(resourceClient client.Dynamic, existingUID types.UID, obj *unstructured.Unstructured) error {
+	name := obj.GetName()
+	orphan := metav1.DeletePropagationOrphan
+
+	err := resourceClient.Delete(name, &metav1.DeleteOptions{
+		PropagationPolicy: &orphan,
+		// make sure we don't delete an item that replaced the one we got
+		Preconditions: &metav1.Preconditions{UID: &existingUID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Errorf("error deleting %s to recreate it: %v", kube.NamespaceAndName(obj), err)
+	}
+
+	// wait for the item to be gone, e.g. once its finalizers have run
+	err = wait.PollImmediate(time.Second, recreateTimeout, func() (bool, error) {
+		_, err := resourceClient.Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return errors.Errorf("error waiting for %s to be deleted to recreate it: %v", kube.NamespaceAndName(obj), err)
+	}
+
+	if _, err := resourceClient.Create(obj); err != nil {
+		return errors.Errorf("error recreating %s: %v", kube.NamespaceAndName(obj), err)
+	}
+
+	return nil
+}
+
+// isImmutableFieldError returns true if err is the API server rejecting a
+// change to an immutable field.
+func isImmutableFieldError(err error) bool {
+	return apierrors.IsInvalid(err) && strings.Contains(err.Error(), "immutable")
+}
+
 func waitForReady(
 	watchChan <-chan watch.Event,
 	name string,
@@ -1063,16 +1324,17 @@ func (ctx *context) unmarshal(filePath string) (*unstructured.Unstructured, erro
 	return &obj, nil
 }
 
-// unzipAndExtractBackup extracts a reader on a gzipped tarball to a local temp directory
+// unzipAndExtractBackup extracts a reader on a compressed tarball to a local
+// temp directory, decompressing it with the algorithm recorded on the backup.
 func (ctx *context) unzipAndExtractBackup(src io.Reader) (string, error) {
-	gzr, err := gzip.NewReader(src)
+	zr, err := archive.NewReader(src, archive.CompressionAlgorithmForBackup(ctx.backup))
 	if err != nil {
-		ctx.log.Infof("error creating gzip reader: %v", err)
+		ctx.log.Infof("error creating decompressing reader: %v", err)
 		return "", err
 	}
-	defer gzr.Close()
+	defer zr.Close()
 
-	return ctx.readBackup(tar.NewReader(gzr))
+	return ctx.readBackup(tar.NewReader(zr))
 }
 
 // readBackup extracts a tar reader to a local directory/file tree within a
@@ -35,7 +35,9 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/watch"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
@@ -372,6 +374,7 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 		actions                 []resolvedAction
 		expectedErrors          api.RestoreResult
 		expectedObjs            []unstructured.Unstructured
+		expectedItemCounts      []api.RestoreItemCount
 	}{
 		{
 			name:          "basic normal case",
@@ -385,6 +388,7 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 				newNamedTestConfigMap("cm-1").ConfigMap,
 				newNamedTestConfigMap("cm-2").ConfigMap,
 			),
+			expectedItemCounts: []api.RestoreItemCount{{GroupResource: "configmaps", Restored: 2}},
 		},
 		{
 			name:         "no such directory causes error",
@@ -416,7 +420,8 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 					"ns-1": {"error decoding \"configmaps/cm-1-invalid.json\": invalid character 'h' in literal true (expecting 'r')"},
 				},
 			},
-			expectedObjs: toUnstructured(newNamedTestConfigMap("cm-2").ConfigMap),
+			expectedObjs:       toUnstructured(newNamedTestConfigMap("cm-2").ConfigMap),
+			expectedItemCounts: []api.RestoreItemCount{{GroupResource: "configmaps", Restored: 1}},
 		},
 		{
 			name:          "matching label selector correctly includes",
@@ -427,11 +432,12 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 			expectedObjs:  toUnstructured(newTestConfigMap().WithLabels(map[string]string{"foo": "bar"}).ConfigMap),
 		},
 		{
-			name:          "non-matching label selector correctly excludes",
-			namespace:     "ns-1",
-			resourcePath:  "configmaps",
-			labelSelector: labels.SelectorFromSet(labels.Set(map[string]string{"foo": "not-bar"})),
-			fileSystem:    arktest.NewFakeFileSystem().WithFile("configmaps/cm-1.json", newTestConfigMap().WithLabels(map[string]string{"foo": "bar"}).ToJSON()),
+			name:               "non-matching label selector correctly excludes",
+			namespace:          "ns-1",
+			resourcePath:       "configmaps",
+			labelSelector:      labels.SelectorFromSet(labels.Set(map[string]string{"foo": "not-bar"})),
+			fileSystem:         arktest.NewFakeFileSystem().WithFile("configmaps/cm-1.json", newTestConfigMap().WithLabels(map[string]string{"foo": "bar"}).ToJSON()),
+			expectedItemCounts: []api.RestoreItemCount{{GroupResource: "configmaps", Skipped: 1}},
 		},
 		{
 			name:          "namespace is remapped",
@@ -480,6 +486,7 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 			labelSelector:           labels.NewSelector(),
 			includeClusterResources: falsePtr,
 			fileSystem:              arktest.NewFakeFileSystem().WithFile("persistentvolumes/pv-1.json", newTestPV().ToJSON()),
+			expectedItemCounts:      []api.RestoreItemCount{{GroupResource: "persistentvolumes", Skipped: 1}},
 		},
 		{
 			name:                    "namespaced resources are not skipped when IncludeClusterResources=false",
@@ -577,6 +584,7 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 						WithAnnotations(v1.MirrorPodAnnotationKey).
 						ToJSON(),
 				),
+			expectedItemCounts: []api.RestoreItemCount{{GroupResource: "pods", Skipped: 1}},
 		},
 	}
 
@@ -617,6 +625,7 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 					Spec: api.RestoreSpec{
 						IncludeClusterResources: test.includeClusterResources,
 						BackupName:              "my-backup",
+						VerifyItemCounts:        true,
 					},
 				},
 				backup:     &api.Backup{},
@@ -630,6 +639,10 @@ func TestRestoreResourceForNamespace(t *testing.T) {
 			assert.Empty(t, warnings.Cluster)
 			assert.Empty(t, warnings.Namespaces)
 			assert.Equal(t, test.expectedErrors, errors)
+
+			if test.expectedItemCounts != nil {
+				assert.Equal(t, test.expectedItemCounts, ctx.sortedItemCounts())
+			}
 		})
 	}
 }
@@ -718,6 +731,142 @@ func TestRestoringExistingServiceAccount(t *testing.T) {
 	}
 }
 
+func TestRestoringExistingItemWithUpdatePolicy(t *testing.T) {
+	immutableErr := k8serrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "cm-1", field.ErrorList{
+		field.Invalid(field.NewPath("data"), nil, "field is immutable"),
+	})
+
+	tests := []struct {
+		name                 string
+		resource             string
+		immutableFieldPolicy api.ImmutableFieldPolicy
+		patchErr             error
+		expectRecreate       bool
+		expectWarning        bool
+		expectError          bool
+	}{
+		{
+			name:     "patch succeeds",
+			resource: "configmaps",
+		},
+		{
+			name:        "patch fails with an error that's not about immutable fields",
+			resource:    "configmaps",
+			patchErr:    errors.New("patch failed"),
+			expectError: true,
+		},
+		{
+			name:          "immutable fields differ and policy defaults to skip",
+			resource:      "configmaps",
+			patchErr:      immutableErr,
+			expectWarning: true,
+		},
+		{
+			name:                 "immutable fields differ and policy is fail",
+			resource:             "configmaps",
+			immutableFieldPolicy: api.ImmutableFieldPolicyFail,
+			patchErr:             immutableErr,
+			expectError:          true,
+		},
+		{
+			name:                 "immutable fields differ and policy is recreate",
+			resource:             "configmaps",
+			immutableFieldPolicy: api.ImmutableFieldPolicyRecreate,
+			patchErr:             immutableErr,
+			expectRecreate:       true,
+			expectWarning:        true,
+		},
+		{
+			name:                 "persistent volume claims are skipped rather than recreated",
+			resource:             "persistentvolumeclaims",
+			immutableFieldPolicy: api.ImmutableFieldPolicyRecreate,
+			patchErr:             immutableErr,
+			expectWarning:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fromBackup := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata": map[string]interface{}{
+					"namespace": "ns-1",
+					"name":      "cm-1",
+				},
+				"data": map[string]interface{}{"foo": "bar"},
+			}}
+			if test.resource == "persistentvolumeclaims" {
+				fromBackup.SetKind("PersistentVolumeClaim")
+				unstructured.SetNestedField(fromBackup.Object, "pv-1", "spec", "volumeName")
+			}
+			fromCluster := fromBackup.DeepCopy()
+			fromCluster.SetUID("existing-uid")
+			unstructured.SetNestedField(fromCluster.Object, "baz", "data", "foo")
+
+			resourceClient := &arktest.FakeDynamicClient{}
+			defer resourceClient.AssertExpectations(t)
+
+			resourceClient.On("Create", mock.Anything).Return(new(unstructured.Unstructured), k8serrors.NewAlreadyExists(schema.GroupResource{Resource: test.resource}, "cm-1")).Once()
+			resourceClient.On("Get", "cm-1", metav1.GetOptions{}).Return(fromCluster, nil).Once()
+			resourceClient.On("Patch", "cm-1", mock.Anything).Return(new(unstructured.Unstructured), test.patchErr)
+
+			if test.expectRecreate {
+				orphan := metav1.DeletePropagationOrphan
+				uid := types.UID("existing-uid")
+				resourceClient.On("Delete", "cm-1", &metav1.DeleteOptions{
+					PropagationPolicy: &orphan,
+					Preconditions:     &metav1.Preconditions{UID: &uid},
+				}).Return(nil)
+				resourceClient.On("Get", "cm-1", metav1.GetOptions{}).Return(new(unstructured.Unstructured), k8serrors.NewNotFound(schema.GroupResource{Resource: test.resource}, "cm-1")).Once()
+				resourceClient.On("Create", mock.Anything).Return(new(unstructured.Unstructured), nil).Once()
+			}
+
+			dynamicFactory := &arktest.FakeDynamicFactory{}
+			resource := metav1.APIResource{Name: test.resource, Namespaced: true}
+			dynamicFactory.On("ClientForGroupVersionResource", schema.GroupVersion{Version: "v1"}, resource, "ns-1").Return(resourceClient, nil)
+
+			fromBackupJSON, err := json.Marshal(fromBackup)
+			require.NoError(t, err)
+
+			ctx := &context{
+				dynamicFactory: dynamicFactory,
+				actions:        []resolvedAction{},
+				fileSystem: arktest.NewFakeFileSystem().
+					WithFile("foo/resources/"+test.resource+"/namespaces/ns-1/cm-1.json", fromBackupJSON),
+				selector: labels.NewSelector(),
+				restore: &api.Restore{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: api.DefaultNamespace,
+						Name:      "my-restore",
+					},
+					Spec: api.RestoreSpec{
+						BackupName:             "my-backup",
+						ExistingResourcePolicy: api.ExistingResourcePolicyUpdate,
+						ImmutableFieldPolicy:   test.immutableFieldPolicy,
+					},
+				},
+				backup: &api.Backup{},
+				log:    arktest.NewLogger(),
+			}
+
+			warnings, errs := ctx.restoreResource(test.resource, "ns-1", "foo/resources/"+test.resource+"/namespaces/ns-1/")
+
+			assert.Equal(t, test.expectWarning, len(warnings.Namespaces["ns-1"]) > 0, "warnings: %v", warnings.Namespaces)
+			assert.Equal(t, test.expectError, len(errs.Namespaces["ns-1"]) > 0, "errors: %v", errs.Namespaces)
+		})
+	}
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	gk := schema.GroupKind{Kind: "ConfigMap"}
+
+	assert.True(t, isImmutableFieldError(k8serrors.NewInvalid(gk, "cm-1", field.ErrorList{field.Invalid(field.NewPath("data"), nil, "field is immutable")})))
+	assert.False(t, isImmutableFieldError(k8serrors.NewInvalid(gk, "cm-1", field.ErrorList{field.Required(field.NewPath("data"), "")})))
+	assert.False(t, isImmutableFieldError(k8serrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, "cm-1")))
+	assert.False(t, isImmutableFieldError(errors.New("field is immutable")))
+}
+
 func TestRestoringPVsWithoutSnapshots(t *testing.T) {
 	pv := `apiVersion: v1
 kind: PersistentVolume
@@ -1668,3 +1817,56 @@ func (nsc *fakeNamespaceClient) Create(ns *v1.Namespace) (*v1.Namespace, error)
 	nsc.createdNamespaces = append(nsc.createdNamespaces, ns)
 	return ns, nil
 }
+
+func TestUnservedGroupResources(t *testing.T) {
+	apiGroups := []metav1.APIGroup{
+		{
+			Name:             "",
+			Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "v1", Version: "v1"}},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"},
+		},
+		{
+			Name: "apps",
+			Versions: []metav1.GroupVersionForDiscovery{
+				{GroupVersion: "apps/v1", Version: "v1"},
+				{GroupVersion: "apps/v1beta2", Version: "v1beta2"},
+			},
+			PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		groupVersions map[string]string
+		expected      []string
+	}{
+		{
+			name: "backups from before group versions were recorded",
+		},
+		{
+			name: "preferred and other served versions",
+			groupVersions: map[string]string{
+				"pods":             "v1",
+				"deployments.apps": "apps/v1",
+				"replicasets.apps": "apps/v1beta2",
+			},
+		},
+		{
+			name: "versions that are no longer served",
+			groupVersions: map[string]string{
+				"pods":                         "v1",
+				"statefulsets.apps":            "apps/v1beta1",
+				"deployments.apps":             "apps/v1beta1",
+				"cronjobs.batch":               "batch/v2alpha1",
+				"certificatesigningrequests.x": "x/v1",
+			},
+			expected: []string{"certificatesigningrequests.x", "cronjobs.batch", "deployments.apps", "statefulsets.apps"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, unservedGroupResources(test.groupVersions, apiGroups))
+		})
+	}
+}
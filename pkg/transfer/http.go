@@ -0,0 +1,299 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transfer
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+const backupsPath = "/backups/"
+
+// httpEndpoint is an Endpoint backed by a transfer server (see NewServer).
+// Backups are sent with a PUT and received with a GET to
+// <baseURL>/backups/<name>.
+type httpEndpoint struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPEndpoint returns an Endpoint that streams backups through the
+// transfer server at baseURL, which must be an https URL. Requests are
+// authenticated with token as a bearer token. If caCert is non-empty, it's
+// the PEM-encoded certificates that the server's certificate is verified
+// against instead of the system's roots.
+func NewHTTPEndpoint(baseURL, token string, caCert []byte) (Endpoint, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing transfer endpoint URL")
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, errors.Errorf("transfer endpoint URL %q must be an https URL", baseURL)
+	}
+	if token == "" {
+		return nil, errors.New("a token is required to authenticate to the transfer endpoint")
+	}
+
+	tlsConfig := new(tls.Config)
+	if len(caCert) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no certificates found in the transfer endpoint's CA certificate")
+		}
+	}
+
+	return &httpEndpoint{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+func (e *httpEndpoint) backupURL(name string) string {
+	return e.baseURL + backupsPath + url.PathEscape(name)
+}
+
+func (e *httpEndpoint) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+e.token)
+	return e.client.Do(req)
+}
+
+func (e *httpEndpoint) Send(backup *api.Backup, contents io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(WriteStream(pw, backup, contents))
+	}()
+	defer pr.Close()
+
+	req, err := http.NewRequest(http.MethodPut, e.backupURL(backup.Name), pr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	resp, err := e.do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending backup to transfer endpoint")
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+func (e *httpEndpoint) Receive(name string) (*api.Backup, io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, e.backupURL(name), nil)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	resp, err := e.do(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error receiving backup from transfer endpoint")
+	}
+
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+
+	backup, contents, err := ReadStream(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+
+	return backup, readCloser{Reader: contents, Closer: resp.Body}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return errors.Errorf("transfer endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// server is the rendezvous point between senders and receivers.
+type server struct {
+	logger      logrus.FieldLogger
+	waitTimeout time.Duration
+	token       string
+
+	lock sync.Mutex
+	// sends holds, for each backup name, the channel a sender hands its
+	// stream to a receiver over. It's created by whichever side arrives
+	// first.
+	sends map[string]chan *send
+}
+
+// send is a stream handed from a sender to a receiver. The receiver reports
+// the outcome of copying the stream on done.
+type send struct {
+	body io.Reader
+	done chan error
+}
+
+// NewServer returns an http.Handler for a transfer server. Each backup sent
+// to it is held until a receiver asks for the same backup, and is then
+// copied straight from the sender's request to the receiver's response, so
+// nothing is buffered beyond what's in flight. Senders and receivers that
+// wait longer than waitTimeout for the other side get a 504 response.
+// Requests that don't carry token as a bearer token get a 401 response.
+func NewServer(logger logrus.FieldLogger, waitTimeout time.Duration, token string) http.Handler {
+	return &server{
+		logger:      logger,
+		waitTimeout: waitTimeout,
+		token:       token,
+		sends:       make(map[string]chan *send),
+	}
+}
+
+func (s *server) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+
+	auth := r.Header.Get("Authorization")
+	if s.token == "" || !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(s.token)) == 1
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, backupsPath) || len(r.URL.Path) == len(backupsPath) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, backupsPath)
+
+	switch r.Method {
+	case http.MethodPut:
+		s.handleSend(w, r, name)
+	case http.MethodGet:
+		s.handleReceive(w, r, name)
+	default:
+		w.Header().Set("Allow", fmt.Sprintf("%s, %s", http.MethodGet, http.MethodPut))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) channelFor(name string) chan *send {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ch, found := s.sends[name]
+	if !found {
+		ch = make(chan *send)
+		s.sends[name] = ch
+	}
+	return ch
+}
+
+func (s *server) release(name string, ch chan *send) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.sends[name] == ch {
+		delete(s.sends, name)
+	}
+}
+
+func (s *server) handleSend(w http.ResponseWriter, r *http.Request, name string) {
+	log := s.logger.WithField("backup", name)
+	log.Info("Waiting for a receiver")
+
+	snd := &send{body: r.Body, done: make(chan error, 1)}
+
+	ch := s.channelFor(name)
+	defer s.release(name, ch)
+
+	timeout := time.NewTimer(s.waitTimeout)
+	defer timeout.Stop()
+
+	select {
+	case ch <- snd:
+	case <-timeout.C:
+		log.Info("Timed out waiting for a receiver")
+		http.Error(w, "timed out waiting for a receiver", http.StatusGatewayTimeout)
+		return
+	case <-r.Context().Done():
+		log.Info("Sender went away before a receiver arrived")
+		return
+	}
+
+	if err := <-snd.done; err != nil {
+		log.WithError(err).Error("Error transferring backup")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	log.Info("Backup transferred")
+}
+
+func (s *server) handleReceive(w http.ResponseWriter, r *http.Request, name string) {
+	ch := s.channelFor(name)
+	defer s.release(name, ch)
+
+	timeout := time.NewTimer(s.waitTimeout)
+	defer timeout.Stop()
+
+	var snd *send
+	select {
+	case snd = <-ch:
+	case <-timeout.C:
+		http.Error(w, "timed out waiting for a sender", http.StatusGatewayTimeout)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(w, snd.body)
+	snd.done <- errors.WithStack(err)
+}
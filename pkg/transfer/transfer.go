@@ -0,0 +1,75 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package transfer streams backups directly from the cluster taking them to
+// a cluster restoring them, without writing them to object storage.
+package transfer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// Endpoint is a shared transfer endpoint that a backing-up cluster and a
+// restoring cluster rendezvous at to stream a backup between them.
+type Endpoint interface {
+	// Send streams the backup's metadata and gzipped tarball to the
+	// endpoint. It blocks until a receiver has consumed the stream.
+	Send(backup *api.Backup, contents io.Reader) error
+
+	// Receive blocks until the named backup is sent to the endpoint, and
+	// returns its metadata and a reader for its gzipped tarball. The caller
+	// must close the reader.
+	Receive(name string) (*api.Backup, io.ReadCloser, error)
+}
+
+// WriteStream writes the backup's metadata followed by its contents to w.
+// The metadata is written as a single line of JSON, which is safe because
+// JSON encoding escapes any newlines within strings.
+func WriteStream(w io.Writer, backup *api.Backup, contents io.Reader) error {
+	if err := json.NewEncoder(w).Encode(backup); err != nil {
+		return errors.Wrap(err, "error encoding backup metadata")
+	}
+
+	if _, err := io.Copy(w, contents); err != nil {
+		return errors.Wrap(err, "error writing backup contents")
+	}
+
+	return nil
+}
+
+// ReadStream reads a stream written by WriteStream from r, returning the
+// backup's metadata and a reader positioned at the start of its contents.
+func ReadStream(r io.Reader) (*api.Backup, io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error reading backup metadata")
+	}
+
+	backup := new(api.Backup)
+	if err := json.Unmarshal(line, backup); err != nil {
+		return nil, nil, errors.Wrap(err, "error decoding backup metadata")
+	}
+
+	return backup, br, nil
+}
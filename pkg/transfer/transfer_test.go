@@ -0,0 +1,184 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transfer
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/heptio/ark/pkg/apis/ark/v1"
+	arktest "github.com/heptio/ark/pkg/util/test"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	backup := &api.Backup{}
+	backup.Name = "backup-1"
+	backup.Spec.Description = "line one\nline two"
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteStream(buf, backup, strings.NewReader("contents\nwith newlines")))
+
+	res, contents, err := ReadStream(buf)
+	require.NoError(t, err)
+	assert.Equal(t, backup, res)
+
+	data, err := ioutil.ReadAll(contents)
+	require.NoError(t, err)
+	assert.Equal(t, "contents\nwith newlines", string(data))
+}
+
+const testToken = "token-1"
+
+// newTestEndpoint starts a TLS transfer server and returns it along with
+// an Endpoint that trusts its certificate.
+func newTestEndpoint(t *testing.T, waitTimeout time.Duration) (*httptest.Server, Endpoint) {
+	server := httptest.NewTLSServer(NewServer(arktest.NewLogger(), waitTimeout, testToken))
+
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	endpoint, err := NewHTTPEndpoint(server.URL+"/", testToken, caCert)
+	require.NoError(t, err)
+
+	return server, endpoint
+}
+
+func TestHTTPEndpoint(t *testing.T) {
+	server, endpoint := newTestEndpoint(t, time.Minute)
+	defer server.Close()
+
+	backup := &api.Backup{}
+	backup.Name = "backup-1"
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- endpoint.Send(backup, strings.NewReader("contents"))
+	}()
+
+	res, contents, err := endpoint.Receive("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, "backup-1", res.Name)
+
+	data, err := ioutil.ReadAll(contents)
+	require.NoError(t, err)
+	require.NoError(t, contents.Close())
+	assert.Equal(t, "contents", string(data))
+
+	assert.NoError(t, <-sendErr)
+}
+
+func TestHTTPEndpointWaitTimeout(t *testing.T) {
+	handler := NewServer(arktest.NewLogger(), time.Millisecond, testToken)
+	ts := httptest.NewTLSServer(handler)
+	defer ts.Close()
+
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	endpoint, err := NewHTTPEndpoint(ts.URL, testToken, caCert)
+	require.NoError(t, err)
+
+	_, _, err = endpoint.Receive("backup-1")
+	assert.Error(t, err)
+
+	backup := &api.Backup{}
+	backup.Name = "backup-1"
+	assert.Error(t, endpoint.Send(backup, strings.NewReader("contents")))
+
+	// neither side that timed out leaves its rendezvous behind.
+	assert.Empty(t, handler.(*server).sends)
+}
+
+func TestNewHTTPEndpointValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		token       string
+		caCert      []byte
+		expectedErr string
+	}{
+		{
+			name:        "http URL is rejected",
+			url:         "http://transfer:8086",
+			token:       testToken,
+			expectedErr: "must be an https URL",
+		},
+		{
+			name:        "missing token is rejected",
+			url:         "https://transfer:8086",
+			expectedErr: "a token is required",
+		},
+		{
+			name:        "CA certificate without certificates is rejected",
+			url:         "https://transfer:8086",
+			token:       testToken,
+			caCert:      []byte("not a certificate"),
+			expectedErr: "no certificates found",
+		},
+		{
+			name:  "https URL with a token is accepted",
+			url:   "https://transfer:8086",
+			token: testToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewHTTPEndpoint(test.url, test.token, test.caCert)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestServerRejectsUnknownRequests(t *testing.T) {
+	handler := NewServer(arktest.NewLogger(), time.Millisecond, testToken)
+
+	tests := []struct {
+		method, path string
+		token        string
+		expected     int
+	}{
+		{method: http.MethodGet, path: "/other", token: testToken, expected: http.StatusNotFound},
+		{method: http.MethodGet, path: "/backups/", token: testToken, expected: http.StatusNotFound},
+		{method: http.MethodPost, path: "/backups/backup-1", token: testToken, expected: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/backups/backup-1", expected: http.StatusUnauthorized},
+		{method: http.MethodPut, path: "/backups/backup-1", token: "wrong", expected: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path+" "+test.token, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, test.expected, rec.Code)
+		})
+	}
+}
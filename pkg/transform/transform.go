@@ -0,0 +1,200 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package transform applies an ordered pipeline of transforms, such as
+// encryption or compression, to a backup's tarball before it's uploaded,
+// and undoes them when the backup is restored.
+package transform
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	arkv1api "github.com/heptio/ark/pkg/apis/ark/v1"
+)
+
+// Stage is a single transform in a pipeline.
+type Stage interface {
+	// Name identifies the stage. It's recorded on each backup the stage is
+	// applied to, so that it can be undone when the backup is restored.
+	Name() string
+
+	// Wrap returns a writer that transforms the data written to it and
+	// writes the result to w. Closing the returned writer flushes any
+	// buffered data to w, but doesn't close w.
+	Wrap(w io.Writer) (io.WriteCloser, error)
+
+	// Unwrap returns a reader that undoes the stage's transform on the
+	// data read from r.
+	Unwrap(r io.Reader) (io.Reader, error)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]func() Stage{
+		"gzip": func() Stage { return gzipStage{} },
+	}
+)
+
+// Register makes a stage available by name to NewPipeline. It panics if a
+// stage with the same name is already registered.
+func Register(name string, newStage func() Stage) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, found := registry[name]; found {
+		panic("transform stage " + name + " is already registered")
+	}
+	registry[name] = newStage
+}
+
+// StageNames returns the sorted names of all registered stages.
+func StageNames() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	return stageNamesLocked()
+}
+
+// Pipeline is an ordered list of stages. Data written through the pipeline
+// passes through each stage in order.
+type Pipeline []Stage
+
+// NewPipeline returns a pipeline made up of the named stages, in order.
+func NewPipeline(names []string) (Pipeline, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	var pipeline Pipeline
+	for _, name := range names {
+		newStage, found := registry[name]
+		if !found {
+			return nil, errors.Errorf("unknown transform stage %q (valid stages are %s)", name, strings.Join(stageNamesLocked(), ", "))
+		}
+		pipeline = append(pipeline, newStage())
+	}
+
+	return pipeline, nil
+}
+
+// stageNamesLocked must be called with registryLock held.
+func stageNamesLocked() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePipeline returns the pipeline for a comma-separated list of stage
+// names, as recorded by Pipeline.String. An empty string is an empty
+// pipeline.
+func ParsePipeline(names string) (Pipeline, error) {
+	if names == "" {
+		return nil, nil
+	}
+	return NewPipeline(strings.Split(names, ","))
+}
+
+// ForBackup returns the pipeline recorded in the backup's
+// TransformsAnnotation.
+func ForBackup(backup *arkv1api.Backup) (Pipeline, error) {
+	return ParsePipeline(backup.Annotations[arkv1api.TransformsAnnotation])
+}
+
+// String returns the comma-separated names of the pipeline's stages.
+func (p Pipeline) String() string {
+	names := make([]string, 0, len(p))
+	for _, stage := range p {
+		names = append(names, stage.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Wrap returns a writer that passes the data written to it through each
+// of the pipeline's stages in order, writing the result to w. The returned
+// writer must be closed to flush all stages; closing it doesn't close w.
+func (p Pipeline) Wrap(w io.Writer) (io.WriteCloser, error) {
+	writers := make([]io.WriteCloser, len(p))
+
+	next := w
+	for i := len(p) - 1; i >= 0; i-- {
+		wc, err := p[i].Wrap(next)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating transform stage %s", p[i].Name())
+		}
+		writers[i] = wc
+		next = wc
+	}
+
+	return &pipelineWriter{Writer: next, writers: writers}, nil
+}
+
+// Unwrap returns a reader that undoes the pipeline's stages, in reverse
+// order, on the data read from r.
+func (p Pipeline) Unwrap(r io.Reader) (io.Reader, error) {
+	next := r
+	for i := len(p) - 1; i >= 0; i-- {
+		reader, err := p[i].Unwrap(next)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error undoing transform stage %s", p[i].Name())
+		}
+		next = reader
+	}
+
+	return next, nil
+}
+
+// pipelineWriter closes each stage's writer in order, so that each stage
+// flushes into the next before it's closed.
+type pipelineWriter struct {
+	io.Writer
+	writers []io.WriteCloser
+}
+
+func (w *pipelineWriter) Close() error {
+	for _, wc := range w.writers {
+		if err := wc.Close(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// gzipStage compresses its data with gzip.
+type gzipStage struct{}
+
+func (gzipStage) Name() string {
+	return "gzip"
+}
+
+func (gzipStage) Wrap(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipStage) Unwrap(r io.Reader) (io.Reader, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return gzr, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixStage prepends its prefix to the data, so tests can check the
+// order stages are applied in.
+type prefixStage struct {
+	prefix string
+}
+
+func (s prefixStage) Name() string {
+	return "prefix-" + s.prefix
+}
+
+func (s prefixStage) Wrap(w io.Writer) (io.WriteCloser, error) {
+	if _, err := w.Write([]byte(s.prefix)); err != nil {
+		return nil, err
+	}
+	return nopWriteCloser{w}, nil
+}
+
+func (s prefixStage) Unwrap(r io.Reader) (io.Reader, error) {
+	prefix := make([]byte, len(s.prefix))
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if string(prefix) != s.prefix {
+		return nil, errors.Errorf("expected prefix %q, got %q", s.prefix, prefix)
+	}
+	return r, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestPipelineRoundTrip(t *testing.T) {
+	pipeline := Pipeline{prefixStage{"a"}, gzipStage{}, prefixStage{"b"}}
+	assert.Equal(t, "prefix-a,gzip,prefix-b", pipeline.String())
+
+	buf := new(bytes.Buffer)
+	w, err := pipeline.Wrap(buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("contents"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// the last stage's output is outermost
+	require.Equal(t, "b", buf.String()[:1])
+	gzr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()[1:]))
+	require.NoError(t, err)
+	inner, err := ioutil.ReadAll(gzr)
+	require.NoError(t, err)
+	assert.Equal(t, "acontents", string(inner))
+
+	r, err := pipeline.Unwrap(buf)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(contents))
+}
+
+func TestEmptyPipeline(t *testing.T) {
+	pipeline, err := ParsePipeline("")
+	require.NoError(t, err)
+	assert.Empty(t, pipeline)
+
+	buf := new(bytes.Buffer)
+	w, err := pipeline.Wrap(buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("contents"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, "contents", buf.String())
+
+	r, err := pipeline.Unwrap(buf)
+	require.NoError(t, err)
+	assert.Equal(t, buf, r)
+}
+
+func TestNewPipeline(t *testing.T) {
+	pipeline, err := ParsePipeline("gzip,gzip")
+	require.NoError(t, err)
+	assert.Equal(t, Pipeline{gzipStage{}, gzipStage{}}, pipeline)
+
+	_, err = NewPipeline([]string{"gzip", "missing"})
+	assert.EqualError(t, err, `unknown transform stage "missing" (valid stages are gzip)`)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package collections
 
 import (
+	"path"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -28,10 +29,13 @@ import (
 // and excluded items. The logic implemented is that everything
 // in the included list except those items in the excluded list
 // should be included. '*' in the includes list means "include
-// everything", but it is not valid in the exclude list.
+// everything", but it is not valid in the exclude list. If
+// MatchGlobs is called, items in both lists are instead shell-style
+// glob patterns.
 type IncludesExcludes struct {
 	includes sets.String
 	excludes sets.String
+	globs    bool
 }
 
 func NewIncludesExcludes() *IncludesExcludes {
@@ -64,10 +68,40 @@ func (ie *IncludesExcludes) GetExcludes() []string {
 	return ie.excludes.List()
 }
 
+// MatchGlobs makes the items in the includes and excludes lists match
+// as shell-style glob patterns, with the syntax of path.Match, rather
+// than exactly.
+func (ie *IncludesExcludes) MatchGlobs() *IncludesExcludes {
+	ie.globs = true
+	return ie
+}
+
+// IncludesPatterns returns true if ie matches globs and its includes
+// list has a pattern that can match more than one item, so the
+// included items can't be enumerated from the list itself.
+func (ie *IncludesExcludes) IncludesPatterns() bool {
+	if !ie.globs {
+		return false
+	}
+	for _, item := range ie.includes.List() {
+		if strings.ContainsAny(item, `*?[\`) {
+			return true
+		}
+	}
+	return false
+}
+
 // ShouldInclude returns whether the specified item should be
 // included or not. Everything in the includes list except those
 // items in the excludes list should be included.
 func (ie *IncludesExcludes) ShouldInclude(s string) bool {
+	if ie.globs {
+		if matchesAny(ie.excludes, s) {
+			return false
+		}
+		return ie.includes.Len() == 0 || matchesAny(ie.includes, s)
+	}
+
 	if ie.excludes.Has(s) {
 		return false
 	}
@@ -76,6 +110,17 @@ func (ie *IncludesExcludes) ShouldInclude(s string) bool {
 	return ie.includes.Len() == 0 || ie.includes.Has("*") || ie.includes.Has(s)
 }
 
+// matchesAny returns true if s matches any of patterns. Invalid
+// patterns match nothing.
+func matchesAny(patterns sets.String, s string) bool {
+	for _, pattern := range patterns.List() {
+		if matched, _ := path.Match(pattern, s); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // IncludesString returns a string containing all of the includes, separated by commas, or * if the
 // list is empty.
 func (ie *IncludesExcludes) IncludesString() string {
@@ -130,6 +175,20 @@ func ValidateIncludesExcludes(includesList, excludesList []string) []error {
 	return errs
 }
 
+// ValidateGlobs checks that each of patterns is a valid glob pattern
+// for an IncludesExcludes that matches globs.
+func ValidateGlobs(patterns []string) []error {
+	var errs []error
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, errors.Errorf("invalid glob pattern %q: %v", pattern, err))
+		}
+	}
+
+	return errs
+}
+
 // GenerateIncludesExcludes constructs an IncludesExcludes struct by taking the provided
 // include/exclude slices, applying the specified mapping function to each item in them,
 // and adding the output of the function to the new struct. If the mapping function returns
@@ -89,6 +89,102 @@ func TestShouldInclude(t *testing.T) {
 	}
 }
 
+func TestShouldIncludeGlobs(t *testing.T) {
+	namespaces := []string{"default", "kube-system", "kube-public", "team-a", "team-b", "prod-01", "prod-1", "prod-123"}
+
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		expected []string
+	}{
+		{
+			name:     "empty - include everything",
+			expected: namespaces,
+		},
+		{
+			name:     "include *",
+			includes: []string{"*"},
+			expected: namespaces,
+		},
+		{
+			name:     "prefix pattern",
+			includes: []string{"team-*"},
+			expected: []string{"team-a", "team-b"},
+		},
+		{
+			name:     "single-character wildcards",
+			includes: []string{"prod-??"},
+			expected: []string{"prod-01"},
+		},
+		{
+			name:     "character class",
+			includes: []string{"team-[b-z]"},
+			expected: []string{"team-b"},
+		},
+		{
+			name:     "several patterns and names",
+			includes: []string{"team-*", "prod-?", "default"},
+			expected: []string{"default", "team-a", "team-b", "prod-1"},
+		},
+		{
+			name:     "include *, exclude pattern",
+			includes: []string{"*"},
+			excludes: []string{"kube-*"},
+			expected: []string{"default", "team-a", "team-b", "prod-01", "prod-1", "prod-123"},
+		},
+		{
+			name:     "exclude wins over include",
+			includes: []string{"prod-*"},
+			excludes: []string{"prod-1*"},
+			expected: []string{"prod-01"},
+		},
+		{
+			name:     "invalid pattern matches nothing",
+			includes: []string{"team-["},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ie := NewIncludesExcludes().Includes(test.includes...).Excludes(test.excludes...).MatchGlobs()
+
+			var included []string
+			for _, ns := range namespaces {
+				if ie.ShouldInclude(ns) {
+					included = append(included, ns)
+				}
+			}
+			assert.Equal(t, test.expected, included)
+		})
+	}
+}
+
+func TestShouldIncludeExactIgnoresPatterns(t *testing.T) {
+	ie := NewIncludesExcludes().Includes("team-*")
+
+	assert.False(t, ie.ShouldInclude("team-a"))
+	assert.True(t, ie.ShouldInclude("team-*"))
+	assert.False(t, ie.IncludesPatterns())
+}
+
+func TestIncludesPatterns(t *testing.T) {
+	assert.False(t, NewIncludesExcludes().Includes("team-a", "team-b").MatchGlobs().IncludesPatterns())
+	assert.False(t, NewIncludesExcludes().Includes("team-a").Excludes("kube-*").MatchGlobs().IncludesPatterns())
+	assert.True(t, NewIncludesExcludes().Includes("team-a", "prod-??").MatchGlobs().IncludesPatterns())
+	assert.True(t, NewIncludesExcludes().Includes("team-[ab]").MatchGlobs().IncludesPatterns())
+}
+
+func TestValidateGlobs(t *testing.T) {
+	assert.Empty(t, ValidateGlobs([]string{"*", "team-*", "prod-??", "team-[a-c]", "default"}))
+
+	errs := ValidateGlobs([]string{"team-[", "ok", "prod-\\"})
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), `invalid glob pattern "team-["`)
+	assert.Contains(t, errs[1].Error(), `invalid glob pattern "prod-\\"`)
+}
+
 func TestValidateIncludesExcludes(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,62 @@
+/*
+Copyright 2018 the Heptio Ark contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/heptio/ark/pkg/cmd/util/flag"
+)
+
+// Format is a format that log entries can be written in.
+type Format string
+
+const (
+	// FormatText writes each entry as key=value pairs, which is logrus's
+	// default.
+	FormatText Format = "text"
+
+	// FormatJSON writes each entry as a line of JSON.
+	FormatJSON Format = "json"
+)
+
+// FormatFlag is a command-line flag for setting a log format.
+type FormatFlag struct {
+	*flag.Enum
+}
+
+// LogFormatFlag constructs a new log format flag.
+func LogFormatFlag(defaultValue Format) *FormatFlag {
+	return &FormatFlag{
+		Enum: flag.NewEnum(string(defaultValue), string(FormatText), string(FormatJSON)),
+	}
+}
+
+// Parse returns the flag's value as a Format.
+func (f *FormatFlag) Parse() Format {
+	return Format(f.String())
+}
+
+// SetFormat configures logger to write its entries in format. Loggers
+// write text unless they're configured otherwise.
+func SetFormat(logger *logrus.Logger, format Format) {
+	switch format {
+	case FormatJSON:
+		logger.Formatter = new(logrus.JSONFormatter)
+	default:
+		logger.Formatter = new(logrus.TextFormatter)
+	}
+}
@@ -57,6 +57,15 @@ func (b *TestBackup) WithLabel(key, value string) *TestBackup {
 	return b
 }
 
+func (b *TestBackup) WithAnnotation(key, value string) *TestBackup {
+	if b.Annotations == nil {
+		b.Annotations = make(map[string]string)
+	}
+	b.Annotations[key] = value
+
+	return b
+}
+
 func (b *TestBackup) WithPhase(phase v1.BackupPhase) *TestBackup {
 	b.Status.Phase = phase
 	return b
@@ -82,6 +91,11 @@ func (b *TestBackup) WithExcludedNamespaces(ns ...string) *TestBackup {
 	return b
 }
 
+func (b *TestBackup) WithTerminatingNamespacePolicy(policy v1.TerminatingNamespacePolicy) *TestBackup {
+	b.Spec.TerminatingNamespacePolicy = policy
+	return b
+}
+
 func (b *TestBackup) WithTTL(ttl time.Duration) *TestBackup {
 	b.Spec.TTL = metav1.Duration{Duration: ttl}
 	return b
@@ -92,6 +106,11 @@ func (b *TestBackup) WithExpiration(expiration time.Time) *TestBackup {
 	return b
 }
 
+func (b *TestBackup) WithObjectLockExpiration(expiration time.Time) *TestBackup {
+	b.Status.ObjectLockExpiration = metav1.Time{Time: expiration}
+	return b
+}
+
 func (b *TestBackup) WithVersion(version int) *TestBackup {
 	b.Status.Version = version
 	return b
@@ -131,12 +150,53 @@ func (b *TestBackup) WithFinalizers(finalizers ...string) *TestBackup {
 	return b
 }
 
+func (b *TestBackup) WithOwnerReferences(refs ...metav1.OwnerReference) *TestBackup {
+	b.ObjectMeta.OwnerReferences = append(b.ObjectMeta.OwnerReferences, refs...)
+
+	return b
+}
+
 func (b *TestBackup) WithStartTimestamp(startTime time.Time) *TestBackup {
 	b.Status.StartTimestamp = metav1.Time{Time: startTime}
 	return b
 }
 
+func (b *TestBackup) WithCompletionTimestamp(completionTime time.Time) *TestBackup {
+	b.Status.CompletionTimestamp = metav1.Time{Time: completionTime}
+	return b
+}
+
+func (b *TestBackup) WithFailureReason(reason string) *TestBackup {
+	b.Status.FailureReason = reason
+	return b
+}
+
+func (b *TestBackup) WithNotifyWebhook(url string) *TestBackup {
+	b.Spec.NotifyWebhook = &v1.BackupNotifyWebhook{URL: url}
+	return b
+}
+
 func (b *TestBackup) WithStorageLocation(location string) *TestBackup {
 	b.Spec.StorageLocation = location
 	return b
 }
+
+func (b *TestBackup) WithDryRun(value bool) *TestBackup {
+	b.Spec.DryRun = value
+	return b
+}
+
+func (b *TestBackup) WithCancel(value bool) *TestBackup {
+	b.Spec.Cancel = value
+	return b
+}
+
+func (b *TestBackup) WithMirrorStorageLocations(locations ...string) *TestBackup {
+	b.Spec.MirrorStorageLocations = locations
+	return b
+}
+
+func (b *TestBackup) WithBaseBackup(name string) *TestBackup {
+	b.Spec.BaseBackup = name
+	return b
+}
@@ -54,6 +54,21 @@ func (s *TestSchedule) WithCronSchedule(cronExpression string) *TestSchedule {
 	return s
 }
 
+func (s *TestSchedule) WithBackupNameTemplate(template string) *TestSchedule {
+	s.Spec.BackupNameTemplate = template
+	return s
+}
+
+func (s *TestSchedule) WithJitterMaxSeconds(seconds int64) *TestSchedule {
+	s.Spec.JitterMaxSeconds = seconds
+	return s
+}
+
+func (s *TestSchedule) WithBackupOwnerReferences(refs ...metav1.OwnerReference) *TestSchedule {
+	s.Spec.BackupOwnerReferences = refs
+	return s
+}
+
 func (s *TestSchedule) WithLastBackupTime(timeString string) *TestSchedule {
 	t, _ := time.Parse("2006-01-02 15:04:05", timeString)
 	s.Status.LastBackup = metav1.Time{Time: t}